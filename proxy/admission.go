@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// admissionOverrideParam carries the reason for creating or updating a connector in
+// spite of policy violations. It is kept in the audit entry's parameters.
+const admissionOverrideParam = "overridePolicy"
+
+var (
+	// admissionPolicyPath points at an optional JSON or YAML file of connector policies
+	// checked on every create and config update. It is re-read on SIGHUP.
+	admissionPolicyPath = getEnv("ADMISSION_POLICY_FILE", "")
+	// admissionOverrideGroups lists the groups allowed to override policy violations.
+	admissionOverrideGroups = getEnv("ADMISSION_OVERRIDE_GROUPS", "")
+)
+
+// AdmissionPolicy is the on-disk format of ADMISSION_POLICY_FILE.
+type AdmissionPolicy struct {
+	Rules []AdmissionRule `json:"rules" yaml:"rules"`
+}
+
+// AdmissionRule is one named policy. Every check that is set must pass.
+type AdmissionRule struct {
+	Name string `json:"name" yaml:"name"`
+	// AppliesTo restricts the rule to connector classes matching this regular
+	// expression, e.g. "Sink" for a DLQ requirement. Empty applies to every connector.
+	AppliesTo string `json:"appliesTo" yaml:"appliesTo"`
+	// NamePattern is a regular expression connector names must match.
+	NamePattern string `json:"namePattern" yaml:"namePattern"`
+	// RequiredKeys are config keys that must be present and non-empty.
+	RequiredKeys []string `json:"requiredKeys" yaml:"requiredKeys"`
+	// ForbiddenClasses are regular expressions matched against connector.class.
+	ForbiddenClasses []string `json:"forbiddenClasses" yaml:"forbiddenClasses"`
+	// MaxTasks caps tasks.max. Zero leaves it unchecked.
+	MaxTasks int `json:"maxTasks" yaml:"maxTasks"`
+}
+
+// PolicyViolation is a failed check reported to the client.
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type admissionRule struct {
+	name             string
+	appliesTo        *regexp.Regexp
+	namePattern      *regexp.Regexp
+	requiredKeys     []string
+	forbiddenClasses []*regexp.Regexp
+	maxTasks         int
+}
+
+var activeAdmission = struct {
+	sync.RWMutex
+	rules []admissionRule
+}{}
+
+// buildAdmissionRules compiles policy, rejecting unnamed, duplicate or empty rules.
+func buildAdmissionRules(policy AdmissionPolicy) ([]admissionRule, error) {
+	rules := make([]admissionRule, 0, len(policy.Rules))
+	seen := make(map[string]bool, len(policy.Rules))
+	for i, spec := range policy.Rules {
+		name := strings.TrimSpace(spec.Name)
+		if name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("rule %q is defined twice", name)
+		}
+		seen[name] = true
+		if spec.NamePattern == "" && len(spec.RequiredKeys) == 0 && len(spec.ForbiddenClasses) == 0 && spec.MaxTasks == 0 {
+			return nil, fmt.Errorf("rule %q has no checks", name)
+		}
+		if spec.MaxTasks < 0 {
+			return nil, fmt.Errorf("rule %q: maxTasks must not be negative", name)
+		}
+
+		rule := admissionRule{name: name, requiredKeys: spec.RequiredKeys, maxTasks: spec.MaxTasks}
+		var err error
+		if rule.appliesTo, err = compileOptional(spec.AppliesTo); err != nil {
+			return nil, fmt.Errorf("rule %q: invalid appliesTo: %w", name, err)
+		}
+		if rule.namePattern, err = compileOptional(spec.NamePattern); err != nil {
+			return nil, fmt.Errorf("rule %q: invalid namePattern: %w", name, err)
+		}
+		for _, pattern := range spec.ForbiddenClasses {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: invalid forbidden class %q: %w", name, pattern, err)
+			}
+			rule.forbiddenClasses = append(rule.forbiddenClasses, compiled)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func compileOptional(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func currentAdmissionRules() []admissionRule {
+	activeAdmission.RLock()
+	defer activeAdmission.RUnlock()
+	return activeAdmission.rules
+}
+
+// readAdmissionPolicy parses path as YAML when it has a .yaml/.yml extension and as
+// JSON otherwise.
+func readAdmissionPolicy(path string) (AdmissionPolicy, error) {
+	var policy AdmissionPolicy
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, fmt.Errorf("read admission policy: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &policy)
+	default:
+		err = json.Unmarshal(data, &policy)
+	}
+	if err != nil {
+		return policy, fmt.Errorf("decode admission policy: %w", err)
+	}
+	return policy, nil
+}
+
+// reloadAdmissionPolicy re-reads ADMISSION_POLICY_FILE and swaps in its rules. On error
+// the previously active rules stay in place.
+func reloadAdmissionPolicy() error {
+	if admissionPolicyPath == "" {
+		return nil
+	}
+
+	policy, err := readAdmissionPolicy(admissionPolicyPath)
+	if err != nil {
+		return err
+	}
+	rules, err := buildAdmissionRules(policy)
+	if err != nil {
+		return fmt.Errorf("%s: %w", admissionPolicyPath, err)
+	}
+
+	activeAdmission.Lock()
+	activeAdmission.rules = rules
+	activeAdmission.Unlock()
+
+	log.Printf("admission: loaded %d policies from %s", len(rules), admissionPolicyPath)
+	return nil
+}
+
+// watchAdmissionReloads reloads the admission policy every time SIGHUP is received.
+func watchAdmissionReloads() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			if err := reloadAdmissionPolicy(); err != nil {
+				log.Printf("admission: reload failed, keeping previous policies: %v", err)
+			}
+		}
+	}()
+}
+
+// check returns the violations of one rule, or nil when it passes or does not apply.
+func (rule admissionRule) check(name string, config map[string]interface{}) []PolicyViolation {
+	class := configString(config["connector.class"])
+	if rule.appliesTo != nil && !rule.appliesTo.MatchString(class) {
+		return nil
+	}
+
+	var violations []PolicyViolation
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, PolicyViolation{Rule: rule.name, Message: fmt.Sprintf(format, args...)})
+	}
+	if rule.namePattern != nil && !rule.namePattern.MatchString(name) {
+		violate("connector name %q does not match %s", name, rule.namePattern)
+	}
+	for _, key := range rule.requiredKeys {
+		if strings.TrimSpace(configString(config[key])) == "" {
+			violate("config key %s is required", key)
+		}
+	}
+	for _, forbidden := range rule.forbiddenClasses {
+		if forbidden.MatchString(class) {
+			violate("connector class %s is not allowed", class)
+			break
+		}
+	}
+	if rule.maxTasks > 0 {
+		if tasks, err := strconv.Atoi(strings.TrimSpace(configString(config["tasks.max"]))); err == nil && tasks > rule.maxTasks {
+			violate("tasks.max %d exceeds the maximum of %d", tasks, rule.maxTasks)
+		}
+	}
+	return violations
+}
+
+// configString renders a config value as Kafka Connect reads it; numbers and booleans
+// are accepted in place of strings.
+func configString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// checkAdmission runs every active rule against a connector's config.
+func checkAdmission(name string, config map[string]interface{}) []PolicyViolation {
+	var violations []PolicyViolation
+	for _, rule := range currentAdmissionRules() {
+		violations = append(violations, rule.check(name, config)...)
+	}
+	return violations
+}
+
+// admissionRequest extracts the connector name and config from a connector create
+// (POST /connectors) or config update (PUT /connectors/{name}/config), re-buffering the
+// body for the upstream request. ok is false for other requests and malformed bodies,
+// which Kafka Connect rejects on its own.
+func admissionRequest(r *http.Request) (name string, config map[string]interface{}, ok bool, err error) {
+	segments := clusterPathSegments(r.URL.Path)
+	var wrapped bool
+	switch {
+	case r.Method == http.MethodPost && len(segments) == 1 && segments[0] == "connectors":
+		wrapped = true
+	case r.Method == http.MethodPut && len(segments) == 3 && segments[0] == "connectors" && segments[2] == "config":
+		name = segments[1]
+	default:
+		return "", nil, false, nil
+	}
+	if r.Body == nil {
+		return "", nil, false, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return "", nil, false, fmt.Errorf("read request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return "", nil, false, nil
+	}
+	config = document
+	if wrapped {
+		config, _ = document["config"].(map[string]interface{})
+		name, _ = document["name"].(string)
+		if config == nil || name == "" {
+			return "", nil, false, nil
+		}
+	}
+	return name, config, true, nil
+}
+
+// admitConnector enforces the admission policies and connector quotas on a connector
+// create or config update.
+// It writes the rejection and returns false when the request must not proceed.
+// Violations are let through when the request carries an overridePolicy reason and the
+// caller belongs to one of ADMISSION_OVERRIDE_GROUPS.
+func admitConnector(w http.ResponseWriter, r *http.Request) bool {
+	if len(currentAdmissionRules()) == 0 && !connectorLimits.enabled() {
+		return true
+	}
+	name, config, ok, err := admissionRequest(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return false
+	}
+	if !ok {
+		return true
+	}
+	return admitConnectorConfig(w, r, name, config)
+}
+
+// admitConnectorConfig is admitConnector for a config that has already been decoded.
+// The connector quotas are checked once the policies pass.
+func admitConnectorConfig(w http.ResponseWriter, r *http.Request, name string, config map[string]interface{}) bool {
+	return admitConnectorPolicies(w, r, name, config) && enforceConnectorLimits(w, r, name, config)
+}
+
+// admitConnectorPolicies checks a decoded config against the admission policies.
+func admitConnectorPolicies(w http.ResponseWriter, r *http.Request, name string, config map[string]interface{}) bool {
+	violations := checkAdmission(name, config)
+	if len(violations) == 0 {
+		return true
+	}
+
+	rules := make([]string, 0, len(violations))
+	seen := make(map[string]bool, len(violations))
+	for _, violation := range violations {
+		if !seen[violation.Rule] {
+			seen[violation.Rule] = true
+			rules = append(rules, violation.Rule)
+		}
+	}
+
+	reason := strings.TrimSpace(r.URL.Query().Get(admissionOverrideParam))
+	if reason == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":      "policy_violation",
+			"message":    fmt.Sprintf("connector %q violates %s", name, strings.Join(rules, ", ")),
+			"rules":      rules,
+			"violations": violations,
+		})
+		return false
+	}
+	if !inAnyGroup(r, admissionOverrideGroups) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"error":      "policy_override_forbidden",
+			"message":    "overriding admission policies requires membership of " + strings.Join(splitList(admissionOverrideGroups), ", "),
+			"rules":      rules,
+			"violations": violations,
+		})
+		return false
+	}
+
+	log.Printf("admission: %s overrode %s for connector %s: %s", requestUser(r), strings.Join(rules, ", "), name, reason)
+	return true
+}
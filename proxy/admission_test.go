@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const testAdmissionPolicy = `rules:
+  - name: connector-naming
+    namePattern: ^[a-z][a-z0-9-]*$
+  - name: sink-dlq
+    appliesTo: Sink
+    requiredKeys: [errors.deadletterqueue.topic.name]
+  - name: no-file-connectors
+    forbiddenClasses: ["^org\\.apache\\.kafka\\.connect\\.file\\."]
+  - name: task-limit
+    maxTasks: 4
+`
+
+func withAdmissionPolicy(t *testing.T, policy, overrideGroups string) {
+	t.Helper()
+	originalPath, originalGroups, originalRules := admissionPolicyPath, admissionOverrideGroups, currentAdmissionRules()
+	t.Cleanup(func() {
+		admissionPolicyPath, admissionOverrideGroups = originalPath, originalGroups
+		activeAdmission.Lock()
+		activeAdmission.rules = originalRules
+		activeAdmission.Unlock()
+	})
+
+	admissionPolicyPath = filepath.Join(t.TempDir(), "policy.yaml")
+	admissionOverrideGroups = overrideGroups
+	if err := os.WriteFile(admissionPolicyPath, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadAdmissionPolicy(); err != nil {
+		t.Fatalf("reloadAdmissionPolicy: %v", err)
+	}
+}
+
+func TestCheckAdmission(t *testing.T) {
+	withAdmissionPolicy(t, testAdmissionPolicy, "")
+
+	violations := checkAdmission("Orders_Sink", map[string]interface{}{
+		"connector.class": "org.apache.kafka.connect.file.FileStreamSinkConnector",
+		"tasks.max":       float64(8),
+	})
+	rules := make([]string, len(violations))
+	for i, violation := range violations {
+		rules[i] = violation.Rule
+	}
+	if got := strings.Join(rules, ","); got != "connector-naming,sink-dlq,no-file-connectors,task-limit" {
+		t.Fatalf("expected every rule to be violated, got %s: %+v", got, violations)
+	}
+	if !strings.Contains(violations[3].Message, "tasks.max 8 exceeds the maximum of 4") {
+		t.Errorf("unexpected message %q", violations[3].Message)
+	}
+
+	// A source connector is outside sink-dlq.
+	if violations := checkAdmission("orders-source", map[string]interface{}{
+		"connector.class": "io.debezium.connector.postgresql.PostgresConnector",
+		"tasks.max":       "1",
+	}); len(violations) != 0 {
+		t.Fatalf("expected a compliant connector to pass, got %+v", violations)
+	}
+}
+
+func TestBuildAdmissionRulesRejectsInvalidPolicies(t *testing.T) {
+	for _, policy := range []AdmissionPolicy{
+		{Rules: []AdmissionRule{{MaxTasks: 1}}},
+		{Rules: []AdmissionRule{{Name: "a", MaxTasks: 1}, {Name: "a", MaxTasks: 2}}},
+		{Rules: []AdmissionRule{{Name: "empty"}}},
+		{Rules: []AdmissionRule{{Name: "bad", NamePattern: "("}}},
+		{Rules: []AdmissionRule{{Name: "bad", ForbiddenClasses: []string{"["}}}},
+		{Rules: []AdmissionRule{{Name: "bad", MaxTasks: -1}}},
+	} {
+		if _, err := buildAdmissionRules(policy); err == nil {
+			t.Errorf("expected %+v to be rejected", policy)
+		}
+	}
+}
+
+func TestReloadAdmissionPolicyKeepsRulesOnError(t *testing.T) {
+	withAdmissionPolicy(t, testAdmissionPolicy, "")
+
+	os.WriteFile(admissionPolicyPath, []byte("rules:\n  - name: broken\n    namePattern: \"(\"\n"), 0o644)
+	if err := reloadAdmissionPolicy(); err == nil {
+		t.Fatalf("expected the invalid policy to be rejected")
+	}
+	if rules := currentAdmissionRules(); len(rules) != 4 {
+		t.Fatalf("expected the previous rules to stay active, got %d", len(rules))
+	}
+}
+
+func TestProxyHandlerAdmission(t *testing.T) {
+	server, mutations := dryRunTestServer(t)
+	defer withTestConnectURL(t, server)()
+	withAdmissionPolicy(t, testAdmissionPolicy, "platform-admins")
+
+	do := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/default/"+path, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "path": strings.SplitN(path, "?", 2)[0]})
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		return rr
+	}
+
+	create := `{"name":"orders-sink","config":{"connector.class":"io.confluent.connect.s3.S3SinkConnector","tasks.max":"8"}}`
+	rr := do(http.MethodPost, "connectors", create, nil)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var rejection struct {
+		Error      string            `json:"error"`
+		Rules      []string          `json:"rules"`
+		Violations []PolicyViolation `json:"violations"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &rejection)
+	if rejection.Error != "policy_violation" || strings.Join(rejection.Rules, ",") != "sink-dlq,task-limit" || len(rejection.Violations) != 2 {
+		t.Fatalf("unexpected rejection %+v", rejection)
+	}
+
+	// Dry runs are checked too, and config updates take the name from the path.
+	if rr := do(http.MethodPut, "connectors/Orders/config?dryRun=true", `{"connector.class":"FileStreamSink","tasks.max":"1","topics":"orders"}`, nil); rr.Code != http.StatusUnprocessableEntity ||
+		!strings.Contains(rr.Body.String(), "connector-naming") {
+		t.Fatalf("expected the dry run to be rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Forwarded groups count only from a trusted authenticating proxy.
+	if rr := do(http.MethodPost, "connectors?overridePolicy=migration", create, http.Header{"X-Forwarded-Groups": {"platform-admins"}}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a forged override group to be refused, got %d", rr.Code)
+	}
+	withTestTrustedAuthHeaders(t)
+	if rr := do(http.MethodPost, "connectors?overridePolicy=migration", create, http.Header{"X-Auth-Request-Groups": {"developers"}}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected an unprivileged override to be refused, got %d", rr.Code)
+	}
+	if len(mutations()) != 0 {
+		t.Fatalf("expected nothing to reach Connect, got %v", mutations())
+	}
+
+	if rr := do(http.MethodPost, "connectors?overridePolicy=migration", create, http.Header{"X-Auth-Request-Groups": {"developers, platform-admins"}}); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected the privileged override to go through, got %d: %s", rr.Code, rr.Body.String())
+	}
+	compliant := `{"connector.class":"io.confluent.connect.s3.S3SinkConnector","tasks.max":2,"errors.deadletterqueue.topic.name":"orders-dlq"}`
+	if rr := do(http.MethodPut, "connectors/orders-sink/config", compliant, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected a compliant update to go through, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := mutations(); len(got) != 2 || got[0] != "POST /connectors" || got[1] != "PUT /connectors/orders-sink/config" {
+		t.Fatalf("unexpected mutations %v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// Default alert thresholds; individual connectors may override them through the
+	// metadata API. A lag or throughput threshold of 0 disables that rule.
+	alertLagThreshold    = getEnv("ALERT_LAG_THRESHOLD", "0")
+	alertFailureDuration = getEnv("ALERT_FAILURE_DURATION", "0s")
+	alertThroughputFloor = getEnv("ALERT_THROUGHPUT_FLOOR", "0")
+	alertDefaults        = alertThresholds{}
+
+	// alertMetadataCluster is the {cluster} name whose metadata holds the overrides used
+	// by the background alert evaluation.
+	alertMetadataCluster = getEnv("CONSOLE_CLUSTER_NAME", "default")
+)
+
+// AlertRules are per-connector overrides of the default alert thresholds. Unset fields
+// fall back to the defaults; an explicit 0 disables the rule for the connector.
+type AlertRules struct {
+	MaxLag          *float64 `json:"maxLag,omitempty"`          // records behind before alerting
+	FailureDuration *string  `json:"failureDuration,omitempty"` // how long a connector must stay failed
+	MinThroughput   *float64 `json:"minThroughput,omitempty"`   // records/sec written
+}
+
+func (r *AlertRules) empty() bool {
+	return r == nil || (r.MaxLag == nil && r.FailureDuration == nil && r.MinThroughput == nil)
+}
+
+func (r *AlertRules) validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxLag != nil && *r.MaxLag < 0 {
+		return fmt.Errorf("alerts.maxLag must not be negative")
+	}
+	if r.MinThroughput != nil && *r.MinThroughput < 0 {
+		return fmt.Errorf("alerts.minThroughput must not be negative")
+	}
+	if r.FailureDuration != nil {
+		if _, err := parseAlertDuration(*r.FailureDuration); err != nil {
+			return fmt.Errorf("alerts.failureDuration: %v", err)
+		}
+	}
+	return nil
+}
+
+// alertThresholds are the effective thresholds for one connector.
+type alertThresholds struct {
+	MaxLag          float64
+	FailureDuration time.Duration
+	MinThroughput   float64
+}
+
+// parseAlertDuration accepts Go durations and "0"; unlike parseWindow it allows zero,
+// which means "alert immediately".
+func parseAlertDuration(value string) (time.Duration, error) {
+	if value == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, &configError{name: "failure duration", value: value}
+	}
+	return d, nil
+}
+
+// loadAlertDefaults parses the ALERT_* environment variables.
+func loadAlertDefaults() (alertThresholds, error) {
+	var defaults alertThresholds
+	var err error
+
+	if defaults.MaxLag, err = strconv.ParseFloat(alertLagThreshold, 64); err != nil || defaults.MaxLag < 0 {
+		return defaults, &configError{name: "ALERT_LAG_THRESHOLD", value: alertLagThreshold}
+	}
+	if defaults.MinThroughput, err = strconv.ParseFloat(alertThroughputFloor, 64); err != nil || defaults.MinThroughput < 0 {
+		return defaults, &configError{name: "ALERT_THROUGHPUT_FLOOR", value: alertThroughputFloor}
+	}
+	if defaults.FailureDuration, err = parseAlertDuration(alertFailureDuration); err != nil {
+		return defaults, &configError{name: "ALERT_FAILURE_DURATION", value: alertFailureDuration}
+	}
+	return defaults, nil
+}
+
+// apply overlays the connector's overrides on t. Rules are validated when stored, so
+// an unparsable duration here is ignored.
+func (t alertThresholds) apply(rules *AlertRules) alertThresholds {
+	if rules == nil {
+		return t
+	}
+	if rules.MaxLag != nil {
+		t.MaxLag = *rules.MaxLag
+	}
+	if rules.MinThroughput != nil {
+		t.MinThroughput = *rules.MinThroughput
+	}
+	if rules.FailureDuration != nil {
+		if d, err := parseAlertDuration(*rules.FailureDuration); err == nil {
+			t.FailureDuration = d
+		}
+	}
+	return t
+}
+
+// alertThresholdsFor returns the effective thresholds for a connector.
+func alertThresholdsFor(connector string) alertThresholds {
+	return alertDefaults.apply(connectorMetadata.get(alertMetadataCluster, connector).Alerts)
+}
+
+// ConnectorAlertRules is returned by GET /api/{cluster}/connectors/{name}/alerts.
+type ConnectorAlertRules struct {
+	Connector string      `json:"connector"`
+	Defaults  alertView   `json:"defaults"`
+	Overrides *AlertRules `json:"overrides,omitempty"`
+	Effective alertView   `json:"effective"`
+}
+
+type alertView struct {
+	MaxLag          float64 `json:"maxLag"`
+	FailureDuration string  `json:"failureDuration"`
+	MinThroughput   float64 `json:"minThroughput"`
+}
+
+func (t alertThresholds) view() alertView {
+	return alertView{MaxLag: t.MaxLag, FailureDuration: t.FailureDuration.String(), MinThroughput: t.MinThroughput}
+}
+
+// connectorAlertRulesHandler shows the default, overridden and effective alert
+// thresholds for a connector. Overrides are edited through the metadata endpoints.
+func connectorAlertRulesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	overrides := connectorMetadata.get(vars["cluster"], name).Alerts
+
+	writeJSON(w, http.StatusOK, ConnectorAlertRules{
+		Connector: name,
+		Defaults:  alertDefaults.view(),
+		Overrides: overrides,
+		Effective: alertDefaults.apply(overrides).view(),
+	})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func expectNoNotification(t *testing.T, channel recordingChannel) {
+	t.Helper()
+	select {
+	case got := <-channel.sent:
+		t.Fatalf("unexpected notification: %+v", got.event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func expectNotification(t *testing.T, channel recordingChannel, eventType string) recordedNotification {
+	t.Helper()
+	select {
+	case got := <-channel.sent:
+		if got.event.Type != eventType {
+			t.Fatalf("expected %s, got %+v", eventType, got.event)
+		}
+		return got
+	case <-time.After(time.Second):
+		t.Fatalf("expected %s notification", eventType)
+	}
+	return recordedNotification{}
+}
+
+func TestNotifierHonoursFailureDuration(t *testing.T) {
+	channel := recordingChannel{channel: "webhook", sent: make(chan recordedNotification, 4)}
+	n := newNotifier([]notificationChannel{channel}, newNotificationTemplates())
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+	n.thresholds = func(connector string) alertThresholds {
+		if connector == "payments-cdc" {
+			return alertThresholds{}
+		}
+		return alertThresholds{FailureDuration: 5 * time.Minute}
+	}
+
+	n.observe("prod", []connectorStatusResponse{statusFixture("batch-sink", "RUNNING", "RUNNING"), statusFixture("payments-cdc", "RUNNING", "RUNNING")})
+	now = now.Add(time.Minute)
+	n.observe("prod", []connectorStatusResponse{statusFixture("batch-sink", "RUNNING", "FAILED"), statusFixture("payments-cdc", "RUNNING", "FAILED")})
+
+	got := expectNotification(t, channel, eventConnectorFailed)
+	if got.event.Connector != "payments-cdc" {
+		t.Fatalf("expected only the zero-duration connector to alert immediately, got %s", got.event.Connector)
+	}
+	expectNoNotification(t, channel)
+
+	now = now.Add(6 * time.Minute)
+	n.observe("prod", []connectorStatusResponse{statusFixture("batch-sink", "RUNNING", "FAILED"), statusFixture("payments-cdc", "RUNNING", "FAILED")})
+	got = expectNotification(t, channel, eventConnectorFailed)
+	if got.event.Connector != "batch-sink" || got.event.PreviousState != "running" {
+		t.Fatalf("unexpected delayed alert: %+v", got.event)
+	}
+	expectNoNotification(t, channel)
+
+	n.observe("prod", []connectorStatusResponse{statusFixture("batch-sink", "RUNNING", "RUNNING"), statusFixture("payments-cdc", "RUNNING", "RUNNING")})
+	expectNotification(t, channel, eventConnectorRecovered)
+	expectNotification(t, channel, eventConnectorRecovered)
+}
+
+func TestNotifierSkipsRecoveryForUnalertedFailure(t *testing.T) {
+	channel := recordingChannel{channel: "webhook", sent: make(chan recordedNotification, 4)}
+	n := newNotifier([]notificationChannel{channel}, newNotificationTemplates())
+	n.thresholds = func(string) alertThresholds { return alertThresholds{FailureDuration: time.Hour} }
+
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "RUNNING", "RUNNING")})
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "RUNNING", "FAILED")})
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "RUNNING", "RUNNING")})
+	expectNoNotification(t, channel)
+}
+
+func TestNotifierObserveMetricsThresholds(t *testing.T) {
+	channel := recordingChannel{channel: "webhook", sent: make(chan recordedNotification, 4)}
+	n := newNotifier([]notificationChannel{channel}, newNotificationTemplates())
+	n.thresholds = func(string) alertThresholds { return alertThresholds{MaxLag: 1000, MinThroughput: 5} }
+
+	n.observeMetrics([]ConnectorMetrics{{Connector: "orders", OffsetLag: 5000, RecordsOutPerSec: 10}})
+	got := expectNotification(t, channel, eventConnectorLagging)
+	if got.event.Value != 5000 || got.event.Threshold != 1000 {
+		t.Fatalf("unexpected lag event: %+v", got.event)
+	}
+	if got.message != "Connector orders is 5000 records behind (threshold 1000)." {
+		t.Fatalf("unexpected message: %q", got.message)
+	}
+
+	n.observeMetrics([]ConnectorMetrics{{Connector: "orders", OffsetLag: 6000, RecordsOutPerSec: 10}})
+	expectNoNotification(t, channel)
+
+	n.observeMetrics([]ConnectorMetrics{{Connector: "orders", OffsetLag: 10, RecordsOutPerSec: 1}})
+	expectNotification(t, channel, eventConnectorThroughputLow)
+	n.observeMetrics([]ConnectorMetrics{{Connector: "orders", OffsetLag: 5000, RecordsOutPerSec: 1}})
+	expectNotification(t, channel, eventConnectorLagging)
+}
+
+func TestAlertThresholdsOverrides(t *testing.T) {
+	store := withTestMetadataStore(t)
+	withTestAuditLog(t, 10)
+	original := alertDefaults
+	alertDefaults = alertThresholds{MaxLag: 1000, FailureDuration: time.Minute}
+	t.Cleanup(func() { alertDefaults = original })
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/default/connectors/batch-sink/metadata", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "batch-sink"})
+		rr := httptest.NewRecorder()
+		connectorMetadataHandler(rr, req)
+		return rr
+	}
+
+	if rr := put(`{"alerts":{"maxLag":0,"failureDuration":"8h"}}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	effective := alertThresholdsFor("batch-sink")
+	if effective.MaxLag != 0 || effective.FailureDuration != 8*time.Hour {
+		t.Fatalf("expected overrides to apply, got %+v", effective)
+	}
+	if other := alertThresholdsFor("payments-cdc"); other != alertDefaults {
+		t.Fatalf("expected defaults for connectors without overrides, got %+v", other)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/batch-sink/alerts", nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "batch-sink"})
+	rr := httptest.NewRecorder()
+	connectorAlertRulesHandler(rr, req)
+	var rules ConnectorAlertRules
+	if err := json.Unmarshal(rr.Body.Bytes(), &rules); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rules.Defaults.FailureDuration != "1m0s" || rules.Effective.FailureDuration != "8h0m0s" || rules.Overrides == nil {
+		t.Fatalf("unexpected alert rules: %+v", rules)
+	}
+
+	if rr := put(`{"alerts":{"failureDuration":"soon"}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid duration, got %d", rr.Code)
+	}
+	if rr := put(`{"alerts":{}}`); rr.Code != http.StatusOK || store.get("default", "batch-sink").Alerts != nil {
+		t.Fatalf("expected empty alerts object to clear overrides")
+	}
+}
+
+func TestLoadAlertDefaults(t *testing.T) {
+	originalLag, originalDuration := alertLagThreshold, alertFailureDuration
+	t.Cleanup(func() { alertLagThreshold, alertFailureDuration = originalLag, originalDuration })
+
+	alertLagThreshold, alertFailureDuration = "10000", "2m"
+	defaults, err := loadAlertDefaults()
+	if err != nil || defaults.MaxLag != 10000 || defaults.FailureDuration != 2*time.Minute {
+		t.Fatalf("unexpected defaults: %+v, %v", defaults, err)
+	}
+
+	alertLagThreshold = "-1"
+	if _, err := loadAlertDefaults(); err == nil {
+		t.Fatalf("expected negative lag threshold to be rejected")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxAnnotationNotes bounds the free-text notes of a connector.
+	maxAnnotationNotes = 4096
+	// maxAnnotationField bounds the owner contact and runbook URL.
+	maxAnnotationField = 512
+)
+
+// ConnectorAnnotations are the on-call notes of a connector: free text, who to contact
+// and where its runbook lives. They are kept with the connector metadata, returned with
+// the connector detail and added to failure alerts.
+type ConnectorAnnotations struct {
+	Notes        string `json:"notes,omitempty"`
+	OwnerContact string `json:"ownerContact,omitempty"` // e.g. an email address or chat channel
+	RunbookURL   string `json:"runbookUrl,omitempty"`
+}
+
+func (a ConnectorAnnotations) normalize() ConnectorAnnotations {
+	return ConnectorAnnotations{
+		Notes:        strings.TrimSpace(a.Notes),
+		OwnerContact: strings.TrimSpace(a.OwnerContact),
+		RunbookURL:   strings.TrimSpace(a.RunbookURL),
+	}
+}
+
+func (a ConnectorAnnotations) empty() bool {
+	return a == ConnectorAnnotations{}
+}
+
+func (a *ConnectorAnnotations) validate() error {
+	if a == nil {
+		return nil
+	}
+	normalized := a.normalize()
+	if len(normalized.Notes) > maxAnnotationNotes {
+		return fmt.Errorf("notes must be at most %d characters", maxAnnotationNotes)
+	}
+	if len(normalized.OwnerContact) > maxAnnotationField {
+		return fmt.Errorf("ownerContact must be at most %d characters", maxAnnotationField)
+	}
+	if normalized.RunbookURL != "" {
+		u, err := url.Parse(normalized.RunbookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(normalized.RunbookURL) > maxAnnotationField {
+			return fmt.Errorf("runbookUrl must be an http or https URL of at most %d characters", maxAnnotationField)
+		}
+	}
+	return nil
+}
+
+// alertMetadata returns the ownership details added to failure alerts, or nil when the
+// connector has none.
+func (m ConnectorMetadata) alertMetadata() map[string]string {
+	details := map[string]string{"owner": m.Owner, "team": m.Team}
+	if m.Annotations != nil {
+		details["ownerContact"], details["runbookUrl"] = m.Annotations.OwnerContact, m.Annotations.RunbookURL
+	}
+	for key, value := range details {
+		if value == "" {
+			delete(details, key)
+		}
+	}
+	if len(details) == 0 {
+		return nil
+	}
+	return details
+}
+
+// connectorAnnotationsHandler replaces the annotations of a connector; an empty object
+// removes them.
+func connectorAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+
+	var annotations ConnectorAnnotations
+	if err := json.NewDecoder(r.Body).Decode(&annotations); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON annotations object")
+		return
+	}
+	if err := annotations.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	updated, err := connectorMetadata.update(cluster, []string{name}, metadataPatch{Annotations: &annotations}, requestUser(r))
+	if err != nil {
+		log.Printf("metadata: failed to persist annotations for %s: %v", name, err)
+		writeJSONError(w, http.StatusInternalServerError, "metadata_store_failed", "failed to persist connector metadata")
+		return
+	}
+	recordAudit(r, auditActionUpdateMetadata, name, http.StatusOK, map[string]interface{}{"annotations": updated[name].Annotations})
+	writeJSON(w, http.StatusOK, updated[name])
+}
+
+// annotateConnectorDetail adds the connector's annotations to a successful
+// GET /connectors/{name} body. Other bodies, and connectors without annotations, are
+// returned unchanged.
+func annotateConnectorDetail(cluster, name string, status int, body []byte) []byte {
+	if status != http.StatusOK {
+		return body
+	}
+	annotations := connectorMetadata.get(cluster, name).Annotations
+	if annotations == nil {
+		return body
+	}
+	var detail map[string]json.RawMessage
+	if err := json.Unmarshal(body, &detail); err != nil || detail == nil {
+		return body
+	}
+	encoded, err := json.Marshal(annotations)
+	if err != nil {
+		return body
+	}
+	detail["annotations"] = encoded
+	annotated, err := json.Marshal(detail)
+	if err != nil {
+		return body
+	}
+	return annotated
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestConnectorAnnotationsHandler(t *testing.T) {
+	store := withTestMetadataStore(t)
+	logger := withTestAuditLog(t, 10)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/default/connectors/orders-sink/annotations", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "orders-sink"})
+		rr := httptest.NewRecorder()
+		connectorAnnotationsHandler(rr, req)
+		return rr
+	}
+
+	rr := put(`{"notes": " Replays are safe. ", "ownerContact": "#payments-oncall", "runbookUrl": "https://wiki.example.com/orders-sink"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	got := store.get("default", "orders-sink").Annotations
+	if got == nil || got.Notes != "Replays are safe." || got.OwnerContact != "#payments-oncall" {
+		t.Fatalf("unexpected annotations %+v", got)
+	}
+	if entries := logger.Query(AuditFilter{Action: auditActionUpdateMetadata}); len(entries) != 1 {
+		t.Fatalf("expected one metadata audit entry, got %+v", entries)
+	}
+
+	for _, body := range []string{
+		`{"runbookUrl": "wiki/orders-sink"}`,
+		`{"runbookUrl": "ftp://wiki.example.com/orders-sink"}`,
+		`{"notes": "` + strings.Repeat("x", maxAnnotationNotes+1) + `"}`,
+		`not json`,
+	} {
+		if rr := put(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%.40s: expected 400, got %d", body, rr.Code)
+		}
+	}
+
+	if rr := put(`{}`); rr.Code != http.StatusOK || store.get("default", "orders-sink").Annotations != nil {
+		t.Fatalf("expected an empty object to clear the annotations, got %d %+v", rr.Code, store.get("default", "orders-sink").Annotations)
+	}
+}
+
+func TestConnectorDetailIncludesAnnotations(t *testing.T) {
+	store := withTestMetadataStore(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name": "orders-sink", "config": {"connection.password": "hunter2"}, "tasks": [], "type": "sink"}`))
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	get := func(path string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/"+path, nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "path": path})
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		var body map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unexpected body %q", rr.Body.String())
+		}
+		return body
+	}
+
+	if body := get("orders-sink"); body["annotations"] != nil {
+		t.Fatalf("expected no annotations before any are set, got %v", body["annotations"])
+	}
+	annotations := ConnectorAnnotations{OwnerContact: "payments@example.com", RunbookURL: "https://wiki.example.com/orders-sink"}
+	if _, err := store.update("default", []string{"orders-sink"}, metadataPatch{Annotations: &annotations}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	body := get("orders-sink")
+	detail, _ := body["annotations"].(map[string]interface{})
+	if detail["runbookUrl"] != annotations.RunbookURL || body["config"].(map[string]interface{})["connection.password"] != defaultRedactionPlaceholder {
+		t.Fatalf("expected a redacted detail with annotations, got %v", body)
+	}
+	if config := get("orders-sink/config"); config["annotations"] != nil {
+		t.Fatalf("expected only the connector detail to be annotated, got %v", config)
+	}
+}
+
+func TestFailureAlertIncludesAnnotations(t *testing.T) {
+	store := withTestMetadataStore(t)
+	annotations := ConnectorAnnotations{OwnerContact: "#payments-oncall", RunbookURL: "https://wiki.example.com/orders"}
+	if _, err := store.update(alertMetadataCluster, []string{"orders"}, metadataPatch{Annotations: &annotations}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	channel := recordingChannel{channel: "slack", sent: make(chan recordedNotification, 1)}
+	n := newNotifier([]notificationChannel{channel}, newNotificationTemplates())
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "RUNNING", "RUNNING")})
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "RUNNING", "FAILED")})
+
+	select {
+	case got := <-channel.sent:
+		if got.event.Metadata["runbookUrl"] != annotations.RunbookURL {
+			t.Fatalf("expected the runbook in the event metadata, got %v", got.event.Metadata)
+		}
+		if !strings.Contains(got.message, "Owner: #payments-oncall") || !strings.Contains(got.message, "Runbook: https://wiki.example.com/orders") {
+			t.Fatalf("expected owner and runbook in the message, got %q", got.message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected failure notification")
+	}
+}
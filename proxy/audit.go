@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	auditActionCreate       = "CREATE"
+	auditActionUpdate       = "UPDATE"
+	auditActionDelete       = "DELETE"
+	auditActionPause        = "PAUSE"
+	auditActionResume       = "RESUME"
+	auditActionStop         = "STOP"
+	auditActionRestart      = "RESTART"
+	auditActionResetOffsets = "RESET_OFFSETS"
+	auditActionAlterOffsets = "ALTER_OFFSETS"
+	auditActionRestartTask  = "RESTART_TASK"
+	auditActionRestartAll   = "RESTART_ALL"
+	auditActionRebalance    = "REBALANCE"
+	auditActionAdmin        = "ADMIN"
+	auditActionMaintenance  = "MAINTENANCE"
+
+	auditTargetConnector = "CONNECTOR"
+	auditTargetTask      = "TASK"
+	auditTargetCluster   = "CLUSTER"
+
+	auditStatusSuccess = "SUCCESS"
+	auditStatusFailure = "FAILURE"
+)
+
+var (
+	auditLogMaxEntries = getEnv("AUDIT_LOG_MAX_ENTRIES", "10000")
+	auditLogMaxBody    = getEnv("AUDIT_LOG_MAX_BODY", "8192")
+
+	// auditMaxBody caps the request and response bodies kept with an entry; 0 keeps none.
+	auditMaxBody = 8192
+
+	auditLog AuditLogger = newMemoryAuditLogger(10000)
+)
+
+// AuditLogEntry records a mutation performed through the proxy. TargetType says whether
+// it affected a connector, a single task or the whole cluster; Parameters holds the
+// options the caller passed, such as includeTasks on a restart. RequestBody and
+// ResponseBody are redacted like captured traffic and cut at AUDIT_LOG_MAX_BODY bytes;
+// only the entry detail endpoint returns them.
+type AuditLogEntry struct {
+	ID                string                 `json:"id"`
+	Timestamp         time.Time              `json:"timestamp"`
+	User              string                 `json:"user"`
+	ClientIP          string                 `json:"clientIp"`
+	Cluster           string                 `json:"cluster"`
+	Action            string                 `json:"action"`
+	TargetType        string                 `json:"targetType,omitempty"`
+	ConnectorName     string                 `json:"connectorName,omitempty"`
+	Status            string                 `json:"status"`
+	HTTPStatus        int                    `json:"httpStatus"`
+	Parameters        map[string]interface{} `json:"parameters,omitempty"`
+	Details           map[string]interface{} `json:"details,omitempty"`
+	RequestBody       string                 `json:"requestBody,omitempty"`
+	ResponseBody      string                 `json:"responseBody,omitempty"`
+	RequestTruncated  bool                   `json:"requestTruncated,omitempty"`
+	ResponseTruncated bool                   `json:"responseTruncated,omitempty"`
+}
+
+// withoutBodies returns entry without its captured bodies, for lists and streams.
+func (entry AuditLogEntry) withoutBodies() AuditLogEntry {
+	entry.RequestBody, entry.ResponseBody = "", ""
+	entry.RequestTruncated, entry.ResponseTruncated = false, false
+	return entry
+}
+
+// loadAuditMaxBody parses AUDIT_LOG_MAX_BODY.
+func loadAuditMaxBody() (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(auditLogMaxBody))
+	if err != nil || n < 0 {
+		return 0, &configError{name: "AUDIT_LOG_MAX_BODY", value: auditLogMaxBody}
+	}
+	return n, nil
+}
+
+// AuditFilter narrows an audit log query. Empty fields match everything.
+type AuditFilter struct {
+	ID         string
+	Connector  string
+	Action     string
+	TargetType string
+	Status     string
+	Range      timeRange
+	Limit      int
+}
+
+func (f AuditFilter) matches(entry AuditLogEntry) bool {
+	if f.ID != "" && entry.ID != f.ID {
+		return false
+	}
+	if f.Connector != "" && entry.ConnectorName != f.Connector {
+		return false
+	}
+	if f.Action != "" && !strings.EqualFold(entry.Action, f.Action) {
+		return false
+	}
+	if f.TargetType != "" && !strings.EqualFold(entry.TargetType, f.TargetType) {
+		return false
+	}
+	if f.Status != "" && !strings.EqualFold(entry.Status, f.Status) {
+		return false
+	}
+	return f.Range.contains(entry.Timestamp)
+}
+
+// AuditLogger stores and queries audit entries.
+type AuditLogger interface {
+	// Log assigns an ID to entry, stores it and returns the stored entry.
+	Log(entry AuditLogEntry) AuditLogEntry
+	// Query returns matching entries, newest first.
+	Query(filter AuditFilter) []AuditLogEntry
+	// Ping reports whether the backing store is usable; it backs the readiness check.
+	Ping(ctx context.Context) error
+}
+
+// memoryAuditLogger keeps the most recent entries in a bounded in-memory buffer.
+type memoryAuditLogger struct {
+	mu         sync.RWMutex
+	entries    []AuditLogEntry
+	maxEntries int
+	nextID     int64
+}
+
+func newMemoryAuditLogger(maxEntries int) *memoryAuditLogger {
+	return &memoryAuditLogger{maxEntries: maxEntries}
+}
+
+func (l *memoryAuditLogger) Log(entry AuditLogEntry) AuditLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextID++
+	entry.ID = strconv.FormatInt(l.nextID, 10)
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Timestamp = entry.Timestamp.UTC()
+
+	l.entries = append(l.entries, entry)
+	if overflow := len(l.entries) - l.maxEntries; overflow > 0 {
+		l.entries = append([]AuditLogEntry(nil), l.entries[overflow:]...)
+	}
+	return entry
+}
+
+// Ping always succeeds: the in-memory buffer has no external dependency.
+func (l *memoryAuditLogger) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (l *memoryAuditLogger) Query(filter AuditFilter) []AuditLogEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]AuditLogEntry, 0)
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if !filter.matches(l.entries[i]) {
+			continue
+		}
+		result = append(result, l.entries[i])
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// recordAudit logs an audit entry for a request handled by the proxy.
+func recordAudit(r *http.Request, action, connector string, httpStatus int, details map[string]interface{}) AuditLogEntry {
+	return logAudit(newAuditEntry(r, action, connector, httpStatus, details))
+}
+
+func newAuditEntry(r *http.Request, action, connector string, httpStatus int, details map[string]interface{}) AuditLogEntry {
+	status := auditStatusSuccess
+	if httpStatus >= 400 {
+		status = auditStatusFailure
+	}
+
+	return AuditLogEntry{
+		Timestamp:     time.Now().UTC(),
+		User:          requestUser(r),
+		ClientIP:      extractClientIP(r),
+		Cluster:       mux.Vars(r)["cluster"],
+		Action:        action,
+		ConnectorName: connector,
+		Status:        status,
+		HTTPStatus:    httpStatus,
+		Details:       details,
+	}
+}
+
+// logAudit stores entry and publishes it to live audit streams and, when enabled, to
+// the lifecycle events topic. Entries not tied to an HTTP request, such as automatic
+// restarts, are logged through it directly. Entries without a TargetType target their
+// connector, or the cluster when they name none.
+func logAudit(entry AuditLogEntry) AuditLogEntry {
+	if entry.TargetType == "" {
+		entry.TargetType = auditTargetConnector
+		if entry.ConnectorName == "" {
+			entry.TargetType = auditTargetCluster
+		}
+	}
+	entry = auditLog.Log(entry)
+	auditStream.publish(entry)
+	if lifecycleEvents != nil {
+		lifecycleEvents.auditLogged(entry)
+	}
+	return entry
+}
+
+// rawJSON embeds a JSON document in audit details verbatim, falling back to a string
+// when the bytes are not valid JSON.
+func rawJSON(data []byte) interface{} {
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	return string(data)
+}
+
+// auditOperation is a mutation recognised by auditMiddleware.
+type auditOperation struct {
+	action     string
+	targetType string
+	connector  string
+	parameters map[string]interface{}
+}
+
+// detectAuditOperation maps a request to the mutation it performs. ok is false for reads
+// and for handlers that write their own audit entries, such as offsets and metadata.
+func detectAuditOperation(method, path string) (op auditOperation, ok bool) {
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+		return auditOperation{}, false
+	}
+
+	segments := clusterPathSegments(path)
+	if len(segments) == 0 {
+		return auditOperation{}, false
+	}
+	switch segments[0] {
+	case "connectors":
+		return detectConnectorOperation(method, segments[1:])
+	case "cluster":
+		if method != http.MethodPost || len(segments) != 3 || segments[1] != "actions" {
+			return auditOperation{}, false
+		}
+		switch strings.ToLower(segments[2]) {
+		case "restart", "restart-all":
+			return auditOperation{action: auditActionRestartAll, targetType: auditTargetCluster}, true
+		case "rebalance":
+			return auditOperation{action: auditActionRebalance, targetType: auditTargetCluster}, true
+		}
+	case "maintenance":
+		if method == http.MethodPost && len(segments) == 1 {
+			return auditOperation{action: auditActionMaintenance, targetType: auditTargetCluster}, true
+		}
+	case "admin":
+		if method == http.MethodPost {
+			return auditOperation{
+				action:     auditActionAdmin,
+				targetType: auditTargetCluster,
+				parameters: map[string]interface{}{"path": strings.Join(segments, "/")},
+			}, true
+		}
+	}
+	return auditOperation{}, false
+}
+
+// detectConnectorOperation handles the segments after /connectors.
+func detectConnectorOperation(method string, segments []string) (auditOperation, bool) {
+	if len(segments) == 0 || segments[0] == "" {
+		if method == http.MethodPost {
+			return auditOperation{action: auditActionCreate, targetType: auditTargetConnector}, true
+		}
+		return auditOperation{}, false
+	}
+
+	name, subresource := segments[0], strings.Join(segments[1:], "/")
+	connectorOp := func(action string) (auditOperation, bool) {
+		return auditOperation{action: action, targetType: auditTargetConnector, connector: name}, true
+	}
+	switch {
+	case method == http.MethodDelete && subresource == "":
+		return connectorOp(auditActionDelete)
+	case (method == http.MethodPut || method == http.MethodPatch) && subresource == "config":
+		return connectorOp(auditActionUpdate)
+	case (method == http.MethodPut || method == http.MethodPost) && subresource == "pause":
+		return connectorOp(auditActionPause)
+	case method == http.MethodPut && subresource == "stop":
+		return connectorOp(auditActionStop)
+	case (method == http.MethodPut || method == http.MethodPost) && subresource == "resume":
+		return connectorOp(auditActionResume)
+	case method == http.MethodPost && (subresource == "restart" || subresource == "restart-advanced"):
+		return connectorOp(auditActionRestart)
+	case method == http.MethodPost && len(segments) == 4 && segments[1] == "tasks" && segments[3] == "restart":
+		task, err := strconv.Atoi(segments[2])
+		if err != nil {
+			return auditOperation{}, false
+		}
+		return auditOperation{
+			action:     auditActionRestartTask,
+			targetType: auditTargetTask,
+			connector:  name,
+			parameters: map[string]interface{}{"task": task},
+		}, true
+	}
+	return auditOperation{}, false
+}
+
+// auditParameters merges the query string, and for cluster actions the JSON body, into
+// the parameters of op. Connector configs are never copied: they may hold credentials.
+func auditParameters(r *http.Request, op auditOperation, body []byte) map[string]interface{} {
+	parameters := make(map[string]interface{}, len(op.parameters))
+	for key, value := range op.parameters {
+		parameters[key] = value
+	}
+	for key, values := range r.URL.Query() {
+		if len(values) == 1 {
+			parameters[key] = values[0]
+		} else {
+			parameters[key] = values
+		}
+	}
+	if op.targetType == auditTargetCluster && len(bytes.TrimSpace(body)) > 0 {
+		parameters["body"] = rawJSON(body)
+	}
+	if len(parameters) == 0 {
+		return nil
+	}
+	return parameters
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush lets event streams flush through the recorder.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// auditMiddleware records every connector, task and cluster mutation passing through
+// the proxy.
+func auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := detectAuditOperation(r.Method, r.URL.Path)
+		// Simulated dry runs change nothing, so there is nothing to audit. Endpoints
+		// without dry-run support ignore the parameter and are audited as usual.
+		if !ok || dryRunServed(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var body []byte
+		if (auditMaxBody > 0 || op.action == auditActionCreate || op.targetType == auditTargetCluster) && r.Body != nil {
+			var err error
+			body, err = io.ReadAll(r.Body)
+			r.Body.Close()
+			if err == nil && op.action == auditActionCreate {
+				var payload struct {
+					Name string `json:"name"`
+				}
+				if json.Unmarshal(body, &payload) == nil {
+					op.connector = payload.Name
+				}
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		// Up to auditMaxBody bytes of the response are buffered, and only when bodies are kept
+		// with the entry.
+		var status int
+		var response []byte
+		var responseTruncated bool
+		if auditMaxBody > 0 {
+			recorder := &captureWriter{ResponseWriter: w, limit: auditMaxBody}
+			next.ServeHTTP(recorder, r)
+			status, response, responseTruncated = recorder.status, recorder.body.Bytes(), recorder.truncated
+		} else {
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			status = recorder.status
+		}
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// Connect answers PUT /config with 201 when it created the connector.
+		if op.action == auditActionUpdate && status == http.StatusCreated {
+			op.action = auditActionCreate
+		}
+		entry := newAuditEntry(r, op.action, op.connector, status, nil)
+		entry.TargetType = op.targetType
+		entry.Parameters = auditParameters(r, op, body)
+		if auditMaxBody > 0 {
+			if len(bytes.TrimSpace(body)) > 0 {
+				entry.RequestBody, entry.RequestTruncated = captureBody(body, auditMaxBody)
+			}
+			if len(bytes.TrimSpace(response)) > 0 {
+				entry.ResponseBody, entry.ResponseTruncated = captureBody(response, auditMaxBody)
+				entry.ResponseTruncated = entry.ResponseTruncated || responseTruncated
+			}
+		}
+		logAudit(entry)
+	})
+}
+
+// auditLogHandler returns audit entries, newest first, filtered by the connector,
+// action, targetType, status, since, until and limit query parameters. format=csv and
+// format=ndjson download the entries as an attachment.
+func auditLogHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := strings.ToLower(query.Get("format"))
+	if format != "" && format != auditFormatJSON && format != auditFormatCSV && format != auditFormatNDJSON {
+		writeJSONError(w, http.StatusBadRequest, "invalid_format", "format must be json, csv or ndjson")
+		return
+	}
+	timeRange, loc, err := parseTimeRange(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_time_range", err.Error())
+		return
+	}
+	filter := AuditFilter{
+		Connector:  query.Get("connector"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("targetType"),
+		Status:     query.Get("status"),
+		Range:      timeRange,
+		Limit:      100,
+	}
+	if format == auditFormatCSV || format == auditFormatNDJSON {
+		filter.Limit = 0 // exports are complete unless a limit is given
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_limit", "limit must be a non-negative integer")
+			return
+		}
+		filter.Limit = n
+	}
+
+	setTimezoneHeader(w, loc)
+	entries := auditLog.Query(filter)
+	switch format {
+	case auditFormatCSV, auditFormatNDJSON:
+		writeAuditExport(w, format, mux.Vars(r)["cluster"], filter, loc, entries)
+	default:
+		for i := range entries {
+			entries[i] = entries[i].withoutBodies()
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// auditLogEntryHandler returns one audit entry with its captured request and response
+// bodies.
+func auditLogEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	entries := auditLog.Query(AuditFilter{ID: id, Limit: 1})
+	if len(entries) == 0 {
+		writeJSONError(w, http.StatusNotFound, "audit_entry_not_found", fmt.Sprintf("audit entry %s not found", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, entries[0])
+}
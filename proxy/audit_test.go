@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestAuditLog(t *testing.T, maxEntries int) *memoryAuditLogger {
+	t.Helper()
+	original := auditLog
+	logger := newMemoryAuditLogger(maxEntries)
+	auditLog = logger
+	t.Cleanup(func() { auditLog = original })
+	return logger
+}
+
+func TestDetectAuditOperation(t *testing.T) {
+	tests := []struct {
+		method     string
+		path       string
+		action     string
+		targetType string
+		connector  string
+		ok         bool
+	}{
+		{http.MethodPost, "/api/default/connectors", auditActionCreate, auditTargetConnector, "", true},
+		{http.MethodPut, "/api/default/connectors/alpha/config", auditActionUpdate, auditTargetConnector, "alpha", true},
+		{http.MethodDelete, "/api/default/connectors/alpha", auditActionDelete, auditTargetConnector, "alpha", true},
+		{http.MethodPut, "/api/default/connectors/alpha/pause", auditActionPause, auditTargetConnector, "alpha", true},
+		{http.MethodPut, "/api/default/connectors/alpha/resume", auditActionResume, auditTargetConnector, "alpha", true},
+		{http.MethodPost, "/api/default/connectors/alpha/pause", auditActionPause, auditTargetConnector, "alpha", true},
+		{http.MethodPost, "/api/default/connectors/alpha/resume", auditActionResume, auditTargetConnector, "alpha", true},
+		{http.MethodPut, "/api/default/connectors/alpha/stop", auditActionStop, auditTargetConnector, "alpha", true},
+		{http.MethodPost, "/api/default/connectors/alpha/restart", auditActionRestart, auditTargetConnector, "alpha", true},
+		{http.MethodPost, "/api/default/connectors/alpha/tasks/2/restart", auditActionRestartTask, auditTargetTask, "alpha", true},
+		{http.MethodPost, "/api/default/cluster/actions/restart-all", auditActionRestartAll, auditTargetCluster, "", true},
+		{http.MethodPost, "/api/default/cluster/actions/rebalance", auditActionRebalance, auditTargetCluster, "", true},
+		{http.MethodPost, "/api/default/admin/loggers/org.apache.kafka", auditActionAdmin, auditTargetCluster, "", true},
+		{http.MethodPost, "/api/default/maintenance", auditActionMaintenance, auditTargetCluster, "", true},
+		{http.MethodGet, "/api/default/connectors/alpha", "", "", "", false},
+		{http.MethodPost, "/api/default/connectors/alpha/tasks/x/restart", "", "", "", false},
+		{http.MethodPost, "/api/default/connectors/alpha/config/diff", "", "", "", false},
+		// These handlers write their own audit entries.
+		{http.MethodDelete, "/api/default/connectors/alpha/offsets", "", "", "", false},
+		{http.MethodPut, "/api/default/connectors/alpha/metadata", "", "", "", false},
+		{http.MethodPost, "/api/default/cluster/actions/unknown", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		op, ok := detectAuditOperation(tt.method, tt.path)
+		if op.action != tt.action || op.targetType != tt.targetType || op.connector != tt.connector || ok != tt.ok {
+			t.Fatalf("detectAuditOperation(%s %s) = (%+v, %v), want (%q, %q, %q, %v)",
+				tt.method, tt.path, op, ok, tt.action, tt.targetType, tt.connector, tt.ok)
+		}
+	}
+}
+
+func TestAuditMiddlewareRecordsMutations(t *testing.T) {
+	withTestTrustedAuthHeaders(t)
+	logger := withTestAuditLog(t, 10)
+
+	var upstreamBody []byte
+	handler := auditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamBody = make([]byte, r.ContentLength)
+		r.Body.Read(upstreamBody)
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/default/connectors", bytes.NewBufferString(`{"name":"alpha","config":{}}`))
+	req.Header.Set("X-Forwarded-User", "alice")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !bytes.Contains(upstreamBody, []byte(`"alpha"`)) {
+		t.Fatalf("expected request body to reach the handler, got %q", upstreamBody)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/default/connectors/beta", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/default/connectors", nil))
+
+	entries := logger.Query(AuditFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(entries))
+	}
+	if entries[0].Action != auditActionDelete || entries[0].ConnectorName != "beta" || entries[0].Status != auditStatusFailure {
+		t.Fatalf("unexpected delete entry: %+v", entries[0])
+	}
+	if entries[1].Action != auditActionCreate || entries[1].ConnectorName != "alpha" || entries[1].User != "alice" || entries[1].Status != auditStatusSuccess {
+		t.Fatalf("unexpected create entry: %+v", entries[1])
+	}
+}
+
+func TestAuditMiddlewareRecordsTargetsAndParameters(t *testing.T) {
+	logger := withTestAuditLog(t, 10)
+
+	var upstreamBody string
+	handler := auditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		upstreamBody = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/default/connectors/alpha/restart?includeTasks=true&onlyFailed=true", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/default/connectors/alpha/tasks/1/restart", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/default/cluster/actions/restart-all", strings.NewReader(`{"onlyFailed":true}`)))
+	if upstreamBody != `{"onlyFailed":true}` {
+		t.Fatalf("expected the cluster action body to reach the handler, got %q", upstreamBody)
+	}
+
+	entries := logger.Query(AuditFilter{})
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 audit entries, got %+v", entries)
+	}
+	cluster, task, restart := entries[0], entries[1], entries[2]
+	if cluster.Action != auditActionRestartAll || cluster.TargetType != auditTargetCluster || cluster.ConnectorName != "" {
+		t.Fatalf("unexpected cluster entry %+v", cluster)
+	}
+	if body, _ := json.Marshal(cluster.Parameters["body"]); string(body) != `{"onlyFailed":true}` {
+		t.Fatalf("expected the body in the parameters, got %+v", cluster.Parameters)
+	}
+	if task.Action != auditActionRestartTask || task.TargetType != auditTargetTask || task.ConnectorName != "alpha" || task.Parameters["task"] != 1 {
+		t.Fatalf("unexpected task entry %+v", task)
+	}
+	if restart.TargetType != auditTargetConnector || restart.Parameters["includeTasks"] != "true" || restart.Parameters["onlyFailed"] != "true" {
+		t.Fatalf("unexpected restart entry %+v", restart)
+	}
+
+	if got := logger.Query(AuditFilter{TargetType: "task"}); len(got) != 1 || got[0].ID != task.ID {
+		t.Fatalf("expected the target type filter to match the task entry, got %+v", got)
+	}
+}
+
+func TestLogAuditDefaultsTargetType(t *testing.T) {
+	logger := withTestAuditLog(t, 10)
+	logAudit(AuditLogEntry{Action: auditActionRestart, ConnectorName: "alpha"})
+	logAudit(AuditLogEntry{Action: auditActionFailover})
+
+	entries := logger.Query(AuditFilter{})
+	if entries[0].TargetType != auditTargetCluster || entries[1].TargetType != auditTargetConnector {
+		t.Fatalf("unexpected target types %+v", entries)
+	}
+}
+
+func TestMemoryAuditLoggerBounded(t *testing.T) {
+	logger := newMemoryAuditLogger(2)
+	for _, name := range []string{"a", "b", "c"} {
+		logger.Log(AuditLogEntry{Action: auditActionPause, ConnectorName: name, Status: auditStatusSuccess})
+	}
+
+	entries := logger.Query(AuditFilter{})
+	if len(entries) != 2 || entries[0].ConnectorName != "c" || entries[1].ConnectorName != "b" {
+		t.Fatalf("expected the two newest entries, got %+v", entries)
+	}
+	if entries[0].ID != "3" {
+		t.Fatalf("expected IDs to keep increasing, got %q", entries[0].ID)
+	}
+}
+
+func TestAuditLogHandlerFilters(t *testing.T) {
+	logger := withTestAuditLog(t, 10)
+	logger.Log(AuditLogEntry{Action: auditActionPause, ConnectorName: "alpha", Status: auditStatusSuccess})
+	logger.Log(AuditLogEntry{Action: auditActionDelete, ConnectorName: "beta", Status: auditStatusFailure})
+	logger.Log(AuditLogEntry{Action: auditActionResume, ConnectorName: "alpha", Status: auditStatusSuccess})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/default/audit-logs"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		auditLogHandler(rr, req)
+		return rr
+	}
+
+	var entries []AuditLogEntry
+	rr := get("?connector=alpha&limit=1")
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != auditActionResume {
+		t.Fatalf("expected newest alpha entry, got %+v", entries)
+	}
+
+	rr = get("?status=failure")
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ConnectorName != "beta" {
+		t.Fatalf("expected failed beta entry, got %+v", entries)
+	}
+
+	if rr := get("?limit=abc"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid limit, got %d", rr.Code)
+	}
+}
+
+func TestAuditLogEntryBodies(t *testing.T) {
+	withTestAuditLog(t, 10)
+	original := auditMaxBody
+	t.Cleanup(func() { auditMaxBody = original })
+	auditMaxBody = 90
+
+	handler := auditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error_code":400,"message":"Connector configuration is invalid and contains the following 1 error(s)"}`))
+	}))
+	config := `{"connector.class":"JdbcSinkConnector","connection.password":"hunter2"}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/api/default/connectors/alpha/config", strings.NewReader(config)))
+
+	get := func(path, id string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, path, nil), map[string]string{"cluster": "default", "id": id})
+		rr := httptest.NewRecorder()
+		if id == "" {
+			auditLogHandler(rr, req)
+		} else {
+			auditLogEntryHandler(rr, req)
+		}
+		return rr
+	}
+
+	if rr := get("/api/default/audit-logs", ""); strings.Contains(rr.Body.String(), "requestBody") {
+		t.Fatalf("expected the list to leave out bodies, got %s", rr.Body.String())
+	}
+	rr := get("/api/default/audit-logs/1", "1")
+	var entry AuditLogEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entry); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if entry.Status != auditStatusFailure || strings.Contains(entry.RequestBody, "hunter2") || !strings.Contains(entry.RequestBody, "JdbcSinkConnector") || entry.RequestTruncated {
+		t.Fatalf("expected the redacted config, got %+v", entry)
+	}
+	if len(entry.ResponseBody) != 90 || !entry.ResponseTruncated {
+		t.Fatalf("expected the response cut at 90 bytes, got %+v", entry)
+	}
+
+	if rr := get("/api/default/audit-logs/99", "99"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown entry, got %d", rr.Code)
+	}
+}
+
+func TestAuditLogHandlerTimeRange(t *testing.T) {
+	logger := withTestAuditLog(t, 10)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	logger.Log(AuditLogEntry{Action: auditActionPause, ConnectorName: "alpha", Timestamp: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)})
+	logger.Log(AuditLogEntry{Action: auditActionResume, ConnectorName: "alpha", Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, berlin)})
+	logger.Log(AuditLogEntry{Action: auditActionDelete, ConnectorName: "alpha", Timestamp: time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/default/audit-logs"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		auditLogHandler(rr, req)
+		return rr
+	}
+
+	rr := get("?since=2024-05-01T09:00:00Z&until=2024-05-02T00:00:00Z")
+	var entries []AuditLogEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != auditActionResume {
+		t.Fatalf("expected only the resume entry, got %+v", entries)
+	}
+	if !strings.Contains(rr.Body.String(), `"2024-05-01T10:00:00Z"`) {
+		t.Fatalf("expected timestamps to be stored in UTC, got %s", rr.Body.String())
+	}
+	if got := rr.Header().Get(timezoneHeader); got != "UTC" {
+		t.Fatalf("expected UTC timezone hint, got %q", got)
+	}
+
+	rr = get("?since=2024-05-01T10:30&tz=Europe/Berlin")
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(entries) != 2 || rr.Header().Get(timezoneHeader) != "Europe/Berlin" {
+		t.Fatalf("expected local since to be read in Europe/Berlin, got %d entries (%s)", len(entries), rr.Header().Get(timezoneHeader))
+	}
+
+	if rr := get("?since=last-week"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid since, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// auditArchiveLockTTL bounds how long a replica holds the Redis archive lock, should it
+// stop before releasing it.
+const auditArchiveLockTTL = 10 * time.Minute
+
+var (
+	// Audit archival moves entries older than AUDIT_ARCHIVE_AFTER out of the audit log
+	// into one gzip-compressed NDJSON file per UTC day, kept in AUDIT_ARCHIVE_DIR or an
+	// S3 bucket. Unset, entries stay in the log until AUDIT_LOG_MAX_ENTRIES drops them.
+	auditArchiveAfter    = getEnv("AUDIT_ARCHIVE_AFTER", "")
+	auditArchiveInterval = getEnv("AUDIT_ARCHIVE_INTERVAL", "1h")
+	auditArchiveDir      = getEnv("AUDIT_ARCHIVE_DIR", "")
+	auditArchiveBucket   = getEnv("AUDIT_ARCHIVE_S3_BUCKET", "")
+	auditArchivePrefix   = getEnv("AUDIT_ARCHIVE_S3_PREFIX", "audit/")
+	auditArchiveRegion   = getEnv("AUDIT_ARCHIVE_S3_REGION", getEnv("AWS_REGION", ""))
+	auditArchiveEndpoint = getEnv("AUDIT_ARCHIVE_S3_ENDPOINT", "")
+
+	// auditArchive is nil unless AUDIT_ARCHIVE_AFTER is set.
+	auditArchive *auditArchiver
+)
+
+// auditArchiveNamePattern matches the archive of one day, audit-2024-05-01.ndjson.gz.
+var auditArchiveNamePattern = regexp.MustCompile(`^audit-(\d{4}-\d{2}-\d{2})\.ndjson\.gz$`)
+
+var errAuditArchiveNotFound = errors.New("audit archive not found")
+
+// AuditArchive describes the archive file of one day.
+type AuditArchive struct {
+	Name       string    `json:"name"`
+	Date       string    `json:"date"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+}
+
+func auditArchiveName(day time.Time) string {
+	return "audit-" + day.UTC().Format("2006-01-02") + ".ndjson.gz"
+}
+
+// auditCompactor is implemented by audit loggers that can hand their old entries over
+// to the archiver.
+type auditCompactor interface {
+	// Compact passes the entries logged before cutoff, oldest first, to archive and
+	// drops them once archive succeeded. It returns how many entries were archived.
+	Compact(ctx context.Context, cutoff time.Time, archive func([]AuditLogEntry) error) (int, error)
+}
+
+// auditArchiveStore keeps the archive files.
+type auditArchiveStore interface {
+	// append adds a gzip member to the named archive, creating it when missing. A file
+	// of concatenated members reads as one gzip stream.
+	append(ctx context.Context, name string, member []byte) error
+	list(ctx context.Context) ([]AuditArchive, error)
+	open(ctx context.Context, name string) (io.ReadCloser, error)
+	String() string
+}
+
+// auditArchiver periodically compacts the audit log into its store.
+type auditArchiver struct {
+	after time.Duration
+	store auditArchiveStore
+	now   func() time.Time
+}
+
+// loadAuditArchiver reads the AUDIT_ARCHIVE_* settings. It returns nil when
+// AUDIT_ARCHIVE_AFTER is unset. Archives go to S3 when AUDIT_ARCHIVE_S3_BUCKET is set,
+// otherwise to AUDIT_ARCHIVE_DIR, which defaults to audit-archive under DATA_DIR.
+func loadAuditArchiver() (*auditArchiver, error) {
+	if strings.TrimSpace(auditArchiveAfter) == "" {
+		return nil, nil
+	}
+	after, err := parseWindow(auditArchiveAfter, 0)
+	if err != nil {
+		return nil, &configError{name: "AUDIT_ARCHIVE_AFTER", value: auditArchiveAfter}
+	}
+	if _, err := parseWindow(auditArchiveInterval, time.Hour); err != nil {
+		return nil, &configError{name: "AUDIT_ARCHIVE_INTERVAL", value: auditArchiveInterval}
+	}
+
+	archiver := &auditArchiver{after: after, now: time.Now}
+	if bucket := strings.TrimSpace(auditArchiveBucket); bucket != "" {
+		store, err := newS3ArchiveStore(bucket)
+		if err != nil {
+			return nil, err
+		}
+		archiver.store = store
+		return archiver, nil
+	}
+
+	dir := strings.TrimSpace(auditArchiveDir)
+	if dir == "" && dataDir != "" {
+		dir = filepath.Join(dataDir, "audit-archive")
+	}
+	if dir == "" {
+		return nil, errors.New("AUDIT_ARCHIVE_AFTER needs AUDIT_ARCHIVE_DIR, DATA_DIR or AUDIT_ARCHIVE_S3_BUCKET")
+	}
+	archiver.store = dirArchiveStore{dir: dir}
+	return archiver, nil
+}
+
+// cutoff is the start of the oldest UTC day that is still within the hot window, so
+// only whole days are archived and each day ends up in one file.
+func (a *auditArchiver) cutoff() time.Time {
+	return a.now().UTC().Add(-a.after).Truncate(24 * time.Hour)
+}
+
+// compact archives the entries of logger older than the hot window.
+func (a *auditArchiver) compact(ctx context.Context, logger AuditLogger) (int, error) {
+	compactor, ok := logger.(auditCompactor)
+	if !ok {
+		return 0, nil
+	}
+	return compactor.Compact(ctx, a.cutoff(), func(entries []AuditLogEntry) error {
+		return a.archive(ctx, entries)
+	})
+}
+
+// archive writes entries, grouped by UTC day, to the archive of their day.
+func (a *auditArchiver) archive(ctx context.Context, entries []AuditLogEntry) error {
+	days := make(map[string][]AuditLogEntry)
+	for _, entry := range entries {
+		name := auditArchiveName(entry.Timestamp)
+		days[name] = append(days[name], entry)
+	}
+	names := make([]string, 0, len(days))
+	for name := range days {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		encoder := json.NewEncoder(zw)
+		for _, entry := range days[name] {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		if err := a.store.append(ctx, name, buf.Bytes()); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// run compacts the audit log each interval until stop is closed.
+func (a *auditArchiver) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		archived, err := a.compact(ctx, auditLog)
+		cancel()
+		if err != nil {
+			log.Printf("audit: failed to archive old entries: %v", err)
+		} else if archived > 0 {
+			log.Printf("audit: archived %d entries older than %s to %s", archived, a.cutoff().Format("2006-01-02"), a.store)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Compact implements auditCompactor. Archiving runs without holding the lock, so
+// entries logged meanwhile are kept.
+func (l *memoryAuditLogger) Compact(ctx context.Context, cutoff time.Time, archive func([]AuditLogEntry) error) (int, error) {
+	l.mu.RLock()
+	var old []AuditLogEntry
+	for _, entry := range l.entries {
+		if entry.Timestamp.Before(cutoff) {
+			old = append(old, entry)
+		}
+	}
+	l.mu.RUnlock()
+	if len(old) == 0 {
+		return 0, nil
+	}
+	if err := archive(old); err != nil {
+		return 0, err
+	}
+
+	archived := make(map[string]bool, len(old))
+	for _, entry := range old {
+		archived[entry.ID] = true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kept := make([]AuditLogEntry, 0, len(l.entries))
+	for _, entry := range l.entries {
+		if !archived[entry.ID] {
+			kept = append(kept, entry)
+		}
+	}
+	l.entries = kept
+	return len(old), nil
+}
+
+// Compact implements auditCompactor for the shared log. One replica at a time holds a
+// lock while it reads the oldest records, archives them and deletes them from the
+// stream. Should deleting fail after archiving, the entries are archived again on the
+// next run.
+func (l *redisAuditLogger) Compact(ctx context.Context, cutoff time.Time, archive func([]AuditLogEntry) error) (int, error) {
+	reply, err := l.client.do(ctx, "SET", l.archiveKey, l.replica, "PX", strconv.FormatInt(auditArchiveLockTTL.Milliseconds(), 10), "NX")
+	if err != nil || reply == nil {
+		return 0, err
+	}
+	defer l.client.do(context.Background(), "DEL", l.archiveKey)
+
+	var old []AuditLogEntry
+	var streamIDs []string
+	start := "-"
+scan:
+	for {
+		reply, err := l.client.do(ctx, "XRANGE", l.streamKey, start, "+", "COUNT", strconv.Itoa(auditRedisPage))
+		if err != nil {
+			return 0, err
+		}
+		records, _ := reply.([]interface{})
+		for _, record := range records {
+			id, entry, ok := parseAuditStreamRecord(record)
+			if id != "" {
+				start = "(" + id
+			}
+			if ok && !entry.Timestamp.Before(cutoff) {
+				break scan
+			}
+			if ok {
+				old = append(old, entry)
+			}
+			streamIDs = append(streamIDs, id)
+		}
+		if len(records) < auditRedisPage {
+			break
+		}
+	}
+	if len(streamIDs) == 0 {
+		return 0, nil
+	}
+	if len(old) > 0 {
+		if err := archive(old); err != nil {
+			return 0, err
+		}
+	}
+	for len(streamIDs) > 0 {
+		n := len(streamIDs)
+		if n > auditRedisPage {
+			n = auditRedisPage
+		}
+		args := append([]string{"XDEL", l.streamKey}, streamIDs[:n]...)
+		if _, err := l.client.do(ctx, args...); err != nil {
+			return len(old), fmt.Errorf("archived, but failed to trim the stream: %w", err)
+		}
+		streamIDs = streamIDs[n:]
+	}
+	return len(old), nil
+}
+
+// dirArchiveStore keeps archives as files in a directory.
+type dirArchiveStore struct {
+	dir string
+}
+
+func (s dirArchiveStore) String() string {
+	return s.dir
+}
+
+func (s dirArchiveStore) append(ctx context.Context, name string, member []byte) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(member); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s dirArchiveStore) list(ctx context.Context) ([]AuditArchive, error) {
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []AuditArchive{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	archives := make([]AuditArchive, 0, len(files))
+	for _, file := range files {
+		match := auditArchiveNamePattern.FindStringSubmatch(file.Name())
+		if match == nil || !file.Type().IsRegular() {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, AuditArchive{Name: file.Name(), Date: match[1], Size: info.Size(), ModifiedAt: info.ModTime().UTC()})
+	}
+	return archives, nil
+}
+
+func (s dirArchiveStore) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errAuditArchiveNotFound
+	}
+	return f, err
+}
+
+// s3ArchiveStore keeps archives as objects under a prefix of an S3 bucket, addressed
+// path-style so S3-compatible stores such as MinIO work through
+// AUDIT_ARCHIVE_S3_ENDPOINT. Requests are signed like the Secrets Manager ones.
+type s3ArchiveStore struct {
+	endpoint string
+	bucket   string
+	prefix   string
+	region   string
+	creds    awsCredentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+func newS3ArchiveStore(bucket string) (*s3ArchiveStore, error) {
+	region := strings.TrimSpace(auditArchiveRegion)
+	if region == "" {
+		return nil, errors.New("AUDIT_ARCHIVE_S3_REGION or AWS_REGION is required when AUDIT_ARCHIVE_S3_BUCKET is set")
+	}
+	creds := awsCredentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required when AUDIT_ARCHIVE_S3_BUCKET is set")
+	}
+	endpoint := strings.TrimRight(strings.TrimSpace(auditArchiveEndpoint), "/")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	} else if _, err := url.ParseRequestURI(endpoint); err != nil {
+		return nil, &configError{name: "AUDIT_ARCHIVE_S3_ENDPOINT", value: auditArchiveEndpoint}
+	}
+	return &s3ArchiveStore{
+		endpoint: endpoint,
+		bucket:   bucket,
+		prefix:   strings.TrimLeft(auditArchivePrefix, "/"),
+		region:   region,
+		creds:    creds,
+		client:   newUpstreamClient(time.Minute),
+		now:      time.Now,
+	}, nil
+}
+
+func (s *s3ArchiveStore) String() string {
+	return "s3://" + s.bucket + "/" + s.prefix
+}
+
+// do sends a signed request for key (the bucket itself when empty) with query.
+func (s *s3ArchiveStore) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	target, err := url.Parse(s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + s.bucket
+	if key != "" {
+		target.Path += "/" + key
+	}
+	target.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	signAWSRequest(req, body, "s3", s.region, s.creds, s.now())
+	return s.client.Do(req)
+}
+
+func s3Error(resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	return fmt.Errorf("s3: HTTP %d %s %s", resp.StatusCode, body.Code, body.Message)
+}
+
+// append reads the current object, if any, and writes it back with member added: S3
+// objects cannot be appended to in place.
+func (s *s3ArchiveStore) append(ctx context.Context, name string, member []byte) error {
+	existing, err := s.open(ctx, name)
+	var data []byte
+	switch {
+	case err == nil:
+		data, err = io.ReadAll(existing)
+		existing.Close()
+		if err != nil {
+			return err
+		}
+	case !errors.Is(err, errAuditArchiveNotFound):
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, s.prefix+name, nil, append(data, member...))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *s3ArchiveStore) list(ctx context.Context) ([]AuditArchive, error) {
+	archives := make([]AuditArchive, 0)
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: decode object list: %w", err)
+		}
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.prefix)
+			if match := auditArchiveNamePattern.FindStringSubmatch(name); match != nil {
+				archives = append(archives, AuditArchive{Name: name, Date: match[1], Size: object.Size, ModifiedAt: object.LastModified.UTC()})
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return archives, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *s3ArchiveStore) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errAuditArchiveNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+}
+
+func writeAuditArchiveDisabled(w http.ResponseWriter) {
+	writeJSONError(w, http.StatusNotFound, "audit_archive_disabled", "audit archival is not enabled (set AUDIT_ARCHIVE_AFTER)")
+}
+
+// auditArchivesHandler lists the archive files, newest day first.
+func auditArchivesHandler(w http.ResponseWriter, r *http.Request) {
+	archiver := auditArchive
+	if archiver == nil {
+		writeAuditArchiveDisabled(w)
+		return
+	}
+	archives, err := archiver.store.list(r.Context())
+	if err != nil {
+		log.Printf("audit: failed to list archives: %v", err)
+		writeJSONError(w, http.StatusBadGateway, "audit_archive_failed", "failed to list the audit archives")
+		return
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Date > archives[j].Date })
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"archives":  archives,
+		"hotWindow": archiver.after.String(),
+		"cutoff":    archiver.cutoff(),
+	})
+}
+
+// auditArchiveHandler downloads one archive file as stored: gzip-compressed NDJSON
+// with the full entries, request and response bodies included.
+func auditArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	archiver := auditArchive
+	if archiver == nil {
+		writeAuditArchiveDisabled(w)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if !auditArchiveNamePattern.MatchString(name) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_archive", "archive names look like audit-2024-05-01.ndjson.gz")
+		return
+	}
+	archive, err := archiver.store.open(r.Context(), name)
+	if errors.Is(err, errAuditArchiveNotFound) {
+		writeJSONError(w, http.StatusNotFound, "archive_not_found", fmt.Sprintf("no audit archive %s", name))
+		return
+	}
+	if err != nil {
+		log.Printf("audit: failed to open archive %s: %v", name, err)
+		writeJSONError(w, http.StatusBadGateway, "audit_archive_failed", "failed to read the audit archive")
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, archive); err != nil {
+		log.Printf("audit: failed to send archive %s: %v", name, err)
+	}
+}
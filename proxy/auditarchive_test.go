@@ -0,0 +1,259 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// readAuditArchive decodes every entry of a gzip-compressed NDJSON archive.
+func readAuditArchive(t *testing.T, r io.Reader) []AuditLogEntry {
+	t.Helper()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []AuditLogEntry
+	decoder := json.NewDecoder(zr)
+	for decoder.More() {
+		var entry AuditLogEntry
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditArchiverCompactsMemoryLog(t *testing.T) {
+	now := time.Date(2024, 5, 10, 15, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	archiver := &auditArchiver{after: 48 * time.Hour, store: dirArchiveStore{dir: dir}, now: func() time.Time { return now }}
+	logger := newMemoryAuditLogger(100)
+	logAt := func(at time.Time, connector string) {
+		logger.Log(AuditLogEntry{Timestamp: at, Action: auditActionUpdate, ConnectorName: connector, RequestBody: `{"tasks.max":"2"}`})
+	}
+
+	logAt(now.Add(-5*24*time.Hour), "old-1")                  // 2024-05-05
+	logAt(now.Add(-4*24*time.Hour), "old-2")                  // 2024-05-06
+	logAt(time.Date(2024, 5, 8, 1, 0, 0, 0, time.UTC), "hot") // within the day of the cutoff
+	logAt(now, "new")
+
+	if got := archiver.cutoff(); !got.Equal(time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the cutoff at the start of the day, got %s", got)
+	}
+	archived, err := archiver.compact(context.Background(), logger)
+	if err != nil || archived != 2 {
+		t.Fatalf("expected two entries to be archived, got %d %v", archived, err)
+	}
+	if remaining := logger.Query(AuditFilter{}); len(remaining) != 2 || remaining[1].ConnectorName != "hot" {
+		t.Fatalf("expected the hot entries to stay, got %+v", remaining)
+	}
+
+	// An entry for an archived day, e.g. from an import, is added to that day's file.
+	logAt(now.Add(-5*24*time.Hour+time.Hour), "late")
+	if archived, err := archiver.compact(context.Background(), logger); err != nil || archived != 1 {
+		t.Fatalf("expected the late entry to be archived, got %d %v", archived, err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "audit-2024-05-05.ndjson.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries := readAuditArchive(t, f)
+	if len(entries) != 2 || entries[0].ConnectorName != "old-1" || entries[1].ConnectorName != "late" || entries[0].RequestBody == "" {
+		t.Fatalf("expected both full entries of the day, got %+v", entries)
+	}
+
+	archives, err := archiver.store.list(context.Background())
+	if err != nil || len(archives) != 2 || archives[0].Date == "" {
+		t.Fatalf("expected two daily archives, got %+v %v", archives, err)
+	}
+}
+
+func TestRedisAuditLoggerCompact(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	logger := newTestRedisAuditLogger(t, addr)
+	cutoff := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+	logger.Log(AuditLogEntry{Timestamp: cutoff.Add(-48 * time.Hour), Action: auditActionCreate, ConnectorName: "a"})
+	logger.Log(AuditLogEntry{Timestamp: cutoff.Add(-time.Hour), Action: auditActionPause, ConnectorName: "b"})
+	logger.Log(AuditLogEntry{Timestamp: cutoff.Add(time.Hour), Action: auditActionResume, ConnectorName: "c"})
+
+	var got []AuditLogEntry
+	archived, err := logger.Compact(context.Background(), cutoff, func(entries []AuditLogEntry) error {
+		got = entries
+		return nil
+	})
+	if err != nil || archived != 2 || len(got) != 2 || got[0].ConnectorName != "a" || got[1].ID != "2" {
+		t.Fatalf("expected the two old entries oldest first, got %d %+v %v", archived, got, err)
+	}
+	if remaining := logger.Query(AuditFilter{}); len(remaining) != 1 || remaining[0].ConnectorName != "c" {
+		t.Fatalf("expected only the new entry to stay in the stream, got %+v", remaining)
+	}
+	if archived, err := logger.Compact(context.Background(), cutoff, func([]AuditLogEntry) error { return nil }); archived != 0 || err != nil {
+		t.Fatalf("expected nothing left to archive, got %d %v", archived, err)
+	}
+}
+
+// fakeS3 serves PutObject, GetObject and ListObjectsV2 for one bucket.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	signed  bool
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signed = strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") && r.Header.Get("X-Amz-Content-Sha256") != ""
+
+	key := strings.TrimPrefix(r.URL.Path, "/archive-bucket/")
+	switch {
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[key] = body
+	case r.URL.Path == "/archive-bucket" && r.URL.Query().Get("list-type") == "2":
+		type object struct {
+			Key          string
+			Size         int64
+			LastModified string
+		}
+		var result struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []object
+		}
+		for name, body := range s.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				result.Contents = append(result.Contents, object{name, int64(len(body)), "2024-05-10T15:00:00.000Z"})
+			}
+		}
+		xml.NewEncoder(w).Encode(result)
+	default:
+		body, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		w.Write(body)
+	}
+}
+
+func TestS3ArchiveStore(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := &s3ArchiveStore{
+		endpoint: server.URL, bucket: "archive-bucket", prefix: "audit/", region: "eu-west-1",
+		creds: awsCredentials{accessKeyID: "AKID", secretAccessKey: "secret"}, client: server.Client(), now: time.Now,
+	}
+	archiver := &auditArchiver{after: time.Hour, store: store, now: time.Now}
+	day := time.Date(2024, 5, 5, 10, 0, 0, 0, time.UTC)
+	for _, connector := range []string{"a", "b"} {
+		if err := archiver.archive(context.Background(), []AuditLogEntry{{ID: connector, Timestamp: day, ConnectorName: connector}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !fake.signed {
+		t.Fatal("expected signed S3 requests")
+	}
+
+	archives, err := store.list(context.Background())
+	if err != nil || len(archives) != 1 || archives[0].Name != "audit-2024-05-05.ndjson.gz" || archives[0].ModifiedAt.IsZero() {
+		t.Fatalf("unexpected archives %+v %v", archives, err)
+	}
+	body, err := store.open(context.Background(), archives[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if entries := readAuditArchive(t, body); len(entries) != 2 || entries[1].ConnectorName != "b" {
+		t.Fatalf("expected both appends in one object, got %+v", entries)
+	}
+	if _, err := store.open(context.Background(), "audit-2024-05-06.ndjson.gz"); err != errAuditArchiveNotFound {
+		t.Fatalf("expected a missing archive, got %v", err)
+	}
+}
+
+func TestAuditArchiveHandlers(t *testing.T) {
+	original := auditArchive
+	t.Cleanup(func() { auditArchive = original })
+
+	call := func(handler http.HandlerFunc, vars map[string]string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/audit-logs/archives", nil), vars)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	auditArchive = nil
+	if rr := call(auditArchivesHandler, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 while archival is off, got %d", rr.Code)
+	}
+
+	auditArchive = &auditArchiver{after: 24 * time.Hour, store: dirArchiveStore{dir: t.TempDir()}, now: time.Now}
+	if err := auditArchive.archive(context.Background(), []AuditLogEntry{{ID: "1", Timestamp: time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := call(auditArchivesHandler, nil)
+	var list struct {
+		Archives []AuditArchive `json:"archives"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list.Archives) != 1 {
+		t.Fatalf("expected one archive, got %s", rr.Body.String())
+	}
+
+	rr = call(auditArchiveHandler, map[string]string{"name": list.Archives[0].Name})
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/gzip" || !strings.Contains(rr.Header().Get("Content-Disposition"), "audit-2024-05-05") {
+		t.Fatalf("unexpected download %d %v", rr.Code, rr.Header())
+	}
+	if entries := readAuditArchive(t, rr.Body); len(entries) != 1 {
+		t.Fatalf("expected the archived entry, got %+v", entries)
+	}
+	if rr := call(auditArchiveHandler, map[string]string{"name": "../secrets.json"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected other names to be refused, got %d", rr.Code)
+	}
+	if rr := call(auditArchiveHandler, map[string]string{"name": "audit-2024-05-06.ndjson.gz"}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing day, got %d", rr.Code)
+	}
+}
+
+func TestLoadAuditArchiver(t *testing.T) {
+	originals := []string{auditArchiveAfter, auditArchiveDir, auditArchiveBucket, dataDir}
+	t.Cleanup(func() {
+		auditArchiveAfter, auditArchiveDir, auditArchiveBucket, dataDir = originals[0], originals[1], originals[2], originals[3]
+	})
+
+	auditArchiveAfter, auditArchiveDir, auditArchiveBucket, dataDir = "", "", "", ""
+	if archiver, err := loadAuditArchiver(); archiver != nil || err != nil {
+		t.Fatalf("expected archival to be off, got %+v %v", archiver, err)
+	}
+	auditArchiveAfter = "30d"
+	if _, err := loadAuditArchiver(); err == nil {
+		t.Fatal("expected archival without a destination to be refused")
+	}
+	dataDir = t.TempDir()
+	archiver, err := loadAuditArchiver()
+	if err != nil || archiver.after != 30*24*time.Hour || archiver.store.String() != filepath.Join(dataDir, "audit-archive") {
+		t.Fatalf("expected archives under DATA_DIR, got %+v %v", archiver, err)
+	}
+	auditArchiveAfter = "soon"
+	if _, err := loadAuditArchiver(); err == nil || !strings.Contains(err.Error(), "AUDIT_ARCHIVE_AFTER") {
+		t.Fatalf("expected an invalid window to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	auditFormatJSON   = "json"
+	auditFormatCSV    = "csv"
+	auditFormatNDJSON = "ndjson"
+)
+
+var auditCSVHeader = []string{"id", "timestamp", "user", "clientIp", "cluster", "action", "targetType", "connectorName", "status", "httpStatus", "parameters", "details"}
+
+// unsafeFilenameChars are replaced in export file names.
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// auditExportFilename names an export after the cluster and every filter applied, so a
+// downloaded file records what it contains, e.g.
+// audit-logs_default_connector-orders_since-20240501T000000Z.csv.
+func auditExportFilename(cluster string, filter AuditFilter, format string) string {
+	parts := []string{"audit-logs", cluster}
+	add := func(name, value string) {
+		if value != "" {
+			parts = append(parts, name+"-"+value)
+		}
+	}
+	add("connector", filter.Connector)
+	add("action", strings.ToLower(filter.Action))
+	add("target", strings.ToLower(filter.TargetType))
+	add("status", strings.ToLower(filter.Status))
+	if !filter.Range.Since.IsZero() {
+		add("since", filter.Range.Since.Format("20060102T150405Z"))
+	}
+	if !filter.Range.Until.IsZero() {
+		add("until", filter.Range.Until.Format("20060102T150405Z"))
+	}
+	if filter.Limit > 0 {
+		add("limit", strconv.Itoa(filter.Limit))
+	}
+
+	name := unsafeFilenameChars.ReplaceAllString(strings.Join(parts, "_"), "-")
+	return name + "." + format
+}
+
+// csvSafe neutralises values that spreadsheet applications would evaluate as formulas.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// auditCSVRecord flattens an entry for CSV. Parameters and details are embedded as JSON.
+// When the request named a time zone other than UTC, a localTimestamp column follows
+// timestamp.
+func auditCSVRecord(entry AuditLogEntry, loc *time.Location) []string {
+	embed := func(values map[string]interface{}) string {
+		if len(values) == 0 {
+			return ""
+		}
+		data, err := json.Marshal(values)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	record := []string{entry.ID, entry.Timestamp.UTC().Format(time.RFC3339Nano)}
+	if loc != time.UTC {
+		record = append(record, entry.Timestamp.In(loc).Format(time.RFC3339Nano))
+	}
+	return append(record,
+		csvSafe(entry.User),
+		entry.ClientIP,
+		csvSafe(entry.Cluster),
+		entry.Action,
+		entry.TargetType,
+		csvSafe(entry.ConnectorName),
+		entry.Status,
+		strconv.Itoa(entry.HTTPStatus),
+		csvSafe(embed(entry.Parameters)),
+		csvSafe(embed(entry.Details)),
+	)
+}
+
+// writeAuditExport writes entries as a CSV or NDJSON attachment.
+func writeAuditExport(w http.ResponseWriter, format, cluster string, filter AuditFilter, loc *time.Location, entries []AuditLogEntry) {
+	if format == auditFormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", auditExportFilename(cluster, filter, format)))
+	w.WriteHeader(http.StatusOK)
+
+	if format == auditFormatNDJSON {
+		encoder := json.NewEncoder(w)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				log.Printf("audit: failed to write NDJSON export: %v", err)
+				return
+			}
+		}
+		return
+	}
+
+	writer := csv.NewWriter(w)
+	header := auditCSVHeader
+	if loc != time.UTC {
+		header = append([]string{header[0], header[1], "localTimestamp"}, header[2:]...)
+	}
+	writer.Write(header)
+	for _, entry := range entries {
+		writer.Write(auditCSVRecord(entry, loc))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("audit: failed to write CSV export: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func seedAuditExportLog(t *testing.T) {
+	t.Helper()
+	logger := withTestAuditLog(t, 10)
+	logger.Log(AuditLogEntry{Timestamp: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), User: "alice", Cluster: "default", Action: auditActionPause, ConnectorName: "orders", Status: auditStatusSuccess, HTTPStatus: 202})
+	logger.Log(AuditLogEntry{Timestamp: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC), User: "=cmd|' /C calc'!A0", Cluster: "default", Action: auditActionDelete, ConnectorName: "orders", Status: auditStatusFailure, HTTPStatus: 500, Details: map[string]interface{}{"reason": "boom"}})
+	logger.Log(AuditLogEntry{Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), User: "bob", Cluster: "default", Action: auditActionPause, ConnectorName: "payments", Status: auditStatusSuccess, HTTPStatus: 202})
+}
+
+func getAuditExport(query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/default/audit-logs"+query, nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	auditLogHandler(rr, req)
+	return rr
+}
+
+func TestAuditLogCSVExport(t *testing.T) {
+	seedAuditExportLog(t)
+
+	rr := getAuditExport("?format=csv&connector=orders&since=2024-05-01T00:00:00Z")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	want := `attachment; filename="audit-logs_default_connector-orders_since-20240501T000000Z.csv"`
+	if got := rr.Header().Get("Content-Disposition"); got != want {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(auditCSVHeader, ",") {
+		t.Fatalf("unexpected CSV: %v", records)
+	}
+	failed := records[1]
+	if failed[1] != "2024-05-01T09:00:00Z" || failed[2] != "'=cmd|' /C calc'!A0" || failed[11] != `{"reason":"boom"}` {
+		t.Fatalf("unexpected CSV row: %v", failed)
+	}
+}
+
+func TestAuditLogCSVExportLocalTimestamp(t *testing.T) {
+	seedAuditExportLog(t)
+
+	records, err := csv.NewReader(getAuditExport("?format=csv&tz=Asia/Tokyo&limit=1").Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if records[0][2] != "localTimestamp" || records[1][2] != "2024-05-01T19:00:00+09:00" {
+		t.Fatalf("expected local timestamp column, got %v", records)
+	}
+}
+
+func TestAuditLogNDJSONExport(t *testing.T) {
+	seedAuditExportLog(t)
+
+	rr := getAuditExport("?format=ndjson&action=pause")
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.Contains(got, "audit-logs_default_action-pause.ndjson") {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+
+	var connectors []string
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		var entry AuditLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("decode line %q: %v", scanner.Text(), err)
+		}
+		connectors = append(connectors, entry.ConnectorName)
+	}
+	if strings.Join(connectors, ",") != "payments,orders" {
+		t.Fatalf("expected newest-first pause entries, got %v", connectors)
+	}
+}
+
+func TestAuditLogExportRejectsUnknownFormat(t *testing.T) {
+	seedAuditExportLog(t)
+	if rr := getAuditExport("?format=xlsx"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
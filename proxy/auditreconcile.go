@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// auditReconcileInterval is how often the connectors of every cluster are compared
+	// with the creates and deletes in the audit log; 0 turns the reconciler off.
+	auditReconcileInterval = getEnv("AUDIT_RECONCILE_INTERVAL", "5m")
+
+	auditReconciliation = newAuditReconciler(time.Now)
+)
+
+const (
+	unexplainedChangesFile = "unexplained-changes.json"
+	// unexplainedChangesMax is how many unexplained changes are kept, over all clusters.
+	unexplainedChangesMax = 1000
+	// auditReconcileGrace widens the window audit entries are looked up in, for workers
+	// whose connector list lags behind the worker that made the change.
+	auditReconcileGrace = time.Minute
+
+	connectorAppeared    = "appeared"
+	connectorDisappeared = "disappeared"
+)
+
+// UnexplainedChange is a connector that appeared or disappeared without an audit entry
+// for it, e.g. because it was created or deleted directly against Kafka Connect. The
+// change happened between Since and DetectedAt.
+type UnexplainedChange struct {
+	ID             string    `json:"id"`
+	Cluster        string    `json:"cluster"`
+	Connector      string    `json:"connector"`
+	Change         string    `json:"change"`
+	ConnectorClass string    `json:"connectorClass,omitempty"`
+	Since          time.Time `json:"since"`
+	DetectedAt     time.Time `json:"detectedAt"`
+}
+
+// UnexplainedChangesReport is returned by GET /api/{cluster}/audit-logs/unexplained.
+type UnexplainedChangesReport struct {
+	Cluster          string              `json:"cluster"`
+	LastReconciledAt *time.Time          `json:"lastReconciledAt,omitempty"`
+	Changes          []UnexplainedChange `json:"changes"`
+}
+
+// clusterObservation is the connector list a pass read from a cluster, with the changes
+// still waiting for their audit entry.
+type clusterObservation struct {
+	at         time.Time
+	connectors map[string]string // connector name to connector.class
+	pending    []UnexplainedChange
+}
+
+type unexplainedDocument struct {
+	NextID  int                 `json:"nextId"`
+	Changes []UnexplainedChange `json:"changes"`
+}
+
+// auditReconciler compares the connectors of each cluster between passes and flags the
+// ones that appeared or disappeared without a matching CREATE, RESTORE, DELETE or
+// CLEANUP_STALE audit entry. The first pass after startup only takes a baseline.
+type auditReconciler struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	observed map[string]*clusterObservation
+	changes  []UnexplainedChange
+	nextID   int
+}
+
+func newAuditReconciler(now func() time.Time) *auditReconciler {
+	return &auditReconciler{now: now, observed: make(map[string]*clusterObservation)}
+}
+
+func (a *auditReconciler) load() error {
+	var doc unexplainedDocument
+	if err := loadJSON(unexplainedChangesFile, &doc); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nextID, a.changes = doc.NextID, doc.Changes
+	return nil
+}
+
+// saveLocked persists the unexplained changes. Callers must hold a.mu.
+func (a *auditReconciler) saveLocked() error {
+	return saveJSON(unexplainedChangesFile, unexplainedDocument{NextID: a.nextID, Changes: a.changes})
+}
+
+// clusters returns the clusters to reconcile: the default cluster and every cluster of
+// KAFKA_CONNECT_CLUSTERS except standbys, whose connectors the standby sync manages.
+func (a *auditReconciler) clusters() []string {
+	seen := map[string]bool{alertMetadataCluster: true}
+	for cluster := range clusterURLs {
+		seen[cluster] = true
+	}
+	clusters := make([]string, 0, len(seen))
+	for cluster := range seen {
+		if !standbys.inStandby(cluster) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	sort.Strings(clusters)
+	return clusters
+}
+
+// sameConnectCluster reports whether two {cluster} names reach the same Kafka Connect
+// cluster; names not in KAFKA_CONNECT_CLUSTERS all reach the default one.
+func sameConnectCluster(a, b string) bool {
+	return a == b || connectURLFor(a) == connectURLFor(b)
+}
+
+// detailNames reads a list of connector names from audit details, which hold []string
+// when logged and []interface{} once read back from a persistent audit store.
+func detailNames(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		names := make([]string, 0, len(v))
+		for _, item := range v {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// explained reports whether the audit log has an entry for a change.
+func (a *auditReconciler) explained(change UnexplainedChange) bool {
+	window := timeRange{Since: change.Since.Add(-auditReconcileGrace)}
+	actions := []string{auditActionCreate, auditActionRestore}
+	if change.Change == connectorDisappeared {
+		actions = []string{auditActionDelete}
+	}
+	for _, entry := range auditLog.Query(AuditFilter{Connector: change.Connector, Status: auditStatusSuccess, Range: window}) {
+		if sameConnectCluster(entry.Cluster, change.Cluster) && containsString(actions, entry.Action) {
+			return true
+		}
+	}
+	if change.Change != connectorDisappeared {
+		return false
+	}
+	for _, entry := range auditLog.Query(AuditFilter{Action: auditActionCleanupStale, Range: window}) {
+		if sameConnectCluster(entry.Cluster, change.Cluster) && containsString(detailNames(entry.Details["deleted"]), change.Connector) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcile compares the connectors of a cluster with the previous pass. A change
+// without an audit entry is held back for one pass, since the entry of a request still
+// in flight is only logged once it completes, and flagged if the entry is still missing.
+func (a *auditReconciler) reconcile(ctx context.Context, cluster string) error {
+	connectors, err := fetchExpandedConnectorStatuses(ctx, connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		return err
+	}
+	now := a.now().UTC()
+	current := make(map[string]string, len(connectors))
+	for name, connector := range connectors {
+		current[name] = connector.Info.Config["connector.class"]
+	}
+
+	a.mu.Lock()
+	previous := a.observed[cluster]
+	a.mu.Unlock()
+
+	var flagged, pending []UnexplainedChange
+	if previous != nil {
+		for _, change := range previous.pending {
+			if !a.explained(change) {
+				flagged = append(flagged, change)
+			}
+		}
+		hold := func(name, change, class string) {
+			candidate := UnexplainedChange{Cluster: cluster, Connector: name, Change: change, ConnectorClass: class, Since: previous.at, DetectedAt: now}
+			if !a.explained(candidate) {
+				pending = append(pending, candidate)
+			}
+		}
+		for name, class := range current {
+			if _, ok := previous.connectors[name]; !ok {
+				hold(name, connectorAppeared, class)
+			}
+		}
+		for name, class := range previous.connectors {
+			if _, ok := current[name]; !ok {
+				hold(name, connectorDisappeared, class)
+			}
+		}
+	}
+	sort.Slice(flagged, func(i, j int) bool { return flagged[i].Connector < flagged[j].Connector })
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.observed[cluster] = &clusterObservation{at: now, connectors: current, pending: pending}
+	if len(flagged) == 0 {
+		return nil
+	}
+	for _, change := range flagged {
+		a.nextID++
+		change.ID = strconv.Itoa(a.nextID)
+		a.changes = append(a.changes, change)
+		log.Printf("audit reconcile: connector %s %s on cluster %s without an audit entry", change.Connector, change.Change, cluster)
+	}
+	if overflow := len(a.changes) - unexplainedChangesMax; overflow > 0 {
+		a.changes = append([]UnexplainedChange(nil), a.changes[overflow:]...)
+	}
+	if err := a.saveLocked(); err != nil {
+		log.Printf("audit reconcile: failed to persist unexplained changes: %v", err)
+	}
+	return nil
+}
+
+// report returns the unexplained changes of a cluster within r, newest first.
+func (a *auditReconciler) report(cluster string, r timeRange) UnexplainedChangesReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report := UnexplainedChangesReport{Cluster: cluster, Changes: []UnexplainedChange{}}
+	if observation, ok := a.observed[cluster]; ok {
+		at := observation.at
+		report.LastReconciledAt = &at
+	}
+	for i := len(a.changes) - 1; i >= 0; i-- {
+		if change := a.changes[i]; change.Cluster == cluster && r.contains(change.DetectedAt) {
+			report.Changes = append(report.Changes, change)
+		}
+	}
+	return report
+}
+
+func (a *auditReconciler) tick(ctx context.Context) {
+	for _, cluster := range a.clusters() {
+		if err := a.reconcile(ctx, cluster); err != nil {
+			log.Printf("audit reconcile: failed to list the connectors of cluster %s: %v", cluster, err)
+		}
+	}
+}
+
+func (a *auditReconciler) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		a.tick(ctx)
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// unexplainedChangesHandler lists the connectors of a cluster that appeared or
+// disappeared without going through the proxy, newest first, for security review.
+func unexplainedChangesHandler(w http.ResponseWriter, r *http.Request) {
+	window, loc, err := parseTimeRange(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_time_range", err.Error())
+		return
+	}
+	setTimezoneHeader(w, loc)
+	writeJSON(w, http.StatusOK, auditReconciliation.report(mux.Vars(r)["cluster"], window))
+}
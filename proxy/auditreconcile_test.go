@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeConnectorList serves GET /connectors?expand=info for a set of connector names it
+// lets the test change.
+type fakeConnectorList struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (c *fakeConnectorList) set(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names = names
+}
+
+func (c *fakeConnectorList) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	connectors := make(map[string]interface{}, len(c.names))
+	for _, name := range c.names {
+		connectors[name] = map[string]interface{}{
+			"info":   map[string]interface{}{"config": map[string]string{"connector.class": "FileStreamSink"}},
+			"status": map[string]interface{}{"name": name},
+		}
+	}
+	writeJSON(w, http.StatusOK, connectors)
+}
+
+func TestAuditReconcilerFlagsChangesOutsideTheProxy(t *testing.T) {
+	logger := withTestAuditLog(t, 100)
+	originalDir := dataDir
+	t.Cleanup(func() { dataDir = originalDir })
+	dataDir = t.TempDir()
+
+	connect := &fakeConnectorList{}
+	server := httptest.NewServer(connect)
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	reconciler := newAuditReconciler(func() time.Time { return now })
+	pass := func() {
+		t.Helper()
+		if err := reconciler.reconcile(context.Background(), "default"); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+	}
+	audit := func(action, connector string, httpStatus int, details map[string]interface{}) {
+		status := auditStatusSuccess
+		if httpStatus >= 400 {
+			status = auditStatusFailure
+		}
+		logger.Log(AuditLogEntry{Timestamp: now.Add(-time.Minute), Cluster: "default", Action: action, ConnectorName: connector, Status: status, HTTPStatus: httpStatus, Details: details})
+	}
+
+	connect.set("orders-sink", "legacy-sink", "stale-sink")
+	pass()
+	if report := reconciler.report("default", timeRange{}); len(report.Changes) != 0 || report.LastReconciledAt == nil {
+		t.Fatalf("expected the first pass to only take a baseline, got %+v", report)
+	}
+
+	// Between passes: billing-sink is created and stale-sink cleaned up through the
+	// proxy, debug-sink is created and legacy-sink deleted directly, and the create of
+	// late-sink is still in flight when the next pass runs.
+	now = now.Add(5 * time.Minute)
+	audit(auditActionCreate, "billing-sink", http.StatusCreated, nil)
+	audit(auditActionCreate, "debug-sink", http.StatusConflict, nil)
+	audit(auditActionCleanupStale, "", http.StatusOK, map[string]interface{}{"deleted": []interface{}{"stale-sink"}})
+	connect.set("orders-sink", "billing-sink", "debug-sink", "late-sink")
+	pass()
+	if report := reconciler.report("default", timeRange{}); len(report.Changes) != 0 {
+		t.Fatalf("expected unexplained changes to be held back for one pass, got %+v", report.Changes)
+	}
+
+	audit(auditActionCreate, "late-sink", http.StatusCreated, nil)
+	now = now.Add(5 * time.Minute)
+	pass()
+	changes := reconciler.report("default", timeRange{}).Changes
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.Connector+" "+change.Change)
+	}
+	if strings.Join(got, ",") != "legacy-sink disappeared,debug-sink appeared" {
+		t.Fatalf("expected debug-sink and legacy-sink to be flagged, got %v", got)
+	}
+	if debug := changes[1]; debug.ConnectorClass != "FileStreamSink" || !debug.Since.Equal(time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)) || !debug.DetectedAt.Equal(time.Date(2024, 5, 6, 9, 5, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected change %+v", debug)
+	}
+
+	pass()
+	if report := reconciler.report("default", timeRange{}); len(report.Changes) != 2 {
+		t.Fatalf("expected a pass without changes to flag nothing, got %+v", report.Changes)
+	}
+	if other := reconciler.report("dr", timeRange{}); len(other.Changes) != 0 || other.LastReconciledAt != nil {
+		t.Fatalf("expected nothing for another cluster, got %+v", other)
+	}
+
+	reloaded := newAuditReconciler(time.Now)
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if report := reloaded.report("default", timeRange{}); len(report.Changes) != 2 || report.Changes[0].ID != "2" {
+		t.Fatalf("expected the changes to be persisted, got %+v", report.Changes)
+	}
+}
+
+func TestUnexplainedChangesHandler(t *testing.T) {
+	original := auditReconciliation
+	t.Cleanup(func() { auditReconciliation = original })
+	auditReconciliation = newAuditReconciler(time.Now)
+	for i, detected := range []time.Time{time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)} {
+		auditReconciliation.changes = append(auditReconciliation.changes, UnexplainedChange{ID: fmt.Sprint(i + 1), Cluster: "default", Connector: "debug-sink", Change: connectorAppeared, DetectedAt: detected})
+	}
+
+	call := func(query string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/audit-logs/unexplained"+query, nil), map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		unexplainedChangesHandler(rr, req)
+		return rr
+	}
+	var report UnexplainedChangesReport
+	rr := call("?since=2024-05-02T00:00:00Z")
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || rr.Code != http.StatusOK || len(report.Changes) != 1 || report.Changes[0].ID != "2" {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call("?since=yesterday"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid time, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	auditBackendMemory = "memory"
+	auditBackendRedis  = "redis"
+
+	// auditRedisTimeout bounds writing or reading one batch of audit entries.
+	auditRedisTimeout = 5 * time.Second
+	auditRedisPage    = 500
+	// auditFollowBlock is how long a follower waits for new entries per XREAD.
+	auditFollowBlock = 5 * time.Second
+
+	// auditAppendScript numbers and appends an entry in one step, so the stream is
+	// always in ID order even when replicas log at the same time.
+	auditAppendScript = `local seq = redis.call('INCR', KEYS[1])
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[1], '*', 'id', seq, 'replica', ARGV[2], 'entry', ARGV[3])
+return seq`
+)
+
+var (
+	// auditLogBackend selects where audit entries are kept. With "redis" every replica
+	// writes to, and the audit page reads from, one Redis stream.
+	auditLogBackend = getEnv("AUDIT_LOG_BACKEND", auditBackendMemory)
+	// auditLogRedisURL defaults to CACHE_REDIS_URL so one Redis can serve both.
+	auditLogRedisURL = getEnv("AUDIT_LOG_REDIS_URL", "")
+	// auditLogImport is an NDJSON audit export loaded once into an empty shared log,
+	// to carry history over from the in-memory store.
+	auditLogImport = getEnv("AUDIT_LOG_IMPORT", "")
+)
+
+// loadAuditLogger builds the audit logger selected by AUDIT_LOG_BACKEND.
+func loadAuditLogger(maxEntries int) (AuditLogger, error) {
+	switch strings.ToLower(strings.TrimSpace(auditLogBackend)) {
+	case auditBackendMemory, "":
+		return newMemoryAuditLogger(maxEntries), nil
+	case auditBackendRedis:
+		setting, rawURL := "AUDIT_LOG_REDIS_URL", auditLogRedisURL
+		if strings.TrimSpace(rawURL) == "" {
+			setting, rawURL = "CACHE_REDIS_URL", cacheRedisURL
+		}
+		if strings.TrimSpace(rawURL) == "" {
+			return nil, fmt.Errorf("AUDIT_LOG_REDIS_URL or CACHE_REDIS_URL is required when AUDIT_LOG_BACKEND is redis")
+		}
+		client, err := newRedisClient(setting, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		return newRedisAuditLogger(client, cacheKeyPrefix, maxEntries), nil
+	default:
+		return nil, &configError{name: "AUDIT_LOG_BACKEND", value: auditLogBackend}
+	}
+}
+
+// redisAuditLogger keeps audit entries in a Redis stream shared by every replica. IDs
+// come from a Redis counter, so they stay increasing sequence numbers across replicas
+// and clients resuming the audit stream with Last-Event-ID keep working.
+type redisAuditLogger struct {
+	client     *redisClient
+	streamKey  string
+	seqKey     string
+	importKey  string
+	archiveKey string
+	maxEntries int
+	// replica tags entries with their origin so a follower does not republish its own.
+	replica string
+}
+
+func newRedisAuditLogger(client *redisClient, prefix string, maxEntries int) *redisAuditLogger {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &redisAuditLogger{
+		client:     client,
+		streamKey:  prefix + "audit:log",
+		seqKey:     prefix + "audit:seq",
+		importKey:  prefix + "audit:imported",
+		archiveKey: prefix + "audit:archive-lock",
+		maxEntries: maxEntries,
+		replica:    hex.EncodeToString(id),
+	}
+}
+
+// Log appends entry to the stream, trimming it to roughly maxEntries. A failed write is
+// logged; the readiness check reports the store as down until Redis is back.
+func (l *redisAuditLogger) Log(entry AuditLogEntry) AuditLogEntry {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Timestamp = entry.Timestamp.UTC()
+
+	ctx, cancel := context.WithTimeout(context.Background(), auditRedisTimeout)
+	defer cancel()
+	if err := l.append(ctx, &entry); err != nil {
+		log.Printf("audit: failed to write %s entry to Redis: %v", entry.Action, err)
+	}
+	return entry
+}
+
+// append stores entry without its ID; the ID is assigned by auditAppendScript and kept
+// in the record's id field.
+func (l *redisAuditLogger) append(ctx context.Context, entry *AuditLogEntry) error {
+	entry.ID = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	reply, err := l.client.do(ctx, "EVAL", auditAppendScript, "2", l.seqKey, l.streamKey,
+		strconv.Itoa(l.maxEntries), l.replica, string(data))
+	if err != nil {
+		return err
+	}
+	seq, ok := reply.(int64)
+	if !ok {
+		return fmt.Errorf("redis: unexpected EVAL reply %T", reply)
+	}
+	entry.ID = strconv.FormatInt(seq, 10)
+	return nil
+}
+
+// Query reads the stream newest first, a page at a time, until filter.Limit entries
+// matched or the stream is exhausted.
+func (l *redisAuditLogger) Query(filter AuditFilter) []AuditLogEntry {
+	ctx, cancel := context.WithTimeout(context.Background(), auditRedisTimeout)
+	defer cancel()
+
+	result := make([]AuditLogEntry, 0)
+	end := "+"
+	for {
+		reply, err := l.client.do(ctx, "XREVRANGE", l.streamKey, end, "-", "COUNT", strconv.Itoa(auditRedisPage))
+		if err != nil {
+			log.Printf("audit: failed to read entries from Redis: %v", err)
+			return result
+		}
+		records, _ := reply.([]interface{})
+		for _, record := range records {
+			id, entry, ok := parseAuditStreamRecord(record)
+			if id != "" {
+				end = "(" + id
+			}
+			if !ok || !filter.matches(entry) {
+				continue
+			}
+			result = append(result, entry)
+			if filter.Limit > 0 && len(result) >= filter.Limit {
+				return result
+			}
+		}
+		if len(records) < auditRedisPage {
+			return result
+		}
+	}
+}
+
+func (l *redisAuditLogger) Ping(ctx context.Context) error {
+	_, err := l.client.do(ctx, "PING")
+	return err
+}
+
+// follow publishes entries logged by other replicas to this replica's live audit
+// streams, until stop is closed.
+func (l *redisAuditLogger) follow(stop <-chan struct{}) {
+	// Start from the newest record rather than "$", which would skip whatever is logged
+	// between two reads.
+	var last string
+	for {
+		var err error
+		if last, err = l.latestStreamID(); err == nil {
+			break
+		}
+		log.Printf("audit: failed to follow the shared audit log: %v", err)
+		select {
+		case <-stop:
+			return
+		case <-time.After(auditFollowBlock):
+		}
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), auditFollowBlock+auditRedisTimeout)
+		reply, err := l.client.do(ctx, "XREAD", "COUNT", strconv.Itoa(auditRedisPage),
+			"BLOCK", strconv.FormatInt(auditFollowBlock.Milliseconds(), 10), "STREAMS", l.streamKey, last)
+		cancel()
+		if err != nil {
+			log.Printf("audit: failed to follow the shared audit log: %v", err)
+			select {
+			case <-stop:
+				return
+			case <-time.After(auditFollowBlock):
+			}
+			continue
+		}
+
+		// The reply is [[stream, [record, ...]]], or nil when the block timed out.
+		streams, _ := reply.([]interface{})
+		for _, stream := range streams {
+			pair, _ := stream.([]interface{})
+			if len(pair) != 2 {
+				continue
+			}
+			records, _ := pair[1].([]interface{})
+			for _, record := range records {
+				id, entry, ok := parseAuditStreamRecord(record)
+				if id != "" {
+					last = id
+				}
+				if ok && auditStreamRecordReplica(record) != l.replica {
+					auditStream.publish(entry)
+				}
+			}
+		}
+	}
+}
+
+// latestStreamID returns the ID of the newest record, or "0-0" for an empty stream.
+func (l *redisAuditLogger) latestStreamID() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), auditRedisTimeout)
+	defer cancel()
+	reply, err := l.client.do(ctx, "XREVRANGE", l.streamKey, "+", "-", "COUNT", "1")
+	if err != nil {
+		return "", err
+	}
+	if records, _ := reply.([]interface{}); len(records) == 1 {
+		if id, _, _ := parseAuditStreamRecord(records[0]); id != "" {
+			return id, nil
+		}
+	}
+	return "0-0", nil
+}
+
+// parseAuditStreamRecord decodes a stream record, [id, [field, value, ...]]. The stream
+// ID is returned even for records that do not hold an entry, so readers can move past them.
+func parseAuditStreamRecord(record interface{}) (string, AuditLogEntry, bool) {
+	fields := auditStreamFields(record)
+	var entry AuditLogEntry
+	if fields["entry"] == "" || json.Unmarshal([]byte(fields["entry"]), &entry) != nil {
+		return fields[""], AuditLogEntry{}, false
+	}
+	entry.ID = fields["id"]
+	return fields[""], entry, true
+}
+
+func auditStreamRecordReplica(record interface{}) string {
+	return auditStreamFields(record)["replica"]
+}
+
+// auditStreamFields returns the fields of a record, with its stream ID under "".
+func auditStreamFields(record interface{}) map[string]string {
+	parts, _ := record.([]interface{})
+	if len(parts) != 2 {
+		return nil
+	}
+	id, _ := parts[0].([]byte)
+	values, _ := parts[1].([]interface{})
+	fields := map[string]string{"": string(id)}
+	for i := 0; i+1 < len(values); i += 2 {
+		name, _ := values[i].([]byte)
+		value, _ := values[i+1].([]byte)
+		fields[string(name)] = string(value)
+	}
+	return fields
+}
+
+// claimImport reports whether this replica should import AUDIT_LOG_IMPORT. Only the
+// first replica to start claims it, so the history is loaded exactly once.
+func (l *redisAuditLogger) claimImport(ctx context.Context) (bool, error) {
+	reply, err := l.client.do(ctx, "SET", l.importKey, time.Now().UTC().Format(time.RFC3339), "NX")
+	return reply != nil, err
+}
+
+// importAuditLog loads the NDJSON export at path into logger, oldest first so the
+// entries keep their order. Imported entries keep their timestamps but get new IDs.
+func importAuditLog(logger AuditLogger, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var entries []AuditLogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry AuditLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return 0, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if shared, ok := logger.(*redisAuditLogger); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), auditRedisTimeout)
+		claimed, err := shared.claimImport(ctx)
+		cancel()
+		if err != nil || !claimed {
+			return 0, err
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	for _, entry := range entries {
+		if entry.Details == nil {
+			entry.Details = map[string]interface{}{}
+		}
+		entry.Details["importedId"] = entry.ID
+		logger.Log(entry)
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestRedisAuditLogger(t *testing.T, addr string) *redisAuditLogger {
+	t.Helper()
+	client, err := newRedisClient("AUDIT_LOG_REDIS_URL", "redis://"+addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRedisAuditLogger(client, "console:", 100)
+}
+
+func TestRedisAuditLoggerSharesEntries(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	first, second := newTestRedisAuditLogger(t, addr), newTestRedisAuditLogger(t, addr)
+
+	// Follow from the second replica before anything is logged.
+	entries, cancel := auditStream.subscribe()
+	defer cancel()
+	stop := make(chan struct{})
+	defer close(stop)
+	go second.follow(stop)
+	time.Sleep(50 * time.Millisecond)
+
+	a := first.Log(AuditLogEntry{Action: auditActionCreate, ConnectorName: "orders", Status: auditStatusSuccess})
+	b := second.Log(AuditLogEntry{Action: auditActionPause, ConnectorName: "orders", Status: auditStatusSuccess})
+	c := first.Log(AuditLogEntry{Action: auditActionDelete, ConnectorName: "billing", Status: auditStatusFailure})
+	if a.ID != "1" || b.ID != "2" || c.ID != "3" || a.Timestamp.IsZero() {
+		t.Fatalf("expected IDs shared across replicas, got %q %q %q", a.ID, b.ID, c.ID)
+	}
+
+	all := second.Query(AuditFilter{})
+	if len(all) != 3 || all[0].ID != "3" || all[2].ID != "1" || all[2].ConnectorName != "orders" {
+		t.Fatalf("expected every replica's entries newest first, got %+v", all)
+	}
+	if got := first.Query(AuditFilter{Connector: "orders", Limit: 1}); len(got) != 1 || got[0].ID != "2" {
+		t.Fatalf("expected the newest orders entry, got %+v", got)
+	}
+	if err := first.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	// The follower republishes what the first replica logged, not its own entry.
+	var published []string
+	timeout := time.After(2 * time.Second)
+	for len(published) < 2 {
+		select {
+		case entry := <-entries:
+			published = append(published, entry.ID)
+		case <-timeout:
+			t.Fatalf("expected entries 1 and 3 to be followed, got %v", published)
+		}
+	}
+	if published[0] != "1" || published[1] != "3" {
+		t.Fatalf("expected entries 1 and 3 to be followed, got %v", published)
+	}
+}
+
+func TestImportAuditLog(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	first, second := newTestRedisAuditLogger(t, addr), newTestRedisAuditLogger(t, addr)
+
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	export := `{"id":"8","timestamp":"2024-05-01T12:05:00Z","action":"DELETE","connectorName":"orders","status":"SUCCESS","httpStatus":204}
+{"id":"7","timestamp":"2024-05-01T12:00:00Z","action":"CREATE","connectorName":"orders","status":"SUCCESS","httpStatus":201}
+`
+	if err := os.WriteFile(path, []byte(export), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := importAuditLog(first, path); err != nil || n != 2 {
+		t.Fatalf("expected 2 imported entries, got %d, %v", n, err)
+	}
+	if n, err := importAuditLog(second, path); err != nil || n != 0 {
+		t.Fatalf("expected the second replica to skip the import, got %d, %v", n, err)
+	}
+
+	entries := second.Query(AuditFilter{})
+	if len(entries) != 2 || entries[0].Action != auditActionDelete || entries[1].Action != auditActionCreate {
+		t.Fatalf("expected the export in its original order, got %+v", entries)
+	}
+	if entries[1].ID != "1" || entries[1].Details["importedId"] != "7" || !entries[1].Timestamp.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected new IDs with the original timestamp, got %+v", entries[1])
+	}
+
+	os.WriteFile(path, []byte("not json\n"), 0o644)
+	if _, err := importAuditLog(newMemoryAuditLogger(10), path); err == nil {
+		t.Fatalf("expected a malformed export to be rejected")
+	}
+}
+
+func TestLoadAuditLogger(t *testing.T) {
+	originalBackend, originalURL, originalCacheURL := auditLogBackend, auditLogRedisURL, cacheRedisURL
+	t.Cleanup(func() {
+		auditLogBackend, auditLogRedisURL, cacheRedisURL = originalBackend, originalURL, originalCacheURL
+	})
+
+	auditLogBackend, auditLogRedisURL, cacheRedisURL = "redis", "", "redis://cache:6379/1"
+	logger, err := loadAuditLogger(100)
+	if err != nil {
+		t.Fatalf("loadAuditLogger: %v", err)
+	}
+	if shared := logger.(*redisAuditLogger); shared.client.addr != "cache:6379" || shared.client.db != 1 {
+		t.Fatalf("expected CACHE_REDIS_URL to be used, got %+v", shared.client)
+	}
+
+	for _, tc := range []struct{ backend, url, cacheURL string }{
+		{"redis", "", ""},
+		{"redis", "postgres://db/audit", ""},
+		{"postgres", "", ""},
+	} {
+		auditLogBackend, auditLogRedisURL, cacheRedisURL = tc.backend, tc.url, tc.cacheURL
+		if _, err := loadAuditLogger(100); err == nil {
+			t.Errorf("expected %s %q to be rejected", tc.backend, tc.url)
+		}
+	}
+}
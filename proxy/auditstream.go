@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditStreamBuffer is how many entries a subscriber may fall behind before it is
+// disconnected. Clients reconnect with Last-Event-ID and catch up from the audit log.
+const auditStreamBuffer = 64
+
+// auditStreamHeartbeat keeps idle streams open through proxies that time out silent
+// connections.
+var auditStreamHeartbeat = 15 * time.Second
+
+var auditStream = newAuditBroadcaster()
+
+// auditBroadcaster fans new audit entries out to live subscribers.
+type auditBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan AuditLogEntry]struct{}
+}
+
+func newAuditBroadcaster() *auditBroadcaster {
+	return &auditBroadcaster{subscribers: make(map[chan AuditLogEntry]struct{})}
+}
+
+// subscribe registers a subscriber. The returned channel is closed when cancel is called
+// or when the subscriber falls too far behind.
+func (b *auditBroadcaster) subscribe() (<-chan AuditLogEntry, func()) {
+	ch := make(chan AuditLogEntry, auditStreamBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *auditBroadcaster) publish(entry AuditLogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// auditEntryAfter reports whether entry was logged after the entry with ID last. Audit
+// IDs are sequence numbers, per process in memory and shared in Redis; other IDs never
+// compare as later.
+func auditEntryAfter(entry AuditLogEntry, last int64) bool {
+	id, err := strconv.ParseInt(entry.ID, 10, 64)
+	return err == nil && id > last
+}
+
+func writeAuditEvent(w http.ResponseWriter, entry AuditLogEntry) error {
+	data, err := json.Marshal(entry.withoutBodies())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: audit\ndata: %s\n\n", entry.ID, data)
+	return err
+}
+
+// auditLogStreamHandler streams new audit entries as server-sent events. It accepts the
+// connector, action and status filters of auditLogHandler; a Last-Event-ID header (or
+// lastEventId query parameter) first replays the entries logged since that ID.
+func auditLogStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming_unsupported", "the response writer does not support streaming")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := AuditFilter{
+		Connector:  query.Get("connector"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("targetType"),
+		Status:     query.Get("status"),
+	}
+
+	lastEventID := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if lastEventID == "" {
+		lastEventID = query.Get("lastEventId")
+	}
+	var last int64 = -1
+	if lastEventID != "" {
+		n, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_last_event_id", "Last-Event-ID must be an audit entry ID")
+			return
+		}
+		last = n
+	}
+
+	// Subscribe before replaying so nothing logged in between is missed.
+	entries, cancel := auditStream.subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if last >= 0 {
+		backlog := auditLog.Query(filter)
+		for i := len(backlog) - 1; i >= 0; i-- {
+			if !auditEntryAfter(backlog[i], last) {
+				continue
+			}
+			if err := writeAuditEvent(w, backlog[i]); err != nil {
+				return
+			}
+			last, _ = strconv.ParseInt(backlog[i].ID, 10, 64)
+		}
+	}
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(auditStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry, ok := <-entries:
+			if !ok {
+				return
+			}
+			if !filter.matches(entry) || (last >= 0 && !auditEntryAfter(entry, last)) {
+				continue
+			}
+			if err := writeAuditEvent(w, entry); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
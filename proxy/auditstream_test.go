@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestAuditStream(t *testing.T) *auditBroadcaster {
+	t.Helper()
+	original := auditStream
+	stream := newAuditBroadcaster()
+	auditStream = stream
+	t.Cleanup(func() { auditStream = original })
+	return stream
+}
+
+// openAuditStream connects to the stream handler and returns a reader positioned after
+// the ": connected" comment, collecting the events replayed before it.
+func openAuditStream(t *testing.T, query string, header http.Header) (replayed []AuditLogEntry, next func() AuditLogEntry) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auditLogStreamHandler(w, mux.SetURLVars(r, map[string]string{"cluster": "default"}))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/default/audit-logs/stream"+query, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() (AuditLogEntry, bool) {
+		var entry AuditLogEntry
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read stream: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == ": connected":
+				return entry, false
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &entry); err != nil {
+					t.Fatalf("decode event: %v", err)
+				}
+			case line == "" && entry.ID != "":
+				return entry, true
+			}
+		}
+	}
+
+	for {
+		entry, ok := readEvent()
+		if !ok {
+			break
+		}
+		replayed = append(replayed, entry)
+	}
+	return replayed, func() AuditLogEntry {
+		entry, _ := readEvent()
+		return entry
+	}
+}
+
+func TestAuditLogStreamPushesNewEntries(t *testing.T) {
+	withTestAuditLog(t, 10)
+	withTestAuditStream(t)
+
+	replayed, next := openAuditStream(t, "?connector=alpha", nil)
+	if len(replayed) != 0 {
+		t.Fatalf("expected no replay without Last-Event-ID, got %d", len(replayed))
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/api/default/connectors/beta/pause", nil), map[string]string{"cluster": "default"})
+	recordAudit(req, auditActionPause, "beta", http.StatusAccepted, nil)
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/api/default/connectors/alpha/pause", nil), map[string]string{"cluster": "default"})
+	recordAudit(req, auditActionPause, "alpha", http.StatusAccepted, nil)
+
+	entry := next()
+	if entry.ConnectorName != "alpha" || entry.Action != auditActionPause || entry.ID != "2" {
+		t.Fatalf("unexpected streamed entry: %+v", entry)
+	}
+}
+
+func TestAuditLogStreamReplaysFromLastEventID(t *testing.T) {
+	logger := withTestAuditLog(t, 10)
+	withTestAuditStream(t)
+	logger.Log(AuditLogEntry{Action: auditActionPause, ConnectorName: "alpha"})
+	logger.Log(AuditLogEntry{Action: auditActionResume, ConnectorName: "alpha"})
+	logger.Log(AuditLogEntry{Action: auditActionDelete, ConnectorName: "alpha"})
+
+	replayed, _ := openAuditStream(t, "", http.Header{"Last-Event-Id": []string{"1"}})
+	if len(replayed) != 2 || replayed[0].ID != "2" || replayed[1].ID != "3" {
+		t.Fatalf("expected entries 2 and 3 in order, got %+v", replayed)
+	}
+}
+
+func TestAuditBroadcasterDropsSlowSubscribers(t *testing.T) {
+	stream := newAuditBroadcaster()
+	entries, cancel := stream.subscribe()
+	defer cancel()
+
+	for i := 0; i <= auditStreamBuffer; i++ {
+		stream.publish(AuditLogEntry{ID: "x"})
+	}
+
+	deadline := time.After(time.Second)
+	for received := 0; ; received++ {
+		select {
+		case _, ok := <-entries:
+			if !ok {
+				if received != auditStreamBuffer {
+					t.Fatalf("expected %d buffered entries before close, got %d", auditStreamBuffer, received)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatalf("expected slow subscriber to be disconnected")
+		}
+	}
+}
+
+func TestAuditLogStreamRejectsInvalidLastEventID(t *testing.T) {
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/audit-logs/stream?lastEventId=abc", nil), map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	auditLogStreamHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
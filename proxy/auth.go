@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName   = "kconnect_session"
+	oidcStateCookieName = "kconnect_oidc"
+	oidcLoginTimeout    = 10 * time.Minute
+
+	// Purposes of the signed cookie values; each is signed with its own key.
+	signedSession   = "session"
+	signedOIDCLogin = "oidc-login"
+
+	minSessionSecretLen = 32
+)
+
+var (
+	oidcIssuerURL     = getEnv("OIDC_ISSUER_URL", "")
+	oidcClientID      = getEnv("OIDC_CLIENT_ID", "")
+	oidcClientSecret  = getEnv("OIDC_CLIENT_SECRET", "")
+	oidcRedirectURL   = getEnv("OIDC_REDIRECT_URL", "")
+	oidcScopes        = getEnv("OIDC_SCOPES", "openid profile email")
+	oidcUsernameClaim = getEnv("OIDC_USERNAME_CLAIM", "preferred_username")
+	oidcPostLoginURL  = getEnv("OIDC_POST_LOGIN_URL", "/")
+	sessionSecret     = getEnv("SESSION_SECRET", "")
+	sessionTTL        = getEnv("SESSION_TTL", "8h")
+
+	// oidcAuth is nil unless OIDC_ISSUER_URL is set.
+	oidcAuth *oidcAuthenticator
+)
+
+// AuthUser is the profile of a signed-in user, kept in the session cookie and returned
+// by GET /auth/me.
+type AuthUser struct {
+	Username  string    `json:"username"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Groups    []string  `json:"groups,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// oidcLogin is kept in a short-lived cookie between /auth/login and /auth/callback.
+type oidcLogin struct {
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"`
+	Redirect  string    `json:"redirect"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// oidcAuthenticator runs the authorization code flow (with PKCE) against an OpenID
+// Connect provider and keeps the signed-in user in an HMAC-signed session cookie.
+type oidcAuthenticator struct {
+	issuer        string
+	clientID      string
+	clientSecret  string
+	redirectURL   string
+	scopes        []string
+	usernameClaim string
+	postLoginURL  string
+	secret        []byte
+	ttl           time.Duration
+	client        *http.Client
+	now           func() time.Time
+
+	mu       sync.Mutex
+	provider *oidcProvider
+}
+
+// loadOIDCConfig reads the OIDC_* and SESSION_* settings. It returns nil when
+// OIDC_ISSUER_URL is unset, leaving authentication to a fronting proxy.
+func loadOIDCConfig() (*oidcAuthenticator, error) {
+	issuer := strings.TrimSpace(oidcIssuerURL)
+	if issuer == "" {
+		return nil, nil
+	}
+	if _, err := url.ParseRequestURI(issuer); err != nil {
+		return nil, &configError{name: "OIDC_ISSUER_URL", value: oidcIssuerURL}
+	}
+	if strings.TrimSpace(oidcClientID) == "" {
+		return nil, &configError{name: "OIDC_CLIENT_ID", value: oidcClientID}
+	}
+	redirect, err := url.Parse(strings.TrimSpace(oidcRedirectURL))
+	if err != nil || !redirect.IsAbs() {
+		return nil, &configError{name: "OIDC_REDIRECT_URL", value: oidcRedirectURL}
+	}
+	if len(sessionSecret) < minSessionSecretLen {
+		return nil, fmt.Errorf("SESSION_SECRET must be at least %d characters when OIDC is enabled", minSessionSecretLen)
+	}
+	ttl, err := parseWindow(sessionTTL, 8*time.Hour)
+	if err != nil {
+		return nil, &configError{name: "SESSION_TTL", value: sessionTTL}
+	}
+
+	return &oidcAuthenticator{
+		issuer:        strings.TrimSuffix(issuer, "/"),
+		clientID:      strings.TrimSpace(oidcClientID),
+		clientSecret:  oidcClientSecret,
+		redirectURL:   redirect.String(),
+		scopes:        strings.Fields(oidcScopes),
+		usernameClaim: strings.TrimSpace(oidcUsernameClaim),
+		postLoginURL:  oidcPostLoginURL,
+		secret:        []byte(sessionSecret),
+		ttl:           ttl,
+		client:        newUpstreamClient(10 * time.Second),
+		now:           time.Now,
+	}, nil
+}
+
+// discover fetches the provider metadata once; failures are retried on the next login.
+func (a *oidcAuthenticator) discover(ctx context.Context) (*oidcProvider, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.provider != nil {
+		return a.provider, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch OIDC discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch OIDC discovery document: HTTP %d", resp.StatusCode)
+	}
+
+	var provider oidcProvider
+	if err := json.NewDecoder(resp.Body).Decode(&provider); err != nil {
+		return nil, fmt.Errorf("decode OIDC discovery document: %w", err)
+	}
+	if strings.TrimSuffix(provider.Issuer, "/") != a.issuer {
+		return nil, fmt.Errorf("OIDC discovery document is for issuer %q, expected %q", provider.Issuer, a.issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" {
+		return nil, errors.New("OIDC discovery document lacks the authorization or token endpoint")
+	}
+	a.provider = &provider
+	return a.provider, nil
+}
+
+// sign encodes v as base64url JSON followed by its HMAC-SHA256 for purpose.
+func (a *oidcAuthenticator) sign(purpose string, v interface{}) (string, error) {
+	return signValue(a.secret, purpose, v)
+}
+
+// verify checks the signature of a value produced by sign for the same purpose and
+// decodes it into v.
+func (a *oidcAuthenticator) verify(purpose, value string, v interface{}) error {
+	return verifyValue(a.secret, purpose, value, v)
+}
+
+// signedValueMAC returns the HMAC-SHA256 of an encoded payload under a key derived
+// from secret for purpose, so a value signed for one cookie is rejected by the others.
+func signedValueMAC(secret []byte, purpose, encoded string) string {
+	key := hmac.New(sha256.New, secret)
+	key.Write([]byte(purpose))
+	mac := hmac.New(sha256.New, key.Sum(nil))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signValue encodes v as base64url JSON followed by its HMAC-SHA256 for purpose.
+func signValue(secret []byte, purpose string, v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signedValueMAC(secret, purpose, encoded), nil
+}
+
+// verifyValue checks the signature of a value produced by signValue for the same
+// purpose and decodes it into v.
+func verifyValue(secret []byte, purpose, value string, v interface{}) error {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return errors.New("malformed signed value")
+	}
+	expected := signedValueMAC(secret, purpose, encoded)
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+		return errors.New("invalid signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
+func (a *oidcAuthenticator) secureCookies() bool {
+	return strings.HasPrefix(a.redirectURL, "https://")
+}
+
+func (a *oidcAuthenticator) setCookie(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(expires.Sub(a.now()).Seconds()),
+		HttpOnly: true,
+		Secure:   a.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (a *oidcAuthenticator) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: a.secureCookies(), SameSite: http.SameSiteLaxMode})
+}
+
+// session returns the user of a valid, unexpired session cookie.
+func (a *oidcAuthenticator) session(r *http.Request) (AuthUser, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return AuthUser{}, false
+	}
+	var user AuthUser
+	if err := a.verify(signedSession, cookie.Value, &user); err != nil || !a.now().Before(user.ExpiresAt) || user.Username == "" || user.Subject == "" {
+		return AuthUser{}, false
+	}
+	return user, true
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// safeRedirect only accepts paths on this host, so the login flow cannot be used as an
+// open redirect.
+func safeRedirect(target, fallback string) string {
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") && !strings.HasPrefix(target, "/\\") {
+		return target
+	}
+	return fallback
+}
+
+// idTokenClaims are the ID token claims the console uses.
+type idTokenClaims map[string]interface{}
+
+func (c idTokenClaims) str(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// parseIDToken decodes the claims of an ID token received from the token endpoint and
+// checks its issuer, audience, expiry and nonce. The signature is not checked: the
+// token comes straight from the provider over TLS, which OpenID Connect Core 1.0
+// (section 3.1.3.7) accepts in place of signature validation for the code flow.
+func (a *oidcAuthenticator) parseIDToken(token, nonce string) (idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decode ID token: %w", err)
+	}
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("decode ID token: %w", err)
+	}
+
+	if strings.TrimSuffix(claims.str("iss"), "/") != a.issuer {
+		return nil, fmt.Errorf("ID token issued by %q", claims.str("iss"))
+	}
+	audienceOK := claims.str("aud") == a.clientID
+	if audiences, ok := claims["aud"].([]interface{}); ok {
+		for _, audience := range audiences {
+			if audience == a.clientID {
+				audienceOK = true
+			}
+		}
+	}
+	if !audienceOK {
+		return nil, errors.New("ID token was not issued for this client")
+	}
+	exp, _ := claims["exp"].(float64)
+	if !a.now().Before(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("ID token has expired")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.str("nonce")), []byte(nonce)) != 1 {
+		return nil, errors.New("ID token nonce does not match")
+	}
+	if claims.str("sub") == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	return claims, nil
+}
+
+// exchangeCode trades an authorization code for the ID token.
+func (a *oidcAuthenticator) exchangeCode(ctx context.Context, provider *oidcProvider, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {a.redirectURL},
+		"client_id":     {a.clientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("token request: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return tokens.IDToken, nil
+}
+
+// userFromClaims builds the session profile. The username comes from
+// OIDC_USERNAME_CLAIM, falling back to the email address and then the subject.
+func (a *oidcAuthenticator) userFromClaims(claims idTokenClaims) AuthUser {
+	user := AuthUser{
+		Subject:   claims.str("sub"),
+		Email:     claims.str("email"),
+		Name:      claims.str("name"),
+		ExpiresAt: a.now().Add(a.ttl).UTC(),
+	}
+	for _, candidate := range []string{claims.str(a.usernameClaim), user.Email, user.Subject} {
+		if candidate != "" {
+			user.Username = candidate
+			break
+		}
+	}
+	if groups, ok := claims["groups"].([]interface{}); ok {
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				user.Groups = append(user.Groups, name)
+			}
+		}
+	}
+	return user
+}
+
+type authUserKey struct{}
+
+// authenticatedUser returns the user attached to the request by authMiddleware.
+func authenticatedUser(r *http.Request) (AuthUser, bool) {
+	user, ok := r.Context().Value(authUserKey{}).(AuthUser)
+	return user, ok
+}
+
+// authPublic reports whether a path stays reachable without a session: probes, the
+// login flow, the API description and the deploy webhook, which CI authenticates by
+// signing the body.
+func authPublic(path string) bool {
+	if segments := clusterPathSegments(path); len(segments) == 1 && segments[0] == "deploy" {
+		return true
+	}
+	return path == "/health" || strings.HasPrefix(path, "/health/") || strings.HasPrefix(path, "/auth/") ||
+		path == "/api/openapi.json" || path == "/api/docs"
+}
+
+// loginEnabled reports whether the console has its own login, OIDC or local users.
+func loginEnabled() bool {
+	return oidcAuth != nil || localAuth != nil
+}
+
+// sessionUser returns the user of the request's session under the enabled login.
+func sessionUser(r *http.Request) (AuthUser, bool) {
+	if auth := oidcAuth; auth != nil {
+		return auth.session(r)
+	}
+	if local := localAuth; local != nil {
+		return local.session(r)
+	}
+	return AuthUser{}, false
+}
+
+func writeUnauthenticated(w http.ResponseWriter) {
+	message := "sign in at /auth/login"
+	if oidcAuth == nil {
+		message = "sign in with POST /auth/local/login"
+	}
+	writeJSONError(w, http.StatusUnauthorized, "unauthenticated", message)
+}
+
+// authMiddleware requires a session for the API when OIDC or local users are enabled
+// and attaches the signed-in user to the request, so audit entries and metadata changes
+// name them.
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !loginEnabled() || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, ok := sessionUser(r)
+		if !ok {
+			if authPublic(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			writeUnauthenticated(w)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authUserKey{}, user)))
+	})
+}
+
+func writeAuthDisabled(w http.ResponseWriter) {
+	writeJSONError(w, http.StatusNotFound, "auth_disabled", "OIDC login is not configured (set OIDC_ISSUER_URL)")
+}
+
+// authLoginHandler redirects to the provider's login page. ?redirect= names the console
+// page to return to afterwards.
+func authLoginHandler(w http.ResponseWriter, r *http.Request) {
+	auth := oidcAuth
+	if auth == nil {
+		writeAuthDisabled(w)
+		return
+	}
+	provider, err := auth.discover(r.Context())
+	if err != nil {
+		log.Printf("auth: %v", err)
+		writeJSONError(w, http.StatusBadGateway, "oidc_unavailable", "the identity provider could not be reached")
+		return
+	}
+
+	login := oidcLogin{Redirect: safeRedirect(r.URL.Query().Get("redirect"), auth.postLoginURL), ExpiresAt: auth.now().Add(oidcLoginTimeout)}
+	for _, token := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		if *token, err = randomToken(); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "login_failed", "failed to start the login")
+			return
+		}
+	}
+	signed, err := auth.sign(signedOIDCLogin, login)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "login_failed", "failed to start the login")
+		return
+	}
+	auth.setCookie(w, oidcStateCookieName, signed, login.ExpiresAt)
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	target, err := url.Parse(provider.AuthorizationEndpoint)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, "oidc_unavailable", "invalid authorization endpoint")
+		return
+	}
+	query := target.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", auth.clientID)
+	query.Set("redirect_uri", auth.redirectURL)
+	query.Set("scope", strings.Join(auth.scopes, " "))
+	query.Set("state", login.State)
+	query.Set("nonce", login.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// authCallbackHandler completes the login: it checks the state, exchanges the code for
+// an ID token and sets the session cookie.
+func authCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	auth := oidcAuth
+	if auth == nil {
+		writeAuthDisabled(w)
+		return
+	}
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		message := providerError
+		if description := query.Get("error_description"); description != "" {
+			message += ": " + description
+		}
+		writeJSONError(w, http.StatusUnauthorized, "login_failed", message)
+		return
+	}
+
+	var login oidcLogin
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil || auth.verify(signedOIDCLogin, cookie.Value, &login) != nil || !auth.now().Before(login.ExpiresAt) ||
+		subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(login.State)) != 1 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_state", "the login expired or did not start here; sign in again")
+		return
+	}
+	auth.clearCookie(w, oidcStateCookieName)
+
+	provider, err := auth.discover(r.Context())
+	if err != nil {
+		log.Printf("auth: %v", err)
+		writeJSONError(w, http.StatusBadGateway, "oidc_unavailable", "the identity provider could not be reached")
+		return
+	}
+	idToken, err := auth.exchangeCode(r.Context(), provider, query.Get("code"), login.Verifier)
+	if err != nil {
+		log.Printf("auth: %v", err)
+		writeJSONError(w, http.StatusBadGateway, "token_exchange_failed", "the identity provider rejected the login")
+		return
+	}
+	claims, err := auth.parseIDToken(idToken, login.Nonce)
+	if err != nil {
+		log.Printf("auth: %v", err)
+		writeJSONError(w, http.StatusUnauthorized, "login_failed", err.Error())
+		return
+	}
+
+	user := auth.userFromClaims(claims)
+	session, err := auth.sign(signedSession, user)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "login_failed", "failed to create the session")
+		return
+	}
+	auth.setCookie(w, sessionCookieName, session, user.ExpiresAt)
+	log.Printf("auth: %s signed in", user.Username)
+	http.Redirect(w, r, login.Redirect, http.StatusFound)
+}
+
+// authMeHandler returns the signed-in user.
+func authMeHandler(w http.ResponseWriter, r *http.Request) {
+	if !loginEnabled() {
+		writeAuthDisabled(w)
+		return
+	}
+	user, ok := sessionUser(r)
+	if !ok {
+		writeUnauthenticated(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// authLogoutHandler ends the console session. With OIDC the provider's own session is
+// kept.
+func authLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case oidcAuth != nil:
+		oidcAuth.clearCookie(w, sessionCookieName)
+	case localAuth != nil:
+		localAuth.clearCookie(w, r)
+	default:
+		writeAuthDisabled(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
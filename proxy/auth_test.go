@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withTestOIDC enables OIDC against a fake provider that signs in "alice" with the nonce
+// of the last authorization request.
+func withTestOIDC(t *testing.T) *oidcAuthenticator {
+	t.Helper()
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			writeJSON(w, http.StatusOK, map[string]string{
+				"issuer":                 provider.URL,
+				"authorization_endpoint": provider.URL + "/authorize",
+				"token_endpoint":         provider.URL + "/token",
+			})
+		case "/token":
+			r.ParseForm()
+			if user, pass, _ := r.BasicAuth(); user != "console" || pass != "s3cret" || r.Form.Get("code") != "good-code" || r.Form.Get("code_verifier") == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+				return
+			}
+			claims, _ := json.Marshal(map[string]interface{}{
+				"iss": provider.URL, "aud": []string{"console"}, "sub": "u-1", "exp": time.Now().Add(time.Hour).Unix(),
+				"nonce": r.Form.Get("code"), "preferred_username": "alice", "email": "alice@example.com", "groups": []string{"platform"},
+			})
+			token := "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+			writeJSON(w, http.StatusOK, map[string]string{"id_token": token, "access_token": "at"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(provider.Close)
+
+	original := oidcAuth
+	t.Cleanup(func() { oidcAuth = original })
+	oidcAuth = &oidcAuthenticator{
+		issuer:        provider.URL,
+		clientID:      "console",
+		clientSecret:  "s3cret",
+		redirectURL:   "https://console.example.com/auth/callback",
+		scopes:        []string{"openid", "profile"},
+		usernameClaim: "preferred_username",
+		postLoginURL:  "/",
+		secret:        []byte(strings.Repeat("k", minSessionSecretLen)),
+		ttl:           time.Hour,
+		client:        provider.Client(),
+		now:           time.Now,
+	}
+	return oidcAuth
+}
+
+func cookieNamed(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	auth := withTestOIDC(t)
+
+	rr := httptest.NewRecorder()
+	authLoginHandler(rr, httptest.NewRequest(http.MethodGet, "/auth/login?redirect=/connectors/orders", nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected a redirect to the provider, got %d: %s", rr.Code, rr.Body.String())
+	}
+	location, _ := url.Parse(rr.Header().Get("Location"))
+	query := location.Query()
+	if location.Path != "/authorize" || query.Get("client_id") != "console" || query.Get("code_challenge_method") != "S256" || query.Get("scope") != "openid profile" {
+		t.Fatalf("unexpected authorization request %s", location)
+	}
+	stateCookie := cookieNamed(rr.Result().Cookies(), oidcStateCookieName)
+	if stateCookie == nil || !stateCookie.HttpOnly || !stateCookie.Secure {
+		t.Fatalf("expected a secure state cookie, got %+v", stateCookie)
+	}
+
+	// The fake provider echoes the code as the nonce, so the callback uses the nonce.
+	var login oidcLogin
+	if err := auth.verify(signedOIDCLogin, stateCookie.Value, &login); err != nil {
+		t.Fatalf("verify state cookie: %v", err)
+	}
+	callback := func(state, code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/callback?state="+url.QueryEscape(state)+"&code="+url.QueryEscape(code), nil)
+		req.AddCookie(stateCookie)
+		rr := httptest.NewRecorder()
+		authCallbackHandler(rr, req)
+		return rr
+	}
+	if rr := callback("forged", "good-code"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a forged state to be rejected, got %d", rr.Code)
+	}
+	if rr := callback(login.State, "bad-code"); rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected a rejected code to fail, got %d", rr.Code)
+	}
+	if rr := callback(login.State, "good-code"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a nonce mismatch to fail, got %d", rr.Code)
+	}
+
+	login.Nonce = "good-code"
+	signed, _ := auth.sign(signedOIDCLogin, login)
+	stateCookie.Value = signed
+	rr = callback(login.State, "good-code")
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/connectors/orders" {
+		t.Fatalf("expected a redirect back to the console, got %d %v: %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+	session := cookieNamed(rr.Result().Cookies(), sessionCookieName)
+	if session == nil {
+		t.Fatalf("expected a session cookie")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	authMeHandler(rr, req)
+	var user AuthUser
+	if err := json.Unmarshal(rr.Body.Bytes(), &user); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected the profile, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if user.Username != "alice" || user.Email != "alice@example.com" || len(user.Groups) != 1 || user.Subject != "u-1" {
+		t.Fatalf("unexpected profile %+v", user)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	auth := withTestOIDC(t)
+	logger := withTestAuditLog(t, 10)
+
+	handler := authMiddleware(auditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, requestUser(r))
+	})))
+	do := func(path string, session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("X-Forwarded-User", "mallory")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("/api/default/connectors/orders/restart", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a session, got %d", rr.Code)
+	}
+	if rr := do("/health/live", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected probes to stay public, got %d", rr.Code)
+	}
+
+	session, _ := auth.sign(signedSession, AuthUser{Username: "alice", Subject: "u-1", ExpiresAt: time.Now().Add(time.Hour)})
+	rr := do("/api/default/connectors/orders/restart", session)
+	if rr.Code != http.StatusOK || rr.Body.String() != "alice" {
+		t.Fatalf("expected the session user, got %d %q", rr.Code, rr.Body.String())
+	}
+	if entries := logger.Query(AuditFilter{}); len(entries) != 1 || entries[0].User != "alice" {
+		t.Fatalf("expected the audit entry to name the session user, got %+v", entries)
+	}
+
+	expired, _ := auth.sign(signedSession, AuthUser{Username: "alice", Subject: "u-1", ExpiresAt: time.Now().Add(-time.Minute)})
+	tampered := strings.Replace(session, session[:4], "eyJB", 1)
+	anonymous, _ := auth.sign(signedSession, AuthUser{ExpiresAt: time.Now().Add(time.Hour)})
+
+	// The login state cookie is signed with the same secret and decodes into an AuthUser
+	// with an expiry; it must not pass as a session.
+	login := httptest.NewRecorder()
+	authLoginHandler(login, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	state := cookieNamed(login.Result().Cookies(), oidcStateCookieName)
+	if state == nil {
+		t.Fatal("expected a login state cookie")
+	}
+	for name, value := range map[string]string{"expired": expired, "tampered": tampered, "anonymous": anonymous, "replayed login state": state.Value} {
+		if rr := do("/api/default/connectors", value); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d", name, rr.Code)
+		}
+	}
+}
+
+func TestLoadOIDCConfig(t *testing.T) {
+	restore := func(vars ...*string) {
+		originals := make([]string, len(vars))
+		for i, v := range vars {
+			originals[i] = *v
+		}
+		t.Cleanup(func() {
+			for i, v := range vars {
+				*v = originals[i]
+			}
+		})
+	}
+	restore(&oidcIssuerURL, &oidcClientID, &oidcRedirectURL, &sessionSecret)
+
+	oidcIssuerURL = ""
+	if auth, err := loadOIDCConfig(); auth != nil || err != nil {
+		t.Fatalf("expected OIDC to be disabled without an issuer, got %v, %v", auth, err)
+	}
+
+	oidcIssuerURL, oidcClientID, oidcRedirectURL = "https://idp.example.com/", "console", "https://console.example.com/auth/callback"
+	sessionSecret = "short"
+	if _, err := loadOIDCConfig(); err == nil {
+		t.Fatalf("expected a short SESSION_SECRET to be rejected")
+	}
+	sessionSecret = strings.Repeat("k", minSessionSecretLen)
+	auth, err := loadOIDCConfig()
+	if err != nil || auth.issuer != "https://idp.example.com" {
+		t.Fatalf("unexpected config %+v, %v", auth, err)
+	}
+
+	if safeRedirect("//evil.example.com", "/") != "/" || safeRedirect("https://evil.example.com", "/") != "/" || safeRedirect("/topology", "/") != "/topology" {
+		t.Fatalf("expected only local redirects to be accepted")
+	}
+}
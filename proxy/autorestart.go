@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// autoRestartConfigKey opts a connector into automatic restarts from its own config.
+	autoRestartConfigKey = "console.autorestart"
+
+	// autoHealerUser is the audit log user of automatic restarts.
+	autoHealerUser = "auto-healer"
+)
+
+var (
+	// The auto-healer restarts FAILED connectors that opted in, either with
+	// console.autorestart=true in their config or through the metadata API. It runs on
+	// the monitoring poll and is off unless AUTO_RESTART_ENABLED is true.
+	autoRestartEnabled        = getEnv("AUTO_RESTART_ENABLED", "false")
+	autoRestartMaxAttempts    = getEnv("AUTO_RESTART_MAX_ATTEMPTS", "5")
+	autoRestartInitialBackoff = getEnv("AUTO_RESTART_INITIAL_BACKOFF", "30s")
+	autoRestartMaxBackoff     = getEnv("AUTO_RESTART_MAX_BACKOFF", "10m")
+
+	connectorAutoHealer = newAutoHealer(autoRestartSettings{}, time.Now)
+)
+
+// AutoRestartPolicy is a per-connector override of the auto-restart settings. Unset
+// fields fall back to the connector config opt-in and the AUTO_RESTART_* defaults.
+type AutoRestartPolicy struct {
+	Enabled        *bool   `json:"enabled,omitempty"`
+	MaxAttempts    *int    `json:"maxAttempts,omitempty"`
+	InitialBackoff *string `json:"initialBackoff,omitempty"`
+	MaxBackoff     *string `json:"maxBackoff,omitempty"`
+}
+
+func (p *AutoRestartPolicy) empty() bool {
+	return p == nil || (p.Enabled == nil && p.MaxAttempts == nil && p.InitialBackoff == nil && p.MaxBackoff == nil)
+}
+
+func (p *AutoRestartPolicy) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxAttempts != nil && *p.MaxAttempts < 0 {
+		return fmt.Errorf("autoRestart.maxAttempts must not be negative")
+	}
+	if p.InitialBackoff != nil {
+		if _, err := parseWindow(*p.InitialBackoff, 0); err != nil {
+			return fmt.Errorf("autoRestart.initialBackoff: %v", err)
+		}
+	}
+	if p.MaxBackoff != nil {
+		if _, err := parseWindow(*p.MaxBackoff, 0); err != nil {
+			return fmt.Errorf("autoRestart.maxBackoff: %v", err)
+		}
+	}
+	return nil
+}
+
+// autoRestartSettings are the effective settings for one connector.
+type autoRestartSettings struct {
+	Enabled        bool
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// loadAutoRestartDefaults parses the AUTO_RESTART_* environment variables. enabled
+// reports whether the auto-healer should run at all.
+func loadAutoRestartDefaults() (defaults autoRestartSettings, enabled bool, err error) {
+	if enabled, err = strconv.ParseBool(autoRestartEnabled); err != nil {
+		return defaults, false, &configError{name: "AUTO_RESTART_ENABLED", value: autoRestartEnabled}
+	}
+	if defaults.MaxAttempts, err = strconv.Atoi(autoRestartMaxAttempts); err != nil || defaults.MaxAttempts < 0 {
+		return defaults, false, &configError{name: "AUTO_RESTART_MAX_ATTEMPTS", value: autoRestartMaxAttempts}
+	}
+	if defaults.InitialBackoff, err = parseWindow(autoRestartInitialBackoff, 30*time.Second); err != nil {
+		return defaults, false, &configError{name: "AUTO_RESTART_INITIAL_BACKOFF", value: autoRestartInitialBackoff}
+	}
+	if defaults.MaxBackoff, err = parseWindow(autoRestartMaxBackoff, 10*time.Minute); err != nil || defaults.MaxBackoff < defaults.InitialBackoff {
+		return defaults, false, &configError{name: "AUTO_RESTART_MAX_BACKOFF", value: autoRestartMaxBackoff}
+	}
+	return defaults, enabled, nil
+}
+
+// apply overlays the connector's overrides on s. Policies are validated when stored.
+func (s autoRestartSettings) apply(policy *AutoRestartPolicy) autoRestartSettings {
+	if policy == nil {
+		return s
+	}
+	if policy.Enabled != nil {
+		s.Enabled = *policy.Enabled
+	}
+	if policy.MaxAttempts != nil {
+		s.MaxAttempts = *policy.MaxAttempts
+	}
+	if policy.InitialBackoff != nil {
+		if d, err := parseWindow(*policy.InitialBackoff, s.InitialBackoff); err == nil {
+			s.InitialBackoff = d
+		}
+	}
+	if policy.MaxBackoff != nil {
+		if d, err := parseWindow(*policy.MaxBackoff, s.MaxBackoff); err == nil {
+			s.MaxBackoff = d
+		}
+	}
+	return s
+}
+
+// backoff returns the delay after the given number of attempts: InitialBackoff doubled
+// per attempt, capped at MaxBackoff.
+func (s autoRestartSettings) backoff(attempts int) time.Duration {
+	delay := s.InitialBackoff
+	for i := 1; i < attempts && delay < s.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.MaxBackoff {
+		delay = s.MaxBackoff
+	}
+	return delay
+}
+
+// restartState tracks the automatic restarts of one failure episode. It is dropped as
+// soon as the connector is healthy again.
+type restartState struct {
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"lastAttemptAt,omitempty"`
+	NextAttempt time.Time `json:"nextAttemptAt,omitempty"`
+	Exhausted   bool      `json:"exhausted"`
+	LastError   string    `json:"lastError,omitempty"`
+
+	configOptIn *bool // console.autorestart, read once per episode
+}
+
+// autoHealer restarts failed connectors with exponential backoff.
+type autoHealer struct {
+	mu       sync.Mutex
+	enabled  bool // observing the monitoring poll
+	defaults autoRestartSettings
+	now      func() time.Time
+	client   *http.Client
+	states   map[string]*restartState
+}
+
+func newAutoHealer(defaults autoRestartSettings, now func() time.Time) *autoHealer {
+	return &autoHealer{
+		defaults: defaults,
+		now:      now,
+		client:   newConnectClient(10 * time.Second),
+		states:   make(map[string]*restartState),
+	}
+}
+
+func connectorHasFailed(status connectorStatusResponse) bool {
+	if normalizeState(status.Connector.State) == "failed" {
+		return true
+	}
+	for _, task := range status.Tasks {
+		if normalizeState(task.State) == "failed" {
+			return true
+		}
+	}
+	return false
+}
+
+// configOptIn reports whether the connector config sets console.autorestart=true.
+func (h *autoHealer) configOptIn(ctx context.Context, baseURL, name string) (bool, error) {
+	config, err := fetchConnectorConfig(ctx, h.client, baseURL, name)
+	if err != nil {
+		return false, err
+	}
+	enabled, _ := strconv.ParseBool(strings.TrimSpace(config[autoRestartConfigKey]))
+	return enabled, nil
+}
+
+// settingsFor resolves the effective settings of a connector. A metadata policy that
+// sets enabled wins over the config opt-in.
+func (h *autoHealer) settingsFor(ctx context.Context, baseURL, name string, state *restartState) autoRestartSettings {
+	policy := connectorMetadata.get(alertMetadataCluster, name).AutoRestart
+	settings := h.defaults.apply(policy)
+	if policy != nil && policy.Enabled != nil {
+		return settings
+	}
+
+	if state.configOptIn == nil {
+		optIn, err := h.configOptIn(ctx, baseURL, name)
+		if err != nil {
+			log.Printf("auto-healer: %v", err)
+			return settings
+		}
+		state.configOptIn = &optIn
+	}
+	settings.Enabled = *state.configOptIn
+	return settings
+}
+
+func (h *autoHealer) restart(ctx context.Context, baseURL, name string) (int, error) {
+	target := joinURL(baseURL, "connectors", url.PathEscape(name), "restart") + "?includeTasks=true&onlyFailed=true"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return http.StatusBadGateway, &connectUnavailableError{Err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("restart of %s returned HTTP %d", name, resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// observe is a statusObserver: it restarts failed connectors that are due and forgets
+// connectors that have recovered. Nothing is restarted while the cluster is in
+// maintenance mode.
+func (h *autoHealer) observe(clusterID string, statuses []connectorStatusResponse) {
+	if maintenance.active(alertMetadataCluster) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now().UTC()
+	baseURL := connectURLFor(alertMetadataCluster)
+	seen := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		seen[status.Name] = true
+		if !connectorHasFailed(status) {
+			if state, ok := h.states[status.Name]; ok && state.Attempts > 0 {
+				log.Printf("auto-healer: %s recovered after %d restart(s)", status.Name, state.Attempts)
+			}
+			delete(h.states, status.Name)
+			continue
+		}
+
+		state, ok := h.states[status.Name]
+		if !ok {
+			state = &restartState{}
+			h.states[status.Name] = state
+		}
+		if state.Exhausted || now.Before(state.NextAttempt) {
+			continue
+		}
+
+		settings := h.settingsFor(ctx, baseURL, status.Name, state)
+		if !settings.Enabled {
+			continue
+		}
+		if state.Attempts >= settings.MaxAttempts {
+			state.Exhausted = true
+			log.Printf("auto-healer: giving up on %s after %d restart(s)", status.Name, state.Attempts)
+			logAudit(AuditLogEntry{
+				Timestamp:     now,
+				User:          autoHealerUser,
+				Cluster:       alertMetadataCluster,
+				Action:        auditActionRestart,
+				ConnectorName: status.Name,
+				Status:        auditStatusFailure,
+				Details:       map[string]interface{}{"automatic": true, "attempts": state.Attempts, "exhausted": true},
+			})
+			continue
+		}
+
+		httpStatus, err := h.restart(ctx, baseURL, status.Name)
+		state.Attempts++
+		state.LastAttempt = now
+		state.NextAttempt = now.Add(settings.backoff(state.Attempts))
+		state.LastError = ""
+		auditStatus := auditStatusSuccess
+		details := map[string]interface{}{"automatic": true, "attempt": state.Attempts, "maxAttempts": settings.MaxAttempts, "nextAttemptAt": state.NextAttempt}
+		if err != nil {
+			state.LastError = err.Error()
+			auditStatus = auditStatusFailure
+			details["error"] = err.Error()
+			log.Printf("auto-healer: %v", err)
+		}
+		logAudit(AuditLogEntry{
+			Timestamp:     now,
+			User:          autoHealerUser,
+			Cluster:       alertMetadataCluster,
+			Action:        auditActionRestart,
+			ConnectorName: status.Name,
+			Status:        auditStatus,
+			HTTPStatus:    httpStatus,
+			Details:       details,
+		})
+	}
+
+	// Connectors that were deleted no longer need tracking.
+	for name := range h.states {
+		if !seen[name] {
+			delete(h.states, name)
+		}
+	}
+}
+
+func (h *autoHealer) state(name string) (restartState, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	state, ok := h.states[name]
+	if !ok {
+		return restartState{}, false
+	}
+	return *state, true
+}
+
+// ConnectorAutoRestart is returned by GET /api/{cluster}/connectors/{name}/autorestart.
+type ConnectorAutoRestart struct {
+	Connector      string             `json:"connector"`
+	EngineEnabled  bool               `json:"engineEnabled"`
+	Overrides      *AutoRestartPolicy `json:"overrides,omitempty"`
+	Enabled        bool               `json:"enabled"`
+	EnabledBy      string             `json:"enabledBy"` // metadata, config or default
+	MaxAttempts    int                `json:"maxAttempts"`
+	InitialBackoff string             `json:"initialBackoff"`
+	MaxBackoff     string             `json:"maxBackoff"`
+	State          *restartState      `json:"state,omitempty"`
+}
+
+// connectorAutoRestartHandler shows the effective auto-restart policy of a connector and
+// the current failure episode, if any. Overrides are edited through the metadata API.
+func connectorAutoRestartHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+	healer := connectorAutoHealer
+
+	policy := connectorMetadata.get(cluster, name).AutoRestart
+	settings := healer.defaults.apply(policy)
+	result := ConnectorAutoRestart{
+		Connector:      name,
+		EngineEnabled:  healer.enabled,
+		Overrides:      policy,
+		EnabledBy:      "default",
+		MaxAttempts:    settings.MaxAttempts,
+		InitialBackoff: settings.InitialBackoff.String(),
+		MaxBackoff:     settings.MaxBackoff.String(),
+	}
+
+	if policy != nil && policy.Enabled != nil {
+		result.Enabled, result.EnabledBy = *policy.Enabled, "metadata"
+	} else {
+		optIn, err := healer.configOptIn(r.Context(), connectURLFor(cluster), name)
+		if err != nil {
+			writeJSONError(w, http.StatusBadGateway, "config_fetch_failed", err.Error())
+			return
+		}
+		if optIn {
+			result.Enabled, result.EnabledBy = true, "config"
+		}
+	}
+
+	if cluster == alertMetadataCluster {
+		if state, ok := healer.state(name); ok {
+			result.State = &state
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
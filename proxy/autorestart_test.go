@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeRestartCluster serves connector configs and counts restart requests.
+type fakeRestartCluster struct {
+	mu       sync.Mutex
+	configs  map[string]map[string]string
+	restarts map[string]int
+	status   int
+}
+
+func newFakeRestartCluster(t *testing.T, configs map[string]map[string]string) *fakeRestartCluster {
+	t.Helper()
+	fake := &fakeRestartCluster{configs: configs, restarts: make(map[string]int), status: http.StatusAccepted}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[0] != "connectors" {
+			http.NotFound(w, r)
+			return
+		}
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && parts[2] == "config":
+			json.NewEncoder(w).Encode(fake.configs[parts[1]])
+		case r.Method == http.MethodPost && parts[2] == "restart":
+			if r.URL.Query().Get("includeTasks") != "true" || r.URL.Query().Get("onlyFailed") != "true" {
+				t.Errorf("expected includeTasks and onlyFailed, got %s", r.URL.RawQuery)
+			}
+			fake.restarts[parts[1]]++
+			w.WriteHeader(fake.status)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(withTestConnectURL(t, server))
+	return fake
+}
+
+func (f *fakeRestartCluster) restartCount(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.restarts[name]
+}
+
+func failedStatus(name string) connectorStatusResponse {
+	status := connectorStatusResponse{Name: name}
+	status.Connector.State = "RUNNING"
+	status.Tasks = append(status.Tasks, struct {
+		ID       int    `json:"id"`
+		State    string `json:"state"`
+		WorkerID string `json:"worker_id"`
+		Trace    string `json:"trace,omitempty"`
+	}{ID: 0, State: "FAILED"})
+	return status
+}
+
+func runningStatus(name string) connectorStatusResponse {
+	status := connectorStatusResponse{Name: name}
+	status.Connector.State = "RUNNING"
+	return status
+}
+
+func TestAutoRestartBackoff(t *testing.T) {
+	settings := autoRestartSettings{InitialBackoff: 30 * time.Second, MaxBackoff: 2 * time.Minute}
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 2 * time.Minute}
+	for i, want := range expected {
+		if got := settings.backoff(i + 1); got != want {
+			t.Fatalf("attempt %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+}
+
+func TestAutoHealerRestartsOptedInConnectors(t *testing.T) {
+	fake := newFakeRestartCluster(t, map[string]map[string]string{
+		"orders":  {autoRestartConfigKey: "true"},
+		"billing": {},
+	})
+	withTestMetadataStore(t)
+	logger := withTestAuditLog(t, 10)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	healer := newAutoHealer(autoRestartSettings{MaxAttempts: 2, InitialBackoff: time.Minute, MaxBackoff: time.Hour}, func() time.Time { return now })
+	statuses := []connectorStatusResponse{failedStatus("orders"), failedStatus("billing")}
+
+	healer.observe("cluster-1", statuses)
+	if fake.restartCount("orders") != 1 || fake.restartCount("billing") != 0 {
+		t.Fatalf("expected only the opted-in connector to restart, got %v", fake.restarts)
+	}
+
+	// Still inside the backoff window.
+	now = now.Add(30 * time.Second)
+	healer.observe("cluster-1", statuses)
+	if fake.restartCount("orders") != 1 {
+		t.Fatalf("expected no restart during backoff, got %d", fake.restartCount("orders"))
+	}
+
+	now = now.Add(30 * time.Second)
+	healer.observe("cluster-1", statuses)
+	now = now.Add(2 * time.Minute)
+	healer.observe("cluster-1", statuses)
+	if fake.restartCount("orders") != 2 {
+		t.Fatalf("expected restarts to stop at max attempts, got %d", fake.restartCount("orders"))
+	}
+	if state, _ := healer.state("orders"); !state.Exhausted || state.Attempts != 2 {
+		t.Fatalf("expected exhausted state, got %+v", state)
+	}
+
+	entries := logger.Query(AuditFilter{Connector: "orders"})
+	if len(entries) != 3 {
+		t.Fatalf("expected two restarts and a give-up entry, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.User != autoHealerUser || entry.Action != auditActionRestart || entry.Details["automatic"] != true {
+			t.Fatalf("unexpected audit entry: %+v", entry)
+		}
+	}
+	if entries[0].Status != auditStatusFailure || entries[1].Status != auditStatusSuccess {
+		t.Fatalf("expected the give-up entry to be a failure, got %s and %s", entries[0].Status, entries[1].Status)
+	}
+
+	// Recovery starts a fresh episode.
+	healer.observe("cluster-1", []connectorStatusResponse{runningStatus("orders")})
+	if _, ok := healer.state("orders"); ok {
+		t.Fatalf("expected recovered connector to be forgotten")
+	}
+}
+
+func TestAutoHealerMetadataPolicyOverridesConfig(t *testing.T) {
+	fake := newFakeRestartCluster(t, map[string]map[string]string{
+		"orders":  {autoRestartConfigKey: "true"},
+		"billing": {},
+	})
+	store := withTestMetadataStore(t)
+	withTestAuditLog(t, 10)
+
+	enabled, disabled := true, false
+	if _, err := store.update(alertMetadataCluster, []string{"billing"}, metadataPatch{AutoRestart: &AutoRestartPolicy{Enabled: &enabled}}, "alice"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := store.update(alertMetadataCluster, []string{"orders"}, metadataPatch{AutoRestart: &AutoRestartPolicy{Enabled: &disabled}}, "alice"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	healer := newAutoHealer(autoRestartSettings{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Hour}, time.Now)
+	healer.observe("cluster-1", []connectorStatusResponse{failedStatus("orders"), failedStatus("billing")})
+	if fake.restartCount("orders") != 0 || fake.restartCount("billing") != 1 {
+		t.Fatalf("expected metadata policy to win, got %v", fake.restarts)
+	}
+}
+
+func TestAutoRestartPolicyValidate(t *testing.T) {
+	negative, bad := -1, "soon"
+	if err := (&AutoRestartPolicy{MaxAttempts: &negative}).validate(); err == nil {
+		t.Fatalf("expected negative maxAttempts to be rejected")
+	}
+	if err := (&AutoRestartPolicy{InitialBackoff: &bad}).validate(); err == nil {
+		t.Fatalf("expected invalid backoff to be rejected")
+	}
+	if err := (*AutoRestartPolicy)(nil).validate(); err != nil {
+		t.Fatalf("expected nil policy to be valid, got %v", err)
+	}
+}
+
+func TestConnectorAutoRestartHandler(t *testing.T) {
+	newFakeRestartCluster(t, map[string]map[string]string{"orders": {autoRestartConfigKey: "true"}})
+	withTestMetadataStore(t)
+	original := connectorAutoHealer
+	connectorAutoHealer = newAutoHealer(autoRestartSettings{MaxAttempts: 5, InitialBackoff: 30 * time.Second, MaxBackoff: 10 * time.Minute}, time.Now)
+	t.Cleanup(func() { connectorAutoHealer = original })
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders/autorestart", nil), map[string]string{"cluster": "default", "name": "orders"})
+	rr := httptest.NewRecorder()
+	connectorAutoRestartHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var result ConnectorAutoRestart
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !result.Enabled || result.EnabledBy != "config" || result.MaxAttempts != 5 || result.InitialBackoff != "30s" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
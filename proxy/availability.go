@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	availabilityDefaultPeriod = 30 * 24 * time.Hour
+
+	availabilityFormatJSON = "json"
+	availabilityFormatCSV  = "csv"
+)
+
+var availabilityCSVHeader = []string{"connector", "availabilityPercent", "observedSeconds", "downSeconds", "plannedSeconds", "incidents", "mttrSeconds", "longestOutageSeconds", "ongoingOutage"}
+
+// ConnectorAvailability summarises the state history of one connector over a report
+// period. A connector is down while it or one of its tasks is FAILED; time spent paused
+// or stopped is planned and excluded from the availability percentage, as is time the
+// connector did not exist. AvailabilityPercent and MTTRSeconds are null when there is
+// nothing to compute them from.
+type ConnectorAvailability struct {
+	Connector            string   `json:"connector"`
+	AvailabilityPercent  *float64 `json:"availabilityPercent"`
+	ObservedSeconds      float64  `json:"observedSeconds"`
+	DownSeconds          float64  `json:"downSeconds"`
+	PlannedSeconds       float64  `json:"plannedSeconds"`
+	Incidents            int      `json:"incidents"`
+	MTTRSeconds          *float64 `json:"mttrSeconds"`
+	LongestOutageSeconds float64  `json:"longestOutageSeconds"`
+	OngoingOutage        bool     `json:"ongoingOutage"`
+}
+
+// AvailabilityReport is returned by GET /api/{cluster}/reports/availability.
+type AvailabilityReport struct {
+	Cluster    string                  `json:"cluster"`
+	From       time.Time               `json:"from"`
+	To         time.Time               `json:"to"`
+	Connectors []ConnectorAvailability `json:"connectors"`
+}
+
+type availabilityPhase int
+
+const (
+	phaseAbsent availabilityPhase = iota
+	phaseUp
+	phaseDown
+	phasePlanned
+)
+
+// availabilityPhaseOf classifies the states of a connector's targets at one moment.
+func availabilityPhaseOf(states map[string]string) availabilityPhase {
+	if len(states) == 0 {
+		return phaseAbsent
+	}
+	for _, state := range states {
+		if state == "failed" {
+			return phaseDown
+		}
+	}
+	switch states[stateTargetConnector] {
+	case "paused", "stopped":
+		return phasePlanned
+	}
+	return phaseUp
+}
+
+// outage is a period during which a connector was down. Open outages are still in
+// progress at the end of the replay.
+type outage struct {
+	start, end time.Time
+	open       bool
+}
+
+// connectorAvailability replays the transitions of one connector, oldest first, and
+// measures the time spent in each phase between from and to. An outage already in
+// progress at from counts as an incident, clipped to the period. MTTR is the mean
+// duration of the outages that ended within the period. ok is false when the connector
+// did not exist during the period.
+func connectorAvailability(name string, transitions []StateTransition, from, to time.Time) (result ConnectorAvailability, ok bool) {
+	result.Connector = name
+	states := make(map[string]string)
+	phase, phaseStart := phaseAbsent, from
+	var outages []outage
+
+	// advance accounts for the time spent in the current phase up to t.
+	advance := func(t time.Time) {
+		start, end := maxTime(phaseStart, from), t
+		if !end.After(start) {
+			return
+		}
+		seconds := end.Sub(start).Seconds()
+		switch phase {
+		case phaseUp:
+			result.ObservedSeconds += seconds
+		case phaseDown:
+			result.ObservedSeconds += seconds
+			result.DownSeconds += seconds
+		case phasePlanned:
+			result.PlannedSeconds += seconds
+		}
+		if phase != phaseAbsent {
+			ok = true
+		}
+	}
+
+	for _, transition := range transitions {
+		if transition.At.After(to) {
+			break
+		}
+		advance(transition.At)
+		if transition.To == stateRemoved {
+			delete(states, transition.Target)
+		} else {
+			states[transition.Target] = transition.To
+		}
+
+		next := availabilityPhaseOf(states)
+		switch {
+		case next == phaseDown && phase != phaseDown:
+			outages = append(outages, outage{start: transition.At, open: true})
+		case next != phaseDown && phase == phaseDown:
+			outages[len(outages)-1].end, outages[len(outages)-1].open = transition.At, false
+		}
+		phase, phaseStart = next, transition.At
+	}
+	advance(to)
+
+	var repaired []float64
+	for _, o := range outages {
+		end := o.end
+		if o.open {
+			end = to
+		}
+		if !end.After(from) || !o.start.Before(to) {
+			continue
+		}
+		seconds := end.Sub(maxTime(o.start, from)).Seconds()
+		result.Incidents++
+		result.LongestOutageSeconds = math.Max(result.LongestOutageSeconds, seconds)
+		if o.open {
+			result.OngoingOutage = true
+		} else {
+			repaired = append(repaired, seconds)
+		}
+	}
+
+	if result.ObservedSeconds > 0 {
+		percent := (result.ObservedSeconds - result.DownSeconds) / result.ObservedSeconds * 100
+		result.AvailabilityPercent = &percent
+	}
+	if len(repaired) > 0 {
+		total := 0.0
+		for _, seconds := range repaired {
+			total += seconds
+		}
+		mttr := total / float64(len(repaired))
+		result.MTTRSeconds = &mttr
+	}
+	return result, ok
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// availability reports on every connector of cluster that existed between from and to,
+// including connectors deleted since, sorted by name.
+func (h *stateHistory) availability(cluster string, from, to time.Time) []ConnectorAvailability {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	report := []ConnectorAvailability{}
+	for name, stateLog := range h.clusters[cluster] {
+		if result, ok := connectorAvailability(name, stateLog.Transitions, from, to); ok {
+			report = append(report, result)
+		}
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Connector < report[j].Connector })
+	return report
+}
+
+// connectorAvailabilityOf reports on one connector of cluster between from and to. ok
+// is false when the connector has no history in the period.
+func (h *stateHistory) connectorAvailabilityOf(cluster, name string, from, to time.Time) (ConnectorAvailability, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stateLog, ok := h.clusters[cluster][name]
+	if !ok {
+		return ConnectorAvailability{}, false
+	}
+	return connectorAvailability(name, stateLog.Transitions, from, to)
+}
+
+// availabilityCSVRecord formats one row; null values are left empty.
+func availabilityCSVRecord(entry ConnectorAvailability) []string {
+	number := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	optional := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return number(*v)
+	}
+	return []string{
+		csvSafe(entry.Connector),
+		optional(entry.AvailabilityPercent),
+		number(entry.ObservedSeconds),
+		number(entry.DownSeconds),
+		number(entry.PlannedSeconds),
+		strconv.Itoa(entry.Incidents),
+		optional(entry.MTTRSeconds),
+		number(entry.LongestOutageSeconds),
+		strconv.FormatBool(entry.OngoingOutage),
+	}
+}
+
+func writeAvailabilityCSV(w http.ResponseWriter, report AvailabilityReport) {
+	filename := unsafeFilenameChars.ReplaceAllString(strings.Join([]string{
+		"availability", report.Cluster, report.From.Format("20060102T150405Z"), report.To.Format("20060102T150405Z"),
+	}, "_"), "-") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write(availabilityCSVHeader)
+	for _, entry := range report.Connectors {
+		writer.Write(availabilityCSVRecord(entry))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("availability: failed to write CSV report: %v", err)
+	}
+}
+
+// availabilityReportHandler reports availability, failure incidents, MTTR and the
+// longest outage of every connector between ?from= and ?to= (default: the last 30
+// days), computed from the recorded state history. format=csv downloads the report.
+func availabilityReportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := strings.ToLower(query.Get("format"))
+	if format != "" && format != availabilityFormatJSON && format != availabilityFormatCSV {
+		writeJSONError(w, http.StatusBadRequest, "invalid_format", "format must be json or csv")
+		return
+	}
+	loc, err := requestLocation(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_time_range", err.Error())
+		return
+	}
+
+	now := connectorStateHistory.now().UTC()
+	to := now
+	if value := strings.TrimSpace(query.Get("to")); value != "" {
+		if to, err = parseTimestamp(value, loc); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_time_range", "to: "+err.Error())
+			return
+		}
+		if to.After(now) {
+			to = now
+		}
+	}
+	from := to.Add(-availabilityDefaultPeriod)
+	if value := strings.TrimSpace(query.Get("from")); value != "" {
+		if from, err = parseTimestamp(value, loc); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_time_range", "from: "+err.Error())
+			return
+		}
+	}
+	if !from.Before(to) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_time_range", "from must be before to")
+		return
+	}
+
+	cluster := mux.Vars(r)["cluster"]
+	report := AvailabilityReport{
+		Cluster:    cluster,
+		From:       from,
+		To:         to,
+		Connectors: connectorStateHistory.availability(cluster, from, to),
+	}
+	setTimezoneHeader(w, loc)
+	if format == availabilityFormatCSV {
+		writeAvailabilityCSV(w, report)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestConnectorAvailability(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours float64) time.Time { return start.Add(time.Duration(hours * float64(time.Hour))) }
+	transitions := []StateTransition{
+		{Target: stateTargetConnector, To: "running", At: at(0)},
+		{Target: "task-0", To: "running", At: at(0)},
+		// Down from 2h to 3h, counted from the start of the period at 2h30.
+		{Target: "task-0", From: "running", To: "failed", At: at(2)},
+		{Target: "task-0", From: "failed", To: "running", At: at(3)},
+		// Paused for 1h: planned.
+		{Target: stateTargetConnector, From: "running", To: "paused", At: at(4)},
+		{Target: stateTargetConnector, From: "paused", To: "running", At: at(5)},
+		// Down for 30m, then again from 7h until the end of the period.
+		{Target: stateTargetConnector, From: "running", To: "failed", At: at(6)},
+		{Target: stateTargetConnector, From: "failed", To: "running", At: at(6.5)},
+		{Target: stateTargetConnector, From: "running", To: "failed", At: at(7)},
+	}
+
+	result, ok := connectorAvailability("orders", transitions, at(2.5), at(8.5))
+	if !ok {
+		t.Fatalf("expected the connector to be reported")
+	}
+	// Observed 5h (6h minus 1h paused), down 30m + 30m + 1h30.
+	if result.ObservedSeconds != 5*3600 || result.DownSeconds != 2.5*3600 || result.PlannedSeconds != 3600 {
+		t.Fatalf("unexpected durations %+v", result)
+	}
+	if result.AvailabilityPercent == nil || *result.AvailabilityPercent != 50 {
+		t.Fatalf("expected 50%% availability, got %v", result.AvailabilityPercent)
+	}
+	if result.Incidents != 3 || !result.OngoingOutage || result.LongestOutageSeconds != 1.5*3600 {
+		t.Fatalf("unexpected incidents %+v", result)
+	}
+	if result.MTTRSeconds == nil || *result.MTTRSeconds != 1800 {
+		t.Fatalf("expected MTTR over the two repaired outages, got %v", result.MTTRSeconds)
+	}
+
+	// A connector deleted before the period is not reported.
+	deleted := []StateTransition{
+		{Target: stateTargetConnector, To: "running", At: at(0)},
+		{Target: stateTargetConnector, From: "running", To: stateRemoved, At: at(1)},
+	}
+	if _, ok := connectorAvailability("legacy", deleted, at(2), at(3)); ok {
+		t.Fatalf("expected a connector that no longer existed to be skipped")
+	}
+}
+
+func TestAvailabilityReportHandler(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	original := connectorStateHistory
+	connectorStateHistory = newStateHistory(7*24*time.Hour, func() time.Time { return now })
+	t.Cleanup(func() { connectorStateHistory = original })
+
+	connectorStateHistory.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[]},{"name":"=billing","connector":{"state":"RUNNING"},"tasks":[]}]`))
+	now = start.Add(time.Hour)
+	connectorStateHistory.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"FAILED"},"tasks":[]},{"name":"=billing","connector":{"state":"RUNNING"},"tasks":[]}]`))
+	now = start.Add(2 * time.Hour)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/reports/availability"+query, nil), map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		availabilityReportHandler(rr, req)
+		return rr
+	}
+
+	rr := get("")
+	var report AvailabilityReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !report.To.Equal(now) || !report.From.Equal(now.Add(-availabilityDefaultPeriod)) || len(report.Connectors) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if orders := report.Connectors[1]; orders.Connector != "orders" || *orders.AvailabilityPercent != 50 || orders.Incidents != 1 || orders.MTTRSeconds != nil {
+		t.Fatalf("unexpected orders availability %+v", orders)
+	}
+
+	rr = get("?from=2024-05-01T00:30:00Z&to=2024-05-01T01:00:00Z&format=csv")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Header().Get("Content-Disposition"), "availability_default_20240501T003000Z_20240501T010000Z.csv") {
+		t.Fatalf("expected a CSV attachment, got %d %v", rr.Code, rr.Header())
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil || len(records) != 3 || records[0][0] != "connector" {
+		t.Fatalf("unexpected CSV %v, %v", records, err)
+	}
+	if records[1][0] != "'=billing" || records[2][1] != "100" || records[2][5] != "0" || records[2][6] != "" {
+		t.Fatalf("unexpected CSV rows %v", records[1:])
+	}
+
+	for _, query := range []string{"?format=xml", "?from=yesterday", "?from=2024-05-01T02:00:00Z&to=2024-05-01T01:00:00Z"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
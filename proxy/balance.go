@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultBalanceThreshold is how many times the mean task count a worker may carry
+// before it is flagged as overloaded.
+const defaultBalanceThreshold = 1.5
+
+// WorkerBalance is the task load of one worker.
+type WorkerBalance struct {
+	ID         string `json:"id"`
+	Tasks      int    `json:"tasks"`
+	Connectors int    `json:"connectors"`
+	// Share is the fraction of the placed tasks the worker runs.
+	Share float64 `json:"share"`
+	// Deviation is the worker's task count minus the mean.
+	Deviation  float64 `json:"deviation"`
+	Overloaded bool    `json:"overloaded"`
+}
+
+// TaskBalance is returned by GET /api/{cluster}/monitoring/balance. Workers only appear
+// when they run a connector or a task, so an idle worker that just joined is not seen.
+type TaskBalance struct {
+	Workers         []WorkerBalance `json:"workers"`
+	Tasks           int             `json:"tasks"`
+	UnassignedTasks int             `json:"unassignedTasks"`
+	MinTasks        int             `json:"minTasks"`
+	MaxTasks        int             `json:"maxTasks"`
+	MeanTasks       float64         `json:"meanTasks"`
+	StdDev          float64         `json:"stdDev"`
+	// CoefficientOfVariation is StdDev relative to MeanTasks, comparable across clusters.
+	CoefficientOfVariation float64 `json:"coefficientOfVariation"`
+	Threshold              float64 `json:"threshold"`
+	RebalanceRecommended   bool    `json:"rebalanceRecommended"`
+	Reason                 string  `json:"reason,omitempty"`
+}
+
+// computeTaskBalance measures how evenly the placed tasks are spread over the workers. A
+// worker is overloaded when it runs more than threshold times the mean, and more than
+// the ceiling of the mean: with 3 tasks on 2 workers one of them has to run 2.
+func computeTaskBalance(detail WorkersDetail, threshold float64) TaskBalance {
+	balance := TaskBalance{Workers: []WorkerBalance{}, UnassignedTasks: len(detail.UnassignedTasks), Threshold: threshold}
+	if len(detail.Workers) == 0 {
+		return balance
+	}
+
+	balance.MinTasks = math.MaxInt32
+	for _, worker := range detail.Workers {
+		tasks := len(worker.Tasks)
+		balance.Tasks += tasks
+		if tasks < balance.MinTasks {
+			balance.MinTasks = tasks
+		}
+		if tasks > balance.MaxTasks {
+			balance.MaxTasks = tasks
+		}
+	}
+	mean := float64(balance.Tasks) / float64(len(detail.Workers))
+	balance.MeanTasks = roundTo(mean, 2)
+
+	var variance float64
+	var overloaded []string
+	for _, worker := range detail.Workers {
+		tasks := float64(len(worker.Tasks))
+		variance += (tasks - mean) * (tasks - mean)
+		entry := WorkerBalance{
+			ID:         worker.ID,
+			Tasks:      len(worker.Tasks),
+			Connectors: len(worker.Connectors),
+			Deviation:  roundTo(tasks-mean, 2),
+			Overloaded: tasks > mean*threshold && tasks > math.Ceil(mean),
+		}
+		if balance.Tasks > 0 {
+			entry.Share = roundTo(tasks/float64(balance.Tasks), 4)
+		}
+		if entry.Overloaded {
+			overloaded = append(overloaded, worker.ID)
+		}
+		balance.Workers = append(balance.Workers, entry)
+	}
+	stdDev := math.Sqrt(variance / float64(len(detail.Workers)))
+	balance.StdDev = roundTo(stdDev, 2)
+	if mean > 0 {
+		balance.CoefficientOfVariation = roundTo(stdDev/mean, 4)
+	}
+	sort.Slice(balance.Workers, func(i, j int) bool {
+		if balance.Workers[i].Tasks != balance.Workers[j].Tasks {
+			return balance.Workers[i].Tasks > balance.Workers[j].Tasks
+		}
+		return balance.Workers[i].ID < balance.Workers[j].ID
+	})
+
+	if len(overloaded) > 0 {
+		sort.Strings(overloaded)
+		balance.RebalanceRecommended = true
+		balance.Reason = fmt.Sprintf("%d worker(s) run more than %.2gx the mean of %.2f tasks: %s", len(overloaded), threshold, balance.MeanTasks, strings.Join(overloaded, ", "))
+	}
+	return balance
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+// taskBalanceHandler reports the task distribution across the workers of a cluster and
+// whether it is skewed enough to warrant a rebalance. ?threshold= overrides the factor
+// of the mean above which a worker is flagged.
+func taskBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	threshold := defaultBalanceThreshold
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(parsed > 1) {
+			writeJSONError(w, http.StatusBadRequest, "invalid_query", fmt.Sprintf("threshold must be a number greater than 1, got %q", raw))
+			return
+		}
+		threshold = parsed
+	}
+
+	cluster := mux.Vars(r)["cluster"]
+	connectors, err := fetchExpandedConnectorStatuses(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, computeTaskBalance(aggregateWorkers(connectors), threshold))
+}
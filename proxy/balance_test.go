@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func balanceWorkers(tasks map[string]int) WorkersDetail {
+	detail := WorkersDetail{}
+	for id, count := range tasks {
+		worker := WorkerDetail{ID: id, Connectors: []string{}, Tasks: []WorkerTask{}}
+		for i := 0; i < count; i++ {
+			worker.Tasks = append(worker.Tasks, WorkerTask{Connector: "c", ID: i})
+		}
+		detail.Workers = append(detail.Workers, worker)
+	}
+	return detail
+}
+
+func TestComputeTaskBalance(t *testing.T) {
+	even := computeTaskBalance(balanceWorkers(map[string]int{"w1:8083": 2, "w2:8083": 1}), defaultBalanceThreshold)
+	if even.RebalanceRecommended || even.MaxTasks != 2 || even.MinTasks != 1 || even.MeanTasks != 1.5 || even.StdDev != 0.5 {
+		t.Fatalf("expected an uneven split of 3 tasks to be acceptable, got %+v", even)
+	}
+
+	skewed := computeTaskBalance(balanceWorkers(map[string]int{"w1:8083": 8, "w2:8083": 2, "w3:8083": 2}), defaultBalanceThreshold)
+	if !skewed.RebalanceRecommended || skewed.Tasks != 12 || skewed.MeanTasks != 4 || skewed.StdDev != 2.83 || skewed.CoefficientOfVariation != 0.7071 {
+		t.Fatalf("unexpected skewed balance %+v", skewed)
+	}
+	if top := skewed.Workers[0]; top.ID != "w1:8083" || !top.Overloaded || top.Share != 0.6667 || top.Deviation != 4 {
+		t.Fatalf("expected w1 to be flagged, got %+v", top)
+	}
+	if skewed.Workers[1].Overloaded || skewed.Reason == "" {
+		t.Fatalf("expected only w1 to be flagged, got %+v", skewed)
+	}
+
+	if lenient := computeTaskBalance(balanceWorkers(map[string]int{"w1:8083": 8, "w2:8083": 2, "w3:8083": 2}), 2.5); lenient.RebalanceRecommended {
+		t.Fatalf("expected a higher threshold not to flag w1, got %+v", lenient)
+	}
+	if empty := computeTaskBalance(WorkersDetail{}, defaultBalanceThreshold); empty.RebalanceRecommended || len(empty.Workers) != 0 {
+		t.Fatalf("unexpected balance without workers %+v", empty)
+	}
+}
+
+func TestTaskBalanceHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+		  "orders": {"status": {"connector": {"state": "RUNNING", "worker_id": "w1:8083"}, "tasks": [
+		    {"id": 0, "state": "RUNNING", "worker_id": "w1:8083"},
+		    {"id": 1, "state": "RUNNING", "worker_id": "w1:8083"},
+		    {"id": 2, "state": "RUNNING", "worker_id": "w1:8083"},
+		    {"id": 3, "state": "UNASSIGNED", "worker_id": ""}
+		  ]}},
+		  "payments": {"status": {"connector": {"state": "RUNNING", "worker_id": "w2:8083"}, "tasks": []}}
+		}`)
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/default/monitoring/balance"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		taskBalanceHandler(rr, req)
+		return rr
+	}
+
+	rr := get("")
+	var balance TaskBalance
+	if err := json.Unmarshal(rr.Body.Bytes(), &balance); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(balance.Workers) != 2 || balance.UnassignedTasks != 1 || balance.Workers[1].ID != "w2:8083" || balance.Workers[1].Tasks != 0 || balance.Workers[1].Connectors != 1 {
+		t.Fatalf("expected the connector-only worker to count with no tasks, got %+v", balance)
+	}
+	if !balance.RebalanceRecommended || !balance.Workers[0].Overloaded {
+		t.Fatalf("expected w1 to be flagged, got %+v", balance)
+	}
+
+	if rr := get("?threshold=1"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a threshold of 1 to be rejected, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheBackendMemory = "memory"
+	cacheBackendRedis  = "redis"
+
+	redisDialTimeout = 5 * time.Second
+	// redisCommandTimeout bounds a command whose context has no deadline of its own.
+	redisCommandTimeout = 2 * time.Second
+	redisMaxIdleConns   = 8
+	redisScanCount      = 500
+)
+
+var (
+	// cacheBackendSetting selects where the config, plugin catalog and monitoring summary
+	// caches keep their entries. With "redis" every proxy replica shares entries and
+	// invalidations; CACHE_REDIS_URL is then required.
+	cacheBackendSetting = getEnv("CACHE_BACKEND", cacheBackendMemory)
+	cacheRedisURL       = getEnv("CACHE_REDIS_URL", "")
+	// cacheKeyPrefix namespaces the keys in Redis so several consoles can share a server.
+	cacheKeyPrefix = getEnv("CACHE_KEY_PREFIX", "kconnect-console:")
+
+	sharedCache Cache = newMemoryCache(time.Now)
+)
+
+// Cache stores byte values with a TTL. Subsystems namespace their keys and encode their
+// own values; a failing backend is treated by callers as a miss.
+type Cache interface {
+	// Get returns the value stored under key, or false when it is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Invalidate drops every entry whose key starts with prefix.
+	Invalidate(ctx context.Context, prefix string) error
+}
+
+// loadCache builds the cache backend selected by CACHE_BACKEND.
+func loadCache() (Cache, error) {
+	switch strings.ToLower(strings.TrimSpace(cacheBackendSetting)) {
+	case cacheBackendMemory, "":
+		return newMemoryCache(time.Now), nil
+	case cacheBackendRedis:
+		if strings.TrimSpace(cacheRedisURL) == "" {
+			return nil, fmt.Errorf("CACHE_REDIS_URL is required when CACHE_BACKEND is redis")
+		}
+		return newRedisCache(cacheRedisURL, cacheKeyPrefix)
+	default:
+		return nil, &configError{name: "CACHE_BACKEND", value: cacheBackendSetting}
+	}
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// memoryCache is the in-process Cache. Expired entries are dropped when they are read.
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+func newMemoryCache(now func() time.Time) *memoryCache {
+	return &memoryCache{entries: make(map[string]memoryEntry), now: now}
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = memoryEntry{value: value, expiresAt: c.now().Add(ttl)}
+	return nil
+}
+
+func (c *memoryCache) Invalidate(ctx context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	return nil
+}
+
+// redisCache is a Cache backed by a Redis server.
+type redisCache struct {
+	client *redisClient
+	prefix string
+}
+
+func newRedisCache(rawURL, prefix string) (*redisCache, error) {
+	client, err := newRedisClient("CACHE_REDIS_URL", rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &redisCache{client: client, prefix: prefix}, nil
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.client.do(ctx, "GET", c.prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	ms := ttl.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	_, err := c.client.do(ctx, "SET", c.prefix+key, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+// Invalidate scans for the prefix and deletes the matching keys batch by batch, so a
+// large keyspace never blocks the server.
+func (c *redisCache) Invalidate(ctx context.Context, prefix string) error {
+	pattern := redisGlobEscape(c.prefix+prefix) + "*"
+	cursor := "0"
+	for {
+		reply, err := c.client.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(redisScanCount))
+		if err != nil {
+			return err
+		}
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return fmt.Errorf("redis: unexpected SCAN reply %v", reply)
+		}
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]interface{})
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, key := range keys {
+				if k, ok := key.([]byte); ok {
+					args = append(args, string(k))
+				}
+			}
+			if _, err := c.client.do(ctx, args...); err != nil {
+				return err
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+func redisGlobEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// redisClient speaks RESP to a Redis server over a small pool of connections.
+type redisClient struct {
+	addr     string
+	username string
+	password string
+	db       int
+	useTLS   bool
+
+	idle chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError is an error reply from the server; the connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// newRedisClient parses redis://[user:password@]host[:port][/db], as read from the
+// named setting; rediss:// uses TLS.
+func newRedisClient(setting, rawURL string) (*redisClient, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Hostname() == "" {
+		return nil, &configError{name: setting, value: redactURL(rawURL)}
+	}
+	c := &redisClient{addr: u.Host, useTLS: u.Scheme == "rediss", idle: make(chan *redisConn, redisMaxIdleConns)}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 {
+			return nil, &configError{name: setting, value: redactURL(rawURL)}
+		}
+	}
+	return c, nil
+}
+
+// do runs one command. A connection that fails mid-command is closed rather than
+// returned to the pool, since its stream may hold a partial reply.
+func (c *redisClient) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.roundTrip(ctx, args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	return reply, err
+}
+
+func (c *redisClient) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	raw, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if c.useTLS {
+		host, _, _ := net.SplitHostPort(c.addr)
+		raw = tls.Client(raw, &tls.Config{ServerName: host})
+	}
+	conn := &redisConn{conn: raw, r: bufio.NewReader(raw)}
+
+	if c.password != "" {
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := conn.roundTrip(ctx, auth); err != nil {
+			raw.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.roundTrip(ctx, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			raw.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *redisClient) put(conn *redisConn) {
+	select {
+	case c.idle <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (rc *redisConn) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisCommandTimeout)
+	}
+	rc.conn.SetDeadline(deadline)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(rc.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readRESP(rc.r)
+}
+
+// readRESP reads one RESP2 reply. Nil bulk strings and arrays are returned as nil.
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	debugCaptureEnabled = getEnv("DEBUG_CAPTURE", "false")
+	debugCaptureSize    = getEnv("DEBUG_CAPTURE_SIZE", "100")
+	debugCaptureMaxBody = getEnv("DEBUG_CAPTURE_MAX_BODY", "16384")
+	debugCaptureToken   = getEnv("DEBUG_CAPTURE_TOKEN", "")
+
+	trafficCapture = newCaptureBuffer(100, 16384)
+)
+
+// CapturedExchange is one request/response pair recorded by the debug capture. JSON
+// bodies are redacted like proxied responses, and bodies are cut at
+// DEBUG_CAPTURE_MAX_BODY bytes.
+type CapturedExchange struct {
+	ID                string    `json:"id"`
+	Timestamp         time.Time `json:"timestamp"`
+	User              string    `json:"user"`
+	Method            string    `json:"method"`
+	Path              string    `json:"path"`
+	Query             string    `json:"query,omitempty"`
+	Status            int       `json:"status"`
+	LatencyMs         float64   `json:"latencyMs"`
+	RequestBody       string    `json:"requestBody,omitempty"`
+	ResponseType      string    `json:"responseContentType,omitempty"`
+	ResponseBody      string    `json:"responseBody,omitempty"`
+	RequestTruncated  bool      `json:"requestTruncated,omitempty"`
+	ResponseTruncated bool      `json:"responseTruncated,omitempty"`
+}
+
+// CaptureStatus is returned by the capture toggle and included with the captures.
+type CaptureStatus struct {
+	Enabled  bool `json:"enabled"`
+	Size     int  `json:"size"`
+	Captured int  `json:"captured"`
+}
+
+// CaptureList is returned by GET /api/debug/captures.
+type CaptureList struct {
+	CaptureStatus
+	Captures []CapturedExchange `json:"captures"`
+}
+
+// captureBuffer keeps the most recent exchanges while capture is enabled.
+type captureBuffer struct {
+	mu        sync.Mutex
+	enabled   bool
+	size      int
+	maxBody   int
+	exchanges []CapturedExchange
+	nextID    int64
+}
+
+func newCaptureBuffer(size, maxBody int) *captureBuffer {
+	return &captureBuffer{size: size, maxBody: maxBody}
+}
+
+// loadCaptureBuffer builds the capture buffer from DEBUG_CAPTURE*.
+func loadCaptureBuffer() (*captureBuffer, error) {
+	enabled, err := strconv.ParseBool(strings.TrimSpace(debugCaptureEnabled))
+	if err != nil {
+		return nil, &configError{name: "DEBUG_CAPTURE", value: debugCaptureEnabled}
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(debugCaptureSize))
+	if err != nil || size <= 0 {
+		return nil, &configError{name: "DEBUG_CAPTURE_SIZE", value: debugCaptureSize}
+	}
+	maxBody, err := strconv.Atoi(strings.TrimSpace(debugCaptureMaxBody))
+	if err != nil || maxBody < 0 {
+		return nil, &configError{name: "DEBUG_CAPTURE_MAX_BODY", value: debugCaptureMaxBody}
+	}
+	buffer := newCaptureBuffer(size, maxBody)
+	buffer.enabled = enabled
+	return buffer, nil
+}
+
+func (b *captureBuffer) isEnabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.enabled
+}
+
+func (b *captureBuffer) setEnabled(enabled bool) CaptureStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.enabled = enabled
+	return b.statusLocked()
+}
+
+func (b *captureBuffer) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.exchanges = nil
+}
+
+func (b *captureBuffer) statusLocked() CaptureStatus {
+	return CaptureStatus{Enabled: b.enabled, Size: b.size, Captured: len(b.exchanges)}
+}
+
+func (b *captureBuffer) add(exchange CapturedExchange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	exchange.ID = strconv.FormatInt(b.nextID, 10)
+	b.exchanges = append(b.exchanges, exchange)
+	if overflow := len(b.exchanges) - b.size; overflow > 0 {
+		b.exchanges = append([]CapturedExchange(nil), b.exchanges[overflow:]...)
+	}
+}
+
+// list returns up to limit exchanges, newest first; limit <= 0 returns all of them.
+func (b *captureBuffer) list(limit int) (CaptureStatus, []CapturedExchange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make([]CapturedExchange, 0, len(b.exchanges))
+	for i := len(b.exchanges) - 1; i >= 0; i-- {
+		result = append(result, b.exchanges[i])
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return b.statusLocked(), result
+}
+
+// captureBody redacts a JSON body and cuts it to max bytes.
+func captureBody(body []byte, max int) (string, bool) {
+	var document interface{}
+	if json.Unmarshal(body, &document) == nil {
+		if redacted, err := json.Marshal(redactSensitiveData(document)); err == nil {
+			body = redacted
+		}
+	}
+	if len(body) > max {
+		return string(body[:max]), true
+	}
+	return string(body), false
+}
+
+// captureWriter keeps a copy of the first limit bytes of the response and notes in
+// truncated whether more was written. A JSON body that fits is redacted as a whole by
+// captureBody; the start of a longer one is kept as written, which for proxied
+// responses is already redacted.
+type captureWriter struct {
+	http.ResponseWriter
+	status    int
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if room := cw.limit - cw.body.Len(); len(p) > room {
+		if room > 0 {
+			cw.body.Write(p[:room])
+		}
+		cw.truncated = true
+	} else {
+		cw.body.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *captureWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// captureMiddleware records API exchanges while debug capture is enabled. Event
+// streams and the debug endpoints themselves are not recorded.
+func captureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buffer := trafficCapture
+		if !buffer.isEnabled() || !strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/api/debug/") ||
+			strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		exchange := CapturedExchange{
+			Timestamp: time.Now().UTC(),
+			User:      requestUser(r),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Query:     r.URL.RawQuery,
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if err == nil {
+				exchange.RequestBody, exchange.RequestTruncated = captureBody(body, buffer.maxBody)
+			}
+		}
+
+		recorder := &captureWriter{ResponseWriter: w, limit: buffer.maxBody}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		exchange.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+		exchange.Status = recorder.status
+		if exchange.Status == 0 {
+			exchange.Status = http.StatusOK
+		}
+		exchange.ResponseType = w.Header().Get("Content-Type")
+		exchange.ResponseBody, exchange.ResponseTruncated = captureBody(recorder.body.Bytes(), buffer.maxBody)
+		exchange.ResponseTruncated = exchange.ResponseTruncated || recorder.truncated
+		buffer.add(exchange)
+	})
+}
+
+// authorizeDebug requires DEBUG_CAPTURE_TOKEN as a bearer token. Captures hold request
+// bodies, so the endpoints stay closed until a token is configured.
+func authorizeDebug(w http.ResponseWriter, r *http.Request) bool {
+	token := debugCaptureToken
+	if token == "" {
+		writeJSONError(w, http.StatusForbidden, "debug_disabled", "set DEBUG_CAPTURE_TOKEN to use the debug endpoints")
+		return false
+	}
+	presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, "invalid_debug_token", "a valid debug token is required")
+		return false
+	}
+	return true
+}
+
+// debugCaptureHandler turns the capture on or off with {"enabled": true|false};
+// "clear": true also drops the recorded exchanges. Toggles are audited.
+func debugCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizeDebug(w, r) {
+		return
+	}
+	var req struct {
+		Enabled *bool `json:"enabled"`
+		Clear   bool  `json:"clear"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", `request body must be {"enabled": true|false}`)
+		return
+	}
+
+	if req.Clear {
+		trafficCapture.clear()
+	}
+	status := trafficCapture.setEnabled(*req.Enabled)
+	recordAudit(r, auditActionAdmin, "", http.StatusOK, map[string]interface{}{"debugCapture": *req.Enabled, "cleared": req.Clear})
+	writeJSON(w, http.StatusOK, status)
+}
+
+// debugCapturesHandler returns the recorded exchanges, newest first (?limit= caps them).
+func debugCapturesHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizeDebug(w, r) {
+		return
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_limit", "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+
+	status, exchanges := trafficCapture.list(limit)
+	writeJSON(w, http.StatusOK, CaptureList{CaptureStatus: status, Captures: exchanges})
+}
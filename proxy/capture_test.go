@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func withTestCapture(t *testing.T, size, maxBody int, token string) *captureBuffer {
+	t.Helper()
+	originalBuffer, originalToken := trafficCapture, debugCaptureToken
+	trafficCapture, debugCaptureToken = newCaptureBuffer(size, maxBody), token
+	t.Cleanup(func() { trafficCapture, debugCaptureToken = originalBuffer, originalToken })
+	return trafficCapture
+}
+
+func TestCaptureMiddleware(t *testing.T) {
+	buffer := withTestCapture(t, 2, 64, "")
+	handler := captureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	do := func(path, body string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Body.String() != body {
+			t.Fatalf("expected the handler to see the request body, got %q", rr.Body.String())
+		}
+	}
+
+	do("/api/default/connectors", `{"name":"orders"}`)
+	if _, captured := buffer.list(0); len(captured) != 0 {
+		t.Fatalf("expected nothing to be captured while disabled, got %+v", captured)
+	}
+
+	buffer.setEnabled(true)
+	do("/api/default/connectors", `{"name":"orders","config":{"connection.password":"hunter2"}}`)
+	do("/api/default/connectors/orders/config", `{"topics":"`+strings.Repeat("x", 100)+`"}`)
+	do("/api/default/connectors/billing/config", `{"topics":"billing"}`)
+	do("/api/debug/captures", `{}`)
+
+	status, captured := buffer.list(0)
+	if status.Captured != 2 || len(captured) != 2 {
+		t.Fatalf("expected the ring buffer to keep the last 2 exchanges, got %+v", captured)
+	}
+	if newest := captured[0]; newest.Path != "/api/default/connectors/billing/config" || newest.Status != http.StatusCreated || newest.ResponseType != "application/json" {
+		t.Fatalf("unexpected newest exchange %+v", newest)
+	}
+	if truncated := captured[1]; !truncated.RequestTruncated || !truncated.ResponseTruncated || len(truncated.ResponseBody) != 64 {
+		t.Fatalf("expected bodies to be cut at the limit, got %+v", truncated)
+	}
+
+	buffer.clear()
+	do("/api/default/connectors", `{"name":"orders","config":{"connection.password":"hunter2"}}`)
+	if _, captured := buffer.list(1); strings.Contains(captured[0].RequestBody, "hunter2") || strings.Contains(captured[0].ResponseBody, "hunter2") {
+		t.Fatalf("expected captured bodies to be redacted, got %+v", captured[0])
+	}
+}
+
+func TestCaptureWriterKeepsOnlyTheLimit(t *testing.T) {
+	rr := httptest.NewRecorder()
+	recorder := &captureWriter{ResponseWriter: rr, limit: 64}
+	chunk := strings.Repeat("x", 40)
+	for i := 0; i < 1000; i++ {
+		recorder.Write([]byte(chunk))
+	}
+	if recorder.body.Len() != 64 || !recorder.truncated {
+		t.Fatalf("expected 64 bytes to be kept and the body marked truncated, kept %d (truncated %t)", recorder.body.Len(), recorder.truncated)
+	}
+	if rr.Body.Len() != 40000 {
+		t.Fatalf("expected the client to get the whole body, got %d bytes", rr.Body.Len())
+	}
+
+	buffer := withTestCapture(t, 1, 64, "")
+	buffer.setEnabled(true)
+	handler := captureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 1000; i++ {
+			w.Write([]byte(chunk))
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/default/connectors", nil))
+	if _, captured := buffer.list(0); len(captured) != 1 || captured[0].ResponseBody != chunk+chunk[:24] || !captured[0].ResponseTruncated {
+		t.Fatalf("expected the response to be cut at the limit, got %+v", captured)
+	}
+}
+
+func TestDebugCaptureHandlers(t *testing.T) {
+	buffer := withTestCapture(t, 10, 1024, "")
+	logger := withTestAuditLog(t, 10)
+
+	toggle := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/debug/capture", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		debugCaptureHandler(rr, req)
+		return rr
+	}
+
+	if rr := toggle("anything", `{"enabled":true}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without DEBUG_CAPTURE_TOKEN, got %d", rr.Code)
+	}
+	debugCaptureToken = "debug-token"
+	if rr := toggle("wrong", `{"enabled":true}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong token, got %d", rr.Code)
+	}
+	if rr := toggle("debug-token", `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without enabled, got %d", rr.Code)
+	}
+	if rr := toggle("debug-token", `{"enabled":true}`); rr.Code != http.StatusOK || !buffer.isEnabled() {
+		t.Fatalf("expected capture to be enabled, got %d", rr.Code)
+	}
+	if entries := logger.Query(AuditFilter{}); len(entries) != 1 || entries[0].Action != auditActionAdmin {
+		t.Fatalf("expected the toggle to be audited, got %+v", entries)
+	}
+
+	buffer.add(CapturedExchange{Method: http.MethodGet, Path: "/api/default/summary", Status: http.StatusBadGateway})
+	req := httptest.NewRequest(http.MethodGet, "/api/debug/captures?limit=5", nil)
+	req.Header.Set("Authorization", "Bearer debug-token")
+	rr := httptest.NewRecorder()
+	debugCapturesHandler(rr, req)
+	var list CaptureList
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !list.Enabled || list.Captured != 1 || len(list.Captures) != 1 || list.Captures[0].ID != "1" {
+		t.Fatalf("unexpected captures %+v", list)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// connectClustersSpec maps additional {cluster} names to Kafka Connect URLs, e.g.
+	// "dr=http://connect-dr:8083". CONSOLE_CLUSTER_NAME uses KAFKA_CONNECT_URL; other
+	// names are unknown and answered with 404.
+	connectClustersSpec = getEnv("KAFKA_CONNECT_CLUSTERS", "")
+	// connectWorkersSpec lists the REST URLs of the individual workers of a cluster, e.g.
+	// "default=http://connect-1:8083|http://connect-2:8083", for per-worker reports.
+	connectWorkersSpec = getEnv("KAFKA_CONNECT_WORKERS", "")
+
+	clusterURLs    = map[string]string{}
+	clusterWorkers = map[string][]string{}
+)
+
+// parsePairs parses "key=value,key=value" lists.
+func parsePairs(name, spec string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range splitList(spec) {
+		key, value, ok := strings.Cut(item, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, &configError{name: name, value: item}
+		}
+		if _, dup := pairs[key]; dup {
+			return nil, fmt.Errorf("%s: cluster %q listed twice", name, key)
+		}
+		pairs[key] = value
+	}
+	return pairs, nil
+}
+
+// parseClusterURLs parses KAFKA_CONNECT_CLUSTERS.
+func parseClusterURLs(spec string) (map[string]string, error) {
+	return parsePairs("KAFKA_CONNECT_CLUSTERS", spec)
+}
+
+// parseClusterWorkers parses KAFKA_CONNECT_WORKERS, whose values are "|"-separated URLs.
+func parseClusterWorkers(spec string) (map[string][]string, error) {
+	pairs, err := parsePairs("KAFKA_CONNECT_WORKERS", spec)
+	if err != nil {
+		return nil, err
+	}
+	workers := make(map[string][]string, len(pairs))
+	for cluster, value := range pairs {
+		for _, u := range strings.Split(value, "|") {
+			if u = strings.TrimSpace(u); u != "" {
+				workers[cluster] = append(workers[cluster], u)
+			}
+		}
+	}
+	return workers, nil
+}
+
+// knownCluster reports whether cluster is the console's own cluster or one listed in
+// KAFKA_CONNECT_CLUSTERS.
+func knownCluster(cluster string) bool {
+	_, ok := clusterURLs[cluster]
+	return ok || cluster == alertMetadataCluster
+}
+
+// clusterGuard answers requests for {cluster} names the proxy does not know with 404,
+// rather than sending them to the default cluster.
+func clusterGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cluster, ok := mux.Vars(r)["cluster"]; ok && !knownCluster(cluster) {
+			writeJSONError(w, http.StatusNotFound, "unknown_cluster", fmt.Sprintf("cluster %s is not configured", cluster))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// connectURLFor returns the Kafka Connect URL serving the given {cluster} name.
+func connectURLFor(cluster string) string {
+	return lookupClusterURL(clusterURLs, cluster)
+}
+
+func lookupClusterURL(urls map[string]string, cluster string) string {
+	if u, ok := urls[cluster]; ok {
+		return u
+	}
+	return connectURL
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestClusterURLs(t *testing.T, urls map[string]string) {
+	t.Helper()
+	original := clusterURLs
+	clusterURLs = urls
+	t.Cleanup(func() { clusterURLs = original })
+}
+
+func TestParseClusterURLs(t *testing.T) {
+	urls, err := parseClusterURLs(" dr = http://connect-dr:8083 , staging=http://staging:8083")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if urls["dr"] != "http://connect-dr:8083" || urls["staging"] != "http://staging:8083" {
+		t.Fatalf("unexpected clusters: %v", urls)
+	}
+
+	for _, spec := range []string{"dr", "=http://x", "dr=", "dr=http://a,dr=http://b"} {
+		if _, err := parseClusterURLs(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestBuildProxyURLUsesClusterURL(t *testing.T) {
+	withTestClusterURLs(t, map[string]string{"dr": "http://connect-dr:8083/base"})
+
+	req := httptest.NewRequest("GET", "/api/dr/connectors/alpha/status?expand=info", nil)
+	target, err := buildProxyURL(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := target.String(); got != "http://connect-dr:8083/base/connectors/alpha/status?expand=info" {
+		t.Fatalf("unexpected target %s", got)
+	}
+}
+
+func TestClusterGuardRejectsUnknownClusters(t *testing.T) {
+	withTestClusterURLs(t, map[string]string{"dr": "http://connect-dr:8083"})
+
+	router := mux.NewRouter()
+	router.Use(clusterGuard)
+	router.HandleFunc("/api/{cluster}/connectors", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for path, want := range map[string]int{
+		"/api/" + alertMetadataCluster + "/connectors": http.StatusNoContent,
+		"/api/dr/connectors":                           http.StatusNoContent,
+		"/api/typo/connectors":                         http.StatusNotFound,
+		"/api/health":                                  http.StatusNoContent,
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", path, want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestParseClusterWorkers(t *testing.T) {
+	workers, err := parseClusterWorkers("default=http://connect-1:8083| http://connect-2:8083 ,dr=http://connect-dr:8083")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(workers["default"]) != 2 || workers["default"][1] != "http://connect-2:8083" || len(workers["dr"]) != 1 {
+		t.Fatalf("unexpected workers: %v", workers)
+	}
+	if _, err := parseClusterWorkers("default"); err == nil {
+		t.Fatal("expected error for a cluster without workers")
+	}
+}
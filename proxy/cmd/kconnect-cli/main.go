@@ -0,0 +1,528 @@
+// Command kconnect-cli scripts the console proxy from a shell or a CI job. It talks to
+// the proxy, not to Kafka Connect, so redaction, admission policies, tenancy and the
+// audit log apply to it as they do to the web UI, and it shares the proxy's Connect
+// client from internal/connectclient.
+//
+//	kconnect-cli list
+//	kconnect-cli -cluster prod status orders-sink
+//	kconnect-cli export -o connectors.json
+//	KCONNECT_DEPLOY_SECRET=... kconnect-cli apply -f connectors.json -dry-run
+//	kconnect-cli restart -include-tasks -only-failed orders-sink
+//
+// Global flags default to KCONNECT_URL, KCONNECT_CLUSTER, KCONNECT_USER and
+// KCONNECT_PASSWORD. With a user and password it signs in with the proxy's local login
+// first. The exit status is 0 on success, 1 on errors, 2 on usage errors and 3 when
+// status finds a failed connector or task, or apply is refused or fails for a connector.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mcnabb998/kconnect-console/proxy/internal/connectclient"
+)
+
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitUnhealthy = 3
+)
+
+// redactionPlaceholder is the proxy's default replacement of sensitive values.
+const redactionPlaceholder = "***REDACTED***"
+
+// errUnhealthy makes a command exit with exitUnhealthy after printing its report.
+var errUnhealthy = errors.New("unhealthy")
+
+const usage = `usage: kconnect-cli [flags] <command> [command flags] [args]
+
+commands:
+  list                 connectors with their state and task counts
+  status <name>        connector and task states, with traces of failed ones
+  export [-o file]     connector configs as a deployment for apply
+  apply -f file        apply a deployment through POST /api/{cluster}/deploy
+  restart <name>       restart a connector, optionally with its tasks
+
+flags:
+`
+
+// headerList collects repeated -header "Name: value" flags.
+type headerList []string
+
+func (h *headerList) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerList) Set(value string) error {
+	if name, _, ok := strings.Cut(value, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q must be Name: value", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// cli is a parsed invocation.
+type cli struct {
+	proxyURL string
+	cluster  string
+	user     string
+	password string
+	headers  headerList
+	timeout  time.Duration
+	output   string
+	client   *http.Client
+	stdout   io.Writer
+	stderr   io.Writer
+	getenv   func(string) string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
+}
+
+func run(args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	c := &cli{stdout: stdout, stderr: stderr, getenv: getenv}
+	flags := flag.NewFlagSet("kconnect-cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&c.proxyURL, "url", envOr(getenv, "KCONNECT_URL", "http://localhost:8080"), "URL of the console proxy")
+	flags.StringVar(&c.cluster, "cluster", envOr(getenv, "KCONNECT_CLUSTER", "default"), "cluster to work on")
+	flags.StringVar(&c.user, "user", getenv("KCONNECT_USER"), "local user to sign in as")
+	flags.StringVar(&c.password, "password", getenv("KCONNECT_PASSWORD"), "password of -user")
+	flags.Var(&c.headers, "header", `extra request header such as "Authorization: Bearer ..." for an authenticating proxy; repeatable`)
+	flags.DurationVar(&c.timeout, "timeout", 30*time.Second, "timeout of each request")
+	flags.StringVar(&c.output, "o", "table", "output of list and status: table or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 || (c.output != "table" && c.output != "json") {
+		flags.Usage()
+		return exitUsage
+	}
+
+	commands := map[string]func(ctx context.Context, args []string) error{
+		"list":    c.list,
+		"status":  c.status,
+		"export":  c.export,
+		"apply":   c.apply,
+		"restart": c.restart,
+	}
+	command, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "kconnect-cli: unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	if err := c.connect(ctx); err != nil {
+		fmt.Fprintf(stderr, "kconnect-cli: %v\n", err)
+		return exitError
+	}
+	err := command(ctx, flags.Args()[1:])
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUnhealthy):
+		return exitUnhealthy
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "kconnect-cli %s: %v\n", flags.Arg(0), err)
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "kconnect-cli %s: %v\n", flags.Arg(0), err)
+		return exitError
+	}
+}
+
+// usageError reports bad command arguments.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func envOr(getenv func(string) string, name, fallback string) string {
+	if value := getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// headerTransport adds the -header values to every request.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+// connect builds the HTTP client and signs in when a user is given.
+func (c *cli) connect(ctx context.Context) error {
+	headers := http.Header{}
+	for _, header := range c.headers {
+		name, value, _ := strings.Cut(header, ":")
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	c.client = &http.Client{Timeout: c.timeout, Jar: jar, Transport: &headerTransport{base: http.DefaultTransport, headers: headers}}
+	if c.user == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{"username": c.user, "password": c.password})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, connectclient.JoinURL(c.proxyURL, "auth", "local", "login"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sign in: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sign in as %s: %s", c.user, apiError(resp))
+	}
+	return nil
+}
+
+// baseURL is where the proxy serves the Connect REST API of the cluster.
+func (c *cli) baseURL() string {
+	return connectclient.JoinURL(c.proxyURL, "api", c.cluster)
+}
+
+// apiError describes a failed proxy answer, using the code and message of its JSON
+// error body.
+func apiError(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var payload struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error != "" && payload.Message != "" {
+		return fmt.Sprintf("HTTP %d %s: %s", resp.StatusCode, payload.Error, payload.Message)
+	}
+	return fmt.Sprintf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func (c *cli) writeJSON(value interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// listRow is one connector of the list command.
+type listRow struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	State      string         `json:"state"`
+	Worker     string         `json:"worker"`
+	TaskStates map[string]int `json:"taskStates"`
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError("list takes no arguments")
+	}
+	expanded, err := connectclient.Expanded(ctx, c.client, c.baseURL())
+	if err != nil {
+		return err
+	}
+
+	rows := make([]listRow, 0, len(expanded))
+	statuses := make([]connectclient.ConnectorStatus, 0, len(expanded))
+	for name, connector := range expanded {
+		row := listRow{
+			Name:       name,
+			Type:       connector.Status.Type,
+			State:      connectclient.NormalizeState(connector.Status.Connector.State),
+			Worker:     connector.Status.Connector.WorkerID,
+			TaskStates: map[string]int{},
+		}
+		if row.Type == "" {
+			row.Type = connector.Info.Type
+		}
+		for _, task := range connector.Status.Tasks {
+			row.TaskStates[connectclient.NormalizeState(task.State)]++
+		}
+		rows = append(rows, row)
+		statuses = append(statuses, connector.Status)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	summary := connectclient.Summarize(statuses)
+
+	if c.output == "json" {
+		return c.writeJSON(struct {
+			Connectors []listRow             `json:"connectors"`
+			Summary    connectclient.Summary `json:"summary"`
+		}{rows, summary})
+	}
+	table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tTYPE\tSTATE\tTASKS\tWORKER")
+	for _, row := range rows {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", row.Name, row.Type, row.State, formatCounts(row.TaskStates), row.Worker)
+	}
+	table.Flush()
+	fmt.Fprintf(c.stdout, "\n%d connectors: %s\n", summary.TotalConnectors, formatCounts(summary.ConnectorStates))
+	return nil
+}
+
+// formatCounts renders the non-zero counts as "2 running, 1 failed", in state order.
+func formatCounts(counts map[string]int) string {
+	var parts []string
+	for _, state := range []string{"running", "paused", "stopped", "failed", "unassigned", "unknown"} {
+		if counts[state] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[state], state))
+		}
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (c *cli) status(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("status takes one connector name")
+	}
+	status, err := connectclient.Status(ctx, c.client, c.baseURL(), args[0])
+	if errors.Is(err, connectclient.ErrConnectorNotFound) {
+		return fmt.Errorf("connector %q does not exist", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	failed := connectclient.NormalizeState(status.Connector.State) == "failed"
+	for _, task := range status.Tasks {
+		failed = failed || connectclient.NormalizeState(task.State) == "failed"
+	}
+	if c.output == "json" {
+		if err := c.writeJSON(status); err != nil {
+			return err
+		}
+	} else {
+		table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "INSTANCE\tSTATE\tWORKER")
+		fmt.Fprintf(table, "%s\t%s\t%s\n", status.Name, status.Connector.State, status.Connector.WorkerID)
+		for _, task := range status.Tasks {
+			fmt.Fprintf(table, "task %d\t%s\t%s\n", task.ID, task.State, task.WorkerID)
+		}
+		table.Flush()
+		if status.Connector.Trace != "" {
+			fmt.Fprintf(c.stdout, "\n%s:\n%s\n", status.Name, status.Connector.Trace)
+		}
+		for _, task := range status.Tasks {
+			if task.Trace != "" {
+				fmt.Fprintf(c.stdout, "\ntask %d:\n%s\n", task.ID, task.Trace)
+			}
+		}
+	}
+	if failed {
+		return errUnhealthy
+	}
+	return nil
+}
+
+func (c *cli) export(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	output := flags.String("o", "", "file to write the deployment to instead of stdout")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return usageError("usage: export [-o file]")
+	}
+	expanded, err := connectclient.Expanded(ctx, c.client, c.baseURL())
+	if err != nil {
+		return err
+	}
+
+	deployment := connectclient.Export(expanded)
+	for _, connector := range deployment.Connectors {
+		if keys := redactedKeys(connector.Config); len(keys) > 0 {
+			fmt.Fprintf(c.stderr, "warning: %s: %s redacted by the proxy; replace with secret placeholders before applying\n", connector.Name, strings.Join(keys, ", "))
+		}
+	}
+	body, err := json.MarshalIndent(deployment, "", "  ")
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+	if *output == "" {
+		_, err = c.stdout.Write(body)
+		return err
+	}
+	return os.WriteFile(*output, body, 0o600)
+}
+
+// redactedKeys lists the keys whose value the proxy replaced with its placeholder.
+func redactedKeys(config map[string]interface{}) []string {
+	var keys []string
+	for key, value := range config {
+		if text, ok := value.(string); ok && strings.Contains(text, redactionPlaceholder) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deployResult is the part of the proxy's deploy report the CLI prints.
+type deployResult struct {
+	DryRun     bool           `json:"dryRun"`
+	Actions    map[string]int `json:"actions"`
+	Failed     int            `json:"failed"`
+	Connectors []struct {
+		Name       string `json:"name"`
+		Action     string `json:"action"`
+		Applied    bool   `json:"applied"`
+		Error      string `json:"error"`
+		Validation *struct {
+			Errors map[string][]string `json:"errors"`
+		} `json:"validation"`
+	} `json:"connectors"`
+}
+
+func (c *cli) apply(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	file := flags.String("f", "", "deployment file, as written by export; - reads stdin")
+	dryRun := flags.Bool("dry-run", false, "plan and validate without changing anything")
+	prune := flags.Bool("prune", false, "delete connectors the deployment does not list")
+	revision := flags.String("revision", c.getenv("KCONNECT_REVISION"), "revision recorded with the deployment, e.g. a commit SHA")
+	secret := flags.String("secret", c.getenv("KCONNECT_DEPLOY_SECRET"), "the proxy's DEPLOY_WEBHOOK_SECRET")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || *file == "" {
+		return usageError("usage: apply -f file [-dry-run] [-prune] [-revision rev]")
+	}
+	if *secret == "" {
+		return usageError("the deploy secret is required (-secret or KCONNECT_DEPLOY_SECRET)")
+	}
+
+	var raw []byte
+	var err error
+	if *file == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+	var deployment connectclient.Deployment
+	if err := json.Unmarshal(raw, &deployment); err != nil {
+		return fmt.Errorf("%s is not a deployment: %v", *file, err)
+	}
+	for _, connector := range deployment.Connectors {
+		if keys := redactedKeys(connector.Config); len(keys) > 0 {
+			return fmt.Errorf("%s: %s still hold the redaction placeholder", connector.Name, strings.Join(keys, ", "))
+		}
+	}
+	deployment.Prune = deployment.Prune || *prune
+	if *revision != "" {
+		deployment.Revision = *revision
+	}
+
+	body, err := json.Marshal(deployment)
+	if err != nil {
+		return err
+	}
+	target := connectclient.JoinURL(c.baseURL(), "deploy")
+	if *dryRun {
+		target += "?dryRun=true"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(connectclient.TimestampHeader, timestamp)
+	req.Header.Set(connectclient.SignatureHeader, connectclient.Sign(*secret, timestamp, *dryRun, body))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result deployResult
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusUnprocessableEntity, http.StatusBadGateway:
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("decode deploy report: %w", err)
+		}
+	default:
+		return errors.New(apiError(resp))
+	}
+
+	table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "CONNECTOR\tACTION\tAPPLIED\tERROR")
+	for _, connector := range result.Connectors {
+		message := connector.Error
+		if connector.Validation != nil {
+			var fields []string
+			for key, errs := range connector.Validation.Errors {
+				fields = append(fields, key+": "+strings.Join(errs, "; "))
+			}
+			sort.Strings(fields)
+			message = strings.Join(append([]string{message}, fields...), " ")
+		}
+		fmt.Fprintf(table, "%s\t%s\t%t\t%s\n", connector.Name, connector.Action, connector.Applied, strings.TrimSpace(message))
+	}
+	table.Flush()
+	if result.DryRun {
+		fmt.Fprintln(c.stdout, "\ndry run: nothing was changed")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errUnhealthy
+	}
+	return nil
+}
+
+func (c *cli) restart(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("restart", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	includeTasks := flags.Bool("include-tasks", false, "restart the tasks as well")
+	onlyFailed := flags.Bool("only-failed", false, "restart only failed instances")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return usageError("usage: restart [-include-tasks] [-only-failed] <name>")
+	}
+	name := flags.Arg(0)
+	status, err := connectclient.Restart(ctx, c.client, c.baseURL(), name, *includeTasks, *onlyFailed)
+	if errors.Is(err, connectclient.ErrConnectorNotFound) {
+		return fmt.Errorf("connector %q does not exist", name)
+	}
+	if err != nil {
+		return err
+	}
+	if status == http.StatusAccepted {
+		fmt.Fprintf(c.stdout, "restart of %s accepted; check progress with: kconnect-cli status %s\n", name, name)
+		return nil
+	}
+	fmt.Fprintf(c.stdout, "restarted %s\n", name)
+	return nil
+}
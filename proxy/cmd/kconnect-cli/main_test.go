@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mcnabb998/kconnect-console/proxy/internal/connectclient"
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+func newProxyServer() *testutils.ConnectServer {
+	running := map[string]interface{}{"state": "RUNNING", "worker_id": "connect-1:8083"}
+	return testutils.NewConnectServer(map[string]testutils.Response{
+		"POST /auth/local/login": {Body: map[string]string{"username": "ci"}},
+		"GET /api/prod/connectors": {Body: map[string]interface{}{
+			"orders-sink": map[string]interface{}{
+				"info":   map[string]interface{}{"type": "sink", "config": map[string]string{"name": "orders-sink", "connector.class": "JdbcSink", "connection.password": "***REDACTED***"}},
+				"status": map[string]interface{}{"name": "orders-sink", "type": "sink", "connector": running, "tasks": []interface{}{map[string]interface{}{"id": 0, "state": "FAILED"}}},
+			},
+			"billing-source": map[string]interface{}{
+				"info":   map[string]interface{}{"type": "source", "config": map[string]string{"name": "billing-source", "connector.class": "JdbcSource"}},
+				"status": map[string]interface{}{"name": "billing-source", "type": "source", "connector": running, "tasks": []interface{}{map[string]interface{}{"id": 0, "state": "RUNNING"}}},
+			},
+		}},
+		"GET /api/prod/connectors/orders-sink/status": {Body: map[string]interface{}{
+			"name": "orders-sink", "connector": running,
+			"tasks": []interface{}{map[string]interface{}{"id": 0, "state": "FAILED", "trace": "java.sql.SQLException: timeout"}},
+		}},
+		"POST /api/prod/connectors/orders-sink/restart": {Status: http.StatusAccepted},
+		"POST /api/prod/deploy": {Body: map[string]interface{}{
+			"dryRun": true, "actions": map[string]int{"update": 1},
+			"connectors": []interface{}{map[string]interface{}{"name": "billing-source", "action": "update"}},
+		}},
+	})
+}
+
+func runCLI(t *testing.T, env map[string]string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr, func(name string) string { return env[name] })
+	return code, stdout.String(), stderr.String()
+}
+
+func TestListAndStatus(t *testing.T) {
+	server := newProxyServer()
+	defer server.Close()
+	env := map[string]string{"KCONNECT_URL": server.URL(), "KCONNECT_CLUSTER": "prod", "KCONNECT_USER": "ci"}
+
+	code, out, _ := runCLI(t, env, "list")
+	if code != exitOK || !strings.Contains(out, "billing-source") || strings.Index(out, "billing-source") > strings.Index(out, "orders-sink") {
+		t.Fatalf("expected a sorted table, got %d:\n%s", code, out)
+	}
+	if !strings.Contains(out, "1 failed") || !strings.Contains(out, "2 connectors: 2 running") {
+		t.Fatalf("expected task counts and a summary, got:\n%s", out)
+	}
+	if requests := server.Requests(); requests[0].Path != "/auth/local/login" {
+		t.Fatalf("expected a local login first, got %s", requests[0].Path)
+	}
+
+	code, out, _ = runCLI(t, env, "-o", "json", "list")
+	var listed struct {
+		Summary connectclient.Summary `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(out), &listed); err != nil || code != exitOK || listed.Summary.Totals["failed"] != 1 {
+		t.Fatalf("unexpected JSON list %d %s", code, out)
+	}
+
+	code, out, _ = runCLI(t, env, "status", "orders-sink")
+	if code != exitUnhealthy || !strings.Contains(out, "java.sql.SQLException") {
+		t.Fatalf("expected a failed task to exit with %d and show its trace, got %d:\n%s", exitUnhealthy, code, out)
+	}
+	if code, _, stderr := runCLI(t, env, "status", "missing"); code != exitError || !strings.Contains(stderr, `"missing" does not exist`) {
+		t.Fatalf("expected an error for a missing connector, got %d %s", code, stderr)
+	}
+	if code, out, _ := runCLI(t, env, "restart", "-include-tasks", "orders-sink"); code != exitOK || !strings.Contains(out, "accepted") {
+		t.Fatalf("unexpected restart result %d %s", code, out)
+	}
+	if code, _, _ := runCLI(t, env, "frobnicate"); code != exitUsage {
+		t.Fatalf("expected a usage error, got %d", code)
+	}
+}
+
+func TestExportAndApply(t *testing.T) {
+	server := newProxyServer()
+	defer server.Close()
+	env := map[string]string{"KCONNECT_URL": server.URL(), "KCONNECT_CLUSTER": "prod"}
+
+	file := filepath.Join(t.TempDir(), "connectors.json")
+	code, _, stderr := runCLI(t, env, "export", "-o", file)
+	if code != exitOK || !strings.Contains(stderr, "orders-sink: connection.password redacted") {
+		t.Fatalf("expected a warning about redacted values, got %d %s", code, stderr)
+	}
+	if code, _, stderr := runCLI(t, env, "apply", "-f", file, "-secret", "s3cret"); code != exitError || !strings.Contains(stderr, "redaction placeholder") {
+		t.Fatalf("expected redacted configs to be refused, got %d %s", code, stderr)
+	}
+
+	var deployment connectclient.Deployment
+	raw, _ := os.ReadFile(file)
+	if err := json.Unmarshal(raw, &deployment); err != nil || len(deployment.Connectors) != 2 {
+		t.Fatalf("unexpected export %s", raw)
+	}
+	deployment.Connectors = deployment.Connectors[:1]
+	raw, _ = json.Marshal(deployment)
+	os.WriteFile(file, raw, 0o600)
+
+	if code, _, _ := runCLI(t, env, "apply", "-f", file); code != exitUsage {
+		t.Fatalf("expected the deploy secret to be required, got %d", code)
+	}
+	env["KCONNECT_DEPLOY_SECRET"] = "s3cret"
+	code, out, stderr := runCLI(t, env, "apply", "-f", file, "-dry-run", "-revision", "abc123")
+	if code != exitOK || !strings.Contains(out, "billing-source") || !strings.Contains(out, "dry run") {
+		t.Fatalf("unexpected apply result %d %s %s", code, out, stderr)
+	}
+	requests := server.Requests()
+	deploy := requests[len(requests)-1]
+	if deploy.Header.Get(connectclient.SignatureHeader) != connectclient.Sign("s3cret", deploy.Header.Get(connectclient.TimestampHeader), true, deploy.Body) || !strings.Contains(string(deploy.Body), `"revision":"abc123"`) {
+		t.Fatalf("expected a signed deployment with the revision, got %s %s", deploy.Header.Get(connectclient.SignatureHeader), deploy.Body)
+	}
+}
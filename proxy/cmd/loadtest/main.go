@@ -0,0 +1,500 @@
+// Command loadtest drives a configurable request mix against the proxy while the proxy
+// talks to a simulated Kafka Connect cluster, then reports latency percentiles per
+// request type and the proxy's memory usage.
+//
+// By default it builds the proxy from -proxy-dir, starts it against an in-process mock
+// Connect cluster and stops it afterwards. Environment variables are passed through, so
+// e.g. CONFIG_CACHE_TTL=0 go run ./cmd/loadtest compares runs without the cache.
+//
+//	go run ./cmd/loadtest -connectors 5000 -concurrency 64 -duration 1m
+//	go run ./cmd/loadtest -target http://localhost:8080 -pid 4242
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+type config struct {
+	connectors     int
+	tasks          int
+	failedRatio    float64
+	connectLatency time.Duration
+	duration       time.Duration
+	concurrency    int
+	mix            string
+	cluster        string
+	target         string
+	proxyDir       string
+	pid            int
+	maxP99         time.Duration
+}
+
+// operation is one kind of request in the mix.
+type operation struct {
+	name   string
+	weight int
+	path   func(rng *rand.Rand) string
+}
+
+// result holds the latencies and failures observed for one operation.
+type result struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func main() {
+	var cfg config
+	flag.IntVar(&cfg.connectors, "connectors", 1000, "connectors in the simulated Connect cluster")
+	flag.IntVar(&cfg.tasks, "tasks", 2, "tasks per simulated connector")
+	flag.Float64Var(&cfg.failedRatio, "failed", 0.05, "fraction of simulated connectors in the FAILED state")
+	flag.DurationVar(&cfg.connectLatency, "connect-latency", 5*time.Millisecond, "latency added to every simulated Connect response")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "how long to generate load")
+	flag.IntVar(&cfg.concurrency, "concurrency", 32, "concurrent clients")
+	flag.StringVar(&cfg.mix, "mix", "list=30,status=30,config=20,summary=10,plugins=5,audit=5", "request mix as name=weight pairs")
+	flag.StringVar(&cfg.cluster, "cluster", "default", "{cluster} path segment used in requests")
+	flag.StringVar(&cfg.target, "target", "", "URL of an already running proxy; when empty the proxy is built and started")
+	flag.StringVar(&cfg.proxyDir, "proxy-dir", ".", "proxy source directory used when -target is empty")
+	flag.IntVar(&cfg.pid, "pid", 0, "PID of the proxy given by -target, for memory sampling")
+	flag.DurationVar(&cfg.maxP99, "max-p99", 0, "exit with status 1 when the overall p99 latency exceeds this")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		log.Fatalf("loadtest: %v", err)
+	}
+}
+
+func run(cfg config) error {
+	if cfg.connectors <= 0 || cfg.concurrency <= 0 || cfg.duration <= 0 {
+		return errors.New("-connectors, -concurrency and -duration must be positive")
+	}
+
+	names := connectorNames(cfg.connectors)
+	ops, err := parseMix(cfg.mix, cfg.cluster, names)
+	if err != nil {
+		return err
+	}
+
+	connect := testutils.NewConnectServerWithOptions(simulatedCluster(names, cfg.tasks, cfg.failedRatio), testutils.ConnectServerOptions{
+		Latency:          cfg.connectLatency,
+		DisableRecording: true,
+	})
+	defer connect.Close()
+	log.Printf("simulated Kafka Connect with %d connectors at %s", len(names), connect.URL())
+
+	target, pid := cfg.target, cfg.pid
+	if target == "" {
+		proxy, url, err := startProxy(cfg.proxyDir, connect.URL())
+		if err != nil {
+			return err
+		}
+		defer func() {
+			proxy.Process.Signal(os.Interrupt)
+			proxy.Wait()
+			os.RemoveAll(filepath.Dir(proxy.Path))
+		}()
+		target, pid = url, proxy.Process.Pid
+	}
+
+	memory := newMemorySampler(pid)
+	stopSampling := memory.start(500 * time.Millisecond)
+
+	log.Printf("running %s of load with %d clients against %s", cfg.duration, cfg.concurrency, target)
+	results, elapsed := generateLoad(target, ops, cfg.concurrency, cfg.duration)
+	stopSampling()
+
+	overallP99 := report(os.Stdout, ops, results, elapsed, memory)
+	if cfg.maxP99 > 0 && overallP99 > cfg.maxP99 {
+		return fmt.Errorf("p99 latency %s exceeds -max-p99 %s", overallP99, cfg.maxP99)
+	}
+	return nil
+}
+
+func connectorNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("loadtest-connector-%05d", i)
+	}
+	return names
+}
+
+// simulatedCluster returns mock Connect responses for the given connectors.
+func simulatedCluster(names []string, tasks int, failedRatio float64) map[string]testutils.Response {
+	responses := map[string]testutils.Response{
+		"GET /":           {Body: map[string]string{"version": "3.7.0", "commit": "loadtest", "kafka_cluster_id": "loadtest-cluster"}},
+		"GET /connectors": {Body: names},
+		"GET /connector-plugins": {Body: []map[string]string{
+			{"class": "io.confluent.connect.jdbc.JdbcSourceConnector", "type": "source", "version": "10.7.4"},
+			{"class": "io.confluent.connect.s3.S3SinkConnector", "type": "sink", "version": "10.5.7"},
+			{"class": "io.debezium.connector.postgresql.PostgresConnector", "type": "source", "version": "2.5.0"},
+		}},
+	}
+
+	failedEvery := 0
+	if failedRatio > 0 {
+		failedEvery = int(math.Max(1, math.Round(1/failedRatio)))
+	}
+
+	for i, name := range names {
+		state := "RUNNING"
+		if failedEvery > 0 && i%failedEvery == 0 {
+			state = "FAILED"
+		}
+		connectorType := "sink"
+		if i%2 == 0 {
+			connectorType = "source"
+		}
+
+		taskStates := make([]map[string]interface{}, tasks)
+		taskIDs := make([]map[string]interface{}, tasks)
+		for id := range taskStates {
+			taskStates[id] = map[string]interface{}{"id": id, "state": state, "worker_id": fmt.Sprintf("worker-%d:8083", id%3)}
+			taskIDs[id] = map[string]interface{}{"connector": name, "task": id}
+		}
+
+		config := map[string]string{
+			"name":                name,
+			"connector.class":     "io.confluent.connect.jdbc.JdbcSourceConnector",
+			"tasks.max":           strconv.Itoa(tasks),
+			"connection.url":      "jdbc:postgresql://db:5432/app",
+			"connection.password": "loadtest-secret",
+			"topic.prefix":        name + "-",
+		}
+		if connectorType == "sink" {
+			config["connector.class"] = "io.confluent.connect.s3.S3SinkConnector"
+			config["topics"] = name
+		}
+
+		base := "/connectors/" + name
+		responses["GET "+base] = testutils.Response{Body: map[string]interface{}{"name": name, "config": config, "tasks": taskIDs, "type": connectorType}}
+		responses["GET "+base+"/config"] = testutils.Response{Body: config}
+		responses["GET "+base+"/status"] = testutils.Response{Body: map[string]interface{}{
+			"name":      name,
+			"connector": map[string]string{"state": state, "worker_id": "worker-0:8083"},
+			"tasks":     taskStates,
+			"type":      connectorType,
+		}}
+	}
+	return responses
+}
+
+// parseMix parses "name=weight,..." into operations against the given cluster.
+func parseMix(spec, cluster string, names []string) ([]operation, error) {
+	randomConnector := func(rng *rand.Rand) string { return names[rng.Intn(len(names))] }
+	paths := map[string]func(rng *rand.Rand) string{
+		"list": func(*rand.Rand) string { return "/api/" + cluster + "/connectors" },
+		"status": func(rng *rand.Rand) string {
+			return "/api/" + cluster + "/connectors/" + randomConnector(rng) + "/status"
+		},
+		"config": func(rng *rand.Rand) string {
+			return "/api/" + cluster + "/connectors/" + randomConnector(rng) + "/config"
+		},
+		"info":    func(rng *rand.Rand) string { return "/api/" + cluster + "/connectors/" + randomConnector(rng) },
+		"summary": func(*rand.Rand) string { return "/api/" + cluster + "/monitoring/summary" },
+		"plugins": func(*rand.Rand) string { return "/api/" + cluster + "/connector-plugins" },
+		"audit":   func(*rand.Rand) string { return "/api/" + cluster + "/audit-logs" },
+		"health":  func(*rand.Rand) string { return "/health/ready" },
+	}
+
+	var ops []operation
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, weightText, ok := strings.Cut(item, "=")
+		path, known := paths[strings.TrimSpace(name)]
+		if !ok || !known {
+			return nil, fmt.Errorf("invalid mix entry %q (known operations: list, status, config, info, summary, plugins, audit, health)", item)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(weightText))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight in mix entry %q", item)
+		}
+		if weight > 0 {
+			ops = append(ops, operation{name: strings.TrimSpace(name), weight: weight, path: path})
+		}
+	}
+	if len(ops) == 0 {
+		return nil, errors.New("the request mix is empty")
+	}
+	return ops, nil
+}
+
+// pick chooses an operation with probability proportional to its weight.
+func pick(ops []operation, total int, rng *rand.Rand) int {
+	n := rng.Intn(total)
+	for i, op := range ops {
+		if n < op.weight {
+			return i
+		}
+		n -= op.weight
+	}
+	return len(ops) - 1
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// startProxy builds the proxy in dir and runs it against connectURL.
+func startProxy(dir, connectURL string) (*exec.Cmd, string, error) {
+	tmp, err := os.MkdirTemp("", "kconnect-loadtest")
+	if err != nil {
+		return nil, "", err
+	}
+	binary := filepath.Join(tmp, "proxy")
+
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = dir
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		os.RemoveAll(tmp)
+		return nil, "", fmt.Errorf("build proxy: %w", err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, "", err
+	}
+
+	proxy := exec.Command(binary)
+	proxy.Env = append(os.Environ(), "KAFKA_CONNECT_URL="+connectURL, "PORT="+strconv.Itoa(port))
+	proxy.Stdout, proxy.Stderr = io.Discard, io.Discard
+	if err := proxy.Start(); err != nil {
+		os.RemoveAll(tmp)
+		return nil, "", fmt.Errorf("start proxy: %w", err)
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d", port)
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		if resp, err := http.Get(url + "/health/live"); err == nil {
+			resp.Body.Close()
+			log.Printf("started proxy (pid %d) at %s", proxy.Process.Pid, url)
+			return proxy, url, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	proxy.Process.Kill()
+	proxy.Wait()
+	os.RemoveAll(tmp)
+	return nil, "", errors.New("proxy did not become live within 15s")
+}
+
+// generateLoad runs concurrency clients until duration has passed.
+func generateLoad(target string, ops []operation, concurrency int, duration time.Duration) ([]result, time.Duration) {
+	total := 0
+	for _, op := range ops {
+		total += op.weight
+	}
+
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConns: concurrency, MaxIdleConnsPerHost: concurrency},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	perWorker := make([][]result, concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w) + 1))
+			results := make([]result, len(ops))
+			for ctx.Err() == nil {
+				i := pick(ops, total, rng)
+				began := time.Now()
+				resp, err := client.Get(target + ops[i].path(rng))
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				if ctx.Err() != nil && err != nil {
+					break // interrupted by the end of the run
+				}
+				results[i].latencies = append(results[i].latencies, time.Since(began))
+				if err != nil || resp.StatusCode >= 400 {
+					results[i].errors++
+				}
+			}
+			perWorker[w] = results
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	merged := make([]result, len(ops))
+	for _, results := range perWorker {
+		for i, r := range results {
+			merged[i].latencies = append(merged[i].latencies, r.latencies...)
+			merged[i].errors += r.errors
+		}
+	}
+	return merged, elapsed
+}
+
+// percentile returns the p-th percentile (0..1) of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// report prints the results and returns the overall p99 latency.
+func report(out io.Writer, ops []operation, results []result, elapsed time.Duration, memory *memorySampler) time.Duration {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\trequests\terrors\treq/s\tp50\tp90\tp99\tmax\t")
+
+	var all []time.Duration
+	totalErrors := 0
+	row := func(name string, latencies []time.Duration, errors int) {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t\n", name, len(latencies), errors,
+			float64(len(latencies))/elapsed.Seconds(),
+			round(percentile(latencies, 0.50)), round(percentile(latencies, 0.90)),
+			round(percentile(latencies, 0.99)), round(percentile(latencies, 1)))
+	}
+	for i, op := range ops {
+		row(op.name, results[i].latencies, results[i].errors)
+		all = append(all, results[i].latencies...)
+		totalErrors += results[i].errors
+	}
+	row("total", all, totalErrors)
+	tw.Flush()
+
+	if peak, last, ok := memory.summary(); ok {
+		fmt.Fprintf(out, "\nproxy memory: peak RSS %s, final RSS %s\n", formatBytes(peak), formatBytes(last))
+	} else {
+		fmt.Fprintln(out, "\nproxy memory: not sampled (needs Linux and the proxy PID)")
+	}
+	return percentile(all, 0.99)
+}
+
+func round(d time.Duration) time.Duration {
+	if d >= time.Millisecond {
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+}
+
+// memorySampler polls the resident set size of a process from /proc.
+type memorySampler struct {
+	pid  int
+	mu   sync.Mutex
+	peak int64
+	last int64
+}
+
+func newMemorySampler(pid int) *memorySampler {
+	return &memorySampler{pid: pid}
+}
+
+func (m *memorySampler) start(interval time.Duration) (stop func()) {
+	if m.pid == 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			m.sample()
+			select {
+			case <-ticker.C:
+			case <-done:
+				m.sample()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func (m *memorySampler) sample() {
+	rss, err := readRSS(m.pid)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = rss
+	if rss > m.peak {
+		m.peak = rss
+	}
+}
+
+func (m *memorySampler) summary() (peak, last int64, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.peak, m.last, m.peak > 0
+}
+
+// readRSS returns VmRSS of pid in bytes.
+func readRSS(pid int) (int64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	return parseVmRSS(string(data))
+}
+
+func parseVmRSS(status string) (int64, error) {
+	for _, line := range strings.Split(status, "\n") {
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "VmRSS:"))
+		if len(fields) == 0 {
+			break
+		}
+		kb, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	return 0, errors.New("VmRSS not found")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseMix(t *testing.T) {
+	ops, err := parseMix("list=3, status=1,audit=0", "default", []string{"alpha"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ops) != 2 || ops[0].name != "list" || ops[1].name != "status" {
+		t.Fatalf("expected zero-weight entries to be dropped, got %+v", ops)
+	}
+	if got := ops[1].path(rand.New(rand.NewSource(1))); got != "/api/default/connectors/alpha/status" {
+		t.Fatalf("unexpected path %s", got)
+	}
+
+	for _, spec := range []string{"", "list", "unknown=1", "list=-1", "list=0"} {
+		if _, err := parseMix(spec, "default", []string{"alpha"}); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestPickFollowsWeights(t *testing.T) {
+	ops := []operation{{name: "a", weight: 3}, {name: "b", weight: 1}}
+	rng := rand.New(rand.NewSource(7))
+	counts := make([]int, 2)
+	for i := 0; i < 4000; i++ {
+		counts[pick(ops, 4, rng)]++
+	}
+	if counts[0] < 2700 || counts[0] > 3300 {
+		t.Fatalf("expected roughly 3:1 split, got %v", counts)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	if got := percentile(latencies, 0.5); got != 50*time.Millisecond {
+		t.Fatalf("p50 = %s", got)
+	}
+	if got := percentile(latencies, 0.99); got != 99*time.Millisecond {
+		t.Fatalf("p99 = %s", got)
+	}
+	if got := percentile(nil, 0.99); got != 0 {
+		t.Fatalf("expected 0 for no samples, got %s", got)
+	}
+}
+
+func TestParseVmRSS(t *testing.T) {
+	rss, err := parseVmRSS("Name:\tproxy\nVmPeak:\t  900000 kB\nVmRSS:\t   18944 kB\n")
+	if err != nil || rss != 18944*1024 {
+		t.Fatalf("unexpected result %d, %v", rss, err)
+	}
+	if _, err := parseVmRSS("Name:\tproxy\n"); err == nil {
+		t.Fatalf("expected error without VmRSS")
+	}
+}
+
+func TestSimulatedClusterMarksFailedConnectors(t *testing.T) {
+	names := connectorNames(20)
+	responses := simulatedCluster(names, 2, 0.25)
+	failed := 0
+	for _, name := range names {
+		status := responses["GET /connectors/"+name+"/status"].Body.(map[string]interface{})
+		if status["connector"].(map[string]string)["state"] == "FAILED" {
+			failed++
+		}
+	}
+	if failed != 5 {
+		t.Fatalf("expected 5 failed connectors, got %d", failed)
+	}
+}
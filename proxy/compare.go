@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statuses of the entries of a cluster comparison.
+const (
+	compareSame      = "same"
+	compareDifferent = "different"
+	compareMissing   = "missing"
+)
+
+// ConnectorComparison is one connector of a cluster comparison. MissingIn lists the
+// clusters without it; Diff, set for connectors present in both, reads as the changes
+// that turn the first cluster's config into the second's.
+type ConnectorComparison struct {
+	Name      string            `json:"name"`
+	Status    string            `json:"status"`
+	States    map[string]string `json:"states"`
+	MissingIn []string          `json:"missingIn,omitempty"`
+	Diff      *ConfigDiff       `json:"diff,omitempty"`
+}
+
+// PluginComparison is one connector plugin class of a cluster comparison. Versions maps
+// cluster names to the installed version(s).
+type PluginComparison struct {
+	Class     string            `json:"class"`
+	Type      string            `json:"type"`
+	Status    string            `json:"status"`
+	Versions  map[string]string `json:"versions"`
+	MissingIn []string          `json:"missingIn,omitempty"`
+}
+
+// ComparedWorkers are the Connect versions run by the workers of one cluster. Workers
+// that did not answer the version probe are counted as unreachable.
+type ComparedWorkers struct {
+	Cluster     string   `json:"cluster"`
+	Workers     int      `json:"workers"`
+	Unreachable int      `json:"unreachable"`
+	Versions    []string `json:"versions"`
+}
+
+// ClusterComparison is returned by GET /api/compare.
+type ClusterComparison struct {
+	Clusters            []string              `json:"clusters"`
+	ComparedAt          time.Time             `json:"comparedAt"`
+	Connectors          []ConnectorComparison `json:"connectors"`
+	Plugins             []PluginComparison    `json:"plugins"`
+	Workers             []ComparedWorkers     `json:"workers"`
+	WorkerVersionsMatch bool                  `json:"workerVersionsMatch"`
+	Identical           bool                  `json:"identical"`
+}
+
+// clusterSnapshot is what the comparison reads from one cluster.
+type clusterSnapshot struct {
+	connectors map[string]expandedConnector
+	plugins    []connectPluginInfo
+	workers    WorkersDetail
+}
+
+// fetchClusterSnapshot reads the connectors, plugins and workers of a cluster, keeping
+// only the connectors in the caller's scope.
+func fetchClusterSnapshot(ctx context.Context, scope tenantScope, cluster string) (clusterSnapshot, error) {
+	client, baseURL := connectClientFor(cluster, routeRead), connectURLFor(cluster)
+	connectors, err := fetchExpandedConnectorStatuses(ctx, client, baseURL)
+	if err != nil {
+		return clusterSnapshot{}, err
+	}
+	plugins, err := fetchConnectorPlugins(ctx, client, baseURL)
+	if err != nil {
+		return clusterSnapshot{}, err
+	}
+
+	snapshot := clusterSnapshot{connectors: make(map[string]expandedConnector, len(connectors)), plugins: plugins}
+	for name, connector := range connectors {
+		if scope.allowsConnector(cluster, name) {
+			snapshot.connectors[name] = connector
+		}
+	}
+	// Worker versions are probed on every worker, including those running connectors
+	// outside the caller's scope; only the versions are reported.
+	snapshot.workers = aggregateWorkers(connectors)
+	var wg sync.WaitGroup
+	for i := range snapshot.workers.Workers {
+		wg.Add(1)
+		go func(w *WorkerDetail) {
+			defer wg.Done()
+			probeWorker(ctx, client, baseURL, w)
+		}(&snapshot.workers.Workers[i])
+	}
+	wg.Wait()
+	return snapshot, nil
+}
+
+// compareConnectors lines up the connectors of two clusters by name.
+func compareConnectors(rules redactionRules, clusters []string, snapshots []clusterSnapshot) []ConnectorComparison {
+	names := make(map[string]struct{})
+	for _, snapshot := range snapshots {
+		for name := range snapshot.connectors {
+			names[name] = struct{}{}
+		}
+	}
+
+	result := make([]ConnectorComparison, 0, len(names))
+	for name := range names {
+		entry := ConnectorComparison{Name: name, States: make(map[string]string)}
+		configs := make([]map[string]string, 0, len(clusters))
+		for i, cluster := range clusters {
+			connector, ok := snapshots[i].connectors[name]
+			if !ok {
+				entry.MissingIn = append(entry.MissingIn, cluster)
+				continue
+			}
+			entry.States[cluster] = normalizeState(connector.Status.Connector.State)
+			config := make(map[string]string, len(connector.Info.Config))
+			for key, value := range connector.Info.Config {
+				config[key] = value
+			}
+			secretRefs.restoreStrings(cluster, name, config)
+			configs = append(configs, config)
+		}
+
+		if len(entry.MissingIn) > 0 {
+			entry.Status = compareMissing
+		} else {
+			diff := diffConfigs(rules, configs[0], configs[1])
+			diff.Connector = name
+			entry.Status = compareSame
+			if len(diff.Added)+len(diff.Removed)+len(diff.Changed) > 0 {
+				entry.Status = compareDifferent
+				entry.Diff = &diff
+			}
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// comparePlugins lines up the connector plugins of two clusters by class.
+func comparePlugins(clusters []string, snapshots []clusterSnapshot) []PluginComparison {
+	byClass := make(map[string]*PluginComparison)
+	installed := make(map[string]map[string][]string)
+	for i, cluster := range clusters {
+		for _, plugin := range snapshots[i].plugins {
+			if _, ok := byClass[plugin.Class]; !ok {
+				byClass[plugin.Class] = &PluginComparison{Class: plugin.Class, Type: plugin.Type, Versions: make(map[string]string)}
+				installed[plugin.Class] = make(map[string][]string)
+			}
+			installed[plugin.Class][cluster] = append(installed[plugin.Class][cluster], plugin.Version)
+		}
+	}
+
+	result := make([]PluginComparison, 0, len(byClass))
+	for class, entry := range byClass {
+		distinct := make(map[string]struct{})
+		for _, cluster := range clusters {
+			versions, ok := installed[class][cluster]
+			if !ok {
+				entry.MissingIn = append(entry.MissingIn, cluster)
+				continue
+			}
+			sort.Strings(versions)
+			entry.Versions[cluster] = strings.Join(versions, ", ")
+			distinct[entry.Versions[cluster]] = struct{}{}
+		}
+		switch {
+		case len(entry.MissingIn) > 0:
+			entry.Status = compareMissing
+		case len(distinct) > 1:
+			entry.Status = compareDifferent
+		default:
+			entry.Status = compareSame
+		}
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Class < result[j].Class })
+	return result
+}
+
+// compareWorkers reports the Connect versions of each cluster's workers.
+func compareWorkers(clusters []string, snapshots []clusterSnapshot) []ComparedWorkers {
+	result := make([]ComparedWorkers, len(clusters))
+	for i, cluster := range clusters {
+		versions := make(map[string]struct{})
+		entry := ComparedWorkers{Cluster: cluster, Workers: len(snapshots[i].workers.Workers), Versions: []string{}}
+		for _, w := range snapshots[i].workers.Workers {
+			if !w.Reachable {
+				entry.Unreachable++
+				continue
+			}
+			if _, seen := versions[w.Version]; !seen {
+				versions[w.Version] = struct{}{}
+				entry.Versions = append(entry.Versions, w.Version)
+			}
+		}
+		sort.Strings(entry.Versions)
+		result[i] = entry
+	}
+	return result
+}
+
+// compareClusters builds the comparison of two cluster snapshots.
+func compareClusters(rules redactionRules, clusters []string, snapshots []clusterSnapshot, now time.Time) ClusterComparison {
+	comparison := ClusterComparison{
+		Clusters:   clusters,
+		ComparedAt: now,
+		Connectors: compareConnectors(rules, clusters, snapshots),
+		Plugins:    comparePlugins(clusters, snapshots),
+		Workers:    compareWorkers(clusters, snapshots),
+	}
+	comparison.WorkerVersionsMatch = strings.Join(comparison.Workers[0].Versions, ",") == strings.Join(comparison.Workers[1].Versions, ",")
+
+	comparison.Identical = comparison.WorkerVersionsMatch
+	for _, connector := range comparison.Connectors {
+		comparison.Identical = comparison.Identical && connector.Status == compareSame
+	}
+	for _, plugin := range comparison.Plugins {
+		comparison.Identical = comparison.Identical && plugin.Status == compareSame
+	}
+	return comparison
+}
+
+// compareClustersHandler compares the connectors, plugins and worker versions of two
+// clusters side by side, e.g. before promoting connectors from staging to production.
+func compareClustersHandler(w http.ResponseWriter, r *http.Request) {
+	clusters := splitList(r.URL.Query().Get("clusters"))
+	if len(clusters) != 2 || clusters[0] == clusters[1] {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "clusters must name two different clusters, e.g. clusters=staging,prod")
+		return
+	}
+
+	scope := tenantScopeFor(r)
+	snapshots := make([]clusterSnapshot, len(clusters))
+	errs := make([]error, len(clusters))
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func(i int, cluster string) {
+			defer wg.Done()
+			snapshots[i], errs[i] = fetchClusterSnapshot(r.Context(), scope, cluster)
+		}(i, cluster)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("cluster %s: %w", clusters[i], err)
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, compareClusters(currentRedactionRules(), clusters, snapshots, time.Now().UTC()))
+}
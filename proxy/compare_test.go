@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newCompareCluster serves a one-worker Connect cluster whose worker ID is its own
+// address, so the version probe reaches it.
+func newCompareCluster(t *testing.T, version, plugins, connectors string) *httptest.Server {
+	var id string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, `{"version": %q, "commit": "abc"}`, version)
+		case "/connector-plugins":
+			w.Write([]byte(plugins))
+		case "/connectors":
+			w.Write([]byte(strings.ReplaceAll(connectors, "WORKER", id)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	id = strings.TrimPrefix(server.URL, "http://")
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCompareClustersHandler(t *testing.T) {
+	connector := func(name, state, config string) string {
+		return fmt.Sprintf(`%q: {"info": {"name": %q, "config": %s}, "status": {"name": %q, "connector": {"state": %q, "worker_id": "WORKER"}, "tasks": []}}`, name, name, config, name, state)
+	}
+	staging := newCompareCluster(t, "3.7.0",
+		`[{"class": "JdbcSink", "type": "sink", "version": "10.7.6"}, {"class": "FileStreamSink", "type": "sink", "version": "3.7.0"}]`,
+		"{"+connector("orders-sink", "RUNNING", `{"connector.class": "JdbcSink", "tasks.max": "4", "connection.password": "staging"}`)+","+
+			connector("billing-sink", "RUNNING", `{"connector.class": "FileStreamSink", "file": "/tmp/billing"}`)+","+
+			connector("scratch-sink", "PAUSED", `{"connector.class": "FileStreamSink"}`)+"}")
+	prod := newCompareCluster(t, "3.6.1",
+		`[{"class": "JdbcSink", "type": "sink", "version": "10.7.4"}, {"class": "FileStreamSink", "type": "sink", "version": "3.6.1"}]`,
+		"{"+connector("orders-sink", "FAILED", `{"connector.class": "JdbcSink", "tasks.max": "2", "connection.password": "prod"}`)+","+
+			connector("billing-sink", "RUNNING", `{"connector.class": "FileStreamSink", "file": "/tmp/billing"}`)+"}")
+	withTestClusterURLs(t, map[string]string{"staging": staging.URL, "prod": prod.URL})
+
+	call := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		compareClustersHandler(rr, httptest.NewRequest(http.MethodGet, "/api/compare?"+query, nil))
+		return rr
+	}
+
+	rr := call("clusters=staging,prod")
+	var comparison ClusterComparison
+	if err := json.Unmarshal(rr.Body.Bytes(), &comparison); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if comparison.Identical || len(comparison.Connectors) != 3 {
+		t.Fatalf("expected three differing connectors, got %+v", comparison.Connectors)
+	}
+	billing, orders, scratch := comparison.Connectors[0], comparison.Connectors[1], comparison.Connectors[2]
+	if billing.Status != compareSame || billing.Diff != nil {
+		t.Fatalf("expected billing-sink to match, got %+v", billing)
+	}
+	if orders.Status != compareDifferent || orders.States["prod"] != "failed" || len(orders.Diff.Changed) != 2 {
+		t.Fatalf("expected orders-sink to differ in two keys, got %+v", orders)
+	}
+	if password := orders.Diff.Changed[0]; password.Key != "connection.password" || password.OldValue == "staging" || password.NewValue == "prod" {
+		t.Fatalf("expected the password to be redacted, got %+v", password)
+	}
+	if orders.Diff.Changed[1].OldValue != "4" || orders.Diff.Changed[1].NewValue != "2" {
+		t.Fatalf("expected tasks.max to read from staging to prod, got %+v", orders.Diff.Changed[1])
+	}
+	if scratch.Status != compareMissing || strings.Join(scratch.MissingIn, ",") != "prod" {
+		t.Fatalf("expected scratch-sink to be missing in prod, got %+v", scratch)
+	}
+
+	if len(comparison.Plugins) != 2 || comparison.Plugins[1].Status != compareDifferent || comparison.Plugins[1].Versions["prod"] != "10.7.4" {
+		t.Fatalf("expected the JDBC plugin versions to differ, got %+v", comparison.Plugins)
+	}
+	if comparison.WorkerVersionsMatch || strings.Join(comparison.Workers[0].Versions, ",") != "3.7.0" || comparison.Workers[1].Workers != 1 {
+		t.Fatalf("expected the worker versions to differ, got %+v", comparison.Workers)
+	}
+
+	for _, query := range []string{"", "clusters=staging", "clusters=prod,prod", "clusters=a,b,c"} {
+		if rr := call(query); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q, got %d", query, rr.Code)
+		}
+	}
+}
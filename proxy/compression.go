@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	compressionMinBytes = getEnv("COMPRESSION_MIN_BYTES", "1024")
+
+	responseCompressionMin = 1024
+)
+
+// loadCompressionMinBytes parses COMPRESSION_MIN_BYTES.
+func loadCompressionMinBytes() (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(compressionMinBytes))
+	if err != nil || n < 0 {
+		return 0, &configError{name: "COMPRESSION_MIN_BYTES", value: compressionMinBytes}
+	}
+	return n, nil
+}
+
+// decodingTransport asks Kafka Connect (or a gateway in front of it) for gzip or deflate
+// and hands callers the decoded body. Browsers' own Accept-Encoding headers are copied
+// onto proxied requests; without this a compressed body would reach redaction, which
+// only understands plain JSON, and be passed through unredacted.
+type decodingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding != "gzip" && encoding != "deflate" {
+		return resp, nil
+	}
+	if req.Method == http.MethodHead || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	resp.Body = &decodedBody{encoding: encoding, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decodedBody decompresses lazily so an empty body with a Content-Encoding header does
+// not fail the round trip.
+type decodedBody struct {
+	encoding string
+	raw      io.ReadCloser
+	reader   io.Reader
+	err      error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.reader, b.err = newDecoder(b.encoding, b.raw)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+func (b *decodedBody) Close() error {
+	return b.raw.Close()
+}
+
+// newDecoder returns a reader for a gzip or deflate body. HTTP deflate is meant to be
+// zlib-wrapped, but some servers send raw DEFLATE, so both are accepted.
+func newDecoder(encoding string, body io.Reader) (io.Reader, error) {
+	if encoding == "gzip" {
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip response: %w", err)
+		}
+		return reader, nil
+	}
+
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+		reader, err := zlib.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("decode deflate response: %w", err)
+		}
+		return reader, nil
+	}
+	return flate.NewReader(buffered), nil
+}
+
+// acceptedEncoding picks gzip, or else deflate, when the client accepts it.
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		ok := true
+		for _, param := range fields[1:] {
+			if key, value, found := strings.Cut(strings.TrimSpace(param), "="); found && strings.EqualFold(key, "q") {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				ok = err == nil && q > 0
+			}
+		}
+		accepted[name] = ok
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if ok, listed := accepted[encoding]; ok || (!listed && accepted["*"]) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressible reports whether a response of this content type benefits from
+// compression. Event streams are excluded so each event is flushed as it happens.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/x-ndjson", mediaType == "application/javascript",
+		strings.HasSuffix(mediaType, "+json"):
+		return true
+	}
+	return false
+}
+
+// compressWriter holds back the start of a response until it knows whether the body
+// reaches the minimum size, then writes it either compressed or as is.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minBytes int
+
+	status  int
+	buf     []byte
+	started bool
+	encoder io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.started {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minBytes {
+			return len(p), nil
+		}
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// start writes the header and the buffered body.
+func (cw *compressWriter) start(large bool) error {
+	cw.started = true
+	header := cw.Header()
+	if large && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusNotModified {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		if cw.encoding == "gzip" {
+			cw.encoder = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.encoder = zlib.NewWriter(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buffered := cw.buf
+	cw.buf = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buffered)
+	} else {
+		_, err = cw.ResponseWriter.Write(buffered)
+	}
+	return err
+}
+
+// Flush sends what has been written so far, compressed if it is large enough.
+func (cw *compressWriter) Flush() {
+	if !cw.started {
+		if cw.status == 0 {
+			return
+		}
+		cw.start(len(cw.buf) >= cw.minBytes)
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressWriter) close() error {
+	if !cw.started {
+		if cw.status == 0 {
+			return nil
+		}
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Close()
+	}
+	return nil
+}
+
+// compressionMiddleware gzip- or deflate-compresses API responses of at least
+// COMPRESSION_MIN_BYTES, such as the expanded connector list and the plugin catalog,
+// when the client accepts it. Event streams are never compressed.
+func compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minBytes: responseCompressionMin}
+		defer func() {
+			if err := cw.close(); err != nil {
+				log.Printf("compression: %v", err)
+			}
+		}()
+		next.ServeHTTP(cw, r)
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestProxyHandlerRedactsCompressedUpstreamResponses(t *testing.T) {
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		io.WriteString(gz, `{"name":"alpha","config":{"connection.password":"hunter2","tasks.max":"1"}}`)
+		gz.Close()
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+	original := configCache
+	configCache = newResponseCache(0, newMemoryCache(time.Now))
+	t.Cleanup(func() { configCache = original })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/alpha", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "path": "alpha"})
+	rr := httptest.NewRecorder()
+	proxyHandler(rr, req)
+
+	if acceptEncoding != "gzip, deflate" {
+		t.Fatalf("expected the proxy to negotiate its own encodings, got %q", acceptEncoding)
+	}
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected a decoded 200 response, got %d %v", rr.Code, rr.Header())
+	}
+	if body := rr.Body.String(); strings.Contains(body, "hunter2") || !strings.Contains(body, `"tasks.max":"1"`) {
+		t.Fatalf("expected the decoded body to be redacted, got %s", body)
+	}
+}
+
+func TestNewDecoderHandlesDeflateVariants(t *testing.T) {
+	const payload = `{"connectors":["alpha"]}`
+
+	var wrapped, raw bytes.Buffer
+	zw := zlib.NewWriter(&wrapped)
+	io.WriteString(zw, payload)
+	zw.Close()
+	fw, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	io.WriteString(fw, payload)
+	fw.Close()
+
+	for name, body := range map[string][]byte{"zlib": wrapped.Bytes(), "raw": raw.Bytes()} {
+		reader, err := newDecoder("deflate", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if decoded, err := io.ReadAll(reader); err != nil || string(decoded) != payload {
+			t.Fatalf("%s: decoded %q, %v", name, decoded, err)
+		}
+	}
+}
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"gzip, deflate, br":     "gzip",
+		"deflate":               "deflate",
+		"gzip;q=0, deflate":     "deflate",
+		"br, *;q=0.1":           "gzip",
+		"identity":              "",
+		"GZIP;q=0.5":            "gzip",
+		"gzip;q=0, deflate;q=0": "",
+	}
+	for header, want := range tests {
+		if got := acceptedEncoding(header); got != want {
+			t.Fatalf("acceptedEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	original := responseCompressionMin
+	responseCompressionMin = 64
+	t.Cleanup(func() { responseCompressionMin = original })
+
+	large := `{"connectors":"` + strings.Repeat("alpha,", 50) + `"}`
+	handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/default/large":
+			writeJSON(w, http.StatusOK, map[string]string{"connectors": strings.Repeat("alpha,", 50)})
+		case "/api/default/small":
+			writeJSON(w, http.StatusCreated, map[string]string{"name": "alpha"})
+		case "/api/default/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			io.WriteString(w, "event: audit\ndata: "+strings.Repeat("x", 100)+"\n\n")
+		}
+	}))
+	do := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("/api/default/large", "gzip, deflate")
+	if rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a gzip response, got %v", rr.Header())
+	}
+	reader, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if body, _ := io.ReadAll(reader); strings.TrimSpace(string(body)) != large {
+		t.Fatalf("unexpected decompressed body %s", body)
+	}
+
+	rr = do("/api/default/large", "deflate")
+	zr, err := zlib.NewReader(rr.Body)
+	if err != nil || rr.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expected a deflate response, got %v, %v", rr.Header(), err)
+	}
+	if body, _ := io.ReadAll(zr); strings.TrimSpace(string(body)) != large {
+		t.Fatalf("unexpected inflated body %s", body)
+	}
+
+	if rr := do("/api/default/large", ""); rr.Header().Get("Content-Encoding") != "" || strings.TrimSpace(rr.Body.String()) != large {
+		t.Fatalf("expected an uncompressed response without Accept-Encoding, got %v", rr.Header())
+	}
+	if rr := do("/api/default/small", "gzip"); rr.Code != http.StatusCreated || rr.Header().Get("Content-Encoding") != "" || !strings.Contains(rr.Body.String(), "alpha") {
+		t.Fatalf("expected a small response to stay uncompressed, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := do("/api/default/stream", "gzip"); rr.Header().Get("Content-Encoding") != "" || !strings.HasPrefix(rr.Body.String(), "event: audit") {
+		t.Fatalf("expected event streams to stay uncompressed, got %v", rr.Header())
+	}
+}
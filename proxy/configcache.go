@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// configCache serves repeated connector detail and config reads from the shared cache.
+// Entries are keyed by upstream so several Connect clusters never share cached data.
+var configCache = newResponseCache(5*time.Second, sharedCache)
+
+const configCacheNamespace = "config|"
+
+type cachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// responseCache is a short-TTL read-through cache for redacted connector responses.
+type responseCache struct {
+	ttl   time.Duration
+	store Cache
+}
+
+func newResponseCache(ttl time.Duration, store Cache) *responseCache {
+	return &responseCache{ttl: ttl, store: store}
+}
+
+// cacheable reports whether GETs of the connector sub-resource may be cached. Only the
+// connector itself and its config are; status and tasks change too often.
+func (c *responseCache) cacheable(subresource string) bool {
+	return c.ttl > 0 && (subresource == "" || subresource == "config")
+}
+
+func connectorCachePrefix(upstream *url.URL, connector string) string {
+	return configCacheNamespace + upstream.Scheme + "://" + upstream.Host + "|" + connector + "|"
+}
+
+func (c *responseCache) key(upstream *url.URL, connector, subresource string) string {
+	return connectorCachePrefix(upstream, connector) + subresource + "?" + upstream.RawQuery
+}
+
+func (c *responseCache) get(ctx context.Context, key string) (cachedResponse, bool) {
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		log.Printf("cache: failed to read %s: %v", key, err)
+	}
+	var entry cachedResponse
+	if !ok || json.Unmarshal(data, &entry) != nil {
+		return cachedResponse{}, false
+	}
+	return entry, true
+}
+
+func (c *responseCache) set(ctx context.Context, key string, status int, header http.Header, body []byte) {
+	data, err := json.Marshal(cachedResponse{Status: status, Header: header.Clone(), Body: body})
+	if err == nil {
+		err = c.store.Set(ctx, key, data, c.ttl)
+	}
+	if err != nil {
+		log.Printf("cache: failed to store %s: %v", key, err)
+	}
+}
+
+// invalidateConnector drops every cached response for connector on the given upstream,
+// on every replica sharing the cache.
+func (c *responseCache) invalidateConnector(ctx context.Context, upstream *url.URL, connector string) {
+	if err := c.store.Invalidate(ctx, connectorCachePrefix(upstream, connector)); err != nil {
+		log.Printf("cache: failed to invalidate %s: %v", connector, err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+func TestConnectorPathInfo(t *testing.T) {
+	tests := []struct {
+		path        string
+		name        string
+		subresource string
+		ok          bool
+	}{
+		{"/api/default/connectors/alpha", "alpha", "", true},
+		{"/api/default/connectors/alpha/config", "alpha", "config", true},
+		{"/api/default/connectors/alpha/tasks/0/restart", "alpha", "tasks/0/restart", true},
+		{"/api/default/connectors", "", "", false},
+		{"/api/default/connectors/", "", "", false},
+		{"/api/default/workers/alpha", "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, sub, ok := connectorPathInfo(tt.path)
+		if name != tt.name || sub != tt.subresource || ok != tt.ok {
+			t.Fatalf("connectorPathInfo(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.path, name, sub, ok, tt.name, tt.subresource, tt.ok)
+		}
+	}
+}
+
+func TestProxyHandlerCachesConnectorConfig(t *testing.T) {
+	withTestRestorePoints(t, 0) // snapshots would add upstream reads
+	server := testutils.NewConnectServer(map[string]testutils.Response{
+		"GET /connectors/alpha/config": {
+			Body:    map[string]string{"connector.class": "FileStreamSource", "db.password": "hunter2"},
+			Headers: map[string]string{"Content-Type": "application/json"},
+		},
+		"PUT /connectors/alpha/config": {
+			Body:    map[string]string{"connector.class": "FileStreamSource"},
+			Headers: map[string]string{"Content-Type": "application/json"},
+		},
+		"GET /connectors/alpha/status": {
+			Body:    map[string]string{"state": "RUNNING"},
+			Headers: map[string]string{"Content-Type": "application/json"},
+		},
+	})
+	defer server.Close()
+
+	originalURL, originalCache := connectURL, configCache
+	connectURL = server.URL()
+	configCache = newResponseCache(time.Minute, newMemoryCache(time.Now))
+	t.Cleanup(func() { connectURL, configCache = originalURL, originalCache })
+
+	do := func(method, path, subpath string) *httptest.ResponseRecorder {
+		var body *bytes.Buffer
+		if method == http.MethodPut {
+			body = bytes.NewBufferString(`{"connector.class":"FileStreamSource"}`)
+		} else {
+			body = &bytes.Buffer{}
+		}
+		req := httptest.NewRequest(method, path, body)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "path": subpath})
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		return rr
+	}
+
+	first := do(http.MethodGet, "/api/default/connectors/alpha/config", "alpha/config")
+	second := do(http.MethodGet, "/api/default/connectors/alpha/config", "alpha/config")
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("expected MISS then HIT, got %q then %q", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Fatalf("cached body differs: %q vs %q", first.Body.String(), second.Body.String())
+	}
+	if bytes.Contains(second.Body.Bytes(), []byte("hunter2")) {
+		t.Fatalf("expected cached body to be redacted")
+	}
+
+	do(http.MethodGet, "/api/default/connectors/alpha/status", "alpha/status")
+	do(http.MethodGet, "/api/default/connectors/alpha/status", "alpha/status")
+
+	do(http.MethodPut, "/api/default/connectors/alpha/config", "alpha/config")
+	third := do(http.MethodGet, "/api/default/connectors/alpha/config", "alpha/config")
+	if third.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expected mutation to invalidate the cache, got %q", third.Header().Get("X-Cache"))
+	}
+
+	counts := map[string]int{}
+	for _, req := range server.Requests() {
+		counts[req.Method+" "+req.Path]++
+	}
+	if counts["GET /connectors/alpha/config"] != 2 {
+		t.Fatalf("expected 2 upstream config reads, got %d", counts["GET /connectors/alpha/config"])
+	}
+	if counts["GET /connectors/alpha/status"] != 2 {
+		t.Fatalf("expected status reads to bypass the cache, got %d", counts["GET /connectors/alpha/status"])
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := newResponseCache(time.Second, newMemoryCache(func() time.Time { return now }))
+	cache.set(context.Background(), "k", http.StatusOK, http.Header{}, []byte("v"))
+
+	if _, ok := cache.get(context.Background(), "k"); !ok {
+		t.Fatalf("expected fresh entry to be served")
+	}
+	now = now.Add(time.Second)
+	if _, ok := cache.get(context.Background(), "k"); ok {
+		t.Fatalf("expected expired entry to be evicted")
+	}
+	if newResponseCache(0, newMemoryCache(time.Now)).cacheable("config") {
+		t.Fatalf("expected zero TTL to disable caching")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Warning codes raised by the config diff for changes that are easy to get wrong.
+const (
+	diffWarningClassChanged  = "connector_class_changed"
+	diffWarningTopicsChanged = "topics_changed"
+	diffWarningTasksReduced  = "tasks_max_reduced"
+	diffWarningPlaceholder   = "redacted_placeholder"
+)
+
+// ConfigChange is one key of a config diff. Values of sensitive keys are redacted.
+type ConfigChange struct {
+	Key      string `json:"key"`
+	OldValue string `json:"oldValue,omitempty"`
+	NewValue string `json:"newValue,omitempty"`
+}
+
+// ConfigWarning flags a change that may break the connector or lose data.
+type ConfigWarning struct {
+	Code    string `json:"code"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// ConfigDiff is returned by POST /api/{cluster}/connectors/{name}/config/diff.
+type ConfigDiff struct {
+	Connector string          `json:"connector"`
+	Added     []ConfigChange  `json:"added"`
+	Removed   []ConfigChange  `json:"removed"`
+	Changed   []ConfigChange  `json:"changed"`
+	Unchanged int             `json:"unchanged"`
+	Warnings  []ConfigWarning `json:"warnings"`
+}
+
+// diffConfigs compares the live config with a candidate. Keys are reported in sorted
+// order and sensitive values are replaced by the redaction placeholder.
+func diffConfigs(rules redactionRules, live, candidate map[string]string) ConfigDiff {
+	diff := ConfigDiff{
+		Added:    []ConfigChange{},
+		Removed:  []ConfigChange{},
+		Changed:  []ConfigChange{},
+		Warnings: []ConfigWarning{},
+	}
+	display := func(key, value string) string {
+		if rules.isSensitive(key) && !isSecretReference(value) {
+			return rules.placeholder
+		}
+		return value
+	}
+
+	keys := make([]string, 0, len(live)+len(candidate))
+	for key := range live {
+		keys = append(keys, key)
+	}
+	for key := range candidate {
+		if _, ok := live[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldValue, hadOld := live[key]
+		newValue, hasNew := candidate[key]
+		switch {
+		case !hadOld:
+			diff.Added = append(diff.Added, ConfigChange{Key: key, NewValue: display(key, newValue)})
+		case !hasNew:
+			diff.Removed = append(diff.Removed, ConfigChange{Key: key, OldValue: display(key, oldValue)})
+		case oldValue == newValue:
+			diff.Unchanged++
+			continue
+		default:
+			diff.Changed = append(diff.Changed, ConfigChange{Key: key, OldValue: display(key, oldValue), NewValue: display(key, newValue)})
+		}
+
+		if hasNew && newValue == rules.placeholder {
+			diff.Warnings = append(diff.Warnings, ConfigWarning{
+				Code:    diffWarningPlaceholder,
+				Key:     key,
+				Message: fmt.Sprintf("%s is set to the redaction placeholder; applying would overwrite the real value", key),
+			})
+		}
+	}
+
+	diff.Warnings = append(diff.Warnings, dangerousChanges(live, candidate)...)
+	return diff
+}
+
+// dangerousChanges warns about changes that re-create the connector's behaviour from
+// scratch or shrink its parallelism.
+func dangerousChanges(live, candidate map[string]string) []ConfigWarning {
+	var warnings []ConfigWarning
+	if oldClass, newClass := live["connector.class"], candidate["connector.class"]; oldClass != newClass {
+		warnings = append(warnings, ConfigWarning{
+			Code:    diffWarningClassChanged,
+			Key:     "connector.class",
+			Message: fmt.Sprintf("connector.class changes from %q to %q; existing offsets may not apply to the new class", oldClass, newClass),
+		})
+	}
+	for _, key := range []string{"topics", "topics.regex"} {
+		if live[key] != candidate[key] {
+			warnings = append(warnings, ConfigWarning{
+				Code:    diffWarningTopicsChanged,
+				Key:     key,
+				Message: fmt.Sprintf("%s changes from %q to %q; the connector will read or write different topics", key, live[key], candidate[key]),
+			})
+		}
+	}
+	oldTasks, oldErr := strconv.Atoi(strings.TrimSpace(live["tasks.max"]))
+	newTasks, newErr := strconv.Atoi(strings.TrimSpace(candidate["tasks.max"]))
+	if oldErr == nil && newErr == nil && newTasks < oldTasks {
+		warnings = append(warnings, ConfigWarning{
+			Code:    diffWarningTasksReduced,
+			Key:     "tasks.max",
+			Message: fmt.Sprintf("tasks.max drops from %d to %d; throughput will be reduced", oldTasks, newTasks),
+		})
+	}
+	return warnings
+}
+
+// fetchConnectorConfig returns the live config of a connector.
+func fetchConnectorConfig(ctx context.Context, client *http.Client, baseURL, name string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(baseURL, "connectors", url.PathEscape(name), "config"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errConnectorNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching config of %s: %d", name, resp.StatusCode)
+	}
+
+	var config map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("decode config of %s: %w", name, err)
+	}
+	return config, nil
+}
+
+// connectorConfigDiffHandler previews a config update: it takes the body that would be
+// sent to PUT /connectors/{name}/config and compares it with the live config.
+func connectorConfigDiffHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	var candidate map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&candidate); err != nil || candidate == nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a connector config object with string values")
+		return
+	}
+
+	live, err := fetchConnectorConfig(r.Context(), connectClientFor(vars["cluster"], routeRead), connectURLFor(vars["cluster"]), name)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", name))
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		default:
+			writeJSONError(w, http.StatusBadGateway, "config_fetch_failed", err.Error())
+		}
+		return
+	}
+
+	secretRefs.restoreStrings(vars["cluster"], name, live)
+	diff := diffConfigs(currentRedactionRules(), live, candidate)
+	diff.Connector = name
+	writeJSON(w, http.StatusOK, diff)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	live := map[string]string{
+		"connector.class":     "io.confluent.connect.jdbc.JdbcSinkConnector",
+		"topics":              "orders",
+		"tasks.max":           "4",
+		"connection.url":      "jdbc:postgresql://db/orders",
+		"connection.password": "s3cret",
+		"batch.size":          "500",
+	}
+	candidate := map[string]string{
+		"connector.class":     "io.confluent.connect.jdbc.JdbcSinkConnector",
+		"topics":              "orders,refunds",
+		"tasks.max":           "2",
+		"connection.url":      "jdbc:postgresql://db/orders",
+		"connection.password": "n3w",
+		"auto.create":         "true",
+	}
+
+	diff := diffConfigs(defaultRedactionRules(), live, candidate)
+	if len(diff.Added) != 1 || diff.Added[0].Key != "auto.create" {
+		t.Fatalf("unexpected added keys: %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Key != "batch.size" || diff.Removed[0].OldValue != "500" {
+		t.Fatalf("unexpected removed keys: %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 3 || diff.Unchanged != 2 {
+		t.Fatalf("expected 3 changed and 2 unchanged keys, got %+v", diff)
+	}
+	if diff.Changed[0].Key != "connection.password" || diff.Changed[0].OldValue != defaultRedactionPlaceholder || diff.Changed[0].NewValue != defaultRedactionPlaceholder {
+		t.Fatalf("expected password change to be redacted, got %+v", diff.Changed[0])
+	}
+
+	codes := map[string]bool{}
+	for _, warning := range diff.Warnings {
+		codes[warning.Code] = true
+	}
+	if !codes[diffWarningTopicsChanged] || !codes[diffWarningTasksReduced] || codes[diffWarningClassChanged] {
+		t.Fatalf("unexpected warnings: %+v", diff.Warnings)
+	}
+}
+
+func TestDiffConfigsWarnsAboutClassChangeAndPlaceholders(t *testing.T) {
+	live := map[string]string{"connector.class": "FileStreamSource", "db.password": "s3cret"}
+	candidate := map[string]string{"connector.class": "FileStreamSink", "db.password": defaultRedactionPlaceholder}
+
+	diff := diffConfigs(defaultRedactionRules(), live, candidate)
+	codes := map[string]bool{}
+	for _, warning := range diff.Warnings {
+		codes[warning.Code] = true
+	}
+	if !codes[diffWarningClassChanged] || !codes[diffWarningPlaceholder] {
+		t.Fatalf("expected class and placeholder warnings, got %+v", diff.Warnings)
+	}
+}
+
+func TestConnectorConfigDiffHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connectors/orders/config" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"connector.class":"FileStreamSink","topics":"orders","tasks.max":"1"}`))
+	}))
+	defer server.Close()
+	restore := withTestConnectURL(t, server)
+	defer restore()
+
+	body := `{"connector.class":"FileStreamSink","topics":"orders","tasks.max":"2"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/default/connectors/orders/config/diff", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "orders"})
+	rr := httptest.NewRecorder()
+	connectorConfigDiffHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var diff ConfigDiff
+	if err := json.Unmarshal(rr.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if diff.Connector != "orders" || len(diff.Changed) != 1 || diff.Changed[0].NewValue != "2" || len(diff.Warnings) != 0 {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/default/connectors/missing/config/diff", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "missing"})
+	rr = httptest.NewRecorder()
+	connectorConfigDiffHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown connector, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// activeConfigFile holds the settings of the --config file. It is loaded while the
+// package variables are initialised, because getEnv reads every setting then.
+var activeConfigFile = loadConfigFile(os.Args[1:])
+
+// configFile is a YAML or JSON file of settings. Nested keys are joined with "_" and
+// upper-cased to name a setting, so
+//
+//	oidc:
+//	  issuer_url: https://sso.example.com
+//
+// sets OIDC_ISSUER_URL. Lists become comma-separated values and a map of scalars is
+// also readable as name=value pairs, so a clusters map under kafka_connect sets
+// KAFKA_CONNECT_CLUSTERS. The optional profiles section holds per-environment
+// overrides of the same shape, selected with --profile or CONFIG_PROFILE.
+type configFile struct {
+	path    string
+	profile string
+	// settings maps setting names to values, the selected profile applied.
+	settings map[string]string
+	// keys lists the leaf keys of the file as setting name candidates, from the
+	// outermost section inwards, for reporting keys that configure nothing.
+	keys [][]string
+	err  error
+}
+
+// EffectiveSetting is one setting reported by GET /api/config.
+type EffectiveSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// EffectiveConfig is returned by GET /api/config. Credentials are masked as in the
+// startup summary.
+type EffectiveConfig struct {
+	File     string             `json:"file,omitempty"`
+	Profile  string             `json:"profile,omitempty"`
+	Settings []EffectiveSetting `json:"settings"`
+}
+
+// configFileArgs finds --config and --profile (or -config and -profile) in args,
+// falling back to CONFIG_FILE and CONFIG_PROFILE.
+func configFileArgs(args []string) (path, profile string) {
+	path, profile = os.Getenv("CONFIG_FILE"), os.Getenv("CONFIG_PROFILE")
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || (name != "config" && name != "profile") {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if name == "config" {
+			path = value
+		} else {
+			profile = value
+		}
+	}
+	return path, profile
+}
+
+// loadConfigFile reads the file named in args. A file that cannot be used is kept as
+// an error for the startup checks to report rather than stopping initialisation.
+func loadConfigFile(args []string) *configFile {
+	path, profile := configFileArgs(args)
+	recordSetting("CONFIG_FILE", "")
+	recordSetting("CONFIG_PROFILE", "")
+	if path == "" {
+		if profile != "" {
+			return &configFile{profile: profile, err: fmt.Errorf("profile %q was selected without a config file", profile)}
+		}
+		return nil
+	}
+	file, err := readConfigFile(path, profile)
+	if err != nil {
+		return &configFile{path: path, profile: profile, err: err}
+	}
+	return file
+}
+
+func readConfigFile(path, profile string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML, so one parser reads both.
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	file := &configFile{path: path, profile: profile, settings: map[string]string{}}
+	profiles, _ := document["profiles"].(map[string]interface{})
+	if _, ok := document["profiles"]; ok && profiles == nil {
+		return nil, fmt.Errorf("%s: profiles must map profile names to settings", path)
+	}
+	delete(document, "profiles")
+	if err := file.add(nil, document); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if profile != "" {
+		overrides, ok := profiles[profile].(map[string]interface{})
+		if !ok {
+			names := make([]string, 0, len(profiles))
+			for name := range profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("%s: profile %q is not defined (profiles: %s)", path, profile, strings.Join(names, ", "))
+		}
+		if err := file.add(nil, overrides); err != nil {
+			return nil, fmt.Errorf("%s: profile %s: %v", path, profile, err)
+		}
+	}
+	return file, nil
+}
+
+// add records the settings under section, whose key path is prefix.
+func (f *configFile) add(prefix []string, section map[string]interface{}) error {
+	pairs := make([]string, 0, len(section))
+	scalars := true
+	keys := make([]string, 0, len(section))
+	for key := range section {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := append(append([]string(nil), prefix...), configKeyName(key))
+		switch value := section[key].(type) {
+		case map[string]interface{}:
+			scalars = false
+			if err := f.add(path, value); err != nil {
+				return err
+			}
+		default:
+			text, err := configValue(value)
+			if err != nil {
+				return fmt.Errorf("%s: %v", strings.Join(path, "_"), err)
+			}
+			f.settings[strings.Join(path, "_")] = text
+			f.keys = append(f.keys, path)
+			pairs = append(pairs, key+"="+text)
+		}
+	}
+	if len(prefix) > 0 && scalars && len(pairs) > 0 {
+		f.settings[strings.Join(prefix, "_")] = strings.Join(pairs, ",")
+	}
+	return nil
+}
+
+// configKeyName turns a file key such as issuer-url into the ISSUER_URL part of a
+// setting name.
+func configKeyName(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(strings.TrimSpace(key)))
+}
+
+// configValue formats a scalar or a list of scalars the way the setting is written in
+// the environment.
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			text, err := configValue(item)
+			if err != nil || strings.Contains(text, ",") {
+				return "", fmt.Errorf("list items must be scalars without commas")
+			}
+			items[i] = text
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+func (f *configFile) lookup(name string) (string, bool) {
+	if f == nil || f.settings == nil {
+		return "", false
+	}
+	value, ok := f.settings[name]
+	return value, ok
+}
+
+// unknownKeys returns the keys of the file that configure no known setting, which are
+// usually typos. A key counts as known when it or one of its sections names a setting.
+func (f *configFile) unknownKeys(known map[string]string) []string {
+	if f == nil {
+		return nil
+	}
+	var unknown []string
+	seen := make(map[string]bool, len(f.keys))
+	for _, path := range f.keys {
+		if seen[strings.Join(path, "_")] {
+			continue
+		}
+		seen[strings.Join(path, "_")] = true
+		matched := false
+		for i := len(path); i > 0 && !matched; i-- {
+			_, matched = known[strings.Join(path[:i], "_")]
+		}
+		if !matched {
+			unknown = append(unknown, strings.Join(path, "_"))
+		}
+	}
+	return unknown
+}
+
+// lookupSetting returns the value of a setting and where it came from: the
+// environment, which overrides everything, the config file or the default.
+func lookupSetting(name, defaultValue string) (value, source string) {
+	if value := os.Getenv(name); value != "" {
+		return value, "env"
+	}
+	if value, ok := activeConfigFile.lookup(name); ok && value != "" {
+		return value, "file"
+	}
+	return defaultValue, "default"
+}
+
+// settingSource returns where the named setting's value comes from.
+func settingSource(name string) string {
+	_, source := lookupSetting(name, "")
+	return source
+}
+
+// effectiveSettings lists every known setting with its masked value, sorted by name.
+func effectiveSettings() []EffectiveSetting {
+	configSettingsMu.Lock()
+	defaults := make(map[string]string, len(configSettings))
+	for name, def := range configSettings {
+		defaults[name] = def
+	}
+	configSettingsMu.Unlock()
+
+	settings := make([]EffectiveSetting, 0, len(defaults))
+	for name, def := range defaults {
+		value, source := lookupSetting(name, def)
+		if value != "" {
+			value = maskSetting(name, value)
+		}
+		settings = append(settings, EffectiveSetting{Name: name, Value: value, Source: source})
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name < settings[j].Name })
+	return settings
+}
+
+// effectiveConfigHandler reports the configuration the proxy is running with, so
+// support staff can check a deployment without access to its environment.
+func effectiveConfigHandler(w http.ResponseWriter, r *http.Request) {
+	config := EffectiveConfig{Settings: effectiveSettings()}
+	if activeConfigFile != nil {
+		config.File, config.Profile = activeConfigFile.path, activeConfigFile.profile
+	}
+	writeJSON(w, http.StatusOK, config)
+}
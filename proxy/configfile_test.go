@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFileArgs(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "/etc/env.yaml")
+	t.Setenv("CONFIG_PROFILE", "")
+	if path, profile := configFileArgs([]string{"-test.v"}); path != "/etc/env.yaml" || profile != "" {
+		t.Fatalf("expected the environment to apply, got %q %q", path, profile)
+	}
+	if path, profile := configFileArgs([]string{"--config", "/etc/a.yaml", "-profile=prod"}); path != "/etc/a.yaml" || profile != "prod" {
+		t.Fatalf("expected the flags to win, got %q %q", path, profile)
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	path := writeTestConfigFile(t, "config.yaml", `
+kafka_connect:
+  url: http://connect:8083
+  clusters:
+    dr: http://connect-dr:8083
+oidc:
+  issuer-url: https://sso.example.com
+  scopes: [openid, email]
+cache:
+  ttl: 30s
+alert_lag_threshold: 1000
+profiles:
+  prod:
+    kafka_connect:
+      url: http://connect-prod:8083
+    cache:
+      ttl: 5m
+    typo_setting: true
+`)
+
+	file, err := readConfigFile(path, "prod")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for name, want := range map[string]string{
+		"KAFKA_CONNECT_URL":      "http://connect-prod:8083",
+		"KAFKA_CONNECT_CLUSTERS": "dr=http://connect-dr:8083",
+		"OIDC_ISSUER_URL":        "https://sso.example.com",
+		"OIDC_SCOPES":            "openid,email",
+		"CACHE_TTL":              "5m",
+		"ALERT_LAG_THRESHOLD":    "1000",
+	} {
+		if got, _ := file.lookup(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	known := map[string]string{"KAFKA_CONNECT_URL": "", "KAFKA_CONNECT_CLUSTERS": "", "OIDC_ISSUER_URL": "", "OIDC_SCOPES": "", "CACHE_TTL": "", "ALERT_LAG_THRESHOLD": ""}
+	if unknown := file.unknownKeys(known); strings.Join(unknown, ",") != "TYPO_SETTING" {
+		t.Fatalf("expected the typo to be reported, got %v", unknown)
+	}
+
+	if _, err := readConfigFile(path, "staging"); err == nil || !strings.Contains(err.Error(), "profiles: prod") {
+		t.Fatalf("expected an unknown profile to fail, got %v", err)
+	}
+	broken := writeTestConfigFile(t, "broken.json", `{"cache": {"ttl": [{"nested": true}]}}`)
+	if _, err := readConfigFile(broken, ""); err == nil || !strings.Contains(err.Error(), "CACHE_TTL") {
+		t.Fatalf("expected a nested list to fail, got %v", err)
+	}
+}
+
+func TestEffectiveConfigHandler(t *testing.T) {
+	path := writeTestConfigFile(t, "config.json", `{"kafka_connect": {"url": "http://connect:8083", "password": "hunter2"}, "cache": {"key_prefix": "from-file:"}}`)
+	file, err := readConfigFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := activeConfigFile
+	activeConfigFile = file
+	t.Cleanup(func() { activeConfigFile = original })
+	t.Setenv("KAFKA_CONNECT_URL", "http://connect-env:8083")
+
+	rr := httptest.NewRecorder()
+	effectiveConfigHandler(rr, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	var config EffectiveConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil || config.File != path {
+		t.Fatalf("unexpected response %s", rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "hunter2") {
+		t.Fatalf("expected the password to be masked: %s", rr.Body.String())
+	}
+	settings := make(map[string]EffectiveSetting)
+	for _, setting := range config.Settings {
+		settings[setting.Name] = setting
+	}
+	for name, want := range map[string]string{
+		"KAFKA_CONNECT_URL":      "http://connect-env:8083 env",
+		"KAFKA_CONNECT_PASSWORD": defaultRedactionPlaceholder + " file",
+		"CACHE_KEY_PREFIX":       "from-file: file",
+		"UPSTREAM_TIMEOUT":       "10s default",
+	} {
+		if got := settings[name].Value + " " + settings[name].Source; got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	jsonPatchMediaType  = "application/json-patch+json"
+	mergePatchMediaType = "application/merge-patch+json"
+)
+
+var errPatchTestFailed = errors.New("test operation failed")
+
+// jsonPatchOperation is one operation of an RFC 6902 JSON Patch document.
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from,omitempty"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+// parseJSONPointer splits an RFC 6901 pointer into unescaped reference tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex resolves a token against an array of length n; "-" means the end when
+// allowed.
+func arrayIndex(token string, n int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return n, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	limit := n - 1
+	if allowEnd {
+		limit = n
+	}
+	if index > limit {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+// pointerGet returns the value a pointer refers to.
+func pointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	current := doc
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q does not exist", token)
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("cannot descend into %q", token)
+		}
+	}
+	return current, nil
+}
+
+// pointerUpdate applies fn to the container holding the last token and returns the
+// new document; fn receives the container and the token and returns the new container.
+func pointerUpdate(doc interface{}, tokens []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("the whole document cannot be the target")
+	}
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("path member %q does not exist", tokens[0])
+		}
+		updated, err := pointerUpdate(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = updated
+		return node, nil
+	case []interface{}:
+		index, err := arrayIndex(tokens[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := pointerUpdate(node[index], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updated
+		return node, nil
+	}
+	return nil, fmt.Errorf("cannot descend into %q", tokens[0])
+}
+
+func pointerAdd(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("cannot add %q to a scalar", token)
+	})
+}
+
+func pointerRemove(doc interface{}, tokens []string) (interface{}, error) {
+	return pointerUpdate(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("path member %q does not exist", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:index], node[index+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot remove %q from a scalar", token)
+	})
+}
+
+// deepCopyJSON copies a decoded JSON value so copy and move do not alias.
+func deepCopyJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = deepCopyJSON(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = deepCopyJSON(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// applyJSONPatch applies an RFC 6902 patch. Operations apply in order and the patch
+// fails as a whole if any operation fails.
+func applyJSONPatch(doc interface{}, patch []jsonPatchOperation) (interface{}, error) {
+	doc = deepCopyJSON(doc)
+	for i, op := range patch {
+		fail := func(err error) (interface{}, error) {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+		path, err := parseJSONPointer(op.Path)
+		if err != nil {
+			return fail(err)
+		}
+		var value interface{}
+		if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+			if op.Value == nil {
+				return fail(errors.New("value is required"))
+			}
+			if err := json.Unmarshal(*op.Value, &value); err != nil {
+				return fail(err)
+			}
+		}
+
+		switch op.Op {
+		case "add":
+			doc, err = pointerAdd(doc, path, value)
+		case "remove":
+			doc, err = pointerRemove(doc, path)
+		case "replace":
+			if _, err = pointerGet(doc, path); err == nil {
+				if len(path) == 0 {
+					doc = value
+				} else if doc, err = pointerRemove(doc, path); err == nil {
+					doc, err = pointerAdd(doc, path, value)
+				}
+			}
+		case "move", "copy":
+			var from []string
+			if from, err = parseJSONPointer(op.From); err != nil {
+				return fail(err)
+			}
+			if op.Op == "move" && strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return fail(errors.New("cannot move a value into itself"))
+			}
+			var moved interface{}
+			if moved, err = pointerGet(doc, from); err == nil {
+				moved = deepCopyJSON(moved)
+				if op.Op == "move" {
+					doc, err = pointerRemove(doc, from)
+				}
+				if err == nil {
+					doc, err = pointerAdd(doc, path, moved)
+				}
+			}
+		case "test":
+			var current interface{}
+			if current, err = pointerGet(doc, path); err == nil && !jsonEqual(current, value) {
+				err = errPatchTestFailed
+			}
+		default:
+			return fail(fmt.Errorf("unknown operation %q", op.Op))
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	return doc, nil
+}
+
+func jsonEqual(a, b interface{}) bool {
+	left, errA := json.Marshal(a)
+	right, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(left, right)
+}
+
+// applyMergePatch applies an RFC 7386 merge patch: members set to null are removed,
+// objects merge recursively and anything else replaces the target.
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	} else {
+		targetObject = deepCopyJSON(targetObject).(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = applyMergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// patchedConfig checks that a patched document is still a connector config: an object
+// of string values that names its connector class.
+func patchedConfig(doc interface{}) (map[string]string, error) {
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("the patched config must be a JSON object")
+	}
+	config := make(map[string]string, len(object))
+	var invalid []string
+	for key, value := range object {
+		s, ok := value.(string)
+		if !ok {
+			invalid = append(invalid, key)
+			continue
+		}
+		config[key] = s
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return nil, fmt.Errorf("config values must be strings: %s", strings.Join(invalid, ", "))
+	}
+	if strings.TrimSpace(config["connector.class"]) == "" {
+		return nil, errors.New("the patched config must keep connector.class")
+	}
+	return config, nil
+}
+
+// connectorConfigPatchHandler serves PATCH /api/{cluster}/connectors/{name}/config. It
+// applies a JSON Patch (application/json-patch+json) or merge patch
+// (application/merge-patch+json, also assumed for application/json) to the live
+// config, validates the result and PUTs it through the regular proxy path, so secret
+// placeholders, caching, dry runs and auditing behave as for a full update.
+func connectorConfigPatchHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "application/json"
+	}
+	if mediaType != jsonPatchMediaType && mediaType != mergePatchMediaType && mediaType != "application/json" {
+		w.Header().Set("Accept-Patch", jsonPatchMediaType+", "+mergePatchMediaType)
+		writeJSONError(w, http.StatusUnsupportedMediaType, "unsupported_patch_type",
+			fmt.Sprintf("Content-Type must be %s or %s", jsonPatchMediaType, mergePatchMediaType))
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "failed to read request body")
+		return
+	}
+
+	baseURL := connectURLFor(cluster)
+	live, err := fetchConnectorConfig(r.Context(), connectClientFor(cluster, routeRead), baseURL, name)
+	if err != nil {
+		writeDryRunError(w, err, name)
+		return
+	}
+	// Patch the config as clients see it, with placeholders rather than resolved secrets.
+	secretRefs.restoreStrings(cluster, name, live)
+	current := make(map[string]interface{}, len(live))
+	for key, value := range live {
+		current[key] = value
+	}
+
+	var patched interface{}
+	if mediaType == jsonPatchMediaType {
+		var operations []jsonPatchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_patch", "a JSON Patch must be an array of operations")
+			return
+		}
+		if patched, err = applyJSONPatch(current, operations); err != nil {
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, errPatchTestFailed) {
+				status = http.StatusConflict
+			}
+			writeJSONError(w, status, "patch_failed", err.Error())
+			return
+		}
+	} else {
+		var patch interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_patch", "a merge patch must be a JSON document")
+			return
+		}
+		patched = applyMergePatch(current, patch)
+	}
+	config, err := patchedConfig(patched)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, "patch_failed", err.Error())
+		return
+	}
+	merged, err := json.Marshal(config)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "encode_failed", err.Error())
+		return
+	}
+
+	put := r.Clone(r.Context())
+	put.Method = http.MethodPut
+	put.Header.Set("Content-Type", "application/json")
+	put.Body = io.NopCloser(bytes.NewReader(merged))
+	put.ContentLength = int64(len(merged))
+	resolved := make(map[string]interface{}, len(config)+1)
+	for key, value := range config {
+		resolved[key] = value
+	}
+	resolved["name"] = name
+	if isDryRun(r) {
+		// Applied patches are admitted by proxyHandler; dry runs never reach it.
+		if admitConnectorConfig(w, r, name, resolved) {
+			dryRunConnector(w, put, name, "config")
+		}
+		return
+	}
+
+	// Validate before writing so a bad patch is rejected with per-key errors.
+	if _, err := connectorSecrets.resolveConfig(r.Context(), resolved); err != nil {
+		writeSecretResolutionError(w, err)
+		return
+	}
+	validation, err := validateConnectorConfig(r.Context(), connectClientFor(cluster, routeValidate), baseURL, config["connector.class"], resolved)
+	if err != nil {
+		writeDryRunError(w, err, name)
+		return
+	}
+	if validation.ErrorCount > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":      "invalid_config",
+			"message":    fmt.Sprintf("the patched config has %d validation error(s)", validation.ErrorCount),
+			"validation": validation,
+		})
+		return
+	}
+
+	proxyHandler(w, put)
+}
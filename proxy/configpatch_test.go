@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := map[string]interface{}{"topics": "orders", "a/b": "x", "list": []interface{}{"one", "two"}}
+	var patch []jsonPatchOperation
+	if err := json.Unmarshal([]byte(`[
+		{"op": "test", "path": "/topics", "value": "orders"},
+		{"op": "replace", "path": "/topics", "value": "orders,returns"},
+		{"op": "move", "from": "/a~1b", "path": "/moved"},
+		{"op": "copy", "from": "/topics", "path": "/topics.copy"},
+		{"op": "add", "path": "/list/1", "value": "between"},
+		{"op": "remove", "path": "/list/0"}
+	]`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	patched, err := applyJSONPatch(doc, patch)
+	if err != nil {
+		t.Fatalf("applyJSONPatch: %v", err)
+	}
+	want := `{"list":["between","two"],"moved":"x","topics":"orders,returns","topics.copy":"orders,returns"}`
+	if got, _ := json.Marshal(patched); string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if doc["topics"] != "orders" {
+		t.Fatalf("expected the input document to be left alone")
+	}
+
+	for body, wantErr := range map[string]error{
+		`[{"op": "test", "path": "/topics", "value": "billing"}]`: errPatchTestFailed,
+		`[{"op": "remove", "path": "/missing"}]`:                  nil,
+		`[{"op": "replace", "path": "/missing", "value": "x"}]`:   nil,
+		`[{"op": "increment", "path": "/topics"}]`:                nil,
+	} {
+		json.Unmarshal([]byte(body), &patch)
+		_, err := applyJSONPatch(doc, patch)
+		if err == nil || (wantErr != nil && !errors.Is(err, wantErr)) {
+			t.Errorf("%s: expected an error, got %v", body, err)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	target := map[string]interface{}{"topics": "orders", "transforms": "mask", "nested": map[string]interface{}{"a": "1", "b": "2"}}
+	var patch interface{}
+	json.Unmarshal([]byte(`{"topics": "returns", "transforms": null, "nested": {"b": null, "c": "3"}}`), &patch)
+
+	want := `{"nested":{"a":"1","c":"3"},"topics":"returns"}`
+	if got, _ := json.Marshal(applyMergePatch(target, patch)); string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+// configPatchTestServer fakes a Connect cluster with the connector "orders" and records
+// the config it is sent.
+func configPatchTestServer(t *testing.T) (*httptest.Server, func() map[string]string) {
+	t.Helper()
+	var (
+		mu     sync.Mutex
+		stored map[string]string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors/orders/config":
+			io.WriteString(w, `{"connector.class":"FileStreamSink","topics":"orders","file":"/tmp/orders.txt"}`)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/config/validate"):
+			var config map[string]string
+			json.NewDecoder(r.Body).Decode(&config)
+			if config["topics"] == "" {
+				io.WriteString(w, `{"error_count":1,"configs":[{"value":{"name":"topics","errors":["Must configure one of topics or topics.regex"]}}]}`)
+				return
+			}
+			io.WriteString(w, `{"error_count":0,"configs":[]}`)
+		case r.Method == http.MethodPut && r.URL.Path == "/connectors/orders/config":
+			mu.Lock()
+			json.NewDecoder(r.Body).Decode(&stored)
+			mu.Unlock()
+			io.WriteString(w, `{"name":"orders","config":{},"tasks":[]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error_code":404,"message":"not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return stored
+	}
+}
+
+func TestConnectorConfigPatchHandler(t *testing.T) {
+	server, stored := configPatchTestServer(t)
+	defer withTestConnectURL(t, server)()
+	logger := withTestAuditLog(t, 10)
+	handler := auditMiddleware(http.HandlerFunc(connectorConfigPatchHandler))
+
+	do := func(name, query, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/default/connectors/"+name+"/config"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": name})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("orders", "", mergePatchMediaType, `{"file": "/tmp/orders-v2.txt"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected the merge patch to be applied, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := stored(); got["file"] != "/tmp/orders-v2.txt" || got["topics"] != "orders" || got["connector.class"] != "FileStreamSink" {
+		t.Fatalf("expected the merged config to be PUT, got %v", got)
+	}
+	if entries := logger.Query(AuditFilter{}); len(entries) != 1 || entries[0].Action != auditActionUpdate || entries[0].ConnectorName != "orders" {
+		t.Fatalf("expected the patch to be audited as UPDATE, got %+v", entries)
+	}
+
+	rr := do("orders", "", jsonPatchMediaType, `[{"op": "remove", "path": "/topics"}]`)
+	var invalid struct {
+		Error      string           `json:"error"`
+		Validation ConfigValidation `json:"validation"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &invalid); err != nil || rr.Code != http.StatusBadRequest || len(invalid.Validation.Errors["topics"]) != 1 {
+		t.Fatalf("expected validation errors, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := stored(); got["topics"] != "orders" {
+		t.Fatalf("expected an invalid config not to be written, got %v", got)
+	}
+
+	rr = do("orders", "?dryRun=true", jsonPatchMediaType, `[{"op": "replace", "path": "/topics", "value": "returns"}]`)
+	var result DryRunResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK || !result.DryRun || result.Diff == nil || len(result.Diff.Changed) != 1 {
+		t.Fatalf("unexpected dry run %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := stored(); got["topics"] != "orders" {
+		t.Fatalf("expected a dry run not to be written, got %v", got)
+	}
+
+	for _, tc := range []struct {
+		name, contentType, body string
+		want                    int
+	}{
+		{"orders", jsonPatchMediaType, `[{"op": "test", "path": "/topics", "value": "billing"}]`, http.StatusConflict},
+		{"orders", jsonPatchMediaType, `{"topics": "returns"}`, http.StatusBadRequest},
+		{"orders", mergePatchMediaType, `{"connector.class": null}`, http.StatusUnprocessableEntity},
+		{"orders", mergePatchMediaType, `{"tasks.max": 2}`, http.StatusUnprocessableEntity},
+		{"orders", "text/plain", `topics=returns`, http.StatusUnsupportedMediaType},
+		{"missing", mergePatchMediaType, `{"topics": "returns"}`, http.StatusNotFound},
+	} {
+		if rr := do(tc.name, "", tc.contentType, tc.body); rr.Code != tc.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tc.contentType, tc.body, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Resolution states of a ConfigProvider reference.
+const (
+	referenceResolved   = "resolved"
+	referenceUnresolved = "unresolved"
+	referenceError      = "error"
+	referenceUnchecked  = "unchecked"
+)
+
+// maxReferenceProbes caps the validation requests a single preview makes; references
+// beyond it are reported as unchecked.
+const maxReferenceProbes = 25
+
+// configProviderPattern matches ${provider:[path:]variable}, the syntax Kafka Connect's
+// ConfigTransformer expands on the workers.
+var configProviderPattern = regexp.MustCompile(`\$\{([^}]*?):(?:([^}]*?):)?([^}]*?)\}`)
+
+// ConfigResolution previews how the ConfigProvider references in a connector's config
+// expand on the Connect workers. Config is the rendered config: resolved references are
+// replaced by their value, or by the redaction placeholder when Redacted is set or the
+// key is sensitive, and unresolved ones are left as written.
+type ConfigResolution struct {
+	Connector  string                    `json:"connector"`
+	Redacted   bool                      `json:"redacted"`
+	Config     map[string]string         `json:"config"`
+	References []ConfigProviderReference `json:"references"`
+	// Unresolved counts the references not confirmed to resolve.
+	Unresolved int `json:"unresolved"`
+}
+
+// ConfigProviderReference is one ${provider:path:variable} occurrence in a config value.
+type ConfigProviderReference struct {
+	Key       string `json:"key"`
+	Reference string `json:"reference"`
+	Provider  string `json:"provider"`
+	Path      string `json:"path,omitempty"`
+	Variable  string `json:"variable"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+}
+
+// referenceProbe is the outcome of expanding one reference on the workers.
+type referenceProbe struct {
+	status, message, value string
+}
+
+// findConfigReferences lists the ConfigProvider references in config, sorted by key.
+// Keys holding the proxy's own secret placeholders (proxyKeys) are skipped: those are
+// resolved by the proxy before Connect sees them.
+func findConfigReferences(config map[string]string, proxyKeys map[string]bool) []ConfigProviderReference {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	references := []ConfigProviderReference{}
+	for _, key := range keys {
+		if proxyKeys[key] {
+			continue
+		}
+		for _, match := range configProviderPattern.FindAllStringSubmatch(config[key], -1) {
+			references = append(references, ConfigProviderReference{
+				Key:       key,
+				Reference: match[0],
+				Provider:  match[1],
+				Path:      match[2],
+				Variable:  match[3],
+			})
+		}
+	}
+	return references
+}
+
+// probeConfigReference asks Connect to expand reference by validating a config whose
+// name is the reference: Connect transforms every value before validating, and echoes
+// the name back. A reference that comes back unchanged names a provider the workers do
+// not have, or a variable the provider does not know.
+func probeConfigReference(r *http.Request, cluster, class, reference string) (referenceProbe, error) {
+	config := map[string]interface{}{"connector.class": class, "name": reference}
+	validation, err := validateConnectorConfig(r.Context(), connectClientFor(cluster, routeValidate), connectURLFor(cluster), class, config)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			return referenceProbe{}, err
+		}
+		// Providers that fail, e.g. on a missing file, fail the whole validation.
+		return referenceProbe{status: referenceError, message: err.Error()}, nil
+	}
+
+	value, ok := validation.values["name"]
+	switch {
+	case !ok:
+		return referenceProbe{status: referenceUnchecked, message: "Kafka Connect did not report the expanded value"}, nil
+	case value == reference:
+		return referenceProbe{status: referenceUnresolved, message: "the workers left the reference unexpanded; check the provider is configured in config.providers and knows the variable"}, nil
+	}
+	return referenceProbe{status: referenceResolved, value: value}, nil
+}
+
+// renderResolvedConfig builds the preview of config from the probe of every reference.
+func renderResolvedConfig(rules redactionRules, config map[string]string, proxyKeys map[string]bool, probes map[string]referenceProbe, redact bool) map[string]string {
+	rendered := make(map[string]string, len(config))
+	for key, value := range config {
+		sensitive := rules.isSensitive(key)
+		switch {
+		case proxyKeys[key]:
+			rendered[key] = value
+		case configProviderPattern.MatchString(value):
+			rendered[key] = configProviderPattern.ReplaceAllStringFunc(value, func(reference string) string {
+				probe := probes[reference]
+				if probe.status != referenceResolved {
+					return reference
+				}
+				if redact || sensitive {
+					return rules.placeholder
+				}
+				return probe.value
+			})
+		case sensitive:
+			rendered[key] = rules.placeholder
+		default:
+			rendered[key] = value
+		}
+	}
+	return rendered
+}
+
+// connectorConfigResolvedHandler previews the expansion of the ConfigProvider references
+// in a connector's live config, probing each distinct reference on the workers. Resolved
+// values are redacted unless ?redact=false, and sensitive keys always are.
+func connectorConfigResolvedHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+
+	redact := true
+	if raw := r.URL.Query().Get("redact"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_query", fmt.Sprintf("redact must be true or false, got %q", raw))
+			return
+		}
+		redact = parsed
+	}
+
+	live, err := fetchConnectorConfig(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster), name)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", name))
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		default:
+			writeJSONError(w, http.StatusBadGateway, "config_fetch_failed", err.Error())
+		}
+		return
+	}
+
+	proxyKeys := make(map[string]bool)
+	for key, ref := range secretRefs.get(cluster, name) {
+		if value, ok := live[key]; ok && secretHash(value) == ref.Hash {
+			live[key] = ref.Placeholder
+			proxyKeys[key] = true
+		}
+	}
+
+	result := ConfigResolution{Connector: name, Redacted: redact, References: findConfigReferences(live, proxyKeys)}
+	probes := make(map[string]referenceProbe)
+	for i, reference := range result.References {
+		probe, seen := probes[reference.Reference]
+		if !seen {
+			if len(probes) < maxReferenceProbes {
+				probe, err = probeConfigReference(r, cluster, live["connector.class"], reference.Reference)
+				if err != nil {
+					writeConnectUnavailable(w, err)
+					return
+				}
+			} else {
+				probe = referenceProbe{status: referenceUnchecked, message: fmt.Sprintf("only the first %d distinct references are checked", maxReferenceProbes)}
+			}
+			probes[reference.Reference] = probe
+		}
+		result.References[i].Status, result.References[i].Message = probe.status, probe.message
+		if probe.status != referenceResolved {
+			result.Unresolved++
+		}
+	}
+
+	result.Config = renderResolvedConfig(currentRedactionRules(), live, proxyKeys, probes, redact)
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestFindConfigReferences(t *testing.T) {
+	config := map[string]string{
+		"connection.url":      "jdbc:postgresql://${file:/opt/db.properties:host}/${env:DB_NAME}",
+		"connection.password": "${vault:secret/data/db:password}",
+		"api.key":             "${vault:secret/api#key}",
+		"topics":              "orders",
+	}
+	references := findConfigReferences(config, map[string]bool{"api.key": true})
+	if len(references) != 3 {
+		t.Fatalf("expected 3 references, got %+v", references)
+	}
+	if got := references[0]; got.Key != "connection.password" || got.Provider != "vault" || got.Path != "secret/data/db" || got.Variable != "password" {
+		t.Fatalf("unexpected reference %+v", got)
+	}
+	if got := references[2]; got.Key != "connection.url" || got.Reference != "${env:DB_NAME}" || got.Provider != "env" || got.Path != "" || got.Variable != "DB_NAME" {
+		t.Fatalf("expected a reference without a path, got %+v", got)
+	}
+}
+
+func TestConnectorConfigResolvedHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors/orders-sink/config":
+			json.NewEncoder(w).Encode(map[string]string{
+				"connector.class":     "JdbcSinkConnector",
+				"connection.url":      "jdbc:postgresql://${file:/opt/db.properties:host}/orders",
+				"connection.user":     "${file:/opt/db.properties:user}",
+				"connection.password": "${file:/opt/db.properties:password}",
+				"topics":              "${vault:secret/topics:orders}",
+				"errors.log.enable":   "${file:/missing.properties:errors}",
+			})
+		case r.Method == http.MethodPut && r.URL.Path == "/connector-plugins/JdbcSinkConnector/config/validate":
+			var config map[string]string
+			json.NewDecoder(r.Body).Decode(&config)
+			expanded := map[string]string{
+				"${file:/opt/db.properties:host}":     "db.internal",
+				"${file:/opt/db.properties:user}":     "orders_app",
+				"${file:/opt/db.properties:password}": "hunter2",
+			}
+			if strings.Contains(config["name"], "/missing.properties") {
+				http.Error(w, `{"error_code":500,"message":"Could not read properties from file /missing.properties"}`, http.StatusInternalServerError)
+				return
+			}
+			value, ok := expanded[config["name"]]
+			if !ok {
+				value = config["name"]
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error_count": 0,
+				"configs":     []interface{}{map[string]interface{}{"value": map[string]interface{}{"name": "name", "value": value, "errors": []string{}}}},
+			})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	restore := withTestConnectURL(t, server)
+	defer restore()
+
+	resolve := func(query string) ConfigResolution {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders-sink/config/resolved"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "orders-sink"})
+		rr := httptest.NewRecorder()
+		connectorConfigResolvedHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var result ConfigResolution
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return result
+	}
+
+	result := resolve("")
+	if !result.Redacted || len(result.References) != 5 || result.Unresolved != 2 {
+		t.Fatalf("unexpected resolution %+v", result)
+	}
+	statuses := map[string]string{}
+	for _, reference := range result.References {
+		statuses[reference.Key] = reference.Status
+	}
+	if statuses["connection.url"] != referenceResolved || statuses["topics"] != referenceUnresolved || statuses["errors.log.enable"] != referenceError {
+		t.Fatalf("unexpected statuses %v", statuses)
+	}
+	if got := result.Config["connection.url"]; got != "jdbc:postgresql://"+defaultRedactionPlaceholder+"/orders" {
+		t.Fatalf("expected the resolved host to be redacted, got %q", got)
+	}
+	if got := result.Config["topics"]; got != "${vault:secret/topics:orders}" {
+		t.Fatalf("expected the unresolved reference to be kept, got %q", got)
+	}
+
+	result = resolve("?redact=false")
+	if result.Config["connection.url"] != "jdbc:postgresql://db.internal/orders" || result.Config["connection.user"] != "orders_app" {
+		t.Fatalf("expected resolved values without redaction, got %v", result.Config)
+	}
+	if result.Config["connection.password"] != defaultRedactionPlaceholder {
+		t.Fatalf("expected sensitive keys to stay redacted, got %q", result.Config["connection.password"])
+	}
+}
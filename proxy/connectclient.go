@@ -0,0 +1,33 @@
+package main
+
+import "github.com/mcnabb998/kconnect-console/proxy/internal/connectclient"
+
+// The Kafka Connect REST client lives in internal/connectclient, where kconnect-cli
+// shares it. The proxy keeps using it under these names.
+type (
+	connectorStatusResponse = connectclient.ConnectorStatus
+	connectUnavailableError = connectclient.UnavailableError
+	expandedConnector       = connectclient.ExpandedConnector
+	connectConfigValidation = connectclient.Validation
+	configValidationError   = connectclient.ValidationError
+
+	// DeployPayload is the body of POST /api/{cluster}/deploy.
+	DeployPayload = connectclient.Deployment
+	// DeployConnector is the desired config of one connector of a deployment.
+	DeployConnector = connectclient.DeployConnector
+)
+
+var (
+	errConnectorNotFound = connectclient.ErrConnectorNotFound
+
+	joinURL              = connectclient.JoinURL
+	normalizeState       = connectclient.NormalizeState
+	newStateCounter      = connectclient.NewStateCounter
+	connectorTotalsClass = connectclient.TotalsClass
+
+	fetchConnectorNames            = connectclient.ConnectorNames
+	fetchConnectorStatus           = connectclient.Status
+	fetchExpandedConnectorStatuses = connectclient.Expanded
+	requestConfigValidation        = connectclient.Validate
+	restartConnector               = connectclient.Restart
+)
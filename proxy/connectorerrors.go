@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// errorHistoryRetention is how long an error that is no longer reported by Connect is
+// kept so users can still see what happened.
+const errorHistoryRetention = 24 * time.Hour
+
+var connectorErrors = newErrorTracker(time.Now)
+
+// stackFramePattern matches a frame such as "at com.example.Task.poll(Task.java:42)",
+// which messages like Jackson's "at [Source: ...]" do not.
+var stackFramePattern = regexp.MustCompile(`^at [\w$.<>/-]+\(`)
+
+// JavaException is one exception of a Java stack trace. Location is its innermost frame.
+type JavaException struct {
+	Class    string `json:"class"`
+	Message  string `json:"message,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// parseJavaTrace splits a stack trace into its chain of exceptions, outermost first.
+// Suppressed exceptions and their causes are skipped.
+func parseJavaTrace(trace string) []JavaException {
+	var chain []JavaException
+	inMessage, suppressed := false, false
+
+	header := func(line string) {
+		exception := JavaException{Class: strings.TrimSpace(line)}
+		if i := strings.Index(line, ": "); i > 0 && !strings.ContainsAny(line[:i], " \t") {
+			exception.Class, exception.Message = line[:i], strings.TrimSpace(line[i+2:])
+		}
+		chain = append(chain, exception)
+		inMessage, suppressed = true, false
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(trace, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		indented := len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
+		switch {
+		case len(chain) == 0:
+			if trimmed != "" {
+				header(trimmed)
+			}
+		case !indented && strings.HasPrefix(line, "Caused by: "):
+			header(strings.TrimPrefix(line, "Caused by: "))
+		case indented && (strings.HasPrefix(trimmed, "Suppressed: ") || strings.HasPrefix(trimmed, "Caused by: ")):
+			inMessage, suppressed = false, true
+		case stackFramePattern.MatchString(trimmed):
+			inMessage = false
+			if current := &chain[len(chain)-1]; !suppressed && current.Location == "" {
+				current.Location = strings.TrimPrefix(trimmed, "at ")
+			}
+		case strings.HasPrefix(trimmed, "... ") && strings.HasSuffix(trimmed, " more"):
+			inMessage = false
+		case inMessage && trimmed != "":
+			current := &chain[len(chain)-1]
+			current.Message = strings.TrimSpace(current.Message + "\n" + trimmed)
+		}
+	}
+	return chain
+}
+
+// ConnectorErrorGroup is a distinct error reported by a connector or its tasks. Sources
+// lists where it is currently reported ("connector", "task-0", ...).
+type ConnectorErrorGroup struct {
+	ID        string        `json:"id"`
+	Exception JavaException `json:"exception"`
+	RootCause JavaException `json:"rootCause"`
+	Chain     []string      `json:"chain"`
+	Sources   []string      `json:"sources"`
+	Active    bool          `json:"active"`
+	FirstSeen time.Time     `json:"firstSeen"`
+	LastSeen  time.Time     `json:"lastSeen"`
+	Trace     string        `json:"trace,omitempty"`
+}
+
+// ConnectorErrors is returned by GET /api/{cluster}/connectors/{name}/errors.
+type ConnectorErrors struct {
+	Connector string                `json:"connector"`
+	State     string                `json:"state"`
+	Errors    []ConnectorErrorGroup `json:"errors"`
+}
+
+// groupTraces parses the traces of a connector status and merges identical errors.
+// Errors are identical when their exception chain and root cause message match.
+func groupTraces(status connectorStatusResponse) map[string]ConnectorErrorGroup {
+	groups := make(map[string]ConnectorErrorGroup)
+	add := func(source, trace string) {
+		chain := parseJavaTrace(trace)
+		if len(chain) == 0 {
+			return
+		}
+		classes := make([]string, len(chain))
+		for i, exception := range chain {
+			classes[i] = exception.Class
+		}
+		root := chain[len(chain)-1]
+		sum := sha256.Sum256([]byte(strings.Join(classes, "<") + "\n" + root.Message))
+		id := hex.EncodeToString(sum[:6])
+
+		group, ok := groups[id]
+		if !ok {
+			group = ConnectorErrorGroup{ID: id, Exception: chain[0], RootCause: root, Chain: classes, Trace: trace}
+		}
+		group.Sources = append(group.Sources, source)
+		groups[id] = group
+	}
+
+	if status.Connector.Trace != "" {
+		add("connector", status.Connector.Trace)
+	}
+	for _, task := range status.Tasks {
+		if task.Trace != "" {
+			add("task-"+strconv.Itoa(task.ID), task.Trace)
+		}
+	}
+	return groups
+}
+
+// errorTracker remembers when each error group was first and last seen per connector.
+type errorTracker struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	clusters map[string]map[string]map[string]ConnectorErrorGroup
+}
+
+func newErrorTracker(now func() time.Time) *errorTracker {
+	return &errorTracker{now: now, clusters: make(map[string]map[string]map[string]ConnectorErrorGroup)}
+}
+
+// record merges the errors in status into the history of the connector and returns the
+// history, active errors first and most recent first.
+func (t *errorTracker) record(cluster string, status connectorStatusResponse) []ConnectorErrorGroup {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.recordLocked(cluster, status)
+}
+
+func (t *errorTracker) recordLocked(cluster string, status connectorStatusResponse) []ConnectorErrorGroup {
+	now := t.now().UTC()
+	connectors, ok := t.clusters[cluster]
+	if !ok {
+		connectors = make(map[string]map[string]ConnectorErrorGroup)
+		t.clusters[cluster] = connectors
+	}
+	history, ok := connectors[status.Name]
+	if !ok {
+		history = make(map[string]ConnectorErrorGroup)
+		connectors[status.Name] = history
+	}
+
+	current := groupTraces(status)
+	for id, group := range current {
+		group.FirstSeen = now
+		if previous, ok := history[id]; ok && previous.Active {
+			group.FirstSeen = previous.FirstSeen
+		}
+		group.Active, group.LastSeen = true, now
+		history[id] = group
+	}
+	for id, group := range history {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		if now.Sub(group.LastSeen) > errorHistoryRetention {
+			delete(history, id)
+			continue
+		}
+		group.Active, group.Sources = false, []string{}
+		history[id] = group
+	}
+	if len(history) == 0 {
+		delete(connectors, status.Name)
+	}
+
+	result := make([]ConnectorErrorGroup, 0, len(history))
+	for _, group := range history {
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Active != result[j].Active {
+			return result[i].Active
+		}
+		if !result[i].LastSeen.Equal(result[j].LastSeen) {
+			return result[i].LastSeen.After(result[j].LastSeen)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// observe is a statusObserver that keeps error history current between requests.
+// Connectors missing from the poll were deleted and are forgotten.
+func (t *errorTracker) observe(_ string, statuses []connectorStatusResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		seen[status.Name] = true
+		t.recordLocked(alertMetadataCluster, status)
+	}
+	for name := range t.clusters[alertMetadataCluster] {
+		if !seen[name] {
+			delete(t.clusters[alertMetadataCluster], name)
+		}
+	}
+}
+
+// connectorErrorsHandler returns the parsed, grouped errors of a connector and its tasks.
+// Full traces are included with ?trace=true.
+func connectorErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+
+	status, err := fetchConnectorStatus(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster), name)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", name))
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		default:
+			writeJSONError(w, http.StatusBadGateway, "status_fetch_failed", err.Error())
+		}
+		return
+	}
+	if status.Name == "" {
+		status.Name = name
+	}
+
+	groups := connectorErrors.record(cluster, status)
+	if includeTrace, _ := strconv.ParseBool(r.URL.Query().Get("trace")); !includeTrace {
+		for i := range groups {
+			groups[i].Trace = ""
+		}
+	}
+	writeJSON(w, http.StatusOK, ConnectorErrors{Connector: name, State: normalizeState(status.Connector.State), Errors: groups})
+}
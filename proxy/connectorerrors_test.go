@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const testSinkTrace = "org.apache.kafka.connect.errors.ConnectException: Tolerance exceeded in error handler\n" +
+	"\tat org.apache.kafka.connect.runtime.errors.RetryWithToleranceOperator.execAndHandleError(RetryWithToleranceOperator.java:206)\n" +
+	"\tat org.apache.kafka.connect.runtime.WorkerSinkTask.convertAndTransformRecord(WorkerSinkTask.java:516)\n" +
+	"Caused by: org.apache.kafka.connect.errors.DataException: Converting byte[] to Kafka Connect data failed\n" +
+	"\tat org.apache.kafka.connect.json.JsonConverter.toConnectData(JsonConverter.java:324)\n" +
+	"\t... 13 more\n" +
+	"Caused by: org.apache.kafka.common.errors.SerializationException: Unrecognized token 'abc': was expecting JSON\n" +
+	" at [Source: (byte[])\"abc\"; line: 1, column: 4]\n" +
+	"\tat org.apache.kafka.connect.json.JsonDeserializer.deserialize(JsonDeserializer.java:66)\n" +
+	"\tSuppressed: java.io.IOException: close failed\n" +
+	"\t\tat com.example.Cleanup.close(Cleanup.java:10)\n" +
+	"\t... 14 more\n"
+
+func TestParseJavaTrace(t *testing.T) {
+	chain := parseJavaTrace(testSinkTrace)
+	if len(chain) != 3 {
+		t.Fatalf("expected 3 exceptions, got %+v", chain)
+	}
+	if chain[0].Class != "org.apache.kafka.connect.errors.ConnectException" || chain[0].Message != "Tolerance exceeded in error handler" ||
+		chain[0].Location != "org.apache.kafka.connect.runtime.errors.RetryWithToleranceOperator.execAndHandleError(RetryWithToleranceOperator.java:206)" {
+		t.Fatalf("unexpected top exception %+v", chain[0])
+	}
+	root := chain[2]
+	if root.Class != "org.apache.kafka.common.errors.SerializationException" ||
+		root.Message != "Unrecognized token 'abc': was expecting JSON\nat [Source: (byte[])\"abc\"; line: 1, column: 4]" ||
+		root.Location != "org.apache.kafka.connect.json.JsonDeserializer.deserialize(JsonDeserializer.java:66)" {
+		t.Fatalf("unexpected root cause %+v", root)
+	}
+
+	if chain := parseJavaTrace("java.lang.NullPointerException\n\tat com.example.Task.poll(Task.java:42)"); len(chain) != 1 || chain[0].Class != "java.lang.NullPointerException" || chain[0].Message != "" {
+		t.Fatalf("unexpected chain without message %+v", chain)
+	}
+	if chain := parseJavaTrace("  \n"); len(chain) != 0 {
+		t.Fatalf("expected no exceptions for an empty trace, got %+v", chain)
+	}
+}
+
+func testErrorStatus(t *testing.T, body string) connectorStatusResponse {
+	t.Helper()
+	var status connectorStatusResponse
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatalf("unmarshal status: %v", err)
+	}
+	return status
+}
+
+func TestErrorTrackerGroupsAndTimestamps(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newErrorTracker(func() time.Time { return now })
+	trace, _ := json.Marshal(testSinkTrace)
+	failing := testErrorStatus(t, `{"name":"orders-sink","connector":{"state":"RUNNING"},"tasks":[
+		{"id":0,"state":"FAILED","trace":`+string(trace)+`},
+		{"id":1,"state":"RUNNING"},
+		{"id":2,"state":"FAILED","trace":`+string(trace)+`}]}`)
+
+	groups := tracker.record("default", failing)
+	if len(groups) != 1 || strings.Join(groups[0].Sources, ",") != "task-0,task-2" || !groups[0].Active || len(groups[0].Chain) != 3 {
+		t.Fatalf("expected one group for both tasks, got %+v", groups)
+	}
+	first := groups[0]
+
+	now = now.Add(time.Minute)
+	groups = tracker.record("default", failing)
+	if groups[0].ID != first.ID || !groups[0].FirstSeen.Equal(first.FirstSeen) || !groups[0].LastSeen.Equal(now) {
+		t.Fatalf("expected the first seen time to be kept, got %+v", groups[0])
+	}
+
+	now = now.Add(time.Minute)
+	recovered := testErrorStatus(t, `{"name":"orders-sink","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"}]}`)
+	groups = tracker.record("default", recovered)
+	if len(groups) != 1 || groups[0].Active || len(groups[0].Sources) != 0 || groups[0].LastSeen.Equal(now) {
+		t.Fatalf("expected the cleared error to stay as inactive history, got %+v", groups)
+	}
+
+	now = now.Add(errorHistoryRetention + time.Minute)
+	if groups = tracker.record("default", recovered); len(groups) != 0 {
+		t.Fatalf("expected old errors to be pruned, got %+v", groups)
+	}
+}
+
+func TestErrorTrackerObserveForgetsDeletedConnectors(t *testing.T) {
+	tracker := newErrorTracker(time.Now)
+	trace, _ := json.Marshal("java.lang.IllegalStateException: boom")
+	tracker.observe("default", []connectorStatusResponse{
+		testErrorStatus(t, `{"name":"orders-sink","connector":{"state":"FAILED","trace":`+string(trace)+`}}`),
+	})
+	if len(tracker.clusters[alertMetadataCluster]["orders-sink"]) != 1 {
+		t.Fatalf("expected the polled error to be recorded, got %+v", tracker.clusters)
+	}
+	tracker.observe("default", nil)
+	if _, ok := tracker.clusters[alertMetadataCluster]["orders-sink"]; ok {
+		t.Fatalf("expected a deleted connector to be forgotten")
+	}
+}
+
+func TestConnectorErrorsHandler(t *testing.T) {
+	status := `{"name":"orders-sink","connector":{"state":"FAILED","trace":"java.lang.IllegalStateException: boom\n\tat com.example.Sink.start(Sink.java:12)"},"tasks":[]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connectors/orders-sink/status" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(status))
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	original := connectorErrors
+	connectorErrors = newErrorTracker(time.Now)
+	t.Cleanup(func() { connectorErrors = original })
+
+	get := func(name, query string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connectors/"+name+"/errors"+query, nil), map[string]string{"cluster": "default", "name": name})
+		rr := httptest.NewRecorder()
+		connectorErrorsHandler(rr, req)
+		return rr
+	}
+
+	rr := get("orders-sink", "")
+	var result ConnectorErrors
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if result.State != "failed" || len(result.Errors) != 1 || result.Errors[0].RootCause.Message != "boom" ||
+		result.Errors[0].RootCause.Location != "com.example.Sink.start(Sink.java:12)" || result.Errors[0].Trace != "" {
+		t.Fatalf("unexpected errors %s", rr.Body.String())
+	}
+
+	rr = get("orders-sink", "?trace=true")
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	if !strings.HasPrefix(result.Errors[0].Trace, "java.lang.IllegalStateException: boom") {
+		t.Fatalf("expected the full trace with ?trace=true, got %s", rr.Body.String())
+	}
+
+	if rr := get("missing", ""); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "connector_not_found") {
+		t.Fatalf("expected 404 for an unknown connector, got %d %s", rr.Code, rr.Body.String())
+	}
+}
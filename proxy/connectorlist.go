@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultConnectorPageSize = 50
+	maxConnectorPageSize     = 500
+)
+
+// expandedConnector is one entry of GET /connectors?expand=info&expand=status.
+// ConnectorListItem is a row of the paginated connectors list.
+type ConnectorListItem struct {
+	Name           string         `json:"name"`
+	Type           string         `json:"type"`
+	State          string         `json:"state"`
+	WorkerID       string         `json:"workerId,omitempty"`
+	ConnectorClass string         `json:"connectorClass,omitempty"`
+	Tasks          int            `json:"tasks"`
+	TaskStates     map[string]int `json:"taskStates"`
+	Tags           []string       `json:"tags,omitempty"`
+}
+
+// failed reports whether the connector or any of its tasks is FAILED.
+func (c ConnectorListItem) failed() bool {
+	return c.State == "failed" || c.TaskStates["failed"] > 0
+}
+
+// ConnectorPage is returned by GET /api/{cluster}/connectors/expanded.
+type ConnectorPage struct {
+	Connectors []ConnectorListItem `json:"connectors"`
+	Total      int                 `json:"total"`
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"pageSize"`
+	TotalPages int                 `json:"totalPages"`
+}
+
+// connectorListQuery holds the filter, sort and page parameters of the list endpoint.
+type connectorListQuery struct {
+	States     []string
+	Type       string
+	Tags       []string
+	Search     string
+	SortKey    string
+	Descending bool
+	Page       int
+	PageSize   int
+}
+
+var connectorSortKeys = map[string]func(a, b ConnectorListItem) int{
+	"name":  func(a, b ConnectorListItem) int { return strings.Compare(a.Name, b.Name) },
+	"state": func(a, b ConnectorListItem) int { return strings.Compare(a.State, b.State) },
+	"type":  func(a, b ConnectorListItem) int { return strings.Compare(a.Type, b.Type) },
+	"class": func(a, b ConnectorListItem) int { return strings.Compare(a.ConnectorClass, b.ConnectorClass) },
+	"tasks": func(a, b ConnectorListItem) int { return a.Tasks - b.Tasks },
+}
+
+func positiveIntParam(query url.Values, name string, fallback int) (int, error) {
+	value := strings.TrimSpace(query.Get(name))
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+func parseConnectorListQuery(query url.Values) (connectorListQuery, error) {
+	q := connectorListQuery{
+		Type:    strings.ToLower(strings.TrimSpace(query.Get("type"))),
+		Search:  strings.ToLower(strings.TrimSpace(query.Get("search"))),
+		Tags:    splitList(query.Get("tag")),
+		SortKey: "name",
+	}
+
+	for _, state := range splitList(query.Get("state")) {
+		state = strings.ToLower(state)
+		if normalizeState(state) == "unknown" && state != "unknown" {
+			return q, fmt.Errorf("unknown state %q", state)
+		}
+		q.States = append(q.States, state)
+	}
+	if q.Type != "" && q.Type != "source" && q.Type != "sink" {
+		return q, fmt.Errorf("type must be source or sink")
+	}
+
+	if sortParam := strings.TrimSpace(query.Get("sort")); sortParam != "" {
+		q.SortKey, q.Descending = strings.TrimPrefix(sortParam, "-"), strings.HasPrefix(sortParam, "-")
+		if _, ok := connectorSortKeys[q.SortKey]; !ok {
+			return q, fmt.Errorf("unknown sort key %q", q.SortKey)
+		}
+	}
+
+	var err error
+	if q.Page, err = positiveIntParam(query, "page", 1); err != nil {
+		return q, err
+	}
+	if q.PageSize, err = positiveIntParam(query, "pageSize", defaultConnectorPageSize); err != nil {
+		return q, err
+	}
+	if q.PageSize > maxConnectorPageSize {
+		q.PageSize = maxConnectorPageSize
+	}
+	return q, nil
+}
+
+// matches applies the state, type, tag and search filters. A state of "failed" also
+// matches connectors that are running with failed tasks; several tags match connectors
+// with any of them.
+func (q connectorListQuery) matches(c ConnectorListItem) bool {
+	if len(q.States) > 0 {
+		found := false
+		for _, state := range q.States {
+			if c.State == state || (state == "failed" && c.failed()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Type != "" && c.Type != q.Type {
+		return false
+	}
+	if len(q.Tags) > 0 && !hasAnyTag(c.Tags, q.Tags) {
+		return false
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(c.Name), q.Search) && !strings.Contains(strings.ToLower(c.ConnectorClass), q.Search) {
+		return false
+	}
+	return true
+}
+
+// apply filters, sorts and paginates items. Ties sort by name so pages are stable.
+func (q connectorListQuery) apply(items []ConnectorListItem) ConnectorPage {
+	filtered := make([]ConnectorListItem, 0, len(items))
+	for _, item := range items {
+		if q.matches(item) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	compare := connectorSortKeys[q.SortKey]
+	sort.SliceStable(filtered, func(i, j int) bool {
+		c := compare(filtered[i], filtered[j])
+		if c == 0 {
+			return filtered[i].Name < filtered[j].Name
+		}
+		if q.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	page := ConnectorPage{
+		Connectors: []ConnectorListItem{},
+		Total:      len(filtered),
+		Page:       q.Page,
+		PageSize:   q.PageSize,
+		TotalPages: int(math.Ceil(float64(len(filtered)) / float64(q.PageSize))),
+	}
+	start := (q.Page - 1) * q.PageSize
+	if start < len(filtered) {
+		end := start + q.PageSize
+		if end > len(filtered) {
+			end = len(filtered)
+		}
+		page.Connectors = filtered[start:end]
+	}
+	return page
+}
+
+// fetchConnectorList returns a list row for every connector.
+func fetchConnectorList(ctx context.Context, client *http.Client, baseURL string) ([]ConnectorListItem, error) {
+	expanded, err := fetchExpandedConnectorStatuses(ctx, client, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ConnectorListItem, 0, len(expanded))
+	for name, connector := range expanded {
+		item := ConnectorListItem{
+			Name:           name,
+			Type:           connector.Status.Type,
+			State:          normalizeState(connector.Status.Connector.State),
+			WorkerID:       connector.Status.Connector.WorkerID,
+			ConnectorClass: connector.Info.Config["connector.class"],
+			Tasks:          len(connector.Status.Tasks),
+			TaskStates:     make(map[string]int),
+		}
+		if item.Type == "" {
+			item.Type = connector.Info.Type
+		}
+		for _, task := range connector.Status.Tasks {
+			item.TaskStates[normalizeState(task.State)]++
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// connectorListHandler serves a filtered, sorted page of connectors so large clusters do
+// not need a status request per connector from the browser.
+func connectorListHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseConnectorListQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+
+	cluster := mux.Vars(r)["cluster"]
+	items, err := fetchConnectorList(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+	metadata := connectorMetadata.all(cluster)
+	scope := tenantScopeFor(r)
+	visible := items[:0]
+	for _, item := range items {
+		item.Tags = metadata[item.Name].Tags
+		if scope.allows(item.Name, item.Tags) {
+			visible = append(visible, item)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, query.apply(visible))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const expandedConnectorsFixture = `{
+  "orders-sink": {
+    "info": {"config": {"connector.class": "io.confluent.connect.jdbc.JdbcSinkConnector"}, "type": "sink"},
+    "status": {"name": "orders-sink", "connector": {"state": "RUNNING", "worker_id": "w1:8083"}, "tasks": [{"id": 0, "state": "RUNNING"}, {"id": 1, "state": "FAILED"}], "type": "sink"}
+  },
+  "payments-cdc": {
+    "info": {"config": {"connector.class": "io.debezium.connector.postgresql.PostgresConnector"}, "type": "source"},
+    "status": {"name": "payments-cdc", "connector": {"state": "RUNNING", "worker_id": "w2:8083"}, "tasks": [{"id": 0, "state": "RUNNING"}], "type": "source"}
+  },
+  "audit-s3": {
+    "info": {"config": {"connector.class": "io.confluent.connect.s3.S3SinkConnector"}, "type": "sink"},
+    "status": {"name": "audit-s3", "connector": {"state": "PAUSED", "worker_id": "w1:8083"}, "tasks": [], "type": "sink"}
+  }
+}`
+
+func TestConnectorListQueryApply(t *testing.T) {
+	items := []ConnectorListItem{
+		{Name: "b", Type: "sink", State: "running", Tasks: 2, TaskStates: map[string]int{"running": 1, "failed": 1}},
+		{Name: "a", Type: "source", State: "running", Tasks: 1, TaskStates: map[string]int{"running": 1}, Tags: []string{"payments"}},
+		{Name: "c", Type: "sink", State: "paused", ConnectorClass: "S3SinkConnector", TaskStates: map[string]int{}, Tags: []string{"analytics", "pii"}},
+	}
+
+	query, err := parseConnectorListQuery(url.Values{"state": {"failed"}})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if page := query.apply(items); page.Total != 1 || page.Connectors[0].Name != "b" {
+		t.Fatalf("expected failed tasks to match state=failed, got %+v", page)
+	}
+
+	query, _ = parseConnectorListQuery(url.Values{"type": {"sink"}, "sort": {"-tasks"}})
+	if page := query.apply(items); page.Total != 2 || page.Connectors[0].Name != "b" || page.Connectors[1].Name != "c" {
+		t.Fatalf("unexpected sink order: %+v", page.Connectors)
+	}
+
+	query, _ = parseConnectorListQuery(url.Values{"search": {"s3sink"}})
+	if page := query.apply(items); page.Total != 1 || page.Connectors[0].Name != "c" {
+		t.Fatalf("expected search to match connector class, got %+v", page.Connectors)
+	}
+
+	query, _ = parseConnectorListQuery(url.Values{"tag": {"pii, payments"}})
+	if page := query.apply(items); page.Total != 2 || page.Connectors[0].Name != "a" || page.Connectors[1].Name != "c" {
+		t.Fatalf("expected connectors with any of the tags, got %+v", page.Connectors)
+	}
+
+	query, _ = parseConnectorListQuery(url.Values{"pageSize": {"2"}, "page": {"2"}})
+	page := query.apply(items)
+	if page.Total != 3 || page.TotalPages != 2 || len(page.Connectors) != 1 || page.Connectors[0].Name != "c" {
+		t.Fatalf("unexpected second page: %+v", page)
+	}
+
+	query, _ = parseConnectorListQuery(url.Values{"page": {"5"}})
+	if page := query.apply(items); len(page.Connectors) != 0 || page.Connectors == nil {
+		t.Fatalf("expected an empty page past the end, got %+v", page.Connectors)
+	}
+}
+
+func TestParseConnectorListQueryRejectsInvalidParameters(t *testing.T) {
+	for _, values := range []url.Values{
+		{"page": {"0"}},
+		{"pageSize": {"abc"}},
+		{"sort": {"owner"}},
+		{"type": {"both"}},
+		{"state": {"broken"}},
+	} {
+		if _, err := parseConnectorListQuery(values); err == nil {
+			t.Fatalf("expected %v to be rejected", values)
+		}
+	}
+
+	query, err := parseConnectorListQuery(url.Values{"pageSize": {"10000"}})
+	if err != nil || query.PageSize != maxConnectorPageSize {
+		t.Fatalf("expected pageSize to be capped, got %d (%v)", query.PageSize, err)
+	}
+}
+
+func TestConnectorListHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connectors" || len(r.URL.Query()["expand"]) != 2 {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(expandedConnectorsFixture))
+	}))
+	defer server.Close()
+	restore := withTestConnectURL(t, server)
+	defer restore()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/expanded?type=sink&pageSize=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	connectorListHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page ConnectorPage
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if page.Total != 2 || page.TotalPages != 2 || len(page.Connectors) != 1 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	first := page.Connectors[0]
+	if first.Name != "audit-s3" || first.State != "paused" || first.ConnectorClass != "io.confluent.connect.s3.S3SinkConnector" || first.WorkerID != "w1:8083" {
+		t.Fatalf("unexpected connector: %+v", first)
+	}
+}
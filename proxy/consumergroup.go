@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+const (
+	// consumerGroupTimeout bounds the admin requests behind one inspection.
+	consumerGroupTimeout = 10 * time.Second
+
+	// consumerGroupOverride is the connector config key that replaces the default
+	// connect-{name} group of a sink connector.
+	consumerGroupOverride = "consumer.override.group.id"
+)
+
+var (
+	// consumerGroupStuckAfter is how long a partition with lag may keep the same
+	// committed offset before it is flagged as stuck.
+	consumerGroupStuckAfter = getEnv("CONSUMER_GROUP_STUCK_AFTER", "5m")
+
+	consumerGroups       consumerGroupInspector = newKafkaGroupInspector(splitList(kafkaBootstrapServers))
+	consumerGroupOffsets                        = newGroupOffsetTracker(5*time.Minute, time.Now)
+
+	errConsumerGroupNotFound = errors.New("consumer group not found")
+)
+
+type topicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+// consumerGroupSnapshot is what the Kafka admin API reports about a group.
+type consumerGroupSnapshot struct {
+	State     string
+	Members   []ConsumerGroupMember
+	Committed map[topicPartition]int64
+	End       map[topicPartition]int64
+}
+
+// consumerGroupInspector describes a consumer group with its committed and end offsets.
+type consumerGroupInspector interface {
+	enabled() bool
+	inspect(ctx context.Context, group string) (consumerGroupSnapshot, error)
+}
+
+// kafkaGroupInspector answers inspections with DescribeGroups, OffsetFetch and
+// ListOffsets requests; it never joins the group.
+type kafkaGroupInspector struct {
+	brokers []string
+}
+
+func newKafkaGroupInspector(brokers []string) *kafkaGroupInspector {
+	return &kafkaGroupInspector{brokers: brokers}
+}
+
+func (k *kafkaGroupInspector) enabled() bool {
+	return len(k.brokers) > 0
+}
+
+func (k *kafkaGroupInspector) inspect(ctx context.Context, group string) (consumerGroupSnapshot, error) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(k.brokers...))
+	if err != nil {
+		return consumerGroupSnapshot{}, err
+	}
+	defer client.Close()
+
+	snapshot := consumerGroupSnapshot{Committed: map[topicPartition]int64{}, End: map[topicPartition]int64{}}
+
+	describe := kmsg.NewPtrDescribeGroupsRequest()
+	describe.Groups = []string{group}
+	described, err := describe.RequestWith(ctx, client)
+	if err != nil {
+		return snapshot, fmt.Errorf("describe group %s: %w", group, err)
+	}
+	if len(described.Groups) == 1 {
+		g := described.Groups[0]
+		if err := kerr.ErrorForCode(g.ErrorCode); err != nil && !errors.Is(err, kerr.GroupIDNotFound) {
+			return snapshot, fmt.Errorf("describe group %s: %w", group, err)
+		}
+		snapshot.State = g.State
+		for _, m := range g.Members {
+			member := ConsumerGroupMember{MemberID: m.MemberID, ClientID: m.ClientID, Host: m.ClientHost, Assignments: map[string][]int32{}}
+			var assignment kmsg.ConsumerMemberAssignment
+			if g.ProtocolType == "consumer" && assignment.ReadFrom(m.MemberAssignment) == nil {
+				for _, topic := range assignment.Topics {
+					member.Assignments[topic.Topic] = append(member.Assignments[topic.Topic], topic.Partitions...)
+				}
+			}
+			snapshot.Members = append(snapshot.Members, member)
+		}
+	}
+
+	fetch := kmsg.NewPtrOffsetFetchRequest()
+	fetch.Group = group
+	fetched, err := fetch.RequestWith(ctx, client)
+	if err != nil {
+		return snapshot, fmt.Errorf("fetch offsets of %s: %w", group, err)
+	}
+	if err := kerr.ErrorForCode(fetched.ErrorCode); err != nil {
+		return snapshot, fmt.Errorf("fetch offsets of %s: %w", group, err)
+	}
+	for _, topic := range fetched.Topics {
+		for _, p := range topic.Partitions {
+			if p.ErrorCode == 0 && p.Offset >= 0 {
+				snapshot.Committed[topicPartition{topic.Topic, p.Partition}] = p.Offset
+			}
+		}
+	}
+
+	// End offsets for every partition that is committed or assigned.
+	partitions := make(map[string][]int32)
+	for tp := range snapshot.Committed {
+		partitions[tp.Topic] = append(partitions[tp.Topic], tp.Partition)
+	}
+	for _, member := range snapshot.Members {
+		for topic, ps := range member.Assignments {
+			for _, p := range ps {
+				if _, ok := snapshot.Committed[topicPartition{topic, p}]; !ok {
+					partitions[topic] = append(partitions[topic], p)
+				}
+			}
+		}
+	}
+	if len(partitions) == 0 {
+		return snapshot, nil
+	}
+	list := kmsg.NewPtrListOffsetsRequest()
+	for topic, ps := range partitions {
+		reqTopic := kmsg.NewListOffsetsRequestTopic()
+		reqTopic.Topic = topic
+		for _, p := range ps {
+			reqPartition := kmsg.NewListOffsetsRequestTopicPartition()
+			reqPartition.Partition = p
+			reqPartition.Timestamp = -1 // latest
+			reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
+		}
+		list.Topics = append(list.Topics, reqTopic)
+	}
+	listed, err := list.RequestWith(ctx, client)
+	if err != nil {
+		return snapshot, fmt.Errorf("list end offsets for %s: %w", group, err)
+	}
+	for _, topic := range listed.Topics {
+		for _, p := range topic.Partitions {
+			if p.ErrorCode == 0 {
+				snapshot.End[topicPartition{topic.Topic, p.Partition}] = p.Offset
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// groupOffsetTracker remembers when each committed offset was first seen, so a
+// partition whose offset has not moved across inspections can be flagged.
+type groupOffsetTracker struct {
+	mu         sync.Mutex
+	stuckAfter time.Duration
+	now        func() time.Time
+	seen       map[string]map[topicPartition]offsetObservation
+}
+
+type offsetObservation struct {
+	offset int64
+	since  time.Time
+}
+
+func newGroupOffsetTracker(stuckAfter time.Duration, now func() time.Time) *groupOffsetTracker {
+	return &groupOffsetTracker{stuckAfter: stuckAfter, now: now, seen: make(map[string]map[topicPartition]offsetObservation)}
+}
+
+// observe records the committed offsets of a group and returns, per partition, since
+// when the offset has been unchanged. Partitions no longer committed are forgotten.
+func (t *groupOffsetTracker) observe(group string, committed map[topicPartition]int64) (map[topicPartition]time.Time, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now().UTC()
+	previous := t.seen[group]
+	current := make(map[topicPartition]offsetObservation, len(committed))
+	unchanged := make(map[topicPartition]time.Time, len(committed))
+	for tp, offset := range committed {
+		observation := offsetObservation{offset: offset, since: now}
+		if last, ok := previous[tp]; ok && last.offset == offset {
+			observation.since = last.since
+		}
+		current[tp] = observation
+		unchanged[tp] = observation.since
+	}
+	t.seen[group] = current
+	return unchanged, now
+}
+
+// ConsumerGroupMember is one consumer of a sink connector's group.
+type ConsumerGroupMember struct {
+	MemberID    string             `json:"memberId"`
+	ClientID    string             `json:"clientId"`
+	Host        string             `json:"host"`
+	Assignments map[string][]int32 `json:"assignments"`
+}
+
+// ConsumerGroupPartition is the progress of the group on one partition. Stuck means
+// the partition has lag and its committed offset has not moved for StuckAfterSeconds.
+type ConsumerGroupPartition struct {
+	Topic           string     `json:"topic"`
+	Partition       int32      `json:"partition"`
+	CommittedOffset *int64     `json:"committedOffset"`
+	EndOffset       *int64     `json:"endOffset"`
+	Lag             *int64     `json:"lag"`
+	MemberID        string     `json:"memberId,omitempty"`
+	ClientID        string     `json:"clientId,omitempty"`
+	UnchangedSince  *time.Time `json:"unchangedSince,omitempty"`
+	Stuck           bool       `json:"stuck"`
+}
+
+// ConsumerGroupReport is returned by GET /api/{cluster}/connectors/{name}/consumer-group.
+type ConsumerGroupReport struct {
+	Connector         string                   `json:"connector"`
+	Group             string                   `json:"group"`
+	GroupSource       string                   `json:"groupSource"`
+	State             string                   `json:"state"`
+	Members           []ConsumerGroupMember    `json:"members"`
+	Partitions        []ConsumerGroupPartition `json:"partitions"`
+	TotalLag          int64                    `json:"totalLag"`
+	StuckPartitions   int                      `json:"stuckPartitions"`
+	StuckAfterSeconds int64                    `json:"stuckAfterSeconds"`
+	ObservedAt        time.Time                `json:"observedAt"`
+}
+
+// sinkConsumerGroup returns the group of a sink connector and where the name came from.
+func sinkConsumerGroup(name string, config map[string]string) (group, source string) {
+	if override := strings.TrimSpace(config[consumerGroupOverride]); override != "" {
+		return override, consumerGroupOverride
+	}
+	return "connect-" + name, "default"
+}
+
+// buildConsumerGroupReport joins the snapshot with the member assignments and the
+// offset history.
+func buildConsumerGroupReport(name, group, source string, snapshot consumerGroupSnapshot, tracker *groupOffsetTracker) ConsumerGroupReport {
+	unchanged, now := tracker.observe(group, snapshot.Committed)
+	report := ConsumerGroupReport{
+		Connector:         name,
+		Group:             group,
+		GroupSource:       source,
+		State:             snapshot.State,
+		Members:           snapshot.Members,
+		Partitions:        []ConsumerGroupPartition{},
+		StuckAfterSeconds: int64(tracker.stuckAfter / time.Second),
+		ObservedAt:        now,
+	}
+	if report.Members == nil {
+		report.Members = []ConsumerGroupMember{}
+	}
+
+	owners := make(map[topicPartition]ConsumerGroupMember)
+	partitions := make(map[topicPartition]bool)
+	for _, member := range snapshot.Members {
+		for topic, ps := range member.Assignments {
+			for _, p := range ps {
+				owners[topicPartition{topic, p}] = member
+				partitions[topicPartition{topic, p}] = true
+			}
+		}
+	}
+	for tp := range snapshot.Committed {
+		partitions[tp] = true
+	}
+
+	for tp := range partitions {
+		entry := ConsumerGroupPartition{Topic: tp.Topic, Partition: tp.Partition}
+		if owner, ok := owners[tp]; ok {
+			entry.MemberID, entry.ClientID = owner.MemberID, owner.ClientID
+		}
+		committed, hasCommit := snapshot.Committed[tp]
+		end, hasEnd := snapshot.End[tp]
+		if hasCommit {
+			entry.CommittedOffset = &committed
+			since := unchanged[tp]
+			entry.UnchangedSince = &since
+		}
+		if hasEnd {
+			entry.EndOffset = &end
+		}
+		if hasCommit && hasEnd {
+			lag := end - committed
+			if lag < 0 {
+				lag = 0
+			}
+			entry.Lag = &lag
+			report.TotalLag += lag
+			entry.Stuck = lag > 0 && now.Sub(unchanged[tp]) >= tracker.stuckAfter
+		}
+		if entry.Stuck {
+			report.StuckPartitions++
+		}
+		report.Partitions = append(report.Partitions, entry)
+	}
+	sort.Slice(report.Partitions, func(i, j int) bool {
+		a, b := report.Partitions[i], report.Partitions[j]
+		if a.Topic != b.Topic {
+			return a.Topic < b.Topic
+		}
+		return a.Partition < b.Partition
+	})
+	return report
+}
+
+// connectorConsumerGroupHandler inspects the consumer group of a sink connector:
+// members and their assignments, committed and end offsets, lag per partition, and
+// partitions whose committed offset has stopped moving while they still have lag.
+func connectorConsumerGroupHandler(w http.ResponseWriter, r *http.Request) {
+	inspector := consumerGroups
+	if !inspector.enabled() {
+		writeJSONError(w, http.StatusNotImplemented, "kafka_unavailable", "set KAFKA_BOOTSTRAP_SERVERS to inspect consumer groups")
+		return
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	client := connectClientFor(vars["cluster"], routeRead)
+	baseURL := connectURLFor(vars["cluster"])
+	writeConnectError := func(err error) {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %s not found", name))
+		default:
+			writeJSONError(w, http.StatusBadGateway, "connector_fetch_failed", err.Error())
+		}
+	}
+
+	status, err := fetchConnectorStatus(r.Context(), client, baseURL, name)
+	if err != nil {
+		writeConnectError(err)
+		return
+	}
+	if status.Type != "" && status.Type != "sink" {
+		writeJSONError(w, http.StatusBadRequest, "not_a_sink_connector", fmt.Sprintf("connector %s is a %s connector; only sink connectors consume as a group", name, status.Type))
+		return
+	}
+	config, err := fetchConnectorConfig(r.Context(), client, baseURL, name)
+	if err != nil {
+		writeConnectError(err)
+		return
+	}
+	group, source := sinkConsumerGroup(name, config)
+
+	ctx, cancel := context.WithTimeout(r.Context(), consumerGroupTimeout)
+	defer cancel()
+	snapshot, err := inspector.inspect(ctx, group)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, "consumer_group_failed", err.Error())
+		return
+	}
+	if len(snapshot.Members) == 0 && len(snapshot.Committed) == 0 && (snapshot.State == "" || snapshot.State == "Dead") {
+		writeJSONError(w, http.StatusNotFound, "consumer_group_not_found", fmt.Sprintf("%s: %s has no members or committed offsets", errConsumerGroupNotFound, group))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, buildConsumerGroupReport(name, group, source, snapshot, consumerGroupOffsets))
+}
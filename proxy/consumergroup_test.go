@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type fakeGroupInspector struct {
+	snapshot consumerGroupSnapshot
+	group    string
+}
+
+func (f *fakeGroupInspector) enabled() bool { return true }
+
+func (f *fakeGroupInspector) inspect(ctx context.Context, group string) (consumerGroupSnapshot, error) {
+	f.group = group
+	return f.snapshot, nil
+}
+
+func withGroupInspector(t *testing.T, inspector consumerGroupInspector, tracker *groupOffsetTracker) {
+	t.Helper()
+	originalInspector, originalTracker := consumerGroups, consumerGroupOffsets
+	consumerGroups, consumerGroupOffsets = inspector, tracker
+	t.Cleanup(func() { consumerGroups, consumerGroupOffsets = originalInspector, originalTracker })
+}
+
+// consumerGroupTestServer fakes a Connect cluster with the sink "orders-sink", whose
+// group is overridden, and the source "orders-source".
+func consumerGroupTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/connectors/orders-sink/status":
+			io.WriteString(w, `{"name":"orders-sink","connector":{"state":"RUNNING"},"tasks":[],"type":"sink"}`)
+		case "/connectors/orders-sink/config":
+			io.WriteString(w, `{"connector.class":"JdbcSinkConnector","consumer.override.group.id":"orders-writers"}`)
+		case "/connectors/orders-source/status":
+			io.WriteString(w, `{"name":"orders-source","connector":{"state":"RUNNING"},"tasks":[],"type":"source"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error_code":404,"message":"not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getConsumerGroup(name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/"+name+"/consumer-group", nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": name})
+	rr := httptest.NewRecorder()
+	connectorConsumerGroupHandler(rr, req)
+	return rr
+}
+
+func TestConnectorConsumerGroupHandler(t *testing.T) {
+	defer withTestConnectURL(t, consumerGroupTestServer(t))()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	inspector := &fakeGroupInspector{snapshot: consumerGroupSnapshot{
+		State: "Stable",
+		Members: []ConsumerGroupMember{
+			{MemberID: "connector-consumer-orders-sink-0-abc", ClientID: "connector-consumer-orders-sink-0", Host: "/10.0.0.1", Assignments: map[string][]int32{"orders": {0, 1}}},
+		},
+		Committed: map[topicPartition]int64{{"orders", 0}: 100, {"orders", 1}: 50},
+		End:       map[topicPartition]int64{{"orders", 0}: 100, {"orders", 1}: 80},
+	}}
+	withGroupInspector(t, inspector, newGroupOffsetTracker(5*time.Minute, func() time.Time { return now }))
+
+	decode := func(rr *httptest.ResponseRecorder) ConsumerGroupReport {
+		t.Helper()
+		var report ConsumerGroupReport
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return report
+	}
+
+	report := decode(getConsumerGroup("orders-sink"))
+	if inspector.group != "orders-writers" || report.GroupSource != consumerGroupOverride {
+		t.Fatalf("expected the overridden group, got %q (%s)", inspector.group, report.GroupSource)
+	}
+	if len(report.Partitions) != 2 || report.TotalLag != 30 || report.StuckPartitions != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	lagging := report.Partitions[1]
+	if *lagging.Lag != 30 || lagging.ClientID != "connector-consumer-orders-sink-0" || lagging.Stuck {
+		t.Fatalf("unexpected partition %+v", lagging)
+	}
+
+	// The lagging partition keeps its offset past the window; the caught-up one is fine.
+	now = now.Add(6 * time.Minute)
+	report = decode(getConsumerGroup("orders-sink"))
+	if report.StuckPartitions != 1 || !report.Partitions[1].Stuck || report.Partitions[0].Stuck {
+		t.Fatalf("expected orders-1 to be stuck, got %+v", report.Partitions)
+	}
+
+	inspector.snapshot.Committed[topicPartition{"orders", 1}] = 60
+	now = now.Add(6 * time.Minute)
+	if report = decode(getConsumerGroup("orders-sink")); report.StuckPartitions != 0 {
+		t.Fatalf("expected movement to clear the stuck flag, got %+v", report.Partitions)
+	}
+
+	if rr := getConsumerGroup("orders-source"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a source connector, got %d", rr.Code)
+	}
+	if rr := getConsumerGroup("missing"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing connector, got %d", rr.Code)
+	}
+	inspector.snapshot = consumerGroupSnapshot{State: "Dead"}
+	if rr := getConsumerGroup("orders-sink"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an empty group, got %d", rr.Code)
+	}
+}
+
+func TestConnectorConsumerGroupRequiresKafka(t *testing.T) {
+	withGroupInspector(t, newKafkaGroupInspector(nil), consumerGroupOffsets)
+	if rr := getConsumerGroup("orders-sink"); rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without KAFKA_BOOTSTRAP_SERVERS, got %d", rr.Code)
+	}
+	if group, source := sinkConsumerGroup("orders-sink", map[string]string{}); group != "connect-orders-sink" || source != "default" {
+		t.Fatalf("unexpected default group %q (%s)", group, source)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/cors"
+)
+
+// defaultCORSHeaders are the request headers the web UI and API clients send
+// cross-origin. Identity headers such as X-Forwarded-User are deliberately absent: they
+// are set by a fronting proxy, never by a browser.
+var defaultCORSHeaders = []string{
+	"Accept",
+	"Accept-Language",
+	"Authorization",
+	"Content-Type",
+	"Last-Event-ID",
+	"X-Requested-With",
+	offsetsConfirmHeader,
+	apiVersionHeader,
+	timezoneHeader,
+	traceparentHeader,
+	tracestateHeader,
+}
+
+var (
+	// allowedOrigins is "*" or a comma-separated list of origins, each of which may
+	// start its host with "*." to allow every subdomain, e.g.
+	// https://console.example.com,https://*.corp.example.com
+	allowedOrigins     = getEnv("ALLOWED_ORIGINS", "*")
+	corsAllowedHeaders = getEnv("CORS_ALLOWED_HEADERS", strings.Join(defaultCORSHeaders, ","))
+	// corsMaxAge is how long browsers may cache a preflight response; "0" makes them
+	// send one before every request.
+	corsMaxAge = getEnv("CORS_MAX_AGE", "10m")
+)
+
+// loadCORSOptions builds the CORS policy from ALLOWED_ORIGINS, CORS_ALLOWED_HEADERS and
+// CORS_MAX_AGE. Credentials are only allowed for an explicit list of origins, never
+// together with "*", which would let any site make authenticated requests.
+func loadCORSOptions() (cors.Options, error) {
+	origins, err := parseAllowedOrigins(allowedOrigins)
+	if err != nil {
+		return cors.Options{}, fmt.Errorf("ALLOWED_ORIGINS: %w", err)
+	}
+
+	headers := splitCORSList(corsAllowedHeaders)
+	if len(headers) == 0 {
+		return cors.Options{}, &configError{name: "CORS_ALLOWED_HEADERS", value: corsAllowedHeaders}
+	}
+
+	maxAge, err := time.ParseDuration(strings.TrimSpace(corsMaxAge))
+	if err != nil || maxAge < 0 {
+		return cors.Options{}, &configError{name: "CORS_MAX_AGE", value: corsMaxAge}
+	}
+	seconds := int(maxAge / time.Second)
+	if seconds == 0 {
+		// rs/cors omits the header for 0, and browsers then cache for 5 seconds.
+		seconds = -1
+	}
+
+	allOrigins := len(origins) == 1 && origins[0] == "*"
+	return cors.Options{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		ExposedHeaders:   []string{offsetsConfirmHeader, apiVersionHeader, timezoneHeader, "Content-Disposition", "Deprecation", "Sunset", "Link"},
+		AllowedHeaders:   headers,
+		MaxAge:           seconds,
+		AllowCredentials: !allOrigins,
+	}, nil
+}
+
+// parseAllowedOrigins splits a comma-separated origin list. An empty list means "*";
+// "*" cannot be combined with other origins.
+func parseAllowedOrigins(value string) ([]string, error) {
+	origins := splitCORSList(value)
+	if len(origins) == 0 {
+		return []string{"*"}, nil
+	}
+	for _, origin := range origins {
+		if origin == "*" {
+			if len(origins) > 1 {
+				return nil, fmt.Errorf("%q cannot be combined with other origins", origin)
+			}
+			continue
+		}
+		if err := validateCORSOrigin(origin); err != nil {
+			return nil, err
+		}
+	}
+	return origins, nil
+}
+
+// validateCORSOrigin accepts scheme://host[:port], where the host may start with "*."
+// to match any subdomain of the rest of the host.
+func validateCORSOrigin(origin string) error {
+	plain := origin
+	if i := strings.Index(origin, "://"); i >= 0 && strings.HasPrefix(origin[i+3:], "*.") {
+		plain = origin[:i+3] + origin[i+5:]
+	}
+	if strings.Contains(plain, "*") {
+		return fmt.Errorf("origin %q: a wildcard is only allowed as the first label of the host, e.g. https://*.example.com", origin)
+	}
+
+	u, err := url.Parse(plain)
+	switch {
+	case err != nil:
+		return fmt.Errorf("origin %q: %v", origin, err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		return fmt.Errorf("origin %q: expected an http:// or https:// origin", origin)
+	case u.Host == "" || u.Hostname() == "":
+		return fmt.Errorf("origin %q: missing host", origin)
+	case u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "":
+		return fmt.Errorf("origin %q: an origin has no credentials, path, query or fragment", origin)
+	}
+	return nil
+}
+
+func splitCORSList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
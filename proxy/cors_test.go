@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/cors"
+)
+
+func withTestCORS(t *testing.T, origins, headers, maxAge string) {
+	t.Helper()
+	originalOrigins, originalHeaders, originalMaxAge := allowedOrigins, corsAllowedHeaders, corsMaxAge
+	t.Cleanup(func() {
+		allowedOrigins, corsAllowedHeaders, corsMaxAge = originalOrigins, originalHeaders, originalMaxAge
+	})
+	allowedOrigins, corsAllowedHeaders, corsMaxAge = origins, headers, maxAge
+}
+
+func TestParseAllowedOrigins(t *testing.T) {
+	valid := map[string]int{
+		"*":                     1,
+		"":                      1,
+		" ,":                    1,
+		"http://localhost:3000": 1,
+		"https://app.com, https://staging.app.com ,":                 2,
+		"https://*.corp.example.com,http://localhost:3000":           2,
+		"https://console.example.com,https://*.dev.example.com:8443": 2,
+	}
+	for value, want := range valid {
+		origins, err := parseAllowedOrigins(value)
+		if err != nil || len(origins) != want {
+			t.Errorf("%q: expected %d origins, got %v (%v)", value, want, origins, err)
+		}
+	}
+
+	for _, value := range []string{
+		"*,https://app.com",
+		"https://app.com,*",
+		"app.com",
+		"ftp://app.com",
+		"https://app.com/",
+		"https://app.com/console",
+		"https://user@app.com",
+		"https://*",
+		"https://*.",
+		"https://app.*.com",
+		"https://*.*.app.com",
+		"*.app.com",
+		"http://localhost:*",
+	} {
+		if _, err := parseAllowedOrigins(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestCORSPolicy(t *testing.T) {
+	withTestCORS(t, "https://console.example.com,https://*.corp.example.com", strings.Join(defaultCORSHeaders, ","), "2h")
+	options, err := loadCORSOptions()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	handler := cors.New(options).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	preflight := func(origin, headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/default/connectors", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		req.Header.Set("Access-Control-Request-Headers", headers)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, origin := range []string{"https://console.example.com", "https://team-a.corp.example.com", "https://a.b.corp.example.com"} {
+		rr := preflight(origin, "content-type,x-offsets-confirm-token")
+		if rr.Header().Get("Access-Control-Allow-Origin") != origin || rr.Header().Get("Access-Control-Allow-Credentials") != "true" || rr.Header().Get("Access-Control-Max-Age") != "7200" {
+			t.Errorf("%s: expected the preflight to be allowed with credentials, got %v", origin, rr.Header())
+		}
+	}
+	for _, origin := range []string{"https://evil.example.com", "https://corp.example.com.evil.io", "https://evilcorp.example.com", "http://team-a.corp.example.com"} {
+		if rr := preflight(origin, "content-type"); rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%s: expected the origin to be rejected, got %v", origin, rr.Header())
+		}
+	}
+	if rr := preflight("https://console.example.com", "x-forwarded-user"); rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected headers outside the allowed list to be rejected, got %v", rr.Header())
+	}
+
+	withTestCORS(t, "*", "*", "0")
+	options, err = loadCORSOptions()
+	if err != nil || options.AllowCredentials || options.MaxAge != -1 {
+		t.Fatalf("expected a wildcard policy without credentials or preflight caching, got %+v (%v)", options, err)
+	}
+
+	for _, bad := range [][3]string{
+		{"*,https://app.com", "Content-Type", "10m"},
+		{"*", " , ", "10m"},
+		{"*", "Content-Type", "-1m"},
+		{"*", "Content-Type", "ten minutes"},
+	} {
+		withTestCORS(t, bad[0], bad[1], bad[2])
+		if _, err := loadCORSOptions(); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+}
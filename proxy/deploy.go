@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mcnabb998/kconnect-console/proxy/internal/connectclient"
+)
+
+// deploySignatureHeader carries the HMAC-SHA256 of the deployment as sha256=<hex>, the
+// format GitHub uses for its webhooks, and deployTimestampHeader the Unix time it was
+// signed at.
+const (
+	deploySignatureHeader = connectclient.SignatureHeader
+	deployTimestampHeader = connectclient.TimestampHeader
+
+	// deploySignatureTolerance is how far a deployment's timestamp may be from the
+	// proxy's clock.
+	deploySignatureTolerance = 5 * time.Minute
+)
+
+// deployUser is recorded in the audit log for changes made by a deployment that does not
+// carry a forwarded user.
+const deployUser = "deploy"
+
+const auditActionDeploy = "DEPLOY"
+
+// Actions planned for a connector by a deployment.
+const (
+	deployCreate    = "create"
+	deployUpdate    = "update"
+	deployUnchanged = "unchanged"
+	deployDelete    = "delete"
+)
+
+var (
+	deployWebhookSecret = getEnv("DEPLOY_WEBHOOK_SECRET", "")
+	deployReplays       = &deployReplayGuard{}
+)
+
+// DeployConnectorResult is the outcome for one connector. Applied is set once Kafka
+// Connect accepted the change; unchanged connectors are never applied.
+type DeployConnectorResult struct {
+	Name       string            `json:"name"`
+	Action     string            `json:"action"`
+	Applied    bool              `json:"applied"`
+	Diff       *ConfigDiff       `json:"diff,omitempty"`
+	Validation *ConfigValidation `json:"validation,omitempty"`
+	Violations []PolicyViolation `json:"violations,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// DeployResult is the apply report of a deployment. Actions counts the connectors per
+// planned action.
+type DeployResult struct {
+	Cluster    string                  `json:"cluster"`
+	Revision   string                  `json:"revision,omitempty"`
+	DryRun     bool                    `json:"dryRun"`
+	Actions    map[string]int          `json:"actions"`
+	Failed     int                     `json:"failed"`
+	Connectors []DeployConnectorResult `json:"connectors"`
+}
+
+// deployItem is a connector change waiting to be applied: the config as written in the
+// payload, and resolved for Kafka Connect.
+type deployItem struct {
+	result   *DeployConnectorResult
+	config   map[string]string
+	resolved map[string]string
+	refs     map[string]secretRef
+}
+
+// deploySignedKey marks a deploy request whose signature deployHandler has checked.
+type deploySignedKey struct{}
+
+// isDeployWebhook reports whether r is the deploy webhook, which CI authenticates by
+// signing the body instead of with a session or groups.
+func isDeployWebhook(r *http.Request) bool {
+	segments := clusterPathSegments(r.URL.Path)
+	return r.Method == http.MethodPost && len(segments) == 1 && segments[0] == "deploy"
+}
+
+// signedDeploy reports whether r is a deployment with a valid signature. Holders of the
+// webhook secret may deploy any connector, so they are tenancy admins.
+func signedDeploy(r *http.Request) bool {
+	signed, _ := r.Context().Value(deploySignedKey{}).(bool)
+	return signed
+}
+
+// validDeploySignature checks header against the signature of a deployment under secret
+// (see connectclient.Sign).
+func validDeploySignature(secret, timestamp string, dryRun bool, body []byte, header string) bool {
+	if !strings.HasPrefix(header, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte("sha256="+strings.ToLower(strings.TrimPrefix(header, "sha256="))), []byte(connectclient.Sign(secret, timestamp, dryRun, body)))
+}
+
+// deployReplayGuard remembers the signatures of recent deployments, so a request
+// captured within deploySignatureTolerance cannot be sent a second time.
+type deployReplayGuard struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// first records signature and reports whether it had not been seen yet. Signatures
+// are forgotten once their timestamp is out of the tolerance anyway.
+func (g *deployReplayGuard) first(signature string, signedAt, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seen == nil {
+		g.seen = make(map[string]time.Time)
+	}
+	for seen, at := range g.seen {
+		if now.Sub(at) > deploySignatureTolerance {
+			delete(g.seen, seen)
+		}
+	}
+	if _, ok := g.seen[signature]; ok {
+		return false
+	}
+	g.seen[signature] = signedAt
+	return true
+}
+
+// checkDeploySignature authenticates a deployment: the signature must cover the body,
+// ?dryRun and a timestamp within deploySignatureTolerance of now, and must not have
+// been used before.
+func checkDeploySignature(r *http.Request, body []byte, now time.Time) (int, string, string) {
+	timestamp := r.Header.Get(deployTimestampHeader)
+	signature := strings.ToLower(r.Header.Get(deploySignatureHeader))
+	if !validDeploySignature(deployWebhookSecret, timestamp, isDryRun(r), body, signature) {
+		return http.StatusUnauthorized, "invalid_signature", fmt.Sprintf("%s must be sha256= followed by the HMAC-SHA256 of %s, dryRun and the body", deploySignatureHeader, deployTimestampHeader)
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	signedAt := time.Unix(seconds, 0)
+	if err != nil || signedAt.Before(now.Add(-deploySignatureTolerance)) || signedAt.After(now.Add(deploySignatureTolerance)) {
+		return http.StatusUnauthorized, "stale_signature", fmt.Sprintf("%s must be within %s of the proxy's clock", deployTimestampHeader, deploySignatureTolerance)
+	}
+	if !deployReplays.first(signature, signedAt, now) {
+		return http.StatusConflict, "replayed_deployment", "this signed deployment was already received; sign it again to resend it"
+	}
+	return 0, "", ""
+}
+
+// parseDeployPayload decodes and checks a deployment. Config values are converted to the
+// strings Kafka Connect stores, and the name is set to the connector's.
+func parseDeployPayload(body []byte) (DeployPayload, map[string]map[string]string, error) {
+	var payload DeployPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return DeployPayload{}, nil, fmt.Errorf("body must be a JSON deployment: %v", err)
+	}
+	if payload.Prune && len(payload.Connectors) == 0 {
+		return DeployPayload{}, nil, errors.New("refusing to prune every connector: the deployment lists none")
+	}
+
+	configs := make(map[string]map[string]string, len(payload.Connectors))
+	for i, connector := range payload.Connectors {
+		name := strings.TrimSpace(connector.Name)
+		switch {
+		case name == "":
+			return DeployPayload{}, nil, fmt.Errorf("connectors[%d]: name is required", i)
+		case configs[name] != nil:
+			return DeployPayload{}, nil, fmt.Errorf("connector %q is listed twice", name)
+		case connector.Config == nil:
+			return DeployPayload{}, nil, fmt.Errorf("connector %q: config is required", name)
+		}
+		config := make(map[string]string, len(connector.Config)+1)
+		for key, value := range connector.Config {
+			switch value.(type) {
+			case string, float64, bool:
+				config[key] = configString(value)
+			default:
+				return DeployPayload{}, nil, fmt.Errorf("connector %q: %s must be a string, number or boolean", name, key)
+			}
+		}
+		if config["connector.class"] == "" {
+			return DeployPayload{}, nil, fmt.Errorf("connector %q: connector.class is required", name)
+		}
+		if existing, ok := config["name"]; ok && existing != name {
+			return DeployPayload{}, nil, fmt.Errorf("connector %q: config name %q does not match", name, existing)
+		}
+		config["name"] = name
+		configs[name] = config
+	}
+	return payload, configs, nil
+}
+
+// planDeployment compares the desired configs with the live ones, as clients see them
+// with secret placeholders restored, and lists the change each connector needs.
+func planDeployment(r *http.Request, cluster string, configs map[string]map[string]string, prune bool) ([]*deployItem, error) {
+	live, err := fetchExpandedConnectorStatuses(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	rules := currentRedactionRules()
+	var items []*deployItem
+	for name, config := range configs {
+		item := &deployItem{result: &DeployConnectorResult{Name: name, Action: deployCreate}, config: config}
+		if connector, exists := live[name]; exists {
+			current := make(map[string]string, len(connector.Info.Config))
+			for key, value := range connector.Info.Config {
+				current[key] = value
+			}
+			secretRefs.restoreStrings(cluster, name, current)
+			diff := diffConfigs(rules, current, config)
+			diff.Connector = name
+			item.result.Action = deployUnchanged
+			if len(diff.Added)+len(diff.Removed)+len(diff.Changed) > 0 {
+				item.result.Action, item.result.Diff = deployUpdate, &diff
+			}
+		}
+		items = append(items, item)
+	}
+	if prune {
+		for name := range live {
+			if configs[name] == nil {
+				items = append(items, &deployItem{result: &DeployConnectorResult{Name: name, Action: deployDelete}})
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].result.Name < items[j].result.Name })
+	return items, nil
+}
+
+// checkDeployItem runs the checks a single config write would go through, admission
+// policies, secret resolution and Kafka Connect's validation, and reports whether the
+// change may be applied.
+func checkDeployItem(r *http.Request, cluster string, item *deployItem) (bool, error) {
+	if item.result.Action != deployCreate && item.result.Action != deployUpdate {
+		return true, nil
+	}
+	name := item.result.Name
+
+	document := make(map[string]interface{}, len(item.config))
+	for key, value := range item.config {
+		document[key] = value
+	}
+	if violations := checkAdmission(name, document); len(violations) > 0 {
+		item.result.Violations = violations
+		item.result.Error = "the config violates the admission policies"
+		return false, nil
+	}
+
+	refs, err := connectorSecrets.resolveConfig(r.Context(), document)
+	if err != nil {
+		item.result.Error = err.Error()
+		return false, nil
+	}
+	item.refs = refs
+	item.resolved = make(map[string]string, len(document))
+	for key, value := range document {
+		item.resolved[key] = configString(value)
+	}
+
+	validation, err := validateConnectorConfig(r.Context(), connectClientFor(cluster, routeValidate), connectURLFor(cluster), item.config["connector.class"], document)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			return false, err
+		}
+		item.result.Error = err.Error()
+		return false, nil
+	}
+	if validation.ErrorCount > 0 {
+		item.result.Validation = validation
+		item.result.Error = fmt.Sprintf("the config has %d validation error(s)", validation.ErrorCount)
+		return false, nil
+	}
+	return true, nil
+}
+
+// applyDeployItem writes one change to Kafka Connect and audits it.
+func applyDeployItem(r *http.Request, cluster, revision string, item *deployItem) {
+	name := item.result.Name
+	baseURL := connectURLFor(cluster)
+	client := connectClientFor(cluster, routeWrite)
+
+	var action string
+	var err error
+	switch item.result.Action {
+	case deployCreate, deployUpdate:
+		action = auditActionUpdate
+		if item.result.Action == deployCreate {
+			action = auditActionCreate
+		}
+		err = sendConnectRequest(r.Context(), client, http.MethodPut, joinURL(baseURL, "connectors", url.PathEscape(name), "config"), item.resolved)
+		if err == nil {
+			if err := secretRefs.set(cluster, name, item.refs); err != nil {
+				log.Printf("deploy: failed to record secret placeholders for %s: %v", name, err)
+			}
+		}
+	case deployDelete:
+		action = auditActionDelete
+		err = sendConnectRequest(r.Context(), client, http.MethodDelete, joinURL(baseURL, "connectors", url.PathEscape(name)), nil)
+		if err == nil {
+			if err := secretRefs.set(cluster, name, nil); err != nil {
+				log.Printf("deploy: failed to forget secret placeholders for %s: %v", name, err)
+			}
+		}
+	default:
+		return
+	}
+	if upstream, parseErr := url.Parse(baseURL); parseErr == nil {
+		configCache.invalidateConnector(r.Context(), upstream, name)
+	}
+
+	status := http.StatusOK
+	details := map[string]interface{}{"deploy": true}
+	if revision != "" {
+		details["revision"] = revision
+	}
+	if err != nil {
+		item.result.Error = err.Error()
+		status = http.StatusBadGateway
+		details["error"] = err.Error()
+	} else {
+		item.result.Applied = true
+	}
+	logAudit(deployAuditEntry(r, action, name, status, details))
+}
+
+func deployAuditEntry(r *http.Request, action, connector string, status int, details map[string]interface{}) AuditLogEntry {
+	entry := newAuditEntry(r, action, connector, status, details)
+	if entry.User == "anonymous" {
+		entry.User = deployUser
+	}
+	return entry
+}
+
+// deployHandler applies a signed set of connector configs from CI: missing connectors are
+// created, drifted ones updated and, with prune, unlisted ones deleted. Every change is
+// checked first, and nothing is applied unless all pass. ?dryRun=true returns the plan.
+func deployHandler(w http.ResponseWriter, r *http.Request) {
+	if deployWebhookSecret == "" {
+		writeJSONError(w, http.StatusNotFound, "deploy_disabled", "config deployment is not configured (set DEPLOY_WEBHOOK_SECRET)")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "failed to read request body")
+		return
+	}
+	if status, code, message := checkDeploySignature(r, body, time.Now()); status != 0 {
+		writeJSONError(w, status, code, message)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), deploySignedKey{}, true))
+	payload, configs, err := parseDeployPayload(body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_deployment", err.Error())
+		return
+	}
+
+	cluster := mux.Vars(r)["cluster"]
+	items, err := planDeployment(r, cluster, configs, payload.Prune)
+	if err != nil {
+		writeConnectorsFetchError(w, err)
+		return
+	}
+
+	result := DeployResult{Cluster: cluster, Revision: payload.Revision, DryRun: isDryRun(r), Actions: map[string]int{}, Connectors: []DeployConnectorResult{}}
+	rejected := false
+	for _, item := range items {
+		result.Actions[item.result.Action]++
+		ok, err := checkDeployItem(r, cluster, item)
+		if err != nil {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		rejected = rejected || !ok
+	}
+
+	status := http.StatusOK
+	switch {
+	case rejected:
+		status = http.StatusUnprocessableEntity
+	case !result.DryRun:
+		for _, item := range items {
+			applyDeployItem(r, cluster, payload.Revision, item)
+		}
+	}
+	for _, item := range items {
+		if item.result.Error != "" {
+			result.Failed++
+			if status == http.StatusOK {
+				status = http.StatusBadGateway
+			}
+		}
+		result.Connectors = append(result.Connectors, *item.result)
+	}
+
+	if !result.DryRun {
+		if status == http.StatusOK {
+			registerDeployedState(r, cluster, payload, configs)
+		}
+		details := map[string]interface{}{"actions": result.Actions, "failed": result.Failed, "prune": payload.Prune}
+		if payload.Revision != "" {
+			details["revision"] = payload.Revision
+		}
+		logAudit(deployAuditEntry(r, auditActionDeploy, "", status, details))
+	}
+	writeJSON(w, status, result)
+}
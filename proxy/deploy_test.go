@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// signDeploy returns the headers of a deployment signed at signedAt.
+func signDeploy(secret, body string, dryRun bool, signedAt time.Time) http.Header {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s.%t.%s", timestamp, dryRun, body)
+	return http.Header{
+		deployTimestampHeader: {timestamp},
+		deploySignatureHeader: {"sha256=" + hex.EncodeToString(mac.Sum(nil))},
+	}
+}
+
+func TestParseDeployPayload(t *testing.T) {
+	_, configs, err := parseDeployPayload([]byte(`{"connectors": [{"name": "orders", "config": {"connector.class": "Jdbc", "tasks.max": 2, "errors.log.enable": true}}]}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := configs["orders"]; got["tasks.max"] != "2" || got["errors.log.enable"] != "true" || got["name"] != "orders" {
+		t.Fatalf("expected values converted to strings, got %v", got)
+	}
+
+	for body, want := range map[string]string{
+		`{"prune": true, "connectors": []}`:                                                                                      "refusing to prune",
+		`{"connectors": [{"name": "a", "config": {"tasks.max": "1"}}]}`:                                                          "connector.class is required",
+		`{"connectors": [{"name": "a", "config": {"connector.class": "X", "x": [1]}}]}`:                                          "must be a string",
+		`{"connectors": [{"name": "a", "config": {"connector.class": "X", "name": "b"}}]}`:                                       "does not match",
+		`{"connectors": [{"name": "a", "config": {"connector.class": "X"}}, {"name": "a", "config": {"connector.class": "X"}}]}`: "listed twice",
+	} {
+		if _, _, err := parseDeployPayload([]byte(body)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", body, want, err)
+		}
+	}
+}
+
+func TestDeployHandler(t *testing.T) {
+	originalSecret := deployWebhookSecret
+	t.Cleanup(func() { deployWebhookSecret = originalSecret })
+	deployWebhookSecret = "ci-secret"
+	deployReplays = &deployReplayGuard{}
+	logger := withTestAuditLog(t, 20)
+	withTestDriftDetection(t)
+
+	var mu sync.Mutex
+	var writes []string
+	live := map[string]map[string]string{
+		"orders-sink": {"connector.class": "JdbcSink", "name": "orders-sink", "tasks.max": "1", "topics": "orders"},
+		"audit-sink":  {"connector.class": "S3Sink", "name": "audit-sink", "topics": "audit"},
+		"legacy":      {"connector.class": "FileSink", "name": "legacy"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors":
+			expanded := map[string]interface{}{}
+			for name, config := range live {
+				expanded[name] = map[string]interface{}{"info": map[string]interface{}{"config": config}}
+			}
+			json.NewEncoder(w).Encode(expanded)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/config/validate"):
+			var config map[string]string
+			json.NewDecoder(r.Body).Decode(&config)
+			result := map[string]interface{}{"error_count": 0, "configs": []interface{}{}}
+			if config["tasks.max"] == "0" {
+				result = map[string]interface{}{"error_count": 1, "configs": []interface{}{
+					map[string]interface{}{"value": map[string]interface{}{"name": "tasks.max", "value": "0", "errors": []string{"must be at least 1"}}},
+				}}
+			}
+			json.NewEncoder(w).Encode(result)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/config"):
+			name := strings.Split(r.URL.Path, "/")[2]
+			var config map[string]string
+			json.NewDecoder(r.Body).Decode(&config)
+			live[name] = config
+			writes = append(writes, "PUT "+name)
+		case r.Method == http.MethodDelete:
+			name := strings.Split(r.URL.Path, "/")[2]
+			delete(live, name)
+			writes = append(writes, "DELETE "+name)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	deploy := func(body, query string, header http.Header) (*httptest.ResponseRecorder, DeployResult) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/default/deploy"+query, strings.NewReader(body))
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		deployHandler(rr, req)
+		var result DeployResult
+		json.Unmarshal(rr.Body.Bytes(), &result)
+		return rr, result
+	}
+
+	body := `{"revision": "abc123", "prune": true, "connectors": [
+		{"name": "orders-sink", "config": {"connector.class": "JdbcSink", "tasks.max": 2, "topics": "orders"}},
+		{"name": "audit-sink", "config": {"connector.class": "S3Sink", "topics": "audit"}},
+		{"name": "payments-cdc", "config": {"connector.class": "Postgres"}}
+	]}`
+	if rr, _ := deploy(body, "", signDeploy("wrong", body, false, time.Now())); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a bad signature to be rejected, got %d", rr.Code)
+	}
+
+	dryRun := signDeploy("ci-secret", body, true, time.Now())
+	rr, plan := deploy(body, "?dryRun=true", dryRun)
+	if rr.Code != http.StatusOK || !plan.DryRun || len(writes) != 0 {
+		t.Fatalf("unexpected dry run %d: %s (writes %v)", rr.Code, rr.Body.String(), writes)
+	}
+	actions := map[string]string{}
+	for _, connector := range plan.Connectors {
+		actions[connector.Name] = connector.Action
+	}
+	if actions["orders-sink"] != deployUpdate || actions["audit-sink"] != deployUnchanged || actions["payments-cdc"] != deployCreate || actions["legacy"] != deployDelete {
+		t.Fatalf("unexpected plan %v", actions)
+	}
+
+	// A captured dry run cannot be replayed, neither as a real deployment nor as is.
+	if rr, _ := deploy(body, "", dryRun); rr.Code != http.StatusUnauthorized || len(writes) != 0 {
+		t.Fatalf("expected a dry run's signature to be refused for a real deployment, got %d (writes %v)", rr.Code, writes)
+	}
+	if rr, _ := deploy(body, "?dryRun=true", dryRun); rr.Code != http.StatusConflict {
+		t.Fatalf("expected a replayed deployment to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr, _ := deploy(body, "", signDeploy("ci-secret", body, false, time.Now().Add(-10*time.Minute))); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "stale_signature") || len(writes) != 0 {
+		t.Fatalf("expected an old signature to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	invalid := strings.Replace(body, `"tasks.max": 2`, `"tasks.max": 0`, 1)
+	rr, rejected := deploy(invalid, "", signDeploy("ci-secret", invalid, false, time.Now()))
+	if rr.Code != http.StatusUnprocessableEntity || rejected.Failed != 1 || len(writes) != 0 {
+		t.Fatalf("expected a validation error to block the whole deployment, got %d: %s (writes %v)", rr.Code, rr.Body.String(), writes)
+	}
+
+	rr, applied := deploy(body, "", signDeploy("ci-secret", body, false, time.Now()))
+	if rr.Code != http.StatusOK || applied.Failed != 0 {
+		t.Fatalf("unexpected deploy %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Join(writes, ",") != "DELETE legacy,PUT orders-sink,PUT payments-cdc" {
+		t.Fatalf("unexpected writes %v", writes)
+	}
+	if live["orders-sink"]["tasks.max"] != "2" || live["payments-cdc"]["name"] != "payments-cdc" {
+		t.Fatalf("unexpected configs after deploy %v", live)
+	}
+	if desired, ok := driftDetection.desiredState("default"); !ok || desired.Source != desiredSourceDeploy || desired.Revision != "abc123" || len(desired.Connectors) != 3 {
+		t.Fatalf("expected the deployment to be registered as the desired state, got %+v", desired)
+	}
+
+	writes = nil
+	if rr, again := deploy(body, "", signDeploy("ci-secret", body, false, time.Now().Add(-time.Second))); rr.Code != http.StatusOK || again.Actions[deployUnchanged] != 3 || len(writes) != 0 {
+		t.Fatalf("expected a second deploy to change nothing, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var users, revisions []string
+	for _, entry := range logger.Query(AuditFilter{Connector: "orders-sink"}) {
+		users = append(users, entry.User)
+		revisions = append(revisions, entry.Details["revision"].(string))
+	}
+	if len(users) != 1 || users[0] != deployUser || revisions[0] != "abc123" {
+		t.Fatalf("expected the update to be audited as the deployment, got %v %v", users, revisions)
+	}
+}
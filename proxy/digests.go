@@ -0,0 +1,518 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	digestsFile = "summary-digests.json"
+
+	auditActionCreateDigest = "CREATE_DIGEST"
+	auditActionDeleteDigest = "DELETE_DIGEST"
+
+	// Digest formats: a digest posts the failed and degraded connectors; a summary also
+	// attaches the full monitoring summary to webhook payloads.
+	digestFormatDigest  = "digest"
+	digestFormatSummary = "summary"
+
+	// digestMaxListed caps the connectors named per state in a digest message.
+	digestMaxListed = 20
+)
+
+var summaryDigests = newDigestScheduler(time.Now)
+
+// SummaryDigest periodically posts the health of a cluster to a webhook or Slack
+// channel at each Cron match in Timezone. URL is masked when digests are listed.
+type SummaryDigest struct {
+	ID          string    `json:"id"`
+	Cluster     string    `json:"cluster"`
+	Cron        string    `json:"cron"`
+	Timezone    string    `json:"timezone"`
+	Channel     string    `json:"channel"`
+	URL         string    `json:"url"`
+	Format      string    `json:"format"`
+	Tags        []string  `json:"tags,omitempty"`
+	SkipHealthy bool      `json:"skipHealthy,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CreatedBy   string    `json:"createdBy"`
+
+	// LastRunAt is the last time the digest was due, whether or not it was sent.
+	LastRunAt  *time.Time `json:"lastRunAt,omitempty"`
+	LastSentAt *time.Time `json:"lastSentAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+	NextRun    *time.Time `json:"nextRun,omitempty"`
+}
+
+// digestRequest is the body of POST /api/{cluster}/digests.
+type digestRequest struct {
+	Cron        string   `json:"cron"`
+	Timezone    string   `json:"timezone,omitempty"`
+	Channel     string   `json:"channel,omitempty"`
+	URL         string   `json:"url"`
+	Format      string   `json:"format,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	SkipHealthy bool     `json:"skipHealthy,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// normalize trims the request and fills in the defaults: UTC, a webhook and the
+// digest format.
+func (req digestRequest) normalize() digestRequest {
+	req.Cron, req.URL, req.Description = strings.TrimSpace(req.Cron), strings.TrimSpace(req.URL), strings.TrimSpace(req.Description)
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	req.Channel, req.Format = strings.ToLower(strings.TrimSpace(req.Channel)), strings.ToLower(strings.TrimSpace(req.Format))
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if req.Channel == "" {
+		req.Channel = "webhook"
+	}
+	if req.Format == "" {
+		req.Format = digestFormatDigest
+	}
+	var tags []string
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	req.Tags = tags
+	return req
+}
+
+func (req digestRequest) validate() error {
+	if _, _, err := compileDigest(req.Cron, req.Timezone); err != nil {
+		return err
+	}
+	if req.Channel != "webhook" && req.Channel != "slack" {
+		return fmt.Errorf("channel must be webhook or slack, got %q", req.Channel)
+	}
+	if req.Format != digestFormatDigest && req.Format != digestFormatSummary {
+		return fmt.Errorf("format must be %s or %s, got %q", digestFormatDigest, digestFormatSummary, req.Format)
+	}
+	if req.Format == digestFormatSummary && req.Channel == "slack" {
+		return fmt.Errorf("the %s format needs a webhook; Slack only takes the message", digestFormatSummary)
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	return nil
+}
+
+func compileDigest(cron, timezone string) (cronSpec, *time.Location, error) {
+	spec, err := parseCron(cron)
+	if err != nil {
+		return cronSpec{}, nil, err
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return cronSpec{}, nil, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	return spec, loc, nil
+}
+
+// masked returns the digest as listed by the API: webhook URLs carry their secret in
+// the path, so only the host is shown.
+func (d SummaryDigest) masked() SummaryDigest {
+	d.URL = maskSetting("WEBHOOK", d.URL)
+	return d
+}
+
+// DigestConnector is a connector named in a digest.
+type DigestConnector struct {
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	FailedTasks int    `json:"failedTasks"`
+	Tasks       int    `json:"tasks"`
+}
+
+// digestPayload is posted to webhooks.
+type digestPayload struct {
+	Type       string             `json:"type"`
+	DigestID   string             `json:"digestId"`
+	Cluster    string             `json:"cluster"`
+	Subject    string             `json:"subject"`
+	Message    string             `json:"message"`
+	Totals     map[string]int     `json:"totals"`
+	Connectors []DigestConnector  `json:"connectors"`
+	Summary    *MonitoringSummary `json:"summary,omitempty"`
+	Timestamp  time.Time          `json:"timestamp"`
+}
+
+// digestConnectors returns the failed and degraded connectors of a summary, failed
+// first.
+func digestConnectors(summary MonitoringSummary) []DigestConnector {
+	result := []DigestConnector{}
+	for _, overview := range summary.Connectors {
+		class := connectorTotalsClass(overview.State, overview.TaskStates)
+		if class != "failed" && class != "degraded" {
+			continue
+		}
+		tasks := 0
+		for _, count := range overview.TaskStates {
+			tasks += count
+		}
+		result = append(result, DigestConnector{Name: overview.Name, State: class, FailedTasks: overview.TaskStates["failed"], Tasks: tasks})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].State != result[j].State {
+			return result[i].State == "failed"
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// renderDigest writes the subject and text of a digest.
+func renderDigest(cluster string, summary MonitoringSummary, connectors []DigestConnector) (string, string) {
+	totals := summary.Totals
+	subject := fmt.Sprintf("[kconnect] %s health: %d failed, %d degraded", cluster, totals["failed"], totals["degraded"])
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "Cluster %s: %d connectors, %d running, %d degraded, %d failed, %d stopped.",
+		cluster, summary.TotalConnectors, totals["running"], totals["degraded"], totals["failed"], totals["stopped"])
+	if len(connectors) == 0 {
+		msg.WriteString(" All connectors are healthy.")
+		return subject, msg.String()
+	}
+	for _, state := range []string{"failed", "degraded"} {
+		var listed []string
+		more := 0
+		for _, c := range connectors {
+			if c.State != state {
+				continue
+			}
+			if len(listed) == digestMaxListed {
+				more++
+				continue
+			}
+			entry := c.Name
+			if c.FailedTasks > 0 {
+				entry += fmt.Sprintf(" (%d/%d tasks failed)", c.FailedTasks, c.Tasks)
+			}
+			listed = append(listed, entry)
+		}
+		if len(listed) == 0 {
+			continue
+		}
+		fmt.Fprintf(&msg, "\n%s%s: %s", strings.ToUpper(state[:1]), state[1:], strings.Join(listed, ", "))
+		if more > 0 {
+			fmt.Fprintf(&msg, " and %d more", more)
+		}
+	}
+	return subject, msg.String()
+}
+
+// digestDocument is the persisted form of the digests.
+type digestDocument struct {
+	NextID  int64           `json:"nextId"`
+	Digests []SummaryDigest `json:"digests"`
+}
+
+// digestScheduler stores the summary digests and sends the ones due on every tick.
+type digestScheduler struct {
+	mu      sync.Mutex
+	nextID  int64
+	digests []SummaryDigest
+	now     func() time.Time
+	client  *http.Client
+}
+
+func newDigestScheduler(now func() time.Time) *digestScheduler {
+	return &digestScheduler{now: now, client: newUpstreamClient(30 * time.Second)}
+}
+
+// load replaces the digests with the persisted ones, if any.
+func (s *digestScheduler) load() error {
+	var doc digestDocument
+	if err := loadJSON(digestsFile, &doc); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID, s.digests = doc.NextID, doc.Digests
+	return nil
+}
+
+// saveLocked persists the digests. Callers must hold s.mu.
+func (s *digestScheduler) saveLocked() error {
+	return saveJSON(digestsFile, digestDocument{NextID: s.nextID, Digests: s.digests})
+}
+
+// due returns when a digest is next due after its last run, or after its creation.
+func (s *digestScheduler) due(digest SummaryDigest) (time.Time, bool) {
+	spec, loc, err := compileDigest(digest.Cron, digest.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	from := digest.CreatedAt
+	if digest.LastRunAt != nil {
+		from = *digest.LastRunAt
+	}
+	next, ok := spec.next(from.In(loc))
+	return next.UTC(), ok
+}
+
+// withNextRun fills in when a digest is next sent.
+func (s *digestScheduler) withNextRun(digest SummaryDigest) SummaryDigest {
+	if next, ok := s.due(digest); ok {
+		if now := s.now(); next.Before(now) {
+			next = now.UTC().Truncate(time.Minute)
+		}
+		digest.NextRun = &next
+	}
+	return digest
+}
+
+// list returns the digests of a cluster with their URLs masked.
+func (s *digestScheduler) list(cluster string) []SummaryDigest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []SummaryDigest{}
+	for _, digest := range s.digests {
+		if digest.Cluster == cluster {
+			result = append(result, s.withNextRun(digest).masked())
+		}
+	}
+	return result
+}
+
+func (s *digestScheduler) get(cluster, id string) (SummaryDigest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, digest := range s.digests {
+		if digest.ID == id && digest.Cluster == cluster {
+			return digest, true
+		}
+	}
+	return SummaryDigest{}, false
+}
+
+// add stores a new digest built from a normalized, validated request.
+func (s *digestScheduler) add(cluster string, req digestRequest, user string) (SummaryDigest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	digest := SummaryDigest{
+		ID:          strconv.FormatInt(s.nextID, 10),
+		Cluster:     cluster,
+		Cron:        req.Cron,
+		Timezone:    req.Timezone,
+		Channel:     req.Channel,
+		URL:         req.URL,
+		Format:      req.Format,
+		Tags:        req.Tags,
+		SkipHealthy: req.SkipHealthy,
+		Description: req.Description,
+		CreatedAt:   s.now().UTC(),
+		CreatedBy:   user,
+	}
+	s.digests = append(s.digests, digest)
+	return s.withNextRun(digest).masked(), s.saveLocked()
+}
+
+// remove deletes a digest.
+func (s *digestScheduler) remove(cluster, id string) (SummaryDigest, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, digest := range s.digests {
+		if digest.ID == id && digest.Cluster == cluster {
+			s.digests = append(s.digests[:i], s.digests[i+1:]...)
+			return digest.masked(), true, s.saveLocked()
+		}
+	}
+	return SummaryDigest{}, false, nil
+}
+
+// record stores the outcome of a run.
+func (s *digestScheduler) record(id string, ran time.Time, sent bool, err error) (SummaryDigest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.digests {
+		digest := &s.digests[i]
+		if digest.ID != id {
+			continue
+		}
+		ran = ran.UTC()
+		digest.LastRunAt, digest.LastError = &ran, ""
+		if err != nil {
+			digest.LastError = err.Error()
+		} else if sent {
+			digest.LastSentAt = &ran
+		}
+		if err := s.saveLocked(); err != nil {
+			log.Printf("digests: failed to persist digests: %v", err)
+		}
+		return s.withNextRun(*digest).masked(), true
+	}
+	return SummaryDigest{}, false
+}
+
+// send posts a digest built from the given summary. It reports false without posting
+// when the digest skips healthy clusters and nothing failed.
+func (s *digestScheduler) send(ctx context.Context, digest SummaryDigest, summary MonitoringSummary) (bool, error) {
+	summary = summary.withTags(connectorMetadata.all(digest.Cluster), digest.Tags)
+	connectors := digestConnectors(summary)
+	if digest.SkipHealthy && len(connectors) == 0 {
+		return false, nil
+	}
+
+	subject, message := renderDigest(digest.Cluster, summary, connectors)
+	if digest.Channel == "slack" {
+		return true, postJSON(ctx, s.client, digest.URL, map[string]string{"text": subject + "\n" + message})
+	}
+	payload := digestPayload{
+		Type:       "summary_digest",
+		DigestID:   digest.ID,
+		Cluster:    digest.Cluster,
+		Subject:    subject,
+		Message:    message,
+		Totals:     summary.Totals,
+		Connectors: connectors,
+		Timestamp:  s.now().UTC(),
+	}
+	if digest.Format == digestFormatSummary {
+		payload.Summary = &summary
+	}
+	return true, postJSON(ctx, s.client, digest.URL, payload)
+}
+
+// runDigest fetches the summary of the digest's cluster and sends the digest.
+func (s *digestScheduler) runDigest(ctx context.Context, digest SummaryDigest, summaries map[string]MonitoringSummary) (bool, error) {
+	summary, ok := summaries[digest.Cluster]
+	if !ok {
+		fetched, err := fetchMonitoringSummary(ctx, connectClientFor(digest.Cluster, routeRead), connectURLFor(digest.Cluster))
+		if err != nil {
+			return false, fmt.Errorf("fetch monitoring summary: %w", err)
+		}
+		summary = fetched
+		summaries[digest.Cluster] = summary
+	}
+	return s.send(ctx, digest, summary)
+}
+
+// tick sends the digests that came due since their last run. A digest missed while the
+// proxy was down is sent once on the first tick, not once per missed match.
+func (s *digestScheduler) tick(ctx context.Context) {
+	s.mu.Lock()
+	snapshot := append([]SummaryDigest(nil), s.digests...)
+	s.mu.Unlock()
+
+	now := s.now()
+	summaries := make(map[string]MonitoringSummary)
+	for _, digest := range snapshot {
+		if next, ok := s.due(digest); !ok || next.After(now) {
+			continue
+		}
+		sent, err := s.runDigest(ctx, digest, summaries)
+		if err != nil {
+			log.Printf("digests: failed to send digest %s of cluster %s: %v", digest.ID, digest.Cluster, err)
+		}
+		s.record(digest.ID, now, sent, err)
+	}
+}
+
+func (s *digestScheduler) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		s.tick(ctx)
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// summaryDigestsHandler lists (GET) or creates (POST) the summary digests of a cluster.
+func summaryDigestsHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, summaryDigests.list(cluster))
+		return
+	}
+
+	var req digestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON digest object")
+		return
+	}
+	req = req.normalize()
+	if err := req.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_digest", err.Error())
+		return
+	}
+	digest, err := summaryDigests.add(cluster, req, requestUser(r))
+	if err != nil {
+		log.Printf("digests: failed to persist digest %s: %v", digest.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "digest_store_failed", "failed to persist digest")
+		return
+	}
+	recordAudit(r, auditActionCreateDigest, "", http.StatusCreated, map[string]interface{}{
+		"digestId": digest.ID, "cron": digest.Cron, "timezone": digest.Timezone, "channel": digest.Channel, "url": digest.URL,
+	})
+	writeJSON(w, http.StatusCreated, digest)
+}
+
+// summaryDigestHandler deletes a summary digest.
+func summaryDigestHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, id := vars["cluster"], vars["id"]
+
+	digest, ok, err := summaryDigests.remove(cluster, id)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "digest_not_found", fmt.Sprintf("cluster %s has no digest %s", cluster, id))
+		return
+	}
+	if err != nil {
+		log.Printf("digests: failed to persist digests after deleting %s: %v", id, err)
+	}
+	recordAudit(r, auditActionDeleteDigest, "", http.StatusOK, map[string]interface{}{"digestId": id, "cron": digest.Cron})
+	writeJSON(w, http.StatusOK, digest)
+}
+
+// sendDigestHandler sends a digest right away, e.g. to check a new webhook. Skipped
+// healthy digests are still posted.
+func sendDigestHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, id := vars["cluster"], vars["id"]
+
+	digest, ok := summaryDigests.get(cluster, id)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "digest_not_found", fmt.Sprintf("cluster %s has no digest %s", cluster, id))
+		return
+	}
+	digest.SkipHealthy = false
+	_, err := summaryDigests.runDigest(r.Context(), digest, map[string]MonitoringSummary{})
+	updated, _ := summaryDigests.record(id, summaryDigests.now(), err == nil, err)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, "digest_send_failed", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+func withTestDigests(t *testing.T, now *time.Time) {
+	t.Helper()
+	originalStore, originalDir, originalURL := summaryDigests, dataDir, connectURL
+	t.Cleanup(func() { summaryDigests, dataDir, connectURL = originalStore, originalDir, originalURL })
+	dataDir = t.TempDir()
+	summaryDigests = newDigestScheduler(func() time.Time { return *now })
+
+	connect := testutils.NewConnectServer(map[string]testutils.Response{
+		"GET /connectors": {Body: []string{"orders-sink", "billing-source"}},
+		"GET /connectors/orders-sink/status": {Body: map[string]interface{}{
+			"name": "orders-sink", "type": "sink", "connector": map[string]string{"state": "RUNNING"},
+			"tasks": []interface{}{map[string]interface{}{"id": 0, "state": "FAILED"}, map[string]interface{}{"id": 1, "state": "RUNNING"}},
+		}},
+		"GET /connectors/billing-source/status": {Body: map[string]interface{}{
+			"name": "billing-source", "type": "source", "connector": map[string]string{"state": "RUNNING"},
+			"tasks": []interface{}{map[string]interface{}{"id": 0, "state": "RUNNING"}},
+		}},
+	})
+	t.Cleanup(connect.Close)
+	connectURL = connect.URL()
+}
+
+// digestReceiver records the JSON bodies posted to it.
+type digestReceiver struct {
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+}
+
+func (d *digestReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	d.mu.Lock()
+	d.bodies = append(d.bodies, body)
+	d.mu.Unlock()
+}
+
+func (d *digestReceiver) received() []map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]map[string]interface{}(nil), d.bodies...)
+}
+
+func TestRenderDigest(t *testing.T) {
+	summary := MonitoringSummary{
+		TotalConnectors: 3,
+		Totals:          map[string]int{"running": 1, "degraded": 1, "failed": 1},
+		Connectors: []ConnectorStatusOverview{
+			{Name: "orders-sink", State: "running", TaskStates: map[string]int{"running": 3, "failed": 1}},
+			{Name: "billing-source", State: "running", TaskStates: map[string]int{"running": 1}},
+			{Name: "users-cdc", State: "failed"},
+		},
+	}
+	connectors := digestConnectors(summary)
+	if len(connectors) != 2 || connectors[0].Name != "users-cdc" || connectors[1].State != "degraded" || connectors[1].Tasks != 4 {
+		t.Fatalf("expected the failed connector before the degraded one, got %+v", connectors)
+	}
+	subject, message := renderDigest("prod", summary, connectors)
+	if subject != "[kconnect] prod health: 1 failed, 1 degraded" {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+	if !strings.Contains(message, "3 connectors, 1 running") || !strings.Contains(message, "\nFailed: users-cdc\nDegraded: orders-sink (1/4 tasks failed)") {
+		t.Fatalf("unexpected message %q", message)
+	}
+	if _, message := renderDigest("prod", MonitoringSummary{Totals: map[string]int{}}, nil); !strings.HasSuffix(message, "All connectors are healthy.") {
+		t.Fatalf("unexpected healthy message %q", message)
+	}
+}
+
+func TestDigestSchedulerSendsWhenDue(t *testing.T) {
+	now := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	withTestDigests(t, &now)
+	receiver := &digestReceiver{}
+	webhook := httptest.NewServer(receiver)
+	defer webhook.Close()
+
+	req := digestRequest{Cron: "0 9 * * *", URL: webhook.URL + "/hooks/s3cret", Format: digestFormatSummary}.normalize()
+	digest, err := summaryDigests.add("default", req, "alice")
+	if err != nil || digest.NextRun == nil || !digest.NextRun.Equal(time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected digest %+v %v", digest, err)
+	}
+	if strings.Contains(digest.URL, "s3cret") {
+		t.Fatalf("expected the webhook URL to be masked, got %s", digest.URL)
+	}
+
+	summaryDigests.tick(context.Background())
+	if got := receiver.received(); len(got) != 0 {
+		t.Fatalf("expected nothing before 09:00, got %v", got)
+	}
+
+	// The proxy was down at 09:00; the missed digest is sent once.
+	now = time.Date(2024, 5, 6, 11, 30, 0, 0, time.UTC)
+	summaryDigests.tick(context.Background())
+	summaryDigests.tick(context.Background())
+	got := receiver.received()
+	if len(got) != 1 || got[0]["type"] != "summary_digest" || got[0]["summary"] == nil {
+		t.Fatalf("expected one summary digest, got %v", got)
+	}
+	if connectors := got[0]["connectors"].([]interface{}); len(connectors) != 1 || connectors[0].(map[string]interface{})["state"] != "degraded" {
+		t.Fatalf("expected orders-sink to be degraded, got %v", got[0]["connectors"])
+	}
+
+	reloaded := newDigestScheduler(func() time.Time { return now })
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	listed := reloaded.list("default")
+	if len(listed) != 1 || listed[0].LastSentAt == nil || !listed[0].NextRun.Equal(time.Date(2024, 5, 7, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the run to be persisted, got %+v", listed)
+	}
+}
+
+func TestSummaryDigestsHandlers(t *testing.T) {
+	now := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	withTestDigests(t, &now)
+	withTestAuditLog(t, 10)
+	receiver := &digestReceiver{}
+	slack := httptest.NewServer(receiver)
+	defer slack.Close()
+
+	call := func(handler http.HandlerFunc, method, path, body string, vars map[string]string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(method, path, bytes.NewBufferString(body)), vars)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+	cluster := map[string]string{"cluster": "default"}
+
+	for _, body := range []string{
+		`{"cron": "every day", "url": "https://hooks.example.com/x"}`,
+		`{"cron": "0 9 * * *", "url": "hooks.example.com/x"}`,
+		`{"cron": "0 9 * * *", "url": "https://hooks.example.com/x", "channel": "teams"}`,
+		`{"cron": "0 9 * * *", "url": "https://hooks.example.com/x", "channel": "slack", "format": "summary"}`,
+	} {
+		if rr := call(summaryDigestsHandler, http.MethodPost, "/api/default/digests", body, cluster); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, rr.Code)
+		}
+	}
+
+	rr := call(summaryDigestsHandler, http.MethodPost, "/api/default/digests", `{"cron": "0 9 * * 1-5", "timezone": "Europe/Berlin", "channel": "slack", "url": "`+slack.URL+`/services/T0/B0/s3cret"}`, cluster)
+	var digest SummaryDigest
+	if err := json.Unmarshal(rr.Body.Bytes(), &digest); err != nil || rr.Code != http.StatusCreated || digest.Format != digestFormatDigest {
+		t.Fatalf("unexpected create response %d %s", rr.Code, rr.Body.String())
+	}
+	if entries := auditLog.Query(AuditFilter{Action: auditActionCreateDigest}); len(entries) != 1 || strings.Contains(rr.Body.String(), "s3cret") {
+		t.Fatalf("expected an audited digest with a masked URL, got %v %s", entries, rr.Body.String())
+	}
+	if rr := call(summaryDigestsHandler, http.MethodGet, "/api/default/digests", "", cluster); !strings.Contains(rr.Body.String(), `"id":"1"`) {
+		t.Fatalf("expected the digest to be listed, got %s", rr.Body.String())
+	}
+
+	vars := map[string]string{"cluster": "default", "id": digest.ID}
+	if rr := call(sendDigestHandler, http.MethodPost, "/api/default/digests/1/send", "", vars); rr.Code != http.StatusOK {
+		t.Fatalf("expected the digest to be sent, got %d %s", rr.Code, rr.Body.String())
+	}
+	if got := receiver.received(); len(got) != 1 || !strings.Contains(got[0]["text"].(string), "Degraded: orders-sink (1/2 tasks failed)") {
+		t.Fatalf("expected a Slack message, got %v", got)
+	}
+
+	if rr := call(summaryDigestHandler, http.MethodDelete, "/api/default/digests/1", "", vars); rr.Code != http.StatusOK {
+		t.Fatalf("expected the digest to be deleted, got %d", rr.Code)
+	}
+	if rr := call(sendDigestHandler, http.MethodPost, "/api/default/digests/1/send", "", vars); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after deleting, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const desiredStateFile = "desired-state.json"
+
+const eventDriftDetected = "drift_detected"
+
+// Where a desired state was registered from.
+const (
+	desiredSourceDeploy = "deploy"
+	desiredSourceUpload = "upload"
+)
+
+const (
+	auditActionSetDesiredState   = "SET_DESIRED_STATE"
+	auditActionClearDesiredState = "CLEAR_DESIRED_STATE"
+)
+
+var (
+	driftCheckInterval = getEnv("DRIFT_CHECK_INTERVAL", "5m")
+
+	driftDetection = newDriftDetector(time.Now)
+)
+
+// DesiredState is the set of connector configs a cluster is expected to run, as
+// registered by the last deployment or uploaded bundle. With Prune the list is the whole
+// cluster, and connectors that are not listed count as drift.
+type DesiredState struct {
+	Cluster      string                       `json:"cluster"`
+	Revision     string                       `json:"revision,omitempty"`
+	Source       string                       `json:"source"`
+	Prune        bool                         `json:"prune"`
+	RegisteredAt time.Time                    `json:"registeredAt"`
+	RegisteredBy string                       `json:"registeredBy,omitempty"`
+	Connectors   map[string]map[string]string `json:"connectors"`
+}
+
+// DriftReport is returned by GET /api/{cluster}/drift. Missing connectors are desired but
+// do not exist, extra ones exist but are not desired (only reported with prune), and
+// Changed holds the redacted diff from the live config to the desired one.
+type DriftReport struct {
+	Cluster      string       `json:"cluster"`
+	Revision     string       `json:"revision,omitempty"`
+	CheckedAt    time.Time    `json:"checkedAt"`
+	Drifted      bool         `json:"drifted"`
+	DriftedSince *time.Time   `json:"driftedSince,omitempty"`
+	InSync       int          `json:"inSync"`
+	Missing      []string     `json:"missing"`
+	Extra        []string     `json:"extra"`
+	Changed      []ConfigDiff `json:"changed"`
+}
+
+// driftSignature identifies the drifted connectors of a report, so that a notification is
+// sent when drift appears or spreads but not on every check while it persists.
+func (r DriftReport) driftSignature() string {
+	changed := make([]string, len(r.Changed))
+	for i, diff := range r.Changed {
+		changed[i] = diff.Connector
+	}
+	return strings.Join(r.Missing, ",") + "|" + strings.Join(r.Extra, ",") + "|" + strings.Join(changed, ",")
+}
+
+// driftDetector keeps the desired state of each cluster, persisted in DATA_DIR, and the
+// last drift report computed for it.
+type driftDetector struct {
+	mu      sync.Mutex
+	desired map[string]DesiredState
+	reports map[string]DriftReport
+	now     func() time.Time
+	notify  func(events []NotificationEvent)
+}
+
+func newDriftDetector(now func() time.Time) *driftDetector {
+	return &driftDetector{
+		desired: make(map[string]DesiredState),
+		reports: make(map[string]DriftReport),
+		now:     now,
+		notify: func(events []NotificationEvent) {
+			if notifications.enabled() {
+				go notifications.deliver(events)
+			}
+		},
+	}
+}
+
+func (d *driftDetector) load() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return loadJSON(desiredStateFile, &d.desired)
+}
+
+func (d *driftDetector) desiredState(cluster string) (DesiredState, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state, ok := d.desired[cluster]
+	return state, ok
+}
+
+// register replaces the desired state of state.Cluster and forgets its last report, which
+// described the previous one. The state is kept in memory even when persisting it fails.
+func (d *driftDetector) register(state DesiredState) (DesiredState, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state.RegisteredAt = d.now().UTC()
+	d.desired[state.Cluster] = state
+	delete(d.reports, state.Cluster)
+	return state, saveJSON(desiredStateFile, d.desired)
+}
+
+func (d *driftDetector) clear(cluster string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.desired[cluster]; !ok {
+		return false, nil
+	}
+	delete(d.desired, cluster)
+	delete(d.reports, cluster)
+	return true, saveJSON(desiredStateFile, d.desired)
+}
+
+// check compares the live connectors of cluster with its desired state and records the
+// report. It returns false when no desired state is registered.
+func (d *driftDetector) check(ctx context.Context, cluster string) (DriftReport, bool, error) {
+	state, ok := d.desiredState(cluster)
+	if !ok {
+		return DriftReport{}, false, nil
+	}
+	live, err := fetchExpandedConnectorStatuses(ctx, connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		return DriftReport{}, true, err
+	}
+	report := compareDesiredState(currentRedactionRules(), state, live, d.now().UTC())
+	return d.record(state, report), true, nil
+}
+
+// record stores report and notifies when the cluster drifted in a way the previous report
+// did not show. A report for a desired state replaced during the check is not stored.
+func (d *driftDetector) record(state DesiredState, report DriftReport) DriftReport {
+	d.mu.Lock()
+	current, ok := d.desired[state.Cluster]
+	if !ok || !current.RegisteredAt.Equal(state.RegisteredAt) {
+		d.mu.Unlock()
+		return report
+	}
+	previous, seen := d.reports[state.Cluster]
+	if report.Drifted {
+		since := report.CheckedAt
+		if seen && previous.DriftedSince != nil {
+			since = *previous.DriftedSince
+		}
+		report.DriftedSince = &since
+	}
+	d.reports[state.Cluster] = report
+	d.mu.Unlock()
+
+	if report.Drifted && (!seen || previous.driftSignature() != report.driftSignature()) {
+		d.notify([]NotificationEvent{driftEvent(report)})
+	}
+	return report
+}
+
+func (d *driftDetector) clusters() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	clusters := make([]string, 0, len(d.desired))
+	for cluster := range d.desired {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	return clusters
+}
+
+// run checks every cluster with a desired state each interval until stop is closed.
+func (d *driftDetector) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, cluster := range d.clusters() {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if _, _, err := d.check(ctx, cluster); err != nil {
+					log.Printf("drift: failed to check cluster %s: %v", cluster, err)
+				}
+				cancel()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// compareDesiredState diffs the live configs, with secret placeholders restored as clients
+// see them, against the desired ones.
+func compareDesiredState(rules redactionRules, state DesiredState, live map[string]expandedConnector, now time.Time) DriftReport {
+	report := DriftReport{
+		Cluster:   state.Cluster,
+		Revision:  state.Revision,
+		CheckedAt: now,
+		Missing:   []string{},
+		Extra:     []string{},
+		Changed:   []ConfigDiff{},
+	}
+
+	for name, desired := range state.Connectors {
+		connector, exists := live[name]
+		if !exists {
+			report.Missing = append(report.Missing, name)
+			continue
+		}
+		current := make(map[string]string, len(connector.Info.Config))
+		for key, value := range connector.Info.Config {
+			current[key] = value
+		}
+		secretRefs.restoreStrings(state.Cluster, name, current)
+		diff := diffConfigs(rules, current, desired)
+		if len(diff.Added)+len(diff.Removed)+len(diff.Changed) == 0 {
+			report.InSync++
+			continue
+		}
+		diff.Connector = name
+		report.Changed = append(report.Changed, diff)
+	}
+	if state.Prune {
+		for name := range live {
+			if _, desired := state.Connectors[name]; !desired {
+				report.Extra = append(report.Extra, name)
+			}
+		}
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Slice(report.Changed, func(i, j int) bool { return report.Changed[i].Connector < report.Changed[j].Connector })
+	report.Drifted = len(report.Missing)+len(report.Extra)+len(report.Changed) > 0
+	return report
+}
+
+func driftEvent(report DriftReport) NotificationEvent {
+	changed := make([]string, len(report.Changed))
+	for i, diff := range report.Changed {
+		changed[i] = diff.Connector
+	}
+	return NotificationEvent{
+		Type:    eventDriftDetected,
+		Cluster: report.Cluster,
+		State:   "drifted",
+		Metadata: map[string]string{
+			"revision": report.Revision,
+			"missing":  strings.Join(report.Missing, ", "),
+			"extra":    strings.Join(report.Extra, ", "),
+			"changed":  strings.Join(changed, ", "),
+		},
+		Value:     float64(len(report.Missing) + len(report.Extra) + len(report.Changed)),
+		Timestamp: report.CheckedAt,
+	}
+}
+
+// registerDeployedState records the configs of an applied deployment as the desired
+// state of cluster.
+func registerDeployedState(r *http.Request, cluster string, payload DeployPayload, configs map[string]map[string]string) {
+	user := requestUser(r)
+	if user == "anonymous" {
+		user = deployUser
+	}
+	state := DesiredState{Cluster: cluster, Revision: payload.Revision, Source: desiredSourceDeploy, Prune: payload.Prune, RegisteredBy: user, Connectors: configs}
+	if _, err := driftDetection.register(state); err != nil {
+		log.Printf("drift: failed to persist the desired state of %s: %v", cluster, err)
+	}
+}
+
+// redactedDesiredState returns state with sensitive values replaced by the redaction
+// placeholder; secret placeholders are kept.
+func redactedDesiredState(rules redactionRules, state DesiredState) DesiredState {
+	connectors := make(map[string]map[string]string, len(state.Connectors))
+	for name, config := range state.Connectors {
+		redacted := make(map[string]string, len(config))
+		for key, value := range config {
+			if rules.isSensitive(key) && !isSecretReference(value) {
+				value = rules.placeholder
+			}
+			redacted[key] = value
+		}
+		connectors[name] = redacted
+	}
+	state.Connectors = connectors
+	return state
+}
+
+// driftHandler reports how the live connectors of a cluster differ from its desired state.
+func driftHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	report, ok, err := driftDetection.check(r.Context(), cluster)
+	switch {
+	case !ok:
+		writeJSONError(w, http.StatusNotFound, "no_desired_state", fmt.Sprintf("no desired state is registered for cluster %s; deploy or upload one first", cluster))
+	case err != nil:
+		writeConnectorsFetchError(w, err)
+	default:
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// desiredStateHandler shows, uploads or removes the desired state of a cluster. Uploads
+// take the body of a deployment, and only register it: nothing is applied.
+func desiredStateHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	switch r.Method {
+	case http.MethodGet:
+		state, ok := driftDetection.desiredState(cluster)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "no_desired_state", fmt.Sprintf("no desired state is registered for cluster %s", cluster))
+			return
+		}
+		writeJSON(w, http.StatusOK, redactedDesiredState(currentRedactionRules(), state))
+
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_body", "failed to read request body")
+			return
+		}
+		payload, configs, err := parseDeployPayload(body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_desired_state", err.Error())
+			return
+		}
+		state := DesiredState{Cluster: cluster, Revision: payload.Revision, Source: desiredSourceUpload, Prune: payload.Prune, RegisteredBy: requestUser(r), Connectors: configs}
+		state, err = driftDetection.register(state)
+		details := map[string]interface{}{"connectors": len(configs), "prune": payload.Prune}
+		if payload.Revision != "" {
+			details["revision"] = payload.Revision
+		}
+		if err != nil {
+			log.Printf("drift: failed to persist the desired state of %s: %v", cluster, err)
+			recordAudit(r, auditActionSetDesiredState, "", http.StatusInternalServerError, details)
+			writeJSONError(w, http.StatusInternalServerError, "persist_failed", err.Error())
+			return
+		}
+		recordAudit(r, auditActionSetDesiredState, "", http.StatusOK, details)
+		writeJSON(w, http.StatusOK, redactedDesiredState(currentRedactionRules(), state))
+
+	case http.MethodDelete:
+		removed, err := driftDetection.clear(cluster)
+		if err != nil {
+			log.Printf("drift: failed to persist the removal of the desired state of %s: %v", cluster, err)
+			recordAudit(r, auditActionClearDesiredState, "", http.StatusInternalServerError, nil)
+			writeJSONError(w, http.StatusInternalServerError, "persist_failed", err.Error())
+			return
+		}
+		if !removed {
+			writeJSONError(w, http.StatusNotFound, "no_desired_state", fmt.Sprintf("no desired state is registered for cluster %s", cluster))
+			return
+		}
+		recordAudit(r, auditActionClearDesiredState, "", http.StatusNoContent, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
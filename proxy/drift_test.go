@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestDriftDetection(t *testing.T) *[]NotificationEvent {
+	t.Helper()
+	originalDetector, originalDir := driftDetection, dataDir
+	t.Cleanup(func() { driftDetection, dataDir = originalDetector, originalDir })
+	dataDir = t.TempDir()
+	driftDetection = newDriftDetector(time.Now)
+	var events []NotificationEvent
+	driftDetection.notify = func(batch []NotificationEvent) { events = append(events, batch...) }
+	return &events
+}
+
+func TestCompareDesiredState(t *testing.T) {
+	state := DesiredState{Cluster: "default", Revision: "abc123", Connectors: map[string]map[string]string{
+		"orders-sink":  {"connector.class": "JdbcSink", "name": "orders-sink", "connection.password": "hunter2", "tasks.max": "2"},
+		"audit-sink":   {"connector.class": "S3Sink", "name": "audit-sink"},
+		"payments-cdc": {"connector.class": "Postgres", "name": "payments-cdc"},
+	}}
+	live := map[string]expandedConnector{}
+	for name, config := range map[string]map[string]string{
+		"orders-sink": {"connector.class": "JdbcSink", "name": "orders-sink", "connection.password": "changed", "tasks.max": "1"},
+		"audit-sink":  {"connector.class": "S3Sink", "name": "audit-sink"},
+		"legacy":      {"connector.class": "FileSink", "name": "legacy"},
+	} {
+		var connector expandedConnector
+		connector.Info.Config = config
+		live[name] = connector
+	}
+
+	report := compareDesiredState(defaultRedactionRules(), state, live, time.Now())
+	if !report.Drifted || report.InSync != 1 || fmt.Sprint(report.Missing) != "[payments-cdc]" || len(report.Extra) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(report.Changed) != 1 || report.Changed[0].Connector != "orders-sink" || len(report.Changed[0].Changed) != 2 {
+		t.Fatalf("expected orders-sink to differ in two keys, got %+v", report.Changed)
+	}
+	if body, _ := json.Marshal(report); strings.Contains(string(body), "hunter2") {
+		t.Fatalf("expected sensitive values to be redacted, got %s", body)
+	}
+
+	state.Prune = true
+	if report := compareDesiredState(defaultRedactionRules(), state, live, time.Now()); fmt.Sprint(report.Extra) != "[legacy]" {
+		t.Fatalf("expected undesired connectors to be reported with prune, got %v", report.Extra)
+	}
+}
+
+func TestDriftDetectorNotifiesOnNewDrift(t *testing.T) {
+	events := withTestDriftDetection(t)
+	state, _ := driftDetection.register(DesiredState{Cluster: "default", Connectors: map[string]map[string]string{}})
+
+	drifted := DriftReport{Cluster: "default", Drifted: true, Missing: []string{"orders-sink"}, CheckedAt: time.Now()}
+	first := driftDetection.record(state, drifted)
+	driftDetection.record(state, drifted)
+	if len(*events) != 1 || (*events)[0].Type != eventDriftDetected || (*events)[0].Metadata["missing"] != "orders-sink" {
+		t.Fatalf("expected one notification while the drift persists, got %+v", *events)
+	}
+
+	drifted.Extra = []string{"legacy"}
+	drifted.CheckedAt = drifted.CheckedAt.Add(time.Minute)
+	spread := driftDetection.record(state, drifted)
+	if len(*events) != 2 || !spread.DriftedSince.Equal(*first.DriftedSince) {
+		t.Fatalf("expected spreading drift to notify and keep its start, got %d events, %+v", len(*events), spread)
+	}
+
+	driftDetection.record(state, DriftReport{Cluster: "default"})
+	driftDetection.record(state, drifted)
+	if len(*events) != 3 {
+		t.Fatalf("expected drift reappearing after a clean check to notify again, got %d events", len(*events))
+	}
+
+	// A report for a replaced desired state is stale.
+	driftDetection.register(DesiredState{Cluster: "default", Connectors: map[string]map[string]string{}})
+	driftDetection.record(state, DriftReport{Cluster: "default", Drifted: true, Missing: []string{"other"}})
+	if len(*events) != 3 {
+		t.Fatalf("expected a stale report to be dropped, got %d events", len(*events))
+	}
+}
+
+func TestDriftHandlers(t *testing.T) {
+	withTestTrustedAuthHeaders(t)
+	events := withTestDriftDetection(t)
+	logger := withTestAuditLog(t, 10)
+
+	var mu sync.Mutex
+	live := map[string]map[string]string{
+		"orders-sink": {"connector.class": "JdbcSink", "name": "orders-sink", "tasks.max": "1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		expanded := map[string]interface{}{}
+		for name, config := range live {
+			expanded[name] = map[string]interface{}{"info": map[string]interface{}{"config": config}}
+		}
+		json.NewEncoder(w).Encode(expanded)
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	do := func(handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		req.Header.Set("X-Forwarded-User", "alice")
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	if rr := do(driftHandler, http.MethodGet, "/api/default/drift", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a desired state, got %d", rr.Code)
+	}
+	if rr := do(desiredStateHandler, http.MethodPut, "/api/default/drift/desired", `{"connectors": [{"name": "a", "config": {}}]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid bundle to be rejected, got %d", rr.Code)
+	}
+
+	bundle := `{"revision": "abc123", "connectors": [{"name": "orders-sink", "config": {"connector.class": "JdbcSink", "tasks.max": 2, "connection.password": "hunter2"}}]}`
+	rr := do(desiredStateHandler, http.MethodPut, "/api/default/drift/desired", bundle)
+	var state DesiredState
+	json.Unmarshal(rr.Body.Bytes(), &state)
+	if rr.Code != http.StatusOK || state.Source != desiredSourceUpload || state.RegisteredBy != "alice" || strings.Contains(rr.Body.String(), "hunter2") {
+		t.Fatalf("unexpected upload %d: %s", rr.Code, rr.Body.String())
+	}
+
+	reloaded := newDriftDetector(time.Now)
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if persisted, ok := reloaded.desiredState("default"); !ok || persisted.Connectors["orders-sink"]["tasks.max"] != "2" {
+		t.Fatalf("expected the desired state to be persisted, got %+v", persisted)
+	}
+
+	rr = do(driftHandler, http.MethodGet, "/api/default/drift", "")
+	var report DriftReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	if rr.Code != http.StatusOK || !report.Drifted || len(report.Changed) != 1 || report.Revision != "abc123" || len(*events) != 1 {
+		t.Fatalf("unexpected drift report %d: %s (%d events)", rr.Code, rr.Body.String(), len(*events))
+	}
+
+	mu.Lock()
+	live["orders-sink"] = map[string]string{"connector.class": "JdbcSink", "name": "orders-sink", "tasks.max": "2", "connection.password": "hunter2"}
+	mu.Unlock()
+	rr = do(driftHandler, http.MethodGet, "/api/default/drift", "")
+	report = DriftReport{}
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	if report.Drifted || report.InSync != 1 || report.DriftedSince != nil {
+		t.Fatalf("expected the cluster to be in sync, got %s", rr.Body.String())
+	}
+
+	if rr := do(desiredStateHandler, http.MethodDelete, "/api/default/drift/desired", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if rr := do(desiredStateHandler, http.MethodGet, "/api/default/drift/desired", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the desired state to be gone, got %d", rr.Code)
+	}
+
+	actions := map[string]int{}
+	for _, entry := range logger.Query(AuditFilter{}) {
+		actions[entry.Action]++
+	}
+	if actions[auditActionSetDesiredState] != 1 || actions[auditActionClearDesiredState] != 1 {
+		t.Fatalf("expected the upload and removal to be audited, got %v", actions)
+	}
+}
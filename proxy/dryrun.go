@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Operations reported by a dry run.
+const (
+	dryRunDelete         = "delete"
+	dryRunCreate         = "create"
+	dryRunUpdate         = "update"
+	dryRunRestartAll     = "restart-all"
+	dryRunRebalance      = "rebalance"
+	dryRunPauseAll       = "pause-all"
+	dryRunResumePrevious = "resume-previous"
+)
+
+// DryRunResult describes what a mutation would do. Nothing is sent to Kafka Connect
+// beyond the reads and the config validation needed to build it. WouldSucceed is false
+// when the config does not pass validation.
+type DryRunResult struct {
+	DryRun       bool              `json:"dryRun"`
+	Operation    string            `json:"operation"`
+	Connector    string            `json:"connector,omitempty"`
+	Description  string            `json:"description"`
+	WouldSucceed bool              `json:"wouldSucceed"`
+	State        string            `json:"state,omitempty"`
+	Tasks        int               `json:"tasks,omitempty"`
+	Diff         *ConfigDiff       `json:"diff,omitempty"`
+	Validation   *ConfigValidation `json:"validation,omitempty"`
+	Connectors   []string          `json:"connectors,omitempty"`
+}
+
+// ConfigValidation is the outcome of Kafka Connect's config validation. Errors maps
+// each invalid setting to its messages.
+type ConfigValidation struct {
+	ErrorCount int                 `json:"errorCount"`
+	Errors     map[string][]string `json:"errors"`
+	// values holds the value Connect parsed for each setting it knows, after expanding
+	// ConfigProvider references. Passwords come back as [hidden].
+	values map[string]string
+}
+
+// isDryRun reports whether the request asked for ?dryRun=true.
+func isDryRun(r *http.Request) bool {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	return dryRun
+}
+
+// dryRunSupported reports whether proxyHandler can dry-run a request: deleting a
+// connector or replacing its config.
+func dryRunSupported(method, subresource string) bool {
+	return (method == http.MethodDelete && subresource == "") || (method == http.MethodPut && subresource == "config")
+}
+
+// dryRunServed reports whether a ?dryRun=true request is answered with a simulated
+// result instead of changing anything. Other endpoints ignore the parameter and pass
+// the request on, so a dry run of them is a real mutation.
+func dryRunServed(r *http.Request) bool {
+	if !isDryRun(r) {
+		return false
+	}
+	segments := clusterPathSegments(r.URL.Path)
+	switch {
+	case len(segments) == 1 && segments[0] == "deploy":
+		return r.Method == http.MethodPost
+	case len(segments) == 3 && segments[0] == "cluster" && segments[1] == "actions":
+		return r.Method == http.MethodPost
+	case len(segments) == 3 && segments[0] == "connectors" && segments[1] == "metadata" && segments[2] == "bulk":
+		return r.Method == http.MethodPost
+	case len(segments) == 3 && segments[0] == "connectors" && segments[2] == "config" && r.Method == http.MethodPatch:
+		return true
+	}
+	_, subresource, ok := connectorPathInfo(r.URL.Path)
+	return ok && dryRunSupported(r.Method, subresource)
+}
+
+// writeDryRunError reports a failed read made while building a dry run.
+func writeDryRunError(w http.ResponseWriter, err error, name string) {
+	var unavailable *connectUnavailableError
+	switch {
+	case errors.Is(err, errConnectorNotFound):
+		writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", name))
+	case errors.As(err, &unavailable):
+		writeConnectUnavailable(w, err)
+	default:
+		writeJSONError(w, http.StatusBadGateway, "dry_run_failed", err.Error())
+	}
+}
+
+// dryRunConnector answers a dry run of DELETE /connectors/{name} or
+// PUT /connectors/{name}/config.
+func dryRunConnector(w http.ResponseWriter, r *http.Request, name, subresource string) {
+	cluster := mux.Vars(r)["cluster"]
+	baseURL := connectURLFor(cluster)
+	client := connectClientFor(cluster, routeRead)
+
+	if r.Method == http.MethodDelete {
+		status, err := fetchConnectorStatus(r.Context(), client, baseURL, name)
+		if err != nil {
+			writeDryRunError(w, err, name)
+			return
+		}
+		writeJSON(w, http.StatusOK, DryRunResult{
+			DryRun:       true,
+			Operation:    dryRunDelete,
+			Connector:    name,
+			Description:  fmt.Sprintf("delete connector %s and its %d task(s)", name, len(status.Tasks)),
+			WouldSucceed: true,
+			State:        normalizeState(status.Connector.State),
+			Tasks:        len(status.Tasks),
+		})
+		return
+	}
+
+	var candidate map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&candidate); err != nil || candidate == nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a connector config object with string values")
+		return
+	}
+	class := strings.TrimSpace(candidate["connector.class"])
+	if class == "" {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "connector config must set connector.class")
+		return
+	}
+
+	result := DryRunResult{DryRun: true, Operation: dryRunUpdate, Connector: name}
+	live, err := fetchConnectorConfig(r.Context(), client, baseURL, name)
+	switch {
+	case errors.Is(err, errConnectorNotFound):
+		result.Operation = dryRunCreate
+		result.Description = fmt.Sprintf("create connector %s", name)
+	case err != nil:
+		writeDryRunError(w, err, name)
+		return
+	default:
+		secretRefs.restoreStrings(cluster, name, live)
+		diff := diffConfigs(currentRedactionRules(), live, candidate)
+		diff.Connector = name
+		result.Diff = &diff
+		result.Description = fmt.Sprintf("update connector %s: %d added, %d removed, %d changed setting(s)",
+			name, len(diff.Added), len(diff.Removed), len(diff.Changed))
+	}
+
+	// Placeholders are resolved so Connect validates the values it would receive.
+	resolved := make(map[string]interface{}, len(candidate)+1)
+	for key, value := range candidate {
+		resolved[key] = value
+	}
+	resolved["name"] = name
+	if _, err := connectorSecrets.resolveConfig(r.Context(), resolved); err != nil {
+		writeSecretResolutionError(w, err)
+		return
+	}
+	validation, err := validateConnectorConfig(r.Context(), connectClientFor(cluster, routeValidate), baseURL, class, resolved)
+	if err != nil {
+		writeDryRunError(w, err, name)
+		return
+	}
+	result.Validation = validation
+	result.WouldSucceed = validation.ErrorCount == 0
+	writeJSON(w, http.StatusOK, result)
+}
+
+// validateConnectorConfig runs Kafka Connect's validation of config against the plugin.
+func validateConnectorConfig(ctx context.Context, client *http.Client, baseURL, class string, config map[string]interface{}) (*ConfigValidation, error) {
+	payload, err := requestConfigValidation(ctx, client, baseURL, class, config)
+	if err != nil {
+		return nil, err
+	}
+
+	validation := &ConfigValidation{ErrorCount: payload.ErrorCount, Errors: map[string][]string{}, values: map[string]string{}}
+	for _, config := range payload.Configs {
+		if len(config.Value.Errors) > 0 {
+			validation.Errors[config.Value.Name] = config.Value.Errors
+		}
+		if config.Value.Value != nil {
+			validation.values[config.Value.Name] = *config.Value.Value
+		}
+	}
+	return validation, nil
+}
+
+// dryRunClusterAction lists the connectors a cluster action would affect.
+func dryRunClusterAction(w http.ResponseWriter, r *http.Request, operation string) {
+	names, err := fetchConnectorNames(r.Context(), connectClientFor(mux.Vars(r)["cluster"], routeRead), connectURLFor(mux.Vars(r)["cluster"]))
+	if err != nil {
+		writeDryRunError(w, err, "")
+		return
+	}
+	sort.Strings(names)
+
+	description := fmt.Sprintf("restart %d connector(s) and their tasks", len(names))
+	if operation == dryRunRebalance {
+		description = fmt.Sprintf("rebalance %d connector(s) across the workers", len(names))
+	}
+	writeJSON(w, http.StatusOK, DryRunResult{
+		DryRun:       true,
+		Operation:    operation,
+		Description:  description,
+		WouldSucceed: true,
+		Connectors:   names,
+	})
+}
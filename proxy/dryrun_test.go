@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// dryRunTestServer fakes a Connect cluster with the connector "orders" and records
+// every mutation it receives.
+func dryRunTestServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu        sync.Mutex
+		mutations []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors":
+			io.WriteString(w, `["orders","billing"]`)
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors/orders/status":
+			io.WriteString(w, `{"name":"orders","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"},{"id":1,"state":"RUNNING"}]}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors/orders/config":
+			io.WriteString(w, `{"connector.class":"FileStreamSink","tasks.max":"2","topics":"orders"}`)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/config/validate"):
+			var config map[string]string
+			json.NewDecoder(r.Body).Decode(&config)
+			if config["topics"] == "" {
+				io.WriteString(w, `{"error_count":1,"configs":[{"value":{"name":"topics","errors":["Must configure one of topics or topics.regex"]}},{"value":{"name":"tasks.max","errors":[]}}]}`)
+				return
+			}
+			io.WriteString(w, `{"error_count":0,"configs":[]}`)
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error_code":404,"message":"not found"}`)
+		default:
+			mu.Lock()
+			mutations = append(mutations, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), mutations...)
+	}
+}
+
+func TestProxyHandlerDryRun(t *testing.T) {
+	server, mutations := dryRunTestServer(t)
+	defer withTestConnectURL(t, server)()
+
+	do := func(method, path, body string) (*httptest.ResponseRecorder, DryRunResult) {
+		req := httptest.NewRequest(method, "/api/default/connectors/"+path, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "path": strings.SplitN(path, "?", 2)[0]})
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		var result DryRunResult
+		json.Unmarshal(rr.Body.Bytes(), &result)
+		return rr, result
+	}
+
+	rr, result := do(http.MethodDelete, "orders?dryRun=true", "")
+	if rr.Code != http.StatusOK || !result.DryRun || result.Operation != dryRunDelete || result.State != "running" || result.Tasks != 2 {
+		t.Fatalf("unexpected delete dry run %d %+v", rr.Code, result)
+	}
+	if rr, _ := do(http.MethodDelete, "missing?dryRun=true", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing connector, got %d", rr.Code)
+	}
+
+	rr, result = do(http.MethodPut, "orders/config?dryRun=true", `{"connector.class":"FileStreamSink","tasks.max":"1","topics":"orders"}`)
+	if rr.Code != http.StatusOK || result.Operation != dryRunUpdate || !result.WouldSucceed || result.Diff == nil || len(result.Diff.Changed) != 1 {
+		t.Fatalf("unexpected update dry run %d %+v", rr.Code, result)
+	}
+	if len(result.Diff.Warnings) != 1 || result.Diff.Warnings[0].Code != diffWarningTasksReduced {
+		t.Fatalf("expected the tasks.max warning, got %+v", result.Diff.Warnings)
+	}
+
+	rr, result = do(http.MethodPut, "payments/config?dryRun=true", `{"connector.class":"FileStreamSink"}`)
+	if rr.Code != http.StatusOK || result.Operation != dryRunCreate || result.WouldSucceed || result.Validation.ErrorCount != 1 ||
+		len(result.Validation.Errors["topics"]) != 1 || len(result.Validation.Errors) != 1 {
+		t.Fatalf("unexpected create dry run %d %+v", rr.Code, result)
+	}
+	if rr, _ := do(http.MethodPut, "orders/config?dryRun=true", `{"tasks.max":"1"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without connector.class, got %d", rr.Code)
+	}
+
+	if got := mutations(); len(got) != 0 {
+		t.Fatalf("expected no mutation to reach Connect, got %v", got)
+	}
+	do(http.MethodDelete, "orders?dryRun=false", "")
+	if got := mutations(); len(got) != 1 || got[0] != "DELETE /connectors/orders" {
+		t.Fatalf("expected dryRun=false to be forwarded, got %v", got)
+	}
+}
+
+func TestClusterActionDryRun(t *testing.T) {
+	server, mutations := dryRunTestServer(t)
+	defer withTestConnectURL(t, server)()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/default/cluster/actions/restart?dryRun=true", nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "action": "restart"})
+	rr := httptest.NewRecorder()
+	clusterActionHandler(rr, req)
+
+	var result DryRunResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if result.Operation != dryRunRestartAll || len(result.Connectors) != 2 || result.Connectors[0] != "billing" {
+		t.Fatalf("unexpected cluster dry run %+v", result)
+	}
+	if got := mutations(); len(got) != 0 {
+		t.Fatalf("expected the restart not to be forwarded, got %v", got)
+	}
+}
+
+func TestDryRunServed(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+		want         bool
+	}{
+		{http.MethodDelete, "/api/default/connectors/orders?dryRun=true", true},
+		{http.MethodPut, "/api/default/connectors/orders/config?dryRun=true", true},
+		{http.MethodPatch, "/api/default/connectors/orders/config?dryRun=true", true},
+		{http.MethodPost, "/api/default/cluster/actions/restart?dryRun=true", true},
+		{http.MethodPost, "/api/default/deploy?dryRun=true", true},
+		{http.MethodPost, "/api/default/connectors/metadata/bulk?dryRun=true", true},
+		{http.MethodDelete, "/api/default/connectors/orders", false},
+		{http.MethodPut, "/api/default/connectors/orders/pause?dryRun=true", false},
+		{http.MethodPost, "/api/default/connectors?dryRun=true", false},
+		{http.MethodPost, "/api/default/connectors/orders/restart?dryRun=true", false},
+	} {
+		if got := dryRunServed(httptest.NewRequest(tc.method, tc.path, nil)); got != tc.want {
+			t.Errorf("%s %s: expected %v, got %v", tc.method, tc.path, tc.want, got)
+		}
+	}
+}
+
+func TestAuditMiddlewareSkipsDryRuns(t *testing.T) {
+	logger := withTestAuditLog(t, 10)
+
+	handler := auditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodDelete, "/api/default/connectors/orders?dryRun=true", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if entries := logger.Query(AuditFilter{}); len(entries) != 0 {
+		t.Fatalf("expected dry runs not to be audited, got %+v", entries)
+	}
+
+	// Endpoints without dry-run support forward the request, so it is audited.
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodPut, "/api/default/connectors/orders/pause?dryRun=true", ""},
+		{http.MethodPost, "/api/default/connectors/orders/restart?dryRun=true", ""},
+		{http.MethodPost, "/api/default/connectors?dryRun=true", `{"name":"billing","config":{}}`},
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+	}
+	entries := logger.Query(AuditFilter{})
+	if len(entries) != 3 || entries[0].Action != auditActionCreate || entries[0].ConnectorName != "billing" || entries[2].Action != auditActionPause {
+		t.Fatalf("expected mutations without dry-run support to be audited, got %+v", entries)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxUpstreamErrorBody bounds how much of a Kafka Connect error body is read.
+const maxUpstreamErrorBody = 64 << 10
+
+// ProxyError is the error body of every proxy endpoint. Error is a stable,
+// machine-readable code the web UI categorizes by; Message is for people. Errors that
+// came from Kafka Connect keep Connect's error_code and carry its answer in Upstream.
+type ProxyError struct {
+	Error       string         `json:"error"`
+	Message     string         `json:"message"`
+	Remediation string         `json:"remediation,omitempty"`
+	ErrorCode   int            `json:"error_code,omitempty"`
+	Upstream    *UpstreamError `json:"upstream,omitempty"`
+}
+
+// UpstreamError is what Kafka Connect answered.
+type UpstreamError struct {
+	Status    int    `json:"status"`
+	ErrorCode int    `json:"error_code,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// errorRemediations suggests what to do about an error code.
+var errorRemediations = map[string]string{
+	"connect_unreachable":      "Check that Kafka Connect is running and KAFKA_CONNECT_URL points at it; requests resume once the circuit closes.",
+	"connect_unavailable":      "Check that Kafka Connect is running and reachable from the proxy.",
+	"connect_busy":             "Too many requests are waiting for Kafka Connect; retry after the Retry-After delay.",
+	"connect_request_failed":   "Check the network path from the proxy to Kafka Connect and the proxy logs.",
+	"upstream_timeout":         "Kafka Connect did not answer in time; retry, or raise the timeout of this route class.",
+	"connect_request_timeout":  "The Connect worker timed out forwarding the request, usually during a rebalance; retry in a few seconds.",
+	"rebalance_in_progress":    "The Connect cluster is rebalancing; retry once it has settled.",
+	"connector_not_found":      "Check the connector name and cluster; it may have been deleted.",
+	"task_not_found":           "Check the task ID; the connector may have fewer tasks after a reconfiguration.",
+	"not_found":                "Check the path and cluster.",
+	"connector_exists":         "Choose another connector name, or update the existing connector instead.",
+	"connector_config_invalid": "Validate the config with PUT /connector-plugins/{plugin}/config/validate and fix the reported fields.",
+	"plugin_not_found":         "Install the connector plugin on every worker, or fix connector.class.",
+	"connect_unauthorized":     "Check the proxy's Kafka Connect credentials (KAFKA_CONNECT_USERNAME or Kerberos settings).",
+	"connect_forbidden":        "The proxy's Kafka Connect principal lacks permission for this operation.",
+	"connect_bad_request":      "Kafka Connect rejected the request; check the body and parameters.",
+	"connect_conflict":         "The request conflicts with the connector's current state; refresh and retry.",
+	"connect_internal_error":   "Kafka Connect failed to handle the request; check the worker logs.",
+	"invalid_path":             "Use a path under /api/{cluster}/ without '..' or encoded slashes.",
+	"metrics_unavailable":      "Enable JMX metrics on the Connect workers to see them here.",
+	"thread_dump_failed":       "Allow exec on java.lang:type=Threading in the workers' Jolokia access policy and list every worker in JOLOKIA_URL.",
+	"summary_fetch_failed":     "Check that Kafka Connect is reachable; the summary is retried on the next request.",
+	"unauthenticated":          "Sign in again; the session may have expired.",
+	"tenant_forbidden":         "The connector or endpoint is outside your team's namespaces; ask a tenancy admin.",
+}
+
+// connectErrorRules map Kafka Connect answers to codes, first match wins. A zero status
+// matches any status; Contains is matched against the lowercased message.
+var connectErrorRules = []struct {
+	Status   int
+	Contains string
+	Code     string
+}{
+	{http.StatusConflict, "rebalance", "rebalance_in_progress"},
+	{http.StatusConflict, "stale configuration", "rebalance_in_progress"},
+	{http.StatusConflict, "already exists", "connector_exists"},
+	{0, "connector configuration is invalid", "connector_config_invalid"},
+	{0, "failed to find any class that implements connector", "plugin_not_found"},
+	{0, "request timed out", "connect_request_timeout"},
+	{http.StatusNotFound, "task", "task_not_found"},
+	{http.StatusNotFound, "connector", "connector_not_found"},
+	{http.StatusUnauthorized, "", "connect_unauthorized"},
+	{http.StatusForbidden, "", "connect_forbidden"},
+}
+
+// classifyConnectError returns the code of a Kafka Connect error answer.
+func classifyConnectError(status int, message string) string {
+	lower := strings.ToLower(message)
+	for _, rule := range connectErrorRules {
+		if (rule.Status == 0 || rule.Status == status) && strings.Contains(lower, rule.Contains) {
+			return rule.Code
+		}
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return "not_found"
+	case status == http.StatusConflict:
+		return "connect_conflict"
+	case status >= 500:
+		return "connect_internal_error"
+	default:
+		return "connect_bad_request"
+	}
+}
+
+// translateConnectError builds the proxy error for a Kafka Connect error answer. Bodies
+// other than Connect's {"error_code","message"} are kept, shortened, as the upstream
+// message.
+func translateConnectError(status int, body []byte) ProxyError {
+	upstream := &UpstreamError{Status: status}
+	var connectErr struct {
+		ErrorCode int    `json:"error_code"`
+		Message   string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &connectErr); err == nil && connectErr.Message != "" {
+		upstream.ErrorCode, upstream.Message = connectErr.ErrorCode, connectErr.Message
+	} else {
+		upstream.Message = truncateRunes(strings.TrimSpace(string(body)), 512)
+	}
+
+	code := classifyConnectError(status, connectErr.Message)
+	message := upstream.Message
+	if connectErr.Message == "" {
+		message = fmt.Sprintf("Kafka Connect answered HTTP %d", status)
+	}
+	return ProxyError{
+		Error:       code,
+		Message:     message,
+		Remediation: errorRemediations[code],
+		ErrorCode:   upstream.ErrorCode,
+		Upstream:    upstream,
+	}
+}
+
+// writeConnectError answers with the translated error of a failed Kafka Connect
+// response, keeping Connect's status code.
+func writeConnectError(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamErrorBody))
+	if err != nil {
+		log.Printf("failed to read Kafka Connect error response: %v", err)
+	}
+	writeJSON(w, resp.StatusCode, translateConnectError(resp.StatusCode, body))
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTranslateConnectError(t *testing.T) {
+	for _, tt := range []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusNotFound, `{"error_code":404,"message":"Connector orders-sink not found"}`, "connector_not_found"},
+		{http.StatusNotFound, `{"error_code":404,"message":"Task orders-sink-3 not found"}`, "task_not_found"},
+		{http.StatusNotFound, `{"error_code":404,"message":"HTTP 404 Not Found"}`, "not_found"},
+		{http.StatusConflict, `{"error_code":409,"message":"Cannot complete request because of a conflicting operation (e.g. worker rebalance)"}`, "rebalance_in_progress"},
+		{http.StatusConflict, `{"error_code":409,"message":"Connector orders-sink already exists"}`, "connector_exists"},
+		{http.StatusBadRequest, `{"error_code":400,"message":"Connector configuration is invalid and contains the following 1 error(s)"}`, "connector_config_invalid"},
+		{http.StatusInternalServerError, `{"error_code":500,"message":"Failed to find any class that implements Connector and which name matches com.example.Missing"}`, "plugin_not_found"},
+		{http.StatusInternalServerError, `{"error_code":500,"message":"Request timed out"}`, "connect_request_timeout"},
+		{http.StatusInternalServerError, `{"error_code":500,"message":"boom"}`, "connect_internal_error"},
+		{http.StatusUnauthorized, `<html>Unauthorized</html>`, "connect_unauthorized"},
+		{http.StatusBadGateway, `<html><body>Bad gateway: no connector here</body></html>`, "connect_internal_error"},
+	} {
+		got := translateConnectError(tt.status, []byte(tt.body))
+		if got.Error != tt.want || got.Upstream == nil || got.Upstream.Status != tt.status {
+			t.Errorf("%d %s: expected %s, got %+v", tt.status, tt.body, tt.want, got)
+		}
+		if got.Remediation == "" {
+			t.Errorf("%s: expected a remediation", got.Error)
+		}
+	}
+
+	got := translateConnectError(http.StatusBadGateway, []byte(`<html>`+strings.Repeat("x", 1000)+`</html>`))
+	if got.Message != "Kafka Connect answered HTTP 502" || len([]rune(got.Upstream.Message)) != 513 || got.ErrorCode != 0 {
+		t.Fatalf("expected a generic message with the shortened body upstream, got %+v", got)
+	}
+}
+
+func TestProxyHandlerTranslatesConnectErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `{"error_code":409,"message":"Cannot complete request momentarily due to stale configuration (typically caused by a concurrent config change)"}`)
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	for name, call := range map[string]func(*httptest.ResponseRecorder){
+		"proxy": func(rr *httptest.ResponseRecorder) {
+			req := httptest.NewRequest(http.MethodPut, "/api/default/connectors/orders/pause", nil)
+			proxyHandler(rr, mux.SetURLVars(req, map[string]string{"cluster": "default", "path": "orders/pause"}))
+		},
+		"cluster action": func(rr *httptest.ResponseRecorder) {
+			req := httptest.NewRequest(http.MethodPost, "/api/default/cluster/actions/rebalance", nil)
+			clusterActionHandler(rr, mux.SetURLVars(req, map[string]string{"cluster": "default", "action": "rebalance"}))
+		},
+		"cluster info": func(rr *httptest.ResponseRecorder) {
+			req := httptest.NewRequest(http.MethodGet, "/api/default/cluster", nil)
+			clusterInfoHandler(rr, mux.SetURLVars(req, map[string]string{"cluster": "default"}))
+		},
+	} {
+		rr := httptest.NewRecorder()
+		call(rr)
+		var body ProxyError
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: expected a JSON error, got %q", name, rr.Body.String())
+		}
+		if rr.Code != http.StatusConflict || body.Error != "rebalance_in_progress" || body.ErrorCode != 409 || body.Upstream == nil || !strings.Contains(body.Upstream.Message, "stale configuration") {
+			t.Fatalf("%s: unexpected error %d %+v", name, rr.Code, body)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	suggestionReasonCase    = "case"
+	suggestionReasonPrefix  = "prefix"
+	suggestionReasonSimilar = "similar"
+
+	maxNameSuggestions = 10
+)
+
+// NameSuggestion is an existing connector whose name is close to the requested one.
+type NameSuggestion struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ConnectorExistence reports whether a connector name is taken and lists near misses.
+type ConnectorExistence struct {
+	Name        string           `json:"name"`
+	Exists      bool             `json:"exists"`
+	Suggestions []NameSuggestion `json:"suggestions"`
+}
+
+// canonicalConnectorName lowercases a name and drops separators so that
+// "Orders-Sink", "orders_sink" and "orders.sink" compare equal.
+func canonicalConnectorName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', '.', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// checkConnectorName classifies existing connector names against name. Exact matches
+// set Exists; case-insensitive or separator-only differences, prefix variants and
+// near-identical spellings are returned as suggestions, closest first.
+func checkConnectorName(name string, existing []string) ConnectorExistence {
+	result := ConnectorExistence{Name: name, Suggestions: []NameSuggestion{}}
+	target := canonicalConnectorName(name)
+
+	rank := map[string]int{suggestionReasonCase: 0, suggestionReasonPrefix: 1, suggestionReasonSimilar: 2}
+	for _, candidate := range existing {
+		if candidate == name {
+			result.Exists = true
+			continue
+		}
+
+		canonical := canonicalConnectorName(candidate)
+		reason := ""
+		switch {
+		case canonical == target:
+			reason = suggestionReasonCase
+		case target != "" && (strings.HasPrefix(canonical, target) || strings.HasPrefix(target, canonical)):
+			reason = suggestionReasonPrefix
+		case len(target) >= 4 && editDistance(canonical, target) <= 2:
+			reason = suggestionReasonSimilar
+		default:
+			continue
+		}
+		result.Suggestions = append(result.Suggestions, NameSuggestion{Name: candidate, Reason: reason})
+	}
+
+	sort.SliceStable(result.Suggestions, func(i, j int) bool {
+		a, b := result.Suggestions[i], result.Suggestions[j]
+		if rank[a.Reason] != rank[b.Reason] {
+			return rank[a.Reason] < rank[b.Reason]
+		}
+		return a.Name < b.Name
+	})
+	if len(result.Suggestions) > maxNameSuggestions {
+		result.Suggestions = result.Suggestions[:maxNameSuggestions]
+	}
+	return result
+}
+
+// connectorExistsHandler answers GET /api/{cluster}/connectors/{name}/exists so the
+// creation form can flag collisions and likely typos before submitting.
+func connectorExistsHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	names, err := fetchConnectorNames(r.Context(), connectClientFor(mux.Vars(r)["cluster"], routeRead), connectURLFor(mux.Vars(r)["cluster"]))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, checkConnectorName(name, names))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCheckConnectorName(t *testing.T) {
+	existing := []string{"orders-sink", "Orders_Source", "orders-sink-v2", "ordres-sink", "payments"}
+
+	result := checkConnectorName("orders-sink", existing)
+	if !result.Exists {
+		t.Fatalf("expected exact match to exist")
+	}
+	got := map[string]string{}
+	for _, s := range result.Suggestions {
+		got[s.Name] = s.Reason
+	}
+	if got["orders-sink-v2"] != suggestionReasonPrefix || got["ordres-sink"] != suggestionReasonSimilar {
+		t.Fatalf("unexpected suggestions: %+v", result.Suggestions)
+	}
+	if _, ok := got["payments"]; ok {
+		t.Fatalf("unrelated connector should not be suggested")
+	}
+
+	result = checkConnectorName("orders.source", existing)
+	if result.Exists {
+		t.Fatalf("expected case variant not to count as existing")
+	}
+	if len(result.Suggestions) == 0 || result.Suggestions[0].Name != "Orders_Source" || result.Suggestions[0].Reason != suggestionReasonCase {
+		t.Fatalf("expected case variant first, got %+v", result.Suggestions)
+	}
+}
+
+func TestConnectorExistsHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`["Orders-Sink","payments"]`))
+	}))
+	defer server.Close()
+	restore := withTestConnectURL(t, server)
+	defer restore()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders-sink/exists", nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "orders-sink"})
+	rr := httptest.NewRecorder()
+	connectorExistsHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var result ConnectorExistence
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Exists || len(result.Suggestions) != 1 || result.Suggestions[0].Name != "Orders-Sink" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// faultInjectionEnabled turns on POST /api/debug/faults. It is meant for local
+	// development and test environments only: faults affect every caller of the proxy.
+	faultInjectionEnabled = getEnv("FAULT_INJECTION", "false")
+
+	upstreamFaults = newFaultInjector(false, rand.Float64)
+)
+
+// maxFaultLatency bounds injected latency, so a forgotten fault cannot hold requests
+// forever.
+const maxFaultLatency = 5 * time.Minute
+
+// Kinds of injected faults.
+const (
+	faultLatency    = "latency"
+	faultError      = "error"
+	faultConnection = "connection"
+)
+
+// FaultRule injects a fault into the Kafka Connect calls whose method and path match.
+// Path is matched against the Connect REST path on segment boundaries, with "*"
+// matching any single segment: "/connectors" matches every connector call and
+// "/connectors/*/status" only status reads. Rate is the share of matching calls that
+// fail; Remaining, when set, removes the rule after that many injections.
+type FaultRule struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Method    string    `json:"method,omitempty"`
+	Path      string    `json:"path"`
+	Latency   string    `json:"latency,omitempty"`
+	Status    int       `json:"status,omitempty"`
+	Rate      float64   `json:"rate"`
+	Remaining int       `json:"remaining,omitempty"`
+	Injected  int       `json:"injected"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy"`
+
+	latency  time.Duration
+	segments []string
+}
+
+// FaultList is returned by GET /api/debug/faults.
+type FaultList struct {
+	Enabled bool        `json:"enabled"`
+	Faults  []FaultRule `json:"faults"`
+}
+
+// errInjectedConnectionFailure is returned for connection faults, as a refused
+// connection would be.
+var errInjectedConnectionFailure = errors.New("injected connection failure")
+
+// faultInjector holds the active fault rules.
+type faultInjector struct {
+	mu      sync.Mutex
+	enabled bool
+	rules   []*FaultRule
+	nextID  int64
+	roll    func() float64
+}
+
+func newFaultInjector(enabled bool, roll func() float64) *faultInjector {
+	return &faultInjector{enabled: enabled, roll: roll}
+}
+
+// loadFaultInjector builds the injector from FAULT_INJECTION.
+func loadFaultInjector() (*faultInjector, error) {
+	enabled, err := strconv.ParseBool(strings.TrimSpace(faultInjectionEnabled))
+	if err != nil {
+		return nil, &configError{name: "FAULT_INJECTION", value: faultInjectionEnabled}
+	}
+	return newFaultInjector(enabled, rand.Float64), nil
+}
+
+// validate checks a rule and fills in its defaults and parsed fields.
+func (rule *FaultRule) validate() error {
+	switch rule.Kind {
+	case faultLatency:
+		d, err := time.ParseDuration(rule.Latency)
+		if err != nil || d <= 0 || d > maxFaultLatency {
+			return fmt.Errorf("latency must be a duration between 1ms and %s, such as 2s", maxFaultLatency)
+		}
+		rule.latency = d
+	case faultError:
+		if rule.Status == 0 {
+			rule.Status = http.StatusInternalServerError
+		}
+		if rule.Status < 500 || rule.Status > 599 {
+			return fmt.Errorf("status must be a 5xx status code")
+		}
+	case faultConnection:
+	default:
+		return fmt.Errorf("kind must be %s, %s or %s", faultLatency, faultError, faultConnection)
+	}
+	if rule.Kind != faultLatency && rule.Latency != "" {
+		return fmt.Errorf("latency only applies to %s faults", faultLatency)
+	}
+	if rule.Kind != faultError && rule.Status != 0 {
+		return fmt.Errorf("status only applies to %s faults", faultError)
+	}
+
+	rule.Method = strings.ToUpper(strings.TrimSpace(rule.Method))
+	rule.Path = "/" + strings.Trim(strings.TrimSpace(rule.Path), "/")
+	rule.segments = nil
+	if rule.Path != "/" {
+		rule.segments = strings.Split(strings.TrimPrefix(rule.Path, "/"), "/")
+	}
+	if rule.Rate == 0 {
+		rule.Rate = 1
+	}
+	if rule.Rate < 0 || rule.Rate > 1 {
+		return fmt.Errorf("rate must be between 0 and 1")
+	}
+	if rule.Remaining < 0 {
+		return fmt.Errorf("remaining must not be negative")
+	}
+	return nil
+}
+
+func (rule *FaultRule) matches(method, path string) bool {
+	if rule.Method != "" && rule.Method != method {
+		return false
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < len(rule.segments) {
+		return false
+	}
+	for i, want := range rule.segments {
+		if want != "*" && want != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *faultInjector) isEnabled() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.enabled
+}
+
+func (f *faultInjector) add(rule FaultRule) FaultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	rule.ID = strconv.FormatInt(f.nextID, 10)
+	f.rules = append(f.rules, &rule)
+	return rule
+}
+
+// remove deletes the rule with id, or every rule when id is empty. It reports whether
+// anything was removed.
+func (f *faultInjector) remove(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kept := f.rules[:0]
+	for _, rule := range f.rules {
+		if id != "" && rule.ID != id {
+			kept = append(kept, rule)
+		}
+	}
+	removed := len(kept) < len(f.rules)
+	f.rules = kept
+	return removed
+}
+
+func (f *faultInjector) list() FaultList {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := FaultList{Enabled: f.enabled, Faults: make([]FaultRule, 0, len(f.rules))}
+	for _, rule := range f.rules {
+		list.Faults = append(list.Faults, *rule)
+	}
+	return list
+}
+
+// pick returns a copy of the first rule matching the call that fires, counting the
+// injection and dropping the rule once it has none remaining.
+func (f *faultInjector) pick(method, path string) (FaultRule, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.enabled {
+		return FaultRule{}, false
+	}
+	for i, rule := range f.rules {
+		if !rule.matches(method, path) || (rule.Rate < 1 && f.roll() >= rule.Rate) {
+			continue
+		}
+		rule.Injected++
+		picked := *rule
+		if rule.Remaining > 0 {
+			rule.Remaining--
+			if rule.Remaining == 0 {
+				f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			}
+		}
+		return picked, true
+	}
+	return FaultRule{}, false
+}
+
+// faultTransport injects the configured faults into Kafka Connect calls. It sits below
+// the retries and the circuit breaker, so injected failures are retried, trip the
+// breaker and time out exactly like a misbehaving Connect would.
+type faultTransport struct {
+	base http.RoundTripper
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rule, ok := upstreamFaults.pick(req.Method, req.URL.Path)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	log.Printf("faults: injecting %s fault %s into %s %s", rule.Kind, rule.ID, req.Method, req.URL.Path)
+
+	switch rule.Kind {
+	case faultLatency:
+		timer := time.NewTimer(rule.latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		return t.base.RoundTrip(req)
+	case faultError:
+		body, _ := json.Marshal(map[string]interface{}{
+			"error_code": rule.Status,
+			"message":    fmt.Sprintf("injected fault %s", rule.ID),
+		})
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status)),
+			StatusCode:    rule.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	default:
+		return nil, errInjectedConnectionFailure
+	}
+}
+
+// authorizeFaults requires FAULT_INJECTION and the debug token.
+func authorizeFaults(w http.ResponseWriter, r *http.Request) bool {
+	if !upstreamFaults.isEnabled() {
+		writeJSONError(w, http.StatusForbidden, "faults_disabled", "set FAULT_INJECTION=true to inject upstream faults; never enable it in production")
+		return false
+	}
+	return authorizeDebug(w, r)
+}
+
+// debugFaultsHandler lists (GET), adds (POST) or clears (DELETE) fault rules. Changes
+// are audited.
+func debugFaultsHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizeFaults(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, upstreamFaults.list())
+	case http.MethodPost:
+		var rule FaultRule
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_body", err.Error())
+			return
+		}
+		if err := rule.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_fault", err.Error())
+			return
+		}
+		rule.Injected = 0
+		rule.CreatedAt = time.Now().UTC()
+		rule.CreatedBy = requestUser(r)
+		rule = upstreamFaults.add(rule)
+		recordAudit(r, auditActionAdmin, "", http.StatusCreated, map[string]interface{}{"fault": rule.ID, "kind": rule.Kind, "method": rule.Method, "path": rule.Path})
+		writeJSON(w, http.StatusCreated, rule)
+	case http.MethodDelete:
+		upstreamFaults.remove("")
+		recordAudit(r, auditActionAdmin, "", http.StatusOK, map[string]interface{}{"faults": "cleared"})
+		writeJSON(w, http.StatusOK, upstreamFaults.list())
+	}
+}
+
+// debugFaultHandler removes one fault rule.
+func debugFaultHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizeFaults(w, r) {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if !upstreamFaults.remove(id) {
+		writeJSONError(w, http.StatusNotFound, "fault_not_found", fmt.Sprintf("fault %q does not exist", id))
+		return
+	}
+	recordAudit(r, auditActionAdmin, "", http.StatusOK, map[string]interface{}{"fault": id, "removed": true})
+	writeJSON(w, http.StatusOK, upstreamFaults.list())
+}
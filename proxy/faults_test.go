@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestFaults(t *testing.T, enabled bool, roll func() float64) *faultInjector {
+	t.Helper()
+	original := upstreamFaults
+	upstreamFaults = newFaultInjector(enabled, roll)
+	t.Cleanup(func() { upstreamFaults = original })
+	return upstreamFaults
+}
+
+func TestFaultTransport(t *testing.T) {
+	rolls := []float64{0.9, 0.1}
+	faults := withTestFaults(t, true, func() float64 {
+		roll := rolls[0]
+		rolls = rolls[1:]
+		return roll
+	})
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: &faultTransport{base: http.DefaultTransport}}
+
+	get := func(method, path string) (*http.Response, error) {
+		req, _ := http.NewRequest(method, upstream.URL+path, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	for _, rule := range []FaultRule{
+		{Kind: faultError, Method: "put", Path: "/connectors/*/config/", Status: http.StatusServiceUnavailable, Remaining: 1},
+		{Kind: faultConnection, Path: "connectors/*/status", Rate: 0.5},
+		{Kind: faultLatency, Path: "/connector-plugins", Latency: "20ms"},
+	} {
+		if err := rule.validate(); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		faults.add(rule)
+	}
+
+	if resp, err := get(http.MethodPut, "/connectors/orders/config"); err != nil || resp.StatusCode != http.StatusServiceUnavailable || calls != 0 {
+		t.Fatalf("expected an injected 503, got %v %v", resp, err)
+	}
+	if resp, err := get(http.MethodPut, "/connectors/orders/config"); err != nil || resp.StatusCode != http.StatusOK || calls != 1 {
+		t.Fatalf("expected the rule to be used up, got %v %v", resp, err)
+	}
+	if _, err := get(http.MethodGet, "/connectors/orders/config"); err != nil || calls != 2 {
+		t.Fatalf("expected the method to be matched, got %v", err)
+	}
+
+	// The first status read rolls 0.9 and passes; the second rolls 0.1 and fails.
+	if _, err := get(http.MethodGet, "/connectors/orders/status"); err != nil || calls != 3 {
+		t.Fatalf("expected the call above the rate to pass, got %v", err)
+	}
+	if _, err := get(http.MethodGet, "/connectors/orders/status"); !errors.Is(err, errInjectedConnectionFailure) || calls != 3 {
+		t.Fatalf("expected an injected connection failure, got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := get(http.MethodGet, "/connector-plugins/FileStreamSink/config"); err != nil || time.Since(start) < 20*time.Millisecond || calls != 4 {
+		t.Fatalf("expected the call to be delayed and forwarded, got %v after %s", err, time.Since(start))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+"/connector-plugins", nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the delay to respect the request deadline, got %v", err)
+	}
+
+	list := faults.list()
+	if len(list.Faults) != 2 || list.Faults[0].Injected != 1 || list.Faults[1].Injected != 2 {
+		t.Fatalf("unexpected rules %+v", list.Faults)
+	}
+	faults.enabled = false
+	if _, err := get(http.MethodGet, "/connectors/orders/status"); err != nil {
+		t.Fatalf("expected no faults while disabled, got %v", err)
+	}
+}
+
+func TestDebugFaultsHandler(t *testing.T) {
+	withTestCapture(t, 10, 1024, "s3cret")
+	logger := withTestAuditLog(t, 10)
+	withTestFaults(t, false, func() float64 { return 0 })
+
+	do := func(method, path, body string, vars map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		req = mux.SetURLVars(req, vars)
+		rr := httptest.NewRecorder()
+		if vars["id"] != "" {
+			debugFaultHandler(rr, req)
+		} else {
+			debugFaultsHandler(rr, req)
+		}
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/api/debug/faults", `{"kind": "connection", "path": "/connectors"}`, nil); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "faults_disabled") {
+		t.Fatalf("expected 403 while fault injection is off, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	upstreamFaults.enabled = true
+	for _, body := range []string{
+		`{"kind": "explode"}`,
+		`{"kind": "error", "status": 404}`,
+		`{"kind": "latency"}`,
+		`{"kind": "latency", "latency": "1h"}`,
+		`{"kind": "connection", "status": 503}`,
+		`{"kind": "connection", "rate": 2}`,
+		`{"kind": "connection", "path": "/connectors", "bogus": true}`,
+	} {
+		if rr := do(http.MethodPost, "/api/debug/faults", body, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+
+	rr := do(http.MethodPost, "/api/debug/faults", `{"kind": "error", "path": "/connectors"}`, nil)
+	var rule FaultRule
+	if err := json.Unmarshal(rr.Body.Bytes(), &rule); err != nil || rr.Code != http.StatusCreated || rule.Status != http.StatusInternalServerError || rule.Rate != 1 {
+		t.Fatalf("expected the rule to be created with defaults, got %d: %s", rr.Code, rr.Body.String())
+	}
+	do(http.MethodPost, "/api/debug/faults", `{"kind": "latency", "latency": "2s"}`, nil)
+
+	if rr := do(http.MethodDelete, "/api/debug/faults/"+rule.ID, "", map[string]string{"id": rule.ID}); rr.Code != http.StatusOK || len(upstreamFaults.list().Faults) != 1 {
+		t.Fatalf("expected the rule to be removed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodDelete, "/api/debug/faults/"+rule.ID, "", map[string]string{"id": rule.ID}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a removed rule, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/api/debug/faults", "", nil); rr.Code != http.StatusOK || len(upstreamFaults.list().Faults) != 0 {
+		t.Fatalf("expected every rule to be removed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if entries := logger.Query(AuditFilter{Action: auditActionAdmin}); len(entries) != 4 {
+		t.Fatalf("expected the fault changes to be audited, got %d entries", len(entries))
+	}
+}
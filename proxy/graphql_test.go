@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestGraphQLLimits(t *testing.T, limits graphqlLimits) {
+	t.Helper()
+	previous := graphqlService
+	graphqlService = newGraphQLServer(limits)
+	t.Cleanup(func() { graphqlService = previous })
+}
+
+func serveGraphQL(method, rawQuery, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/default/graphql?"+rawQuery, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	graphqlHandler(rr, req)
+	return rr
+}
+
+func TestLoadGraphQLLimits(t *testing.T) {
+	defer func(depth, complexity, body string) {
+		graphqlMaxDepth, graphqlMaxComplexity, graphqlMaxBodyBytes = depth, complexity, body
+	}(graphqlMaxDepth, graphqlMaxComplexity, graphqlMaxBodyBytes)
+
+	graphqlMaxDepth, graphqlMaxComplexity, graphqlMaxBodyBytes = "8", " 200 ", "1024"
+	limits, err := loadGraphQLLimits()
+	if err != nil || limits != (graphqlLimits{MaxDepth: 8, MaxComplexity: 200, MaxBody: 1024}) {
+		t.Fatalf("unexpected limits %+v, %v", limits, err)
+	}
+	for _, bad := range []*string{&graphqlMaxDepth, &graphqlMaxComplexity, &graphqlMaxBodyBytes} {
+		previous := *bad
+		*bad = "0"
+		if _, err := loadGraphQLLimits(); err == nil {
+			t.Errorf("expected 0 to be rejected")
+		}
+		*bad = previous
+	}
+}
+
+func TestGraphQLIntrospection(t *testing.T) {
+	body, _ := json.Marshal(graphqlRequest{Query: `{
+		__schema { queryType { name } types { name } }
+		__type(name: "Connector") { kind fields { name type { kind ofType { name } } args { name defaultValue } } }
+	}`})
+	rr := serveGraphQL(http.MethodPost, "", string(body))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var result struct {
+		Data struct {
+			Schema struct {
+				QueryType struct{ Name string }
+				Types     []struct{ Name string }
+			} `json:"__schema"`
+			Type struct {
+				Kind   string
+				Fields []struct {
+					Name string
+					Type struct {
+						Kind   string
+						OfType *struct{ Name string }
+					}
+				}
+			} `json:"__type"`
+		}
+		Errors []interface{}
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors: %s", rr.Body.String())
+	}
+	if result.Data.Schema.QueryType.Name != "Query" {
+		t.Fatalf("unexpected query type %+v", result.Data.Schema.QueryType)
+	}
+	types := map[string]bool{}
+	for _, typ := range result.Data.Schema.Types {
+		types[typ.Name] = true
+	}
+	for _, name := range []string{"Connector", "Task", "Plugin", "MonitoringSummary", "__Type", "String"} {
+		if !types[name] {
+			t.Errorf("expected type %s in the schema", name)
+		}
+	}
+	fields := map[string]string{}
+	for _, field := range result.Data.Type.Fields {
+		fields[field.Name] = field.Type.Kind
+	}
+	if result.Data.Type.Kind != "OBJECT" || fields["name"] != "NON_NULL" || fields["metrics"] != "OBJECT" {
+		t.Fatalf("unexpected Connector type %+v", result.Data.Type)
+	}
+}
+
+func TestGraphQLRejectsDeepQueries(t *testing.T) {
+	withTestGraphQLLimits(t, graphqlLimits{MaxDepth: 5, MaxComplexity: 1000, MaxBody: 64 << 10})
+	deep := func(levels int) string {
+		return strings.Repeat("__schema { types { ofType { ofType { ", 1) +
+			strings.Repeat("ofType { ", levels) + "name" + strings.Repeat(" }", levels+4)
+	}
+
+	// __schema, types, three ofTypes and name are six levels; a fragment does not hide them.
+	for _, query := range []string{
+		"{ " + deep(1) + " }",
+		"{ ...deep } fragment deep on Query { " + deep(1) + " }",
+	} {
+		rr := serveGraphQL(http.MethodGet, url.Values{"query": {query}}.Encode(), "")
+		if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "nested 6 levels deep; the limit is 5") {
+			t.Fatalf("expected the depth limit to reject %s, got %d: %s", query, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := serveGraphQL(http.MethodGet, url.Values{"query": {"{ " + deep(0) + " }"}}.Encode(), "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected a query within the limit to run, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGraphQLRejectsComplexQueries(t *testing.T) {
+	withTestGraphQLLimits(t, graphqlLimits{MaxDepth: 15, MaxComplexity: 1000, MaxBody: 64 << 10})
+
+	// Each alias costs 1 + 10 * (name + metrics(10 + offsetLag)) = 121.
+	var query strings.Builder
+	query.WriteString("{")
+	for i := 0; i < 9; i++ {
+		fmt.Fprintf(&query, " c%d: connectors { name metrics { offsetLag } }", i)
+	}
+	query.WriteString(" }")
+	rr := serveGraphQL(http.MethodGet, url.Values{"query": {query.String()}}.Encode(), "")
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "operation has complexity 1089, which exceeds the limit of 1000") {
+		t.Fatalf("expected the complexity limit to reject the query, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGraphQLLimitsBodySize(t *testing.T) {
+	withTestGraphQLLimits(t, graphqlLimits{MaxDepth: 15, MaxComplexity: 1000, MaxBody: 256})
+	query := "{ " + strings.Repeat("__typename ", 30) + "}"
+	body, _ := json.Marshal(graphqlRequest{Query: query})
+
+	for _, rr := range []*httptest.ResponseRecorder{
+		serveGraphQL(http.MethodPost, "", string(body)),
+		serveGraphQL(http.MethodGet, url.Values{"query": {query}}.Encode(), ""),
+	} {
+		if rr.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rr.Body.String(), "limited to 256 bytes") {
+			t.Fatalf("expected 413, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	// A body without a Content-Length is cut off when it reaches the limit.
+	req := httptest.NewRequest(http.MethodPost, "/api/default/graphql", struct{ io.Reader }{strings.NewReader(string(body))})
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	rr := httptest.NewRecorder()
+	graphqlHandler(rr, mux.SetURLVars(req, map[string]string{"cluster": "default"}))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a streamed body, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serveGraphQL(http.MethodPost, "", `{"query": "{ __typename }"}`)
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"data":{"__typename":"Query"}}` {
+		t.Fatalf("expected a small query to run, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	var connectorRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/connectors":
+			atomic.AddInt32(&connectorRequests, 1)
+			w.Write([]byte(`{
+  "orders-sink": {
+    "info": {"config": {"connector.class": "JdbcSinkConnector", "connection.password": "hunter2", "tasks.max": "2"}, "type": "sink"},
+    "status": {"name": "orders-sink", "connector": {"state": "RUNNING", "worker_id": "w1:8083"}, "tasks": [{"id": 0, "state": "RUNNING", "worker_id": "w1:8083"}, {"id": 1, "state": "FAILED", "worker_id": "w2:8083", "trace": "boom"}], "type": "sink"}
+  },
+  "payments-cdc": {
+    "info": {"config": {"connector.class": "PostgresConnector"}, "type": "source"},
+    "status": {"name": "payments-cdc", "connector": {"state": "PAUSED", "worker_id": "w2:8083"}, "tasks": [], "type": "source"}
+  }
+}`))
+		case "/connector-plugins":
+			w.Write([]byte(`[{"class": "JdbcSinkConnector", "type": "sink", "version": "10.7"}, {"class": "PostgresConnector", "type": "source"}]`))
+		case "/":
+			w.Write([]byte(`{"version": "3.7.0", "commit": "abc", "kafka_cluster_id": "kc-1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	restore := withTestConnectURL(t, server)
+	defer restore()
+
+	body, _ := json.Marshal(graphqlRequest{
+		Query: `query Page($state: String) {
+			failing: connectors(state: $state) { name tasks { id state trace } }
+			connectors { name type state workerId password: configValue(key: "connection.password") config { key value sensitive } metrics { offsetLag } }
+			connector(name: "payments-cdc") { state connectorClass }
+			plugins(type: "source") { class version }
+			cluster { version kafkaClusterId }
+		}`,
+		Variables: map[string]interface{}{"state": "running"},
+	})
+	rr := serveGraphQL(http.MethodPost, "", string(body))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if n := atomic.LoadInt32(&connectorRequests); n != 1 {
+		t.Fatalf("expected connector fields to share one upstream request, got %d", n)
+	}
+	want := `{"data":{` +
+		`"failing":[{"name":"orders-sink","tasks":[{"id":0,"state":"running","trace":null},{"id":1,"state":"failed","trace":"boom"}]}],` +
+		`"connectors":[` +
+		`{"name":"orders-sink","type":"sink","state":"running","workerId":"w1:8083","password":"***REDACTED***","config":[{"key":"connection.password","value":"***REDACTED***","sensitive":true},{"key":"connector.class","value":"JdbcSinkConnector","sensitive":false},{"key":"tasks.max","value":"2","sensitive":false}],"metrics":null},` +
+		`{"name":"payments-cdc","type":"source","state":"paused","workerId":"w2:8083","password":null,"config":[{"key":"connector.class","value":"PostgresConnector","sensitive":false}],"metrics":null}],` +
+		`"connector":{"state":"paused","connectorClass":"PostgresConnector"},` +
+		`"plugins":[{"class":"PostgresConnector","version":null}],` +
+		`"cluster":{"version":"3.7.0","kafkaClusterId":"kc-1"}}}`
+	if got := strings.TrimSpace(rr.Body.String()); got != want {
+		t.Fatalf("unexpected response\n got: %s\nwant: %s", got, want)
+	}
+
+	rr = serveGraphQL(http.MethodGet, url.Values{"query": {"{ connectors { nope } }"}}.Encode(), "")
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), `Cannot query field \"nope\" on type \"Connector\"`) {
+		t.Fatalf("expected a validation error, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
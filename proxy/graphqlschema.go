@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/mux"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+// The schema is in graphql.graphqls and executed by the gqlgen code in graphql_gen.go.
+// Queries are limited in nesting depth, complexity and size, so one request cannot make
+// the proxy or Kafka Connect do unbounded work.
+var (
+	graphqlMaxDepth      = getEnv("GRAPHQL_MAX_DEPTH", "15")
+	graphqlMaxComplexity = getEnv("GRAPHQL_MAX_COMPLEXITY", "1000")
+	graphqlMaxBodyBytes  = getEnv("GRAPHQL_MAX_BODY_BYTES", "65536")
+
+	graphqlService = newGraphQLServer(defaultGraphQLLimits)
+)
+
+const (
+	// graphqlListCost multiplies the complexity of the fields selected on each item of
+	// the connectors and plugins lists, which hold many items.
+	graphqlListCost = 10
+	// graphqlMetricsCost is the complexity of Connector.metrics, a JMX read per connector.
+	graphqlMetricsCost = 10
+)
+
+// graphqlLimits bounds the queries graphqlHandler accepts.
+type graphqlLimits struct {
+	MaxDepth      int
+	MaxComplexity int
+	MaxBody       int64
+}
+
+var defaultGraphQLLimits = graphqlLimits{MaxDepth: 15, MaxComplexity: 1000, MaxBody: 64 << 10}
+
+// loadGraphQLLimits parses the GRAPHQL_MAX_* settings.
+func loadGraphQLLimits() (graphqlLimits, error) {
+	var limits graphqlLimits
+	var err error
+	if limits.MaxDepth, err = strconv.Atoi(strings.TrimSpace(graphqlMaxDepth)); err != nil || limits.MaxDepth <= 0 {
+		return limits, &configError{name: "GRAPHQL_MAX_DEPTH", value: graphqlMaxDepth}
+	}
+	if limits.MaxComplexity, err = strconv.Atoi(strings.TrimSpace(graphqlMaxComplexity)); err != nil || limits.MaxComplexity <= 0 {
+		return limits, &configError{name: "GRAPHQL_MAX_COMPLEXITY", value: graphqlMaxComplexity}
+	}
+	if limits.MaxBody, err = strconv.ParseInt(strings.TrimSpace(graphqlMaxBodyBytes), 10, 64); err != nil || limits.MaxBody <= 0 {
+		return limits, &configError{name: "GRAPHQL_MAX_BODY_BYTES", value: graphqlMaxBodyBytes}
+	}
+	return limits, nil
+}
+
+// graphqlLoader fetches the data of one GraphQL request. Every connector field is
+// resolved from a single expanded connectors request and every other upstream call is
+// made at most once, however many fields or aliases need it.
+type graphqlLoader struct {
+	cluster string
+	client  *http.Client
+	baseURL string
+
+	connectorsOnce sync.Once
+	connectors     []*graphqlConnector
+	connectorsErr  error
+
+	pluginsOnce sync.Once
+	plugins     []connectPluginInfo
+	pluginsErr  error
+
+	clusterOnce sync.Once
+	clusterInfo map[string]interface{}
+	clusterErr  error
+}
+
+func newGraphQLLoader(cluster string) *graphqlLoader {
+	return &graphqlLoader{cluster: cluster, client: connectClientFor(cluster, routeRead), baseURL: connectURLFor(cluster)}
+}
+
+// graphqlConnector is a connector with its status and config, the source of the
+// Connector type's fields.
+type graphqlConnector struct {
+	name   string
+	config map[string]string
+	expandedConnector
+}
+
+func (l *graphqlLoader) loadConnectors(ctx context.Context) ([]*graphqlConnector, error) {
+	l.connectorsOnce.Do(func() {
+		expanded, err := fetchExpandedConnectorStatuses(ctx, l.client, l.baseURL)
+		if err != nil {
+			l.connectorsErr = err
+			return
+		}
+		for name, connector := range expanded {
+			config := make(map[string]string, len(connector.Info.Config))
+			for key, value := range connector.Info.Config {
+				config[key] = value
+			}
+			secretRefs.restoreStrings(l.cluster, name, config)
+			l.connectors = append(l.connectors, &graphqlConnector{name: name, config: config, expandedConnector: connector})
+		}
+		sort.Slice(l.connectors, func(i, j int) bool { return l.connectors[i].name < l.connectors[j].name })
+	})
+	return l.connectors, l.connectorsErr
+}
+
+func (l *graphqlLoader) loadPlugins(ctx context.Context) ([]connectPluginInfo, error) {
+	l.pluginsOnce.Do(func() {
+		l.plugins, l.pluginsErr = fetchConnectorPlugins(ctx, l.client, l.baseURL)
+	})
+	return l.plugins, l.pluginsErr
+}
+
+func (l *graphqlLoader) loadClusterInfo(ctx context.Context) (map[string]interface{}, error) {
+	l.clusterOnce.Do(func() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(l.baseURL, "/"), nil)
+		if err != nil {
+			l.clusterErr = err
+			return
+		}
+		resp, err := l.client.Do(req)
+		if err != nil {
+			l.clusterErr = &connectUnavailableError{Err: err}
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			l.clusterErr = fmt.Errorf("unexpected status fetching cluster info: %d", resp.StatusCode)
+			return
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&l.clusterInfo); err != nil {
+			l.clusterErr = fmt.Errorf("decode cluster info: %w", err)
+		}
+	})
+	return l.clusterInfo, l.clusterErr
+}
+
+type graphqlLoaderKey struct{}
+
+// graphqlLoaderFrom returns the loader graphqlHandler put in the request context.
+func graphqlLoaderFrom(ctx context.Context) *graphqlLoader {
+	return ctx.Value(graphqlLoaderKey{}).(*graphqlLoader)
+}
+
+// redactedConfig lists the connector's config as ConfigEntry values, redacted the same
+// way as the REST responses.
+func (c *graphqlConnector) redactedConfig() []*graphqlConfigEntry {
+	rules := currentRedactionRules()
+	keys := make([]string, 0, len(c.config))
+	for key := range c.config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]*graphqlConfigEntry, 0, len(keys))
+	for _, key := range keys {
+		value, sensitive := c.config[key], false
+		if !isSecretReference(value) && rules.isSensitive(key) {
+			value, sensitive = rules.placeholder, true
+		}
+		entries = append(entries, &graphqlConfigEntry{Key: key, Value: &value, Sensitive: sensitive})
+	}
+	return entries
+}
+
+func (c *graphqlConnector) connectorType() string {
+	if c.Status.Type != "" {
+		return c.Status.Type
+	}
+	return c.Info.Type
+}
+
+// graphqlNullable maps the empty string to null.
+func graphqlNullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// graphqlStateCounts lists counts by state as StateCount values, sorted by state so
+// responses are stable.
+func graphqlStateCounts(counts map[string]int) []*graphqlStateCount {
+	states := make([]string, 0, len(counts))
+	for state := range counts {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	list := make([]*graphqlStateCount, 0, len(states))
+	for _, state := range states {
+		list = append(list, &graphqlStateCount{State: state, Count: counts[state]})
+	}
+	return list
+}
+
+// graphqlResolver is the ResolverRoot of the generated schema. The data of a request
+// comes from the graphqlLoader in its context.
+type graphqlResolver struct{}
+
+func (graphqlResolver) Query() QueryResolver         { return graphqlQueryResolver{} }
+func (graphqlResolver) Connector() ConnectorResolver { return graphqlConnectorResolver{} }
+
+type graphqlQueryResolver struct{}
+
+func (graphqlQueryResolver) Connectors(ctx context.Context, state, typeArg *string) ([]*graphqlConnector, error) {
+	connectors, err := graphqlLoaderFrom(ctx).loadConnectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*graphqlConnector, 0, len(connectors))
+	for _, c := range connectors {
+		if (state == nil || normalizeState(c.Status.Connector.State) == normalizeState(*state)) &&
+			(typeArg == nil || strings.EqualFold(c.connectorType(), *typeArg)) {
+			matched = append(matched, c)
+		}
+	}
+	return matched, nil
+}
+
+func (graphqlQueryResolver) Connector(ctx context.Context, name string) (*graphqlConnector, error) {
+	connectors, err := graphqlLoaderFrom(ctx).loadConnectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range connectors {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (graphqlQueryResolver) Plugins(ctx context.Context, typeArg *string) ([]*graphqlPlugin, error) {
+	plugins, err := graphqlLoaderFrom(ctx).loadPlugins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*graphqlPlugin, 0, len(plugins))
+	for _, plugin := range plugins {
+		if typeArg == nil || strings.EqualFold(plugin.Type, *typeArg) {
+			matched = append(matched, &graphqlPlugin{Class: plugin.Class, Type: plugin.Type, Version: graphqlNullable(plugin.Version)})
+		}
+	}
+	return matched, nil
+}
+
+func (graphqlQueryResolver) Summary(ctx context.Context) (*graphqlSummary, error) {
+	summary, _, _, err := monitoringSummaryCache.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if summary.ClusterID == "" {
+		summary.ClusterID = graphqlLoaderFrom(ctx).cluster
+	}
+	if summary.Uptime == "" && summary.UptimeSeconds > 0 {
+		summary.Uptime = formatUptime(time.Duration(summary.UptimeSeconds) * time.Second)
+	}
+	connectors := make([]*graphqlConnectorOverview, 0, len(summary.Connectors))
+	for _, c := range summary.Connectors {
+		connectors = append(connectors, &graphqlConnectorOverview{Name: c.Name, State: c.State, Type: graphqlNullable(c.Type)})
+	}
+	return &graphqlSummary{
+		ClusterID:       graphqlNullable(summary.ClusterID),
+		TotalConnectors: summary.TotalConnectors,
+		ConnectorStates: graphqlStateCounts(summary.ConnectorStates),
+		TaskStates:      graphqlStateCounts(summary.TaskStates),
+		UptimeSeconds:   summary.UptimeSeconds,
+		Uptime:          graphqlNullable(summary.Uptime),
+		Connectors:      connectors,
+	}, nil
+}
+
+func (graphqlQueryResolver) Cluster(ctx context.Context) (*graphqlCluster, error) {
+	info, err := graphqlLoaderFrom(ctx).loadClusterInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	field := func(key string) *string {
+		if value, ok := info[key].(string); ok {
+			return &value
+		}
+		return nil
+	}
+	return &graphqlCluster{Version: field("version"), Commit: field("commit"), KafkaClusterID: field("kafka_cluster_id")}, nil
+}
+
+// graphqlConnectorResolver resolves the fields of Connector from the loaded connector.
+type graphqlConnectorResolver struct{}
+
+func (graphqlConnectorResolver) Name(_ context.Context, c *graphqlConnector) (string, error) {
+	return c.name, nil
+}
+
+func (graphqlConnectorResolver) Type(_ context.Context, c *graphqlConnector) (*string, error) {
+	return graphqlNullable(c.connectorType()), nil
+}
+
+func (graphqlConnectorResolver) State(_ context.Context, c *graphqlConnector) (string, error) {
+	return normalizeState(c.Status.Connector.State), nil
+}
+
+func (graphqlConnectorResolver) WorkerID(_ context.Context, c *graphqlConnector) (*string, error) {
+	return graphqlNullable(c.Status.Connector.WorkerID), nil
+}
+
+func (graphqlConnectorResolver) Trace(_ context.Context, c *graphqlConnector) (*string, error) {
+	return graphqlNullable(c.Status.Connector.Trace), nil
+}
+
+func (graphqlConnectorResolver) ConnectorClass(_ context.Context, c *graphqlConnector) (*string, error) {
+	return graphqlNullable(c.config["connector.class"]), nil
+}
+
+func (graphqlConnectorResolver) Config(_ context.Context, c *graphqlConnector) ([]*graphqlConfigEntry, error) {
+	return c.redactedConfig(), nil
+}
+
+func (graphqlConnectorResolver) ConfigValue(_ context.Context, c *graphqlConnector, key string) (*string, error) {
+	for _, entry := range c.redactedConfig() {
+		if entry.Key == key {
+			return entry.Value, nil
+		}
+	}
+	return nil, nil
+}
+
+func (graphqlConnectorResolver) Tasks(_ context.Context, c *graphqlConnector) ([]*graphqlTask, error) {
+	tasks := make([]*graphqlTask, 0, len(c.Status.Tasks))
+	for _, task := range c.Status.Tasks {
+		tasks = append(tasks, &graphqlTask{
+			ID:       task.ID,
+			State:    normalizeState(task.State),
+			WorkerID: graphqlNullable(task.WorkerID),
+			Trace:    graphqlNullable(task.Trace),
+		})
+	}
+	return tasks, nil
+}
+
+func (graphqlConnectorResolver) Metrics(ctx context.Context, c *graphqlConnector) (*graphqlMetrics, error) {
+	metrics, err := jolokiaMetrics(ctx, c.name)
+	if errors.Is(err, errMetricsUnavailable) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &graphqlMetrics{
+		Timestamp:         metrics.Timestamp.UTC().Format(time.RFC3339),
+		Tasks:             metrics.Tasks,
+		RecordsInPerSec:   metrics.RecordsInPerSec,
+		RecordsOutPerSec:  metrics.RecordsOutPerSec,
+		TotalRecordErrors: metrics.TotalRecordErrors,
+		ErrorsPerSec:      metrics.ErrorsPerSec,
+		OffsetLag:         metrics.OffsetLag,
+	}, nil
+}
+
+// The codes of the errors that reject a query over a limit. Both are registered as
+// protocol errors, so the query is answered with 422 like any other invalid query.
+const (
+	graphqlDepthLimitExceeded      = "DEPTH_LIMIT_EXCEEDED"
+	graphqlComplexityLimitExceeded = "COMPLEXITY_LIMIT_EXCEEDED"
+)
+
+// graphqlDepthLimit rejects operations whose fields are nested deeper than its value,
+// counting through fragments.
+type graphqlDepthLimit int
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = graphqlDepthLimit(0)
+
+func (graphqlDepthLimit) ExtensionName() string                   { return "DepthLimit" }
+func (graphqlDepthLimit) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (limit graphqlDepthLimit) MutateOperationContext(_ context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if depth := graphqlDepth(rc.Operation.SelectionSet, map[string]int{}); depth > int(limit) {
+		err := gqlerror.Errorf("The query is nested %d levels deep; the limit is %d.", depth, limit)
+		errcode.Set(err, graphqlDepthLimitExceeded)
+		return err
+	}
+	return nil
+}
+
+// graphqlDepth returns how deeply fields are nested in selections. Fragment depths are
+// memoized, as one fragment can be spread many times.
+func graphqlDepth(selections ast.SelectionSet, fragments map[string]int) int {
+	depth := 0
+	for _, selection := range selections {
+		d := 0
+		switch s := selection.(type) {
+		case *ast.Field:
+			d = 1 + graphqlDepth(s.SelectionSet, fragments)
+		case *ast.InlineFragment:
+			d = graphqlDepth(s.SelectionSet, fragments)
+		case *ast.FragmentSpread:
+			if s.Definition == nil {
+				break
+			}
+			var ok bool
+			if d, ok = fragments[s.Name]; !ok {
+				d = graphqlDepth(s.Definition.SelectionSet, fragments)
+				fragments[s.Name] = d
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// graphqlComplexity weighs the fields that fan out: each item of the connectors and
+// plugins lists, and the JMX read behind Connector.metrics. Other fields cost 1.
+func graphqlComplexity() ComplexityRoot {
+	var complexity ComplexityRoot
+	complexity.Query.Connectors = func(child int, _, _ *string) int { return 1 + graphqlListCost*child }
+	complexity.Query.Plugins = func(child int, _ *string) int { return 1 + graphqlListCost*child }
+	complexity.Connector.Metrics = func(child int) int { return graphqlMetricsCost + child }
+	return complexity
+}
+
+// graphqlServer executes queries within its limits.
+type graphqlServer struct {
+	limits  graphqlLimits
+	handler *handler.Server
+}
+
+func newGraphQLServer(limits graphqlLimits) *graphqlServer {
+	errcode.RegisterErrorType(graphqlDepthLimitExceeded, errcode.KindProtocol)
+	errcode.RegisterErrorType(graphqlComplexityLimitExceeded, errcode.KindProtocol)
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: graphqlResolver{}, Complexity: graphqlComplexity()}))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(graphqlDepthLimit(limits.MaxDepth))
+	srv.Use(extension.FixedComplexityLimit(limits.MaxComplexity))
+	return &graphqlServer{limits: limits, handler: srv}
+}
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// graphqlHandler serves GraphQL queries over GET (query, variables and operationName
+// parameters) and POST (a JSON body with the same fields). Field errors are reported in
+// the response's errors list next to the data that could be resolved; queries that fail
+// to parse, validate or stay within the depth and complexity limits are answered with
+// 422, and requests larger than GRAPHQL_MAX_BODY_BYTES with 413.
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	server := graphqlService
+	tooLarge := func() {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+			fmt.Sprintf("GraphQL requests are limited to %d bytes", server.limits.MaxBody))
+	}
+	if int64(len(r.URL.RawQuery)) > server.limits.MaxBody || r.ContentLength > server.limits.MaxBody {
+		tooLarge()
+		return
+	}
+	if r.Body != nil {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, server.limits.MaxBody))
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			tooLarge()
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "failed to read the request body: "+err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlLoaderKey{}, newGraphQLLoader(mux.Vars(r)["cluster"]))
+	server.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// The GraphQL object types other than Connector, as bound in gqlgen.yml. Nullable
+// fields are pointers.
+type (
+	graphqlConfigEntry struct {
+		Key       string
+		Value     *string
+		Sensitive bool
+	}
+	graphqlTask struct {
+		ID       int
+		State    string
+		WorkerID *string
+		Trace    *string
+	}
+	graphqlMetrics struct {
+		Timestamp         string
+		Tasks             int
+		RecordsInPerSec   float64
+		RecordsOutPerSec  float64
+		TotalRecordErrors float64
+		ErrorsPerSec      float64
+		OffsetLag         float64
+	}
+	graphqlPlugin struct {
+		Class   string
+		Type    string
+		Version *string
+	}
+	graphqlStateCount struct {
+		State string
+		Count int
+	}
+	graphqlConnectorOverview struct {
+		Name  string
+		State string
+		Type  *string
+	}
+	graphqlSummary struct {
+		ClusterID       *string
+		TotalConnectors int
+		ConnectorStates []*graphqlStateCount
+		TaskStates      []*graphqlStateCount
+		UptimeSeconds   int64
+		Uptime          *string
+		Connectors      []*graphqlConnectorOverview
+	}
+	graphqlCluster struct {
+		Version        *string
+		Commit         *string
+		KafkaClusterID *string
+	}
+)
@@ -254,6 +254,10 @@ func TestSummaryHandlerAggregatesData(t *testing.T) {
 		io.WriteString(w, `{"cluster_id":"cluster-1"}`)
 	})
 	muxRouter.HandleFunc("/connectors", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("expand") {
+			io.WriteString(w, `{"alpha":{"info":{"config":{"connector.class":"demo"},"type":"source"},"status":{"connector":{"state":"RUNNING","worker_id":"worker-1:8083"},"tasks":[{"id":0,"state":"RUNNING","worker_id":"worker-1:8083"}],"type":"source"}}}`)
+			return
+		}
 		io.WriteString(w, `["alpha"]`)
 	})
 	muxRouter.HandleFunc("/connectors/alpha", func(w http.ResponseWriter, r *http.Request) {
@@ -265,9 +269,6 @@ func TestSummaryHandlerAggregatesData(t *testing.T) {
 	muxRouter.HandleFunc("/connector-plugins", func(w http.ResponseWriter, r *http.Request) {
 		io.WriteString(w, `[{"class":"demo","type":"source","version":"1"}]`)
 	})
-	muxRouter.HandleFunc("/workers", func(w http.ResponseWriter, r *http.Request) {
-		io.WriteString(w, `[{"worker_id":"worker-1"}]`)
-	})
 
 	server := httptest.NewServer(muxRouter)
 	defer server.Close()
@@ -295,6 +296,64 @@ func TestSummaryHandlerAggregatesData(t *testing.T) {
 	if connectorStats["total"].(float64) != 1 {
 		t.Fatalf("expected total connectors = 1, got %v", connectorStats["total"])
 	}
+	workerInfo := payload["workerInfo"].(map[string]interface{})
+	if workerInfo["workers"].(float64) != 1 || workerInfo["worker_ids"] != "worker-1:8083" {
+		t.Fatalf("expected worker info derived from task placement, got %v", workerInfo)
+	}
+}
+
+func TestSummaryHandlerReportsFailedSections(t *testing.T) {
+	muxRouter := http.NewServeMux()
+	muxRouter.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"version":"3.7.0"}`)
+	})
+	muxRouter.HandleFunc("/connectors", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("expand") {
+			io.WriteString(w, `{"alpha":{"status":{"connector":{"state":"RUNNING","worker_id":"worker-1:8083"},"tasks":[]}}}`)
+			return
+		}
+		io.WriteString(w, `["alpha","beta","gamma","deleted"]`)
+	})
+	muxRouter.HandleFunc("/connectors/alpha/status", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"connector":{"state":"RUNNING"},"tasks":[]}`)
+	})
+	muxRouter.HandleFunc("/connectors/beta/status", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"connector":{"state":"FAILED"},"tasks":[]}`)
+	})
+	muxRouter.HandleFunc("/connectors/gamma/status", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	muxRouter.HandleFunc("/connectors/deleted/status", http.NotFound)
+	muxRouter.HandleFunc("/connector-plugins", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+
+	server := httptest.NewServer(muxRouter)
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	rr := httptest.NewRecorder()
+	summaryHandler(rr, httptest.NewRequest(http.MethodGet, "/api/default/summary", nil))
+	var summary SettingsSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if summary.ClusterInfo["version"] != "3.7.0" || summary.WorkerInfo["workers"] != float64(1) {
+		t.Fatalf("expected the sections that worked to be filled in, got %+v", summary)
+	}
+	if stats := summary.ConnectorStats; stats.Total != 4 || stats.Running != 1 || stats.Failed != 1 {
+		t.Fatalf("expected partial connector stats, got %+v", stats)
+	}
+	if len(summary.Errors) != 2 || !strings.Contains(summary.Errors["plugins"], "500") || !strings.HasPrefix(summary.Errors["connectorStats"], "1 of 4 connector statuses could not be fetched") {
+		t.Fatalf("expected the plugins and connector stats to be reported, got %v", summary.Errors)
+	}
+
+	connectURL = "http://127.0.0.1:1"
+	rr = httptest.NewRecorder()
+	summaryHandler(rr, httptest.NewRequest(http.MethodGet, "/api/default/summary", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when Kafka Connect is unreachable, got %d %s", rr.Code, rr.Body.String())
+	}
 }
 
 func TestClusterActionHandler(t *testing.T) {
@@ -348,6 +407,7 @@ func TestClusterActionHandler(t *testing.T) {
 }
 
 func TestProxyHandlerHandlesMutations(t *testing.T) {
+	withTestRestorePoints(t, 0) // snapshots would add upstream reads
 	responses := map[string]testutils.Response{
 		"POST /connectors": {
 			Status:  http.StatusCreated,
@@ -519,8 +579,7 @@ func TestGetMonitoringSummaryCaches(t *testing.T) {
 	monitoringHTTPClient = server.Client()
 	t.Cleanup(func() { monitoringHTTPClient = originalClient })
 
-	summaryCacheTTL = time.Second
-	t.Cleanup(func() { summaryCacheTTL = 10 * time.Second })
+	withTestSummaryCache(t, time.Second)
 
 	if _, err := getMonitoringSummary(context.Background()); err != nil {
 		t.Fatalf("first getMonitoringSummary failed: %v", err)
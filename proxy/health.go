@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	dependencyUp       = "up"
+	dependencyDown     = "down"
+	dependencyDisabled = "disabled"
+)
+
+// DependencyStatus is the result of one readiness check.
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// dependencyCheck probes one dependency. A nil check reports the dependency as disabled.
+type dependencyCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// readinessChecks lists the dependencies probed by /health/ready. Kafka Connect and the
+// audit store are critical; Jolokia only degrades metrics and is reported but not fatal.
+func readinessChecks() []dependencyCheck {
+	checks := []dependencyCheck{
+		{name: "kafka_connect", critical: true, check: func(ctx context.Context) error {
+			reason, err := checkKafkaConnect(ctx)
+			if reason == "" {
+				return nil
+			}
+			if err != nil {
+				return errors.New(reason + ": " + err.Error())
+			}
+			return errors.New(reason)
+		}},
+		{name: "audit_store", critical: true, check: func(ctx context.Context) error {
+			return auditLog.Ping(ctx)
+		}},
+		{name: "jolokia"},
+	}
+
+	if collector := connectorMetricsCollector; collector.enabled() {
+		checks[2].check = collector.ping
+	}
+	return checks
+}
+
+// runDependencyChecks runs all checks concurrently and reports whether every critical
+// dependency is up.
+func runDependencyChecks(ctx context.Context, checks []dependencyCheck) (map[string]DependencyStatus, bool) {
+	results := make(map[string]DependencyStatus, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, dep := range checks {
+		if dep.check == nil {
+			results[dep.name] = DependencyStatus{Status: dependencyDisabled, Critical: dep.critical}
+			continue
+		}
+
+		wg.Add(1)
+		go func(dep dependencyCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := dep.check(ctx)
+			status := DependencyStatus{
+				Status:    dependencyUp,
+				Critical:  dep.critical,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				status.Status = dependencyDown
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[dep.name] = status
+			mu.Unlock()
+		}(dep)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, status := range results {
+		if status.Critical && status.Status == dependencyDown {
+			ready = false
+		}
+	}
+	return results, ready
+}
+
+// liveHandler reports that the process is serving requests. It never checks dependencies
+// so orchestrators do not restart the proxy because Kafka Connect is down.
+func liveHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// readyHandler reports per-dependency status and latency, answering 503 when any
+// critical dependency is down.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	results, ready := runDependencyChecks(ctx, readinessChecks())
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+		log.Printf("health: not ready: %+v", results)
+	}
+	writeJSON(w, code, map[string]interface{}{
+		"status":       status,
+		"dependencies": results,
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type readinessPayload struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+func TestLiveHandlerAlwaysOK(t *testing.T) {
+	original := connectURL
+	connectURL = "http://127.0.0.1:0"
+	t.Cleanup(func() { connectURL = original })
+
+	rr := httptest.NewRecorder()
+	liveHandler(rr, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestReadyHandlerReportsDependencies(t *testing.T) {
+	connect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"3.6.0"}`))
+	}))
+	defer connect.Close()
+	restore := withTestConnectURL(t, connect)
+	defer restore()
+
+	jolokia := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer jolokia.Close()
+	originalCollector := connectorMetricsCollector
+	connectorMetricsCollector = newMetricsCollector([]string{jolokia.URL}, time.Hour, time.Now)
+	t.Cleanup(func() { connectorMetricsCollector = originalCollector })
+
+	rr := httptest.NewRecorder()
+	readyHandler(rr, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected non-critical Jolokia failure to keep the proxy ready, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var payload readinessPayload
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if payload.Dependencies["kafka_connect"].Status != dependencyUp || payload.Dependencies["audit_store"].Status != dependencyUp {
+		t.Fatalf("expected critical dependencies up, got %+v", payload.Dependencies)
+	}
+	if payload.Dependencies["jolokia"].Status != dependencyDown || payload.Dependencies["jolokia"].Error == "" {
+		t.Fatalf("expected jolokia down with an error, got %+v", payload.Dependencies["jolokia"])
+	}
+}
+
+func TestReadyHandlerUnavailableWhenConnectDown(t *testing.T) {
+	original := connectURL
+	connectURL = "http://127.0.0.1:0"
+	t.Cleanup(func() { connectURL = original })
+
+	rr := httptest.NewRecorder()
+	readyHandler(rr, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+
+	var payload readinessPayload
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if payload.Status != "not_ready" || payload.Dependencies["kafka_connect"].Status != dependencyDown {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	if payload.Dependencies["jolokia"].Status != dependencyDisabled {
+		t.Fatalf("expected unconfigured jolokia to be disabled, got %+v", payload.Dependencies["jolokia"])
+	}
+}
+
+func TestRunDependencyChecksIgnoresNonCriticalFailures(t *testing.T) {
+	failing := func(context.Context) error { return errors.New("boom") }
+
+	_, ready := runDependencyChecks(context.Background(), []dependencyCheck{{name: "optional", check: failing}})
+	if !ready {
+		t.Fatalf("expected non-critical failure not to affect readiness")
+	}
+	_, ready = runDependencyChecks(context.Background(), []dependencyCheck{{name: "required", critical: true, check: failing}})
+	if ready {
+		t.Fatalf("expected critical failure to fail readiness")
+	}
+}
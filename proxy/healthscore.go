@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// healthScoreWindow is the look-back period for availability and restarts.
+const healthScoreWindow = 24 * time.Hour
+
+// Health factors, in the order they are reported.
+const (
+	healthFactorState        = "state"
+	healthFactorFailedTasks  = "failedTasks"
+	healthFactorAvailability = "availability"
+	healthFactorRestarts     = "restarts"
+	healthFactorErrorRate    = "errorRate"
+	healthFactorLag          = "lag"
+	healthFactorThroughput   = "throughput"
+)
+
+// HealthFactor is one input of a health score and the points it cost.
+type HealthFactor struct {
+	Factor  string  `json:"factor"`
+	Value   float64 `json:"value"`
+	Penalty int     `json:"penalty"`
+	Detail  string  `json:"detail"`
+}
+
+// ConnectorHealth is returned by GET /api/{cluster}/connectors/{name}/health. The score
+// starts at 100 and every factor deducts points; Unavailable lists the factors that
+// could not be evaluated, such as throughput without Jolokia metrics.
+type ConnectorHealth struct {
+	Connector   string         `json:"connector"`
+	Grade       string         `json:"grade"`
+	Score       int            `json:"score"`
+	State       string         `json:"state"`
+	Window      string         `json:"window"`
+	EvaluatedAt time.Time      `json:"evaluatedAt"`
+	Factors     []HealthFactor `json:"factors"`
+	Unavailable []string       `json:"unavailable"`
+}
+
+// HealthGrade is the grade of a connector in the monitoring summary.
+type HealthGrade struct {
+	Grade string `json:"grade"`
+	Score int    `json:"score"`
+}
+
+// healthInputs is what a connector is scored on. Availability and Metrics are nil when
+// there is no state history or Jolokia sample for the connector.
+type healthInputs struct {
+	State        string
+	Tasks        int
+	FailedTasks  int
+	Availability *ConnectorAvailability
+	Restarts     int
+	HasHistory   bool
+	Metrics      *ConnectorMetrics
+	Thresholds   alertThresholds
+}
+
+// gatherHealthInputs completes the current state of a connector with its state history
+// and latest metrics sample.
+func gatherHealthInputs(cluster, name, state string, tasks, failedTasks int, now time.Time) healthInputs {
+	inputs := healthInputs{State: state, Tasks: tasks, FailedTasks: failedTasks, Thresholds: alertThresholdsFor(name)}
+	since := now.Add(-healthScoreWindow)
+	if availability, ok := connectorStateHistory.connectorAvailabilityOf(cluster, name, since, now); ok {
+		inputs.Availability = &availability
+	}
+	inputs.Restarts, inputs.HasHistory = connectorStateHistory.restarts(cluster, name, since)
+	if connectorMetricsCollector.enabled() {
+		if sample, ok := connectorMetricsCollector.latest(name); ok {
+			inputs.Metrics = &sample
+		}
+	}
+	return inputs
+}
+
+// scoreConnectorHealth deducts points from 100 for each factor. A failed connector
+// cannot score above an F on its state alone; paused and stopped connectors are not
+// penalised for their state or for not moving records.
+func scoreConnectorHealth(in healthInputs) (int, []HealthFactor, []string) {
+	factors := []HealthFactor{}
+	unavailable := []string{}
+
+	state := HealthFactor{Factor: healthFactorState, Detail: fmt.Sprintf("connector is %s", in.State)}
+	switch in.State {
+	case "failed":
+		state.Penalty = 60
+	case "unassigned", "restarting":
+		state.Penalty = 25
+	}
+	factors = append(factors, state)
+
+	if in.Tasks > 0 {
+		tasks := HealthFactor{Factor: healthFactorFailedTasks, Value: float64(in.FailedTasks), Detail: fmt.Sprintf("%d of %d task(s) failed", in.FailedTasks, in.Tasks)}
+		if in.FailedTasks > 0 {
+			tasks.Penalty = 20 + int(math.Round(30*float64(in.FailedTasks)/float64(in.Tasks)))
+		}
+		factors = append(factors, tasks)
+	}
+
+	if in.Availability != nil && in.Availability.AvailabilityPercent != nil {
+		percent := *in.Availability.AvailabilityPercent
+		factors = append(factors, HealthFactor{
+			Factor:  healthFactorAvailability,
+			Value:   roundTo(percent, 2),
+			Penalty: int(math.Min(30, math.Round((100-percent)*2))),
+			Detail:  fmt.Sprintf("%.2f%% available over the last %s, %d incident(s)", percent, healthScoreWindow, in.Availability.Incidents),
+		})
+	} else {
+		unavailable = append(unavailable, healthFactorAvailability)
+	}
+
+	if in.HasHistory {
+		factors = append(factors, HealthFactor{
+			Factor:  healthFactorRestarts,
+			Value:   float64(in.Restarts),
+			Penalty: int(math.Min(20, float64(5*in.Restarts))),
+			Detail:  fmt.Sprintf("%d restart(s) in the last %s", in.Restarts, healthScoreWindow),
+		})
+	} else {
+		unavailable = append(unavailable, healthFactorRestarts)
+	}
+
+	if in.Metrics == nil || in.State != "running" {
+		unavailable = append(unavailable, healthFactorErrorRate, healthFactorLag, healthFactorThroughput)
+	} else {
+		factors = append(factors, metricsHealthFactors(*in.Metrics, in.Thresholds)...)
+	}
+
+	score := 100
+	for _, factor := range factors {
+		score -= factor.Penalty
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score, factors, unavailable
+}
+
+// metricsHealthFactors scores a running connector's error rate, and its lag and
+// throughput against its alert thresholds.
+func metricsHealthFactors(sample ConnectorMetrics, thresholds alertThresholds) []HealthFactor {
+	records := sample.RecordsInPerSec + sample.RecordsOutPerSec
+	errorRate := HealthFactor{Factor: healthFactorErrorRate, Detail: "no record errors"}
+	if sample.ErrorsPerSec > 0 {
+		ratio := 1.0
+		if records > 0 {
+			ratio = math.Min(1, sample.ErrorsPerSec/records)
+		}
+		errorRate.Value = roundTo(ratio, 4)
+		errorRate.Detail = fmt.Sprintf("%.2f errors/sec, %.2f%% of records", sample.ErrorsPerSec, ratio*100)
+		switch {
+		case ratio >= 0.05:
+			errorRate.Penalty = 20
+		case ratio >= 0.01:
+			errorRate.Penalty = 10
+		default:
+			errorRate.Penalty = 5
+		}
+	}
+
+	lag := HealthFactor{Factor: healthFactorLag, Value: sample.OffsetLag, Detail: fmt.Sprintf("%.0f records behind", sample.OffsetLag)}
+	if thresholds.MaxLag > 0 && sample.OffsetLag > thresholds.MaxLag {
+		lag.Penalty = 10
+		lag.Detail += fmt.Sprintf(", above the threshold of %.0f", thresholds.MaxLag)
+	}
+
+	throughput := HealthFactor{Factor: healthFactorThroughput, Value: sample.RecordsOutPerSec, Detail: fmt.Sprintf("%.2f records/sec written", sample.RecordsOutPerSec)}
+	if thresholds.MinThroughput > 0 && sample.RecordsOutPerSec < thresholds.MinThroughput {
+		throughput.Penalty = 10
+		throughput.Detail += fmt.Sprintf(", below the floor of %.2f", thresholds.MinThroughput)
+	}
+
+	return []HealthFactor{errorRate, lag, throughput}
+}
+
+// healthGrade maps a score to a letter: A from 90, B from 80, C from 70, D from 60.
+func healthGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	}
+	return "F"
+}
+
+// applyHealthGrades grades every connector of a summary of cluster and counts the
+// connectors per grade.
+func applyHealthGrades(summary *MonitoringSummary, cluster string, now time.Time) {
+	summary.Grades = map[string]int{}
+	for i := range summary.Connectors {
+		overview := &summary.Connectors[i]
+		tasks := 0
+		for _, count := range overview.TaskStates {
+			tasks += count
+		}
+		score, _, _ := scoreConnectorHealth(gatherHealthInputs(cluster, overview.Name, overview.State, tasks, overview.TaskStates["failed"], now))
+		overview.Health = &HealthGrade{Grade: healthGrade(score), Score: score}
+		summary.Grades[overview.Health.Grade]++
+	}
+}
+
+// connectorHealthHandler grades a connector and lists the factors behind the grade.
+func connectorHealthHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+	status, err := fetchConnectorStatus(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster), name)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", name))
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		default:
+			writeJSONError(w, http.StatusBadGateway, "status_fetch_failed", err.Error())
+		}
+		return
+	}
+
+	state := normalizeState(status.Connector.State)
+	failed := 0
+	for _, task := range status.Tasks {
+		if normalizeState(task.State) == "failed" {
+			failed++
+		}
+	}
+	now := time.Now().UTC()
+	score, factors, missing := scoreConnectorHealth(gatherHealthInputs(cluster, name, state, len(status.Tasks), failed, now))
+	writeJSON(w, http.StatusOK, ConnectorHealth{
+		Connector:   name,
+		Grade:       healthGrade(score),
+		Score:       score,
+		State:       state,
+		Window:      healthScoreWindow.String(),
+		EvaluatedAt: now,
+		Factors:     factors,
+		Unavailable: missing,
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestScoreConnectorHealth(t *testing.T) {
+	percent := func(v float64) *ConnectorAvailability {
+		return &ConnectorAvailability{AvailabilityPercent: &v}
+	}
+	thresholds := alertThresholds{MaxLag: 1000, MinThroughput: 5}
+
+	tests := []struct {
+		name        string
+		in          healthInputs
+		score       int
+		grade       string
+		unavailable int
+	}{
+		{"healthy", healthInputs{State: "running", Tasks: 2, Availability: percent(100), HasHistory: true, Metrics: &ConnectorMetrics{RecordsOutPerSec: 50}, Thresholds: thresholds}, 100, "A", 0},
+		{"no history or metrics", healthInputs{State: "running", Tasks: 1}, 100, "A", 5},
+		{"restarts and downtime", healthInputs{State: "running", Tasks: 1, Availability: percent(95), Restarts: 2, HasHistory: true}, 80, "B", 3},
+		{"one of four tasks failed", healthInputs{State: "running", Tasks: 4, FailedTasks: 1}, 72, "C", 5},
+		{"lagging with errors", healthInputs{State: "running", Tasks: 1, Metrics: &ConnectorMetrics{RecordsInPerSec: 10, RecordsOutPerSec: 2, ErrorsPerSec: 1, OffsetLag: 5000}, Thresholds: thresholds}, 60, "D", 2},
+		{"failed connector", healthInputs{State: "failed", Tasks: 1}, 40, "F", 5},
+		{"paused is not penalised", healthInputs{State: "paused", Tasks: 1, Metrics: &ConnectorMetrics{}, Thresholds: thresholds}, 100, "A", 5},
+		{"floor at zero", healthInputs{State: "failed", Tasks: 1, FailedTasks: 1, Availability: percent(0), Restarts: 9, HasHistory: true}, 0, "F", 3},
+	}
+
+	for _, tt := range tests {
+		score, _, unavailable := scoreConnectorHealth(tt.in)
+		if score != tt.score || healthGrade(score) != tt.grade || len(unavailable) != tt.unavailable {
+			t.Errorf("%s: expected %d (%s) with %d unavailable, got %d (%s) %v", tt.name, tt.score, tt.grade, tt.unavailable, score, healthGrade(score), unavailable)
+		}
+	}
+}
+
+func TestConnectorHealthHandler(t *testing.T) {
+	now := time.Now().UTC().Add(-2 * time.Hour)
+	originalHistory, originalCollector := connectorStateHistory, connectorMetricsCollector
+	t.Cleanup(func() { connectorStateHistory, connectorMetricsCollector = originalHistory, originalCollector })
+	connectorStateHistory = newStateHistory(7*24*time.Hour, func() time.Time { return now })
+	connectorMetricsCollector = newMetricsCollector([]string{"http://jolokia.invalid"}, time.Hour, time.Now)
+
+	connectorStateHistory.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"}]}]`))
+	now = now.Add(time.Hour)
+	connectorStateHistory.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"FAILED"}]}]`))
+	now = now.Add(time.Minute)
+	connectorStateHistory.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"}]}]`))
+	connectorMetricsCollector.record(map[string]*ConnectorMetrics{"orders": {RecordsOutPerSec: 100}}, time.Now())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connectors/orders/status" {
+			http.Error(w, `{"error_code":404,"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"name":"orders","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"}]}`))
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	get := func(name string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connectors/"+name+"/health", nil), map[string]string{"cluster": "default", "name": name})
+		rr := httptest.NewRecorder()
+		connectorHealthHandler(rr, req)
+		return rr
+	}
+
+	rr := get("orders")
+	var health ConnectorHealth
+	if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	factors := map[string]HealthFactor{}
+	for _, factor := range health.Factors {
+		factors[factor.Factor] = factor
+	}
+	if health.State != "running" || len(health.Unavailable) != 0 || len(factors) != 7 {
+		t.Fatalf("unexpected health report %s", rr.Body.String())
+	}
+	if factors[healthFactorRestarts].Value != 1 || factors[healthFactorRestarts].Penalty != 5 || factors[healthFactorAvailability].Penalty == 0 {
+		t.Fatalf("expected the task restart to be scored, got %+v", health.Factors)
+	}
+	if health.Score >= 100 || health.Grade != healthGrade(health.Score) {
+		t.Fatalf("unexpected score %d (%s)", health.Score, health.Grade)
+	}
+
+	if rr := get("missing"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing connector, got %d", rr.Code)
+	}
+}
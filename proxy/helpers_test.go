@@ -19,6 +19,7 @@ func TestNormalizeState(t *testing.T) {
 		"Paused":     "paused",
 		"FAILED":     "failed",
 		"Unassigned": "unassigned",
+		"STOPPED":    "stopped",
 		"unknown":    "unknown",
 	}
 
@@ -31,7 +32,7 @@ func TestNormalizeState(t *testing.T) {
 
 func TestConnectUnavailableErrorUnwrap(t *testing.T) {
 	inner := errors.New("boom")
-	cue := &connectUnavailableError{err: inner}
+	cue := &connectUnavailableError{Err: inner}
 	if !errors.Is(cue, inner) {
 		t.Fatalf("expected errors.Is to match inner error")
 	}
@@ -65,6 +66,7 @@ func TestCopyHeaders(t *testing.T) {
 	src.Add("X-Custom", "b")
 	src.Add("Host", "example")
 	src.Add("Content-Length", "42")
+	src.Add("Cookie", sessionCookieName+"=token; theme=dark")
 
 	dst := http.Header{}
 	dst.Add("X-Custom", "old")
@@ -77,6 +79,9 @@ func TestCopyHeaders(t *testing.T) {
 	if dst.Get("Content-Length") != "" {
 		t.Fatalf("expected Content-Length header to be skipped, got %q", dst.Get("Content-Length"))
 	}
+	if dst.Get("Cookie") != "" {
+		t.Fatalf("expected Cookie header to be skipped, got %q", dst.Get("Cookie"))
+	}
 
 	values := dst.Values("X-Custom")
 	if len(values) != 2 || values[0] != "a" || values[1] != "b" {
@@ -287,3 +292,12 @@ func TestGetEnv(t *testing.T) {
 
 	os.Unsetenv("KCONNECT_TEST")
 }
+
+// withTestTrustedAuthHeaders trusts the identity headers of an authenticating proxy for
+// the rest of the test.
+func withTestTrustedAuthHeaders(t *testing.T) {
+	t.Helper()
+	original := trustAuthHeaders
+	trustAuthHeaders = true
+	t.Cleanup(func() { trustAuthHeaders = original })
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Every outbound HTTP call, to Kafka Connect as well as Jolokia, Schema Registry,
+	// notification webhooks and the other integrations, shares one pooled transport so
+	// connections are kept alive and reused across requests instead of being dialled
+	// per call.
+	upstreamMaxIdleConns        = getEnv("UPSTREAM_MAX_IDLE_CONNS", "100")
+	upstreamMaxIdleConnsPerHost = getEnv("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", "32")
+	upstreamMaxConnsPerHost     = getEnv("UPSTREAM_MAX_CONNS_PER_HOST", "0")
+	upstreamIdleConnTimeout     = getEnv("UPSTREAM_IDLE_CONN_TIMEOUT", "90s")
+	upstreamKeepAlive           = getEnv("UPSTREAM_KEEPALIVE", "30s")
+	upstreamHTTP2               = getEnv("UPSTREAM_HTTP2", "true")
+
+	upstreamHTTP = newPooledTransport(defaultTransportSettings)
+)
+
+// TransportSettings are the effective connection pool settings of upstreamHTTP.
+type TransportSettings struct {
+	MaxIdleConns        int           `json:"maxIdleConns"`
+	MaxIdleConnsPerHost int           `json:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int           `json:"maxConnsPerHost"` // 0 is unlimited
+	IdleConnTimeout     time.Duration `json:"-"`
+	KeepAlive           time.Duration `json:"-"` // negative disables TCP keep-alives
+	HTTP2               bool          `json:"http2"`
+
+	IdleConnTimeoutText string `json:"idleConnTimeout"`
+	KeepAliveText       string `json:"keepAlive"`
+}
+
+var defaultTransportSettings = TransportSettings{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 32,
+	IdleConnTimeout:     90 * time.Second,
+	KeepAlive:           30 * time.Second,
+	HTTP2:               true,
+}
+
+// loadTransportSettings parses the UPSTREAM_* connection pool settings.
+func loadTransportSettings() (TransportSettings, error) {
+	var settings TransportSettings
+	count := func(name, value string, target *int) error {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return &configError{name: name, value: value}
+		}
+		*target = n
+		return nil
+	}
+	if err := count("UPSTREAM_MAX_IDLE_CONNS", upstreamMaxIdleConns, &settings.MaxIdleConns); err != nil {
+		return settings, err
+	}
+	if err := count("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", upstreamMaxIdleConnsPerHost, &settings.MaxIdleConnsPerHost); err != nil {
+		return settings, err
+	}
+	if err := count("UPSTREAM_MAX_CONNS_PER_HOST", upstreamMaxConnsPerHost, &settings.MaxConnsPerHost); err != nil {
+		return settings, err
+	}
+
+	var err error
+	if settings.IdleConnTimeout, err = time.ParseDuration(strings.TrimSpace(upstreamIdleConnTimeout)); err != nil || settings.IdleConnTimeout < 0 {
+		return settings, &configError{name: "UPSTREAM_IDLE_CONN_TIMEOUT", value: upstreamIdleConnTimeout}
+	}
+	if settings.KeepAlive, err = time.ParseDuration(strings.TrimSpace(upstreamKeepAlive)); err != nil {
+		return settings, &configError{name: "UPSTREAM_KEEPALIVE", value: upstreamKeepAlive}
+	}
+	if settings.KeepAlive == 0 {
+		settings.KeepAlive = -1 // net.Dialer treats 0 as the default interval
+	}
+	if settings.HTTP2, err = strconv.ParseBool(strings.TrimSpace(upstreamHTTP2)); err != nil {
+		return settings, &configError{name: "UPSTREAM_HTTP2", value: upstreamHTTP2}
+	}
+	return settings, nil
+}
+
+// TransportHostStats are the connection counters of one upstream host.
+type TransportHostStats struct {
+	Host      string `json:"host"`
+	Open      int64  `json:"open"`
+	Dials     int64  `json:"dials"`
+	DialFails int64  `json:"dialFailures"`
+	Requests  int64  `json:"requests"`
+	Reused    int64  `json:"reused"`
+}
+
+// TransportStatus is returned by GET /api/debug/transport.
+type TransportStatus struct {
+	Settings TransportSettings    `json:"settings"`
+	Open     int64                `json:"open"`
+	Requests int64                `json:"requests"`
+	Reused   int64                `json:"reused"`
+	Hosts    []TransportHostStats `json:"hosts"`
+}
+
+// pooledTransport is the shared upstream transport. It wraps an http.Transport built
+// from the settings and counts dials, open connections and connection reuse per host.
+type pooledTransport struct {
+	mu        sync.Mutex
+	settings  TransportSettings
+	transport *http.Transport
+	hosts     map[string]*TransportHostStats
+}
+
+func newPooledTransport(settings TransportSettings) *pooledTransport {
+	t := &pooledTransport{hosts: make(map[string]*TransportHostStats)}
+	t.configure(settings)
+	return t
+}
+
+// configure replaces the underlying transport. Calls in flight finish on the old one,
+// whose idle connections are closed.
+func (t *pooledTransport) configure(settings TransportSettings) {
+	settings.IdleConnTimeoutText = settings.IdleConnTimeout.String()
+	settings.KeepAliveText = settings.KeepAlive.String()
+	if settings.KeepAlive < 0 {
+		settings.KeepAliveText = "off"
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: settings.KeepAlive}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           t.dialer(dialer),
+		ForceAttemptHTTP2:     settings.HTTP2,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
+		IdleConnTimeout:       settings.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if !settings.HTTP2 {
+		// A non-nil empty map keeps the transport from negotiating h2.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	t.mu.Lock()
+	previous := t.transport
+	t.settings, t.transport = settings, transport
+	t.mu.Unlock()
+	if previous != nil {
+		previous.CloseIdleConnections()
+	}
+}
+
+// host returns the counters of addr, creating them on first use. Callers hold t.mu.
+func (t *pooledTransport) host(addr string) *TransportHostStats {
+	stats, ok := t.hosts[addr]
+	if !ok {
+		stats = &TransportHostStats{Host: addr}
+		t.hosts[addr] = stats
+	}
+	return stats
+}
+
+func (t *pooledTransport) dialer(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, addr)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		stats := t.host(addr)
+		if err != nil {
+			stats.DialFails++
+			return nil, err
+		}
+		stats.Dials++
+		stats.Open++
+		return &countedConn{Conn: conn, release: func() {
+			t.mu.Lock()
+			stats.Open--
+			t.mu.Unlock()
+		}}, nil
+	}
+}
+
+func (t *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	transport := t.transport
+	stats := t.host(canonicalHostPort(req))
+	stats.Requests++
+	t.mu.Unlock()
+
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		if info.Reused {
+			t.mu.Lock()
+			stats.Reused++
+			t.mu.Unlock()
+		}
+	}}
+	return transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// CloseIdleConnections lets http.Client.CloseIdleConnections reach the pool.
+func (t *pooledTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	transport := t.transport
+	t.mu.Unlock()
+	transport.CloseIdleConnections()
+}
+
+// status returns the effective settings and a snapshot of the counters.
+func (t *pooledTransport) status() TransportStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := TransportStatus{Settings: t.settings, Hosts: make([]TransportHostStats, 0, len(t.hosts))}
+	for _, stats := range t.hosts {
+		status.Hosts = append(status.Hosts, *stats)
+		status.Open += stats.Open
+		status.Requests += stats.Requests
+		status.Reused += stats.Reused
+	}
+	sort.Slice(status.Hosts, func(i, j int) bool { return status.Hosts[i].Host < status.Hosts[j].Host })
+	return status
+}
+
+// canonicalHostPort returns the host:port a request dials, matching the address the
+// dialer sees.
+func canonicalHostPort(req *http.Request) string {
+	if port := req.URL.Port(); port != "" {
+		return net.JoinHostPort(req.URL.Hostname(), port)
+	}
+	if req.URL.Scheme == "https" {
+		return net.JoinHostPort(req.URL.Hostname(), "443")
+	}
+	return net.JoinHostPort(req.URL.Hostname(), "80")
+}
+
+// countedConn reports its close once, however often Close is called.
+type countedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// newUpstreamClient returns a client on the shared pooled transport.
+func newUpstreamClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: upstreamHTTP}
+}
+
+// debugTransportHandler reports the connection pool settings and counters of the
+// shared upstream transport.
+func debugTransportHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizeDebug(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, upstreamHTTP.status())
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPooledTransportReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	transport := newPooledTransport(defaultTransportSettings)
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	status := transport.status()
+	if len(status.Hosts) != 1 || status.Hosts[0].Host != host {
+		t.Fatalf("expected counters for %s, got %+v", host, status.Hosts)
+	}
+	if stats := status.Hosts[0]; stats.Dials != 1 || stats.Requests != 3 || stats.Reused != 2 || stats.Open != 1 {
+		t.Fatalf("expected one kept-alive connection for three requests, got %+v", stats)
+	}
+
+	client.CloseIdleConnections()
+	deadline := time.Now().Add(time.Second)
+	for transport.status().Open != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if open := transport.status().Open; open != 0 {
+		t.Fatalf("expected closed idle connections to be released, got %d open", open)
+	}
+}
+
+func TestPooledTransportHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	protocol := func(http2 bool) string {
+		settings := defaultTransportSettings
+		settings.HTTP2 = http2
+		transport := newPooledTransport(settings)
+		transport.transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	if got := protocol(true); got != "HTTP/2.0" {
+		t.Fatalf("expected HTTP/2 to be negotiated, got %s", got)
+	}
+	if got := protocol(false); got != "HTTP/1.1" {
+		t.Fatalf("expected HTTP/1.1 with UPSTREAM_HTTP2=false, got %s", got)
+	}
+}
+
+func TestLoadTransportSettings(t *testing.T) {
+	originals := []string{upstreamMaxConnsPerHost, upstreamKeepAlive, upstreamHTTP2}
+	t.Cleanup(func() {
+		upstreamMaxConnsPerHost, upstreamKeepAlive, upstreamHTTP2 = originals[0], originals[1], originals[2]
+	})
+
+	upstreamMaxConnsPerHost, upstreamKeepAlive, upstreamHTTP2 = "8", "0", "false"
+	settings, err := loadTransportSettings()
+	if err != nil || settings.MaxConnsPerHost != 8 || settings.KeepAlive >= 0 || settings.HTTP2 || settings.MaxIdleConnsPerHost != 32 {
+		t.Fatalf("unexpected settings %+v %v", settings, err)
+	}
+	upstreamMaxConnsPerHost = "-1"
+	if _, err := loadTransportSettings(); err == nil || !strings.Contains(err.Error(), "UPSTREAM_MAX_CONNS_PER_HOST") {
+		t.Fatalf("expected a negative limit to be refused, got %v", err)
+	}
+	upstreamMaxConnsPerHost, upstreamHTTP2 = "0", "sometimes"
+	if _, err := loadTransportSettings(); err == nil || !strings.Contains(err.Error(), "UPSTREAM_HTTP2") {
+		t.Fatalf("expected an invalid flag to be refused, got %v", err)
+	}
+}
+
+func TestDebugTransportHandler(t *testing.T) {
+	withTestCapture(t, 1, 64, "secret")
+	req := httptest.NewRequest(http.MethodGet, "/api/debug/transport", nil)
+	rr := httptest.NewRecorder()
+	debugTransportHandler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the debug token to be required, got %d", rr.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	debugTransportHandler(rr, req)
+	var status TransportStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"idleConnTimeout":"1m30s"`) || status.Hosts == nil {
+		t.Fatalf("expected the effective settings and host counters, got %s", rr.Body.String())
+	}
+}
+
+func TestConnectClientForReusesClients(t *testing.T) {
+	original := upstreamTimeouts
+	t.Cleanup(func() { upstreamTimeouts = original })
+
+	upstreamTimeouts = upstreamTimeoutConfig{Default: 5 * time.Second}
+	first := connectClientFor("reuse", routeRead)
+	if connectClientFor("reuse", routeRead) != first {
+		t.Fatal("expected the client to be reused")
+	}
+	if connectClientFor("reuse", routeWrite) == first {
+		t.Fatal("expected a client per route class")
+	}
+	upstreamTimeouts = upstreamTimeoutConfig{Default: time.Second}
+	if client := connectClientFor("reuse", routeRead); client == first || client.Transport.(*deadlineTransport).timeout != time.Second {
+		t.Fatal("expected a new client after the timeout changed")
+	}
+}
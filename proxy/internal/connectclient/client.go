@@ -0,0 +1,248 @@
+// Package connectclient talks to the Kafka Connect REST API. The proxy uses it against
+// the Connect workers and kconnect-cli against the proxy, which serves the same API
+// under /api/{cluster} with its redaction, policies and audit log in front.
+package connectclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrConnectorNotFound is returned when Kafka Connect has no connector of that name.
+var ErrConnectorNotFound = errors.New("connector not found")
+
+// UnavailableError reports that Kafka Connect could not be reached at all.
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("kafka connect is unreachable: %v", e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// ConnectorStatus is the answer of GET /connectors/{name}/status.
+type ConnectorStatus struct {
+	Name      string `json:"name"`
+	Connector struct {
+		State    string `json:"state"`
+		WorkerID string `json:"worker_id"`
+		Trace    string `json:"trace,omitempty"`
+	} `json:"connector"`
+	Tasks []struct {
+		ID       int    `json:"id"`
+		State    string `json:"state"`
+		WorkerID string `json:"worker_id"`
+		Trace    string `json:"trace,omitempty"`
+	} `json:"tasks"`
+	Type string `json:"type"`
+}
+
+// ExpandedConnector is one entry of GET /connectors?expand=info&expand=status.
+type ExpandedConnector struct {
+	Info struct {
+		Config map[string]string `json:"config"`
+		Type   string            `json:"type"`
+	} `json:"info"`
+	Status ConnectorStatus `json:"status"`
+}
+
+// JoinURL appends path parts to base, skipping empty ones.
+func JoinURL(base string, parts ...string) string {
+	trimmed := strings.TrimSuffix(base, "/")
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		trimmed += "/" + strings.TrimPrefix(part, "/")
+	}
+	return trimmed
+}
+
+// ConnectorNames lists the connectors of a cluster.
+func ConnectorNames(ctx context.Context, client *http.Client, baseURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, JoinURL(baseURL, "connectors"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &UnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching connectors: %d", resp.StatusCode)
+	}
+
+	var names []string
+	if err := json.NewDecoder(resp.Body).Decode(&names); err != nil {
+		return nil, fmt.Errorf("decode connectors: %w", err)
+	}
+
+	return names, nil
+}
+
+// Status reads the status of one connector and its tasks.
+func Status(ctx context.Context, client *http.Client, baseURL, name string) (ConnectorStatus, error) {
+	escaped := url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, JoinURL(baseURL, "connectors", escaped, "status"), nil)
+	if err != nil {
+		return ConnectorStatus{}, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ConnectorStatus{}, &UnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ConnectorStatus{}, ErrConnectorNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ConnectorStatus{}, fmt.Errorf("unexpected status fetching connector %s: %d", name, resp.StatusCode)
+	}
+
+	var status ConnectorStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return ConnectorStatus{}, fmt.Errorf("decode connector status for %s: %w", name, err)
+	}
+
+	return status, nil
+}
+
+// Expanded loads every connector with its status and config in one request.
+func Expanded(ctx context.Context, client *http.Client, baseURL string) (map[string]ExpandedConnector, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, JoinURL(baseURL, "connectors")+"?expand=info&expand=status", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &UnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching connectors: %d", resp.StatusCode)
+	}
+
+	var expanded map[string]ExpandedConnector
+	if err := json.NewDecoder(resp.Body).Decode(&expanded); err != nil {
+		return nil, fmt.Errorf("decode connectors: %w", err)
+	}
+	return expanded, nil
+}
+
+// Restart asks Connect to restart a connector with the given options and returns the
+// HTTP status Connect answered with.
+func Restart(ctx context.Context, client *http.Client, baseURL, name string, includeTasks, onlyFailed bool) (int, error) {
+	query := url.Values{}
+	query.Set("includeTasks", strconv.FormatBool(includeTasks))
+	query.Set("onlyFailed", strconv.FormatBool(onlyFailed))
+	target := JoinURL(baseURL, "connectors", url.PathEscape(name), "restart") + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, &UnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, ErrConnectorNotFound
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.StatusCode, fmt.Errorf("restart of %s returned HTTP %d: %s", name, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.StatusCode, nil
+}
+
+// Validation is Kafka Connect's answer to
+// PUT /connector-plugins/{class}/config/validate: the definition of every setting of
+// the plugin and the validated value of each, in the plugin's group order.
+type Validation struct {
+	ErrorCount int      `json:"error_count"`
+	Groups     []string `json:"groups"`
+	Configs    []struct {
+		Definition struct {
+			Name          string   `json:"name"`
+			Type          string   `json:"type"`
+			Required      bool     `json:"required"`
+			DefaultValue  *string  `json:"default_value"`
+			Importance    string   `json:"importance"`
+			Documentation string   `json:"documentation"`
+			Group         string   `json:"group"`
+			Width         string   `json:"width"`
+			DisplayName   string   `json:"display_name"`
+			Dependents    []string `json:"dependents"`
+			Order         int      `json:"order"`
+		} `json:"definition"`
+		Value struct {
+			Name              string   `json:"name"`
+			Value             *string  `json:"value"`
+			RecommendedValues []string `json:"recommended_values"`
+			Errors            []string `json:"errors"`
+			Visible           bool     `json:"visible"`
+		} `json:"value"`
+	} `json:"configs"`
+}
+
+// ValidationError reports a validation request Kafka Connect refused, such as one
+// naming a plugin that is not installed.
+type ValidationError struct {
+	Class   string
+	Status  int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validate %s: HTTP %d: %s", e.Class, e.Status, e.Message)
+}
+
+// Validate runs Kafka Connect's validation of config against the plugin.
+func Validate(ctx context.Context, client *http.Client, baseURL, class string, config interface{}) (Validation, error) {
+	var payload Validation
+	body, err := json.Marshal(config)
+	if err != nil {
+		return payload, err
+	}
+	target := JoinURL(baseURL, "connector-plugins", url.PathEscape(class), "config", "validate")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(body))
+	if err != nil {
+		return payload, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return payload, &UnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return payload, &ValidationError{Class: class, Status: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return payload, fmt.Errorf("decode validation of %s: %w", class, err)
+	}
+	return payload, nil
+}
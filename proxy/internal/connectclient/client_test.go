@@ -0,0 +1,88 @@
+package connectclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+func TestStatusAndRestart(t *testing.T) {
+	server := testutils.NewConnectServer(map[string]testutils.Response{
+		"GET /connectors/orders-sink/status": {Body: map[string]interface{}{
+			"name":      "orders-sink",
+			"connector": map[string]interface{}{"state": "RUNNING", "worker_id": "connect-1:8083"},
+			"tasks":     []interface{}{map[string]interface{}{"id": 0, "state": "FAILED", "worker_id": "connect-1:8083", "trace": "boom"}},
+		}},
+		"POST /connectors/orders-sink/restart": {Status: http.StatusAccepted},
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	status, err := Status(ctx, http.DefaultClient, server.URL(), "orders-sink")
+	if err != nil || status.Connector.WorkerID != "connect-1:8083" || len(status.Tasks) != 1 || status.Tasks[0].Trace != "boom" {
+		t.Fatalf("unexpected status %+v %v", status, err)
+	}
+	if _, err := Status(ctx, http.DefaultClient, server.URL(), "missing"); !errors.Is(err, ErrConnectorNotFound) {
+		t.Fatalf("expected ErrConnectorNotFound, got %v", err)
+	}
+	if code, err := Restart(ctx, http.DefaultClient, server.URL(), "orders-sink", true, true); err != nil || code != http.StatusAccepted {
+		t.Fatalf("unexpected restart result %d %v", code, err)
+	}
+	if _, err := Restart(ctx, http.DefaultClient, server.URL(), "missing", false, false); !errors.Is(err, ErrConnectorNotFound) {
+		t.Fatalf("expected ErrConnectorNotFound, got %v", err)
+	}
+
+	var unavailable *UnavailableError
+	if _, err := Status(ctx, http.DefaultClient, "http://127.0.0.1:1", "orders-sink"); !errors.As(err, &unavailable) {
+		t.Fatalf("expected UnavailableError, got %v", err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var running, degraded, stopped ConnectorStatus
+	running.Connector.State = "RUNNING"
+	running.Tasks = append(running.Tasks, struct {
+		ID       int    `json:"id"`
+		State    string `json:"state"`
+		WorkerID string `json:"worker_id"`
+		Trace    string `json:"trace,omitempty"`
+	}{ID: 0, State: "RUNNING"})
+	degraded.Connector.State = "RUNNING"
+	degraded.Tasks = append(degraded.Tasks, running.Tasks[0], running.Tasks[0])
+	degraded.Tasks[1].State = "FAILED"
+	stopped.Connector.State = "STOPPED"
+
+	summary := Summarize([]ConnectorStatus{running, degraded, stopped})
+	if summary.TotalConnectors != 3 || summary.ConnectorStates["running"] != 2 || summary.TaskStates["failed"] != 1 || summary.TaskStates["running"] != 2 {
+		t.Fatalf("unexpected counts %+v", summary)
+	}
+	if summary.Totals["running"] != 1 || summary.Totals["degraded"] != 1 || summary.Totals["stopped"] != 1 {
+		t.Fatalf("unexpected totals %v", summary.Totals)
+	}
+}
+
+func TestExportAndSign(t *testing.T) {
+	expanded := map[string]ExpandedConnector{}
+	for _, name := range []string{"orders-sink", "billing-source"} {
+		var connector ExpandedConnector
+		connector.Info.Config = map[string]string{"name": name, "connector.class": "FileStreamSink", "tasks.max": "1"}
+		expanded[name] = connector
+	}
+	deployment := Export(expanded)
+	if len(deployment.Connectors) != 2 || deployment.Connectors[0].Name != "billing-source" {
+		t.Fatalf("expected connectors sorted by name, got %+v", deployment.Connectors)
+	}
+	if _, ok := deployment.Connectors[0].Config["name"]; ok || deployment.Connectors[0].Config["tasks.max"] != "1" {
+		t.Fatalf("expected the config without its name, got %v", deployment.Connectors[0].Config)
+	}
+
+	if got := Sign("secret", "1700000000", false, []byte(`{}`)); got != "sha256=89cef24a948e505474dec7c95ddbb9c12422d6529ed44437b4b778cc641353f5" {
+		t.Fatalf("unexpected signature %s", got)
+	}
+	if Sign("secret", "1700000000", true, []byte(`{}`)) == Sign("secret", "1700000000", false, []byte(`{}`)) {
+		t.Fatal("expected dry runs to be signed differently")
+	}
+}
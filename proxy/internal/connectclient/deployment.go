@@ -0,0 +1,63 @@
+package connectclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a deployment as sha256=<hex>, the format
+// GitHub and GitLab webhooks use. See Sign for what is signed.
+const SignatureHeader = "X-Hub-Signature-256"
+
+// TimestampHeader carries the Unix time a deployment was signed at. The proxy refuses
+// signatures that are too old, so a captured request cannot be sent again later.
+const TimestampHeader = "X-Kconnect-Timestamp"
+
+// Deployment is the body of POST /api/{cluster}/deploy, and the format connectors are
+// exported in. Prune deletes connectors that are not listed; it is part of the signed
+// body so that it cannot be added to a captured request.
+type Deployment struct {
+	Revision   string            `json:"revision,omitempty"`
+	Prune      bool              `json:"prune"`
+	Connectors []DeployConnector `json:"connectors"`
+}
+
+// DeployConnector is the desired config of one connector. Values may be strings,
+// numbers or booleans, and may contain secret placeholders.
+type DeployConnector struct {
+	Name   string                 `json:"name"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// Sign returns the SignatureHeader value of a deployment: the HMAC-SHA256 of the
+// TimestampHeader value, "true" or "false" for ?dryRun and the body, joined by dots.
+// Signing dryRun keeps a captured dry run from being replayed as a real deployment.
+func Sign(secret, timestamp string, dryRun bool, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s.%t.", timestamp, dryRun)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Export turns the expanded connectors of a cluster into a deployment that recreates
+// them, sorted by name. The name key is left out of the configs, as the deploy
+// endpoint sets it.
+func Export(expanded map[string]ExpandedConnector) Deployment {
+	deployment := Deployment{Connectors: make([]DeployConnector, 0, len(expanded))}
+	for name, connector := range expanded {
+		config := make(map[string]interface{}, len(connector.Info.Config))
+		for key, value := range connector.Info.Config {
+			if key != "name" {
+				config[key] = value
+			}
+		}
+		deployment.Connectors = append(deployment.Connectors, DeployConnector{Name: name, Config: config})
+	}
+	sort.Slice(deployment.Connectors, func(i, j int) bool {
+		return deployment.Connectors[i].Name < deployment.Connectors[j].Name
+	})
+	return deployment
+}
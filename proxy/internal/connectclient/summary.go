@@ -0,0 +1,82 @@
+package connectclient
+
+import "strings"
+
+// NormalizeState lowercases a Connect state, mapping states it does not know to
+// "unknown".
+func NormalizeState(state string) string {
+	switch strings.ToUpper(state) {
+	case "RUNNING":
+		return "running"
+	case "PAUSED":
+		return "paused"
+	case "STOPPED":
+		return "stopped"
+	case "FAILED":
+		return "failed"
+	case "UNASSIGNED":
+		return "unassigned"
+	default:
+		return "unknown"
+	}
+}
+
+// NewStateCounter returns a count of zero for every normalized state.
+func NewStateCounter() map[string]int {
+	return map[string]int{
+		"running":    0,
+		"paused":     0,
+		"stopped":    0,
+		"failed":     0,
+		"unassigned": 0,
+		"unknown":    0,
+	}
+}
+
+// TotalsClass is the bucket of Summary.Totals a connector counts in. A connector with
+// some failed and some running tasks is degraded, as is one in a state without a bucket
+// of its own.
+func TotalsClass(state string, taskStates map[string]int) string {
+	switch {
+	case taskStates["failed"] > 0 && taskStates["running"] > 0:
+		return "degraded"
+	case taskStates["failed"] > 0:
+		return "failed"
+	}
+	switch state {
+	case "failed", "running", "stopped":
+		return state
+	}
+	return "degraded"
+}
+
+// Summary counts connectors and tasks per normalized state.
+type Summary struct {
+	TotalConnectors int            `json:"totalConnectors"`
+	ConnectorStates map[string]int `json:"connectorStates"`
+	TaskStates      map[string]int `json:"taskStates"`
+	Totals          map[string]int `json:"totals"`
+}
+
+// Summarize counts the connectors and tasks of statuses.
+func Summarize(statuses []ConnectorStatus) Summary {
+	summary := Summary{
+		TotalConnectors: len(statuses),
+		ConnectorStates: NewStateCounter(),
+		TaskStates:      NewStateCounter(),
+		Totals:          map[string]int{"total": len(statuses), "running": 0, "degraded": 0, "failed": 0, "stopped": 0},
+	}
+	for _, status := range statuses {
+		state := NormalizeState(status.Connector.State)
+		summary.ConnectorStates[state]++
+		taskStates := make(map[string]int)
+		for _, task := range status.Tasks {
+			taskStates[NormalizeState(task.State)]++
+		}
+		for taskState, count := range taskStates {
+			summary.TaskStates[taskState] += count
+		}
+		summary.Totals[TotalsClass(state, taskStates)]++
+	}
+	return summary
+}
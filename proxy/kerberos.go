@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+var (
+	// Kerberos (SPNEGO) authentication for Kafka Connect clusters behind a Negotiate
+	// gateway. When the principal and keytab are set every upstream request carries a
+	// fresh Negotiate token for HTTP/<host of the cluster URL>.
+	kerberosPrincipal = getEnv("KAFKA_CONNECT_KERBEROS_PRINCIPAL", "")
+	kerberosKeytab    = getEnv("KAFKA_CONNECT_KERBEROS_KEYTAB", "")
+	kerberosConfig    = getEnv("KAFKA_CONNECT_KERBEROS_CONFIG", "/etc/krb5.conf")
+	kerberosService   = getEnv("KAFKA_CONNECT_KERBEROS_SERVICE", "HTTP")
+
+	connectKerberos *kerberosAuth
+)
+
+// kerberosAuth logs in with a keytab and signs upstream requests with SPNEGO tokens.
+// The underlying client caches its ticket-granting and service tickets and logs in
+// again once they expire.
+type kerberosAuth struct {
+	principal string
+	service   string
+	client    *krbclient.Client
+}
+
+// loadKerberosAuth reads the keytab and krb5.conf named by the KAFKA_CONNECT_KERBEROS_*
+// settings. It returns nil when Kerberos is not configured; the KDC is only contacted
+// by the first upstream request.
+func loadKerberosAuth() (*kerberosAuth, error) {
+	principal, keytabPath := strings.TrimSpace(kerberosPrincipal), strings.TrimSpace(kerberosKeytab)
+	if principal == "" && keytabPath == "" {
+		return nil, nil
+	}
+	if principal == "" || keytabPath == "" {
+		return nil, errors.New("KAFKA_CONNECT_KERBEROS_PRINCIPAL and KAFKA_CONNECT_KERBEROS_KEYTAB must be set together")
+	}
+	if connectUsername != "" {
+		return nil, errors.New("KAFKA_CONNECT_USERNAME and Kerberos authentication cannot be combined")
+	}
+	if strings.TrimSpace(kerberosService) == "" {
+		return nil, &configError{name: "KAFKA_CONNECT_KERBEROS_SERVICE", value: kerberosService}
+	}
+
+	cfg, err := krbconfig.Load(kerberosConfig)
+	if err != nil {
+		return nil, fmt.Errorf("KAFKA_CONNECT_KERBEROS_CONFIG: %w", err)
+	}
+	kt, err := keytab.Load(keytabPath)
+	if err != nil {
+		return nil, fmt.Errorf("KAFKA_CONNECT_KERBEROS_KEYTAB: %w", err)
+	}
+
+	username, realm, _ := strings.Cut(principal, "@")
+	if realm == "" {
+		realm = cfg.LibDefaults.DefaultRealm
+	}
+	if username == "" || realm == "" {
+		return nil, fmt.Errorf("KAFKA_CONNECT_KERBEROS_PRINCIPAL %q needs a realm, either user@REALM or default_realm in %s", principal, kerberosConfig)
+	}
+
+	client := krbclient.NewWithKeytab(username, realm, kt, cfg, krbclient.DisablePAFXFAST(true))
+	return &kerberosAuth{principal: username + "@" + realm, service: strings.TrimSpace(kerberosService), client: client}, nil
+}
+
+// servicePrincipal is the SPN of the cluster req is sent to, e.g. HTTP/connect-1.example.com.
+func (k *kerberosAuth) servicePrincipal(req *http.Request) string {
+	return k.service + "/" + strings.ToLower(strings.TrimSuffix(req.URL.Hostname(), "."))
+}
+
+// authorize sets a Negotiate Authorization header on req, logging in first when the
+// client has no valid ticket.
+func (k *kerberosAuth) authorize(req *http.Request) error {
+	spn := k.servicePrincipal(req)
+	if err := spnego.SetSPNEGOHeader(k.client, req, spn); err != nil {
+		return fmt.Errorf("kerberos: authenticate %s to %s: %w", k.principal, spn, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+// withTestKerberos writes a keytab for console@EXAMPLE.COM and a krb5.conf pointing at
+// kdc, and configures the KAFKA_CONNECT_KERBEROS_* settings to use them.
+func withTestKerberos(t *testing.T, kdc string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	kt := keytab.New()
+	if err := kt.AddEntry("console", "EXAMPLE.COM", "s3cret", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96); err != nil {
+		t.Fatal(err)
+	}
+	data, err := kt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keytabPath, confPath := filepath.Join(dir, "console.keytab"), filepath.Join(dir, "krb5.conf")
+	if err := os.WriteFile(keytabPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conf := "[libdefaults]\n  default_realm = EXAMPLE.COM\n  dns_lookup_kdc = false\n\n[realms]\n  EXAMPLE.COM = {\n    kdc = " + kdc + "\n  }\n"
+	if err := os.WriteFile(confPath, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	originals := []string{kerberosPrincipal, kerberosKeytab, kerberosConfig, kerberosService, connectUsername}
+	t.Cleanup(func() {
+		kerberosPrincipal, kerberosKeytab, kerberosConfig, kerberosService, connectUsername = originals[0], originals[1], originals[2], originals[3], originals[4]
+	})
+	kerberosPrincipal, kerberosKeytab, kerberosConfig, kerberosService, connectUsername = "console", keytabPath, confPath, "HTTP", ""
+}
+
+func TestLoadKerberosAuth(t *testing.T) {
+	withTestKerberos(t, "127.0.0.1:88")
+
+	auth, err := loadKerberosAuth()
+	if err != nil || auth == nil || auth.principal != "console@EXAMPLE.COM" {
+		t.Fatalf("expected the default realm to complete the principal, got %+v %v", auth, err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://Connect-1.example.com:8083/connectors", nil)
+	if spn := auth.servicePrincipal(req); spn != "HTTP/connect-1.example.com" {
+		t.Fatalf("unexpected service principal %q", spn)
+	}
+
+	keytabPath := kerberosKeytab
+	for _, tt := range []struct {
+		name   string
+		change func()
+		want   string
+	}{
+		{"keytab without principal", func() { kerberosPrincipal = "" }, "must be set together"},
+		{"basic auth as well", func() { connectUsername = "admin" }, "cannot be combined"},
+		{"missing keytab", func() { kerberosKeytab = filepath.Join(t.TempDir(), "missing.keytab") }, "KAFKA_CONNECT_KERBEROS_KEYTAB"},
+		{"missing krb5.conf", func() { kerberosConfig = filepath.Join(t.TempDir(), "krb5.conf") }, "KAFKA_CONNECT_KERBEROS_CONFIG"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			withTestKerberos(t, "127.0.0.1:88")
+			kerberosKeytab = keytabPath
+			tt.change()
+			if _, err := loadKerberosAuth(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	kerberosPrincipal, kerberosKeytab = "", ""
+	if auth, err := loadKerberosAuth(); auth != nil || err != nil {
+		t.Fatalf("expected Kerberos to be off, got %+v %v", auth, err)
+	}
+}
+
+func TestAuthTransportKerberosFailsWithoutTicket(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdc := listener.Addr().String()
+	listener.Close() // nothing answers, so no ticket can be obtained
+
+	withTestKerberos(t, kdc)
+	auth, err := loadKerberosAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	client := &http.Client{Transport: &authTransport{base: http.DefaultTransport, kerberos: auth}}
+	_, err = client.Get(server.URL + "/connectors")
+	if err == nil || !strings.Contains(err.Error(), "kerberos: authenticate console@EXAMPLE.COM to HTTP/127.0.0.1") {
+		t.Fatalf("expected a Kerberos error, got %v", err)
+	}
+	if hits.Load() != 0 {
+		t.Fatal("expected no unauthenticated request to reach Kafka Connect")
+	}
+}
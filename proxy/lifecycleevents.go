@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var (
+	// eventsTopic is the Kafka topic connector lifecycle events are written to, on the
+	// brokers of KAFKA_BOOTSTRAP_SERVERS. Publishing is off while it is unset.
+	eventsTopic  = getEnv("EVENTS_TOPIC", "")
+	eventsBuffer = getEnv("EVENTS_BUFFER", "1000")
+
+	// lifecycleEvents is nil unless EVENTS_TOPIC is set.
+	lifecycleEvents *eventPublisher
+)
+
+// lifecycleEventSchemaVersion is raised whenever a field of LifecycleEvent changes
+// meaning or is removed; new optional fields keep the version.
+const lifecycleEventSchemaVersion = 1
+
+// Types of LifecycleEvent.
+const (
+	lifecycleConnectorCreated      = "connector.created"
+	lifecycleConnectorUpdated      = "connector.updated"
+	lifecycleConnectorDeleted      = "connector.deleted"
+	lifecycleConnectorStateChanged = "connector.state_changed"
+	lifecycleAudit                 = "audit"
+)
+
+// connectorRemovedState is the State of a state change for a connector that is no
+// longer reported by Connect.
+const connectorRemovedState = "removed"
+
+// LifecycleEvent is the JSON value of every record on EVENTS_TOPIC. Records are keyed
+// by cluster and connector ("cluster/connector", or the cluster alone), so the events
+// of one connector stay in order on one partition.
+type LifecycleEvent struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	ID            string         `json:"id"`
+	Type          string         `json:"type"`
+	Timestamp     time.Time      `json:"timestamp"`
+	Cluster       string         `json:"cluster"`
+	Connector     string         `json:"connector,omitempty"`
+	User          string         `json:"user,omitempty"`
+	State         string         `json:"state,omitempty"`
+	PreviousState string         `json:"previousState,omitempty"`
+	Audit         *AuditLogEntry `json:"audit,omitempty"`
+}
+
+// key returns the record key of event.
+func (event LifecycleEvent) key() string {
+	if event.Connector == "" {
+		return event.Cluster
+	}
+	return event.Cluster + "/" + event.Connector
+}
+
+// auditLifecycleEvent describes an audit entry as an event. Successful creates,
+// updates and deletes of a connector get their own types; every other entry,
+// including failed changes, is an "audit" event. Captured bodies are never published.
+func auditLifecycleEvent(entry AuditLogEntry) LifecycleEvent {
+	entry = entry.withoutBodies()
+	event := LifecycleEvent{
+		SchemaVersion: lifecycleEventSchemaVersion,
+		ID:            "audit-" + entry.ID,
+		Type:          lifecycleAudit,
+		Timestamp:     entry.Timestamp,
+		Cluster:       entry.Cluster,
+		Connector:     entry.ConnectorName,
+		User:          entry.User,
+		Audit:         &entry,
+	}
+	if entry.Status == auditStatusSuccess && entry.TargetType == auditTargetConnector {
+		switch entry.Action {
+		case auditActionCreate:
+			event.Type = lifecycleConnectorCreated
+		case auditActionUpdate:
+			event.Type = lifecycleConnectorUpdated
+		case auditActionDelete:
+			event.Type = lifecycleConnectorDeleted
+		}
+	}
+	return event
+}
+
+// eventProducer writes one record to the events topic.
+type eventProducer interface {
+	produce(ctx context.Context, key, value []byte) error
+}
+
+// kafkaEventProducer produces to Kafka. The client is created on the first record so
+// a broker that is down at startup does not hold the proxy up.
+type kafkaEventProducer struct {
+	brokers []string
+	topic   string
+	client  *kgo.Client
+}
+
+func (p *kafkaEventProducer) produce(ctx context.Context, key, value []byte) error {
+	if p.client == nil {
+		client, err := kgo.NewClient(kgo.SeedBrokers(p.brokers...), kgo.DefaultProduceTopic(p.topic))
+		if err != nil {
+			return err
+		}
+		p.client = client
+	}
+	return p.client.ProduceSync(ctx, &kgo.Record{Key: key, Value: value}).FirstErr()
+}
+
+// eventPublisher queues lifecycle events and writes them to Kafka from one goroutine,
+// so audited requests never wait for the brokers. Events beyond the buffer are dropped
+// and counted.
+type eventPublisher struct {
+	producer eventProducer
+	topic    string
+	events   chan LifecycleEvent
+
+	mu      sync.Mutex
+	states  map[string]map[string]string // cluster -> connector -> state
+	dropped int
+	now     func() time.Time
+}
+
+// loadEventPublisher builds the publisher from EVENTS_TOPIC and EVENTS_BUFFER. It
+// returns nil when EVENTS_TOPIC is unset.
+func loadEventPublisher() (*eventPublisher, error) {
+	topic := strings.TrimSpace(eventsTopic)
+	if topic == "" {
+		return nil, nil
+	}
+	brokers := splitList(kafkaBootstrapServers)
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("KAFKA_BOOTSTRAP_SERVERS is required when EVENTS_TOPIC is set")
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(eventsBuffer))
+	if err != nil || size <= 0 {
+		return nil, &configError{name: "EVENTS_BUFFER", value: eventsBuffer}
+	}
+	return newEventPublisher(&kafkaEventProducer{brokers: brokers, topic: topic}, topic, size), nil
+}
+
+func newEventPublisher(producer eventProducer, topic string, size int) *eventPublisher {
+	return &eventPublisher{
+		producer: producer,
+		topic:    topic,
+		events:   make(chan LifecycleEvent, size),
+		states:   make(map[string]map[string]string),
+		now:      time.Now,
+	}
+}
+
+// publish queues event, dropping it when the buffer is full.
+func (p *eventPublisher) publish(event LifecycleEvent) {
+	select {
+	case p.events <- event:
+	default:
+		p.mu.Lock()
+		p.dropped++
+		p.mu.Unlock()
+	}
+}
+
+// auditLogged publishes a logged audit entry.
+func (p *eventPublisher) auditLogged(entry AuditLogEntry) {
+	p.publish(auditLifecycleEvent(entry))
+}
+
+// observeStatuses is a statusObserver: it publishes a state change for every connector
+// whose state differs from the previous poll, including connectors that appeared (with
+// no previous state) or disappeared. The first poll of a cluster only establishes a
+// baseline.
+func (p *eventPublisher) observeStatuses(clusterID string, statuses []connectorStatusResponse) {
+	now := p.now().UTC()
+	current := make(map[string]string, len(statuses))
+	for _, status := range statuses {
+		current[status.Name] = normalizeState(status.Connector.State)
+	}
+
+	p.mu.Lock()
+	previous, known := p.states[clusterID]
+	p.states[clusterID] = current
+	p.mu.Unlock()
+	if !known {
+		return
+	}
+
+	changed := func(name, state, before string) {
+		p.publish(LifecycleEvent{
+			SchemaVersion: lifecycleEventSchemaVersion,
+			ID:            fmt.Sprintf("state-%s-%s-%d", clusterID, name, now.UnixNano()),
+			Type:          lifecycleConnectorStateChanged,
+			Timestamp:     now,
+			Cluster:       clusterID,
+			Connector:     name,
+			State:         state,
+			PreviousState: before,
+		})
+	}
+	for _, status := range statuses {
+		if state, before := current[status.Name], previous[status.Name]; state != before {
+			changed(status.Name, state, before)
+		}
+	}
+	for name, before := range previous {
+		if _, ok := current[name]; !ok {
+			changed(name, connectorRemovedState, before)
+		}
+	}
+}
+
+// send writes one event. Events that cannot be encoded or written are logged and
+// dropped; the producer retries transient broker errors until ctx ends.
+func (p *eventPublisher) send(ctx context.Context, event LifecycleEvent) {
+	value, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("lifecycle events: failed to encode %s: %v", event.ID, err)
+		return
+	}
+	if err := p.producer.produce(ctx, []byte(event.key()), value); err != nil {
+		log.Printf("lifecycle events: failed to write %s %s to %s: %v", event.Type, event.ID, p.topic, err)
+	}
+}
+
+func (p *eventPublisher) run(stop <-chan struct{}) {
+	for {
+		var event LifecycleEvent
+		select {
+		case event = <-p.events:
+		case <-stop:
+			return
+		}
+
+		p.mu.Lock()
+		dropped := p.dropped
+		p.dropped = 0
+		p.mu.Unlock()
+		if dropped > 0 {
+			log.Printf("lifecycle events: dropped %d events because the buffer was full", dropped)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		p.send(ctx, event)
+		cancel()
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingProducer keeps the records it is asked to produce.
+type recordingProducer struct {
+	mu      sync.Mutex
+	keys    []string
+	records []LifecycleEvent
+}
+
+func (p *recordingProducer) produce(ctx context.Context, key, value []byte) error {
+	var event LifecycleEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = append(p.keys, string(key))
+	p.records = append(p.records, event)
+	return nil
+}
+
+func (p *recordingProducer) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.records)
+}
+
+func TestEventPublisherAuditEvents(t *testing.T) {
+	withTestAuditLog(t, 10)
+	producer := &recordingProducer{}
+	publisher := newEventPublisher(producer, "kconnect.events", 10)
+	original := lifecycleEvents
+	lifecycleEvents = publisher
+	t.Cleanup(func() { lifecycleEvents = original })
+	stop := make(chan struct{})
+	defer close(stop)
+	go publisher.run(stop)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/default/connectors", nil)
+	created := newAuditEntry(req, auditActionCreate, "orders-sink", http.StatusCreated, nil)
+	created.Cluster, created.RequestBody = "default", `{"name":"orders-sink"}`
+	logAudit(created)
+	failed := newAuditEntry(req, auditActionDelete, "orders-sink", http.StatusConflict, nil)
+	failed.Cluster = "default"
+	logAudit(failed)
+	logAudit(AuditLogEntry{Cluster: "default", Action: auditActionRebalance, Status: auditStatusSuccess})
+
+	waitFor(t, func() bool { return producer.count() == 3 })
+	for i, want := range []string{lifecycleConnectorCreated + " default/orders-sink", lifecycleAudit + " default/orders-sink", lifecycleAudit + " default"} {
+		if got := producer.records[i].Type + " " + producer.keys[i]; got != want {
+			t.Errorf("event %d: expected %q, got %q", i, want, got)
+		}
+	}
+	event := producer.records[0]
+	if event.SchemaVersion != lifecycleEventSchemaVersion || event.ID != "audit-1" || event.Audit == nil || event.Audit.Action != auditActionCreate {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if event.Audit.RequestBody != "" {
+		t.Fatalf("expected captured bodies to stay out of events, got %q", event.Audit.RequestBody)
+	}
+}
+
+func TestEventPublisherStateChanges(t *testing.T) {
+	producer := &recordingProducer{}
+	publisher := newEventPublisher(producer, "kconnect.events", 1)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	publisher.now = func() time.Time { return now }
+
+	poll := func(states ...string) {
+		var statuses []connectorStatusResponse
+		for _, pair := range states {
+			name, state, _ := strings.Cut(pair, "=")
+			status := connectorStatusResponse{Name: name}
+			status.Connector.State = state
+			statuses = append(statuses, status)
+		}
+		publisher.observeStatuses("default", statuses)
+	}
+	poll("orders-sink=RUNNING", "old-source=RUNNING")
+	if len(publisher.events) != 0 {
+		t.Fatal("expected the first poll to only establish a baseline")
+	}
+
+	poll("orders-sink=FAILED", "new-sink=RUNNING")
+	var got []string
+	for len(publisher.events) > 0 {
+		event := <-publisher.events
+		got = append(got, event.Connector+":"+event.PreviousState+">"+event.State)
+	}
+	// The buffer holds one event, so the other two are dropped and counted.
+	if len(got) != 1 || publisher.dropped != 2 {
+		t.Fatalf("expected one queued and two dropped events, got %v and %d dropped", got, publisher.dropped)
+	}
+
+	publisher.events = make(chan LifecycleEvent, 10)
+	poll("orders-sink=FAILED", "new-sink=RUNNING")
+	if len(publisher.events) != 0 {
+		t.Fatal("expected no events while nothing changes")
+	}
+	poll("orders-sink=RUNNING")
+	got = nil
+	for len(publisher.events) > 0 {
+		event := <-publisher.events
+		if event.Type != lifecycleConnectorStateChanged || !strings.HasPrefix(event.ID, "state-default-") {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		got = append(got, event.Connector+":"+event.PreviousState+">"+event.State)
+	}
+	if strings.Join(got, ",") != "orders-sink:failed>running,new-sink:running>removed" {
+		t.Fatalf("unexpected state changes %v", got)
+	}
+}
+
+func TestLoadEventPublisher(t *testing.T) {
+	originals := []string{eventsTopic, eventsBuffer, kafkaBootstrapServers}
+	t.Cleanup(func() {
+		eventsTopic, eventsBuffer, kafkaBootstrapServers = originals[0], originals[1], originals[2]
+	})
+
+	eventsTopic, eventsBuffer, kafkaBootstrapServers = "", "1000", ""
+	if publisher, err := loadEventPublisher(); publisher != nil || err != nil {
+		t.Fatalf("expected publishing to be off, got %v %v", publisher, err)
+	}
+	eventsTopic = "kconnect.events"
+	if _, err := loadEventPublisher(); err == nil || !strings.Contains(err.Error(), "KAFKA_BOOTSTRAP_SERVERS") {
+		t.Fatalf("expected brokers to be required, got %v", err)
+	}
+	kafkaBootstrapServers, eventsBuffer = "kafka:9092", "none"
+	if _, err := loadEventPublisher(); err == nil || !strings.Contains(err.Error(), "EVENTS_BUFFER") {
+		t.Fatalf("expected an invalid buffer to be rejected, got %v", err)
+	}
+	eventsBuffer = "5"
+	if publisher, err := loadEventPublisher(); err != nil || publisher.topic != "kconnect.events" || cap(publisher.events) != 5 {
+		t.Fatalf("unexpected publisher %+v %v", publisher, err)
+	}
+}
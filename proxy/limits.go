@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// Connector quotas checked on every connector create and config update. 0 leaves a
+	// quota unset. Connectors per tenant are counted over the caller's namespaces and do
+	// not apply to callers who see every connector.
+	maxConnectorsPerCluster = getEnv("MAX_CONNECTORS_PER_CLUSTER", "0")
+	maxConnectorsPerTenant  = getEnv("MAX_CONNECTORS_PER_TENANT", "0")
+	maxTasksPerConnector    = getEnv("MAX_TASKS_PER_CONNECTOR", "0")
+	maxTasksPerCluster      = getEnv("MAX_TASKS_PER_CLUSTER", "0")
+
+	connectorLimits connectorQuotas
+)
+
+// Names of the connector quotas, as reported in violations and by GET /api/{cluster}/limits.
+const (
+	limitConnectorsPerCluster = "maxConnectorsPerCluster"
+	limitConnectorsPerTenant  = "maxConnectorsPerTenant"
+	limitTasksPerConnector    = "maxTasksPerConnector"
+	limitTasksPerCluster      = "maxTasksPerCluster"
+)
+
+// connectorQuotas are the configured limits; zero means unlimited.
+type connectorQuotas struct {
+	connectorsPerCluster int
+	connectorsPerTenant  int
+	tasksPerConnector    int
+	tasksPerCluster      int
+}
+
+// loadConnectorLimits parses the MAX_CONNECTORS_* and MAX_TASKS_* variables.
+func loadConnectorLimits() (connectorQuotas, error) {
+	var quotas connectorQuotas
+	for _, setting := range []struct {
+		name, value string
+		target      *int
+	}{
+		{"MAX_CONNECTORS_PER_CLUSTER", maxConnectorsPerCluster, &quotas.connectorsPerCluster},
+		{"MAX_CONNECTORS_PER_TENANT", maxConnectorsPerTenant, &quotas.connectorsPerTenant},
+		{"MAX_TASKS_PER_CONNECTOR", maxTasksPerConnector, &quotas.tasksPerConnector},
+		{"MAX_TASKS_PER_CLUSTER", maxTasksPerCluster, &quotas.tasksPerCluster},
+	} {
+		n, err := strconv.Atoi(strings.TrimSpace(setting.value))
+		if err != nil || n < 0 {
+			return connectorQuotas{}, &configError{name: setting.name, value: setting.value}
+		}
+		*setting.target = n
+	}
+	return quotas, nil
+}
+
+func (q connectorQuotas) enabled() bool {
+	return q.connectorsPerCluster > 0 || q.connectorsPerTenant > 0 || q.tasksPerConnector > 0 || q.tasksPerCluster > 0
+}
+
+// LimitUsage is one quota and how much of it is used. Max is 0 for an unset quota.
+// Requested is set on violations to the usage the rejected change would have led to.
+type LimitUsage struct {
+	Limit     string `json:"limit"`
+	Max       int    `json:"max"`
+	Used      int    `json:"used"`
+	Requested int    `json:"requested,omitempty"`
+	Message   string `json:"message,omitempty"`
+	// Over lists the connectors whose tasks.max is above maxTasksPerConnector, e.g.
+	// after the quota was lowered.
+	Over []string `json:"over,omitempty"`
+}
+
+// ClusterLimits is returned by GET /api/{cluster}/limits.
+type ClusterLimits struct {
+	Cluster string       `json:"cluster"`
+	Limits  []LimitUsage `json:"limits"`
+}
+
+// parseTasksMax reads tasks.max, which Kafka Connect defaults to 1. Values Connect
+// rejects on its own count as 0.
+func parseTasksMax(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 1
+	}
+	tasks, err := strconv.Atoi(value)
+	if err != nil || tasks < 0 {
+		return 0
+	}
+	return tasks
+}
+
+// fetchConnectorTasks returns the tasks.max of every connector of a cluster.
+func fetchConnectorTasks(ctx context.Context, cluster string) (map[string]int, error) {
+	connectors, err := fetchExpandedConnectorStatuses(ctx, connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		return nil, err
+	}
+	tasks := make(map[string]int, len(connectors))
+	for name, connector := range connectors {
+		tasks[name] = parseTasksMax(connector.Info.Config["tasks.max"])
+	}
+	return tasks, nil
+}
+
+// tenantConnectorCount counts the connectors in scope.
+func tenantConnectorCount(scope tenantScope, cluster string, tasks map[string]int) int {
+	count := 0
+	for name := range tasks {
+		if scope.allowsConnector(cluster, name) {
+			count++
+		}
+	}
+	return count
+}
+
+// check returns the quotas a connector create or config update with the given tasks.max
+// would exceed. Cluster-wide task totals above the quota are let through as long as the
+// change does not raise them, so connectors can still be scaled down after the quota
+// was lowered.
+func (q connectorQuotas) check(scope tenantScope, cluster, name string, tasks map[string]int, requested int) []LimitUsage {
+	var violations []LimitUsage
+	current, exists := tasks[name]
+	if !exists && q.connectorsPerCluster > 0 && len(tasks) >= q.connectorsPerCluster {
+		violations = append(violations, LimitUsage{
+			Limit: limitConnectorsPerCluster, Max: q.connectorsPerCluster, Used: len(tasks), Requested: len(tasks) + 1,
+			Message: fmt.Sprintf("cluster %s already has %d connectors, the maximum is %d", cluster, len(tasks), q.connectorsPerCluster),
+		})
+	}
+	if !exists && q.connectorsPerTenant > 0 && !scope.all {
+		if used := tenantConnectorCount(scope, cluster, tasks); used >= q.connectorsPerTenant {
+			violations = append(violations, LimitUsage{
+				Limit: limitConnectorsPerTenant, Max: q.connectorsPerTenant, Used: used, Requested: used + 1,
+				Message: fmt.Sprintf("your namespaces already have %d connectors, the maximum is %d", used, q.connectorsPerTenant),
+			})
+		}
+	}
+	if requested == 0 {
+		// Kafka Connect rejects the tasks.max on its own.
+		return violations
+	}
+	if q.tasksPerConnector > 0 && requested > q.tasksPerConnector {
+		violations = append(violations, LimitUsage{
+			Limit: limitTasksPerConnector, Max: q.tasksPerConnector, Used: current, Requested: requested,
+			Message: fmt.Sprintf("tasks.max %d exceeds the maximum of %d", requested, q.tasksPerConnector),
+		})
+	}
+	if q.tasksPerCluster > 0 {
+		total := 0
+		for _, n := range tasks {
+			total += n
+		}
+		if after := total - current + requested; after > q.tasksPerCluster && after > total {
+			violations = append(violations, LimitUsage{
+				Limit: limitTasksPerCluster, Max: q.tasksPerCluster, Used: total, Requested: after,
+				Message: fmt.Sprintf("cluster %s would run up to %d tasks, the maximum is %d", cluster, after, q.tasksPerCluster),
+			})
+		}
+	}
+	return violations
+}
+
+// usage reports every quota against the current connectors of a cluster. The tenant
+// quota is only reported to callers it applies to.
+func (q connectorQuotas) usage(scope tenantScope, cluster string, tasks map[string]int) []LimitUsage {
+	total, highest := 0, 0
+	var over []string
+	for name, n := range tasks {
+		total += n
+		if !scope.allowsConnector(cluster, name) {
+			continue
+		}
+		if n > highest {
+			highest = n
+		}
+		if q.tasksPerConnector > 0 && n > q.tasksPerConnector {
+			over = append(over, name)
+		}
+	}
+	sort.Strings(over)
+
+	limits := []LimitUsage{{Limit: limitConnectorsPerCluster, Max: q.connectorsPerCluster, Used: len(tasks)}}
+	if !scope.all {
+		limits = append(limits, LimitUsage{Limit: limitConnectorsPerTenant, Max: q.connectorsPerTenant, Used: tenantConnectorCount(scope, cluster, tasks)})
+	}
+	return append(limits,
+		LimitUsage{Limit: limitTasksPerConnector, Max: q.tasksPerConnector, Used: highest, Over: over},
+		LimitUsage{Limit: limitTasksPerCluster, Max: q.tasksPerCluster, Used: total},
+	)
+}
+
+// writeLimitsFetchError answers a failed read of the connectors the quotas are counted on.
+func writeLimitsFetchError(w http.ResponseWriter, err error) {
+	var unavailable *connectUnavailableError
+	if errors.As(err, &unavailable) {
+		writeConnectUnavailable(w, err)
+		return
+	}
+	writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+}
+
+// enforceConnectorLimits rejects a connector create or config update that would exceed a
+// quota with 422 limit_exceeded. Unlike admission policies, quotas cannot be overridden.
+func enforceConnectorLimits(w http.ResponseWriter, r *http.Request, name string, config map[string]interface{}) bool {
+	quotas := connectorLimits
+	if !quotas.enabled() {
+		return true
+	}
+	cluster := mux.Vars(r)["cluster"]
+	tasks, err := fetchConnectorTasks(r.Context(), cluster)
+	if err != nil {
+		writeLimitsFetchError(w, err)
+		return false
+	}
+	requested := parseTasksMax(configString(config["tasks.max"]))
+	violations := quotas.check(tenantScopeFor(r), cluster, name, tasks, requested)
+	if len(violations) == 0 {
+		return true
+	}
+
+	limits := make([]string, len(violations))
+	for i, violation := range violations {
+		limits[i] = violation.Limit
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":      "limit_exceeded",
+		"message":    fmt.Sprintf("connector %q exceeds %s", name, strings.Join(limits, ", ")),
+		"limits":     limits,
+		"violations": violations,
+	})
+	return false
+}
+
+// connectorLimitsHandler reports the connector quotas of a cluster and their usage.
+func connectorLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	tasks, err := fetchConnectorTasks(r.Context(), cluster)
+	if err != nil {
+		writeLimitsFetchError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ClusterLimits{Cluster: cluster, Limits: connectorLimits.usage(tenantScopeFor(r), cluster, tasks)})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+func TestLoadConnectorLimits(t *testing.T) {
+	original := maxTasksPerCluster
+	t.Cleanup(func() { maxTasksPerCluster = original })
+
+	maxTasksPerCluster = "400"
+	quotas, err := loadConnectorLimits()
+	if err != nil || quotas.tasksPerCluster != 400 || !quotas.enabled() {
+		t.Fatalf("unexpected quotas %+v %v", quotas, err)
+	}
+	for _, value := range []string{"-1", "many", ""} {
+		maxTasksPerCluster = value
+		if _, err := loadConnectorLimits(); err == nil {
+			t.Fatalf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestConnectorQuotasCheck(t *testing.T) {
+	tasks := map[string]int{"orders-sink": 4, "orders-source": 2, "pay-sink": 8}
+	tenant := tenantScope{namespaces: []tenantNamespace{{prefix: "orders-"}}}
+	tests := []struct {
+		name      string
+		quotas    connectorQuotas
+		scope     tenantScope
+		connector string
+		requested int
+		want      []string
+	}{
+		{"within every quota", connectorQuotas{connectorsPerCluster: 4, connectorsPerTenant: 3, tasksPerConnector: 8, tasksPerCluster: 20}, tenant, "orders-new", 4, nil},
+		{"cluster full", connectorQuotas{connectorsPerCluster: 3}, tenantScope{all: true}, "orders-new", 1, []string{limitConnectorsPerCluster}},
+		{"cluster full but updating", connectorQuotas{connectorsPerCluster: 3}, tenantScope{all: true}, "orders-sink", 1, nil},
+		{"tenant full", connectorQuotas{connectorsPerTenant: 2}, tenant, "orders-new", 1, []string{limitConnectorsPerTenant}},
+		{"tenant quota skips admins", connectorQuotas{connectorsPerTenant: 2}, tenantScope{all: true}, "orders-new", 1, nil},
+		{"too many tasks", connectorQuotas{tasksPerConnector: 6}, tenant, "orders-sink", 7, []string{limitTasksPerConnector}},
+		{"cluster tasks exceeded", connectorQuotas{tasksPerCluster: 16}, tenant, "orders-sink", 7, []string{limitTasksPerCluster}},
+		{"scaling down over the cluster quota", connectorQuotas{tasksPerCluster: 10}, tenant, "pay-sink", 6, nil},
+		{"invalid tasks.max left to Connect", connectorQuotas{tasksPerConnector: 1, tasksPerCluster: 1}, tenant, "orders-sink", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range tt.quotas.check(tt.scope, "default", tt.connector, tasks, tt.requested) {
+				got = append(got, violation.Limit)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConnectorLimitsEnforcedAndReported(t *testing.T) {
+	withTestTrustedAuthHeaders(t)
+	original := connectorLimits
+	t.Cleanup(func() { connectorLimits = original })
+	connectorLimits = connectorQuotas{connectorsPerTenant: 2, tasksPerConnector: 3, tasksPerCluster: 10}
+	withTestTenancy(t, "orders-team=orders-", "platform")
+
+	connect := testutils.NewConnectServer(map[string]testutils.Response{
+		"GET /connectors": {Body: map[string]interface{}{
+			"orders-sink": map[string]interface{}{"info": map[string]interface{}{"config": map[string]string{"tasks.max": "4"}}, "status": map[string]interface{}{}},
+			"pay-sink":    map[string]interface{}{"info": map[string]interface{}{"config": map[string]string{}}, "status": map[string]interface{}{}},
+		}},
+	})
+	defer connect.Close()
+	originalURL := connectURL
+	t.Cleanup(func() { connectURL = originalURL })
+	connectURL = connect.URL()
+
+	admit := func(method, path, body, groups string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Forwarded-Groups", groups)
+		rr := httptest.NewRecorder()
+		if admitConnector(rr, mux.SetURLVars(req, map[string]string{"cluster": "default"})) {
+			rr.WriteHeader(http.StatusOK)
+		}
+		return rr
+	}
+
+	if rr := admit(http.MethodPost, "/api/default/connectors", `{"name": "orders-source", "config": {"tasks.max": "2"}}`, "orders-team"); rr.Code != http.StatusOK {
+		t.Fatalf("expected the create to pass, got %d %s", rr.Code, rr.Body.String())
+	}
+	rr := admit(http.MethodPut, "/api/default/connectors/orders-source/config", `{"tasks.max": "6"}`, "orders-team")
+	var rejection struct {
+		Error      string       `json:"error"`
+		Violations []LimitUsage `json:"violations"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &rejection); err != nil || rr.Code != http.StatusUnprocessableEntity || rejection.Error != "limit_exceeded" {
+		t.Fatalf("expected 422 limit_exceeded, got %d %s", rr.Code, rr.Body.String())
+	}
+	if len(rejection.Violations) != 2 || rejection.Violations[0].Limit != limitTasksPerConnector || rejection.Violations[1].Used != 5 || rejection.Violations[1].Requested != 11 {
+		t.Fatalf("expected the connector and cluster task quotas with their usage, got %+v", rejection.Violations)
+	}
+	if rr := admit(http.MethodPut, "/api/default/connectors/pay-sink/config", `{"tasks.max": "3"}`, "platform"); rr.Code != http.StatusOK {
+		t.Fatalf("expected an update within the quotas to pass, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	report := func(groups string) ClusterLimits {
+		req := httptest.NewRequest(http.MethodGet, "/api/default/limits", nil)
+		req.Header.Set("X-Forwarded-Groups", groups)
+		rr := httptest.NewRecorder()
+		connectorLimitsHandler(rr, mux.SetURLVars(req, map[string]string{"cluster": "default"}))
+		var limits ClusterLimits
+		if err := json.Unmarshal(rr.Body.Bytes(), &limits); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("unexpected limits response %d %s", rr.Code, rr.Body.String())
+		}
+		return limits
+	}
+	tenant := report("orders-team").Limits
+	if len(tenant) != 4 || tenant[1].Limit != limitConnectorsPerTenant || tenant[1].Used != 1 || tenant[2].Used != 4 || strings.Join(tenant[2].Over, ",") != "orders-sink" || tenant[3].Used != 5 {
+		t.Fatalf("unexpected tenant usage %+v", tenant)
+	}
+	if admin := report("platform").Limits; len(admin) != 3 || admin[0].Used != 2 || admin[0].Max != 0 {
+		t.Fatalf("expected no tenant quota for admins, got %+v", admin)
+	}
+}
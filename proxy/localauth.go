@@ -0,0 +1,594 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const localUsersStoreFile = "local-users.json"
+
+const (
+	auditActionCreateUser = "CREATE_USER"
+	auditActionUpdateUser = "UPDATE_USER"
+	auditActionDeleteUser = "DELETE_USER"
+
+	// minLocalPasswordLen and maxLocalPasswordLen bound local passwords; bcrypt only
+	// uses the first 72 bytes, so longer ones are refused rather than silently cut.
+	minLocalPasswordLen = 12
+	maxLocalPasswordLen = 72
+)
+
+var (
+	// Built-in users for teams without an OpenID Connect provider. Passwords are kept
+	// as bcrypt hashes in DATA_DIR; the first admin is created from
+	// LOCAL_ADMIN_USERNAME and LOCAL_ADMIN_PASSWORD while the store is empty.
+	localAuthEnabled   = getEnv("LOCAL_AUTH_ENABLED", "false")
+	localAdminUsername = getEnv("LOCAL_ADMIN_USERNAME", "admin")
+	localAdminPassword = getEnv("LOCAL_ADMIN_PASSWORD", "")
+
+	// localAuth is nil unless LOCAL_AUTH_ENABLED is true.
+	localAuth *localAuthenticator
+
+	localUsers = newLocalUserStore(time.Now)
+
+	// localBcryptCost is lowered by tests.
+	localBcryptCost = bcrypt.DefaultCost
+)
+
+// localUsernamePattern keeps usernames readable in the audit log and usable in a URL
+// segment.
+var localUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+
+var (
+	errUserNotFound    = errors.New("user not found")
+	errUserExists      = errors.New("user already exists")
+	errLastAdmin       = errors.New("at least one enabled admin must remain")
+	errLocalUsersSaved = errors.New("failed to persist local users")
+)
+
+// LocalUser is a built-in console user as returned by /api/users. The password hash is
+// never part of it.
+type LocalUser struct {
+	Username  string    `json:"username"`
+	Name      string    `json:"name,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Groups    []string  `json:"groups,omitempty"`
+	Admin     bool      `json:"admin"`
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// localUserRecord is a user as persisted. SessionVersion is bumped when the password
+// changes or the user is disabled, which ends the user's existing sessions.
+type localUserRecord struct {
+	LocalUser
+	PasswordHash   string `json:"passwordHash"`
+	SessionVersion int    `json:"sessionVersion"`
+}
+
+func (u localUserRecord) authUser(expires time.Time) AuthUser {
+	return AuthUser{
+		Username:  u.Username,
+		Subject:   "local:" + u.Username,
+		Email:     u.Email,
+		Name:      u.Name,
+		Groups:    u.Groups,
+		ExpiresAt: expires,
+	}
+}
+
+// localUserRequest is the body of POST /api/users.
+type localUserRequest struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Name     string   `json:"name,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	Admin    bool     `json:"admin,omitempty"`
+}
+
+// localUserPatch is the body of PUT /api/users/{username}; omitted fields are kept.
+// CurrentPassword is required when users change their own password.
+type localUserPatch struct {
+	Password        *string   `json:"password,omitempty"`
+	CurrentPassword string    `json:"currentPassword,omitempty"`
+	Name            *string   `json:"name,omitempty"`
+	Email           *string   `json:"email,omitempty"`
+	Groups          *[]string `json:"groups,omitempty"`
+	Admin           *bool     `json:"admin,omitempty"`
+	Disabled        *bool     `json:"disabled,omitempty"`
+}
+
+// selfService reports whether the patch only touches what users may change on their
+// own account: password, name and email.
+func (p localUserPatch) selfService() bool {
+	return p.Groups == nil && p.Admin == nil && p.Disabled == nil
+}
+
+func validateLocalPassword(password string) error {
+	if len(password) < minLocalPasswordLen || len(password) > maxLocalPasswordLen {
+		return fmt.Errorf("password must be %d to %d bytes", minLocalPasswordLen, maxLocalPasswordLen)
+	}
+	return nil
+}
+
+func hashLocalPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), localBcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// localUserStore keeps the built-in users and persists them to DATA_DIR.
+type localUserStore struct {
+	mu    sync.RWMutex
+	now   func() time.Time
+	users map[string]localUserRecord
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+func newLocalUserStore(now func() time.Time) *localUserStore {
+	return &localUserStore{now: now, users: make(map[string]localUserRecord)}
+}
+
+func (s *localUserStore) load() error {
+	users := make(map[string]localUserRecord)
+	if err := loadJSON(localUsersStoreFile, &users); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = users
+	return nil
+}
+
+// saveLocked persists the store. Callers hold s.mu.
+func (s *localUserStore) saveLocked() error {
+	if err := saveJSON(localUsersStoreFile, s.users); err != nil {
+		log.Printf("local auth: failed to persist users: %v", err)
+		return errLocalUsersSaved
+	}
+	return nil
+}
+
+// bootstrap creates an admin when the store has no users, so a fresh install can be
+// signed in to. It reports whether a user was created.
+func (s *localUserStore) bootstrap(username, password string) (bool, error) {
+	s.mu.RLock()
+	empty := len(s.users) == 0
+	s.mu.RUnlock()
+	if !empty {
+		return false, nil
+	}
+	if password == "" {
+		return false, errors.New("LOCAL_ADMIN_PASSWORD must be set to create the first local admin")
+	}
+	if _, err := s.create(localUserRequest{Username: username, Password: password, Admin: true}); err != nil {
+		return false, fmt.Errorf("create local admin %q: %w", username, err)
+	}
+	return true, nil
+}
+
+// list returns the users sorted by username.
+func (s *localUserStore) list() []LocalUser {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]LocalUser, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user.LocalUser)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
+
+func (s *localUserStore) get(username string) (localUserRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[username]
+	return user, ok
+}
+
+// isAdmin reports whether username is an enabled admin.
+func (s *localUserStore) isAdmin(username string) bool {
+	user, ok := s.get(username)
+	return ok && user.Admin && !user.Disabled
+}
+
+// authenticate checks a username and password. Unknown users are compared against a
+// dummy hash so the response time does not reveal which usernames exist.
+func (s *localUserStore) authenticate(username, password string) (localUserRecord, bool) {
+	user, ok := s.get(username)
+	if !ok {
+		s.dummyOnce.Do(func() {
+			s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("kconnect-console-dummy"), localBcryptCost)
+		})
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return localUserRecord{}, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || user.Disabled {
+		return localUserRecord{}, false
+	}
+	return user, true
+}
+
+func (s *localUserStore) create(req localUserRequest) (LocalUser, error) {
+	if !localUsernamePattern.MatchString(req.Username) {
+		return LocalUser{}, errors.New("username must be 1-64 letters, digits, '.', '_', '@' or '-'")
+	}
+	if err := validateLocalPassword(req.Password); err != nil {
+		return LocalUser{}, err
+	}
+	hash, err := hashLocalPassword(req.Password)
+	if err != nil {
+		return LocalUser{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.users[req.Username]; exists {
+		return LocalUser{}, errUserExists
+	}
+	now := s.now().UTC()
+	user := localUserRecord{
+		LocalUser: LocalUser{
+			Username:  req.Username,
+			Name:      strings.TrimSpace(req.Name),
+			Email:     strings.TrimSpace(req.Email),
+			Groups:    normalizeTags(req.Groups),
+			Admin:     req.Admin,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		PasswordHash: hash,
+	}
+	s.users[req.Username] = user
+	return user.LocalUser, s.saveLocked()
+}
+
+// update applies patch to a user. A new password or disabling the user ends the user's
+// sessions; demoting or disabling the last enabled admin is refused.
+func (s *localUserStore) update(username string, patch localUserPatch) (LocalUser, error) {
+	var hash string
+	if patch.Password != nil {
+		if err := validateLocalPassword(*patch.Password); err != nil {
+			return LocalUser{}, err
+		}
+		var err error
+		if hash, err = hashLocalPassword(*patch.Password); err != nil {
+			return LocalUser{}, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[username]
+	if !ok {
+		return LocalUser{}, errUserNotFound
+	}
+	if patch.Name != nil {
+		user.Name = strings.TrimSpace(*patch.Name)
+	}
+	if patch.Email != nil {
+		user.Email = strings.TrimSpace(*patch.Email)
+	}
+	if patch.Groups != nil {
+		user.Groups = normalizeTags(*patch.Groups)
+	}
+	if patch.Admin != nil {
+		user.Admin = *patch.Admin
+	}
+	if patch.Disabled != nil {
+		if *patch.Disabled && !user.Disabled {
+			user.SessionVersion++
+		}
+		user.Disabled = *patch.Disabled
+	}
+	if hash != "" {
+		user.PasswordHash = hash
+		user.SessionVersion++
+	}
+	if s.removesLastAdminLocked(username, user.Admin && !user.Disabled) {
+		return LocalUser{}, errLastAdmin
+	}
+	user.UpdatedAt = s.now().UTC()
+	s.users[username] = user
+	return user.LocalUser, s.saveLocked()
+}
+
+func (s *localUserStore) delete(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[username]; !ok {
+		return errUserNotFound
+	}
+	if s.removesLastAdminLocked(username, false) {
+		return errLastAdmin
+	}
+	delete(s.users, username)
+	return s.saveLocked()
+}
+
+// removesLastAdminLocked reports whether username stopping to be an enabled admin
+// (stillAdmin false) would leave no enabled admin. Callers hold s.mu.
+func (s *localUserStore) removesLastAdminLocked(username string, stillAdmin bool) bool {
+	if stillAdmin {
+		return false
+	}
+	for name, user := range s.users {
+		if name != username && user.Admin && !user.Disabled {
+			return false
+		}
+	}
+	current := s.users[username]
+	return current.Admin && !current.Disabled
+}
+
+// localAuthenticator signs users of the local store in with a password and keeps them
+// in the same HMAC-signed session cookie as OIDC logins.
+type localAuthenticator struct {
+	users  *localUserStore
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// localSession is the session cookie payload. Version must match the user's
+// SessionVersion for the session to stay valid.
+type localSession struct {
+	AuthUser
+	Version int `json:"version"`
+}
+
+// loadLocalAuth reads LOCAL_AUTH_ENABLED and the SESSION_* settings. It returns nil when
+// local users are disabled.
+func loadLocalAuth() (*localAuthenticator, error) {
+	enabled, err := strconv.ParseBool(strings.TrimSpace(localAuthEnabled))
+	if err != nil {
+		return nil, &configError{name: "LOCAL_AUTH_ENABLED", value: localAuthEnabled}
+	}
+	if !enabled {
+		return nil, nil
+	}
+	if strings.TrimSpace(oidcIssuerURL) != "" {
+		return nil, errors.New("LOCAL_AUTH_ENABLED and OIDC_ISSUER_URL cannot be combined")
+	}
+	if !localUsernamePattern.MatchString(localAdminUsername) {
+		return nil, &configError{name: "LOCAL_ADMIN_USERNAME", value: localAdminUsername}
+	}
+	if localAdminPassword != "" {
+		if err := validateLocalPassword(localAdminPassword); err != nil {
+			return nil, fmt.Errorf("LOCAL_ADMIN_PASSWORD: %w", err)
+		}
+	}
+	if len(sessionSecret) < minSessionSecretLen {
+		return nil, fmt.Errorf("SESSION_SECRET must be at least %d characters when local users are enabled", minSessionSecretLen)
+	}
+	ttl, err := parseWindow(sessionTTL, 8*time.Hour)
+	if err != nil {
+		return nil, &configError{name: "SESSION_TTL", value: sessionTTL}
+	}
+	return &localAuthenticator{users: localUsers, secret: []byte(sessionSecret), ttl: ttl, now: time.Now}, nil
+}
+
+// secureRequest reports whether the browser reached the console over HTTPS, directly or
+// through a TLS-terminating proxy.
+func secureRequest(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// setSession issues a session cookie for user and returns the signed-in profile.
+func (a *localAuthenticator) setSession(w http.ResponseWriter, r *http.Request, user localUserRecord) (AuthUser, error) {
+	expires := a.now().Add(a.ttl).UTC()
+	value, err := signValue(a.secret, signedSession, localSession{AuthUser: user.authUser(expires), Version: user.SessionVersion})
+	if err != nil {
+		return AuthUser{}, err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(a.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return user.authUser(expires), nil
+}
+
+func (a *localAuthenticator) clearCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: secureRequest(r), SameSite: http.SameSiteLaxMode})
+}
+
+// session returns the user of a valid, unexpired session cookie whose user still exists,
+// is enabled and has not changed password since signing in. The profile is read from
+// the store, so edits to name, email and groups apply immediately.
+func (a *localAuthenticator) session(r *http.Request) (AuthUser, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return AuthUser{}, false
+	}
+	var session localSession
+	if err := verifyValue(a.secret, signedSession, cookie.Value, &session); err != nil || !a.now().Before(session.ExpiresAt) {
+		return AuthUser{}, false
+	}
+	user, ok := a.users.get(session.Username)
+	if !ok || user.Disabled || user.SessionVersion != session.Version {
+		return AuthUser{}, false
+	}
+	return user.authUser(session.ExpiresAt), true
+}
+
+// localLoginHandler signs a local user in with the username and password in the body.
+func localLoginHandler(w http.ResponseWriter, r *http.Request) {
+	auth := localAuth
+	if auth == nil {
+		writeJSONError(w, http.StatusNotFound, "auth_disabled", "local login is not enabled (set LOCAL_AUTH_ENABLED)")
+		return
+	}
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON object with username and password")
+		return
+	}
+	user, ok := auth.users.authenticate(credentials.Username, credentials.Password)
+	if !ok {
+		log.Printf("local auth: failed login for %q from %s", credentials.Username, extractClientIP(r))
+		writeJSONError(w, http.StatusUnauthorized, "invalid_credentials", "invalid username or password")
+		return
+	}
+	profile, err := auth.setSession(w, r, user)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "login_failed", "failed to create the session")
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// localUsersEnabled answers 404 when local users are off.
+func localUsersEnabled(w http.ResponseWriter) bool {
+	if localAuth == nil {
+		writeJSONError(w, http.StatusNotFound, "local_auth_disabled", "local users are not enabled (set LOCAL_AUTH_ENABLED)")
+		return false
+	}
+	return true
+}
+
+func writeLocalUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUserNotFound):
+		writeJSONError(w, http.StatusNotFound, "user_not_found", err.Error())
+	case errors.Is(err, errUserExists):
+		writeJSONError(w, http.StatusConflict, "user_exists", err.Error())
+	case errors.Is(err, errLastAdmin):
+		writeJSONError(w, http.StatusConflict, "last_admin", err.Error())
+	case errors.Is(err, errLocalUsersSaved):
+		writeJSONError(w, http.StatusInternalServerError, "user_store_failed", err.Error())
+	default:
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+}
+
+// localUsersHandler serves GET and POST /api/users for admins.
+func localUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if !localUsersEnabled(w) {
+		return
+	}
+	if !localAuth.users.isAdmin(requestUser(r)) {
+		writeJSONError(w, http.StatusForbidden, "forbidden", "only admins can manage users")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"users": localAuth.users.list()})
+		return
+	}
+
+	var req localUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON user object")
+		return
+	}
+	user, err := localAuth.users.create(req)
+	if err != nil {
+		writeLocalUserError(w, err)
+		return
+	}
+	recordAudit(r, auditActionCreateUser, "", http.StatusCreated, map[string]interface{}{"username": user.Username, "admin": user.Admin})
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// localUserHandler serves GET, PUT and DELETE /api/users/{username}. Admins may do all
+// three; other users may read their own account and change its password, name and
+// email after confirming the current password.
+func localUserHandler(w http.ResponseWriter, r *http.Request) {
+	if !localUsersEnabled(w) {
+		return
+	}
+	username, caller := mux.Vars(r)["username"], requestUser(r)
+	admin, self := localAuth.users.isAdmin(caller), caller == username
+
+	switch r.Method {
+	case http.MethodGet:
+		if !admin && !self {
+			writeJSONError(w, http.StatusForbidden, "forbidden", "only admins can read other users")
+			return
+		}
+		user, ok := localAuth.users.get(username)
+		if !ok {
+			writeLocalUserError(w, errUserNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, user.LocalUser)
+
+	case http.MethodPut:
+		var patch localUserPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON user object")
+			return
+		}
+		if !admin {
+			if !self || !patch.selfService() {
+				writeJSONError(w, http.StatusForbidden, "forbidden", "only admins can change groups, admin or disabled, or other users")
+				return
+			}
+			if patch.Password != nil {
+				if _, ok := localAuth.users.authenticate(username, patch.CurrentPassword); !ok {
+					writeJSONError(w, http.StatusForbidden, "invalid_credentials", "currentPassword is incorrect")
+					return
+				}
+			}
+		}
+		user, err := localAuth.users.update(username, patch)
+		if err != nil {
+			writeLocalUserError(w, err)
+			return
+		}
+		recordAudit(r, auditActionUpdateUser, "", http.StatusOK, map[string]interface{}{
+			"username":        username,
+			"passwordChanged": patch.Password != nil,
+			"admin":           user.Admin,
+			"disabled":        user.Disabled,
+		})
+		if self && patch.Password != nil {
+			// The new password ended the caller's session; keep them signed in.
+			if record, ok := localAuth.users.get(username); ok {
+				localAuth.setSession(w, r, record)
+			}
+		}
+		writeJSON(w, http.StatusOK, user)
+
+	case http.MethodDelete:
+		if !admin {
+			writeJSONError(w, http.StatusForbidden, "forbidden", "only admins can delete users")
+			return
+		}
+		if err := localAuth.users.delete(username); err != nil {
+			writeLocalUserError(w, err)
+			return
+		}
+		recordAudit(r, auditActionDeleteUser, "", http.StatusNoContent, map[string]interface{}{"username": username})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testAdminPassword = "correct horse battery"
+
+// withTestLocalAuth enables local users with an empty store and bootstraps "admin".
+func withTestLocalAuth(t *testing.T) *localAuthenticator {
+	t.Helper()
+	originalAuth, originalUsers, originalCost := localAuth, localUsers, localBcryptCost
+	t.Cleanup(func() { localAuth, localUsers, localBcryptCost = originalAuth, originalUsers, originalCost })
+
+	localBcryptCost = bcrypt.MinCost
+	localUsers = newLocalUserStore(time.Now)
+	localAuth = &localAuthenticator{users: localUsers, secret: []byte(strings.Repeat("k", minSessionSecretLen)), ttl: time.Hour, now: time.Now}
+	if created, err := localUsers.bootstrap("admin", testAdminPassword); err != nil || !created {
+		t.Fatalf("expected the admin to be created, got %v %v", created, err)
+	}
+	return localAuth
+}
+
+// asUser attaches a signed-in user to req, as authMiddleware does.
+func asUser(req *http.Request, username string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), authUserKey{}, AuthUser{Username: username}))
+}
+
+func localLogin(t *testing.T, username, password string) (*httptest.ResponseRecorder, *http.Cookie) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	body := `{"username": "` + username + `", "password": "` + password + `"}`
+	localLoginHandler(rr, httptest.NewRequest(http.MethodPost, "/auth/local/login", strings.NewReader(body)))
+	return rr, cookieNamed(rr.Result().Cookies(), sessionCookieName)
+}
+
+func TestLocalLoginAndSession(t *testing.T) {
+	withTestLocalAuth(t)
+
+	for _, creds := range [][2]string{{"admin", "wrong password!"}, {"nobody", testAdminPassword}} {
+		if rr, cookie := localLogin(t, creds[0], creds[1]); rr.Code != http.StatusUnauthorized || cookie != nil {
+			t.Fatalf("%s: expected 401 without a cookie, got %d", creds[0], rr.Code)
+		}
+	}
+
+	rr, cookie := localLogin(t, "admin", testAdminPassword)
+	if rr.Code != http.StatusOK || cookie == nil || !cookie.HttpOnly {
+		t.Fatalf("expected a session cookie, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	me := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		authMiddleware(http.HandlerFunc(localUsersHandler)).ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := me(); code != http.StatusOK {
+		t.Fatalf("expected the session to list users, got %d", code)
+	}
+
+	if _, err := localUsers.create(localUserRequest{Username: "backup", Password: testAdminPassword, Admin: true}); err != nil {
+		t.Fatal(err)
+	}
+	disabled := true
+	if _, err := localUsers.update("admin", localUserPatch{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+	if code := me(); code != http.StatusUnauthorized {
+		t.Fatalf("expected disabling the user to end the session, got %d", code)
+	}
+	if rr, _ := localLogin(t, "admin", testAdminPassword); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected disabled users to be refused, got %d", rr.Code)
+	}
+}
+
+func TestLocalUsersHandler(t *testing.T) {
+	withTestLocalAuth(t)
+	logger := withTestAuditLog(t, 10)
+
+	call := func(user, method, path, body string) *httptest.ResponseRecorder {
+		req := asUser(httptest.NewRequest(method, path, strings.NewReader(body)), user)
+		rr := httptest.NewRecorder()
+		if name := strings.TrimPrefix(path, "/api/users/"); name != path {
+			localUserHandler(rr, mux.SetURLVars(req, map[string]string{"username": name}))
+		} else {
+			localUsersHandler(rr, req)
+		}
+		return rr
+	}
+
+	rr := call("admin", http.MethodPost, "/api/users", `{"username": "alice", "password": "alice-password-1", "groups": ["payments"]}`)
+	if rr.Code != http.StatusCreated || strings.Contains(rr.Body.String(), "passwordHash") {
+		t.Fatalf("expected alice to be created without exposing the hash, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := call("admin", http.MethodPost, "/api/users", `{"username": "alice", "password": "alice-password-1"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected a duplicate user to conflict, got %d", rr.Code)
+	}
+	if rr := call("admin", http.MethodPost, "/api/users", `{"username": "bob", "password": "short"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a short password to be refused, got %d", rr.Code)
+	}
+
+	if rr := call("alice", http.MethodGet, "/api/users", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected non-admins to be refused, got %d", rr.Code)
+	}
+	if rr := call("alice", http.MethodGet, "/api/users/admin", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected non-admins to be refused other users, got %d", rr.Code)
+	}
+	if rr := call("alice", http.MethodPut, "/api/users/alice", `{"admin": true}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected users not to promote themselves, got %d", rr.Code)
+	}
+	if rr := call("alice", http.MethodPut, "/api/users/alice", `{"password": "new-alice-password", "currentPassword": "wrong"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected the current password to be checked, got %d", rr.Code)
+	}
+	rr = call("alice", http.MethodPut, "/api/users/alice", `{"password": "new-alice-password", "currentPassword": "alice-password-1"}`)
+	if rr.Code != http.StatusOK || cookieNamed(rr.Result().Cookies(), sessionCookieName) == nil {
+		t.Fatalf("expected the password change to renew the session, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, ok := localUsers.authenticate("alice", "new-alice-password"); !ok {
+		t.Fatal("expected the new password to work")
+	}
+
+	if rr := call("admin", http.MethodPut, "/api/users/admin", `{"admin": false}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected the last admin not to be demoted, got %d", rr.Code)
+	}
+	if rr := call("admin", http.MethodDelete, "/api/users/admin", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected the last admin not to be deleted, got %d", rr.Code)
+	}
+	if rr := call("admin", http.MethodDelete, "/api/users/alice", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected alice to be deleted, got %d", rr.Code)
+	}
+
+	for action, want := range map[string]int{auditActionCreateUser: 1, auditActionUpdateUser: 1, auditActionDeleteUser: 1} {
+		entries := logger.Query(AuditFilter{Action: action})
+		if len(entries) != want {
+			t.Fatalf("expected %d %s entries, got %+v", want, action, entries)
+		}
+		if entries[0].Details["username"] != "alice" || strings.Contains(entries[0].User, "anonymous") {
+			t.Fatalf("unexpected %s entry %+v", action, entries[0])
+		}
+	}
+}
+
+func TestLoadLocalAuth(t *testing.T) {
+	originals := []string{localAuthEnabled, localAdminPassword, sessionSecret, oidcIssuerURL}
+	t.Cleanup(func() {
+		localAuthEnabled, localAdminPassword, sessionSecret, oidcIssuerURL = originals[0], originals[1], originals[2], originals[3]
+	})
+
+	localAuthEnabled, oidcIssuerURL = "false", ""
+	if auth, err := loadLocalAuth(); auth != nil || err != nil {
+		t.Fatalf("expected local users to be off, got %+v %v", auth, err)
+	}
+
+	localAuthEnabled, localAdminPassword, sessionSecret = "true", testAdminPassword, strings.Repeat("k", minSessionSecretLen)
+	if auth, err := loadLocalAuth(); auth == nil || err != nil {
+		t.Fatalf("expected local users to be on, got %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		change func()
+		want   string
+	}{
+		{"invalid flag", func() { localAuthEnabled = "yes please" }, "LOCAL_AUTH_ENABLED"},
+		{"with OIDC", func() { oidcIssuerURL = "https://login.example.com" }, "cannot be combined"},
+		{"short secret", func() { sessionSecret = "short" }, "SESSION_SECRET"},
+		{"short admin password", func() { localAdminPassword = "short" }, "LOCAL_ADMIN_PASSWORD"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			localAuthEnabled, localAdminPassword, sessionSecret, oidcIssuerURL = "true", testAdminPassword, strings.Repeat("k", minSessionSecretLen), ""
+			tt.change()
+			if _, err := loadLocalAuth(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	loggerRevertsFile = "logger-reverts.json"
+
+	auditActionSetLogLevel = "SET_LOG_LEVEL"
+
+	// loggerRevertUser is recorded in the audit log for levels restored automatically.
+	loggerRevertUser = "logger-revert"
+
+	maxLoggerRevertAfter = 24 * time.Hour
+)
+
+// logLevels are the log4j levels Kafka Connect accepts, from least to most verbose.
+var logLevels = []string{"OFF", "FATAL", "ERROR", "WARN", "INFO", "DEBUG", "TRACE"}
+
+var loggerReverts = newLoggerRevertStore(time.Now)
+
+// LoggerLevel is one logger of a worker. LastModified is the epoch milliseconds of the
+// last change, reported by Kafka Connect 3.6+ once a level was set.
+type LoggerLevel struct {
+	Name         string        `json:"name"`
+	Level        string        `json:"level"`
+	LastModified *int64        `json:"lastModified,omitempty"`
+	Revert       *LoggerRevert `json:"revert,omitempty"`
+}
+
+// LoggerList is returned by GET /api/{cluster}/admin/loggers. Levels lists the values a
+// level may be set to.
+type LoggerList struct {
+	Loggers []LoggerLevel `json:"loggers"`
+	Levels  []string      `json:"levels"`
+}
+
+// LoggerLevelRequest is the body of PUT /api/{cluster}/admin/loggers/{logger}.
+// RevertAfter, such as "30m", restores the previous level once it has elapsed.
+type LoggerLevelRequest struct {
+	Level       string `json:"level"`
+	RevertAfter string `json:"revertAfter,omitempty"`
+}
+
+// LoggerRevert is a pending restore of a logger's level. Level is the level in place
+// before the first change, and Scope the Kafka Connect scope the change was made in.
+type LoggerRevert struct {
+	Cluster   string    `json:"cluster"`
+	Logger    string    `json:"logger"`
+	Level     string    `json:"level"`
+	Scope     string    `json:"scope,omitempty"`
+	RevertAt  time.Time `json:"revertAt"`
+	SetBy     string    `json:"setBy,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// LoggerLevelChange is the response to a level change. Modified lists the loggers Kafka
+// Connect changed; it is empty for cluster-scoped changes, which Connect applies
+// asynchronously.
+type LoggerLevelChange struct {
+	Logger        string        `json:"logger"`
+	Level         string        `json:"level"`
+	PreviousLevel string        `json:"previousLevel"`
+	Modified      []string      `json:"modified"`
+	Revert        *LoggerRevert `json:"revert,omitempty"`
+}
+
+type connectLogger struct {
+	Level        string `json:"level"`
+	LastModified *int64 `json:"last_modified"`
+}
+
+func fetchLoggers(ctx context.Context, client *http.Client, baseURL string) (map[string]connectLogger, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(baseURL, "admin", "loggers"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching loggers: %d", resp.StatusCode)
+	}
+
+	var loggers map[string]connectLogger
+	if err := json.NewDecoder(resp.Body).Decode(&loggers); err != nil {
+		return nil, fmt.Errorf("decode loggers: %w", err)
+	}
+	return loggers, nil
+}
+
+// setLoggerLevel changes the level of logger and returns the loggers Kafka Connect
+// reports as modified.
+func setLoggerLevel(ctx context.Context, client *http.Client, baseURL, logger, level, scope string) ([]string, error) {
+	target := joinURL(baseURL, "admin", "loggers", url.PathEscape(logger))
+	if scope != "" {
+		target += "?" + url.Values{"scope": {scope}}.Encode()
+	}
+	body, err := json.Marshal(map[string]string{"level": level})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("PUT %s: HTTP %d: %s", target, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	modified := []string{}
+	if resp.StatusCode == http.StatusNoContent {
+		return modified, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&modified); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decode modified loggers: %w", err)
+	}
+	return modified, nil
+}
+
+// effectiveLevel returns the level of logger, or of the root logger it inherits from
+// when Kafka Connect does not list it.
+func effectiveLevel(loggers map[string]connectLogger, logger string) string {
+	if current, ok := loggers[logger]; ok {
+		return current.Level
+	}
+	return loggers["root"].Level
+}
+
+// loggerRevertStore keeps the pending level restores, persisted in DATA_DIR so that
+// debug logging switched on before a restart is still switched off.
+type loggerRevertStore struct {
+	mu      sync.Mutex
+	reverts []LoggerRevert
+	now     func() time.Time
+	client  *http.Client
+}
+
+func newLoggerRevertStore(now func() time.Time) *loggerRevertStore {
+	return &loggerRevertStore{reverts: []LoggerRevert{}, now: now, client: newConnectClient(30 * time.Second)}
+}
+
+func (s *loggerRevertStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return loadJSON(loggerRevertsFile, &s.reverts)
+}
+
+// pending returns the pending restores of cluster by logger.
+func (s *loggerRevertStore) pending(cluster string) map[string]LoggerRevert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make(map[string]LoggerRevert)
+	for _, revert := range s.reverts {
+		if revert.Cluster == cluster {
+			pending[revert.Logger] = revert
+		}
+	}
+	return pending
+}
+
+// schedule records a restore of revert.Logger. When one is already pending its level is
+// kept, so that raising DEBUG to TRACE still reverts to the level before DEBUG.
+func (s *loggerRevertStore) schedule(revert LoggerRevert) (LoggerRevert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.reverts {
+		if existing.Cluster == revert.Cluster && existing.Logger == revert.Logger {
+			revert.Level = existing.Level
+			s.reverts[i] = revert
+			return revert, saveJSON(loggerRevertsFile, s.reverts)
+		}
+	}
+	s.reverts = append(s.reverts, revert)
+	return revert, saveJSON(loggerRevertsFile, s.reverts)
+}
+
+// cancel drops the pending restore of logger, if any.
+func (s *loggerRevertStore) cancel(cluster, logger string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.reverts {
+		if existing.Cluster == cluster && existing.Logger == logger {
+			s.reverts = append(s.reverts[:i], s.reverts[i+1:]...)
+			return saveJSON(loggerRevertsFile, s.reverts)
+		}
+	}
+	return nil
+}
+
+// tick restores every level that is due. Failed restores stay pending and are retried
+// on the next tick; a level changed again in the meantime is left to its new restore.
+func (s *loggerRevertStore) tick(ctx context.Context) {
+	now := s.now()
+	s.mu.Lock()
+	var due []LoggerRevert
+	for _, revert := range s.reverts {
+		if !revert.RevertAt.After(now) {
+			due = append(due, revert)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, revert := range due {
+		_, err := setLoggerLevel(ctx, s.client, connectURLFor(revert.Cluster), revert.Logger, revert.Level, revert.Scope)
+		s.audit(revert, err)
+
+		s.mu.Lock()
+		for i, existing := range s.reverts {
+			if existing.Cluster != revert.Cluster || existing.Logger != revert.Logger || !existing.RevertAt.Equal(revert.RevertAt) {
+				continue
+			}
+			if err != nil {
+				s.reverts[i].LastError = err.Error()
+			} else {
+				s.reverts = append(s.reverts[:i], s.reverts[i+1:]...)
+			}
+			break
+		}
+		if err := saveJSON(loggerRevertsFile, s.reverts); err != nil {
+			log.Printf("loggers: failed to persist pending reverts: %v", err)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *loggerRevertStore) audit(revert LoggerRevert, err error) {
+	entry := AuditLogEntry{
+		Timestamp:  s.now().UTC(),
+		User:       loggerRevertUser,
+		Cluster:    revert.Cluster,
+		Action:     auditActionSetLogLevel,
+		TargetType: auditTargetCluster,
+		Status:     auditStatusSuccess,
+		HTTPStatus: http.StatusOK,
+		Details:    map[string]interface{}{"automatic": true, "logger": revert.Logger, "level": revert.Level, "setBy": revert.SetBy},
+	}
+	if err != nil {
+		entry.Status, entry.HTTPStatus = auditStatusFailure, http.StatusBadGateway
+		entry.Details["error"] = err.Error()
+		log.Printf("loggers: failed to restore %s on %s to %s: %v", revert.Logger, revert.Cluster, revert.Level, err)
+	}
+	logAudit(entry)
+}
+
+func (s *loggerRevertStore) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		s.tick(ctx)
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// loggersHandler lists the loggers of a worker with their levels and pending restores.
+func loggersHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	loggers, err := fetchLoggers(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "loggers_fetch_failed", err.Error())
+		return
+	}
+
+	pending := loggerReverts.pending(cluster)
+	list := LoggerList{Loggers: make([]LoggerLevel, 0, len(loggers)), Levels: logLevels}
+	for name, logger := range loggers {
+		entry := LoggerLevel{Name: name, Level: logger.Level, LastModified: logger.LastModified}
+		if revert, ok := pending[name]; ok {
+			entry.Revert = &revert
+		}
+		list.Loggers = append(list.Loggers, entry)
+	}
+	sort.Slice(list.Loggers, func(i, j int) bool { return list.Loggers[i].Name < list.Loggers[j].Name })
+	writeJSON(w, http.StatusOK, list)
+}
+
+// loggerLevelHandler sets the level of a logger and, with revertAfter, schedules the
+// previous level to be restored. A change without revertAfter cancels a pending restore.
+// ?scope=cluster applies the change to every worker (Kafka Connect 3.7+).
+func loggerLevelHandler(w http.ResponseWriter, r *http.Request) {
+	cluster, logger := mux.Vars(r)["cluster"], mux.Vars(r)["logger"]
+
+	scope := r.URL.Query().Get("scope")
+	if scope != "" && scope != "worker" && scope != "cluster" {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", fmt.Sprintf("scope must be worker or cluster, got %q", scope))
+		return
+	}
+
+	var req LoggerLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "body must be a JSON object with a level")
+		return
+	}
+	level := strings.ToUpper(strings.TrimSpace(req.Level))
+	valid := false
+	for _, allowed := range logLevels {
+		valid = valid || level == allowed
+	}
+	if !valid {
+		writeJSONError(w, http.StatusBadRequest, "invalid_level", fmt.Sprintf("level must be one of %s, got %q", strings.Join(logLevels, ", "), req.Level))
+		return
+	}
+	var revertAfter time.Duration
+	if req.RevertAfter != "" {
+		parsed, err := parseWindow(req.RevertAfter, 0)
+		if err != nil || parsed > maxLoggerRevertAfter {
+			writeJSONError(w, http.StatusBadRequest, "invalid_revert", fmt.Sprintf("revertAfter must be a duration up to %s, got %q", maxLoggerRevertAfter, req.RevertAfter))
+			return
+		}
+		revertAfter = parsed
+	}
+
+	baseURL := connectURLFor(cluster)
+	loggers, err := fetchLoggers(r.Context(), connectClientFor(cluster, routeRead), baseURL)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "loggers_fetch_failed", err.Error())
+		return
+	}
+	change := LoggerLevelChange{Logger: logger, Level: level, PreviousLevel: effectiveLevel(loggers, logger)}
+
+	details := map[string]interface{}{"logger": logger, "level": level, "previousLevel": change.PreviousLevel}
+	if scope != "" {
+		details["scope"] = scope
+	}
+	if revertAfter > 0 {
+		details["revertAfter"] = req.RevertAfter
+	}
+	modified, err := setLoggerLevel(r.Context(), connectClientFor(cluster, routeWrite), baseURL, logger, level, scope)
+	if err != nil {
+		details["error"] = err.Error()
+		recordAudit(r, auditActionSetLogLevel, "", http.StatusBadGateway, details)
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "set_level_failed", err.Error())
+		return
+	}
+	change.Modified = modified
+
+	if revertAfter > 0 {
+		revert, err := loggerReverts.schedule(LoggerRevert{
+			Cluster:  cluster,
+			Logger:   logger,
+			Level:    change.PreviousLevel,
+			Scope:    scope,
+			RevertAt: loggerReverts.now().Add(revertAfter).UTC(),
+			SetBy:    requestUser(r),
+		})
+		if err != nil {
+			log.Printf("loggers: failed to persist the revert of %s on %s: %v", logger, cluster, err)
+		}
+		change.Revert = &revert
+		details["revertAt"] = revert.RevertAt
+		details["revertTo"] = revert.Level
+	} else if err := loggerReverts.cancel(cluster, logger); err != nil {
+		log.Printf("loggers: failed to persist the cancelled revert of %s on %s: %v", logger, cluster, err)
+	}
+
+	recordAudit(r, auditActionSetLogLevel, "", http.StatusOK, details)
+	writeJSON(w, http.StatusOK, change)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestLoggerLevelHandlerRevertsAfterDelay(t *testing.T) {
+	withTestTrustedAuthHeaders(t)
+	originalStore, originalDir := loggerReverts, dataDir
+	t.Cleanup(func() { loggerReverts, dataDir = originalStore, originalDir })
+	dataDir = t.TempDir()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	loggerReverts = newLoggerRevertStore(func() time.Time { return now })
+	logger := withTestAuditLog(t, 10)
+
+	var mu sync.Mutex
+	levels := map[string]string{"root": "INFO", "org.apache.kafka.connect": "WARN"}
+	var scopes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/admin/loggers":
+			loggers := map[string]interface{}{}
+			for name, level := range levels {
+				loggers[name] = map[string]string{"level": level}
+			}
+			json.NewEncoder(w).Encode(loggers)
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/admin/loggers/"):
+			name := strings.TrimPrefix(r.URL.Path, "/admin/loggers/")
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			levels[name] = body["level"]
+			scopes = append(scopes, r.URL.Query().Get("scope"))
+			if r.URL.Query().Get("scope") == "cluster" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			json.NewEncoder(w).Encode([]string{name})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	put := func(name, query, body string) (*httptest.ResponseRecorder, LoggerLevelChange) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, "/api/default/admin/loggers/"+name+query, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "logger": name})
+		req.Header.Set("X-Forwarded-User", "alice")
+		rr := httptest.NewRecorder()
+		loggerLevelHandler(rr, req)
+		var change LoggerLevelChange
+		json.Unmarshal(rr.Body.Bytes(), &change)
+		return rr, change
+	}
+
+	for _, invalid := range []struct{ query, body string }{
+		{"", `{"level": "VERBOSE"}`},
+		{"?scope=all", `{"level": "DEBUG"}`},
+		{"", `{"level": "DEBUG", "revertAfter": "2d"}`},
+		{"", `not json`},
+		{"?scope=worker", `{"level": "DEBUG", "revertAfter": "-5m"}`},
+	} {
+		if rr, _ := put("org.apache.kafka.connect", invalid.query, invalid.body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected 400, got %d", invalid.query, invalid.body, rr.Code)
+		}
+	}
+
+	rr, change := put("org.apache.kafka.connect", "", `{"level": "debug", "revertAfter": "30m"}`)
+	if rr.Code != http.StatusOK || change.Level != "DEBUG" || change.PreviousLevel != "WARN" || change.Revert == nil || !change.Revert.RevertAt.Equal(now.Add(30*time.Minute)) {
+		t.Fatalf("unexpected change %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Raising the level again keeps the level to restore; an unlisted logger inherits root.
+	now = now.Add(10 * time.Minute)
+	if _, change := put("org.apache.kafka.connect", "", `{"level": "TRACE", "revertAfter": "30m"}`); change.Revert == nil || change.Revert.Level != "WARN" {
+		t.Fatalf("expected the revert to keep the original level, got %+v", change.Revert)
+	}
+	if rr, change := put("io.debezium", "?scope=cluster", `{"level": "DEBUG", "revertAfter": "1h"}`); rr.Code != http.StatusOK || change.PreviousLevel != "INFO" || len(change.Modified) != 0 {
+		t.Fatalf("unexpected cluster-scoped change %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/admin/loggers", nil), map[string]string{"cluster": "default"})
+	listed := httptest.NewRecorder()
+	loggersHandler(listed, req)
+	var list LoggerList
+	json.Unmarshal(listed.Body.Bytes(), &list)
+	if len(list.Levels) != len(logLevels) || len(list.Loggers) != 3 || list.Loggers[1].Name != "org.apache.kafka.connect" || list.Loggers[1].Revert == nil {
+		t.Fatalf("unexpected logger list %s", listed.Body.String())
+	}
+
+	loggerReverts.tick(context.Background())
+	if levels["org.apache.kafka.connect"] != "TRACE" {
+		t.Fatal("expected no revert before it is due")
+	}
+
+	reloaded := newLoggerRevertStore(time.Now)
+	if err := reloaded.load(); err != nil || len(reloaded.pending("default")) != 2 {
+		t.Fatalf("expected the pending reverts to be persisted, got %v (%v)", reloaded.pending("default"), err)
+	}
+
+	now = now.Add(31 * time.Minute)
+	loggerReverts.tick(context.Background())
+	if levels["org.apache.kafka.connect"] != "WARN" || levels["io.debezium"] != "DEBUG" {
+		t.Fatalf("expected only the due level to be restored, got %v", levels)
+	}
+	now = now.Add(time.Hour)
+	loggerReverts.tick(context.Background())
+	if levels["io.debezium"] != "INFO" || scopes[len(scopes)-1] != "cluster" || len(loggerReverts.pending("default")) != 0 {
+		t.Fatalf("expected the cluster-scoped level to be restored in the same scope, got %v %v", levels, scopes)
+	}
+
+	var users []string
+	for _, entry := range logger.Query(AuditFilter{Action: auditActionSetLogLevel}) {
+		users = append(users, entry.User)
+	}
+	if strings.Join(users, ",") != "logger-revert,logger-revert,alice,alice,alice" {
+		t.Fatalf("expected the changes and reverts to be audited, got %v", users)
+	}
+
+	// A change without revertAfter cancels the pending revert.
+	put("org.apache.kafka.connect", "", `{"level": "DEBUG", "revertAfter": "5m"}`)
+	put("org.apache.kafka.connect", "", `{"level": "ERROR"}`)
+	if len(loggerReverts.pending("default")) != 0 {
+		t.Fatalf("expected the explicit level to cancel the revert, got %v", loggerReverts.pending("default"))
+	}
+}
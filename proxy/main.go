@@ -10,7 +10,6 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,12 +17,14 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mcnabb998/kconnect-console/proxy/internal/connectclient"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/otel"
 )
 
 var (
-	connectURL     = getEnv("KAFKA_CONNECT_URL", "http://localhost:8083")
-	allowedOrigins = getEnv("ALLOWED_ORIGINS", "*")
+	connectURL = getEnv("KAFKA_CONNECT_URL", "http://localhost:8083")
+	listenPort = getEnv("PORT", "8080")
 	// Only redact true secret-like keys (including camelCase variants); avoid generic "key.converter"
 	sensitivePattern = regexp.MustCompile(`(?i)(?:^|[._-]|[a-z0-9])(password|secret|api[._-]?key|access[._-]?key|secret[._-]?key|token|credential(s)?)(?:$|[._-]|[a-z0-9])`)
 	safeExactKeys    = map[string]struct{}{
@@ -32,17 +33,19 @@ var (
 		"internal.key.converter":   {},
 		"internal.value.converter": {},
 	}
-	monitoringHTTPClient   = &http.Client{}
-	summaryCacheTTL        = 10 * time.Second
-	monitoringSummaryCache = struct {
-		sync.Mutex
-		data      MonitoringSummary
-		expiresAt time.Time
-		valid     bool
-		fetching  bool // Prevents thundering herd
-	}{}
+	monitoringHTTPClient   = newConnectClient(0)
+	monitoringPollInterval = getEnv("MONITORING_POLL_INTERVAL", "30s")
+	configCacheTTL         = getEnv("CONFIG_CACHE_TTL", "5s")
+	// trustAuthHeaders makes requestUser and requestGroups honour the identity an
+	// authenticating proxy forwards. Only enable it when every request passes through that proxy, which must
+	// strip these headers from its clients.
+	trustAuthHeaders = getEnv("TRUST_AUTH_HEADERS", "false") == "true"
 )
 
+// statusObservers receive the raw connector statuses gathered by every successful
+// monitoring poll together with the cluster ID reported by Kafka Connect.
+var statusObservers []func(clusterID string, statuses []connectorStatusResponse)
+
 // MonitoringSummary represents aggregated status information for connectors.
 type MonitoringSummary struct {
 	ClusterID       string                    `json:"clusterId,omitempty"`
@@ -53,80 +56,45 @@ type MonitoringSummary struct {
 	UptimeSeconds   int64                     `json:"uptimeSeconds"`
 	Uptime          string                    `json:"uptime,omitempty"`
 	Connectors      []ConnectorStatusOverview `json:"connectors"`
+	// Grades counts the connectors per health grade.
+	Grades map[string]int `json:"grades,omitempty"`
+	// Silences are the alert silences of the cluster that apply now.
+	Silences []AlertSilence `json:"silences,omitempty"`
 }
 
 // ConnectorStatusOverview provides a condensed view of an individual connector.
 type ConnectorStatusOverview struct {
-	Name  string `json:"name"`
-	State string `json:"state"`
-	Type  string `json:"type"`
-}
-
-type connectorStatusResponse struct {
-	Name      string `json:"name"`
-	Connector struct {
-		State    string `json:"state"`
-		WorkerID string `json:"worker_id"`
-	} `json:"connector"`
-	Tasks []struct {
-		ID       int    `json:"id"`
-		State    string `json:"state"`
-		WorkerID string `json:"worker_id"`
-	} `json:"tasks"`
-	Type string `json:"type"`
-}
-
-type connectUnavailableError struct {
-	err error
-}
-
-func (e *connectUnavailableError) Error() string {
-	return fmt.Sprintf("kafka connect is unreachable: %v", e.err)
+	Name       string         `json:"name"`
+	State      string         `json:"state"`
+	Type       string         `json:"type"`
+	TaskStates map[string]int `json:"taskStates,omitempty"`
+	Health     *HealthGrade   `json:"health,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	// Tasks is only returned with ?include=tasks.
+	Tasks []TaskStatusOverview `json:"tasks,omitempty"`
 }
 
-func (e *connectUnavailableError) Unwrap() error {
-	return e.err
+// TaskStatusOverview is one task of a connector in the monitoring summary.
+type TaskStatusOverview struct {
+	ID       int    `json:"id"`
+	State    string `json:"state"`
+	WorkerID string `json:"workerId,omitempty"`
 }
 
-func newStateCounter() map[string]int {
-	return map[string]int{
-		"running":    0,
-		"paused":     0,
-		"failed":     0,
-		"unassigned": 0,
-		"unknown":    0,
+// withoutTasks returns a copy of the summary without per-connector task details.
+func (s MonitoringSummary) withoutTasks() MonitoringSummary {
+	connectors := make([]ConnectorStatusOverview, len(s.Connectors))
+	for i, overview := range s.Connectors {
+		overview.Tasks = nil
+		connectors[i] = overview
 	}
-}
-
-func normalizeState(state string) string {
-	switch strings.ToUpper(state) {
-	case "RUNNING":
-		return "running"
-	case "PAUSED":
-		return "paused"
-	case "FAILED":
-		return "failed"
-	case "UNASSIGNED":
-		return "unassigned"
-	default:
-		return "unknown"
-	}
-}
-
-func joinURL(base string, parts ...string) string {
-	trimmed := strings.TrimSuffix(base, "/")
-	for _, part := range parts {
-		if part == "" {
-			continue
-		}
-		trimmed += "/" + strings.TrimPrefix(part, "/")
-	}
-	return trimmed
+	s.Connectors = connectors
+	return s
 }
 
 // fetchFromKafkaConnect makes a GET request to a Kafka Connect endpoint and returns the response body
 func fetchFromKafkaConnect(endpoint string) ([]byte, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := connectClientFor("", routeRead)
 	req, err := http.NewRequest(http.MethodGet, joinURL(connectURL, endpoint), nil)
 	if err != nil {
 		return nil, err
@@ -134,7 +102,7 @@ func fetchFromKafkaConnect(endpoint string) ([]byte, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, &connectUnavailableError{err: err}
+		return nil, &connectUnavailableError{Err: err}
 	}
 	defer resp.Body.Close()
 
@@ -147,31 +115,29 @@ func fetchFromKafkaConnect(endpoint string) ([]byte, error) {
 
 // clusterInfoHandler returns Kafka Connect cluster information
 func clusterInfoHandler(w http.ResponseWriter, r *http.Request) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(connectURL, "/"), nil)
+	client := connectClientFor(mux.Vars(r)["cluster"], routeRead)
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(connectURLFor(mux.Vars(r)["cluster"]), "/"), nil)
 	if err != nil {
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "invalid_connect_url", "failed to create the Kafka Connect request")
 		log.Printf("cluster info: create request error: %v", err)
 		return
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(connectUnavailableError{err: err})
+		writeConnectUnavailable(w, err)
 		log.Printf("cluster info: request error: %v", err)
 		return
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		http.Error(w, fmt.Sprintf("Unexpected status from cluster endpoint: %d", resp.StatusCode), resp.StatusCode)
+		writeConnectError(w, resp)
 		return
 	}
+	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		http.Error(w, "Failed to read response", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadGateway, "connect_request_failed", "failed to read the Kafka Connect response")
 		log.Printf("cluster info: read response error: %v", err)
 		return
 	}
@@ -181,55 +147,6 @@ func clusterInfoHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
-func fetchConnectorNames(ctx context.Context, client *http.Client, baseURL string) ([]string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(baseURL, "connectors"), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, &connectUnavailableError{err: err}
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status fetching connectors: %d", resp.StatusCode)
-	}
-
-	var names []string
-	if err := json.NewDecoder(resp.Body).Decode(&names); err != nil {
-		return nil, fmt.Errorf("decode connectors: %w", err)
-	}
-
-	return names, nil
-}
-
-func fetchConnectorStatus(ctx context.Context, client *http.Client, baseURL, name string) (connectorStatusResponse, error) {
-	escaped := url.PathEscape(name)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(baseURL, "connectors", escaped, "status"), nil)
-	if err != nil {
-		return connectorStatusResponse{}, err
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return connectorStatusResponse{}, &connectUnavailableError{err: err}
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return connectorStatusResponse{}, fmt.Errorf("unexpected status fetching connector %s: %d", name, resp.StatusCode)
-	}
-
-	var status connectorStatusResponse
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-		return connectorStatusResponse{}, fmt.Errorf("decode connector status for %s: %w", name, err)
-	}
-
-	return status, nil
-}
-
 func fetchClusterMetadata(ctx context.Context, client *http.Client, baseURL string) (string, time.Duration, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/"), nil)
 	if err != nil {
@@ -238,7 +155,7 @@ func fetchClusterMetadata(ctx context.Context, client *http.Client, baseURL stri
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", 0, &connectUnavailableError{err: err}
+		return "", 0, &connectUnavailableError{Err: err}
 	}
 	defer resp.Body.Close()
 
@@ -392,6 +309,35 @@ func parseInt(value string) (int64, error) {
 	return strconv.ParseInt(value, 10, 64)
 }
 
+// parseWindow parses look-back windows such as "30d", "24h" or "15m". Day suffixes are
+// accepted in addition to the units understood by time.ParseDuration.
+func parseWindow(value string, fallback time.Duration) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback, nil
+	}
+
+	if strings.HasSuffix(strings.ToLower(value), "d") {
+		days, err := parseInt(strings.TrimSpace(value[:len(value)-1]))
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q: %w", value, err)
+		}
+		if days <= 0 {
+			return 0, fmt.Errorf("invalid window %q: must be positive", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window %q: %w", value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid window %q: must be positive", value)
+	}
+	return d, nil
+}
+
 func formatUptime(d time.Duration) string {
 	if d <= 0 {
 		return "unknown"
@@ -440,63 +386,33 @@ func fetchMonitoringSummary(ctx context.Context, client *http.Client, baseURL st
 		return MonitoringSummary{}, err
 	}
 
-	connectorStates := newStateCounter()
-	taskStates := newStateCounter()
 	overviews := make([]ConnectorStatusOverview, 0, len(names))
-	runningConnectors := 0
-	degradedConnectors := 0
-	failedConnectors := 0
-
+	statuses := make([]connectorStatusResponse, 0, len(names))
 	for _, name := range names {
 		status, err := fetchConnectorStatus(ctx, client, baseURL, name)
 		if err != nil {
 			return MonitoringSummary{}, err
 		}
 
-		state := normalizeState(status.Connector.State)
-		connectorStates[state]++
-		overviews = append(overviews, ConnectorStatusOverview{
+		statuses = append(statuses, status)
+
+		overview := ConnectorStatusOverview{
 			Name:  status.Name,
-			State: state,
+			State: normalizeState(status.Connector.State),
 			Type:  status.Type,
-		})
+		}
 
-		hasRunningTask := false
-		hasFailedTask := false
 		for _, task := range status.Tasks {
 			taskState := normalizeState(task.State)
-			taskStates[taskState]++
-			if taskState == "running" {
-				hasRunningTask = true
-			}
-			if taskState == "failed" {
-				hasFailedTask = true
+			if overview.TaskStates == nil {
+				overview.TaskStates = make(map[string]int)
 			}
+			overview.TaskStates[taskState]++
+			overview.Tasks = append(overview.Tasks, TaskStatusOverview{ID: task.ID, State: taskState, WorkerID: task.WorkerID})
 		}
-
-		switch {
-		case hasFailedTask && hasRunningTask:
-			degradedConnectors++
-		case hasFailedTask:
-			failedConnectors++
-		default:
-			switch state {
-			case "failed":
-				failedConnectors++
-			case "running":
-				runningConnectors++
-			default:
-				degradedConnectors++
-			}
-		}
-	}
-
-	totals := map[string]int{
-		"total":    len(names),
-		"running":  runningConnectors,
-		"degraded": degradedConnectors,
-		"failed":   failedConnectors,
+		overviews = append(overviews, overview)
 	}
+	counts := connectclient.Summarize(statuses)
 
 	clusterID := ""
 	uptime := time.Duration(0)
@@ -515,107 +431,153 @@ func fetchMonitoringSummary(ctx context.Context, client *http.Client, baseURL st
 
 	summary := MonitoringSummary{
 		ClusterID:       clusterID,
-		TotalConnectors: len(names),
-		ConnectorStates: connectorStates,
-		TaskStates:      taskStates,
-		Totals:          totals,
+		TotalConnectors: counts.TotalConnectors,
+		ConnectorStates: counts.ConnectorStates,
+		TaskStates:      counts.TaskStates,
+		Totals:          counts.Totals,
 		UptimeSeconds:   int64((uptime / time.Second)),
 		Uptime:          formatUptime(uptime),
 		Connectors:      overviews,
 	}
 
+	for _, observe := range statusObservers {
+		observe(clusterID, statuses)
+	}
+
 	return summary, nil
 }
 
 func getMonitoringSummary(ctx context.Context) (MonitoringSummary, error) {
-	now := time.Now()
-
-	// Fast path: return cached data if still valid
-	monitoringSummaryCache.Lock()
-	if monitoringSummaryCache.valid && now.Before(monitoringSummaryCache.expiresAt) {
-		summary := monitoringSummaryCache.data
-		monitoringSummaryCache.Unlock()
-		return summary, nil
-	}
-
-	// Cache is expired or invalid - check if someone is already fetching
-	if monitoringSummaryCache.fetching {
-		// Another goroutine is fetching, wait and return stale data or wait for fresh data
-		// Return stale data if available to prevent blocking
-		if monitoringSummaryCache.valid {
-			summary := monitoringSummaryCache.data
-			monitoringSummaryCache.Unlock()
-			return summary, nil
+	summary, _, _, err := monitoringSummaryCache.get(ctx)
+	return summary, err
+}
+
+// runMonitoringPoller refreshes the monitoring summary on a fixed interval so status
+// observers (notifications, ...) see state changes even when nobody has the UI open.
+func runMonitoringPoller(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if _, err := getMonitoringSummary(ctx); err != nil {
+				log.Printf("monitoring poller: %v", err)
+			}
+			cancel()
+		case <-stop:
+			return
 		}
-		// No stale data available, unlock and wait briefly then retry
-		monitoringSummaryCache.Unlock()
-		time.Sleep(100 * time.Millisecond)
-		return getMonitoringSummary(ctx) // Retry
 	}
+}
 
-	// Mark that we're fetching to prevent thundering herd
-	monitoringSummaryCache.fetching = true
-	monitoringSummaryCache.Unlock()
+func resetMonitoringSummaryCache() {
+	monitoringSummaryCache.reset()
+}
 
-	// Fetch new data
-	summary, err := fetchMonitoringSummary(ctx, monitoringHTTPClient, connectURL)
+func getEnv(key, defaultValue string) string {
+	recordSetting(key, defaultValue)
+	value, _ := lookupSetting(key, defaultValue)
+	return value
+}
 
-	// Update cache regardless of success/failure
-	monitoringSummaryCache.Lock()
-	monitoringSummaryCache.fetching = false
-	if err == nil {
-		monitoringSummaryCache.data = summary
-		monitoringSummaryCache.expiresAt = time.Now().Add(summaryCacheTTL)
-		monitoringSummaryCache.valid = true
-	}
-	// If fetch failed but we have old data, keep it valid for graceful degradation
-	// (expiresAt stays in the past, but valid=true allows stale reads)
-	monitoringSummaryCache.Unlock()
+// configError reports an environment variable holding an unusable value.
+type configError struct {
+	name  string
+	value string
+}
 
-	if err != nil {
-		return MonitoringSummary{}, err
+func (e *configError) Error() string {
+	return fmt.Sprintf("invalid value %q for %s", e.value, e.name)
+}
+
+// requestUser identifies the caller of a request: the signed-in user or, with
+// TRUST_AUTH_HEADERS, the user name a fronting proxy (oauth2-proxy, ingress auth, ...)
+// forwards in a header. Requests without either are attributed to "anonymous".
+func requestUser(r *http.Request) string {
+	if user, ok := authenticatedUser(r); ok {
+		return user.Username
+	}
+	if !trustAuthHeaders {
+		return "anonymous"
 	}
+	for _, header := range []string{"X-Forwarded-User", "X-Auth-Request-User", "X-Remote-User"} {
+		if user := strings.TrimSpace(r.Header.Get(header)); user != "" {
+			return user
+		}
+	}
+	return "anonymous"
+}
 
-	return summary, nil
+// requestGroups returns the caller's groups: the OIDC groups claim, or the
+// comma-separated groups forwarded by an authenticating proxy when TRUST_AUTH_HEADERS
+// is on. Anyone can send those headers, so without it callers have no groups.
+func requestGroups(r *http.Request) []string {
+	if user, ok := authenticatedUser(r); ok {
+		return user.Groups
+	}
+	if !trustAuthHeaders {
+		return nil
+	}
+	for _, header := range []string{"X-Forwarded-Groups", "X-Auth-Request-Groups"} {
+		if groups := splitList(r.Header.Get(header)); len(groups) > 0 {
+			return groups
+		}
+	}
+	return nil
 }
 
-func resetMonitoringSummaryCache() {
-	monitoringSummaryCache.Lock()
-	monitoringSummaryCache.data = MonitoringSummary{}
-	monitoringSummaryCache.expiresAt = time.Time{}
-	monitoringSummaryCache.valid = false
-	monitoringSummaryCache.Unlock()
+// inAnyGroup reports whether the caller belongs to one of the comma-separated groups.
+func inAnyGroup(r *http.Request, groups string) bool {
+	allowed := splitList(groups)
+	for _, group := range requestGroups(r) {
+		for _, candidate := range allowed {
+			if group == candidate {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// writeJSON encodes payload as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("failed to encode response: %v", err)
 	}
-	return defaultValue
+}
+
+// writeJSONError writes the ProxyError envelope, with the remediation known for code.
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ProxyError{Error: code, Message: message, Remediation: errorRemediations[code]})
 }
 
 // redactSensitiveData recursively redacts sensitive values in JSON
 func redactSensitiveData(data interface{}) interface{} {
+	return currentRedactionRules().redact(data)
+}
+
+func (r redactionRules) redact(data interface{}) interface{} {
 	switch v := data.(type) {
 	case map[string]interface{}:
 		result := make(map[string]interface{})
 		for key, value := range v {
-			lk := strings.ToLower(key)
-			if _, ok := safeExactKeys[lk]; ok {
-				result[key] = redactSensitiveData(value)
-				continue
-			}
-			if sensitivePattern.MatchString(lk) {
-				result[key] = "***REDACTED***"
+			if s, ok := value.(string); ok && isSecretReference(s) {
+				result[key] = s
+			} else if r.isSensitive(key) {
+				result[key] = r.placeholder
 			} else {
-				result[key] = redactSensitiveData(value)
+				result[key] = r.redact(value)
 			}
 		}
 		return result
 	case []interface{}:
 		result := make([]interface{}, len(v))
 		for i, item := range v {
-			result[i] = redactSensitiveData(item)
+			result[i] = r.redact(item)
 		}
 		return result
 	default:
@@ -623,9 +585,11 @@ func redactSensitiveData(data interface{}) interface{} {
 	}
 }
 
+// copyHeaders copies the client's headers to an upstream request. Cookies belong to the
+// console, and carry its session token, so they are never sent to Kafka Connect.
 func copyHeaders(dst, src http.Header) {
 	for key, values := range src {
-		if strings.EqualFold(key, "Host") || strings.EqualFold(key, "Content-Length") {
+		if strings.EqualFold(key, "Host") || strings.EqualFold(key, "Content-Length") || strings.EqualFold(key, "Cookie") {
 			continue
 		}
 		dst.Del(key)
@@ -635,25 +599,37 @@ func copyHeaders(dst, src http.Header) {
 	}
 }
 
-func writeRedactedResponse(w http.ResponseWriter, resp *http.Response) error {
+// readRedactedBody consumes resp and returns its body with sensitive values redacted.
+// Non-JSON bodies are returned unchanged.
+func readRedactedBody(resp *http.Response) ([]byte, error) {
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("read response body: %w", err)
+		return nil, fmt.Errorf("read response body: %w", err)
 	}
+	return redactBody(body)
+}
 
+// redactBody returns body with sensitive values redacted, or unchanged when it is not
+// JSON.
+func redactBody(body []byte) ([]byte, error) {
 	var jsonData interface{}
 	if err := json.Unmarshal(body, &jsonData); err == nil {
 		redacted := redactSensitiveData(jsonData)
 		redactedBody, err := json.Marshal(redacted)
 		if err != nil {
-			return fmt.Errorf("marshal redacted data: %w", err)
+			return nil, fmt.Errorf("marshal redacted data: %w", err)
 		}
 		body = redactedBody
 	}
 
-	for key, values := range resp.Header {
+	return body, nil
+}
+
+// writeResponse copies header (minus Content-Length) and writes status and body.
+func writeResponse(w http.ResponseWriter, status int, header http.Header, body []byte) error {
+	for key, values := range header {
 		if strings.EqualFold(key, "Content-Length") {
 			continue
 		}
@@ -662,38 +638,33 @@ func writeRedactedResponse(w http.ResponseWriter, resp *http.Response) error {
 		}
 	}
 
-	w.WriteHeader(resp.StatusCode)
+	w.WriteHeader(status)
 	if _, err := w.Write(body); err != nil {
 		return fmt.Errorf("write response body: %w", err)
 	}
 	return nil
 }
 
-// buildProxyURL constructs the target Kafka Connect URL from the incoming request
+// buildProxyURL constructs the target Kafka Connect URL from the incoming request.
+// Paths that are not a known Kafka Connect resource are rejected with a
+// *proxyPathError, so a crafted connector name cannot reach another endpoint.
 func buildProxyURL(r *http.Request) (*url.URL, error) {
-	// Parse the base Kafka Connect URL
-	baseURL, err := url.Parse(connectURL)
+	// Example: /api/default/connectors/my-connector/status -> /connectors/my-connector/status
+	cluster, segments, err := parseProxyPath(r.URL.EscapedPath())
 	if err != nil {
-		return nil, fmt.Errorf("invalid connect URL: %w", err)
+		return nil, err
 	}
 
-	// Build the target path by extracting everything after /api/{cluster}/
-	// Example: /api/default/connectors/my-connector/status -> /connectors/my-connector/status
-	requestPath := strings.TrimPrefix(r.URL.Path, "/")
-	pathParts := strings.SplitN(requestPath, "/", 3) // Split into: ["api", "cluster", "rest/of/path"]
-
-	var targetPath string
-	if len(pathParts) >= 3 && pathParts[2] != "" {
-		// Use everything after /api/{cluster}/
-		targetPath = "/" + pathParts[2]
-	} else {
-		// Fallback for malformed requests
-		targetPath = "/connectors"
+	// Parse the base Kafka Connect URL of the requested cluster
+	baseURL, err := url.Parse(connectURLFor(cluster))
+	if err != nil {
+		return nil, fmt.Errorf("invalid connect URL: %w", err)
 	}
 
 	// Combine base URL path with target path, handling trailing slashes properly
 	basePath := strings.TrimSuffix(baseURL.Path, "/")
-	baseURL.Path = basePath + targetPath
+	baseURL.Path = basePath + "/" + strings.Join(segments, "/")
+	baseURL.RawPath = ""
 
 	// Preserve query parameters from original request
 	baseURL.RawQuery = r.URL.RawQuery
@@ -706,17 +677,67 @@ func proxyHandler(w http.ResponseWriter, r *http.Request) {
 	// Build target URL using proper URL parsing
 	targetURL, err := buildProxyURL(r)
 	if err != nil {
-		http.Error(w, "Invalid proxy URL", http.StatusInternalServerError)
+		var invalidPath *proxyPathError
+		if errors.As(err, &invalidPath) {
+			writeJSONError(w, http.StatusBadRequest, "invalid_path", err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "invalid_connect_url", "failed to build the Kafka Connect URL")
 		log.Printf("Error building proxy URL for %s: %v", r.URL.Path, err)
 		return
 	}
 
+	if !admitConnector(w, r) {
+		return
+	}
+
+	connectorName, subresource, isConnectorPath := connectorPathInfo(r.URL.Path)
+	if isConnectorPath && isDryRun(r) && dryRunSupported(r.Method, subresource) {
+		dryRunConnector(w, r, connectorName, subresource)
+		return
+	}
+
+	cluster := mux.Vars(r)["cluster"]
+	isDetail := r.Method == http.MethodGet && isConnectorPath && subresource == ""
+	cache, cacheKey := configCache, ""
+	if r.Method == http.MethodGet && isConnectorPath && configCache.cacheable(subresource) {
+		cacheKey = configCache.key(targetURL, connectorName, subresource)
+	} else if key := validationCacheKey(r, targetURL); key != "" {
+		cache, cacheKey = validationCache, key
+	}
+	if cacheKey != "" {
+		if cached, ok := cache.get(r.Context(), cacheKey); ok {
+			body := cached.Body
+			if isDetail {
+				body = annotateConnectorDetail(cluster, connectorName, cached.Status, body)
+			}
+			w.Header().Set("X-Cache", "HIT")
+			if err := writeResponse(w, cached.Status, cached.Header, body); err != nil {
+				log.Printf("failed to write cached response: %v", err)
+			}
+			return
+		}
+	}
+	if r.Method != http.MethodGet && isConnectorPath {
+		configCache.invalidateConnector(r.Context(), targetURL, connectorName)
+	}
+
+	pendingRefs, err := resolveSecretRequest(r)
+	if err != nil {
+		writeSecretResolutionError(w, err)
+		return
+	}
+	var pendingPoint pendingRestorePoint
+	if isConnectorPath {
+		pendingPoint = snapshotProxiedChange(r, connectorName, subresource)
+	}
+
 	log.Printf("Proxying %s %s to %s", r.Method, r.URL.Path, targetURL.String())
 
 	// Create the proxy request
 	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL.String(), r.Body)
 	if err != nil {
-		http.Error(w, "Failed to create proxy request", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "invalid_connect_url", "failed to create the Kafka Connect request")
 		log.Printf("Error creating proxy request: %v", err)
 		return
 	}
@@ -725,43 +746,131 @@ func proxyHandler(w http.ResponseWriter, r *http.Request) {
 	copyHeaders(proxyReq.Header, r.Header)
 
 	// Make the request
-	client := &http.Client{}
+	client := connectClientFor(cluster, upstreamRouteClass(r.Method, r.URL.Path))
 	resp, err := client.Do(proxyReq)
 	if err != nil {
-		http.Error(w, "Failed to proxy request", http.StatusBadGateway)
+		var open *circuitOpenError
+		var timedOut *upstreamTimeoutError
+		var busy *upstreamBusyError
+		if errors.As(err, &open) || errors.As(err, &timedOut) || errors.As(err, &busy) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connect_request_failed", "failed to reach Kafka Connect")
 		log.Printf("Error proxying request: %v", err)
 		return
 	}
+
+	if err := pendingRefs.commit(resp.StatusCode); err != nil {
+		log.Printf("secrets: failed to record placeholders for %s: %v", pendingRefs.connector, err)
+	}
+	pendingPoint.commit(resp.StatusCode)
+	if err := restoreSecretResponse(r, resp); err != nil {
+		writeJSONError(w, http.StatusBadGateway, "connect_request_failed", "failed to read the Kafka Connect response")
+		log.Printf("Error reading proxied response: %v", err)
+		return
+	}
+	if resp.StatusCode >= 400 {
+		writeConnectError(w, resp)
+		return
+	}
+
+	if scope := tenantScopeFor(r); !scope.all && r.Method == http.MethodGet && isConnectorListPath(r.URL.Path) {
+		body, err := readRedactedBody(resp)
+		if err != nil {
+			writeJSONError(w, http.StatusBadGateway, "connect_request_failed", "failed to read the Kafka Connect response")
+			log.Printf("Error reading proxied response: %v", err)
+			return
+		}
+		if err := writeResponse(w, resp.StatusCode, resp.Header, scope.filterConnectorListBody(cluster, body)); err != nil {
+			log.Printf("failed to write proxy response: %v", err)
+		}
+		return
+	}
+
+	if cacheKey != "" || isDetail {
+		body, err := readRedactedBody(resp)
+		if err != nil {
+			writeJSONError(w, http.StatusBadGateway, "connect_request_failed", "failed to read the Kafka Connect response")
+			log.Printf("Error reading proxied response: %v", err)
+			return
+		}
+		if cacheKey != "" {
+			if resp.StatusCode == http.StatusOK {
+				cache.set(r.Context(), cacheKey, resp.StatusCode, resp.Header, body)
+			}
+			w.Header().Set("X-Cache", "MISS")
+		}
+		if isDetail {
+			body = annotateConnectorDetail(cluster, connectorName, resp.StatusCode, body)
+		}
+		if err := writeResponse(w, resp.StatusCode, resp.Header, body); err != nil {
+			log.Printf("failed to write proxy response: %v", err)
+		}
+		return
+	}
+
 	if err := writeRedactedResponse(w, resp); err != nil {
 		log.Printf("failed to stream proxy response: %v", err)
 	}
 }
 
+// connectorPathInfo extracts the connector name and sub-resource from a proxied path
+// such as /api/{cluster}/connectors/{name}/config. ok is false for paths that do not
+// address a single connector.
+func connectorPathInfo(path string) (name, subresource string, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(parts) < 3 || parts[0] != "api" {
+		return "", "", false
+	}
+
+	segments := strings.Split(strings.Trim(parts[2], "/"), "/")
+	if len(segments) < 2 || segments[0] != "connectors" || segments[1] == "" {
+		return "", "", false
+	}
+
+	return segments[1], strings.Join(segments[2:], "/"), true
+}
+
 func clusterActionHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	action := vars["action"]
+	baseURL := connectURLFor(vars["cluster"])
 
-	var targetURL string
+	var targetURL, operation string
 	switch strings.ToLower(action) {
 	case "restart", "restart-all":
-		targetURL = joinURL(connectURL, "connectors", "-", "restart")
+		targetURL, operation = joinURL(baseURL, "connectors", "-", "restart"), dryRunRestartAll
 	case "rebalance":
-		targetURL = joinURL(connectURL, "admin", "rebalance")
+		targetURL, operation = joinURL(baseURL, "admin", "rebalance"), dryRunRebalance
+	case "pause-all":
+		pauseAllConnectors(w, r)
+		return
+	case "resume-previous":
+		resumePreviousConnectors(w, r)
+		return
+	case "cleanup-stale":
+		cleanupStaleConnectors(w, r)
+		return
 	default:
-		http.Error(w, fmt.Sprintf("unsupported cluster action: %s", action), http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "unsupported_action", fmt.Sprintf("unsupported cluster action: %s", action))
+		return
+	}
+	if isDryRun(r) {
+		dryRunClusterAction(w, r, operation)
 		return
 	}
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "failed to read request body")
 		log.Printf("cluster action %s: read body error: %v", action, err)
 		return
 	}
 
 	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, targetURL, bytes.NewReader(payload))
 	if err != nil {
-		http.Error(w, "Failed to create cluster action request", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "invalid_connect_url", "failed to create the Kafka Connect request")
 		log.Printf("cluster action %s: create request error: %v", action, err)
 		return
 	}
@@ -771,12 +880,23 @@ func clusterActionHandler(w http.ResponseWriter, r *http.Request) {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := connectClientFor(mux.Vars(r)["cluster"], routeWrite).Do(req)
 	if err != nil {
-		http.Error(w, "Failed to execute cluster action", http.StatusBadGateway)
+		var open *circuitOpenError
+		var timedOut *upstreamTimeoutError
+		var busy *upstreamBusyError
+		if errors.As(err, &open) || errors.As(err, &timedOut) || errors.As(err, &busy) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connect_request_failed", "failed to reach Kafka Connect")
 		log.Printf("cluster action %s: proxy error: %v", action, err)
 		return
 	}
+	if resp.StatusCode >= 400 {
+		writeConnectError(w, resp)
+		return
+	}
 
 	if err := writeRedactedResponse(w, resp); err != nil {
 		log.Printf("cluster action %s: failed to stream response: %v", action, err)
@@ -789,22 +909,8 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	// Check if Kafka Connect is reachable
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(connectURL, "/"), nil)
-	if err != nil {
-		respondUnhealthy(w, "Failed to create health check request", err)
-		return
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		respondUnhealthy(w, "Kafka Connect unreachable", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		respondUnhealthy(w, fmt.Sprintf("Kafka Connect returned HTTP %d", resp.StatusCode), nil)
+	if reason, err := checkKafkaConnect(ctx); reason != "" {
+		respondUnhealthy(w, reason, err)
 		return
 	}
 
@@ -822,6 +928,30 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// checkKafkaConnect probes the Kafka Connect root endpoint. An empty reason means
+// Connect is reachable and accepted the request.
+func checkKafkaConnect(ctx context.Context) (reason string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(connectURL, "/"), nil)
+	if err != nil {
+		return "Failed to create health check request", err
+	}
+
+	resp, err := newConnectClient(0).Do(req)
+	if err != nil {
+		return "Kafka Connect unreachable", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Sprintf("Kafka Connect rejected the proxy's credentials (HTTP %d); check KAFKA_CONNECT_USERNAME/KAFKA_CONNECT_PASSWORD", resp.StatusCode), nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("Kafka Connect returned HTTP %d", resp.StatusCode), nil
+	}
+	return "", nil
+}
+
 // respondUnhealthy writes an unhealthy status response
 func respondUnhealthy(w http.ResponseWriter, reason string, err error) {
 	w.Header().Set("Content-Type", "application/json")
@@ -848,25 +978,32 @@ func monitoringSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	requestedCluster := vars["cluster"]
 
-	summary, err := getMonitoringSummary(r.Context())
-	if err != nil {
-		status := http.StatusBadGateway
-		payload := map[string]string{
-			"error":   "summary_fetch_failed",
-			"message": err.Error(),
+	includeTasks := false
+	for _, include := range splitList(r.URL.Query().Get("include")) {
+		if include != "tasks" {
+			writeJSONError(w, http.StatusBadRequest, "invalid_include", fmt.Sprintf("unknown include %q; supported: tasks", include))
+			return
 		}
+		includeTasks = true
+	}
 
+	summary, age, cacheState, err := monitoringSummaryCache.get(r.Context())
+	if err != nil {
+		status, code := http.StatusBadGateway, "summary_fetch_failed"
 		var cue *connectUnavailableError
 		if errors.As(err, &cue) {
-			status = http.StatusServiceUnavailable
-			payload["error"] = "connect_unreachable"
+			status, code = http.StatusServiceUnavailable, "connect_unreachable"
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(status)
-		if err := json.NewEncoder(w).Encode(payload); err != nil {
-			log.Printf("failed to encode error response: %v", err)
+		var open *circuitOpenError
+		if errors.As(err, &open) {
+			w.Header().Set("Retry-After", strconv.Itoa(int((open.retryAfter+time.Second-1)/time.Second)))
+		}
+		var busy *upstreamBusyError
+		if errors.As(err, &busy) {
+			w.Header().Set("Retry-After", strconv.Itoa(int((busy.retryAfter+time.Second-1)/time.Second)))
 		}
+
+		writeJSONError(w, status, code, err.Error())
 		return
 	}
 
@@ -876,7 +1013,14 @@ func monitoringSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	if summary.Uptime == "" && summary.UptimeSeconds > 0 {
 		summary.Uptime = formatUptime(time.Duration(summary.UptimeSeconds) * time.Second)
 	}
+	summary = summary.withTags(connectorMetadata.all(requestedCluster), splitList(r.URL.Query().Get("tag")))
+	summary = tenantScopeFor(r).filterSummary(summary)
+	if !includeTasks {
+		summary = summary.withoutTasks()
+	}
+	summary.Silences = alertSilences.activeFor(requestedCluster)
 
+	monitoringSummaryCache.setHeaders(w.Header(), age, cacheState)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(summary); err != nil {
@@ -884,159 +1028,305 @@ func monitoringSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// summaryHandler provides aggregated cluster information for the settings page
-func summaryHandler(w http.ResponseWriter, r *http.Request) {
-	// Aggregate data from multiple endpoints
-	type Summary struct {
-		ClusterInfo      map[string]interface{}   `json:"clusterInfo"`
-		ConnectorPlugins []map[string]interface{} `json:"connectorPlugins"`
-		ConnectorStats   struct {
-			Total   int `json:"total"`
-			Running int `json:"running"`
-			Failed  int `json:"failed"`
-			Paused  int `json:"paused"`
-		} `json:"connectorStats"`
-		WorkerInfo map[string]interface{} `json:"workerInfo"`
-	}
-
-	summary := Summary{}
-	var wg sync.WaitGroup
+// summaryStatusWorkers bounds the connector status requests made in parallel by the
+// settings summary.
+const summaryStatusWorkers = 10
 
-	// Fetch cluster info, plugins, and workers in parallel
-	wg.Add(3)
+// Sections of the settings summary, used as the keys of its errors.
+const (
+	summarySectionClusterInfo    = "clusterInfo"
+	summarySectionPlugins        = "plugins"
+	summarySectionConnectorStats = "connectorStats"
+	summarySectionWorkers        = "workers"
+)
 
-	// Fetch cluster info from root endpoint
-	go func() {
-		defer wg.Done()
-		clusterResp, err := http.Get(strings.TrimSuffix(connectURL, "/"))
-		if err == nil {
-			defer clusterResp.Body.Close()
-			if clusterResp.StatusCode == http.StatusOK {
-				body, err := io.ReadAll(clusterResp.Body)
-				if err == nil {
-					var clusterData map[string]interface{}
-					if err := json.Unmarshal(body, &clusterData); err == nil {
-						summary.ClusterInfo = clusterData
+// SettingsConnectorStats counts the connectors of a cluster per state.
+type SettingsConnectorStats struct {
+	Total   int `json:"total"`
+	Running int `json:"running"`
+	Failed  int `json:"failed"`
+	Paused  int `json:"paused"`
+	Stopped int `json:"stopped"`
+}
+
+// SettingsSummary is returned by GET /api/{cluster}/summary. Errors maps the sections
+// that could not be fetched to the reason; the other sections are still filled in.
+type SettingsSummary struct {
+	ClusterInfo      map[string]interface{} `json:"clusterInfo"`
+	ConnectorPlugins []connectPluginInfo    `json:"connectorPlugins"`
+	ConnectorStats   SettingsConnectorStats `json:"connectorStats"`
+	WorkerInfo       map[string]interface{} `json:"workerInfo"`
+	Errors           map[string]string      `json:"errors,omitempty"`
+}
+
+// fetchConnectRoot returns the version, commit and Kafka cluster ID a worker reports at
+// its root endpoint.
+func fetchConnectRoot(ctx context.Context, client *http.Client, baseURL string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching cluster info: %d", resp.StatusCode)
+	}
+	var info map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decode cluster info: %w", err)
+	}
+	return info, nil
+}
+
+// fetchSettingsConnectorStats counts the given connectors per state, fetching their
+// statuses in parallel. Connectors deleted since they were listed are skipped; other
+// failures are counted and the first one is returned with the partial counts.
+func fetchSettingsConnectorStats(ctx context.Context, client *http.Client, baseURL string, names []string) (SettingsConnectorStats, error) {
+	stats := SettingsConnectorStats{Total: len(names)}
+	queue := make(chan string, len(names))
+	for _, name := range names {
+		queue <- name
+	}
+	close(queue)
+
+	var mu sync.Mutex
+	var firstErr error
+	failures := 0
+	var wg sync.WaitGroup
+	for i := 0; i < summaryStatusWorkers && i < len(names); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				status, err := fetchConnectorStatus(ctx, client, baseURL, name)
+				mu.Lock()
+				switch {
+				case errors.Is(err, errConnectorNotFound):
+				case err != nil:
+					failures++
+					if firstErr == nil {
+						firstErr = err
+					}
+				default:
+					switch normalizeState(status.Connector.State) {
+					case "running":
+						stats.Running++
+					case "failed":
+						stats.Failed++
+					case "paused":
+						stats.Paused++
+					case "stopped":
+						stats.Stopped++
 					}
 				}
+				mu.Unlock()
 			}
+		}()
+	}
+	wg.Wait()
+
+	if failures > 0 {
+		return stats, fmt.Errorf("%d of %d connector statuses could not be fetched: %w", failures, len(names), firstErr)
+	}
+	return stats, nil
+}
+
+// summaryHandler provides aggregated cluster information for the settings page. Each
+// section is fetched on its own; a failing section is reported under errors while the
+// others are still returned. Only when every section fails because Kafka Connect is
+// unreachable does the request fail.
+func summaryHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	client, baseURL := connectClientFor(cluster, routeRead), connectURLFor(cluster)
+	ctx := r.Context()
+
+	summary := SettingsSummary{}
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	fail := func(section string, err error) {
+		mu.Lock()
+		errs[section] = err
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		info, err := fetchConnectRoot(ctx, client, baseURL)
+		if err != nil {
+			fail(summarySectionClusterInfo, err)
+			return
 		}
+		summary.ClusterInfo = info
 	}()
-
-	// Fetch connector plugins
 	go func() {
 		defer wg.Done()
-		pluginsResp, err := fetchFromKafkaConnect("connector-plugins")
-		if err == nil {
-			var pluginsData []map[string]interface{}
-			if err := json.Unmarshal(pluginsResp, &pluginsData); err == nil {
-				summary.ConnectorPlugins = pluginsData
-			}
+		plugins, err := fetchConnectorPlugins(ctx, client, baseURL)
+		if err != nil {
+			fail(summarySectionPlugins, err)
+			return
 		}
+		summary.ConnectorPlugins = plugins
 	}()
-
-	// Fetch worker info
+	// Connect has no workers endpoint; workers are derived from connector and task
+	// placement.
 	go func() {
 		defer wg.Done()
-		workersResp, err := fetchFromKafkaConnect("workers")
-		if err == nil {
-			var workers []map[string]interface{}
-			if err := json.Unmarshal(workersResp, &workers); err == nil && len(workers) > 0 {
-				summary.WorkerInfo = workers[0]
-			}
+		workers, err := fetchWorkersDetail(ctx, client, baseURL)
+		if err != nil {
+			fail(summarySectionWorkers, err)
+			return
+		}
+		summary.WorkerInfo = workerSummary(workers)
+	}()
+	go func() {
+		defer wg.Done()
+		names, err := fetchConnectorNames(ctx, client, baseURL)
+		if err != nil {
+			fail(summarySectionConnectorStats, err)
+			return
+		}
+		names = tenantScopeFor(r).filterConnectorNames(cluster, names)
+		stats, err := fetchSettingsConnectorStats(ctx, client, baseURL, names)
+		summary.ConnectorStats = stats
+		if err != nil {
+			fail(summarySectionConnectorStats, err)
 		}
 	}()
-
-	// Wait for initial parallel requests to complete
 	wg.Wait()
 
-	// Fetch connector stats (must be after workers complete to avoid race)
-	connectorsResp, err := fetchFromKafkaConnect("connectors")
-	if err == nil {
-		var connectors []string
-		if err := json.Unmarshal(connectorsResp, &connectors); err == nil {
-			summary.ConnectorStats.Total = len(connectors)
-
-			// Fetch connector statuses in parallel with worker pool (limit concurrency)
-			type connectorState struct {
-				state string
-			}
-			states := make(chan connectorState, len(connectors))
-
-			// Use worker pool pattern to limit concurrent requests
-			workerCount := 10
-			if len(connectors) < workerCount {
-				workerCount = len(connectors)
-			}
-
-			connectorChan := make(chan string, len(connectors))
-			for _, name := range connectors {
-				connectorChan <- name
-			}
-			close(connectorChan)
-
-			var statusWg sync.WaitGroup
-			statusWg.Add(workerCount)
-
-			// Start worker goroutines
-			for i := 0; i < workerCount; i++ {
-				go func() {
-					defer statusWg.Done()
-					for connectorName := range connectorChan {
-						statusResp, err := fetchFromKafkaConnect(fmt.Sprintf("connectors/%s/status", connectorName))
-						if err == nil {
-							var status map[string]interface{}
-							if err := json.Unmarshal(statusResp, &status); err == nil {
-								if connector, ok := status["connector"].(map[string]interface{}); ok {
-									if state, ok := connector["state"].(string); ok {
-										states <- connectorState{state: strings.ToUpper(state)}
-									}
-								}
-							}
-						}
-					}
-				}()
-			}
-
-			// Wait for all workers to finish and close states channel
-			go func() {
-				statusWg.Wait()
-				close(states)
-			}()
-
-			// Collect results
-			for state := range states {
-				switch state.state {
-				case "RUNNING":
-					summary.ConnectorStats.Running++
-				case "FAILED":
-					summary.ConnectorStats.Failed++
-				case "PAUSED":
-					summary.ConnectorStats.Paused++
-				}
-			}
+	unreachable := 0
+	for section, err := range errs {
+		if summary.Errors == nil {
+			summary.Errors = make(map[string]string)
 		}
+		summary.Errors[section] = err.Error()
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			unreachable++
+		}
+		log.Printf("summary: %s of cluster %s: %v", section, cluster, err)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(summary); err != nil {
-		log.Printf("failed to encode summary response: %v", err)
+	if unreachable == 4 {
+		writeConnectUnavailable(w, errs[summarySectionClusterInfo])
+		return
 	}
+	writeJSON(w, http.StatusOK, summary)
 }
 
-func main() {
-	router := mux.NewRouter()
-
+// registerRoutes adds the proxy's routes to router. Routes documented in the OpenAPI
+// spec are listed in apiOperations.
+func registerRoutes(router *mux.Router) {
 	// Health check endpoint
 	router.HandleFunc("/health", healthHandler).Methods("GET")
+	router.HandleFunc("/health/live", liveHandler).Methods("GET")
+	router.HandleFunc("/health/ready", readyHandler).Methods("GET")
+
+	// OIDC and local login
+	router.HandleFunc("/auth/login", authLoginHandler).Methods("GET")
+	router.HandleFunc("/auth/callback", authCallbackHandler).Methods("GET")
+	router.HandleFunc("/auth/me", authMeHandler).Methods("GET")
+	router.HandleFunc("/auth/logout", authLogoutHandler).Methods("POST")
+	router.HandleFunc("/auth/local/login", localLoginHandler).Methods("POST")
+
+	// Proxy administration
+	router.HandleFunc("/api/admin/usage", usageHandler).Methods("GET")
+	router.HandleFunc("/api/config", effectiveConfigHandler).Methods("GET")
+	router.HandleFunc("/api/compare", compareClustersHandler).Methods("GET")
+	router.HandleFunc("/api/debug/capture", debugCaptureHandler).Methods("POST")
+	router.HandleFunc("/api/debug/captures", debugCapturesHandler).Methods("GET")
+	router.HandleFunc("/api/debug/faults", debugFaultsHandler).Methods("GET", "POST", "DELETE")
+	router.HandleFunc("/api/debug/faults/{id}", debugFaultHandler).Methods("DELETE")
+	router.HandleFunc("/api/debug/transport", debugTransportHandler).Methods("GET")
+	router.HandleFunc("/api/preferences/{scope}", preferencesHandler).Methods("GET", "PUT")
+	router.HandleFunc("/api/users", localUsersHandler).Methods("GET", "POST")
+	router.HandleFunc("/api/users/{username}", localUserHandler).Methods("GET", "PUT", "DELETE")
+	router.HandleFunc("/api/openapi.json", openAPIHandler).Methods("GET")
+	router.HandleFunc("/api/docs", swaggerUIHandler).Methods("GET")
+
+	// Connector list, metrics, offsets and name checks (must be registered before the generic connector proxy routes)
+	router.HandleFunc("/api/{cluster}/connectors/expanded", connectorListHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/stale", staleConnectorsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/metrics", connectorMetricsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/metrics/history", connectorMetricsHistoryHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/tasks/{task}/threads", connectorTaskThreadsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/offsets", connectorOffsetsHandler).Methods("GET", "DELETE", "PATCH")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/exists", connectorExistsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/restart-advanced", restartAdvancedHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/pause", connectorPauseHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/resume", connectorResumeHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/config/diff", connectorConfigDiffHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/config/resolved", connectorConfigResolvedHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/config", connectorConfigPatchHandler).Methods("PATCH")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/rotate-secret", rotateSecretHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/autorestart", connectorAutoRestartHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/errors", connectorErrorsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/triage", connectorTriageHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/history", connectorStateHistoryHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/restore-points", restorePointsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/restore-points/{id}/restore", restoreConnectorHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/restore-points", restorePointsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/limits", connectorLimitsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/health", connectorHealthHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/consumer-group", connectorConsumerGroupHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/schedules", connectorSchedulesHandler).Methods("GET", "POST")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/schedules/{id}", connectorScheduleHandler).Methods("DELETE")
+
+	// Console-side connector metadata (owner, team, tags)
+	router.HandleFunc("/api/{cluster}/connectors/metadata/bulk", bulkMetadataHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/metadata", connectorMetadataHandler).Methods("GET", "PUT")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/tags", connectorTagsHandler).Methods("PUT")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/annotations", connectorAnnotationsHandler).Methods("PUT")
+	router.HandleFunc("/api/{cluster}/tags", tagsHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/alerts", connectorAlertRulesHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/alerts/silences", alertSilencesHandler).Methods("GET", "POST")
+	router.HandleFunc("/api/{cluster}/alerts/silences/{id}", alertSilenceHandler).Methods("DELETE")
+	router.HandleFunc("/api/{cluster}/digests", summaryDigestsHandler).Methods("GET", "POST")
+	router.HandleFunc("/api/{cluster}/digests/{id}", summaryDigestHandler).Methods("DELETE")
+	router.HandleFunc("/api/{cluster}/digests/{id}/send", sendDigestHandler).Methods("POST")
+
+	// Connector lifecycle: stop (Connect 3.5+) releases tasks while keeping the config; resume restarts it
+	router.HandleFunc("/api/{cluster}/connectors/{name}/stop", proxyHandler).Methods("PUT")
+	router.HandleFunc("/api/{cluster}/connectors/{name}/resume", proxyHandler).Methods("PUT")
+
+	// Topic browser
+	router.HandleFunc("/api/{cluster}/topics/{topic}/messages", topicMessagesHandler).Methods("GET")
+
+	// Audit log
+	router.HandleFunc("/api/{cluster}/audit-logs", auditLogHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/audit-logs/stream", auditLogStreamHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/audit-logs/archives", auditArchivesHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/audit-logs/archives/{name}", auditArchiveHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/audit-logs/unexplained", unexplainedChangesHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/audit-logs/{id}", auditLogEntryHandler).Methods("GET")
+
+	// Connector templates
+	router.HandleFunc("/api/{cluster}/templates", listTemplatesHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/templates/{id}/render", renderTemplateHandler).Methods("POST")
+
+	// Cold-standby clusters
+	router.HandleFunc("/api/{cluster}/standby", standbyStatusHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/failover", failoverHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/maintenance", maintenanceHandler).Methods("GET", "POST")
+	router.HandleFunc("/api/{cluster}/deploy", deployHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/drift", driftHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/drift/desired", desiredStateHandler).Methods("GET", "PUT", "DELETE")
 
 	// Proxy routes for Kafka Connect
 	router.HandleFunc("/api/{cluster}/connectors", proxyHandler).Methods("GET", "POST")
 	router.HandleFunc("/api/{cluster}/connectors/", proxyHandler).Methods("GET", "POST")
 	router.HandleFunc("/api/{cluster}/connectors/{path:.*}", proxyHandler).Methods("GET", "POST", "PUT", "DELETE")
+	router.HandleFunc("/api/{cluster}/workers/detail", workersDetailHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/topology", topologyHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/search", searchHandler).Methods("GET")
 	router.HandleFunc("/api/{cluster}/workers", proxyHandler).Methods("GET")
 	router.HandleFunc("/api/{cluster}/workers/{path:.*}", proxyHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/admin/loggers", loggersHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/admin/loggers/{logger}", loggerLevelHandler).Methods("PUT")
 	router.HandleFunc("/api/{cluster}/admin", proxyHandler).Methods("GET", "POST")
 	router.HandleFunc("/api/{cluster}/admin/{path:.*}", proxyHandler).Methods("GET", "POST")
 	router.HandleFunc("/api/{cluster}/cluster/actions/{action}", clusterActionHandler).Methods("POST")
@@ -1045,40 +1335,450 @@ func main() {
 	router.HandleFunc("/api/{cluster}/summary", summaryHandler).Methods("GET")
 	// Plugins + validate
 	router.HandleFunc("/api/{cluster}/connector-plugins", proxyHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/connector-plugins/catalog", pluginCatalogHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/plugins/drift", pluginDriftHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/wizard/next-step", wizardNextStepHandler).Methods("POST")
+	router.HandleFunc("/api/{cluster}/connector-plugins/{plugin}/config/preflight", connectorPreflightHandler).Methods("PUT")
 	router.HandleFunc("/api/{cluster}/connector-plugins/{path:.*}", proxyHandler).Methods("GET", "PUT")
 	router.HandleFunc("/api/{cluster}/monitoring/summary", monitoringSummaryHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/monitoring/summary/diff", monitoringSummaryDiffHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/monitoring/balance", taskBalanceHandler).Methods("GET")
+	router.HandleFunc("/api/{cluster}/graphql", graphqlHandler).Methods("GET", "POST")
+	router.HandleFunc("/api/{cluster}/reports/availability", availabilityReportHandler).Methods("GET")
+}
 
-	// CORS configuration
-	// In production, set ALLOWED_ORIGINS environment variable to specific domains
-	// Supports comma-separated list: ALLOWED_ORIGINS=http://localhost:3000,https://yourdomain.com,https://staging.yourdomain.com
-	origins := []string{"*"}
-	if allowedOrigins != "*" && allowedOrigins != "" {
-		// Split by comma and trim whitespace from each origin
-		originList := strings.Split(allowedOrigins, ",")
-		origins = make([]string, 0, len(originList))
-		for _, origin := range originList {
-			trimmed := strings.TrimSpace(origin)
-			if trimmed != "" {
-				origins = append(origins, trimmed)
-			}
+func main() {
+	if fatal := logStartupConfig(checkStartupConfig(context.Background())); fatal > 0 {
+		log.Fatalf("config: %d fatal configuration problem(s); fix the settings above and restart", fatal)
+	}
+
+	router := mux.NewRouter()
+
+	var err error
+	if connectKerberos, err = loadKerberosAuth(); err != nil {
+		log.Fatalf("kerberos: %v", err)
+	}
+	if connectKerberos != nil {
+		log.Printf("Kerberos authentication enabled for Kafka Connect as %s", connectKerberos.principal)
+	}
+	if activeTracer, err = loadTracer(); err != nil {
+		log.Fatalf("tracing: %v", err)
+	}
+	if activeTracer != nil {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { log.Printf("tracing: %v", err) }))
+		log.Printf("Tracing enabled: exporting spans to %s over OTLP %s", redactURL(activeTracer.endpoint), activeTracer.protocol)
+	}
+	router.Use(traceRouteMiddleware)
+	// The network policy goes first, so denied clients reach neither login nor the API.
+	policy, err := loadNetworkPolicy()
+	if err != nil {
+		log.Fatalf("network policy: %v", err)
+	}
+	if policy != nil {
+		log.Printf("Network policy enabled (%s)", policy)
+		router.Use(policy.middleware)
+	}
+
+	limiter, err := newRateLimiterFromEnv()
+	if err != nil {
+		log.Fatalf("rate limiting: %v", err)
+	}
+	if limiter != nil {
+		router.Use(limiter.middleware)
+		go limiter.runPruner(time.Minute, nil)
+		log.Printf("Rate limiting enabled: %s requests/second per client", rateLimitRPS)
+	}
+	if requestBodyLimit, err = loadRequestBodyLimit(); err != nil {
+		log.Fatalf("request limits: %v", err)
+	}
+	router.Use(requestLimitsMiddleware)
+	if responseCompressionMin, err = loadCompressionMinBytes(); err != nil {
+		log.Fatalf("compression: %v", err)
+	}
+	router.Use(compressionMiddleware)
+	if redactionStreamBytes, err = loadRedactionStreamThreshold(); err != nil {
+		log.Fatalf("redaction: %v", err)
+	}
+	if oidcAuth, err = loadOIDCConfig(); err != nil {
+		log.Fatalf("auth: %v", err)
+	}
+	if oidcAuth != nil {
+		log.Printf("OIDC login enabled (issuer %s)", oidcAuth.issuer)
+	}
+	if localAuth, err = loadLocalAuth(); err != nil {
+		log.Fatalf("auth: %v", err)
+	}
+	if localAuth != nil {
+		if err := localUsers.load(); err != nil {
+			log.Fatalf("local auth: failed to load users: %v", err)
 		}
-		// If parsing resulted in empty list, fallback to wildcard for safety
-		if len(origins) == 0 {
-			origins = []string{"*"}
+		created, err := localUsers.bootstrap(localAdminUsername, localAdminPassword)
+		if err != nil {
+			log.Fatalf("local auth: %v", err)
+		}
+		if created {
+			log.Printf("local auth: created admin %q from LOCAL_ADMIN_PASSWORD", localAdminUsername)
+		}
+		log.Printf("Local login enabled: %d users", len(localUsers.list()))
+	}
+	router.Use(authMiddleware)
+	router.Use(clusterGuard)
+	if trafficCapture, err = loadCaptureBuffer(); err != nil {
+		log.Fatalf("debug capture: %v", err)
+	}
+	if trafficCapture.isEnabled() {
+		log.Printf("Debug capture enabled: recording the last %d API exchanges", trafficCapture.size)
+	}
+	router.Use(captureMiddleware)
+	if upstreamFaults, err = loadFaultInjector(); err != nil {
+		log.Fatalf("fault injection: %v", err)
+	}
+	if upstreamFaults.isEnabled() {
+		log.Printf("WARNING: fault injection is enabled; Kafka Connect calls can be made to fail through /api/debug/faults. Never enable FAULT_INJECTION in production")
+	}
+	router.Use(usageMiddleware)
+	router.Use(auditMiddleware)
+	if tenancy, err = loadTenancy(); err != nil {
+		log.Fatalf("tenancy: %v", err)
+	}
+	if tenancy != nil {
+		log.Printf("Tenancy enabled: %d groups with namespaces", len(tenancy.namespaces))
+	}
+	router.Use(tenancyMiddleware)
+	router.Use(standbyGuard)
+	router.Use(maintenanceGuard)
+	if err := maintenance.load(); err != nil {
+		log.Printf("maintenance: failed to load persisted state: %v", err)
+	}
+	if err := pauseSnapshots.load(); err != nil {
+		log.Printf("pause-all: failed to load persisted snapshots: %v", err)
+	}
+
+	maxAuditEntries, err := strconv.Atoi(auditLogMaxEntries)
+	if err != nil || maxAuditEntries <= 0 {
+		log.Fatalf("audit log: %v", &configError{name: "AUDIT_LOG_MAX_ENTRIES", value: auditLogMaxEntries})
+	}
+	if auditMaxBody, err = loadAuditMaxBody(); err != nil {
+		log.Fatalf("audit log: %v", err)
+	}
+	if auditLog, err = loadAuditLogger(maxAuditEntries); err != nil {
+		log.Fatalf("audit log: %v", err)
+	}
+	if shared, ok := auditLog.(*redisAuditLogger); ok {
+		go shared.follow(nil)
+		log.Printf("Sharing the audit log through Redis at %s", shared.client.addr)
+	}
+	if auditLogImport != "" {
+		imported, err := importAuditLog(auditLog, auditLogImport)
+		if err != nil {
+			log.Fatalf("AUDIT_LOG_IMPORT: %v", err)
 		}
+		if imported > 0 {
+			log.Printf("Imported %d audit entries from %s", imported, auditLogImport)
+		}
+	}
+	if auditArchive, err = loadAuditArchiver(); err != nil {
+		log.Fatalf("audit archive: %v", err)
+	}
+	if auditArchive != nil {
+		interval, _ := parseWindow(auditArchiveInterval, time.Hour)
+		go auditArchive.run(interval, nil)
+		log.Printf("Archiving audit entries older than %s to %s every %s", auditArchive.after, auditArchive.store, interval)
 	}
 
-	c := cors.New(cors.Options{
-		AllowedOrigins:   origins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: allowedOrigins != "*" && allowedOrigins != "", // Only allow credentials if origins are restricted
-	})
+	if err := usageStats.load(); err != nil {
+		log.Printf("usage: failed to load persisted statistics: %v", err)
+	}
+	go usageStats.run(time.Minute, nil)
+
+	if err := connectorMetadata.load(); err != nil {
+		log.Printf("metadata: failed to load persisted connector metadata: %v", err)
+	}
+	if err := userPreferences.load(); err != nil {
+		log.Printf("preferences: failed to load persisted preferences: %v", err)
+	}
+
+	if connectorSecrets, err = newSecretResolverFromEnv(); err != nil {
+		log.Fatalf("secrets: %v", err)
+	}
+	if err := secretRefs.load(); err != nil {
+		log.Printf("secrets: failed to load persisted placeholders: %v", err)
+	}
+
+	if clusterURLs, err = parseClusterURLs(connectClustersSpec); err != nil {
+		log.Fatalf("clusters: %v", err)
+	}
+	if clusterWorkers, err = parseClusterWorkers(connectWorkersSpec); err != nil {
+		log.Fatalf("clusters: %v", err)
+	}
+	primaries, err := parseStandbyClusters(standbyClustersSpec, clusterURLs)
+	if err != nil {
+		log.Fatalf("standby: %v", err)
+	}
+	standbys = newStandbyRegistry(primaries, time.Now)
+	if standbys.enabled() {
+		if err := standbys.load(); err != nil {
+			log.Printf("standby: failed to load persisted failover state: %v", err)
+		}
+		interval, err := parseWindow(standbySyncInterval, time.Minute)
+		if err != nil {
+			log.Fatalf("STANDBY_SYNC_INTERVAL: %v", err)
+		}
+		go standbys.run(interval, nil)
+		log.Printf("Syncing %d standby cluster(s) every %s", len(primaries), interval)
+	}
+
+	if err := reloadRedactionConfig(); err != nil {
+		log.Fatalf("redaction: %v", err)
+	}
+	watchRedactionReloads()
+
+	if err := reloadAdmissionPolicy(); err != nil {
+		log.Fatalf("admission: %v", err)
+	}
+	watchAdmissionReloads()
+	if connectorLimits, err = loadConnectorLimits(); err != nil {
+		log.Fatalf("connector limits: %v", err)
+	}
+	if connectorLimits.enabled() {
+		log.Printf("Connector limits enabled")
+	}
+
+	if connectorTemplates, err = loadConnectorTemplates(connectorTemplatesDir); err != nil {
+		log.Fatalf("connector templates: %v", err)
+	}
+
+	if alertDefaults, err = loadAlertDefaults(); err != nil {
+		log.Fatalf("alerts: %v", err)
+	}
+
+	configuredNotifier, err := newNotifierFromEnv()
+	if err != nil {
+		log.Fatalf("notifications: %v", err)
+	}
+	notifications = configuredNotifier
+	if notifications.enabled() {
+		statusObservers = append(statusObservers, notifications.observe)
+		metricsObservers = append(metricsObservers, notifications.observeMetrics)
+	}
+	exporter, err := loadMetricsExporter()
+	if err != nil {
+		log.Fatalf("metrics export: %v", err)
+	}
+	if exporter != nil {
+		statusObservers = append(statusObservers, exporter.observeStatuses)
+		metricsObservers = append(metricsObservers, exporter.observeMetrics)
+		go exporter.run(nil)
+		log.Printf("Exporting metrics to %s (%s) every %s", redactURL(exporter.url), exporter.format, exporter.interval)
+	}
+
+	events, err := loadEventPublisher()
+	if err != nil {
+		log.Fatalf("lifecycle events: %v", err)
+	}
+	if events != nil {
+		lifecycleEvents = events
+		statusObservers = append(statusObservers, events.observeStatuses)
+		go events.run(nil)
+		log.Printf("Publishing connector lifecycle events to Kafka topic %s", events.topic)
+	}
+
+	statusObservers = append(statusObservers, connectorErrors.observe)
+
+	stuckAfter, err := parseWindow(consumerGroupStuckAfter, 5*time.Minute)
+	if err != nil {
+		log.Fatalf("CONSUMER_GROUP_STUCK_AFTER: %v", err)
+	}
+	consumerGroupOffsets = newGroupOffsetTracker(stuckAfter, time.Now)
+
+	historyRetention, err := parseWindow(stateHistoryRetention, 7*24*time.Hour)
+	if err != nil {
+		log.Fatalf("STATE_HISTORY_RETENTION: %v", err)
+	}
+	connectorStateHistory = newStateHistory(historyRetention, time.Now)
+	if err := connectorStateHistory.load(); err != nil {
+		log.Printf("state history: failed to load persisted transitions: %v", err)
+	}
+	statusObservers = append(statusObservers, connectorStateHistory.observe)
+
+	snapshotRetention, err := parseWindow(summarySnapshotRetention, time.Hour)
+	if err != nil {
+		log.Fatalf("SUMMARY_SNAPSHOT_RETENTION: %v", err)
+	}
+	summarySnapshots = newSummarySnapshotStore(snapshotRetention, time.Now)
+	if err := summarySnapshots.load(); err != nil {
+		log.Printf("summary snapshots: failed to load persisted snapshots: %v", err)
+	}
+
+	autoRestartDefaults, autoRestartOn, err := loadAutoRestartDefaults()
+	if err != nil {
+		log.Fatalf("auto-restart: %v", err)
+	}
+	connectorAutoHealer = newAutoHealer(autoRestartDefaults, time.Now)
+	if autoRestartOn {
+		connectorAutoHealer.enabled = true
+		statusObservers = append(statusObservers, connectorAutoHealer.observe)
+		log.Printf("Auto-restart enabled (max %d attempts, backoff %s to %s)", autoRestartDefaults.MaxAttempts, autoRestartDefaults.InitialBackoff, autoRestartDefaults.MaxBackoff)
+	}
+
+	if err := connectorSchedules.load(); err != nil {
+		log.Printf("scheduler: failed to load persisted schedules: %v", err)
+	}
+	maxRestorePoints, err := loadRestorePointsMax()
+	if err != nil {
+		log.Fatalf("restore points: %v", err)
+	}
+	restorePoints = newRestorePointStore(maxRestorePoints, time.Now)
+	if err := restorePoints.load(); err != nil {
+		log.Printf("restore points: failed to load persisted restore points: %v", err)
+	}
+	if err := alertSilences.load(); err != nil {
+		log.Printf("silences: failed to load persisted silences: %v", err)
+	}
+	scheduleInterval, err := parseWindow(schedulerInterval, 30*time.Second)
+	if err != nil {
+		log.Fatalf("SCHEDULER_INTERVAL: %v", err)
+	}
+	go connectorSchedules.run(scheduleInterval, nil)
+
+	if err := summaryDigests.load(); err != nil {
+		log.Printf("digests: failed to load persisted digests: %v", err)
+	}
+	go summaryDigests.run(scheduleInterval, nil)
+
+	if err := loggerReverts.load(); err != nil {
+		log.Printf("loggers: failed to load pending reverts: %v", err)
+	}
+	go loggerReverts.run(scheduleInterval, nil)
+
+	transportSettings, err := loadTransportSettings()
+	if err != nil {
+		log.Fatalf("upstream: %v", err)
+	}
+	upstreamHTTP.configure(transportSettings)
+	upstream, err := loadUpstreamPolicy()
+	if err != nil {
+		log.Fatalf("upstream: %v", err)
+	}
+	connectResilience.setPolicy(upstream)
+	limits, err := loadUpstreamLimits()
+	if err != nil {
+		log.Fatalf("upstream: %v", err)
+	}
+	connectLimiter.setLimits(limits)
+	if limits.MaxConcurrent > 0 {
+		log.Printf("Kafka Connect calls limited to %d concurrent per cluster, %d queued for up to %s", limits.MaxConcurrent, limits.MaxQueued, limits.QueueTimeout)
+	}
+	if upstreamTimeouts, err = loadUpstreamTimeouts(); err != nil {
+		log.Fatalf("upstream timeouts: %v", err)
+	}
+
+	if sharedCache, err = loadCache(); err != nil {
+		log.Fatalf("cache: %v", err)
+	}
+	if _, ok := sharedCache.(*redisCache); ok {
+		log.Printf("Sharing caches through Redis at %s", redactURL(cacheRedisURL))
+	}
+	pluginCatalog = newPluginCatalogCache(pluginCatalogTTL, sharedCache)
+	if configCacheTTL == "0" {
+		configCache = newResponseCache(0, sharedCache)
+	} else {
+		ttl, err := parseWindow(configCacheTTL, 5*time.Second)
+		if err != nil {
+			log.Fatalf("CONFIG_CACHE_TTL: %v", err)
+		}
+		configCache = newResponseCache(ttl, sharedCache)
+	}
+	if validationCacheTTL == "0" {
+		validationCache = newResponseCache(0, sharedCache)
+	} else {
+		ttl, err := parseWindow(validationCacheTTL, 30*time.Second)
+		if err != nil {
+			log.Fatalf("VALIDATION_CACHE_TTL: %v", err)
+		}
+		validationCache = newResponseCache(ttl, sharedCache)
+	}
+
+	summaryTTL, err := loadSummaryCacheTTL()
+	if err != nil {
+		log.Fatalf("SUMMARY_CACHE_TTL: %v", err)
+	}
+	monitoringSummaryCache = newSummaryCache(summaryTTL, summaryCacheMaxStale, sharedCache, time.Now, fetchCurrentMonitoringSummary)
+	queryLimits, err := loadGraphQLLimits()
+	if err != nil {
+		log.Fatalf("graphql: %v", err)
+	}
+	graphqlService = newGraphQLServer(queryLimits)
+
+	if urls := splitList(jolokiaURLs); len(urls) > 0 {
+		interval, err := parseWindow(metricsPollInterval, 15*time.Second)
+		if err != nil {
+			log.Fatalf("METRICS_POLL_INTERVAL: %v", err)
+		}
+		retention, err := parseWindow(metricsRetention, time.Hour)
+		if err != nil {
+			log.Fatalf("METRICS_RETENTION: %v", err)
+		}
+		connectorMetricsCollector = newMetricsCollector(urls, retention, time.Now)
+		go connectorMetricsCollector.run(interval, nil)
+		log.Printf("Collecting Jolokia metrics from %d worker(s) every %s", len(urls), interval)
+	}
+
+	if len(statusObservers) > 0 && monitoringPollInterval != "0" {
+		interval, err := parseWindow(monitoringPollInterval, 30*time.Second)
+		if err != nil {
+			log.Fatalf("MONITORING_POLL_INTERVAL: %v", err)
+		}
+		go runMonitoringPoller(interval, nil)
+	}
+
+	if err := driftDetection.load(); err != nil {
+		log.Printf("drift: failed to load persisted desired states: %v", err)
+	}
+	if driftCheckInterval != "0" {
+		interval, err := parseWindow(driftCheckInterval, 5*time.Minute)
+		if err != nil {
+			log.Fatalf("DRIFT_CHECK_INTERVAL: %v", err)
+		}
+		go driftDetection.run(interval, nil)
+	}
+
+	if err := auditReconciliation.load(); err != nil {
+		log.Printf("audit reconcile: failed to load persisted unexplained changes: %v", err)
+	}
+	if auditReconcileInterval != "0" {
+		interval, err := parseWindow(auditReconcileInterval, 5*time.Minute)
+		if err != nil {
+			log.Fatalf("AUDIT_RECONCILE_INTERVAL: %v", err)
+		}
+		go auditReconciliation.run(interval, nil)
+	}
+
+	registerRoutes(router)
+	webUI, err := loadWebUI()
+	if err != nil {
+		log.Fatalf("web UI: %v", err)
+	}
+	if webUI != nil {
+		// Only requests no route matches reach the UI, so the API keeps precedence.
+		router.NotFoundHandler = compressionMiddleware(webUIHandler(webUI))
+		log.Printf("Serving the embedded web UI at /")
+	}
+
+	corsOptions, err := loadCORSOptions()
+	if err != nil {
+		log.Fatalf("CORS: %v", err)
+	}
+	c := cors.New(corsOptions)
+
+	legacySunset, err := parseLegacyAPISunset(legacyAPISunset)
+	if err != nil {
+		log.Fatalf("api versioning: %v", err)
+	}
 
-	handler := c.Handler(router)
+	handler := tracingMiddleware(c.Handler(apiVersionMiddleware(router, legacySunset)))
 
-	port := getEnv("PORT", "8080")
-	log.Printf("Starting proxy server on port %s", port)
-	log.Printf("Forwarding to Kafka Connect at %s", connectURL)
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	log.Printf("Starting proxy server on port %s", listenPort)
+	log.Printf("Forwarding to Kafka Connect at %s", redactURL(connectURL))
+	log.Fatal(http.ListenAndServe(":"+listenPort, handler))
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -179,6 +180,51 @@ func TestHealthHandler(t *testing.T) {
 	})
 }
 
+func TestRequestUserTrustsHeadersOnlyWhenConfigured(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/default/connectors", nil)
+	req.Header.Set("X-Forwarded-User", "mallory")
+	req.Header.Set("X-Forwarded-Groups", "platform-admins")
+	if user, groups := requestUser(req), requestGroups(req); user != "anonymous" || groups != nil {
+		t.Fatalf("expected forged identity headers to be ignored, got %q %v", user, groups)
+	}
+
+	withTestTrustedAuthHeaders(t)
+	if user, groups := requestUser(req), requestGroups(req); user != "mallory" || len(groups) != 1 {
+		t.Fatalf("expected the trusted proxy's identity, got %q %v", user, groups)
+	}
+	signedIn := req.WithContext(context.WithValue(req.Context(), authUserKey{}, AuthUser{Username: "alice"}))
+	if user := requestUser(signedIn); user != "alice" {
+		t.Fatalf("expected the signed-in user to win over headers, got %q", user)
+	}
+}
+
+func TestProxyHandlerDoesNotForwardCookies(t *testing.T) {
+	var cookie, forwarded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, forwarded = r.Header.Get("Cookie"), r.Header.Get("X-Forwarded-User")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "session-token"})
+	req.Header.Set("X-Forwarded-User", "alice")
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	proxyHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if cookie != "" {
+		t.Fatalf("expected the session cookie to stay with the proxy, Kafka Connect got %q", cookie)
+	}
+	if forwarded != "alice" {
+		t.Fatalf("expected other headers to be forwarded, got %q", forwarded)
+	}
+}
+
 func TestProxyHandler_ForwardsRequestsAndRedacts(t *testing.T) {
 	responses := map[string]testutils.Response{
 		"GET /connectors": {
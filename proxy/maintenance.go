@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const maintenanceStateFile = "maintenance.json"
+
+var (
+	// maintenanceGroups lists the groups allowed to switch maintenance mode. When unset,
+	// any caller that passes authentication may.
+	maintenanceGroups = getEnv("MAINTENANCE_GROUPS", "")
+
+	maintenance = newMaintenanceRegistry(time.Now)
+)
+
+// MaintenanceState says whether a cluster is in maintenance mode, and why.
+type MaintenanceState struct {
+	Cluster   string     `json:"cluster"`
+	Enabled   bool       `json:"enabled"`
+	Reason    string     `json:"reason,omitempty"`
+	EnabledBy string     `json:"enabledBy,omitempty"`
+	EnabledAt *time.Time `json:"enabledAt,omitempty"`
+}
+
+// maintenanceRegistry keeps the clusters in maintenance mode, persisted in DATA_DIR so
+// the mode survives a restart.
+type maintenanceRegistry struct {
+	mu     sync.RWMutex
+	states map[string]MaintenanceState
+	now    func() time.Time
+}
+
+func newMaintenanceRegistry(now func() time.Time) *maintenanceRegistry {
+	return &maintenanceRegistry{states: make(map[string]MaintenanceState), now: now}
+}
+
+func (m *maintenanceRegistry) load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return loadJSON(maintenanceStateFile, &m.states)
+}
+
+func (m *maintenanceRegistry) state(cluster string) MaintenanceState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if state, ok := m.states[cluster]; ok {
+		return state
+	}
+	return MaintenanceState{Cluster: cluster}
+}
+
+// active reports whether mutations of cluster are blocked.
+func (m *maintenanceRegistry) active(cluster string) bool {
+	return m.state(cluster).Enabled
+}
+
+// set switches maintenance mode for cluster. Enabling an already enabled cluster
+// updates the reason but keeps who enabled it and when.
+func (m *maintenanceRegistry) set(cluster string, enabled bool, reason, user string) (MaintenanceState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, existed := m.states[cluster]
+	if !enabled {
+		delete(m.states, cluster)
+		if err := saveJSON(maintenanceStateFile, m.states); err != nil {
+			if existed {
+				m.states[cluster] = previous
+			}
+			return MaintenanceState{}, err
+		}
+		return MaintenanceState{Cluster: cluster}, nil
+	}
+
+	state := previous
+	if !previous.Enabled {
+		now := m.now().UTC()
+		state = MaintenanceState{Cluster: cluster, Enabled: true, EnabledBy: user, EnabledAt: &now}
+	}
+	state.Reason = reason
+	m.states[cluster] = state
+	if err := saveJSON(maintenanceStateFile, m.states); err != nil {
+		if existed {
+			m.states[cluster] = previous
+		} else {
+			delete(m.states, cluster)
+		}
+		return MaintenanceState{}, err
+	}
+	return state, nil
+}
+
+// readOnlyRequest reports whether a request to rest, the path below /api/{cluster},
+// cannot change the cluster: reads, the dry runs the proxy simulates and the read-only
+// POST/PUT endpoints (GraphQL only serves queries).
+func readOnlyRequest(r *http.Request, rest string) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if dryRunServed(r) || rest == "/graphql" {
+		return true
+	}
+	return strings.HasSuffix(rest, "/config/diff") || strings.HasSuffix(rest, "/config/validate") || strings.HasSuffix(rest, "/config/preflight") ||
+		(strings.HasPrefix(rest, "/templates/") && strings.HasSuffix(rest, "/render")) || rest == "/wizard/next-step"
+}
+
+// maintenanceExempt reports whether a request may pass while its cluster is in
+// maintenance: read-only requests, the switch itself, alert silences, which are how a
+// maintenance keeps from paging anyone, and health digests, which touch no connector.
+func maintenanceExempt(r *http.Request, rest string) bool {
+	return readOnlyRequest(r, rest) || rest == "/maintenance" || strings.HasPrefix(rest, "/alerts/silences") || strings.HasPrefix(rest, "/digests")
+}
+
+// maintenanceGuard answers 423 Locked to every mutation of a cluster in maintenance mode.
+func maintenanceGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cluster := mux.Vars(r)["cluster"]
+		if cluster == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rest := strings.TrimPrefix(r.URL.Path, "/api/"+cluster)
+		if maintenanceExempt(r, rest) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		state := maintenance.state(cluster)
+		if !state.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeJSON(w, http.StatusLocked, map[string]interface{}{
+			"error":       "maintenance_mode",
+			"message":     fmt.Sprintf("cluster %s is in maintenance mode: %s", cluster, state.Reason),
+			"maintenance": state,
+		})
+	})
+}
+
+type maintenanceRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+// maintenanceHandler reports (GET) or switches (POST) maintenance mode for a cluster.
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, maintenance.state(cluster))
+		return
+	}
+
+	if maintenanceGroups != "" && !inAnyGroup(r, maintenanceGroups) {
+		writeJSONError(w, http.StatusForbidden, "forbidden",
+			"switching maintenance mode requires membership of "+strings.Join(splitList(maintenanceGroups), ", "))
+		return
+	}
+
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "body must be JSON: "+err.Error())
+		return
+	}
+	if req.Enabled == nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "enabled is required")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if *req.Enabled && req.Reason == "" {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "a reason is required to enable maintenance mode")
+		return
+	}
+
+	user := requestUser(r)
+	state, err := maintenance.set(cluster, *req.Enabled, req.Reason, user)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "persist_failed", err.Error())
+		return
+	}
+	if state.Enabled {
+		log.Printf("maintenance: %s enabled maintenance mode on %s: %s", user, cluster, state.Reason)
+	} else {
+		log.Printf("maintenance: %s disabled maintenance mode on %s", user, cluster)
+	}
+	writeJSON(w, http.StatusOK, state)
+}
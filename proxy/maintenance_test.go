@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestMaintenance(t *testing.T) {
+	t.Helper()
+	originalRegistry, originalDir, originalGroups := maintenance, dataDir, maintenanceGroups
+	t.Cleanup(func() { maintenance, dataDir, maintenanceGroups = originalRegistry, originalDir, originalGroups })
+	dataDir = t.TempDir()
+	maintenance = newMaintenanceRegistry(func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) })
+}
+
+func TestMaintenanceRegistryPersists(t *testing.T) {
+	withTestMaintenance(t)
+
+	if _, err := maintenance.set("default", true, "Kafka upgrade", "alice"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	// Updating the reason keeps who enabled it.
+	if state, _ := maintenance.set("default", true, "Kafka upgrade, extended", "bob"); state.EnabledBy != "alice" || state.Reason != "Kafka upgrade, extended" {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	reloaded := newMaintenanceRegistry(time.Now)
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	state := reloaded.state("default")
+	if !state.Enabled || state.EnabledBy != "alice" || state.EnabledAt == nil || !reloaded.active("default") || reloaded.active("dr") {
+		t.Fatalf("expected the mode to survive a restart, got %+v", state)
+	}
+
+	if state, err := maintenance.set("default", false, "", "alice"); err != nil || state.Enabled {
+		t.Fatalf("expected maintenance to be disabled, got %+v %v", state, err)
+	}
+	if maintenance.active("default") {
+		t.Fatalf("expected the cluster to accept changes again")
+	}
+}
+
+func TestMaintenanceGuard(t *testing.T) {
+	withTestMaintenance(t)
+	maintenance.set("default", true, "Kafka upgrade", "alice")
+
+	router := mux.NewRouter()
+	router.Use(maintenanceGuard)
+	reached := ""
+	handler := func(w http.ResponseWriter, r *http.Request) { reached = r.Method + " " + r.URL.Path }
+	router.HandleFunc("/api/{cluster}/connectors", handler)
+	router.HandleFunc("/api/{cluster}/connectors/{path:.*}", handler)
+	router.HandleFunc("/api/{cluster}/connector-plugins/{path:.*}", handler)
+	router.HandleFunc("/api/{cluster}/maintenance", handler)
+	router.HandleFunc("/api/{cluster}/alerts/silences", handler)
+
+	for _, tc := range []struct {
+		method, path string
+		allowed      bool
+	}{
+		{http.MethodPut, "/api/default/connectors/orders/pause", false},
+		{http.MethodDelete, "/api/default/connectors/orders", false},
+		{http.MethodPut, "/api/default/connectors/orders/metadata", false},
+		{http.MethodGet, "/api/default/connectors/orders/status", true},
+		{http.MethodDelete, "/api/default/connectors/orders?dryRun=true", true},
+		{http.MethodPut, "/api/default/connectors/orders/config?dryRun=true", true},
+		{http.MethodPut, "/api/default/connectors/orders/pause?dryRun=true", false},
+		{http.MethodPost, "/api/default/connectors?dryRun=true", false},
+		{http.MethodPost, "/api/default/connectors/orders/restart?dryRun=true", false},
+		{http.MethodPost, "/api/default/connectors/orders/config/diff", true},
+		{http.MethodPut, "/api/default/connector-plugins/FileStreamSink/config/validate", true},
+		{http.MethodPut, "/api/default/connector-plugins/FileStreamSink/config/preflight", true},
+		{http.MethodPost, "/api/default/maintenance", true},
+		{http.MethodPost, "/api/default/alerts/silences", true},
+		{http.MethodPut, "/api/dr/connectors/orders/pause", true},
+	} {
+		reached = ""
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+		if tc.allowed {
+			if reached == "" {
+				t.Errorf("expected %s %s to pass, got %d", tc.method, tc.path, rr.Code)
+			}
+			continue
+		}
+		if reached != "" || rr.Code != http.StatusLocked || !strings.Contains(rr.Body.String(), "Kafka upgrade") {
+			t.Errorf("expected %s %s to be locked, got %d %s", tc.method, tc.path, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	withTestMaintenance(t)
+	maintenanceGroups = "platform-admins"
+
+	do := func(method, body, groups string) (*httptest.ResponseRecorder, MaintenanceState) {
+		req := httptest.NewRequest(method, "/api/default/maintenance", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		req.Header.Set("X-Forwarded-User", "alice")
+		if groups != "" {
+			req.Header.Set("X-Forwarded-Groups", groups)
+		}
+		rr := httptest.NewRecorder()
+		maintenanceHandler(rr, req)
+		var state MaintenanceState
+		json.Unmarshal(rr.Body.Bytes(), &state)
+		return rr, state
+	}
+
+	// Without TRUST_AUTH_HEADERS a forwarded group is just a header anyone can send.
+	if rr, _ := do(http.MethodPost, `{"enabled":true,"reason":"upgrade"}`, "platform-admins"); rr.Code != http.StatusForbidden || maintenance.active("default") {
+		t.Fatalf("expected a forged MAINTENANCE_GROUPS header to be refused, got %d", rr.Code)
+	}
+	withTestTrustedAuthHeaders(t)
+	if rr, _ := do(http.MethodPost, `{"enabled":true,"reason":"upgrade"}`, "developers"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a caller outside MAINTENANCE_GROUPS to be refused, got %d", rr.Code)
+	}
+	if rr, _ := do(http.MethodPost, `{"enabled":true}`, "platform-admins"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a reason to be required, got %d", rr.Code)
+	}
+	if rr, _ := do(http.MethodPost, `{"reason":"upgrade"}`, "platform-admins"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected enabled to be required, got %d", rr.Code)
+	}
+
+	rr, state := do(http.MethodPost, `{"enabled":true,"reason":"Kafka 3.7 upgrade"}`, "platform-admins")
+	if rr.Code != http.StatusOK || !state.Enabled || state.EnabledBy != "alice" || state.Reason != "Kafka 3.7 upgrade" {
+		t.Fatalf("unexpected response %d %+v", rr.Code, state)
+	}
+	if _, state := do(http.MethodGet, "", ""); !state.Enabled || state.Cluster != "default" {
+		t.Fatalf("expected GET to report the mode, got %+v", state)
+	}
+	if _, state := do(http.MethodPost, `{"enabled":false}`, "platform-admins"); state.Enabled || maintenance.active("default") {
+		t.Fatalf("expected maintenance to be disabled, got %+v", state)
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const metadataStoreFile = "connector-metadata.json"
+
+const auditActionUpdateMetadata = "UPDATE_METADATA"
+
+var connectorMetadata = newMetadataStore(time.Now)
+
+// ConnectorMetadata is console-side ownership information attached to a connector. It
+// is stored by the proxy and never sent to Kafka Connect.
+type ConnectorMetadata struct {
+	Owner       string                `json:"owner,omitempty"`
+	Team        string                `json:"team,omitempty"`
+	Tags        []string              `json:"tags"`
+	Alerts      *AlertRules           `json:"alerts,omitempty"`
+	AutoRestart *AutoRestartPolicy    `json:"autoRestart,omitempty"`
+	Annotations *ConnectorAnnotations `json:"annotations,omitempty"`
+	UpdatedAt   time.Time             `json:"updatedAt,omitempty"`
+	UpdatedBy   string                `json:"updatedBy,omitempty"`
+}
+
+// metadataPatch describes a change to connector metadata. Nil fields are left untouched;
+// Tags replaces the tag set, AddTags and RemoveTags adjust it. Alerts, AutoRestart and
+// Annotations replace the alert rule and auto-restart overrides and the on-call notes;
+// an empty object clears them.
+type metadataPatch struct {
+	Owner       *string               `json:"owner,omitempty"`
+	Team        *string               `json:"team,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	AddTags     []string              `json:"addTags,omitempty"`
+	RemoveTags  []string              `json:"removeTags,omitempty"`
+	Alerts      *AlertRules           `json:"alerts,omitempty"`
+	AutoRestart *AutoRestartPolicy    `json:"autoRestart,omitempty"`
+	Annotations *ConnectorAnnotations `json:"annotations,omitempty"`
+}
+
+func (p metadataPatch) empty() bool {
+	return p.Owner == nil && p.Team == nil && p.Tags == nil && len(p.AddTags) == 0 && len(p.RemoveTags) == 0 && p.Alerts == nil && p.AutoRestart == nil && p.Annotations == nil
+}
+
+func (p metadataPatch) validate() error {
+	if err := p.Alerts.validate(); err != nil {
+		return err
+	}
+	if err := p.AutoRestart.validate(); err != nil {
+		return err
+	}
+	return p.Annotations.validate()
+}
+
+// normalizeTags trims, de-duplicates and sorts tags, dropping empty ones.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (p metadataPatch) apply(meta ConnectorMetadata) ConnectorMetadata {
+	if p.Owner != nil {
+		meta.Owner = strings.TrimSpace(*p.Owner)
+	}
+	if p.Team != nil {
+		meta.Team = strings.TrimSpace(*p.Team)
+	}
+	tags := meta.Tags
+	if p.Tags != nil {
+		tags = p.Tags
+	}
+	tags = append(append([]string(nil), tags...), p.AddTags...)
+	if len(p.RemoveTags) > 0 {
+		remove := make(map[string]bool, len(p.RemoveTags))
+		for _, tag := range p.RemoveTags {
+			remove[strings.TrimSpace(tag)] = true
+		}
+		kept := tags[:0]
+		for _, tag := range tags {
+			if !remove[strings.TrimSpace(tag)] {
+				kept = append(kept, tag)
+			}
+		}
+		tags = kept
+	}
+	meta.Tags = normalizeTags(tags)
+	if p.Alerts != nil {
+		if p.Alerts.empty() {
+			meta.Alerts = nil
+		} else {
+			alerts := *p.Alerts
+			meta.Alerts = &alerts
+		}
+	}
+	if p.AutoRestart != nil {
+		if p.AutoRestart.empty() {
+			meta.AutoRestart = nil
+		} else {
+			policy := *p.AutoRestart
+			meta.AutoRestart = &policy
+		}
+	}
+	if p.Annotations != nil {
+		if annotations := p.Annotations.normalize(); annotations.empty() {
+			meta.Annotations = nil
+		} else {
+			meta.Annotations = &annotations
+		}
+	}
+	return meta
+}
+
+func (m ConnectorMetadata) hasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataStore keeps connector metadata per cluster and persists it to DATA_DIR.
+type metadataStore struct {
+	mu       sync.RWMutex
+	now      func() time.Time
+	clusters map[string]map[string]ConnectorMetadata
+}
+
+func newMetadataStore(now func() time.Time) *metadataStore {
+	return &metadataStore{now: now, clusters: make(map[string]map[string]ConnectorMetadata)}
+}
+
+func (s *metadataStore) load() error {
+	clusters := make(map[string]map[string]ConnectorMetadata)
+	if err := loadJSON(metadataStoreFile, &clusters); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clusters = clusters
+	return nil
+}
+
+// saveLocked persists the store. Callers must hold s.mu.
+func (s *metadataStore) saveLocked() error {
+	return saveJSON(metadataStoreFile, s.clusters)
+}
+
+// get returns the metadata for a connector; missing connectors get an empty record.
+func (s *metadataStore) get(cluster, connector string) ConnectorMetadata {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	meta, ok := s.clusters[cluster][connector]
+	if !ok {
+		return ConnectorMetadata{Tags: []string{}}
+	}
+	return meta
+}
+
+// all returns a copy of the metadata for every connector of a cluster.
+func (s *metadataStore) all(cluster string) map[string]ConnectorMetadata {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]ConnectorMetadata, len(s.clusters[cluster]))
+	for name, meta := range s.clusters[cluster] {
+		result[name] = meta
+	}
+	return result
+}
+
+// update applies patch to each connector in a single persisted write and returns the
+// resulting metadata keyed by connector name.
+func (s *metadataStore) update(cluster string, connectors []string, patch metadataPatch, user string) (map[string]ConnectorMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, ok := s.clusters[cluster]
+	if !ok {
+		entries = make(map[string]ConnectorMetadata)
+		s.clusters[cluster] = entries
+	}
+
+	now := s.now().UTC()
+	updated := make(map[string]ConnectorMetadata, len(connectors))
+	for _, name := range connectors {
+		meta := patch.apply(entries[name])
+		meta.UpdatedAt = now
+		meta.UpdatedBy = user
+		entries[name] = meta
+		updated[name] = meta
+	}
+
+	if err := s.saveLocked(); err != nil {
+		return updated, err
+	}
+	return updated, nil
+}
+
+// connectorMetadataHandler serves GET and PUT for /api/{cluster}/connectors/{name}/metadata.
+func connectorMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, connectorMetadata.get(cluster, name))
+		return
+	}
+
+	var patch metadataPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON metadata object")
+		return
+	}
+	if err := patch.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	updated, err := connectorMetadata.update(cluster, []string{name}, patch, requestUser(r))
+	if err != nil {
+		log.Printf("metadata: failed to persist metadata for %s: %v", name, err)
+		writeJSONError(w, http.StatusInternalServerError, "metadata_store_failed", "failed to persist connector metadata")
+		return
+	}
+	recordAudit(r, auditActionUpdateMetadata, name, http.StatusOK, nil)
+	writeJSON(w, http.StatusOK, updated[name])
+}
+
+// metadataFilter selects connectors for a bulk edit. All set fields must match.
+type metadataFilter struct {
+	Pattern string `json:"pattern,omitempty"` // glob on the connector name, e.g. "orders-*"
+	Owner   string `json:"owner,omitempty"`
+	Team    string `json:"team,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+}
+
+func (f metadataFilter) empty() bool {
+	return f.Pattern == "" && f.Owner == "" && f.Team == "" && f.Tag == ""
+}
+
+func (f metadataFilter) matches(name string, meta ConnectorMetadata) bool {
+	if f.Pattern != "" {
+		if ok, _ := path.Match(f.Pattern, name); !ok {
+			return false
+		}
+	}
+	if f.Owner != "" && meta.Owner != f.Owner {
+		return false
+	}
+	if f.Team != "" && meta.Team != f.Team {
+		return false
+	}
+	if f.Tag != "" && !meta.hasTag(f.Tag) {
+		return false
+	}
+	return true
+}
+
+// bulkMetadataRequest is the body of POST /api/{cluster}/connectors/metadata/bulk.
+// Exactly one of Connectors and Filter selects the targets.
+type bulkMetadataRequest struct {
+	Connectors []string        `json:"connectors,omitempty"`
+	Filter     *metadataFilter `json:"filter,omitempty"`
+	DryRun     bool            `json:"dryRun,omitempty"`
+	metadataPatch
+}
+
+// BulkMetadataResult reports which connectors a bulk edit touched.
+type BulkMetadataResult struct {
+	DryRun   bool                         `json:"dryRun"`
+	Matched  int                          `json:"matched"`
+	Updated  map[string]ConnectorMetadata `json:"updated"`
+	NotFound []string                     `json:"notFound"`
+}
+
+func (req bulkMetadataRequest) validate() error {
+	hasNames, hasFilter := len(req.Connectors) > 0, req.Filter != nil && !req.Filter.empty()
+	if hasNames == hasFilter {
+		return errors.New("specify either connectors or a non-empty filter")
+	}
+	if hasFilter && req.Filter.Pattern != "" {
+		if _, err := path.Match(req.Filter.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid filter pattern %q", req.Filter.Pattern)
+		}
+	}
+	if req.metadataPatch.empty() {
+		return errors.New("no metadata changes specified")
+	}
+	return req.metadataPatch.validate()
+}
+
+// bulkMetadataHandler sets owner, team and tags on many connectors in one request.
+func bulkMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+
+	var req bulkMetadataRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON object")
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	req.DryRun = req.DryRun || isDryRun(r)
+
+	names, err := fetchConnectorNames(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+
+	result := BulkMetadataResult{DryRun: req.DryRun, Updated: map[string]ConnectorMetadata{}, NotFound: []string{}}
+	var targets []string
+	if len(req.Connectors) > 0 {
+		existing := make(map[string]bool, len(names))
+		for _, name := range names {
+			existing[name] = true
+		}
+		for _, name := range req.Connectors {
+			if existing[name] {
+				targets = append(targets, name)
+			} else {
+				result.NotFound = append(result.NotFound, name)
+			}
+		}
+	} else {
+		current := connectorMetadata.all(cluster)
+		for _, name := range names {
+			if req.Filter.matches(name, current[name]) {
+				targets = append(targets, name)
+			}
+		}
+	}
+	sort.Strings(targets)
+	result.Matched = len(targets)
+
+	if req.DryRun {
+		for _, name := range targets {
+			result.Updated[name] = req.metadataPatch.apply(connectorMetadata.get(cluster, name))
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	updated, err := connectorMetadata.update(cluster, targets, req.metadataPatch, requestUser(r))
+	if err != nil {
+		log.Printf("metadata: failed to persist bulk update for %d connectors: %v", len(targets), err)
+		writeJSONError(w, http.StatusInternalServerError, "metadata_store_failed", "failed to persist connector metadata")
+		return
+	}
+	for _, name := range targets {
+		recordAudit(r, auditActionUpdateMetadata, name, http.StatusOK, map[string]interface{}{"bulk": true})
+	}
+	result.Updated = updated
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestMetadataStore(t *testing.T) *metadataStore {
+	t.Helper()
+	original := connectorMetadata
+	store := newMetadataStore(func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) })
+	connectorMetadata = store
+	t.Cleanup(func() { connectorMetadata = original })
+	return store
+}
+
+func TestMetadataPatchApply(t *testing.T) {
+	owner := " alice "
+	meta := metadataPatch{Owner: &owner, AddTags: []string{"pii", "orders", "pii"}}.apply(ConnectorMetadata{Team: "data", Tags: []string{"legacy"}})
+	if meta.Owner != "alice" || meta.Team != "data" {
+		t.Fatalf("unexpected owner/team: %+v", meta)
+	}
+	if got := meta.Tags; len(got) != 3 || got[0] != "legacy" || got[1] != "orders" || got[2] != "pii" {
+		t.Fatalf("unexpected tags: %v", got)
+	}
+
+	meta = metadataPatch{Tags: []string{"a", "b"}, RemoveTags: []string{"b"}}.apply(meta)
+	if len(meta.Tags) != 1 || meta.Tags[0] != "a" {
+		t.Fatalf("expected replace then remove, got %v", meta.Tags)
+	}
+}
+
+func TestMetadataStorePersists(t *testing.T) {
+	original := dataDir
+	dataDir = t.TempDir()
+	t.Cleanup(func() { dataDir = original })
+
+	team := "payments"
+	store := newMetadataStore(time.Now)
+	if _, err := store.update("default", []string{"alpha"}, metadataPatch{Team: &team}, "alice"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	reloaded := newMetadataStore(time.Now)
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if meta := reloaded.get("default", "alpha"); meta.Team != "payments" || meta.UpdatedBy != "alice" {
+		t.Fatalf("expected persisted metadata, got %+v", meta)
+	}
+}
+
+func TestBulkMetadataHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`["orders-sink","orders-source","payments-sink"]`))
+	}))
+	defer server.Close()
+	restore := withTestConnectURL(t, server)
+	defer restore()
+	store := withTestMetadataStore(t)
+	logger := withTestAuditLog(t, 10)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/connectors/metadata/bulk", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		bulkMetadataHandler(rr, req)
+		return rr
+	}
+
+	rr := post(`{"filter":{"pattern":"orders-*"},"team":"orders","addTags":["tier1"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result BulkMetadataResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Matched != 2 || store.get("default", "orders-sink").Team != "orders" || store.get("default", "payments-sink").Team != "" {
+		t.Fatalf("unexpected bulk result: %+v", result)
+	}
+	if len(logger.Query(AuditFilter{Action: auditActionUpdateMetadata})) != 2 {
+		t.Fatalf("expected one audit entry per connector")
+	}
+
+	rr = post(`{"connectors":["payments-sink","ghost"],"owner":"bob","dryRun":true}`)
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !result.DryRun || result.Matched != 1 || len(result.NotFound) != 1 || result.Updated["payments-sink"].Owner != "bob" {
+		t.Fatalf("unexpected dry run result: %+v", result)
+	}
+	if store.get("default", "payments-sink").Owner != "" {
+		t.Fatalf("dry run must not modify the store")
+	}
+
+	rr = post(`{"filter":{"tag":"tier1"},"removeTags":["tier1"]}`)
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Matched != 2 || len(store.get("default", "orders-source").Tags) != 0 {
+		t.Fatalf("expected tag filter to select tagged connectors, got %+v", result)
+	}
+
+	for _, body := range []string{`{"team":"x"}`, `{"connectors":["a"],"filter":{"team":"x"},"team":"y"}`, `{"connectors":["a"]}`, `{"filter":{"pattern":"["},"team":"x"}`} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestConnectorMetadataHandler(t *testing.T) {
+	withTestMetadataStore(t)
+	withTestAuditLog(t, 10)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/default/connectors/alpha/metadata", bytes.NewBufferString(`{"owner":"alice","tags":["pii"]}`))
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "alpha"})
+	rr := httptest.NewRecorder()
+	connectorMetadataHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/default/connectors/alpha/metadata", nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "alpha"})
+	rr = httptest.NewRecorder()
+	connectorMetadataHandler(rr, req)
+	var meta ConnectorMetadata
+	if err := json.Unmarshal(rr.Body.Bytes(), &meta); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if meta.Owner != "alice" || len(meta.Tags) != 1 || meta.Tags[0] != "pii" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+}
+
+func TestMetadataPatchAutoRestart(t *testing.T) {
+	enabled := true
+	meta := metadataPatch{AutoRestart: &AutoRestartPolicy{Enabled: &enabled}}.apply(ConnectorMetadata{})
+	if meta.AutoRestart == nil || meta.AutoRestart.Enabled == nil || !*meta.AutoRestart.Enabled {
+		t.Fatalf("expected auto-restart override, got %+v", meta.AutoRestart)
+	}
+
+	meta = metadataPatch{AutoRestart: &AutoRestartPolicy{}}.apply(meta)
+	if meta.AutoRestart != nil {
+		t.Fatalf("expected empty object to clear the override, got %+v", meta.AutoRestart)
+	}
+}
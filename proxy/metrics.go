@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// jolokiaURLs lists the Jolokia agent endpoints of the Connect workers, e.g.
+	// http://connect-1:8778/jolokia,http://connect-2:8778/jolokia
+	jolokiaURLs         = getEnv("JOLOKIA_URL", "")
+	metricsPollInterval = getEnv("METRICS_POLL_INTERVAL", "15s")
+	metricsRetention    = getEnv("METRICS_RETENTION", "60m")
+
+	errMetricsUnavailable = errors.New("metrics collection is not configured (set JOLOKIA_URL)")
+
+	// metricsObservers receive the samples taken by every successful collection.
+	metricsObservers []func(samples []ConnectorMetrics)
+
+	connectorMetricsCollector = newMetricsCollector(nil, time.Hour, time.Now)
+)
+
+// Sources of a metric in ConnectorMetrics.Sources.
+const (
+	metricSourceJolokia     = "jolokia"
+	metricSourceREST        = "rest"
+	metricSourceUnavailable = "unavailable"
+)
+
+// metricsRestartWindow is how far back restarts are counted from the state history.
+const metricsRestartWindow = 24 * time.Hour
+
+// jolokiaOnlyMetrics can only be read over JMX.
+var jolokiaOnlyMetrics = []string{"recordsInPerSec", "recordsOutPerSec", "totalRecordErrors", "errorsPerSec", "offsetLag"}
+
+// ConnectorMetrics is a point-in-time sample of a connector's JMX metrics, summed over
+// all of its tasks. The metrics endpoint adds the connector's state, task states and
+// restarts from the Connect REST API and the state history, and Sources names where
+// each metric came from, so zeros from a missing source can be told from real ones.
+type ConnectorMetrics struct {
+	Connector         string            `json:"connector"`
+	Timestamp         time.Time         `json:"timestamp"`
+	Tasks             int               `json:"tasks"`
+	RecordsInPerSec   float64           `json:"recordsInPerSec"`
+	RecordsOutPerSec  float64           `json:"recordsOutPerSec"`
+	TotalRecordErrors float64           `json:"totalRecordErrors"`
+	ErrorsPerSec      float64           `json:"errorsPerSec"`
+	OffsetLag         float64           `json:"offsetLag"`
+	State             string            `json:"state,omitempty"`
+	RunningTasks      int               `json:"runningTasks,omitempty"`
+	FailedTasks       int               `json:"failedTasks,omitempty"`
+	Restarts          int               `json:"restarts,omitempty"`
+	StatusObservedAt  *time.Time        `json:"statusObservedAt,omitempty"`
+	Sources           map[string]string `json:"sources,omitempty"`
+}
+
+// MetricsHistory is returned by the metrics history endpoint.
+type MetricsHistory struct {
+	Connector string             `json:"connector"`
+	Window    string             `json:"window,omitempty"`
+	Since     time.Time          `json:"since"`
+	Until     *time.Time         `json:"until,omitempty"`
+	Points    []ConnectorMetrics `json:"points"`
+}
+
+type jolokiaRequest struct {
+	Type      string   `json:"type"`
+	MBean     string   `json:"mbean"`
+	Attribute []string `json:"attribute,omitempty"`
+}
+
+type jolokiaResponse struct {
+	Status int                               `json:"status"`
+	Error  string                            `json:"error,omitempty"`
+	Value  map[string]map[string]interface{} `json:"value"`
+}
+
+// jolokiaMetricReads are issued as a single bulk request against every worker. The
+// wildcard patterns return one entry per connector task.
+var jolokiaMetricReads = []jolokiaRequest{
+	{Type: "read", MBean: "kafka.connect:type=source-task-metrics,connector=*,task=*", Attribute: []string{"source-record-poll-rate", "source-record-write-rate"}},
+	{Type: "read", MBean: "kafka.connect:type=sink-task-metrics,connector=*,task=*", Attribute: []string{"sink-record-read-rate", "sink-record-send-rate"}},
+	{Type: "read", MBean: "kafka.connect:type=task-error-metrics,connector=*,task=*", Attribute: []string{"total-record-errors"}},
+	{Type: "read", MBean: "kafka.consumer:type=consumer-fetch-manager-metrics,client-id=*", Attribute: []string{"records-lag-max"}},
+}
+
+var sinkConsumerClientID = regexp.MustCompile(`^connector-consumer-(.+)-\d+$`)
+
+// metricsCollector polls Jolokia and keeps a rolling in-memory time series per connector.
+type metricsCollector struct {
+	mu        sync.RWMutex
+	client    *http.Client
+	urls      []string
+	retention time.Duration
+	series    map[string][]ConnectorMetrics
+	now       func() time.Time
+}
+
+func newMetricsCollector(urls []string, retention time.Duration, now func() time.Time) *metricsCollector {
+	return &metricsCollector{
+		client:    &http.Client{Timeout: 10 * time.Second, Transport: &tracingTransport{base: upstreamHTTP, peer: "jolokia"}},
+		urls:      urls,
+		retention: retention,
+		series:    make(map[string][]ConnectorMetrics),
+		now:       now,
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
+
+func (c *metricsCollector) enabled() bool {
+	return len(c.urls) > 0
+}
+
+// collect takes one sample from every worker and appends it to the series.
+func (c *metricsCollector) collect(ctx context.Context) error {
+	samples := make(map[string]*ConnectorMetrics)
+	var errs []string
+	for _, url := range c.urls {
+		if err := c.readWorker(ctx, url, samples); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", url, err))
+		}
+	}
+	if len(errs) == len(c.urls) && len(errs) > 0 {
+		return fmt.Errorf("jolokia unreachable: %s", strings.Join(errs, "; "))
+	}
+	if len(errs) > 0 {
+		log.Printf("metrics: partial collection: %s", strings.Join(errs, "; "))
+	}
+
+	observed := c.record(samples, c.now().UTC())
+	for _, observe := range metricsObservers {
+		observe(observed)
+	}
+	return nil
+}
+
+// record appends one sample per connector to the series, derives error rates from the
+// previous sample and drops points older than the retention window. It returns the
+// recorded samples.
+func (c *metricsCollector) record(samples map[string]*ConnectorMetrics, now time.Time) []ConnectorMetrics {
+	cutoff := now.Add(-c.retention)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	recorded := make([]ConnectorMetrics, 0, len(samples))
+	for name, sample := range samples {
+		sample.Connector = name
+		sample.Timestamp = now
+		points := c.series[name]
+		if n := len(points); n > 0 {
+			prev := points[n-1]
+			elapsed := now.Sub(prev.Timestamp).Seconds()
+			if delta := sample.TotalRecordErrors - prev.TotalRecordErrors; elapsed > 0 && delta > 0 {
+				sample.ErrorsPerSec = delta / elapsed
+			}
+		}
+		points = append(points, *sample)
+		for len(points) > 0 && points[0].Timestamp.Before(cutoff) {
+			points = points[1:]
+		}
+		c.series[name] = points
+		recorded = append(recorded, *sample)
+	}
+	for name, points := range c.series {
+		if _, ok := samples[name]; !ok && (len(points) == 0 || points[len(points)-1].Timestamp.Before(cutoff)) {
+			delete(c.series, name)
+		}
+	}
+	return recorded
+}
+
+// ping checks that at least one configured Jolokia agent answers its version endpoint.
+func (c *metricsCollector) ping(ctx context.Context) error {
+	var errs []string
+	for _, url := range c.urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(url, "version"), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", url, err))
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			errs = append(errs, fmt.Sprintf("%s: HTTP %d", url, resp.StatusCode))
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("jolokia unreachable: %s", strings.Join(errs, "; "))
+}
+
+func (c *metricsCollector) readWorker(ctx context.Context, url string, samples map[string]*ConnectorMetrics) error {
+	body, err := json.Marshal(jolokiaMetricReads)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var results []jolokiaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return fmt.Errorf("decode jolokia response: %w", err)
+	}
+
+	tasks := make(map[string]map[string]bool)
+	sample := func(connector string) *ConnectorMetrics {
+		if samples[connector] == nil {
+			samples[connector] = &ConnectorMetrics{}
+		}
+		return samples[connector]
+	}
+
+	for _, result := range results {
+		// A 404 means no MBean matched the pattern, e.g. no sink connectors on this worker.
+		if result.Status != http.StatusOK {
+			continue
+		}
+		for mbean, attrs := range result.Value {
+			props := parseMBeanName(mbean)
+			if clientID, ok := props["client-id"]; ok {
+				match := sinkConsumerClientID.FindStringSubmatch(clientID)
+				if match == nil {
+					continue
+				}
+				sample(match[1]).OffsetLag += jolokiaNumber(attrs["records-lag-max"])
+				continue
+			}
+
+			connector := props["connector"]
+			if connector == "" {
+				continue
+			}
+			m := sample(connector)
+			if tasks[connector] == nil {
+				tasks[connector] = make(map[string]bool)
+			}
+			tasks[connector][props["task"]] = true
+
+			switch props["type"] {
+			case "source-task-metrics":
+				m.RecordsInPerSec += jolokiaNumber(attrs["source-record-poll-rate"])
+				m.RecordsOutPerSec += jolokiaNumber(attrs["source-record-write-rate"])
+			case "sink-task-metrics":
+				m.RecordsInPerSec += jolokiaNumber(attrs["sink-record-read-rate"])
+				m.RecordsOutPerSec += jolokiaNumber(attrs["sink-record-send-rate"])
+			case "task-error-metrics":
+				m.TotalRecordErrors += jolokiaNumber(attrs["total-record-errors"])
+			}
+		}
+	}
+	for connector, ids := range tasks {
+		samples[connector].Tasks += len(ids)
+	}
+	return nil
+}
+
+// parseMBeanName splits "domain:key=value,key=value" into its key properties.
+func parseMBeanName(name string) map[string]string {
+	props := make(map[string]string)
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, pair := range strings.Split(name, ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			props[key] = strings.Trim(value, `"`)
+		}
+	}
+	return props
+}
+
+// jolokiaNumber converts a Jolokia attribute value to a float, treating NaN, null and
+// other non-numeric values as zero.
+func jolokiaNumber(value interface{}) float64 {
+	if f, ok := value.(float64); ok {
+		return f
+	}
+	return 0
+}
+
+func (c *metricsCollector) latest(name string) (ConnectorMetrics, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	points := c.series[name]
+	if len(points) == 0 {
+		return ConnectorMetrics{}, false
+	}
+	return points[len(points)-1], true
+}
+
+func (c *metricsCollector) history(name string, window time.Duration) []ConnectorMetrics {
+	return c.historyRange(name, timeRange{Since: c.now().UTC().Add(-window)})
+}
+
+// historyRange returns the retained samples of name that fall within r.
+func (c *metricsCollector) historyRange(name string, r timeRange) []ConnectorMetrics {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	points := make([]ConnectorMetrics, 0)
+	for _, point := range c.series[name] {
+		if r.contains(point.Timestamp) {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+func (c *metricsCollector) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := c.collect(ctx); err != nil {
+			log.Printf("metrics: %v", err)
+		}
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// jolokiaMetrics returns the most recent sample for name, collecting one on demand when
+// the background collector has not produced any yet.
+func jolokiaMetrics(ctx context.Context, name string) (ConnectorMetrics, error) {
+	collector := connectorMetricsCollector
+	if !collector.enabled() {
+		return ConnectorMetrics{}, errMetricsUnavailable
+	}
+	if sample, ok := collector.latest(name); ok {
+		return sample, nil
+	}
+	if err := collector.collect(ctx); err != nil {
+		return ConnectorMetrics{}, err
+	}
+	if sample, ok := collector.latest(name); ok {
+		return sample, nil
+	}
+	return ConnectorMetrics{Connector: name, Timestamp: collector.now().UTC()}, nil
+}
+
+// fetchConnectorMetrics combines the Jolokia sample of a connector with its status from
+// the Connect REST API. Either source may be missing: without Jolokia the throughput,
+// error and lag metrics are marked unavailable and the task count comes from REST.
+// An error is returned only when neither source answers.
+func fetchConnectorMetrics(ctx context.Context, cluster, name string) (ConnectorMetrics, error) {
+	metrics, jolokiaErr := jolokiaMetrics(ctx, name)
+	if jolokiaErr != nil && !errors.Is(jolokiaErr, errMetricsUnavailable) {
+		log.Printf("metrics: falling back to the Connect REST API for %s: %v", name, jolokiaErr)
+	}
+	sources := map[string]string{}
+	for _, metric := range jolokiaOnlyMetrics {
+		sources[metric] = metricSourceJolokia
+		if jolokiaErr != nil {
+			sources[metric] = metricSourceUnavailable
+		}
+	}
+	sources["tasks"] = metricSourceJolokia
+
+	status, restErr := fetchConnectorStatus(ctx, connectClientFor(cluster, routeRead), connectURLFor(cluster), name)
+	if restErr != nil {
+		if jolokiaErr != nil {
+			return ConnectorMetrics{}, restErr
+		}
+		for _, metric := range []string{"state", "runningTasks", "failedTasks", "restarts", "statusObservedAt"} {
+			sources[metric] = metricSourceUnavailable
+		}
+		metrics.Sources = sources
+		return metrics, nil
+	}
+
+	observedAt := time.Now().UTC()
+	if jolokiaErr != nil {
+		metrics = ConnectorMetrics{Connector: name, Timestamp: observedAt, Tasks: len(status.Tasks)}
+		sources["tasks"] = metricSourceREST
+	}
+	metrics.State = normalizeState(status.Connector.State)
+	for _, task := range status.Tasks {
+		switch normalizeState(task.State) {
+		case "running":
+			metrics.RunningTasks++
+		case "failed":
+			metrics.FailedTasks++
+		}
+	}
+	metrics.StatusObservedAt = &observedAt
+	for _, metric := range []string{"state", "runningTasks", "failedTasks", "statusObservedAt"} {
+		sources[metric] = metricSourceREST
+	}
+	sources["restarts"] = metricSourceUnavailable
+	if restarts, ok := connectorStateHistory.restarts(cluster, name, observedAt.Add(-metricsRestartWindow)); ok {
+		metrics.Restarts = restarts
+		sources["restarts"] = metricSourceREST
+	}
+	metrics.Sources = sources
+	return metrics, nil
+}
+
+// connectorMetricsHandler returns the latest metrics of a connector, from Jolokia and
+// the Connect REST API.
+func connectorMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	metrics, err := fetchConnectorMetrics(r.Context(), vars["cluster"], vars["name"])
+	if err != nil {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", vars["name"]))
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		default:
+			writeJSONError(w, http.StatusBadGateway, "metrics_fetch_failed", err.Error())
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, metrics)
+}
+
+// connectorMetricsHistoryHandler returns the rolling metrics series for a connector.
+func connectorMetricsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	collector := connectorMetricsCollector
+	if !collector.enabled() {
+		writeJSONError(w, http.StatusNotImplemented, "metrics_unavailable", errMetricsUnavailable.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	timeRange, loc, err := parseTimeRange(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_time_range", err.Error())
+		return
+	}
+
+	// An explicit since takes precedence over the relative window.
+	windowParam := query.Get("window")
+	if timeRange.Since.IsZero() {
+		if windowParam == "" {
+			windowParam = "15m"
+		}
+		window, err := parseWindow(windowParam, 15*time.Minute)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_window", err.Error())
+			return
+		}
+		timeRange.Since = collector.now().UTC().Add(-window)
+	}
+
+	setTimezoneHeader(w, loc)
+	writeJSON(w, http.StatusOK, MetricsHistory{
+		Connector: name,
+		Window:    windowParam,
+		Since:     timeRange.Since,
+		Until:     nonZeroTime(timeRange.Until),
+		Points:    collector.historyRange(name, timeRange),
+	})
+}
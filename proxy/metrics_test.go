@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func newJolokiaServer(t *testing.T, totalErrors *float64) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reads []jolokiaRequest
+		if err := json.NewDecoder(r.Body).Decode(&reads); err != nil {
+			t.Errorf("failed to decode jolokia request: %v", err)
+		}
+		if len(reads) != len(jolokiaMetricReads) {
+			t.Errorf("expected bulk read with %d requests, got %d", len(jolokiaMetricReads), len(reads))
+		}
+
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"status": 200, "value": map[string]interface{}{
+				"kafka.connect:connector=orders-source,task=0,type=source-task-metrics": map[string]interface{}{"source-record-poll-rate": 10.0, "source-record-write-rate": 9.0},
+				"kafka.connect:connector=orders-source,task=1,type=source-task-metrics": map[string]interface{}{"source-record-poll-rate": 5.0, "source-record-write-rate": "NaN"},
+			}},
+			{"status": 200, "value": map[string]interface{}{
+				"kafka.connect:connector=orders-sink,task=0,type=sink-task-metrics": map[string]interface{}{"sink-record-read-rate": 7.0, "sink-record-send-rate": 7.0},
+			}},
+			{"status": 200, "value": map[string]interface{}{
+				"kafka.connect:connector=orders-sink,task=0,type=task-error-metrics": map[string]interface{}{"total-record-errors": *totalErrors},
+			}},
+			{"status": 200, "value": map[string]interface{}{
+				"kafka.consumer:client-id=connector-consumer-orders-sink-0,type=consumer-fetch-manager-metrics": map[string]interface{}{"records-lag-max": 42.0},
+				"kafka.consumer:client-id=some-other-client,type=consumer-fetch-manager-metrics":                map[string]interface{}{"records-lag-max": 1000.0},
+			}},
+		})
+	}))
+}
+
+func TestMetricsCollectorCollect(t *testing.T) {
+	totalErrors := 10.0
+	server := newJolokiaServer(t, &totalErrors)
+	defer server.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	collector := newMetricsCollector([]string{server.URL}, time.Minute, func() time.Time { return now })
+
+	if err := collector.collect(context.Background()); err != nil {
+		t.Fatalf("collect returned error: %v", err)
+	}
+
+	source, ok := collector.latest("orders-source")
+	if !ok {
+		t.Fatalf("expected sample for orders-source")
+	}
+	if source.Tasks != 2 || source.RecordsInPerSec != 15 || source.RecordsOutPerSec != 9 {
+		t.Fatalf("unexpected source metrics: %+v", source)
+	}
+
+	sink, _ := collector.latest("orders-sink")
+	if sink.OffsetLag != 42 || sink.RecordsInPerSec != 7 || sink.ErrorsPerSec != 0 {
+		t.Fatalf("unexpected sink metrics: %+v", sink)
+	}
+
+	now = now.Add(10 * time.Second)
+	totalErrors = 30
+	if err := collector.collect(context.Background()); err != nil {
+		t.Fatalf("second collect returned error: %v", err)
+	}
+	sink, _ = collector.latest("orders-sink")
+	if sink.ErrorsPerSec != 2 {
+		t.Fatalf("expected error rate of 2/s, got %v", sink.ErrorsPerSec)
+	}
+
+	if got := len(collector.history("orders-sink", time.Minute)); got != 2 {
+		t.Fatalf("expected 2 history points, got %d", got)
+	}
+	if got := len(collector.history("orders-sink", 5*time.Second)); got != 1 {
+		t.Fatalf("expected window to trim history, got %d points", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := collector.collect(context.Background()); err != nil {
+		t.Fatalf("third collect returned error: %v", err)
+	}
+	if got := len(collector.history("orders-sink", time.Hour)); got != 1 {
+		t.Fatalf("expected retention to drop old points, got %d", got)
+	}
+}
+
+func TestMetricsHistoryRange(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	collector := newMetricsCollector(nil, time.Hour, func() time.Time { return start.Add(10 * time.Minute) })
+	for i := 0; i < 5; i++ {
+		collector.record(map[string]*ConnectorMetrics{"orders-sink": {}}, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	points := collector.historyRange("orders-sink", timeRange{Since: start.Add(time.Minute), Until: start.Add(3 * time.Minute)})
+	if len(points) != 2 || !points[0].Timestamp.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected the samples at +1m and +2m, got %+v", points)
+	}
+}
+
+func TestMetricsCollectorUnreachable(t *testing.T) {
+	collector := newMetricsCollector([]string{"http://127.0.0.1:1/jolokia"}, time.Minute, time.Now)
+	collector.client.Timeout = 100 * time.Millisecond
+	if err := collector.collect(context.Background()); err == nil {
+		t.Fatalf("expected error when Jolokia is unreachable")
+	}
+}
+
+func TestConnectorMetricsHandlers(t *testing.T) {
+	original := connectorMetricsCollector
+	t.Cleanup(func() { connectorMetricsCollector = original })
+
+	connect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connectors/orders-sink/status" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"name":"orders-sink","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"},{"id":1,"state":"FAILED"}]}`)
+	}))
+	defer connect.Close()
+	defer withTestConnectURL(t, connect)()
+
+	connectorMetricsCollector = newMetricsCollector(nil, time.Minute, time.Now)
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders-sink/metrics", nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "orders-sink"})
+	rr := httptest.NewRecorder()
+	connectorMetricsHandler(rr, req)
+	var fallback ConnectorMetrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &fallback); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected REST metrics without JOLOKIA_URL, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if fallback.Tasks != 2 || fallback.RunningTasks != 1 || fallback.FailedTasks != 1 || fallback.State != "running" || fallback.StatusObservedAt == nil {
+		t.Fatalf("unexpected REST metrics %+v", fallback)
+	}
+	if fallback.Sources["tasks"] != metricSourceREST || fallback.Sources["offsetLag"] != metricSourceUnavailable || fallback.Sources["restarts"] != metricSourceUnavailable {
+		t.Fatalf("unexpected sources %+v", fallback.Sources)
+	}
+
+	missing := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connectors/missing/metrics", nil), map[string]string{"cluster": "default", "name": "missing"})
+	rr = httptest.NewRecorder()
+	connectorMetricsHandler(rr, missing)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown connector, got %d", rr.Code)
+	}
+
+	totalErrors := 0.0
+	server := newJolokiaServer(t, &totalErrors)
+	defer server.Close()
+	connectorMetricsCollector = newMetricsCollector([]string{server.URL}, time.Minute, time.Now)
+
+	rr = httptest.NewRecorder()
+	connectorMetricsHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var metrics ConnectorMetrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("failed to decode metrics: %v", err)
+	}
+	if metrics.Connector != "orders-sink" || metrics.OffsetLag != 42 {
+		t.Fatalf("unexpected metrics payload: %+v", metrics)
+	}
+	if metrics.Sources["offsetLag"] != metricSourceJolokia || metrics.Sources["state"] != metricSourceREST || metrics.FailedTasks != 1 {
+		t.Fatalf("expected Jolokia metrics with the REST status, got %+v", metrics)
+	}
+
+	historyReq := httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders-sink/metrics/history?window=15m", nil)
+	historyReq = mux.SetURLVars(historyReq, map[string]string{"cluster": "default", "name": "orders-sink"})
+	rr = httptest.NewRecorder()
+	connectorMetricsHistoryHandler(rr, historyReq)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for history, got %d", rr.Code)
+	}
+	var history MetricsHistory
+	if err := json.Unmarshal(rr.Body.Bytes(), &history); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+	if history.Window != "15m" || len(history.Points) != 1 {
+		t.Fatalf("unexpected history payload: %+v", history)
+	}
+
+	badReq := httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders-sink/metrics/history?window=soon", nil)
+	badReq = mux.SetURLVars(badReq, map[string]string{"cluster": "default", "name": "orders-sink"})
+	rr = httptest.NewRecorder()
+	connectorMetricsHistoryHandler(rr, badReq)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid window, got %d", rr.Code)
+	}
+}
+
+func TestParseMBeanName(t *testing.T) {
+	props := parseMBeanName(`kafka.connect:type=sink-task-metrics,connector="my-sink",task=3`)
+	if props["type"] != "sink-task-metrics" || props["connector"] != "my-sink" || props["task"] != "3" {
+		t.Fatalf("unexpected properties: %v", props)
+	}
+}
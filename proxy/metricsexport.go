@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// metricsExportURL is the write endpoint of an external time-series database, such
+	// as http://influxdb:8086/api/v2/write?org=ops&bucket=kconnect or
+	// http://prometheus:9090/api/v1/write. Exporting is off while it is unset.
+	metricsExportURL      = getEnv("METRICS_EXPORT_URL", "")
+	metricsExportFormat   = getEnv("METRICS_EXPORT_FORMAT", metricsExportInfluxDB)
+	metricsExportInterval = getEnv("METRICS_EXPORT_INTERVAL", "60s")
+	metricsExportHeaders  = getEnv("METRICS_EXPORT_HEADERS", "")
+	metricsExportBuffer   = getEnv("METRICS_EXPORT_BUFFER", "10000")
+)
+
+// Formats of METRICS_EXPORT_FORMAT.
+const (
+	metricsExportInfluxDB   = "influxdb"
+	metricsExportPrometheus = "prometheus"
+)
+
+// exportPoint is one value of one series. Labels are sorted by name.
+type exportPoint struct {
+	name      string
+	labels    [][2]string
+	value     float64
+	timestamp time.Time
+}
+
+// metricsExporter ships connector metrics and state counts to InfluxDB (line
+// protocol) or a Prometheus remote-write endpoint on an interval, so history can be
+// kept in Grafana while the console only holds METRICS_RETENTION in memory. Points
+// are buffered between exports and kept when an export fails, up to maxPoints; the
+// oldest are dropped beyond that.
+type metricsExporter struct {
+	mu        sync.Mutex
+	format    string
+	url       string
+	headers   map[string]string
+	client    *http.Client
+	interval  time.Duration
+	maxPoints int
+	points    []exportPoint
+	dropped   int
+	now       func() time.Time
+}
+
+// loadMetricsExporter builds the exporter from the METRICS_EXPORT_* settings. It
+// returns nil when METRICS_EXPORT_URL is unset.
+func loadMetricsExporter() (*metricsExporter, error) {
+	endpoint := strings.TrimSpace(metricsExportURL)
+	if endpoint == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &configError{name: "METRICS_EXPORT_URL", value: redactURL(endpoint)}
+	}
+	format := strings.ToLower(strings.TrimSpace(metricsExportFormat))
+	if format != metricsExportInfluxDB && format != metricsExportPrometheus {
+		return nil, &configError{name: "METRICS_EXPORT_FORMAT", value: metricsExportFormat}
+	}
+	interval, err := time.ParseDuration(strings.TrimSpace(metricsExportInterval))
+	if err != nil || interval < time.Second {
+		return nil, &configError{name: "METRICS_EXPORT_INTERVAL", value: metricsExportInterval}
+	}
+	maxPoints, err := strconv.Atoi(strings.TrimSpace(metricsExportBuffer))
+	if err != nil || maxPoints <= 0 {
+		return nil, &configError{name: "METRICS_EXPORT_BUFFER", value: metricsExportBuffer}
+	}
+	headers, err := parseOTLPPairs("METRICS_EXPORT_HEADERS", metricsExportHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return &metricsExporter{
+		format:    format,
+		url:       endpoint,
+		headers:   headers,
+		client:    newUpstreamClient(30 * time.Second),
+		interval:  interval,
+		maxPoints: maxPoints,
+		now:       time.Now,
+	}, nil
+}
+
+// add buffers points, dropping the oldest once the buffer is full.
+func (e *metricsExporter) add(points ...exportPoint) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.points = append(e.points, points...)
+	if excess := len(e.points) - e.maxPoints; excess > 0 {
+		e.points = append([]exportPoint(nil), e.points[excess:]...)
+		e.dropped += excess
+	}
+}
+
+// observeMetrics buffers the samples of a metrics collection.
+func (e *metricsExporter) observeMetrics(samples []ConnectorMetrics) {
+	var points []exportPoint
+	for _, sample := range samples {
+		labels := [][2]string{{"connector", sample.Connector}}
+		for _, metric := range []struct {
+			name  string
+			value float64
+		}{
+			{"kconnect_connector_tasks", float64(sample.Tasks)},
+			{"kconnect_connector_records_in_per_sec", sample.RecordsInPerSec},
+			{"kconnect_connector_records_out_per_sec", sample.RecordsOutPerSec},
+			{"kconnect_connector_record_errors_total", sample.TotalRecordErrors},
+			{"kconnect_connector_errors_per_sec", sample.ErrorsPerSec},
+			{"kconnect_connector_offset_lag", sample.OffsetLag},
+		} {
+			points = append(points, exportPoint{name: metric.name, labels: labels, value: metric.value, timestamp: sample.Timestamp})
+		}
+	}
+	e.add(points...)
+}
+
+// observeStatuses buffers the connector and task state counts of a cluster. Every
+// state is written, including zeros, so a series drops back to 0 rather than stopping.
+func (e *metricsExporter) observeStatuses(clusterID string, statuses []connectorStatusResponse) {
+	connectors, tasks := newStateCounter(), newStateCounter()
+	for _, status := range statuses {
+		connectors[normalizeState(status.Connector.State)]++
+		for _, task := range status.Tasks {
+			tasks[normalizeState(task.State)]++
+		}
+	}
+
+	now := e.now().UTC()
+	var points []exportPoint
+	for _, counts := range []struct {
+		name   string
+		counts map[string]int
+	}{
+		{"kconnect_connectors", connectors},
+		{"kconnect_tasks", tasks},
+	} {
+		states := make([]string, 0, len(counts.counts))
+		for state := range counts.counts {
+			states = append(states, state)
+		}
+		sort.Strings(states)
+		for _, state := range states {
+			points = append(points, exportPoint{
+				name:      counts.name,
+				labels:    [][2]string{{"cluster", clusterID}, {"state", state}},
+				value:     float64(counts.counts[state]),
+				timestamp: now,
+			})
+		}
+	}
+	e.add(points...)
+}
+
+// flush sends the buffered points. On failure they are put back for the next attempt.
+func (e *metricsExporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	points, dropped := e.points, e.dropped
+	e.points, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("metrics export: dropped %d points because the export buffer was full", dropped)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	if err := e.send(ctx, points); err != nil {
+		e.mu.Lock()
+		e.points = append(points, e.points...)
+		e.mu.Unlock()
+		e.add()
+		return fmt.Errorf("failed to export %d points: %w", len(points), err)
+	}
+	return nil
+}
+
+func (e *metricsExporter) send(ctx context.Context, points []exportPoint) error {
+	var body []byte
+	contentType := "text/plain; charset=utf-8"
+	if e.format == metricsExportPrometheus {
+		body = encodeSnappyBlock(encodeRemoteWrite(points))
+		contentType = "application/x-protobuf"
+	} else {
+		body = encodeInfluxLines(points)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if e.format == metricsExportPrometheus {
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", e.format, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func (e *metricsExporter) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+		if err := e.flush(ctx); err != nil {
+			log.Printf("metrics export: %v", err)
+		}
+		cancel()
+	}
+}
+
+var (
+	influxNameEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper  = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// encodeInfluxLines writes the points in InfluxDB line protocol, one measurement per
+// series name with the labels as tags and the value in the "value" field. Values
+// InfluxDB cannot store (NaN and infinities) are skipped.
+func encodeInfluxLines(points []exportPoint) []byte {
+	var buf bytes.Buffer
+	for _, point := range points {
+		if math.IsNaN(point.value) || math.IsInf(point.value, 0) {
+			continue
+		}
+		buf.WriteString(influxNameEscaper.Replace(point.name))
+		for _, label := range point.labels {
+			if label[1] == "" {
+				continue
+			}
+			fmt.Fprintf(&buf, ",%s=%s", influxTagEscaper.Replace(label[0]), influxTagEscaper.Replace(label[1]))
+		}
+		fmt.Fprintf(&buf, " value=%s %d\n", strconv.FormatFloat(point.value, 'g', -1, 64), point.timestamp.UnixNano())
+	}
+	return buf.Bytes()
+}
+
+// encodeRemoteWrite encodes the points as a Prometheus remote-write WriteRequest
+// protobuf message. Points of the same series become the samples of one TimeSeries,
+// in the order they were taken.
+func encodeRemoteWrite(points []exportPoint) []byte {
+	type series struct {
+		labels  [][2]string
+		samples []byte
+	}
+	var order []string
+	bySeries := map[string]*series{}
+	for _, point := range points {
+		labels := append([][2]string{{"__name__", point.name}}, point.labels...)
+		sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+		var key strings.Builder
+		for _, label := range labels {
+			key.WriteString(label[0] + "\x00" + label[1] + "\x00")
+		}
+		s, ok := bySeries[key.String()]
+		if !ok {
+			s = &series{labels: labels}
+			bySeries[key.String()] = s
+			order = append(order, key.String())
+		}
+		var sample []byte
+		sample = protoAppendTag(sample, 1, 1)
+		sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(point.value))
+		sample = protoAppendTag(sample, 2, 0)
+		sample = binary.AppendUvarint(sample, uint64(point.timestamp.UnixMilli()))
+		s.samples = protoAppendBytes(s.samples, 2, sample)
+	}
+
+	var request []byte
+	for _, key := range order {
+		s := bySeries[key]
+		var timeSeries []byte
+		for _, label := range s.labels {
+			var encoded []byte
+			encoded = protoAppendBytes(encoded, 1, []byte(label[0]))
+			encoded = protoAppendBytes(encoded, 2, []byte(label[1]))
+			timeSeries = protoAppendBytes(timeSeries, 1, encoded)
+		}
+		timeSeries = append(timeSeries, s.samples...)
+		request = protoAppendBytes(request, 1, timeSeries)
+	}
+	return request
+}
+
+func protoAppendTag(buf []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(field<<3|wireType))
+}
+
+// protoAppendBytes appends a length-delimited field.
+func protoAppendBytes(buf []byte, field int, value []byte) []byte {
+	buf = protoAppendTag(buf, field, 2)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// encodeSnappyBlock frames src in the snappy block format remote-write requires. It
+// emits literals only: the payload is not made smaller, but every snappy decoder
+// accepts it and the proxy needs no compression library.
+func encodeSnappyBlock(src []byte) []byte {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)+len(src)/65536*3+16), uint64(len(src)))
+	for len(src) > 0 {
+		n := len(src)
+		if n > 65536 {
+			n = 65536
+		}
+		switch {
+		case n <= 60:
+			dst = append(dst, byte(n-1)<<2)
+		case n <= 256:
+			dst = append(dst, 60<<2, byte(n-1))
+		default:
+			dst = append(dst, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+	return dst
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMetricsExporter(t *testing.T, format, endpoint string, maxPoints int) *metricsExporter {
+	t.Helper()
+	originals := []string{metricsExportURL, metricsExportFormat, metricsExportBuffer, metricsExportHeaders}
+	t.Cleanup(func() {
+		metricsExportURL, metricsExportFormat, metricsExportBuffer, metricsExportHeaders = originals[0], originals[1], originals[2], originals[3]
+	})
+	metricsExportURL, metricsExportFormat, metricsExportHeaders = endpoint, format, "Authorization=Token%20abc"
+	metricsExportBuffer = "10000"
+	exporter, err := loadMetricsExporter()
+	if err != nil || exporter == nil {
+		t.Fatalf("unexpected exporter %v %v", exporter, err)
+	}
+	exporter.maxPoints = maxPoints
+	exporter.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return exporter
+}
+
+func TestLoadMetricsExporter(t *testing.T) {
+	testMetricsExporter(t, "influxdb", "http://influxdb:8086/api/v2/write", 1)
+
+	metricsExportURL = ""
+	if exporter, err := loadMetricsExporter(); err != nil || exporter != nil {
+		t.Fatalf("expected no exporter without a URL, got %v %v", exporter, err)
+	}
+	for _, tt := range []struct{ url, format, interval, buffer, setting string }{
+		{"influxdb:8086", "influxdb", "60s", "10", "METRICS_EXPORT_URL"},
+		{"http://influxdb:8086", "graphite", "60s", "10", "METRICS_EXPORT_FORMAT"},
+		{"http://influxdb:8086", "influxdb", "10ms", "10", "METRICS_EXPORT_INTERVAL"},
+		{"http://influxdb:8086", "prometheus", "60s", "0", "METRICS_EXPORT_BUFFER"},
+	} {
+		originalInterval := metricsExportInterval
+		metricsExportURL, metricsExportFormat, metricsExportInterval, metricsExportBuffer = tt.url, tt.format, tt.interval, tt.buffer
+		_, err := loadMetricsExporter()
+		metricsExportInterval = originalInterval
+		if err == nil || !strings.Contains(err.Error(), tt.setting) {
+			t.Errorf("expected an error for %s, got %v", tt.setting, err)
+		}
+	}
+}
+
+func testExporterStatuses(t *testing.T) []connectorStatusResponse {
+	t.Helper()
+	var statuses []connectorStatusResponse
+	if err := json.Unmarshal([]byte(`[
+		{"name": "orders", "connector": {"state": "RUNNING"}, "tasks": [{"id": 0, "state": "RUNNING"}, {"id": 1, "state": "FAILED"}]},
+		{"name": "clicks", "connector": {"state": "PAUSED"}, "tasks": []}
+	]`), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	return statuses
+}
+
+func TestMetricsExporterInfluxDB(t *testing.T) {
+	var bodies []string
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			http.Error(w, "database is starting", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Token abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	exporter := testMetricsExporter(t, "influxdb", server.URL, 16)
+
+	exporter.observeStatuses("prod eu", testExporterStatuses(t))
+	exporter.observeMetrics([]ConnectorMetrics{{Connector: "orders", Timestamp: time.Unix(1700000001, 0), Tasks: 2, RecordsInPerSec: 12.5, OffsetLag: math.NaN()}})
+
+	if err := exporter.flush(context.Background()); err == nil || !strings.Contains(err.Error(), "database is starting") {
+		t.Fatalf("expected the first export to fail, got %v", err)
+	}
+	// 12 state counts and 6 metrics do not fit in 16 points: the two oldest go.
+	if err := exporter.flush(context.Background()); err != nil || len(bodies) != 1 {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(bodies[0]), "\n")
+	if len(lines) != 15 {
+		t.Fatalf("expected 15 lines without the dropped points and the NaN lag, got %d:\n%s", len(lines), bodies[0])
+	}
+	for _, want := range []string{
+		`kconnect_connectors,cluster=prod\ eu,state=running value=1 1700000000000000000`,
+		`kconnect_tasks,cluster=prod\ eu,state=failed value=1 1700000000000000000`,
+		`kconnect_connector_records_in_per_sec,connector=orders value=12.5 1700000001000000000`,
+	} {
+		if !strings.Contains(bodies[0], want+"\n") {
+			t.Errorf("expected %q in:\n%s", want, bodies[0])
+		}
+	}
+	if strings.Contains(bodies[0], "offset_lag") || strings.Contains(bodies[0], `kconnect_connectors,cluster=prod\ eu,state=paused`) {
+		t.Fatalf("unexpected lines:\n%s", bodies[0])
+	}
+
+	if err := exporter.flush(context.Background()); err != nil || len(bodies) != 1 {
+		t.Fatalf("expected nothing to be sent without new points, got %v", err)
+	}
+}
+
+// decodeTestProto splits a protobuf message into its fields, keyed by field number.
+// Varints and fixed64 values are returned as 8 little-endian bytes.
+func decodeTestProto(t *testing.T, message []byte) map[int][][]byte {
+	t.Helper()
+	fields := map[int][][]byte{}
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		message = message[n:]
+		var value []byte
+		switch tag & 7 {
+		case 0:
+			v, n := binary.Uvarint(message)
+			value, message = binary.LittleEndian.AppendUint64(nil, v), message[n:]
+		case 1:
+			value, message = message[:8], message[8:]
+		case 2:
+			size, n := binary.Uvarint(message)
+			value, message = message[n:n+int(size)], message[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		fields[int(tag>>3)] = append(fields[int(tag>>3)], value)
+	}
+	return fields
+}
+
+func TestMetricsExporterPrometheus(t *testing.T) {
+	var payload []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("X-Prometheus-Remote-Write-Version") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payload, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	exporter := testMetricsExporter(t, "prometheus", server.URL, 100)
+
+	exporter.observeMetrics([]ConnectorMetrics{{Connector: "orders", Timestamp: time.UnixMilli(1700000001000), Tasks: 2}})
+	exporter.observeMetrics([]ConnectorMetrics{{Connector: "orders", Timestamp: time.UnixMilli(1700000016000), Tasks: 3}})
+	if err := exporter.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The proxy only writes snappy literals, which the test can unwrap directly.
+	length, n := binary.Uvarint(payload)
+	var message []byte
+	for rest := payload[n:]; len(rest) > 0; {
+		tag := rest[0]
+		size, header := int(tag>>2)+1, 1
+		switch tag >> 2 {
+		case 60:
+			size, header = int(rest[1])+1, 2
+		case 61:
+			size, header = int(binary.LittleEndian.Uint16(rest[1:3]))+1, 3
+		}
+		message = append(message, rest[header:header+size]...)
+		rest = rest[header+size:]
+	}
+	if uint64(len(message)) != length {
+		t.Fatalf("expected %d bytes, got %d", length, len(message))
+	}
+
+	series := decodeTestProto(t, message)[1]
+	if len(series) != 6 {
+		t.Fatalf("expected one series per metric, got %d", len(series))
+	}
+	tasks := decodeTestProto(t, series[0])
+	var labels []string
+	for _, label := range tasks[1] {
+		fields := decodeTestProto(t, label)
+		labels = append(labels, string(fields[1][0])+"="+string(fields[2][0]))
+	}
+	if strings.Join(labels, ",") != "__name__=kconnect_connector_tasks,connector=orders" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if len(tasks[2]) != 2 {
+		t.Fatalf("expected both samples in one series, got %d", len(tasks[2]))
+	}
+	second := decodeTestProto(t, tasks[2][1])
+	if value := math.Float64frombits(binary.LittleEndian.Uint64(second[1][0])); value != 3 {
+		t.Fatalf("expected the second sample to be 3, got %v", value)
+	}
+	if timestamp := binary.LittleEndian.Uint64(second[2][0]); timestamp != 1700000016000 {
+		t.Fatalf("expected a millisecond timestamp, got %d", timestamp)
+	}
+
+	big := encodeSnappyBlock(make([]byte, 70000))
+	if length, n := binary.Uvarint(big); length != 70000 || len(big) != n+3+65536+3+4464 {
+		t.Fatalf("unexpected snappy framing of a large payload: %d bytes", len(big))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -220,11 +221,7 @@ func TestMonitoringSummaryHandlerUsesCache(t *testing.T) {
 		monitoringHTTPClient = originalClient
 	})
 
-	originalTTL := summaryCacheTTL
-	summaryCacheTTL = time.Minute
-	t.Cleanup(func() {
-		summaryCacheTTL = originalTTL
-	})
+	withTestSummaryCache(t, time.Minute)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/default/monitoring/summary", nil)
 	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
@@ -249,3 +246,95 @@ func TestMonitoringSummaryHandlerUsesCache(t *testing.T) {
 		t.Fatalf("expected connectors endpoint to be called once, got %d", calls)
 	}
 }
+
+func TestMonitoringSummaryHandlerIncludeTasks(t *testing.T) {
+	resetMonitoringSummaryCache()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/connectors":
+			json.NewEncoder(w).Encode([]string{"alpha"})
+		case "/connectors/alpha/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":      "alpha",
+				"connector": map[string]interface{}{"state": "RUNNING", "worker_id": "worker-a"},
+				"tasks": []map[string]interface{}{
+					{"id": 0, "state": "RUNNING", "worker_id": "worker-a"},
+					{"id": 1, "state": "FAILED", "worker_id": "worker-b"},
+				},
+				"type": "source",
+			})
+		case "/":
+			json.NewEncoder(w).Encode(map[string]interface{}{})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalURL, originalClient := connectURL, monitoringHTTPClient
+	connectURL, monitoringHTTPClient = server.URL, server.Client()
+	t.Cleanup(func() { connectURL, monitoringHTTPClient = originalURL, originalClient })
+	withTestSummaryCache(t, time.Minute)
+
+	get := func(query string) (*httptest.ResponseRecorder, MonitoringSummary) {
+		req := httptest.NewRequest(http.MethodGet, "/api/default/monitoring/summary"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		monitoringSummaryHandler(rr, req)
+		var summary MonitoringSummary
+		json.Unmarshal(rr.Body.Bytes(), &summary)
+		return rr, summary
+	}
+
+	_, summary := get("?include=tasks")
+	tasks := summary.Connectors[0].Tasks
+	if len(tasks) != 2 || tasks[1] != (TaskStatusOverview{ID: 1, State: "failed", WorkerID: "worker-b"}) {
+		t.Fatalf("expected the task details, got %+v", tasks)
+	}
+	rr, summary := get("")
+	if summary.Connectors[0].Tasks != nil || strings.Contains(rr.Body.String(), `"tasks"`) {
+		t.Fatalf("expected tasks to be left out by default, got %s", rr.Body.String())
+	}
+	if _, summary := get("?include=tasks"); len(summary.Connectors[0].Tasks) != 2 {
+		t.Fatal("expected the default payload to leave the cached summary intact")
+	}
+	if rr, _ := get("?include=workers"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown includes to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestFetchMonitoringSummaryCountsStoppedConnectors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/connectors":
+			json.NewEncoder(w).Encode([]string{"gamma"})
+		case "/connectors/gamma/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":      "gamma",
+				"connector": map[string]interface{}{"state": "STOPPED", "worker_id": "worker-a"},
+				"tasks":     []map[string]interface{}{},
+				"type":      "sink",
+			})
+		case "/":
+			json.NewEncoder(w).Encode(map[string]interface{}{})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	summary, err := fetchMonitoringSummary(context.Background(), server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("fetchMonitoringSummary returned error: %v", err)
+	}
+
+	if got := summary.ConnectorStates["stopped"]; got != 1 {
+		t.Fatalf("expected 1 stopped connector, got %d", got)
+	}
+	if summary.Totals["stopped"] != 1 || summary.Totals["degraded"] != 0 {
+		t.Fatalf("expected stopped connector in totals, got %v", summary.Totals)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// The network policy settings are comma-separated lists of CIDRs or single IP
+	// addresses. Read lists apply to requests that cannot change a cluster, mutate lists
+	// to every other request; a list left empty does not restrict anything.
+	networkPolicyReadAllow   = getEnv("NETWORK_POLICY_READ_ALLOW", "")
+	networkPolicyReadDeny    = getEnv("NETWORK_POLICY_READ_DENY", "")
+	networkPolicyMutateAllow = getEnv("NETWORK_POLICY_MUTATE_ALLOW", "")
+	networkPolicyMutateDeny  = getEnv("NETWORK_POLICY_MUTATE_DENY", "")
+)
+
+// Policies of the network policy, by the kind of request they apply to.
+const (
+	networkPolicyRead   = "read"
+	networkPolicyMutate = "mutate"
+)
+
+// ipRules is the allowlist and denylist of one policy.
+type ipRules struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func (rules ipRules) empty() bool {
+	return len(rules.allow) == 0 && len(rules.deny) == 0
+}
+
+// permits reports whether a client at ip may pass. A denylist match always wins; a
+// non-empty allowlist must match. Clients whose address cannot be parsed are rejected
+// by any non-empty policy.
+func (rules ipRules) permits(ip net.IP) bool {
+	if rules.empty() {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, network := range rules.deny {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	if len(rules.allow) == 0 {
+		return true
+	}
+	for _, network := range rules.allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// networkPolicy restricts which client addresses may reach the API, with separate
+// rules for reads and for requests that change a cluster, so that, for example,
+// dashboards stay open internally while connector deletion needs a jump host.
+type networkPolicy struct {
+	read   ipRules
+	mutate ipRules
+}
+
+// loadNetworkPolicy builds the policy from the NETWORK_POLICY_* settings. It returns
+// nil when none is set.
+func loadNetworkPolicy() (*networkPolicy, error) {
+	policy := &networkPolicy{}
+	for _, setting := range []struct {
+		name, value string
+		target      *[]*net.IPNet
+	}{
+		{"NETWORK_POLICY_READ_ALLOW", networkPolicyReadAllow, &policy.read.allow},
+		{"NETWORK_POLICY_READ_DENY", networkPolicyReadDeny, &policy.read.deny},
+		{"NETWORK_POLICY_MUTATE_ALLOW", networkPolicyMutateAllow, &policy.mutate.allow},
+		{"NETWORK_POLICY_MUTATE_DENY", networkPolicyMutateDeny, &policy.mutate.deny},
+	} {
+		networks, err := parseNetworkList(setting.value)
+		if err != nil {
+			return nil, &configError{name: setting.name, value: setting.value}
+		}
+		*setting.target = networks
+	}
+	if policy.read.empty() && policy.mutate.empty() {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// parseNetworkList parses a comma-separated list of CIDRs and IP addresses; a bare
+// address stands for itself alone.
+func parseNetworkList(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// String summarises the policy for the startup log.
+func (p *networkPolicy) String() string {
+	describe := func(rules ipRules) string {
+		return fmt.Sprintf("%d allowed, %d denied", len(rules.allow), len(rules.deny))
+	}
+	return fmt.Sprintf("read: %s; mutate: %s", describe(p.read), describe(p.mutate))
+}
+
+// rulesFor returns the policy that applies to r and its name. Requests outside a
+// cluster, such as /api/debug, are classified by method alone.
+func (p *networkPolicy) rulesFor(r *http.Request) (string, ipRules) {
+	rest := r.URL.Path
+	if cluster := mux.Vars(r)["cluster"]; cluster != "" {
+		rest = strings.TrimPrefix(rest, "/api/"+cluster)
+	}
+	if readOnlyRequest(r, rest) {
+		return networkPolicyRead, p.read
+	}
+	return networkPolicyMutate, p.mutate
+}
+
+// middleware rejects requests from addresses the policy does not permit with 403.
+// Health probes are never restricted. It runs before authentication, so denied clients
+// cannot reach the login endpoints either, and records denied mutations in the audit
+// log itself.
+func (p *networkPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/health/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name, rules := p.rulesFor(r)
+		clientIP := extractClientIP(r)
+		if rules.permits(net.ParseIP(clientIP)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		log.Printf("network_policy: event=denied client_ip=%s method=%s path=%s policy=%s", clientIP, r.Method, r.URL.Path, name)
+		if op, ok := detectAuditOperation(r.Method, r.URL.Path); ok && name == networkPolicyMutate {
+			entry := newAuditEntry(r, op.action, op.connector, http.StatusForbidden, map[string]interface{}{"networkPolicy": name})
+			entry.ClientIP, entry.TargetType = clientIP, op.targetType
+			logAudit(entry)
+		}
+		writeJSONError(w, http.StatusForbidden, "network_policy_denied", fmt.Sprintf("%s requests are not allowed from %s", name, clientIP))
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestLoadNetworkPolicy(t *testing.T) {
+	originals := []string{networkPolicyReadAllow, networkPolicyReadDeny, networkPolicyMutateAllow, networkPolicyMutateDeny}
+	defer func() {
+		networkPolicyReadAllow, networkPolicyReadDeny, networkPolicyMutateAllow, networkPolicyMutateDeny = originals[0], originals[1], originals[2], originals[3]
+	}()
+
+	networkPolicyReadAllow, networkPolicyReadDeny, networkPolicyMutateAllow, networkPolicyMutateDeny = "", " , ", "", ""
+	if policy, err := loadNetworkPolicy(); err != nil || policy != nil {
+		t.Fatalf("expected no policy without settings, got %v %v", policy, err)
+	}
+
+	networkPolicyMutateAllow = "10.20.30.0/24, 192.168.1.7, fd00::1"
+	policy, err := loadNetworkPolicy()
+	if err != nil || policy == nil || len(policy.mutate.allow) != 3 || !policy.read.empty() {
+		t.Fatalf("unexpected policy %v %v", policy, err)
+	}
+	if ones, bits := policy.mutate.allow[1].Mask.Size(); ones != 32 || bits != 32 {
+		t.Fatalf("expected a bare IPv4 address to be a /32, got /%d of %d", ones, bits)
+	}
+	if ones, _ := policy.mutate.allow[2].Mask.Size(); ones != 128 {
+		t.Fatalf("expected a bare IPv6 address to be a /128, got /%d", ones)
+	}
+
+	for _, value := range []string{"10.0.0.0/33", "jump-host", "10.0.0.1/"} {
+		networkPolicyReadDeny = value
+		if _, err := loadNetworkPolicy(); err == nil || !strings.Contains(err.Error(), "NETWORK_POLICY_READ_DENY") {
+			t.Errorf("%q: expected a config error, got %v", value, err)
+		}
+	}
+}
+
+func TestNetworkPolicyMiddleware(t *testing.T) {
+	logger := withTestAuditLog(t, 10)
+	read, _ := parseNetworkList("10.0.0.0/8")
+	readDeny, _ := parseNetworkList("10.9.0.0/16")
+	mutate, _ := parseNetworkList("10.20.30.0/24")
+	policy := &networkPolicy{read: ipRules{allow: read, deny: readDeny}, mutate: ipRules{allow: mutate}}
+
+	router := mux.NewRouter()
+	router.Use(policy.middleware)
+	router.Use(auditMiddleware)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/health", ok)
+	router.HandleFunc("/api/{cluster}/connectors/{name}", ok)
+	router.HandleFunc("/api/{cluster}/connectors/{name}/config/validate", ok)
+	router.HandleFunc("/api/{cluster}/connectors/{name}/pause", ok)
+
+	tests := []struct {
+		method, path, client string
+		want                 int
+	}{
+		{http.MethodGet, "/api/default/connectors/orders", "10.1.2.3", http.StatusOK},
+		{http.MethodGet, "/api/default/connectors/orders", "172.16.0.1", http.StatusForbidden},
+		{http.MethodGet, "/api/default/connectors/orders", "10.9.0.4", http.StatusForbidden},
+		{http.MethodPut, "/api/default/connectors/orders/config/validate", "10.1.2.3", http.StatusOK},
+		{http.MethodDelete, "/api/default/connectors/orders?dryRun=true", "10.1.2.3", http.StatusOK},
+		{http.MethodPut, "/api/default/connectors/orders/pause?dryRun=true", "10.1.2.3", http.StatusForbidden},
+		{http.MethodDelete, "/api/default/connectors/orders", "10.1.2.3", http.StatusForbidden},
+		{http.MethodDelete, "/api/default/connectors/orders", "10.20.30.40", http.StatusOK},
+		{http.MethodGet, "/health", "172.16.0.1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.RemoteAddr = tt.client + ":52100"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s from %s: expected %d, got %d", tt.method, tt.path, tt.client, tt.want, rr.Code)
+		}
+		if rr.Code == http.StatusForbidden && !strings.Contains(rr.Body.String(), "network_policy_denied") {
+			t.Errorf("unexpected body %s", rr.Body.String())
+		}
+	}
+
+	if entries := logger.Query(AuditFilter{Connector: "orders", Status: "failure"}); len(entries) != 2 || entries[0].Action != auditActionDelete || entries[1].Action != auditActionPause || entries[0].HTTPStatus != http.StatusForbidden {
+		t.Fatalf("expected the denied pause and deletion to be audited, got %+v", entries)
+	}
+
+	// Without TRUST_PROXY_HEADERS a forwarded address cannot get around the policy.
+	req := httptest.NewRequest(http.MethodDelete, "/api/default/connectors/orders", nil)
+	req.RemoteAddr = "172.16.0.1:52100"
+	req.Header.Set("X-Forwarded-For", "10.20.30.40")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected the forwarded address to be ignored, got %d", rr.Code)
+	}
+
+	// Behind a trusted load balancer, only the address it appended counts.
+	original := trustProxyHeaders
+	t.Cleanup(func() { trustProxyHeaders = original })
+	trustProxyHeaders = true
+	for _, tt := range []struct {
+		forwarded string
+		want      int
+	}{
+		{"10.20.30.40, 172.16.0.1", http.StatusForbidden},
+		{"172.16.0.1, 10.20.30.40", http.StatusOK},
+		{"10.20.30.40, ", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodDelete, "/api/default/connectors/orders", nil)
+		req.RemoteAddr = "10.0.0.2:52100"
+		req.Header.Set("X-Forwarded-For", tt.forwarded)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("X-Forwarded-For %q: expected %d, got %d", tt.forwarded, tt.want, rr.Code)
+		}
+	}
+}
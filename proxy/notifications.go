@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	eventConnectorFailed        = "connector_failed"
+	eventConnectorRecovered     = "connector_recovered"
+	eventConnectorLagging       = "connector_lagging"
+	eventConnectorThroughputLow = "connector_throughput_low"
+)
+
+var (
+	notifyWebhookURL         = getEnv("NOTIFY_WEBHOOK_URL", "")
+	notifySlackWebhookURL    = getEnv("NOTIFY_SLACK_WEBHOOK_URL", "")
+	notifySMTPAddr           = getEnv("NOTIFY_SMTP_ADDR", "")
+	notifySMTPUsername       = getEnv("NOTIFY_SMTP_USERNAME", "")
+	notifySMTPPassword       = getEnv("NOTIFY_SMTP_PASSWORD", "")
+	notifyEmailFrom          = getEnv("NOTIFY_EMAIL_FROM", "")
+	notifyEmailTo            = getEnv("NOTIFY_EMAIL_TO", "")
+	notificationTemplatesDir = getEnv("NOTIFICATION_TEMPLATES_DIR", "")
+
+	notifications = newNotifier(nil, newNotificationTemplates())
+)
+
+// builtinNotificationTemplates are used when no custom template matches an event.
+var builtinNotificationTemplates = map[string]string{
+	eventConnectorFailed: `{{define "subject"}}[kconnect] {{.Connector}} FAILED{{end}}` +
+		`Connector {{.Connector}}{{if .Cluster}} on cluster {{.Cluster}}{{end}} is FAILED (was {{.PreviousState}}).` +
+		`{{if .FailedTasks}} Failed tasks: {{join .FailedTasks}}.{{end}}{{if .Error}}
+Error: {{.Error}}{{end}}{{with .Metadata.ownerContact}}
+Owner: {{.}}{{end}}{{with .Metadata.runbookUrl}}
+Runbook: {{.}}{{end}}`,
+	eventConnectorRecovered: `{{define "subject"}}[kconnect] {{.Connector}} recovered{{end}}` +
+		`Connector {{.Connector}}{{if .Cluster}} on cluster {{.Cluster}}{{end}} recovered and is {{upper .State}} again.`,
+	eventConnectorLagging: `{{define "subject"}}[kconnect] {{.Connector}} lagging{{end}}` +
+		`Connector {{.Connector}}{{if .Cluster}} on cluster {{.Cluster}}{{end}} is {{printf "%.0f" .Value}} records behind (threshold {{printf "%.0f" .Threshold}}).`,
+	eventConnectorThroughputLow: `{{define "subject"}}[kconnect] {{.Connector}} throughput low{{end}}` +
+		`Connector {{.Connector}}{{if .Cluster}} on cluster {{.Cluster}}{{end}} is writing {{printf "%.2f" .Value}} records/sec (floor {{printf "%.2f" .Threshold}}).`,
+	eventDriftDetected: `{{define "subject"}}[kconnect] {{.Cluster}} drifted from its desired state{{end}}` +
+		`Cluster {{.Cluster}} no longer matches its desired state{{with .Metadata.revision}} (revision {{.}}){{end}}: {{printf "%.0f" .Value}} connector(s) differ.` +
+		`{{with .Metadata.missing}} Missing: {{.}}.{{end}}{{with .Metadata.extra}} Not desired: {{.}}.{{end}}{{with .Metadata.changed}} Changed: {{.}}.{{end}}`,
+}
+
+// NotificationEvent is the data passed to notification templates.
+type NotificationEvent struct {
+	Type          string            `json:"type"`
+	Cluster       string            `json:"cluster"`
+	Connector     string            `json:"connector"`
+	ConnectorType string            `json:"connectorType"`
+	State         string            `json:"state"`
+	PreviousState string            `json:"previousState"`
+	WorkerID      string            `json:"workerId,omitempty"`
+	FailedTasks   []int             `json:"failedTasks,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Trace         string            `json:"trace,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Value         float64           `json:"value,omitempty"`     // measured value for threshold alerts
+	Threshold     float64           `json:"threshold,omitempty"` // threshold that was crossed
+	Timestamp     time.Time         `json:"timestamp"`
+}
+
+type notificationChannel interface {
+	name() string
+	send(ctx context.Context, event NotificationEvent, subject, message string) error
+}
+
+type webhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+func (c webhookChannel) name() string { return "webhook" }
+
+func (c webhookChannel) send(ctx context.Context, event NotificationEvent, subject, message string) error {
+	return postJSON(ctx, c.client, c.url, map[string]interface{}{
+		"event":   event,
+		"subject": subject,
+		"message": message,
+	})
+}
+
+type slackChannel struct {
+	url    string
+	client *http.Client
+}
+
+func (c slackChannel) name() string { return "slack" }
+
+func (c slackChannel) send(ctx context.Context, _ NotificationEvent, _, message string) error {
+	return postJSON(ctx, c.client, c.url, map[string]string{"text": message})
+}
+
+type emailChannel struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (c emailChannel) name() string { return "email" }
+
+func (c emailChannel) send(_ context.Context, _ NotificationEvent, subject, message string) error {
+	var auth smtp.Auth
+	if c.username != "" {
+		host := c.addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", c.username, c.password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(message)
+
+	return smtp.SendMail(c.addr, auth, c.from, c.to, msg.Bytes())
+}
+
+func postJSON(ctx context.Context, client *http.Client, target string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, target)
+	}
+	return nil
+}
+
+// notificationTemplates resolves the template used for an event type and channel.
+//
+// Templates are looked up in NOTIFICATION_TEMPLATES_DIR in this order:
+// <event>.<channel>.tmpl, <event>.tmpl, default.<channel>.tmpl, default.tmpl, and
+// finally the built-in template for the event. A template may define a "subject"
+// block which is used for email subjects and webhook payloads.
+type notificationTemplates struct {
+	custom map[string]*template.Template
+}
+
+var notificationTemplateFuncs = template.FuncMap{
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"firstLine": firstLine,
+	"truncate": func(n int, s string) string {
+		if len(s) <= n {
+			return s
+		}
+		return s[:n] + "..."
+	},
+	"join": func(values []int) string {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = strconv.Itoa(v)
+		}
+		return strings.Join(parts, ", ")
+	},
+}
+
+func newNotificationTemplates() *notificationTemplates {
+	return &notificationTemplates{custom: map[string]*template.Template{}}
+}
+
+// loadNotificationTemplates parses every *.tmpl file in dir.
+func loadNotificationTemplates(dir string) (*notificationTemplates, error) {
+	templates := newNotificationTemplates()
+	if dir == "" {
+		return templates, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read template %s: %w", path, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		tmpl, err := template.New(name).Funcs(notificationTemplateFuncs).Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("parse template %s: %w", path, err)
+		}
+		templates.custom[name] = tmpl
+	}
+
+	return templates, nil
+}
+
+func (t *notificationTemplates) lookup(eventType, channel string) (*template.Template, error) {
+	for _, name := range []string{eventType + "." + channel, eventType, "default." + channel, "default"} {
+		if tmpl, ok := t.custom[name]; ok {
+			return tmpl, nil
+		}
+	}
+
+	builtin, ok := builtinNotificationTemplates[eventType]
+	if !ok {
+		builtin = `{{define "subject"}}[kconnect] {{.Connector}} {{.Type}}{{end}}Connector {{.Connector}}: {{.Type}} ({{.State}})`
+	}
+	return template.New(eventType).Funcs(notificationTemplateFuncs).Parse(builtin)
+}
+
+// render returns the subject and message for event on the given channel.
+func (t *notificationTemplates) render(event NotificationEvent, channel string) (string, string, error) {
+	tmpl, err := t.lookup(event.Type, channel)
+	if err != nil {
+		return "", "", err
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, event); err != nil {
+		return "", "", fmt.Errorf("render %s template: %w", event.Type, err)
+	}
+
+	subject := fmt.Sprintf("[kconnect] %s %s", event.Connector, event.Type)
+	if sub := tmpl.Lookup("subject"); sub != nil {
+		var buf bytes.Buffer
+		if err := sub.Execute(&buf, event); err != nil {
+			return "", "", fmt.Errorf("render %s subject: %w", event.Type, err)
+		}
+		subject = strings.TrimSpace(buf.String())
+	}
+
+	return subject, strings.TrimSpace(body.String()), nil
+}
+
+// connectorHealth is the notifier's memory of a connector between polls.
+type connectorHealth struct {
+	state       string
+	before      string    // last state before the connector failed
+	failedSince time.Time // zero unless failed
+	alerted     bool      // a failure notification was sent for the current failure
+}
+
+// notifier turns connector state transitions observed by the monitoring poller, and
+// threshold breaches observed by the metrics collector, into notifications.
+type notifier struct {
+	mu         sync.Mutex
+	states     map[string]map[string]connectorHealth // cluster -> connector -> health
+	breaches   map[string]map[string]bool            // connector -> event type -> active
+	channels   []notificationChannel
+	templates  *notificationTemplates
+	thresholds func(connector string) alertThresholds
+	now        func() time.Time
+}
+
+func newNotifier(channels []notificationChannel, templates *notificationTemplates) *notifier {
+	return &notifier{
+		states:     make(map[string]map[string]connectorHealth),
+		breaches:   make(map[string]map[string]bool),
+		channels:   channels,
+		templates:  templates,
+		thresholds: alertThresholdsFor,
+		now:        time.Now,
+	}
+}
+
+// newNotifierFromEnv builds the notifier from the NOTIFY_* environment variables.
+func newNotifierFromEnv() (*notifier, error) {
+	templates, err := loadNotificationTemplates(notificationTemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newUpstreamClient(10 * time.Second)
+	var channels []notificationChannel
+	if notifyWebhookURL != "" {
+		channels = append(channels, webhookChannel{url: notifyWebhookURL, client: client})
+	}
+	if notifySlackWebhookURL != "" {
+		channels = append(channels, slackChannel{url: notifySlackWebhookURL, client: client})
+	}
+	if notifySMTPAddr != "" {
+		if notifyEmailFrom == "" || notifyEmailTo == "" {
+			return nil, fmt.Errorf("NOTIFY_EMAIL_FROM and NOTIFY_EMAIL_TO are required when NOTIFY_SMTP_ADDR is set")
+		}
+		var to []string
+		for _, addr := range strings.Split(notifyEmailTo, ",") {
+			if trimmed := strings.TrimSpace(addr); trimmed != "" {
+				to = append(to, trimmed)
+			}
+		}
+		channels = append(channels, emailChannel{
+			addr:     notifySMTPAddr,
+			username: notifySMTPUsername,
+			password: notifySMTPPassword,
+			from:     notifyEmailFrom,
+			to:       to,
+		})
+	}
+
+	return newNotifier(channels, templates), nil
+}
+
+func (n *notifier) enabled() bool {
+	return len(n.channels) > 0
+}
+
+// observe compares statuses with the previous poll and dispatches an event for every
+// connector that has been failed for at least its failure-duration threshold, and for
+// every alerted connector that recovered. Connectors seen for the first time only
+// establish a baseline.
+func (n *notifier) observe(clusterID string, statuses []connectorStatusResponse) {
+	now := n.now().UTC()
+	var events []NotificationEvent
+
+	n.mu.Lock()
+	previous := n.states[clusterID]
+	current := make(map[string]connectorHealth, len(statuses))
+	for _, status := range statuses {
+		event := connectorHealthEvent(clusterID, status, now)
+		prev, known := previous[status.Name]
+		health := connectorHealth{state: event.State}
+
+		if event.State == "failed" {
+			switch {
+			case !known:
+				health.failedSince, health.alerted = now, true
+			case prev.state != "failed":
+				health.before, health.failedSince = prev.state, now
+			default:
+				health.before, health.failedSince, health.alerted = prev.before, prev.failedSince, prev.alerted
+			}
+			if !health.alerted && now.Sub(health.failedSince) >= n.thresholds(status.Name).FailureDuration {
+				health.alerted = true
+				event.Type = eventConnectorFailed
+				event.PreviousState = health.before
+				event.Metadata = connectorMetadata.get(alertMetadataCluster, status.Name).alertMetadata()
+				events = append(events, event)
+			}
+		} else if known && prev.state == "failed" && prev.alerted && event.State == "running" {
+			event.Type = eventConnectorRecovered
+			event.PreviousState = prev.state
+			events = append(events, event)
+		}
+		current[status.Name] = health
+	}
+	n.states[clusterID] = current
+	n.mu.Unlock()
+
+	if len(events) > 0 && n.enabled() {
+		go n.deliver(events)
+	}
+}
+
+// connectorHealthEvent describes status as an event; a connector with any failed task
+// is considered failed.
+func connectorHealthEvent(clusterID string, status connectorStatusResponse, now time.Time) NotificationEvent {
+	event := NotificationEvent{
+		Cluster:       clusterID,
+		Connector:     status.Name,
+		ConnectorType: status.Type,
+		State:         normalizeState(status.Connector.State),
+		WorkerID:      status.Connector.WorkerID,
+		Trace:         status.Connector.Trace,
+		Timestamp:     now,
+	}
+
+	for _, task := range status.Tasks {
+		if normalizeState(task.State) != "failed" {
+			continue
+		}
+		event.State = "failed"
+		event.FailedTasks = append(event.FailedTasks, task.ID)
+		if event.Trace == "" {
+			event.Trace = task.Trace
+			event.WorkerID = task.WorkerID
+		}
+	}
+	event.Error = firstLine(event.Trace)
+
+	return event
+}
+
+// observeMetrics dispatches an event when a connector's offset lag rises above, or its
+// throughput falls below, its threshold. Each breach is reported once and re-armed when
+// the metric returns within bounds.
+func (n *notifier) observeMetrics(samples []ConnectorMetrics) {
+	var events []NotificationEvent
+
+	n.mu.Lock()
+	for _, sample := range samples {
+		thresholds := n.thresholds(sample.Connector)
+		active := n.breaches[sample.Connector]
+		if active == nil {
+			active = make(map[string]bool)
+			n.breaches[sample.Connector] = active
+		}
+
+		checks := []struct {
+			eventType string
+			breached  bool
+			value     float64
+			threshold float64
+		}{
+			{eventConnectorLagging, thresholds.MaxLag > 0 && sample.OffsetLag > thresholds.MaxLag, sample.OffsetLag, thresholds.MaxLag},
+			{eventConnectorThroughputLow, thresholds.MinThroughput > 0 && sample.RecordsOutPerSec < thresholds.MinThroughput, sample.RecordsOutPerSec, thresholds.MinThroughput},
+		}
+		for _, check := range checks {
+			if check.breached && !active[check.eventType] {
+				events = append(events, NotificationEvent{
+					Type:      check.eventType,
+					Connector: sample.Connector,
+					State:     "running",
+					Value:     check.value,
+					Threshold: check.threshold,
+					Timestamp: sample.Timestamp,
+				})
+			}
+			active[check.eventType] = check.breached
+		}
+	}
+	n.mu.Unlock()
+
+	if len(events) > 0 && n.enabled() {
+		go n.deliver(events)
+	}
+}
+
+func (n *notifier) deliver(events []NotificationEvent) {
+	for _, event := range events {
+		if silence, ok := alertSilences.silencing(event); ok {
+			target := event.Connector
+			if target == "" {
+				target = event.Cluster
+			}
+			log.Printf("notifications: %s for %s suppressed by silence %s (%s)", event.Type, target, silence.ID, silence.Reason)
+			continue
+		}
+		for _, channel := range n.channels {
+			subject, message, err := n.templates.render(event, channel.name())
+			if err != nil {
+				log.Printf("notifications: %s: %v", channel.name(), err)
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			if err := channel.send(ctx, event, subject, message); err != nil {
+				log.Printf("notifications: failed to send %s for %s via %s: %v", event.Type, event.Connector, channel.name(), err)
+			}
+			cancel()
+		}
+	}
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordedNotification struct {
+	event   NotificationEvent
+	subject string
+	message string
+}
+
+type recordingChannel struct {
+	channel string
+	sent    chan recordedNotification
+}
+
+func (c recordingChannel) name() string { return c.channel }
+
+func (c recordingChannel) send(_ context.Context, event NotificationEvent, subject, message string) error {
+	c.sent <- recordedNotification{event: event, subject: subject, message: message}
+	return nil
+}
+
+func statusFixture(name, connectorState string, taskStates ...string) connectorStatusResponse {
+	var status connectorStatusResponse
+	status.Name = name
+	status.Type = "sink"
+	status.Connector.State = connectorState
+	for i, state := range taskStates {
+		task := struct {
+			ID       int    `json:"id"`
+			State    string `json:"state"`
+			WorkerID string `json:"worker_id"`
+			Trace    string `json:"trace,omitempty"`
+		}{ID: i, State: state, WorkerID: "worker-1:8083"}
+		if state == "FAILED" {
+			task.Trace = "org.apache.kafka.connect.errors.ConnectException: boom\n\tat Foo.bar(Foo.java:1)"
+		}
+		status.Tasks = append(status.Tasks, task)
+	}
+	return status
+}
+
+func TestNotifierDispatchesTransitions(t *testing.T) {
+	channel := recordingChannel{channel: "slack", sent: make(chan recordedNotification, 4)}
+	n := newNotifier([]notificationChannel{channel}, newNotificationTemplates())
+
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "RUNNING", "RUNNING")})
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "RUNNING", "FAILED")})
+
+	select {
+	case got := <-channel.sent:
+		if got.event.Type != eventConnectorFailed || got.event.PreviousState != "running" {
+			t.Fatalf("unexpected event: %+v", got.event)
+		}
+		if len(got.event.FailedTasks) != 1 || got.event.FailedTasks[0] != 0 {
+			t.Fatalf("expected failed task 0, got %v", got.event.FailedTasks)
+		}
+		if !strings.Contains(got.message, "ConnectException: boom") || strings.Contains(got.message, "Foo.java") {
+			t.Fatalf("expected message to contain first trace line only, got %q", got.message)
+		}
+		if got.subject != "[kconnect] orders FAILED" {
+			t.Fatalf("unexpected subject %q", got.subject)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected failure notification")
+	}
+
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "RUNNING", "RUNNING")})
+	select {
+	case got := <-channel.sent:
+		if got.event.Type != eventConnectorRecovered {
+			t.Fatalf("expected recovery event, got %+v", got.event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected recovery notification")
+	}
+}
+
+func TestNotifierIgnoresFirstObservation(t *testing.T) {
+	channel := recordingChannel{channel: "webhook", sent: make(chan recordedNotification, 1)}
+	n := newNotifier([]notificationChannel{channel}, newNotificationTemplates())
+
+	n.observe("prod", []connectorStatusResponse{statusFixture("orders", "FAILED")})
+
+	select {
+	case got := <-channel.sent:
+		t.Fatalf("unexpected notification for baseline observation: %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotificationTemplatesCustomLookup(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"connector_failed.slack.tmpl": `:rotating_light: {{.Connector}} {{upper .State}} {{.Error | truncate 10}}`,
+		"default.tmpl":                `{{define "subject"}}custom {{.Type}}{{end}}generic {{.Connector}}`,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600); err != nil {
+			t.Fatalf("failed to write template: %v", err)
+		}
+	}
+
+	templates, err := loadNotificationTemplates(dir)
+	if err != nil {
+		t.Fatalf("loadNotificationTemplates returned error: %v", err)
+	}
+
+	event := NotificationEvent{Type: eventConnectorFailed, Connector: "orders", State: "failed", Error: "java.lang.RuntimeException"}
+
+	_, message, err := templates.render(event, "slack")
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	if message != ":rotating_light: orders FAILED java.lang...." {
+		t.Fatalf("unexpected slack message %q", message)
+	}
+
+	subject, message, err := templates.render(event, "email")
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	if subject != "custom connector_failed" || message != "generic orders" {
+		t.Fatalf("unexpected fallback rendering: %q / %q", subject, message)
+	}
+}
+
+func TestLoadNotificationTemplatesRejectsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "default.tmpl"), []byte("{{.Connector"), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	if _, err := loadNotificationTemplates(dir); err == nil {
+		t.Fatalf("expected parse error for invalid template")
+	}
+}
+
+func TestSlackChannelPostsText(t *testing.T) {
+	received := make(chan map[string]string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	channel := slackChannel{url: server.URL, client: server.Client()}
+	if err := channel.send(context.Background(), NotificationEvent{}, "subject", "hello"); err != nil {
+		t.Fatalf("send returned error: %v", err)
+	}
+	if got := <-received; got["text"] != "hello" {
+		t.Fatalf("unexpected slack payload %v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+)
+
+// offsetsConfirmHeader carries the token that must accompany offset resets and
+// alterations. GET returns the token for the offsets currently stored in Connect, so a
+// mutation is only accepted from a client that has looked at those exact offsets.
+const offsetsConfirmHeader = "X-Offsets-Confirm-Token"
+
+func offsetsConfirmToken(connector string, offsets []byte) string {
+	sum := sha256.Sum256(append([]byte(connector+"\n"), offsets...))
+	return hex.EncodeToString(sum[:12])
+}
+
+// fetchConnectorOffsets returns the raw offsets document for a connector (Connect 3.5+).
+func fetchConnectorOffsets(ctx context.Context, client *http.Client, baseURL, name string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(baseURL, "connectors", url.PathEscape(name), "offsets"), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("read offsets for %s: %w", name, err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// connectorOffsetsHandler serves GET, DELETE (reset) and PATCH (alter) for
+// /api/{cluster}/connectors/{name}/offsets. Mutations require the connector to be
+// STOPPED and a confirmation token obtained from a previous GET.
+func connectorOffsetsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	baseURL := connectURLFor(vars["cluster"])
+	client := connectClientFor(vars["cluster"], upstreamRouteClass(r.Method, r.URL.Path))
+
+	before, status, err := fetchConnectorOffsets(r.Context(), client, baseURL, name)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, "offsets_fetch_failed", err.Error())
+		return
+	}
+
+	if r.Method == http.MethodGet || status != http.StatusOK {
+		if status == http.StatusOK {
+			w.Header().Set(offsetsConfirmHeader, offsetsConfirmToken(name, before))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(before)
+		return
+	}
+
+	var payload []byte
+	if r.Method == http.MethodPatch {
+		payload, err = io.ReadAll(r.Body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_body", "failed to read request body")
+			return
+		}
+		if !json.Valid(payload) {
+			writeJSONError(w, http.StatusBadRequest, "invalid_body", "offsets payload must be valid JSON")
+			return
+		}
+	}
+
+	token := r.Header.Get(offsetsConfirmHeader)
+	if token == "" {
+		token = r.URL.Query().Get("confirmToken")
+	}
+	if token == "" {
+		writeJSONError(w, http.StatusPreconditionRequired, "confirmation_required",
+			fmt.Sprintf("GET the current offsets and send the returned %s header to confirm this change", offsetsConfirmHeader))
+		return
+	}
+	if token != offsetsConfirmToken(name, before) {
+		writeJSONError(w, http.StatusConflict, "offsets_changed", "offsets changed since the confirmation token was issued; review them again")
+		return
+	}
+
+	connectorStatus, err := fetchConnectorStatus(r.Context(), client, baseURL, name)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, "status_fetch_failed", err.Error())
+		return
+	}
+	if normalizeState(connectorStatus.Connector.State) != "stopped" {
+		writeJSONError(w, http.StatusConflict, "connector_not_stopped",
+			fmt.Sprintf("connector %s is %s; stop it before modifying offsets", name, connectorStatus.Connector.State))
+		return
+	}
+
+	action := auditActionResetOffsets
+	if r.Method == http.MethodPatch {
+		action = auditActionAlterOffsets
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, joinURL(baseURL, "connectors", url.PathEscape(name), "offsets"), bytes.NewReader(payload))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "request_failed", err.Error())
+		return
+	}
+	if len(payload) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		recordAudit(r, action, name, http.StatusBadGateway, map[string]interface{}{"before": rawJSON(before), "error": err.Error()})
+		writeJSONError(w, http.StatusBadGateway, "connect_unreachable", err.Error())
+		return
+	}
+
+	details := map[string]interface{}{"before": rawJSON(before)}
+	if len(payload) > 0 {
+		details["request"] = rawJSON(payload)
+	}
+	if resp.StatusCode < 300 {
+		if after, afterStatus, err := fetchConnectorOffsets(r.Context(), client, baseURL, name); err == nil && afterStatus == http.StatusOK {
+			details["after"] = rawJSON(after)
+		} else if err != nil {
+			log.Printf("offsets: failed to read offsets for %s after %s: %v", name, action, err)
+		}
+	}
+	recordAudit(r, action, name, resp.StatusCode, details)
+
+	if err := writeRedactedResponse(w, resp); err != nil {
+		log.Printf("offsets: failed to write response: %v", err)
+	}
+}
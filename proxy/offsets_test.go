@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// newOffsetsServer fakes the Connect offsets API for a single connector in the given state.
+func newOffsetsServer(t *testing.T, state string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	offsets := `{"offsets":[{"partition":{"file":"in.txt"},"offset":{"position":42}}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "GET /connectors/alpha/offsets":
+			w.Write([]byte(offsets))
+		case "DELETE /connectors/alpha/offsets":
+			offsets = `{"offsets":[]}`
+			w.Write([]byte(`{"message":"The offsets for this connector have been reset successfully"}`))
+		case "GET /connectors/alpha/status":
+			w.Write([]byte(`{"name":"alpha","connector":{"state":"` + state + `","worker_id":"w1"},"tasks":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doOffsetsRequest(method, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/default/connectors/alpha/offsets", nil)
+	if token != "" {
+		req.Header.Set(offsetsConfirmHeader, token)
+	}
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "alpha"})
+	rr := httptest.NewRecorder()
+	connectorOffsetsHandler(rr, req)
+	return rr
+}
+
+func TestConnectorOffsetsResetFlow(t *testing.T) {
+	server := newOffsetsServer(t, "STOPPED")
+	restore := withTestConnectURL(t, server)
+	defer restore()
+	logger := withTestAuditLog(t, 10)
+
+	rr := doOffsetsRequest(http.MethodGet, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	token := rr.Header().Get(offsetsConfirmHeader)
+	if token == "" {
+		t.Fatalf("expected confirmation token header")
+	}
+
+	if rr := doOffsetsRequest(http.MethodDelete, ""); rr.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected 428 without token, got %d", rr.Code)
+	}
+	if rr := doOffsetsRequest(http.MethodDelete, "stale"); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for stale token, got %d", rr.Code)
+	}
+
+	rr = doOffsetsRequest(http.MethodDelete, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected reset to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	entries := logger.Query(AuditFilter{Action: auditActionResetOffsets})
+	if len(entries) != 1 {
+		t.Fatalf("expected one audit entry, got %d", len(entries))
+	}
+	details, err := json.Marshal(entries[0].Details)
+	if err != nil {
+		t.Fatalf("failed to encode details: %v", err)
+	}
+	if !bytes.Contains(details, []byte(`"position":42`)) || !bytes.Contains(details, []byte(`"after":{"offsets":[]}`)) {
+		t.Fatalf("expected before and after offsets in audit details, got %s", details)
+	}
+
+	if rr := doOffsetsRequest(http.MethodDelete, token); rr.Code != http.StatusConflict {
+		t.Fatalf("expected token to be invalidated by the reset, got %d", rr.Code)
+	}
+}
+
+func TestConnectorOffsetsRequireStoppedConnector(t *testing.T) {
+	server := newOffsetsServer(t, "RUNNING")
+	restore := withTestConnectURL(t, server)
+	defer restore()
+	logger := withTestAuditLog(t, 10)
+
+	token := doOffsetsRequest(http.MethodGet, "").Header().Get(offsetsConfirmHeader)
+	rr := doOffsetsRequest(http.MethodDelete, token)
+	if rr.Code != http.StatusConflict || !bytes.Contains(rr.Body.Bytes(), []byte("connector_not_stopped")) {
+		t.Fatalf("expected connector_not_stopped conflict, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(logger.Query(AuditFilter{})) != 0 {
+		t.Fatalf("expected rejected request not to reach Connect or the audit log")
+	}
+}
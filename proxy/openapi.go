@@ -0,0 +1,460 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// swaggerUIAssets is where /api/docs loads Swagger UI from. Point it at an internal
+// mirror of swagger-ui-dist when the browser cannot reach the public CDN.
+var swaggerUIAssets = getEnv("SWAGGER_UI_ASSETS", "https://unpkg.com/swagger-ui-dist@5")
+
+// apiParam is a query parameter of an operation.
+type apiParam struct {
+	Name        string
+	Description string
+}
+
+// apiOperation documents one route. Path parameters are taken from the {braces} in Path.
+// A nil Response means the body is passed through from Kafka Connect unchanged.
+type apiOperation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Query       []apiParam
+	Request     interface{}
+	Response    interface{}
+	ContentType string // response media type, defaults to application/json
+}
+
+// apiOperations is the documented surface of the proxy. Keep it in step with the routes
+// registered in registerRoutes; TestAPIOperationsCoverRoutes fails when a route is missing.
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/health", Tag: "health", Summary: "Liveness and Kafka Connect reachability", Response: map[string]interface{}{}},
+	{Method: "GET", Path: "/health/live", Tag: "health", Summary: "Liveness probe", Response: map[string]string{}},
+	{Method: "GET", Path: "/health/ready", Tag: "health", Summary: "Readiness probe with per-dependency status", Response: map[string]interface{}{}},
+
+	{Method: "GET", Path: "/auth/login", Tag: "auth", Summary: "Start the OIDC login and redirect to the identity provider", Query: []apiParam{{"redirect", "Console path to return to"}}},
+	{Method: "GET", Path: "/auth/callback", Tag: "auth", Summary: "OIDC redirect target; sets the session cookie", Query: []apiParam{{"code", "Authorization code"}, {"state", "Login state"}}},
+	{Method: "GET", Path: "/auth/me", Tag: "auth", Summary: "Profile of the signed-in user", Response: AuthUser{}},
+	{Method: "POST", Path: "/auth/logout", Tag: "auth", Summary: "End the console session"},
+	{Method: "POST", Path: "/auth/local/login", Tag: "auth", Summary: "Sign in as a local user; sets the session cookie", Request: map[string]string{"username": "", "password": ""}, Response: AuthUser{}},
+
+	{Method: "GET", Path: "/api/admin/usage", Tag: "admin", Summary: "Console usage statistics", Query: []apiParam{{"window", "Look-back window, e.g. 30d"}}, Response: UsageReport{}},
+	{Method: "GET", Path: "/api/config", Tag: "admin", Summary: "Effective runtime configuration with credentials masked", Response: EffectiveConfig{}},
+	{Method: "GET", Path: "/api/compare", Tag: "cluster", Summary: "Side-by-side comparison of the connectors, plugins and worker versions of two clusters", Query: []apiParam{{"clusters", "Two comma-separated cluster names, e.g. staging,prod"}}, Response: ClusterComparison{}},
+	{Method: "POST", Path: "/api/debug/capture", Tag: "admin", Summary: "Turn the debug capture of API traffic on or off (bearer DEBUG_CAPTURE_TOKEN)", Request: map[string]bool{}, Response: CaptureStatus{}},
+	{Method: "GET", Path: "/api/debug/faults", Tag: "admin", Summary: "Active upstream fault rules (FAULT_INJECTION, bearer DEBUG_CAPTURE_TOKEN)", Response: FaultList{}},
+	{Method: "POST", Path: "/api/debug/faults", Tag: "admin", Summary: "Inject latency, 5xx errors or connection failures into matching Kafka Connect calls", Request: FaultRule{}, Response: FaultRule{}},
+	{Method: "DELETE", Path: "/api/debug/faults", Tag: "admin", Summary: "Remove every fault rule", Response: FaultList{}},
+	{Method: "DELETE", Path: "/api/debug/faults/{id}", Tag: "admin", Summary: "Remove a fault rule", Response: FaultList{}},
+	{Method: "GET", Path: "/api/debug/transport", Tag: "admin", Summary: "Connection pool settings and per-host connection counters of the upstream transport (bearer DEBUG_CAPTURE_TOKEN)", Response: TransportStatus{}},
+	{Method: "GET", Path: "/api/debug/captures", Tag: "admin", Summary: "Recorded API request/response pairs, newest first (bearer DEBUG_CAPTURE_TOKEN)", Query: []apiParam{{"limit", "Maximum exchanges"}}, Response: CaptureList{}},
+	{Method: "GET", Path: "/api/preferences/{scope}", Tag: "preferences", Summary: "UI preferences of the calling user under a scope", Response: Preferences{}},
+	{Method: "PUT", Path: "/api/preferences/{scope}", Tag: "preferences", Summary: "Replace the calling user's UI preferences under a scope", Request: map[string]interface{}{}, Response: Preferences{}},
+	{Method: "GET", Path: "/api/users", Tag: "users", Summary: "List local users (admins only)", Response: map[string][]LocalUser{}},
+	{Method: "POST", Path: "/api/users", Tag: "users", Summary: "Create a local user (admins only)", Request: localUserRequest{}, Response: LocalUser{}},
+	{Method: "GET", Path: "/api/users/{username}", Tag: "users", Summary: "A local user; users may read their own account", Response: LocalUser{}},
+	{Method: "PUT", Path: "/api/users/{username}", Tag: "users", Summary: "Update a local user; users may change their own password, name and email", Request: localUserPatch{}, Response: LocalUser{}},
+	{Method: "DELETE", Path: "/api/users/{username}", Tag: "users", Summary: "Delete a local user (admins only)"},
+	{Method: "GET", Path: "/api/openapi.json", Tag: "admin", Summary: "This OpenAPI document", Response: map[string]interface{}{}},
+	{Method: "GET", Path: "/api/docs", Tag: "admin", Summary: "Swagger UI for this document", ContentType: "text/html"},
+
+	{Method: "GET", Path: "/api/{cluster}/connectors", Tag: "connectors", Summary: "List connector names (Kafka Connect passthrough)", Query: []apiParam{{"expand", "status and/or info"}}},
+	{Method: "POST", Path: "/api/{cluster}/connectors", Tag: "connectors", Summary: "Create a connector (Kafka Connect passthrough)", Request: map[string]interface{}{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/expanded", Tag: "connectors", Summary: "Filtered, sorted page of connectors with state and task counts", Query: []apiParam{
+		{"page", "1-based page number"}, {"pageSize", "Connectors per page (max 500)"}, {"state", "Comma-separated states; failed also matches failed tasks"},
+		{"type", "source or sink"}, {"tag", "Comma-separated tags; connectors with any of them match"}, {"search", "Substring of the name or connector class"}, {"sort", "name, state, type, class or tasks; prefix - for descending"},
+	}, Response: ConnectorPage{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/stale", Tag: "connectors", Summary: "Connectors paused for days, idle over the metrics window or whose topics were deleted, with a suggested action", Query: []apiParam{{"pausedDays", "Days a connector may stay paused before it is reported (default STALE_PAUSED_DAYS)"}}, Response: StaleReport{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}", Tag: "connectors", Summary: "Connector info (Kafka Connect passthrough)"},
+	{Method: "DELETE", Path: "/api/{cluster}/connectors/{name}", Tag: "connectors", Summary: "Delete a connector (Kafka Connect passthrough)", Query: []apiParam{{"dryRun", "Describe the deletion without performing it"}}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/config", Tag: "connectors", Summary: "Connector config, sensitive values redacted"},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/rotate-secret", Tag: "connectors", Summary: "Replace the values of sensitive config keys without touching the rest of the config, optionally restarting the connector", Request: secretRotationRequest{}, Response: SecretRotationResult{}},
+	{Method: "PUT", Path: "/api/{cluster}/connectors/{name}/config", Tag: "connectors", Summary: "Create or update a connector config", Query: []apiParam{{"dryRun", "Diff and validate the config without applying it"}}, Request: map[string]string{}},
+	{Method: "PATCH", Path: "/api/{cluster}/connectors/{name}/config", Tag: "connectors", Summary: "Change part of a connector config with a JSON Patch (application/json-patch+json) or merge patch (application/merge-patch+json)", Query: []apiParam{{"dryRun", "Diff and validate the patched config without applying it"}}, Request: []jsonPatchOperation{}},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/config/diff", Tag: "connectors", Summary: "Preview a config update against the live config", Request: map[string]string{}, Response: ConfigDiff{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/config/resolved", Tag: "connectors", Summary: "Preview the expansion of ConfigProvider references on the workers", Query: []apiParam{{"redact", "Redact resolved values (default true)"}}, Response: ConfigResolution{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/status", Tag: "connectors", Summary: "Connector and task status (Kafka Connect passthrough)"},
+	{Method: "PUT", Path: "/api/{cluster}/connectors/{name}/pause", Tag: "connectors", Summary: "Pause a connector"},
+	{Method: "PUT", Path: "/api/{cluster}/connectors/{name}/resume", Tag: "connectors", Summary: "Resume a connector"},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/pause", Tag: "connectors", Summary: "Pause a connector and wait until it and its tasks are paused", Query: []apiParam{{"wait", "How long to wait for the pause to take effect (default 10s, max 1m)"}}, Response: StateChangeOutcome{}},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/resume", Tag: "connectors", Summary: "Resume a connector and wait until it and its tasks are running", Query: []apiParam{{"wait", "How long to wait for the resume to take effect (default 10s, max 1m)"}}, Response: StateChangeOutcome{}},
+	{Method: "PUT", Path: "/api/{cluster}/connectors/{name}/stop", Tag: "connectors", Summary: "Stop a connector (Kafka Connect 3.5+)"},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/restart", Tag: "connectors", Summary: "Restart a connector", Query: []apiParam{{"includeTasks", "Also restart tasks"}, {"onlyFailed", "Only restart failed instances"}}},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/restart-advanced", Tag: "connectors", Summary: "Restart with includeTasks/onlyFailed (Kafka Connect 3.0+) and report the states after it settles", Query: []apiParam{{"includeTasks", "Also restart tasks"}, {"onlyFailed", "Only restart failed instances"}, {"wait", "How long to wait for the restart to settle (default 10s, max 1m)"}}, Response: RestartOutcome{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/tasks", Tag: "connectors", Summary: "Connector tasks (Kafka Connect passthrough)"},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/tasks/{task}/restart", Tag: "connectors", Summary: "Restart one task"},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/exists", Tag: "connectors", Summary: "Whether a connector name is taken, with near-miss suggestions", Response: ConnectorExistence{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/offsets", Tag: "offsets", Summary: "Current connector offsets; X-Offsets-Confirm-Token confirms a later change"},
+	{Method: "DELETE", Path: "/api/{cluster}/connectors/{name}/offsets", Tag: "offsets", Summary: "Reset offsets of a stopped connector (requires X-Offsets-Confirm-Token)"},
+	{Method: "PATCH", Path: "/api/{cluster}/connectors/{name}/offsets", Tag: "offsets", Summary: "Alter offsets of a stopped connector (requires X-Offsets-Confirm-Token)", Request: map[string]interface{}{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/restore-points", Tag: "connectors", Summary: "Config and offsets of a connector saved before its recent deletes and config updates, newest first", Response: []RestorePoint{}},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/restore-points/{id}/restore", Tag: "connectors", Summary: "Put a connector back to a restore point, recreating it if it was deleted", Query: []apiParam{
+		{"offsets", "true to also restore the saved offsets; the connector must be STOPPED"},
+	}, Response: RestoreResult{}},
+	{Method: "GET", Path: "/api/{cluster}/restore-points", Tag: "connectors", Summary: "Restore points of every connector of the cluster, including deleted ones", Response: []RestorePoint{}},
+	{Method: "GET", Path: "/api/{cluster}/limits", Tag: "cluster", Summary: "Connector quotas of the cluster and their usage", Response: ClusterLimits{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/metrics", Tag: "metrics", Summary: "Latest Jolokia metrics sample", Response: ConnectorMetrics{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/metrics/history", Tag: "metrics", Summary: "Metrics time series", Query: []apiParam{{"window", "Look-back window, e.g. 15m"}, {"since", "RFC 3339 start"}, {"until", "RFC 3339 end"}, {"tz", "IANA zone for local since/until"}}, Response: MetricsHistory{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/tasks/{task}/threads", Tag: "metrics", Summary: "Stack traces of the threads of a task, from a Jolokia thread dump of its worker", Response: TaskThreadDump{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/metadata", Tag: "metadata", Summary: "Console-side owner, team, tags and overrides", Response: ConnectorMetadata{}},
+	{Method: "PUT", Path: "/api/{cluster}/connectors/{name}/metadata", Tag: "metadata", Summary: "Update connector metadata", Request: metadataPatch{}, Response: ConnectorMetadata{}},
+	{Method: "PUT", Path: "/api/{cluster}/connectors/{name}/tags", Tag: "metadata", Summary: "Replace the tags of a connector", Request: connectorTagsRequest{}, Response: ConnectorMetadata{}},
+	{Method: "PUT", Path: "/api/{cluster}/connectors/{name}/annotations", Tag: "metadata", Summary: "Replace the notes, owner contact and runbook URL of a connector", Request: ConnectorAnnotations{}, Response: ConnectorMetadata{}},
+	{Method: "GET", Path: "/api/{cluster}/tags", Tag: "metadata", Summary: "Tags used in the cluster with their connectors", Response: TagList{}},
+	{Method: "POST", Path: "/api/{cluster}/connectors/metadata/bulk", Tag: "metadata", Summary: "Apply one metadata change to many connectors", Query: []apiParam{{"dryRun", "Preview the change without saving it"}}, Request: bulkMetadataRequest{}, Response: BulkMetadataResult{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/alerts", Tag: "metadata", Summary: "Default, overridden and effective alert thresholds", Response: ConnectorAlertRules{}},
+	{Method: "GET", Path: "/api/{cluster}/alerts/silences", Tag: "metadata", Summary: "Alert silences of the cluster that have not expired", Response: []AlertSilence{}},
+	{Method: "POST", Path: "/api/{cluster}/alerts/silences", Tag: "metadata", Summary: "Suppress notifications matching a cluster, connector pattern or tag for a duration", Request: silenceRequest{}, Response: AlertSilence{}},
+	{Method: "DELETE", Path: "/api/{cluster}/alerts/silences/{id}", Tag: "metadata", Summary: "End a silence early", Response: AlertSilence{}},
+	{Method: "GET", Path: "/api/{cluster}/digests", Tag: "metadata", Summary: "Scheduled health digests of the cluster, with webhook URLs masked", Response: []SummaryDigest{}},
+	{Method: "POST", Path: "/api/{cluster}/digests", Tag: "metadata", Summary: "Post the failed and degraded connectors, or the whole monitoring summary, to a webhook or Slack on a cron schedule", Request: digestRequest{}, Response: SummaryDigest{}},
+	{Method: "DELETE", Path: "/api/{cluster}/digests/{id}", Tag: "metadata", Summary: "Delete a health digest", Response: SummaryDigest{}},
+	{Method: "POST", Path: "/api/{cluster}/digests/{id}/send", Tag: "metadata", Summary: "Send a health digest now", Response: SummaryDigest{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/autorestart", Tag: "metadata", Summary: "Effective auto-restart policy and attempt state", Response: ConnectorAutoRestart{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/errors", Tag: "monitoring", Summary: "Parsed and grouped error traces of a connector and its tasks", Query: []apiParam{{"trace", "Include the full stack trace of each group"}}, Response: ConnectorErrors{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/triage", Tag: "monitoring", Summary: "Ranked likely causes of a connector's failures with the evidence for each", Response: TriageReport{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/history", Tag: "monitoring", Summary: "State transitions of a connector and its tasks with time spent in each state", Query: []apiParam{
+		{"window", "Look-back window, e.g. 24h or 7d"}, {"since", "RFC 3339 start"}, {"until", "RFC 3339 end"}, {"tz", "IANA zone for local since/until"},
+	}, Response: ConnectorStateHistory{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/health", Tag: "monitoring", Summary: "Health grade (A-F) of a connector with the factors behind it", Response: ConnectorHealth{}},
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/schedules", Tag: "metadata", Summary: "Maintenance windows of a connector", Response: []ConnectorSchedule{}},
+	{Method: "POST", Path: "/api/{cluster}/connectors/{name}/schedules", Tag: "metadata", Summary: "Add a recurring window during which the connector is paused", Request: scheduleRequest{}, Response: ConnectorSchedule{}},
+	{Method: "DELETE", Path: "/api/{cluster}/connectors/{name}/schedules/{id}", Tag: "metadata", Summary: "Delete a maintenance window", Response: ConnectorSchedule{}},
+
+	{Method: "GET", Path: "/api/{cluster}/connectors/{name}/consumer-group", Tag: "topics", Summary: "Consumer group of a sink connector: members, committed offsets, lag and stuck partitions", Response: ConsumerGroupReport{}},
+	{Method: "GET", Path: "/api/{cluster}/topics/{topic}/messages", Tag: "topics", Summary: "Preview topic records", Query: []apiParam{
+		{"partition", "Partition to read"}, {"offset", "earliest, latest or a number"}, {"limit", "Records to return"}, {"format", "auto, json, avro, protobuf, string or base64"},
+	}, Response: TopicMessagesResponse{}},
+
+	{Method: "GET", Path: "/api/{cluster}/audit-logs", Tag: "audit", Summary: "Audit log entries, newest first", Query: []apiParam{
+		{"connector", "Connector name"}, {"action", "Audit action"}, {"targetType", "CONNECTOR, TASK or CLUSTER"}, {"status", "SUCCESS or FAILURE"}, {"limit", "Maximum entries"},
+		{"since", "RFC 3339 start"}, {"until", "RFC 3339 end"}, {"tz", "IANA zone"}, {"format", "json, csv or ndjson"},
+	}, Response: []AuditLogEntry{}},
+	{Method: "GET", Path: "/api/{cluster}/audit-logs/stream", Tag: "audit", Summary: "New audit entries as server-sent events", Query: []apiParam{
+		{"connector", "Connector name"}, {"action", "Audit action"}, {"targetType", "CONNECTOR, TASK or CLUSTER"}, {"status", "SUCCESS or FAILURE"}, {"lastEventId", "Replay entries after this ID"},
+	}, ContentType: "text/event-stream"},
+	{Method: "GET", Path: "/api/{cluster}/audit-logs/archives", Tag: "audit", Summary: "Daily archives of audit entries older than AUDIT_ARCHIVE_AFTER, newest first", Response: map[string]interface{}{}},
+	{Method: "GET", Path: "/api/{cluster}/audit-logs/archives/{name}", Tag: "audit", Summary: "Download one daily archive as gzip-compressed NDJSON", ContentType: "application/gzip"},
+	{Method: "GET", Path: "/api/{cluster}/audit-logs/unexplained", Tag: "audit", Summary: "Connectors that appeared or disappeared without an audit entry, newest first", Query: []apiParam{
+		{"since", "RFC 3339 start"}, {"until", "RFC 3339 end"}, {"tz", "IANA zone"},
+	}, Response: UnexplainedChangesReport{}},
+	{Method: "GET", Path: "/api/{cluster}/audit-logs/{id}", Tag: "audit", Summary: "One audit entry with its redacted request and response bodies", Response: AuditLogEntry{}},
+
+	{Method: "GET", Path: "/api/{cluster}/templates", Tag: "templates", Summary: "Connector config templates", Response: []ConnectorTemplate{}},
+	{Method: "POST", Path: "/api/{cluster}/templates/{id}/render", Tag: "templates", Summary: "Render a template into a connector config", Request: templateRenderRequest{}, Response: RenderedConnector{}},
+
+	{Method: "GET", Path: "/api/{cluster}/standby", Tag: "standby", Summary: "Role and last sync of a cold-standby cluster", Response: StandbyStatus{}},
+	{Method: "POST", Path: "/api/{cluster}/failover", Tag: "standby", Summary: "Promote a standby cluster and resume its connectors", Response: FailoverResult{}},
+
+	{Method: "GET", Path: "/api/{cluster}/maintenance", Tag: "cluster", Summary: "Whether the cluster is in maintenance mode", Response: MaintenanceState{}},
+	{Method: "POST", Path: "/api/{cluster}/maintenance", Tag: "cluster", Summary: "Switch maintenance mode, which answers 423 to every mutation", Request: maintenanceRequest{}, Response: MaintenanceState{}},
+	{Method: "POST", Path: "/api/{cluster}/deploy", Tag: "cluster", Summary: "Apply a signed set of connector configs from CI (X-Hub-Signature-256)", Query: []apiParam{{"dryRun", "Return the plan without applying it"}}, Request: DeployPayload{}, Response: DeployResult{}},
+	{Method: "GET", Path: "/api/{cluster}/drift", Tag: "cluster", Summary: "Compare the live connectors with the registered desired state", Response: DriftReport{}},
+	{Method: "GET", Path: "/api/{cluster}/drift/desired", Tag: "cluster", Summary: "Show the registered desired state (redacted)", Response: DesiredState{}},
+	{Method: "PUT", Path: "/api/{cluster}/drift/desired", Tag: "cluster", Summary: "Register a desired state bundle without applying it", Request: DeployPayload{}, Response: DesiredState{}},
+	{Method: "DELETE", Path: "/api/{cluster}/drift/desired", Tag: "cluster", Summary: "Remove the registered desired state"},
+
+	{Method: "GET", Path: "/api/{cluster}/workers", Tag: "cluster", Summary: "Kafka Connect workers endpoint (passthrough)"},
+	{Method: "GET", Path: "/api/{cluster}/workers/detail", Tag: "cluster", Summary: "Workers derived from connector and task placement", Response: WorkersDetail{}},
+	{Method: "GET", Path: "/api/{cluster}/topology", Tag: "cluster", Summary: "Data-flow graph of source connectors, topics and sink connectors", Response: Topology{}},
+	{Method: "GET", Path: "/api/{cluster}/search", Tag: "cluster", Summary: "Case-insensitive search across connector names, classes, topics and non-sensitive config values", Query: []apiParam{
+		{"q", "Text to search for (required)"}, {"limit", "Maximum number of hits (default 100, at most 1000)"},
+	}, Response: SearchResults{}},
+	{Method: "GET", Path: "/api/{cluster}/admin", Tag: "cluster", Summary: "Kafka Connect admin endpoints (passthrough)"},
+	{Method: "POST", Path: "/api/{cluster}/admin", Tag: "cluster", Summary: "Kafka Connect admin endpoints (passthrough)", Request: map[string]interface{}{}},
+	{Method: "GET", Path: "/api/{cluster}/admin/loggers", Tag: "cluster", Summary: "Worker log levels with pending automatic reverts", Response: LoggerList{}},
+	{Method: "PUT", Path: "/api/{cluster}/admin/loggers/{logger}", Tag: "cluster", Summary: "Set a logger's level, optionally restoring the previous one after revertAfter", Query: []apiParam{{"scope", "worker (default) or cluster (Kafka Connect 3.7+)"}}, Request: LoggerLevelRequest{}, Response: LoggerLevelChange{}},
+	{Method: "POST", Path: "/api/{cluster}/cluster/actions/{action}", Tag: "cluster", Summary: "Run a cluster-wide action: restart, rebalance, pause-all, resume-previous or cleanup-stale", Query: []apiParam{{"dryRun", "List the affected connectors without running the action"}, {"pausedDays", "Stale threshold for cleanup-stale"}}, Request: map[string]interface{}{}},
+	{Method: "GET", Path: "/api/{cluster}/cluster", Tag: "cluster", Summary: "Kafka Connect version, commit and cluster ID"},
+	{Method: "GET", Path: "/api/{cluster}/summary", Tag: "cluster", Summary: "Settings page summary: cluster info, plugins, connector counts and workers, with the sections that failed under errors", Response: SettingsSummary{}},
+	{Method: "GET", Path: "/api/{cluster}/monitoring/summary", Tag: "cluster", Summary: "Aggregated connector and task health", Query: []apiParam{{"tag", "Comma-separated tags; counts cover only connectors with any of them"}, {"include", "tasks adds each connector's task IDs, states and worker IDs"}}, Response: MonitoringSummary{}},
+	{Method: "GET", Path: "/api/{cluster}/monitoring/summary/diff", Tag: "cluster", Summary: "Connectors whose state changed since a summary snapshot", Query: []apiParam{
+		{"since", "Snapshot token from a previous call, or an RFC 3339 timestamp"}, {"tz", "IANA zone for since values without an offset"},
+	}, Response: SummaryDiff{}},
+	{Method: "GET", Path: "/api/{cluster}/monitoring/balance", Tag: "cluster", Summary: "Task distribution across workers with skew metrics and overloaded workers", Query: []apiParam{
+		{"threshold", "Factor of the mean task count above which a worker is flagged (default 1.5)"},
+	}, Response: TaskBalance{}},
+	{Method: "GET", Path: "/api/{cluster}/graphql", Tag: "cluster", Summary: "GraphQL query over connectors, tasks, plugins, metrics and the monitoring summary", Query: []apiParam{
+		{"query", "GraphQL query document"}, {"variables", "JSON object of variable values"}, {"operationName", "Operation to run when the document has several"},
+	}, Response: map[string]interface{}{}},
+	{Method: "POST", Path: "/api/{cluster}/graphql", Tag: "cluster", Summary: "GraphQL query over connectors, tasks, plugins, metrics and the monitoring summary", Request: graphqlRequest{}, Response: map[string]interface{}{}},
+	{Method: "GET", Path: "/api/{cluster}/reports/availability", Tag: "cluster", Summary: "Availability, failure incidents, MTTR and longest outage per connector", Query: []apiParam{
+		{"from", "RFC 3339 start (default: 30 days before to)"}, {"to", "RFC 3339 end (default: now)"}, {"tz", "IANA zone"}, {"format", "json or csv"},
+	}, Response: AvailabilityReport{}},
+	{Method: "GET", Path: "/api/{cluster}/connector-plugins", Tag: "plugins", Summary: "Installed connector plugins (passthrough)"},
+	{Method: "GET", Path: "/api/{cluster}/connector-plugins/catalog", Tag: "plugins", Summary: "Installed connector plugins with their config definitions", Response: []CatalogPlugin{}},
+	{Method: "GET", Path: "/api/{cluster}/plugins/drift", Tag: "plugins", Summary: "Connector plugins missing on some workers or installed with different versions", Response: PluginDriftReport{}},
+	{Method: "POST", Path: "/api/{cluster}/wizard/next-step", Tag: "plugins", Summary: "Validate a partial connector config and return the next group of settings to fill in", Request: WizardStepRequest{}, Response: WizardStep{}},
+	{Method: "PUT", Path: "/api/{cluster}/connector-plugins/{plugin}/config/validate", Tag: "plugins", Summary: "Validate a connector config; identical validations are cached for VALIDATION_CACHE_TTL", Query: []apiParam{{"cache", "false bypasses the validation cache"}}, Request: map[string]string{}},
+	{Method: "PUT", Path: "/api/{cluster}/connector-plugins/{plugin}/config/preflight", Tag: "plugins", Summary: "Validate a connector config and check its topics, ACLs and partitions in Kafka", Request: map[string]string{}, Response: PreflightResult{}},
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// openAPISchemas builds JSON schemas for Go types, collecting named structs as reusable
+// components.
+type openAPISchemas struct {
+	components map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+func (s *openAPISchemas) schemaFor(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := s.components[name]; !ok {
+			s.components[name] = map[string]interface{}{} // placeholder for recursive types
+			s.components[name] = s.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (s *openAPISchemas) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	s.addFields(t, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the JSON fields of t, flattening embedded structs as encoding/json does.
+func (s *openAPISchemas) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.addFields(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func (s *openAPISchemas) bodySchema(model interface{}) map[string]interface{} {
+	return s.schemaFor(reflect.TypeOf(model))
+}
+
+var errorSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"error":   map[string]interface{}{"type": "string", "description": "Machine-readable error code"},
+		"message": map[string]interface{}{"type": "string"},
+	},
+	"required": []string{"error", "message"},
+}
+
+// buildOpenAPISpec assembles the OpenAPI 3 document from apiOperations. Cluster routes
+// are documented under the current /api/{version} prefix.
+func buildOpenAPISpec() map[string]interface{} {
+	schemas := &openAPISchemas{components: map[string]interface{}{"Error": errorSchema}}
+	paths := make(map[string]interface{})
+
+	for _, op := range apiOperations {
+		path := op.Path
+		if strings.HasPrefix(path, "/api/{cluster}") {
+			path = "/api/" + currentAPIVersion + strings.TrimPrefix(path, "/api")
+		}
+
+		var parameters []interface{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range op.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name": param.Name, "in": "query", "description": param.Description, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success := map[string]interface{}{"description": "Success"}
+		switch {
+		case op.Response != nil:
+			success["content"] = map[string]interface{}{contentType: map[string]interface{}{"schema": schemas.bodySchema(op.Response)}}
+		case contentType != "application/json":
+			success["content"] = map[string]interface{}{contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+		default:
+			success["description"] = "Kafka Connect response, passed through with sensitive values redacted"
+			success["content"] = map[string]interface{}{contentType: map[string]interface{}{"schema": map[string]interface{}{}}}
+		}
+
+		operation := map[string]interface{}{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"responses": map[string]interface{}{
+				"200": success,
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}}},
+				},
+			},
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.bodySchema(op.Request)}},
+			}
+		}
+
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Kafka Connect Console API",
+			"version":     currentAPIVersion,
+			"description": "REST API of the kconnect-console proxy. Unversioned /api/... paths behave like /api/" + legacyAPIVersion + ".",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.components},
+	}
+}
+
+// operationID derives a stable identifier such as getClusterConnectorsNameConfig.
+func operationID(op apiOperation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, segment := range strings.FieldsFunc(pathParamPattern.ReplaceAllString(op.Path, "$1"), func(r rune) bool {
+		return r == '/' || r == '-' || r == '.'
+	}) {
+		if segment == "api" {
+			continue
+		}
+		runes := []rune(segment)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+var openAPIDocument = struct {
+	once sync.Once
+	data []byte
+}{}
+
+// openAPIHandler serves the OpenAPI document. It is built once; the route table does not
+// change at runtime.
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	openAPIDocument.once.Do(func() {
+		data, err := json.MarshalIndent(buildOpenAPISpec(), "", "  ")
+		if err != nil {
+			log.Printf("openapi: failed to encode spec: %v", err)
+			return
+		}
+		openAPIDocument.data = data
+	})
+	if openAPIDocument.data == nil {
+		writeJSONError(w, http.StatusInternalServerError, "openapi_unavailable", "the OpenAPI document could not be generated")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument.data)
+}
+
+var swaggerUIPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Kafka Connect Console API</title>
+  <link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`))
+
+// swaggerUIHandler serves a Swagger UI page for the OpenAPI document.
+func swaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := swaggerUIPage.Execute(w, struct {
+		Assets  string
+		SpecURL string
+	}{Assets: strings.TrimSuffix(swaggerUIAssets, "/"), SpecURL: "openapi.json"})
+	if err != nil {
+		log.Printf("openapi: failed to render docs page: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPIOperationsCoverRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	registerRoutes(router)
+
+	documented := make(map[string]bool, len(apiOperations))
+	for _, op := range apiOperations {
+		documented[op.Method+" "+op.Path] = true
+
+		// Every documented operation must be served, directly or by a passthrough route.
+		concrete := pathParamPattern.ReplaceAllString(op.Path, "x")
+		var match mux.RouteMatch
+		if !router.Match(httptest.NewRequest(op.Method, concrete, nil), &match) || match.MatchErr != nil {
+			t.Errorf("documented operation %s %s is not routed", op.Method, op.Path)
+		}
+	}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || strings.Contains(path, ":.*}") || strings.HasSuffix(path, "/") {
+			return nil // passthrough catch-alls are documented by their common Connect paths
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			if !documented[method+" "+path] {
+				t.Errorf("route %s %s is missing from apiOperations", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	openAPIHandler(rr, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var spec struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+				Required   []string               `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("unexpected openapi version %q", spec.OpenAPI)
+	}
+
+	op, ok := spec.Paths["/api/v1/{cluster}/connectors/expanded"]["get"]
+	if !ok || op["operationId"] != "getClusterConnectorsExpanded" {
+		t.Fatalf("expected the connectors list operation, got %v", spec.Paths["/api/v1/{cluster}/connectors/expanded"])
+	}
+	if _, ok := spec.Paths["/health"]["get"]; !ok {
+		t.Fatalf("expected unversioned health routes")
+	}
+
+	page := spec.Components.Schemas["ConnectorPage"]
+	if _, ok := page.Properties["connectors"]; !ok || len(page.Required) == 0 {
+		t.Fatalf("unexpected ConnectorPage schema: %+v", page)
+	}
+	bulk := spec.Components.Schemas["BulkMetadataRequest"]
+	if _, ok := bulk.Properties["owner"]; !ok {
+		t.Fatalf("expected embedded metadata patch fields to be flattened, got %+v", bulk.Properties)
+	}
+}
+
+func TestSwaggerUIHandler(t *testing.T) {
+	original := swaggerUIAssets
+	swaggerUIAssets = "https://artifactory.example.com/swagger-ui-dist/"
+	t.Cleanup(func() { swaggerUIAssets = original })
+
+	rr := httptest.NewRecorder()
+	swaggerUIHandler(rr, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, "https://artifactory.example.com/swagger-ui-dist/swagger-ui-bundle.js") || !strings.Contains(body, `"openapi.json"`) {
+		t.Fatalf("unexpected docs page: %s", body)
+	}
+}
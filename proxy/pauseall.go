@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const pauseSnapshotsFile = "pause-snapshots.json"
+
+const (
+	auditActionPauseAll       = "PAUSE_ALL"
+	auditActionResumePrevious = "RESUME_PREVIOUS"
+)
+
+var pauseSnapshots = newPauseSnapshotStore(time.Now)
+
+// PauseSnapshot lists the connectors the pause-all cluster action paused, so that
+// resume-previous resumes them and leaves alone the ones that were already paused.
+type PauseSnapshot struct {
+	Cluster    string    `json:"cluster"`
+	PausedAt   time.Time `json:"pausedAt"`
+	PausedBy   string    `json:"pausedBy,omitempty"`
+	Connectors []string  `json:"connectors"`
+}
+
+// ClusterPauseResult is returned by the pause-all and resume-previous cluster actions.
+// Skipped lists the connectors left alone: already paused or stopped ones for pause-all,
+// deleted ones for resume-previous. Snapshot is what remains to be resumed, unset once
+// every paused connector has been resumed.
+type ClusterPauseResult struct {
+	Action     string            `json:"action"`
+	Connectors []string          `json:"connectors"`
+	Skipped    []string          `json:"skipped"`
+	Failed     map[string]string `json:"failed"`
+	Snapshot   *PauseSnapshot    `json:"snapshot,omitempty"`
+}
+
+// pauseSnapshotStore keeps a PauseSnapshot per cluster, persisted in DATA_DIR so the
+// connectors to resume are not forgotten across a restart.
+type pauseSnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string]PauseSnapshot
+	now       func() time.Time
+}
+
+func newPauseSnapshotStore(now func() time.Time) *pauseSnapshotStore {
+	return &pauseSnapshotStore{snapshots: make(map[string]PauseSnapshot), now: now}
+}
+
+func (s *pauseSnapshotStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return loadJSON(pauseSnapshotsFile, &s.snapshots)
+}
+
+func (s *pauseSnapshotStore) get(cluster string) (PauseSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.snapshots[cluster]
+	return snapshot, ok
+}
+
+// add records names as paused by pause-all. A second pause-all before resume-previous
+// adds to the snapshot rather than replacing it: the connectors the first one paused are
+// paused by then and would otherwise be forgotten. The snapshot is kept in memory even
+// when persisting it fails.
+func (s *pauseSnapshotStore) add(cluster string, names []string, user string) (PauseSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, ok := s.snapshots[cluster]
+	if !ok {
+		if len(names) == 0 {
+			return PauseSnapshot{}, nil
+		}
+		snapshot = PauseSnapshot{Cluster: cluster, PausedAt: s.now().UTC(), PausedBy: user}
+	}
+	snapshot.Connectors = mergeNames(snapshot.Connectors, names)
+	s.snapshots[cluster] = snapshot
+	return snapshot, saveJSON(pauseSnapshotsFile, s.snapshots)
+}
+
+// remove drops names from the snapshot of cluster, and the snapshot once it is empty.
+func (s *pauseSnapshotStore) remove(cluster string, names []string) (PauseSnapshot, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, ok := s.snapshots[cluster]
+	if !ok {
+		return PauseSnapshot{}, false, nil
+	}
+	drop := make(map[string]bool, len(names))
+	for _, name := range names {
+		drop[name] = true
+	}
+	remaining := []string{}
+	for _, name := range snapshot.Connectors {
+		if !drop[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	snapshot.Connectors = remaining
+	if len(remaining) == 0 {
+		delete(s.snapshots, cluster)
+	} else {
+		s.snapshots[cluster] = snapshot
+	}
+	return snapshot, len(remaining) > 0, saveJSON(pauseSnapshotsFile, s.snapshots)
+}
+
+// mergeNames returns the sorted union of a and b.
+func mergeNames(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := []string{}
+	for _, name := range append(append([]string(nil), a...), b...) {
+		if !seen[name] {
+			seen[name] = true
+			merged = append(merged, name)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// pauseAllConnectors pauses every connector of the cluster that is not already paused
+// or stopped, and records the ones it paused for resume-previous.
+func pauseAllConnectors(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	baseURL := connectURLFor(cluster)
+	statuses, err := fetchExpandedConnectorStatuses(r.Context(), connectClientFor(cluster, routeRead), baseURL)
+	if err != nil {
+		writeConnectorsFetchError(w, err)
+		return
+	}
+
+	var targets []string
+	result := ClusterPauseResult{Action: "pause-all", Connectors: []string{}, Skipped: []string{}, Failed: map[string]string{}}
+	for name, connector := range statuses {
+		if state := normalizeState(connector.Status.Connector.State); state == "paused" || state == "stopped" {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+	sort.Strings(result.Skipped)
+
+	if isDryRun(r) {
+		writeJSON(w, http.StatusOK, DryRunResult{
+			DryRun:       true,
+			Operation:    dryRunPauseAll,
+			Description:  fmt.Sprintf("pause %d connector(s); %d already paused or stopped stay as they are", len(targets), len(result.Skipped)),
+			WouldSucceed: true,
+			Connectors:   targets,
+		})
+		return
+	}
+
+	client := connectClientFor(cluster, routeWrite)
+	for _, name := range targets {
+		if err := sendConnectRequest(r.Context(), client, http.MethodPut, joinURL(baseURL, "connectors", url.PathEscape(name), "pause"), nil); err != nil {
+			result.Failed[name] = err.Error()
+			continue
+		}
+		result.Connectors = append(result.Connectors, name)
+	}
+
+	snapshot, err := pauseSnapshots.add(cluster, result.Connectors, requestUser(r))
+	if err != nil {
+		log.Printf("pause-all %s: failed to persist the paused connectors: %v", cluster, err)
+	}
+	if len(snapshot.Connectors) > 0 {
+		result.Snapshot = &snapshot
+	}
+	writePauseResult(w, r, auditActionPauseAll, result)
+}
+
+// resumePreviousConnectors resumes the connectors recorded by pause-all. Connectors that
+// fail to resume stay in the snapshot, so the action can be retried.
+func resumePreviousConnectors(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	snapshot, ok := pauseSnapshots.get(cluster)
+	if !ok {
+		writeJSONError(w, http.StatusConflict, "no_pause_snapshot", fmt.Sprintf("no connectors of cluster %s were paused by pause-all", cluster))
+		return
+	}
+
+	if isDryRun(r) {
+		writeJSON(w, http.StatusOK, DryRunResult{
+			DryRun:       true,
+			Operation:    dryRunResumePrevious,
+			Description:  fmt.Sprintf("resume %d connector(s) paused by pause-all at %s", len(snapshot.Connectors), snapshot.PausedAt.Format(time.RFC3339)),
+			WouldSucceed: true,
+			Connectors:   snapshot.Connectors,
+		})
+		return
+	}
+
+	baseURL := connectURLFor(cluster)
+	names, err := fetchConnectorNames(r.Context(), connectClientFor(cluster, routeRead), baseURL)
+	if err != nil {
+		writeConnectorsFetchError(w, err)
+		return
+	}
+	exists := make(map[string]bool, len(names))
+	for _, name := range names {
+		exists[name] = true
+	}
+
+	result := ClusterPauseResult{Action: "resume-previous", Connectors: []string{}, Skipped: []string{}, Failed: map[string]string{}}
+	client := connectClientFor(cluster, routeWrite)
+	for _, name := range snapshot.Connectors {
+		if !exists[name] {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+		if err := sendConnectRequest(r.Context(), client, http.MethodPut, joinURL(baseURL, "connectors", url.PathEscape(name), "resume"), nil); err != nil {
+			result.Failed[name] = err.Error()
+			continue
+		}
+		result.Connectors = append(result.Connectors, name)
+	}
+
+	remaining, ok, err := pauseSnapshots.remove(cluster, append(append([]string(nil), result.Connectors...), result.Skipped...))
+	if err != nil {
+		log.Printf("resume-previous %s: failed to persist the paused connectors: %v", cluster, err)
+	}
+	if ok {
+		result.Snapshot = &remaining
+	}
+	writePauseResult(w, r, auditActionResumePrevious, result)
+}
+
+// writePauseResult audits and writes the outcome of pause-all or resume-previous,
+// answering 502 when a connector could not be paused or resumed.
+func writePauseResult(w http.ResponseWriter, r *http.Request, action string, result ClusterPauseResult) {
+	status := http.StatusOK
+	if len(result.Failed) > 0 {
+		status = http.StatusBadGateway
+	}
+	recordAudit(r, action, "", status, map[string]interface{}{
+		"connectors": result.Connectors,
+		"skipped":    result.Skipped,
+		"failed":     result.Failed,
+	})
+	writeJSON(w, status, result)
+}
+
+// writeConnectorsFetchError answers a failure to list the cluster's connectors.
+func writeConnectorsFetchError(w http.ResponseWriter, err error) {
+	var unavailable *connectUnavailableError
+	if errors.As(err, &unavailable) {
+		writeConnectUnavailable(w, err)
+		return
+	}
+	writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+}
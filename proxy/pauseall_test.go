@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPauseAllAndResumePrevious(t *testing.T) {
+	originalStore, originalDir := pauseSnapshots, dataDir
+	t.Cleanup(func() { pauseSnapshots, dataDir = originalStore, originalDir })
+	dataDir = t.TempDir()
+	pauseSnapshots = newPauseSnapshotStore(time.Now)
+	logger := withTestAuditLog(t, 10)
+
+	var mu sync.Mutex
+	states := map[string]string{"orders-sink": "RUNNING", "payments-cdc": "FAILED", "legacy": "PAUSED", "archive": "STOPPED"}
+	failResume := map[string]bool{"payments-cdc": true}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors" && r.URL.Query().Get("expand") != "":
+			expanded := map[string]interface{}{}
+			for name, state := range states {
+				expanded[name] = map[string]interface{}{"status": map[string]interface{}{"name": name, "connector": map[string]string{"state": state}}}
+			}
+			json.NewEncoder(w).Encode(expanded)
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors":
+			names := []string{}
+			for name := range states {
+				names = append(names, name)
+			}
+			json.NewEncoder(w).Encode(names)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/pause"):
+			states[strings.Split(r.URL.Path, "/")[2]] = "PAUSED"
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/resume"):
+			name := strings.Split(r.URL.Path, "/")[2]
+			if failResume[name] {
+				http.Error(w, `{"message":"worker unavailable"}`, http.StatusInternalServerError)
+				return
+			}
+			states[name] = "RUNNING"
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	run := func(action string, query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/default/cluster/actions/"+action+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "action": action})
+		rr := httptest.NewRecorder()
+		clusterActionHandler(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) ClusterPauseResult {
+		t.Helper()
+		var result ClusterPauseResult
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode: %v: %s", err, rr.Body.String())
+		}
+		return result
+	}
+
+	if rr := run("resume-previous", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 without a snapshot, got %d", rr.Code)
+	}
+
+	var dryRun DryRunResult
+	rr := run("pause-all", "?dryRun=true")
+	if err := json.Unmarshal(rr.Body.Bytes(), &dryRun); err != nil || fmt.Sprint(dryRun.Connectors) != "[orders-sink payments-cdc]" || states["orders-sink"] != "RUNNING" {
+		t.Fatalf("unexpected dry run %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = run("pause-all", "")
+	result := decode(rr)
+	if rr.Code != http.StatusOK || fmt.Sprint(result.Connectors) != "[orders-sink payments-cdc]" || fmt.Sprint(result.Skipped) != "[archive legacy]" {
+		t.Fatalf("unexpected pause-all %d: %+v", rr.Code, result)
+	}
+	if result.Snapshot == nil || len(result.Snapshot.Connectors) != 2 {
+		t.Fatalf("expected the paused connectors to be recorded, got %+v", result.Snapshot)
+	}
+
+	// A second pause-all must not forget what the first one paused.
+	if result := decode(run("pause-all", "")); len(result.Connectors) != 0 || result.Snapshot == nil || len(result.Snapshot.Connectors) != 2 {
+		t.Fatalf("unexpected second pause-all %+v", result)
+	}
+
+	reloaded := newPauseSnapshotStore(time.Now)
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if snapshot, ok := reloaded.get("default"); !ok || len(snapshot.Connectors) != 2 {
+		t.Fatalf("expected the snapshot to be persisted, got %+v", snapshot)
+	}
+
+	rr = run("resume-previous", "")
+	result = decode(rr)
+	if rr.Code != http.StatusBadGateway || fmt.Sprint(result.Connectors) != "[orders-sink]" || result.Failed["payments-cdc"] == "" {
+		t.Fatalf("unexpected resume-previous %d: %+v", rr.Code, result)
+	}
+	if states["orders-sink"] != "RUNNING" || states["legacy"] != "PAUSED" || states["archive"] != "STOPPED" {
+		t.Fatalf("expected only the connectors paused by pause-all to be resumed, got %v", states)
+	}
+	if result.Snapshot == nil || fmt.Sprint(result.Snapshot.Connectors) != "[payments-cdc]" {
+		t.Fatalf("expected the failed resume to stay recorded, got %+v", result.Snapshot)
+	}
+
+	failResume["payments-cdc"] = false
+	if rr := run("resume-previous", ""); rr.Code != http.StatusOK || decode(rr).Snapshot != nil {
+		t.Fatalf("expected the retry to clear the snapshot, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, ok := pauseSnapshots.get("default"); ok {
+		t.Fatal("expected no snapshot after every connector was resumed")
+	}
+
+	entries := logger.Query(AuditFilter{})
+	actions := map[string]int{}
+	for _, entry := range entries {
+		actions[entry.Action]++
+	}
+	if actions[auditActionPauseAll] != 2 || actions[auditActionResumePrevious] != 2 {
+		t.Fatalf("expected every pause-all and resume-previous to be audited, got %v", actions)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// StateChangeOutcome is returned by POST /api/{cluster}/connectors/{name}/pause and
+// /resume. Connect applies both asynchronously, so the handlers poll the status until
+// the connector and its tasks reach the target state. Verified is false, with a
+// Warning, when they had not once the wait ran out; the request may still take effect.
+// Failed tasks stay failed through a pause or resume and do not block verification.
+type StateChangeOutcome struct {
+	Connector string        `json:"connector"`
+	Action    string        `json:"action"`
+	Target    string        `json:"target"`
+	Before    RestartStates `json:"before"`
+	After     RestartStates `json:"after"`
+	Verified  bool          `json:"verified"`
+	Warning   string        `json:"warning,omitempty"`
+	WaitedMs  int64         `json:"waitedMs"`
+}
+
+// reached reports whether the connector and every task that is not failed are in
+// target.
+func (s RestartStates) reached(target string) bool {
+	if s.Connector.State != target {
+		return false
+	}
+	for _, task := range s.Tasks {
+		if task.State != target && task.State != "failed" {
+			return false
+		}
+	}
+	return true
+}
+
+// awaitState polls the connector status until it reaches target or the wait runs out,
+// and returns the last status seen.
+func awaitState(ctx context.Context, client *http.Client, baseURL, name, target string, wait time.Duration) (RestartStates, bool, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		status, err := fetchConnectorStatus(ctx, client, baseURL, name)
+		if err != nil {
+			return RestartStates{}, false, err
+		}
+		states := restartStates(status)
+		if states.reached(target) {
+			return states, true, nil
+		}
+		if !time.Now().Add(restartPollInterval).Before(deadline) {
+			return states, false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return states, false, nil
+		case <-time.After(restartPollInterval):
+		}
+	}
+}
+
+// connectorPauseHandler pauses a connector and waits for the pause to take effect.
+func connectorPauseHandler(w http.ResponseWriter, r *http.Request) {
+	changeConnectorState(w, r, "pause", "paused")
+}
+
+// connectorResumeHandler resumes a connector and waits for it to run again.
+func connectorResumeHandler(w http.ResponseWriter, r *http.Request) {
+	changeConnectorState(w, r, "resume", "running")
+}
+
+func changeConnectorState(w http.ResponseWriter, r *http.Request, action, target string) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+	wait, err := parseWaitParam(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_wait", err.Error())
+		return
+	}
+
+	baseURL := connectURLFor(cluster)
+	client := connectClientFor(cluster, routeWrite)
+	writeError := func(err error) {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, unavailable)
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %s not found", name))
+		default:
+			writeJSONError(w, http.StatusBadGateway, action+"_failed", err.Error())
+		}
+	}
+
+	before, err := fetchConnectorStatus(r.Context(), client, baseURL, name)
+	if err != nil {
+		writeError(err)
+		return
+	}
+	if err := sendConnectRequest(r.Context(), client, http.MethodPut, joinURL(baseURL, "connectors", url.PathEscape(name), action), nil); err != nil {
+		writeError(err)
+		return
+	}
+	if upstream, parseErr := url.Parse(baseURL); parseErr == nil {
+		configCache.invalidateConnector(r.Context(), upstream, name)
+	}
+
+	started := time.Now()
+	after, verified, err := awaitState(r.Context(), client, baseURL, name, target, wait)
+	if err != nil {
+		writeError(err)
+		return
+	}
+	outcome := StateChangeOutcome{
+		Connector: name,
+		Action:    action,
+		Target:    target,
+		Before:    restartStates(before),
+		After:     after,
+		Verified:  verified,
+		WaitedMs:  time.Since(started).Milliseconds(),
+	}
+	if !verified {
+		pending := 0
+		for _, instance := range append([]InstanceState{after.Connector}, after.Tasks...) {
+			if instance.State != target && instance.State != "failed" {
+				pending++
+			}
+		}
+		outcome.Warning = fmt.Sprintf("the %s of %s was accepted, but %d of %d instance(s) were not %s after %s; Connect applies it asynchronously, so check the status again shortly",
+			action, name, pending, len(after.Tasks)+1, target, wait)
+	}
+	writeJSON(w, http.StatusOK, outcome)
+}
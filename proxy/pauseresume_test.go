@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestConnectorPauseResumeHandlers(t *testing.T) {
+	originalInterval := restartPollInterval
+	restartPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { restartPollInterval = originalInterval })
+
+	// The connector follows a pause after two status reads; task 1 never resumes.
+	var mu sync.Mutex
+	state, reads := "RUNNING", 0
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/connectors/orders/status":
+			reads++
+			connector, task := state, state
+			if state == "PAUSED" && reads < 3 {
+				connector, task = "RUNNING", "RUNNING"
+			}
+			if state == "RESUMED" {
+				connector, task = "RUNNING", "PAUSED"
+			}
+			fmt.Fprintf(w, `{"name":"orders","connector":{"state":%q},"tasks":[{"id":0,"state":"FAILED"},{"id":1,"state":%q}]}`, connector, task)
+		case r.Method == http.MethodPut && r.URL.Path == "/connectors/orders/pause":
+			requests = append(requests, "pause")
+			state, reads = "PAUSED", 0
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && r.URL.Path == "/connectors/orders/resume":
+			requests = append(requests, "resume")
+			state = "RESUMED"
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, `{"error_code":404,"message":"Connector missing not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	do := func(action, name, query string) (*httptest.ResponseRecorder, StateChangeOutcome) {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/connectors/"+name+"/"+action+"?"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": name})
+		rr := httptest.NewRecorder()
+		if action == "pause" {
+			connectorPauseHandler(rr, req)
+		} else {
+			connectorResumeHandler(rr, req)
+		}
+		var outcome StateChangeOutcome
+		json.Unmarshal(rr.Body.Bytes(), &outcome)
+		return rr, outcome
+	}
+
+	rr, outcome := do("pause", "orders", "wait=5s")
+	if rr.Code != http.StatusOK || !outcome.Verified || outcome.Warning != "" {
+		t.Fatalf("expected a verified pause, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if outcome.Before.Connector.State != "running" || outcome.After.Connector.State != "paused" || outcome.After.Tasks[0].State != "failed" {
+		t.Fatalf("unexpected states %+v", outcome)
+	}
+
+	rr, outcome = do("resume", "orders", "wait=50ms")
+	if rr.Code != http.StatusOK || outcome.Verified || !strings.Contains(outcome.Warning, "1 of 3 instance(s) were not running") {
+		t.Fatalf("expected an unverified resume with a warning, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr, _ := do("pause", "orders", "wait=2h"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a wait above the maximum, got %d", rr.Code)
+	}
+	if rr, _ := do("pause", "missing", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing connector, got %d", rr.Code)
+	}
+	if strings.Join(requests, ",") != "pause,resume" {
+		t.Fatalf("unexpected Connect calls %v", requests)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	pluginCatalogTTL         = time.Hour
+	pluginCatalogConcurrency = 8
+)
+
+// pluginCatalog caches config definitions per Connect cluster, plugin class and version,
+// so an upgraded plugin is described again.
+var pluginCatalog = newPluginCatalogCache(pluginCatalogTTL, sharedCache)
+
+// PluginConfigDefinition describes one setting of a connector plugin, as reported by
+// Kafka Connect's config validation.
+type PluginConfigDefinition struct {
+	Name              string   `json:"name"`
+	Type              string   `json:"type"`
+	Required          bool     `json:"required"`
+	DefaultValue      *string  `json:"defaultValue"`
+	Importance        string   `json:"importance"`
+	Documentation     string   `json:"documentation,omitempty"`
+	Group             string   `json:"group,omitempty"`
+	Width             string   `json:"width,omitempty"`
+	DisplayName       string   `json:"displayName,omitempty"`
+	Dependents        []string `json:"dependents"`
+	Order             int      `json:"order"`
+	RecommendedValues []string `json:"recommendedValues,omitempty"`
+}
+
+// CatalogPlugin is an installed connector plugin with its config definitions. Error is
+// set, and Configs empty, when Connect could not describe the plugin.
+type CatalogPlugin struct {
+	Class   string                   `json:"class"`
+	Type    string                   `json:"type"`
+	Version string                   `json:"version,omitempty"`
+	Groups  []string                 `json:"groups"`
+	Configs []PluginConfigDefinition `json:"configs"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+type connectPluginInfo struct {
+	Class   string `json:"class"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+}
+
+const pluginCatalogNamespace = "plugins|"
+
+type pluginCatalogCache struct {
+	ttl   time.Duration
+	store Cache
+}
+
+func newPluginCatalogCache(ttl time.Duration, store Cache) *pluginCatalogCache {
+	return &pluginCatalogCache{ttl: ttl, store: store}
+}
+
+func pluginCatalogKey(baseURL string, plugin connectPluginInfo) string {
+	return pluginCatalogNamespace + baseURL + "|" + plugin.Class + "|" + plugin.Version
+}
+
+func (c *pluginCatalogCache) get(ctx context.Context, key string) (CatalogPlugin, bool) {
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		log.Printf("cache: failed to read %s: %v", key, err)
+	}
+	var plugin CatalogPlugin
+	if !ok || json.Unmarshal(data, &plugin) != nil {
+		return CatalogPlugin{}, false
+	}
+	return plugin, true
+}
+
+func (c *pluginCatalogCache) set(ctx context.Context, key string, plugin CatalogPlugin) {
+	data, err := json.Marshal(plugin)
+	if err == nil {
+		err = c.store.Set(ctx, key, data, c.ttl)
+	}
+	if err != nil {
+		log.Printf("cache: failed to store %s: %v", key, err)
+	}
+}
+
+func fetchConnectorPlugins(ctx context.Context, client *http.Client, baseURL string) ([]connectPluginInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(baseURL, "connector-plugins"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching connector plugins: %d", resp.StatusCode)
+	}
+
+	var plugins []connectPluginInfo
+	if err := json.NewDecoder(resp.Body).Decode(&plugins); err != nil {
+		return nil, fmt.Errorf("decode connector plugins: %w", err)
+	}
+	return plugins, nil
+}
+
+// describePlugin validates a config naming only the plugin class. Connect answers with
+// the definition of every setting, which is all the catalog needs; the validation
+// errors for missing required settings are ignored.
+func describePlugin(ctx context.Context, client *http.Client, baseURL string, plugin connectPluginInfo) (CatalogPlugin, error) {
+	described := CatalogPlugin{Class: plugin.Class, Type: plugin.Type, Version: plugin.Version, Groups: []string{}, Configs: []PluginConfigDefinition{}}
+
+	payload, err := requestConfigValidation(ctx, client, baseURL, plugin.Class, map[string]string{"connector.class": plugin.Class})
+	if err != nil {
+		return described, err
+	}
+
+	if payload.Groups != nil {
+		described.Groups = payload.Groups
+	}
+	for _, config := range payload.Configs {
+		definition := config.Definition
+		dependents := definition.Dependents
+		if dependents == nil {
+			dependents = []string{}
+		}
+		described.Configs = append(described.Configs, PluginConfigDefinition{
+			Name:              definition.Name,
+			Type:              definition.Type,
+			Required:          definition.Required,
+			DefaultValue:      definition.DefaultValue,
+			Importance:        definition.Importance,
+			Documentation:     definition.Documentation,
+			Group:             definition.Group,
+			Width:             definition.Width,
+			DisplayName:       definition.DisplayName,
+			Dependents:        dependents,
+			Order:             definition.Order,
+			RecommendedValues: config.Value.RecommendedValues,
+		})
+	}
+	return described, nil
+}
+
+// fetchPluginCatalog describes every installed connector plugin, reusing cached
+// descriptions. Plugins Connect fails to describe are returned with Error set and are
+// not cached.
+func fetchPluginCatalog(ctx context.Context, client *http.Client, baseURL string) ([]CatalogPlugin, error) {
+	plugins, err := fetchConnectorPlugins(ctx, client, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := make([]CatalogPlugin, len(plugins))
+	var (
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, pluginCatalogConcurrency)
+	)
+	for i, plugin := range plugins {
+		key := pluginCatalogKey(baseURL, plugin)
+		if cached, ok := pluginCatalog.get(ctx, key); ok {
+			catalog[i] = cached
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, plugin connectPluginInfo, key string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			described, err := describePlugin(ctx, client, baseURL, plugin)
+			if err != nil {
+				described.Error = err.Error()
+			} else {
+				pluginCatalog.set(ctx, key, described)
+			}
+			catalog[i] = described
+		}(i, plugin, key)
+	}
+	wg.Wait()
+	return catalog, nil
+}
+
+// pluginCatalogHandler returns the installed connector plugins with their config
+// definitions, so the creation wizard can render a form without validating each plugin.
+func pluginCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	catalog, err := fetchPluginCatalog(r.Context(), connectClientFor(mux.Vars(r)["cluster"], routeRead), connectURLFor(mux.Vars(r)["cluster"]))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "plugins_fetch_failed", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, catalog)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPluginCatalogHandler(t *testing.T) {
+	var mu sync.Mutex
+	validations := map[string]int{}
+	version := "1.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connector-plugins":
+			io.WriteString(w, `[{"class":"io.example.JdbcSink","type":"sink","version":"`+version+`"},{"class":"io.example.Broken","type":"source","version":"1.0"}]`)
+		case r.Method == http.MethodPut && r.URL.Path == "/connector-plugins/io.example.JdbcSink/config/validate":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["connector.class"] != "io.example.JdbcSink" {
+				t.Errorf("unexpected validate body %v", body)
+			}
+			validations["io.example.JdbcSink"]++
+			io.WriteString(w, `{"name":"io.example.JdbcSink","error_count":1,"groups":["Common","Connection"],"configs":[
+				{"definition":{"name":"connection.url","type":"STRING","required":true,"default_value":null,"importance":"HIGH","documentation":"JDBC URL.","group":"Connection","width":"LONG","display_name":"JDBC URL","dependents":["connection.user"],"order":1},
+				 "value":{"name":"connection.url","value":null,"recommended_values":[],"errors":["Missing required configuration"],"visible":true}},
+				{"definition":{"name":"insert.mode","type":"STRING","required":false,"default_value":"insert","importance":"MEDIUM","documentation":"Insert mode.","group":"Writes","width":"SHORT","display_name":"Insert Mode","dependents":[],"order":2},
+				 "value":{"name":"insert.mode","value":"insert","recommended_values":["insert","upsert","update"],"errors":[],"visible":true}}]}`)
+		case r.Method == http.MethodPut && r.URL.Path == "/connector-plugins/io.example.Broken/config/validate":
+			validations["io.example.Broken"]++
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `{"error_code":500,"message":"boom"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	original := pluginCatalog
+	pluginCatalog = newPluginCatalogCache(pluginCatalogTTL, newMemoryCache(func() time.Time { return now }))
+	t.Cleanup(func() { pluginCatalog = original })
+
+	get := func() []CatalogPlugin {
+		t.Helper()
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connector-plugins/catalog", nil), map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		pluginCatalogHandler(rr, req)
+		var catalog []CatalogPlugin
+		if err := json.Unmarshal(rr.Body.Bytes(), &catalog); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return catalog
+	}
+
+	catalog := get()
+	if len(catalog) != 2 || catalog[0].Class != "io.example.JdbcSink" || strings.Join(catalog[0].Groups, ",") != "Common,Connection" || len(catalog[0].Configs) != 2 {
+		t.Fatalf("unexpected catalog %+v", catalog)
+	}
+	url, mode := catalog[0].Configs[0], catalog[0].Configs[1]
+	if !url.Required || url.DefaultValue != nil || url.Importance != "HIGH" || url.DisplayName != "JDBC URL" || strings.Join(url.Dependents, ",") != "connection.user" {
+		t.Fatalf("unexpected definition %+v", url)
+	}
+	if mode.DefaultValue == nil || *mode.DefaultValue != "insert" || strings.Join(mode.RecommendedValues, ",") != "insert,upsert,update" {
+		t.Fatalf("unexpected definition %+v", mode)
+	}
+	if catalog[1].Error == "" || len(catalog[1].Configs) != 0 {
+		t.Fatalf("expected the broken plugin to carry an error, got %+v", catalog[1])
+	}
+
+	now = now.Add(30 * time.Minute)
+	get()
+	mu.Lock()
+	if validations["io.example.JdbcSink"] != 1 || validations["io.example.Broken"] != 2 {
+		t.Fatalf("expected described plugins to be cached and failures retried, got %v", validations)
+	}
+	version = "2.0"
+	mu.Unlock()
+
+	get()
+	now = now.Add(pluginCatalogTTL)
+	get()
+	mu.Lock()
+	defer mu.Unlock()
+	if validations["io.example.JdbcSink"] != 3 {
+		t.Fatalf("expected a new version and an expired entry to be described again, got %v", validations)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// PluginDriftWorker is one worker compared by the plugin drift report.
+type PluginDriftWorker struct {
+	URL       string `json:"url"`
+	Reachable bool   `json:"reachable"`
+	Plugins   int    `json:"plugins"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PluginDrift is a plugin class that is not installed identically on every reachable
+// worker. Versions maps worker URLs to the installed version(s); MissingOn lists the
+// workers without the plugin.
+type PluginDrift struct {
+	Class     string            `json:"class"`
+	Type      string            `json:"type"`
+	Reason    string            `json:"reason"`
+	Versions  map[string]string `json:"versions"`
+	MissingOn []string          `json:"missingOn"`
+}
+
+// PluginDriftReport is returned by GET /api/{cluster}/plugins/drift. Source is
+// "configured" when the workers come from KAFKA_CONNECT_WORKERS and "discovered" when
+// they were derived from connector and task placement.
+type PluginDriftReport struct {
+	Source     string              `json:"source"`
+	Workers    []PluginDriftWorker `json:"workers"`
+	Drift      []PluginDrift       `json:"drift"`
+	Consistent bool                `json:"consistent"`
+}
+
+// comparePluginInstalls reports the plugin classes missing on some of the given workers
+// or installed with different versions. Workers absent from installed were unreachable
+// and are left out of the comparison.
+func comparePluginInstalls(workers []string, installed map[string][]connectPluginInfo) []PluginDrift {
+	type install struct {
+		pluginType string
+		versions   map[string][]string
+	}
+	byClass := make(map[string]*install)
+	compared := 0
+	for _, worker := range workers {
+		plugins, ok := installed[worker]
+		if !ok {
+			continue
+		}
+		compared++
+		for _, plugin := range plugins {
+			entry, ok := byClass[plugin.Class]
+			if !ok {
+				entry = &install{pluginType: plugin.Type, versions: make(map[string][]string)}
+				byClass[plugin.Class] = entry
+			}
+			entry.versions[worker] = append(entry.versions[worker], plugin.Version)
+		}
+	}
+
+	drift := []PluginDrift{}
+	if compared < 2 {
+		return drift
+	}
+	for class, entry := range byClass {
+		report := PluginDrift{Class: class, Type: entry.pluginType, Versions: make(map[string]string), MissingOn: []string{}}
+		distinct := make(map[string]struct{})
+		for _, worker := range workers {
+			if _, ok := installed[worker]; !ok {
+				continue
+			}
+			versions, ok := entry.versions[worker]
+			if !ok {
+				report.MissingOn = append(report.MissingOn, worker)
+				continue
+			}
+			sort.Strings(versions)
+			joined := strings.Join(versions, ", ")
+			report.Versions[worker] = joined
+			distinct[joined] = struct{}{}
+		}
+		switch {
+		case len(report.MissingOn) > 0:
+			report.Reason = "missing"
+		case len(distinct) > 1:
+			report.Reason = "version_mismatch"
+		default:
+			continue
+		}
+		drift = append(drift, report)
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Class < drift[j].Class })
+	return drift
+}
+
+// pluginDriftWorkers returns the worker URLs of a cluster: the configured ones, or else
+// those of the workers running connectors or tasks.
+func pluginDriftWorkers(ctx context.Context, client *http.Client, cluster, baseURL string) ([]string, string, error) {
+	if urls := clusterWorkers[cluster]; len(urls) > 0 {
+		return urls, "configured", nil
+	}
+	connectors, err := fetchExpandedConnectorStatuses(ctx, client, baseURL)
+	if err != nil {
+		return nil, "", err
+	}
+	detail := aggregateWorkers(connectors)
+	urls := make([]string, 0, len(detail.Workers))
+	for _, w := range detail.Workers {
+		urls = append(urls, strings.TrimSuffix(workerURL(baseURL, w.ID), "/"))
+	}
+	return urls, "discovered", nil
+}
+
+// fetchPluginDrift asks every worker of a cluster for its connector plugins and compares
+// them.
+func fetchPluginDrift(ctx context.Context, client *http.Client, cluster, baseURL string) (PluginDriftReport, error) {
+	urls, source, err := pluginDriftWorkers(ctx, client, cluster, baseURL)
+	if err != nil {
+		return PluginDriftReport{}, err
+	}
+
+	report := PluginDriftReport{Source: source, Workers: make([]PluginDriftWorker, len(urls))}
+	installed := make(map[string][]connectPluginInfo, len(urls))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(worker *PluginDriftWorker, u string) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, workerProbeTimeout)
+			defer cancel()
+			worker.URL = u
+			plugins, err := fetchConnectorPlugins(probeCtx, client, u)
+			if err != nil {
+				worker.Error = err.Error()
+				return
+			}
+			worker.Reachable, worker.Plugins = true, len(plugins)
+			mu.Lock()
+			installed[u] = plugins
+			mu.Unlock()
+		}(&report.Workers[i], u)
+	}
+	wg.Wait()
+
+	report.Drift = comparePluginInstalls(urls, installed)
+	report.Consistent = len(report.Drift) == 0
+	return report, nil
+}
+
+// pluginDriftHandler reports connector plugins that differ between the workers of a
+// cluster, a common cause of task failures after a partial upgrade.
+func pluginDriftHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	report, err := fetchPluginDrift(r.Context(), connectClientFor(cluster, routeRead), cluster, connectURLFor(cluster))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
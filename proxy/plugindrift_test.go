@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestComparePluginInstalls(t *testing.T) {
+	jdbc := func(version string) connectPluginInfo {
+		return connectPluginInfo{Class: "io.confluent.connect.jdbc.JdbcSinkConnector", Type: "sink", Version: version}
+	}
+	mirror := connectPluginInfo{Class: "org.apache.kafka.connect.mirror.MirrorSourceConnector", Type: "source", Version: "3.7.0"}
+	s3 := connectPluginInfo{Class: "io.confluent.connect.s3.S3SinkConnector", Type: "sink", Version: "10.5.0"}
+
+	drift := comparePluginInstalls([]string{"w1", "w2", "w3"}, map[string][]connectPluginInfo{
+		"w1": {jdbc("10.7.4"), mirror, s3},
+		"w2": {jdbc("10.7.6"), mirror},
+		// w3 was unreachable
+	})
+	if len(drift) != 2 {
+		t.Fatalf("expected two drifting plugins, got %+v", drift)
+	}
+	if drift[0].Reason != "version_mismatch" || drift[0].Versions["w1"] != "10.7.4" || drift[0].Versions["w2"] != "10.7.6" || drift[0].Type != "sink" {
+		t.Fatalf("expected a JDBC version mismatch, got %+v", drift[0])
+	}
+	if drift[1].Class != s3.Class || drift[1].Reason != "missing" || len(drift[1].MissingOn) != 1 || drift[1].MissingOn[0] != "w2" {
+		t.Fatalf("expected the S3 sink to be missing on w2, got %+v", drift[1])
+	}
+
+	if drift := comparePluginInstalls([]string{"w1", "w2"}, map[string][]connectPluginInfo{"w1": {s3}}); len(drift) != 0 {
+		t.Fatalf("expected nothing to compare with one reachable worker, got %+v", drift)
+	}
+}
+
+func TestPluginDriftHandler(t *testing.T) {
+	worker := func(plugins string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/connector-plugins" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(plugins))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	w1 := worker(`[{"class":"org.apache.kafka.connect.file.FileStreamSinkConnector","type":"sink","version":"3.7.0"}]`)
+	w2 := worker(`[{"class":"org.apache.kafka.connect.file.FileStreamSinkConnector","type":"sink","version":"3.6.1"}]`)
+	w1ID, w2ID := strings.TrimPrefix(w1.URL, "http://"), strings.TrimPrefix(w2.URL, "http://")
+
+	connect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"orders": {"status": {"connector": {"state": "RUNNING", "worker_id": %q}, "tasks": [
+		  {"id": 0, "state": "RUNNING", "worker_id": %q}
+		]}}}`, w1ID, w2ID)
+	}))
+	defer connect.Close()
+	defer withTestConnectURL(t, connect)()
+
+	call := func() PluginDriftReport {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/plugins/drift", nil), map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		pluginDriftHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var report PluginDriftReport
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return report
+	}
+
+	report := call()
+	if report.Source != "discovered" || len(report.Workers) != 2 || report.Consistent || len(report.Drift) != 1 || report.Drift[0].Versions[w2.URL] != "3.6.1" {
+		t.Fatalf("expected a version mismatch between the discovered workers, got %+v", report)
+	}
+
+	original := clusterWorkers
+	clusterWorkers = map[string][]string{"default": {w1.URL, "http://127.0.0.1:1"}}
+	t.Cleanup(func() { clusterWorkers = original })
+	report = call()
+	if report.Source != "configured" || !report.Consistent || report.Workers[1].Reachable || report.Workers[1].Error == "" || report.Workers[0].Plugins != 1 {
+		t.Fatalf("expected the configured workers with one unreachable, got %+v", report)
+	}
+}
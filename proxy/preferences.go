@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const preferencesStoreFile = "user-preferences.json"
+
+const (
+	// preferencesMaxBytes caps the JSON document of one scope.
+	preferencesMaxBytes = 64 << 10
+	// preferencesMaxScopes caps the scopes one user can keep.
+	preferencesMaxScopes = 50
+)
+
+// preferenceScopePattern restricts scope names to what fits a URL segment unescaped.
+var preferenceScopePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+var userPreferences = newPreferencesStore(time.Now)
+
+// Preferences are the settings the web UI keeps for a user under one scope, such as
+// saved filters, table columns, favorite connectors or the default cluster. Values are
+// opaque to the proxy.
+type Preferences struct {
+	Scope     string                     `json:"scope"`
+	User      string                     `json:"user"`
+	Values    map[string]json.RawMessage `json:"values"`
+	UpdatedAt time.Time                  `json:"updatedAt,omitempty"`
+}
+
+// preferencesStore keeps preferences per user and scope and persists them to DATA_DIR.
+type preferencesStore struct {
+	mu    sync.RWMutex
+	now   func() time.Time
+	users map[string]map[string]Preferences // user -> scope -> preferences
+}
+
+func newPreferencesStore(now func() time.Time) *preferencesStore {
+	return &preferencesStore{now: now, users: make(map[string]map[string]Preferences)}
+}
+
+func (s *preferencesStore) load() error {
+	users := make(map[string]map[string]Preferences)
+	if err := loadJSON(preferencesStoreFile, &users); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = users
+	return nil
+}
+
+// get returns the preferences of user under scope; unknown scopes have no values.
+func (s *preferencesStore) get(user, scope string) Preferences {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefs, ok := s.users[user][scope]
+	if !ok {
+		return Preferences{Scope: scope, User: user, Values: map[string]json.RawMessage{}}
+	}
+	return prefs
+}
+
+// put replaces the preferences of user under scope and persists the store.
+func (s *preferencesStore) put(user, scope string, values map[string]json.RawMessage) (Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scopes, ok := s.users[user]
+	if !ok {
+		scopes = make(map[string]Preferences)
+		s.users[user] = scopes
+	}
+	if _, exists := scopes[scope]; !exists && len(scopes) >= preferencesMaxScopes {
+		return Preferences{}, fmt.Errorf("at most %d preference scopes can be stored per user", preferencesMaxScopes)
+	}
+
+	prefs := Preferences{Scope: scope, User: user, Values: values, UpdatedAt: s.now().UTC()}
+	scopes[scope] = prefs
+	if err := saveJSON(preferencesStoreFile, s.users); err != nil {
+		log.Printf("preferences: failed to persist preferences of %s: %v", user, err)
+		return prefs, errPreferencesNotSaved
+	}
+	return prefs, nil
+}
+
+var errPreferencesNotSaved = errors.New("failed to persist preferences")
+
+// preferencesHandler serves GET and PUT for /api/preferences/{scope}. Preferences
+// belong to the caller: the signed-in user when auth is enabled, otherwise the user
+// forwarded by an authenticating proxy, or one shared "anonymous" user. PUT replaces
+// the scope with the JSON object in the body.
+func preferencesHandler(w http.ResponseWriter, r *http.Request) {
+	scope := mux.Vars(r)["scope"]
+	if !preferenceScopePattern.MatchString(scope) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_scope", "scope must be 1-64 letters, digits, '.', '_' or '-'")
+		return
+	}
+	user := requestUser(r)
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, userPreferences.get(user, scope))
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, preferencesMaxBytes+1))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "failed to read request body")
+		return
+	}
+	if len(body) > preferencesMaxBytes {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "preferences_too_large", fmt.Sprintf("preferences of one scope are limited to %d bytes", preferencesMaxBytes))
+		return
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(body, &values); err != nil || values == nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON object")
+		return
+	}
+
+	prefs, err := userPreferences.put(user, scope, values)
+	if errors.Is(err, errPreferencesNotSaved) {
+		writeJSONError(w, http.StatusInternalServerError, "preferences_store_failed", err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPreferencesHandler(t *testing.T) {
+	withTestTrustedAuthHeaders(t)
+	originalStore, originalDir := userPreferences, dataDir
+	t.Cleanup(func() { userPreferences, dataDir = originalStore, originalDir })
+	dataDir = t.TempDir()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	userPreferences = newPreferencesStore(func() time.Time { return now })
+
+	call := func(method, scope, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/preferences/"+scope, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"scope": scope})
+		if user != "" {
+			req.Header.Set("X-Forwarded-User", user)
+		}
+		rr := httptest.NewRecorder()
+		preferencesHandler(rr, req)
+		return rr
+	}
+
+	rr := call(http.MethodPut, "connectors.table", "alice", `{"columns": ["name", "state"], "favorites": ["orders-sink"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var prefs Preferences
+	rr = call(http.MethodGet, "connectors.table", "alice", "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &prefs); err != nil || string(prefs.Values["favorites"]) != `["orders-sink"]` || !prefs.UpdatedAt.Equal(now) {
+		t.Fatalf("expected the stored preferences, got %s", rr.Body.String())
+	}
+	rr = call(http.MethodGet, "connectors.table", "bob", "")
+	prefs = Preferences{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &prefs); err != nil || prefs.User != "bob" || len(prefs.Values) != 0 {
+		t.Fatalf("expected other users to have their own preferences, got %s", rr.Body.String())
+	}
+
+	reloaded := newPreferencesStore(time.Now)
+	if err := reloaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.get("alice", "connectors.table"); len(got.Values) != 2 {
+		t.Fatalf("expected the preferences to be persisted, got %+v", got)
+	}
+
+	for _, tt := range []struct {
+		scope, body string
+		status      int
+	}{
+		{"../etc", `{}`, http.StatusBadRequest},
+		{"filters", `["not", "an", "object"]`, http.StatusBadRequest},
+		{"filters", `null`, http.StatusBadRequest},
+		{"filters", `{"saved": "` + strings.Repeat("x", preferencesMaxBytes) + `"}`, http.StatusRequestEntityTooLarge},
+	} {
+		if rr := call(http.MethodPut, tt.scope, "alice", tt.body); rr.Code != tt.status {
+			t.Errorf("%s %.20s: expected %d, got %d", tt.scope, tt.body, tt.status, rr.Code)
+		}
+	}
+
+	for i := 0; i < preferencesMaxScopes; i++ {
+		call(http.MethodPut, fmt.Sprintf("scope-%d", i), "carol", `{}`)
+	}
+	if rr := call(http.MethodPut, "one-too-many", "carol", `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected the scope limit to apply, got %d", rr.Code)
+	}
+	if rr := call(http.MethodPut, "scope-0", "carol", `{"theme": "dark"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected existing scopes to stay writable, got %d", rr.Code)
+	}
+}
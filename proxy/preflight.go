@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+const (
+	// preflightTimeout bounds the admin requests behind one pre-flight check.
+	preflightTimeout = 10 * time.Second
+
+	preflightTopicMissing         = "topic_missing"
+	preflightACLMissing           = "acl_missing"
+	preflightPartitionsBelowTasks = "partitions_below_tasks"
+)
+
+var (
+	// kafkaConnectPrincipal is the principal Connect workers use towards Kafka. It is
+	// checked when a connector does not override its client's SASL login.
+	kafkaConnectPrincipal = getEnv("KAFKA_CONNECT_PRINCIPAL", "")
+
+	preflightKafka preflightInspector = newKafkaPreflightInspector(splitList(kafkaBootstrapServers))
+
+	jaasUsernamePattern = regexp.MustCompile(`username\s*=\s*"([^"]+)"`)
+)
+
+// PreflightWarning is a problem the connector would likely run into although Kafka
+// Connect accepts its config.
+type PreflightWarning struct {
+	Check   string `json:"check"`
+	Topic   string `json:"topic,omitempty"`
+	Group   string `json:"group,omitempty"`
+	Message string `json:"message"`
+}
+
+// PreflightResult is returned by PUT /api/{cluster}/connector-plugins/{plugin}/config/preflight:
+// Connect's own validation, plus warnings from checking the config against Kafka.
+// Skipped lists the checks that could not run and why.
+type PreflightResult struct {
+	Validation *ConfigValidation  `json:"validation"`
+	Warnings   []PreflightWarning `json:"warnings"`
+	Skipped    []string           `json:"skipped,omitempty"`
+}
+
+// kafkaACL is one ACL binding as reported by DescribeACLs.
+type kafkaACL struct {
+	ResourceType kmsg.ACLResourceType
+	Resource     string
+	Pattern      kmsg.ACLResourcePatternType
+	Principal    string
+	Operation    kmsg.ACLOperation
+	Allow        bool
+}
+
+// preflightSnapshot is what Kafka reports about the topics of a connector. AutoCreate
+// is nil when the broker setting could not be read; ACLs are only meaningful when
+// Authorizer is true and ACLError is empty.
+type preflightSnapshot struct {
+	Partitions map[string]int
+	AutoCreate *bool
+	Authorizer bool
+	ACLs       []kafkaACL
+	ACLError   string
+}
+
+// preflightInspector reads what a pre-flight check needs from Kafka.
+type preflightInspector interface {
+	enabled() bool
+	inspect(ctx context.Context, topics []string) (preflightSnapshot, error)
+}
+
+// kafkaPreflightInspector answers inspections with Metadata, DescribeConfigs and
+// DescribeACLs requests. Metadata requests never auto-create the topics they ask for.
+type kafkaPreflightInspector struct {
+	brokers []string
+}
+
+func newKafkaPreflightInspector(brokers []string) *kafkaPreflightInspector {
+	return &kafkaPreflightInspector{brokers: brokers}
+}
+
+func (k *kafkaPreflightInspector) enabled() bool {
+	return len(k.brokers) > 0
+}
+
+func (k *kafkaPreflightInspector) inspect(ctx context.Context, topics []string) (preflightSnapshot, error) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(k.brokers...))
+	if err != nil {
+		return preflightSnapshot{}, err
+	}
+	defer client.Close()
+
+	snapshot := preflightSnapshot{Partitions: map[string]int{}}
+	metadata := kmsg.NewPtrMetadataRequest()
+	metadata.Topics = make([]kmsg.MetadataRequestTopic, 0, len(topics))
+	for _, topic := range topics {
+		requested := kmsg.NewMetadataRequestTopic()
+		requested.Topic = kmsg.StringPtr(topic)
+		metadata.Topics = append(metadata.Topics, requested)
+	}
+	described, err := metadata.RequestWith(ctx, client)
+	if err != nil {
+		return snapshot, fmt.Errorf("fetch topic metadata: %w", err)
+	}
+	for _, topic := range described.Topics {
+		if topic.Topic != nil && topic.ErrorCode == 0 {
+			snapshot.Partitions[*topic.Topic] = len(topic.Partitions)
+		}
+	}
+
+	if len(described.Brokers) > 0 {
+		configs := kmsg.NewPtrDescribeConfigsRequest()
+		resource := kmsg.NewDescribeConfigsRequestResource()
+		resource.ResourceType = kmsg.ConfigResourceTypeBroker
+		resource.ResourceName = strconv.Itoa(int(described.Brokers[0].NodeID))
+		resource.ConfigNames = []string{"auto.create.topics.enable"}
+		configs.Resources = append(configs.Resources, resource)
+		if resp, err := configs.RequestWith(ctx, client); err == nil {
+			for _, resource := range resp.Resources {
+				for _, config := range resource.Configs {
+					if resource.ErrorCode == 0 && config.Name == "auto.create.topics.enable" && config.Value != nil {
+						enabled := *config.Value == "true"
+						snapshot.AutoCreate = &enabled
+					}
+				}
+			}
+		}
+	}
+
+	acls := kmsg.NewPtrDescribeACLsRequest()
+	acls.ResourceType = kmsg.ACLResourceTypeAny
+	acls.ResourcePatternType = kmsg.ACLResourcePatternTypeAny
+	acls.Operation = kmsg.ACLOperationAny
+	acls.PermissionType = kmsg.ACLPermissionTypeAny
+	resp, err := acls.RequestWith(ctx, client)
+	if err == nil {
+		err = kerr.ErrorForCode(resp.ErrorCode)
+	}
+	switch {
+	case errors.Is(err, kerr.SecurityDisabled):
+	case err != nil:
+		snapshot.Authorizer = true
+		snapshot.ACLError = err.Error()
+	default:
+		snapshot.Authorizer = true
+		for _, resource := range resp.Resources {
+			for _, acl := range resource.ACLs {
+				snapshot.ACLs = append(snapshot.ACLs, kafkaACL{
+					ResourceType: resource.ResourceType,
+					Resource:     resource.ResourceName,
+					Pattern:      resource.ResourcePatternType,
+					Principal:    acl.Principal,
+					Operation:    acl.Operation,
+					Allow:        acl.PermissionType == kmsg.ACLPermissionTypeAllow,
+				})
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// aclAllows reports whether principal may perform operation on the named resource: an
+// ALLOW binding must match and no DENY binding may. Hosts are not considered.
+func aclAllows(acls []kafkaACL, principal string, resourceType kmsg.ACLResourceType, name string, operation kmsg.ACLOperation) bool {
+	allowed := false
+	for _, acl := range acls {
+		if acl.ResourceType != resourceType || (acl.Principal != principal && acl.Principal != "User:*") {
+			continue
+		}
+		if acl.Operation != operation && acl.Operation != kmsg.ACLOperationAll {
+			continue
+		}
+		matches := acl.Resource == name || acl.Resource == "*"
+		if acl.Pattern == kmsg.ACLResourcePatternTypePrefixed {
+			matches = strings.HasPrefix(name, acl.Resource)
+		}
+		if !matches {
+			continue
+		}
+		if !acl.Allow {
+			return false
+		}
+		allowed = true
+	}
+	return allowed
+}
+
+// preflightPrincipal returns the principal the connector's Kafka client logs in as:
+// the SASL user of its client override, or KAFKA_CONNECT_PRINCIPAL.
+func preflightPrincipal(config map[string]string, sink bool) string {
+	key := "producer.override.sasl.jaas.config"
+	if sink {
+		key = "consumer.override.sasl.jaas.config"
+	}
+	if match := jaasUsernamePattern.FindStringSubmatch(config[key]); match != nil {
+		return "User:" + match[1]
+	}
+	return strings.TrimSpace(kafkaConnectPrincipal)
+}
+
+// preflightTopics returns the topics a connector reads from or writes to, as far as
+// its config names them: topics for a sink, topic or kafka.topic for a source.
+func preflightTopics(config map[string]string, sink bool) []string {
+	keys := []string{"topic", "kafka.topic"}
+	if sink {
+		keys = []string{"topics"}
+	}
+	var topics []string
+	for _, key := range keys {
+		for _, topic := range splitList(config[key]) {
+			if !containsString(topics, topic) {
+				topics = append(topics, topic)
+			}
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// isSinkConfig reports whether config belongs to a sink connector: only sinks have a
+// topics or topics.regex setting.
+func isSinkConfig(config map[string]string) bool {
+	return config["topics"] != "" || config["topics.regex"] != ""
+}
+
+// checkPreflight compares the connector config with what Kafka reports.
+func checkPreflight(name string, config map[string]string, snapshot preflightSnapshot) ([]PreflightWarning, []string) {
+	sink := isSinkConfig(config)
+	topics := preflightTopics(config, sink)
+	warnings := make([]PreflightWarning, 0)
+	var skipped []string
+	if sink && config["topics.regex"] != "" {
+		skipped = append(skipped, "topics matched by topics.regex are not checked")
+	}
+
+	// Connect creates the topics of a source connector that has topic creation settings.
+	sourceCreates := !sink && config["topic.creation.default.replication.factor"] != "" && config["topic.creation.default.partitions"] != ""
+	partitions, missing := 0, 0
+	for _, topic := range topics {
+		count, exists := snapshot.Partitions[topic]
+		partitions += count
+		if !exists {
+			missing++
+		}
+		if exists || sourceCreates {
+			continue
+		}
+		message := fmt.Sprintf("topic %s does not exist", topic)
+		switch {
+		case snapshot.AutoCreate == nil:
+		case *snapshot.AutoCreate:
+			message += "; the broker will auto-create it with its default partitions and replication factor"
+		default:
+			message += " and the broker does not auto-create topics"
+		}
+		warnings = append(warnings, PreflightWarning{Check: preflightTopicMissing, Topic: topic, Message: message})
+	}
+
+	if sink && len(topics) > 0 && missing == 0 {
+		tasks := 1
+		if n, err := strconv.Atoi(strings.TrimSpace(config["tasks.max"])); err == nil && n > 0 {
+			tasks = n
+		}
+		if partitions < tasks {
+			warnings = append(warnings, PreflightWarning{
+				Check:   preflightPartitionsBelowTasks,
+				Message: fmt.Sprintf("tasks.max is %d but the topics have %d partition(s) in total, so %d task(s) would stay idle", tasks, partitions, tasks-partitions),
+			})
+		}
+	}
+
+	principal := preflightPrincipal(config, sink)
+	switch {
+	case !snapshot.Authorizer:
+		skipped = append(skipped, "Kafka has no authorizer, so ACLs are not enforced")
+	case snapshot.ACLError != "":
+		skipped = append(skipped, "ACLs could not be read: "+snapshot.ACLError)
+	case principal == "":
+		skipped = append(skipped, "ACLs are not checked: set KAFKA_CONNECT_PRINCIPAL or a sasl.jaas.config client override")
+	default:
+		operation, verb := kmsg.ACLOperationWrite, "WRITE"
+		if sink {
+			operation, verb = kmsg.ACLOperationRead, "READ"
+		}
+		for _, topic := range topics {
+			if !aclAllows(snapshot.ACLs, principal, kmsg.ACLResourceTypeTopic, topic, operation) {
+				warnings = append(warnings, PreflightWarning{
+					Check:   preflightACLMissing,
+					Topic:   topic,
+					Message: fmt.Sprintf("%s has no ACL allowing %s on topic %s", principal, verb, topic),
+				})
+			}
+		}
+		group, source := sinkConsumerGroup(name, config)
+		switch {
+		case !sink:
+		case name == "" && source == "default":
+			skipped = append(skipped, "the consumer group ACL is not checked: the config has no name")
+		case !aclAllows(snapshot.ACLs, principal, kmsg.ACLResourceTypeGroup, group, kmsg.ACLOperationRead):
+			warnings = append(warnings, PreflightWarning{
+				Check:   preflightACLMissing,
+				Group:   group,
+				Message: fmt.Sprintf("%s has no ACL allowing READ on consumer group %s", principal, group),
+			})
+		}
+	}
+	return warnings, skipped
+}
+
+// connectorPreflightHandler validates a connector config like Connect's
+// /config/validate and adds warnings about its topics, ACLs and partitions, so
+// problems that Connect only reports once tasks run show up before the connector is
+// created or updated.
+func connectorPreflightHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, plugin := vars["cluster"], vars["plugin"]
+
+	var candidate map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&candidate); err != nil || candidate == nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a connector config object with string values")
+		return
+	}
+
+	// Placeholders are resolved so Connect validates, and Kafka is checked against,
+	// the values the connector would receive.
+	resolved := make(map[string]interface{}, len(candidate))
+	for key, value := range candidate {
+		resolved[key] = value
+	}
+	if _, err := connectorSecrets.resolveConfig(r.Context(), resolved); err != nil {
+		writeSecretResolutionError(w, err)
+		return
+	}
+	validation, err := validateConnectorConfig(r.Context(), connectClientFor(cluster, routeValidate), connectURLFor(cluster), plugin, resolved)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		var refused *configValidationError
+		switch {
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		case errors.As(err, &refused) && refused.Status < 500:
+			writeJSONError(w, http.StatusBadRequest, "invalid_plugin", err.Error())
+		default:
+			writeJSONError(w, http.StatusBadGateway, "validation_failed", err.Error())
+		}
+		return
+	}
+
+	result := PreflightResult{Validation: validation, Warnings: make([]PreflightWarning, 0)}
+	if !preflightKafka.enabled() {
+		result.Skipped = []string{"Kafka checks need KAFKA_BOOTSTRAP_SERVERS"}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	config := make(map[string]string, len(resolved))
+	for key, value := range resolved {
+		config[key] = configString(value)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), preflightTimeout)
+	defer cancel()
+	snapshot, err := preflightKafka.inspect(ctx, preflightTopics(config, isSinkConfig(config)))
+	if err != nil {
+		result.Skipped = []string{"Kafka could not be reached: " + err.Error()}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	result.Warnings, result.Skipped = checkPreflight(config["name"], config, snapshot)
+	writeJSON(w, http.StatusOK, result)
+}
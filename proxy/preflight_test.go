@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+type fakePreflightInspector struct {
+	snapshot preflightSnapshot
+	topics   []string
+}
+
+func (f *fakePreflightInspector) enabled() bool { return true }
+
+func (f *fakePreflightInspector) inspect(ctx context.Context, topics []string) (preflightSnapshot, error) {
+	f.topics = topics
+	return f.snapshot, nil
+}
+
+func TestCheckPreflight(t *testing.T) {
+	disabled := false
+	acls := []kafkaACL{
+		{ResourceType: kmsg.ACLResourceTypeTopic, Resource: "orders", Pattern: kmsg.ACLResourcePatternTypeLiteral, Principal: "User:connect", Operation: kmsg.ACLOperationRead, Allow: true},
+		{ResourceType: kmsg.ACLResourceTypeTopic, Resource: "cdc.", Pattern: kmsg.ACLResourcePatternTypePrefixed, Principal: "User:*", Operation: kmsg.ACLOperationAll, Allow: true},
+		{ResourceType: kmsg.ACLResourceTypeTopic, Resource: "cdc.secret", Pattern: kmsg.ACLResourcePatternTypeLiteral, Principal: "User:connect", Operation: kmsg.ACLOperationWrite, Allow: false},
+		{ResourceType: kmsg.ACLResourceTypeGroup, Resource: "connect-", Pattern: kmsg.ACLResourcePatternTypePrefixed, Principal: "User:connect", Operation: kmsg.ACLOperationRead, Allow: true},
+	}
+	snapshot := preflightSnapshot{
+		Partitions: map[string]int{"orders": 3, "cdc.users": 1, "cdc.secret": 1},
+		AutoCreate: &disabled,
+		Authorizer: true,
+		ACLs:       acls,
+	}
+	original := kafkaConnectPrincipal
+	kafkaConnectPrincipal = "User:connect"
+	t.Cleanup(func() { kafkaConnectPrincipal = original })
+
+	tests := []struct {
+		name     string
+		config   map[string]string
+		snapshot preflightSnapshot
+		want     string
+	}{
+		{"readable sink", map[string]string{"topics": "orders", "tasks.max": "3"}, snapshot, ""},
+		{"sink with idle tasks", map[string]string{"topics": "orders,cdc.users", "tasks.max": "6"}, snapshot, "partitions_below_tasks"},
+		{"sink without the topic", map[string]string{"topics": "clicks"}, snapshot, "topic_missing:clicks,acl_missing:clicks"},
+		{"sink with its own login", map[string]string{"topics": "orders", "consumer.override.sasl.jaas.config": `org.apache.kafka.common.security.plain.PlainLoginModule required username="orders-app" password="x";`}, snapshot, "acl_missing:orders,acl_missing:connect-orders-sink"},
+		{"source with a prefixed ACL", map[string]string{"topic": "cdc.users"}, snapshot, ""},
+		{"source denied", map[string]string{"kafka.topic": "cdc.secret"}, snapshot, "acl_missing:cdc.secret"},
+		{"source creating its topic", map[string]string{"topic": "cdc.new", "topic.creation.default.replication.factor": "3", "topic.creation.default.partitions": "6"}, snapshot, ""},
+		{"no authorizer", map[string]string{"topics": "clicks"}, preflightSnapshot{Partitions: map[string]int{}}, "topic_missing:clicks"},
+	}
+	for _, tt := range tests {
+		warnings, _ := checkPreflight("orders-sink", tt.config, tt.snapshot)
+		var got []string
+		for _, warning := range warnings {
+			got = append(got, strings.TrimSuffix(warning.Check+":"+warning.Topic+warning.Group, ":"))
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.want, warnings)
+		}
+	}
+}
+
+func TestConnectorPreflightHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/connector-plugins/FileStreamSink/config/validate" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error_code":404,"message":"Failed to find any class that implements Connector"}`)
+			return
+		}
+		io.WriteString(w, `{"name":"FileStreamSink","error_count":1,"groups":[],"configs":[{"definition":{"name":"file"},"value":{"name":"file","value":null,"errors":["Missing required configuration \"file\""]}}]}`)
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	original := preflightKafka
+	t.Cleanup(func() { preflightKafka = original })
+	inspector := &fakePreflightInspector{snapshot: preflightSnapshot{Partitions: map[string]int{"orders": 1}}}
+	preflightKafka = inspector
+
+	preflight := func(plugin, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/default/connector-plugins/"+plugin+"/config/preflight", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "plugin": plugin})
+		rr := httptest.NewRecorder()
+		connectorPreflightHandler(rr, req)
+		return rr
+	}
+
+	rr := preflight("FileStreamSink", `{"name": "orders-sink", "topics": "orders, clicks", "tasks.max": "2"}`)
+	var result PreflightResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if result.Validation == nil || result.Validation.ErrorCount != 1 || len(result.Validation.Errors["file"]) != 1 {
+		t.Fatalf("expected Connect's validation, got %+v", result.Validation)
+	}
+	if strings.Join(inspector.topics, ",") != "clicks,orders" {
+		t.Fatalf("expected the sink topics to be inspected, got %v", inspector.topics)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Check != preflightTopicMissing || result.Warnings[0].Topic != "clicks" {
+		t.Fatalf("unexpected warnings %+v", result.Warnings)
+	}
+	if !strings.Contains(strings.Join(result.Skipped, ";"), "no authorizer") {
+		t.Fatalf("expected the ACL check to be skipped, got %v", result.Skipped)
+	}
+
+	if rr := preflight("Missing", `{"topics": "orders"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown plugin, got %d", rr.Code)
+	}
+	if rr := preflight("FileStreamSink", `["orders"]`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a body that is not a config, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// proxyResources lists the Kafka Connect paths the generic proxy forwards, by first
+// segment. In a pattern "*" matches any single name and "#" a task ID; other segments
+// must match literally. Anything else is rejected before it reaches Kafka Connect.
+var proxyResources = map[string][][]string{
+	"connectors": {
+		{},
+		{"*"},
+		{"*", "config"},
+		{"*", "status"},
+		{"*", "restart"},
+		{"*", "pause"},
+		{"*", "resume"},
+		{"*", "stop"},
+		{"*", "tasks"},
+		{"*", "tasks-config"},
+		{"*", "tasks", "#", "status"},
+		{"*", "tasks", "#", "restart"},
+		{"*", "topics"},
+		{"*", "topics", "reset"},
+		{"*", "offsets"},
+	},
+	"connector-plugins": {
+		{},
+		{"*"},
+		{"*", "config"},
+		{"*", "config", "validate"},
+	},
+	"workers": {
+		{},
+		{"*"},
+	},
+	"admin": {
+		{},
+		{"loggers"},
+		{"loggers", "*"},
+		{"rebalance"},
+	},
+}
+
+// proxyPathError reports a request path the proxy refuses to forward.
+type proxyPathError struct {
+	path   string
+	reason string
+}
+
+func (e *proxyPathError) Error() string {
+	return fmt.Sprintf("invalid path %q: %s", e.path, e.reason)
+}
+
+// parseProxyPath splits an escaped /api/{cluster}/... path into its cluster and the
+// decoded segments of the Kafka Connect resource. Each segment is decoded on its own, so
+// an encoded slash stays inside the segment, where it is rejected along with dot
+// segments and control characters; the resource must match proxyResources. A single
+// trailing slash is ignored.
+func parseProxyPath(escaped string) (string, []string, error) {
+	invalid := func(reason string) (string, []string, error) {
+		return "", nil, &proxyPathError{path: escaped, reason: reason}
+	}
+
+	raw := strings.Split(strings.TrimSuffix(strings.TrimPrefix(escaped, "/"), "/"), "/")
+	if len(raw) < 3 || raw[0] != "api" {
+		return invalid("expected /api/{cluster}/{resource}")
+	}
+
+	segments := make([]string, 0, len(raw)-1)
+	for _, part := range raw[1:] {
+		segment, err := url.PathUnescape(part)
+		if err != nil {
+			return invalid("malformed escape sequence")
+		}
+		switch {
+		case segment == "":
+			return invalid("empty path segment")
+		case segment == "." || segment == "..":
+			return invalid("dot segments are not allowed")
+		case strings.ContainsAny(segment, `/\`):
+			return invalid("path segments may not contain slashes")
+		case strings.IndexFunc(segment, func(c rune) bool { return c < 0x20 || c == 0x7f }) >= 0:
+			return invalid("path segments may not contain control characters")
+		}
+		segments = append(segments, segment)
+	}
+
+	cluster, resource := segments[0], segments[1:]
+	patterns, ok := proxyResources[resource[0]]
+	if !ok {
+		return invalid(fmt.Sprintf("%s is not a proxied Kafka Connect resource", resource[0]))
+	}
+	for _, pattern := range patterns {
+		if matchProxyPattern(pattern, resource[1:]) {
+			return cluster, resource, nil
+		}
+	}
+	return invalid(fmt.Sprintf("unsupported %s sub-resource", resource[0]))
+}
+
+func matchProxyPattern(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, want := range pattern {
+		switch want {
+		case "*":
+		case "#":
+			if id, err := strconv.Atoi(segments[i]); err != nil || id < 0 || strconv.Itoa(id) != segments[i] {
+				return false
+			}
+		default:
+			if segments[i] != want {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuildProxyURLValidatesPath(t *testing.T) {
+	withTestClusterURLs(t, map[string]string{"dr": "http://connect-dr:8083/base"})
+
+	tests := []struct {
+		path   string
+		target string // empty when the path must be rejected
+	}{
+		{"/api/dr/connectors", "http://connect-dr:8083/base/connectors"},
+		{"/api/dr/connectors/", "http://connect-dr:8083/base/connectors"},
+		{"/api/dr/connectors/orders%20sink/config", "http://connect-dr:8083/base/connectors/orders%20sink/config"},
+		{"/api/dr/connectors/alpha/tasks/3/restart", "http://connect-dr:8083/base/connectors/alpha/tasks/3/restart"},
+		{"/api/dr/connectors/alpha/topics/reset", "http://connect-dr:8083/base/connectors/alpha/topics/reset"},
+		{"/api/dr/connector-plugins/FileStreamSink/config/validate", "http://connect-dr:8083/base/connector-plugins/FileStreamSink/config/validate"},
+		{"/api/dr/admin/loggers/org.apache.kafka", "http://connect-dr:8083/base/admin/loggers/org.apache.kafka"},
+		// A double-encoded dot segment decodes once to a literal name and is forwarded encoded.
+		{"/api/dr/connectors/%252e%252e", "http://connect-dr:8083/base/connectors/%252e%252e"},
+
+		{"/api/dr/connectors/../admin/loggers", ""},
+		{"/api/dr/connectors/%2e%2e/admin/loggers", ""},
+		{"/api/dr/connectors/%2E%2E/admin/loggers", ""},
+		{"/api/dr/connectors/alpha/%2e%2e/%2e%2e/admin/loggers", ""},
+		{"/api/dr/connectors/..%2fadmin%2floggers", ""},
+		{"/api/dr/connectors/..%5cadmin", ""},
+		{"/api/dr/connectors/alpha%2Fstatus", ""},
+		{"/api/dr/connectors/./alpha", ""},
+		{"/api/dr/connectors/alpha%00/status", ""},
+		{"/api/dr/connectors//alpha", ""},
+		{"/api/dr/connectors/alpha/secrets", ""},
+		{"/api/dr/connectors/alpha/tasks/x/restart", ""},
+		{"/api/dr/connectors/alpha/tasks/-1/status", ""},
+		{"/api/dr/connectors/alpha/config/extra", ""},
+		{"/api/dr/workers/w1/connectors", ""},
+		{"/api/dr/admin/loggers/a/b", ""},
+		{"/api/dr/kafka/topics", ""},
+		{"/api/dr", ""},
+	}
+
+	for _, tt := range tests {
+		target, err := buildProxyURL(httptest.NewRequest(http.MethodGet, tt.path, nil))
+		switch {
+		case tt.target == "" && err == nil:
+			t.Errorf("%s: expected the path to be rejected, got %s", tt.path, target)
+		case tt.target != "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.path, err)
+		case tt.target != "" && target.String() != tt.target:
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.target, target)
+		}
+	}
+}
+
+func TestProxyHandlerRejectsTraversal(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected upstream request %s %s", r.Method, r.URL)
+	}))
+	defer upstream.Close()
+	defer withTestConnectURL(t, upstream)()
+
+	rr := httptest.NewRecorder()
+	proxyHandler(rr, httptest.NewRequest(http.MethodPut, "/api/default/connectors/%2e%2e/admin/loggers/root", strings.NewReader(`{"level":"TRACE"}`)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "invalid_path") {
+		t.Fatalf("expected 400 invalid_path, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	rateLimitRPS   = getEnv("RATE_LIMIT_RPS", "0")
+	rateLimitBurst = getEnv("RATE_LIMIT_BURST", "")
+	// trustProxyHeaders makes extractClientIP honour X-Forwarded-For / X-Real-IP. Only
+	// enable it when the proxy sits behind a load balancer that sets these headers.
+	trustProxyHeaders = getEnv("TRUST_PROXY_HEADERS", "false") == "true"
+)
+
+// offenderLogInterval controls how often repeated rejections for the same client are
+// logged after the first one.
+const offenderLogInterval = 100
+
+// extractClientIP returns the IP address of the client that issued r. With
+// TRUST_PROXY_HEADERS it is the rightmost X-Forwarded-For entry, which the trusted load
+// balancer appended; the entries before it come from the client and can be forged.
+func extractClientIP(r *http.Request) string {
+	if trustProxyHeaders {
+		entries := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entry := strings.TrimSpace(entries[i]); entry != "" {
+				return entry
+			}
+		}
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+	rejections int
+}
+
+// rateLimiter is a per-key token bucket limiter.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+func newRateLimiter(rate float64, burst int, now func() time.Time) *rateLimiter {
+	if burst < 1 {
+		burst = int(math.Ceil(rate))
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     now,
+	}
+}
+
+// newRateLimiterFromEnv returns nil when RATE_LIMIT_RPS is zero, disabling the limiter.
+func newRateLimiterFromEnv() (*rateLimiter, error) {
+	rate, err := strconv.ParseFloat(rateLimitRPS, 64)
+	if err != nil || rate < 0 {
+		return nil, &configError{name: "RATE_LIMIT_RPS", value: rateLimitRPS}
+	}
+	if rate == 0 {
+		return nil, nil
+	}
+
+	burst := 0
+	if rateLimitBurst != "" {
+		burst, err = strconv.Atoi(rateLimitBurst)
+		if err != nil || burst < 0 {
+			return nil, &configError{name: "RATE_LIMIT_BURST", value: rateLimitBurst}
+		}
+	}
+	return newRateLimiter(rate, burst, time.Now), nil
+}
+
+// allow consumes a token for key. When the bucket is empty it returns false together
+// with the time until the next token becomes available and the number of consecutive
+// rejections for key.
+func (l *rateLimiter) allow(key string) (bool, time.Duration, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, lastRefill: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.lastRefill).Seconds()
+	bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+	bucket.lastRefill = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.rejections = 0
+		return true, 0, 0
+	}
+
+	bucket.rejections++
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait, bucket.rejections
+}
+
+// prune drops buckets that have been idle long enough to be full again.
+func (l *rateLimiter) prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	idle := time.Duration(l.burst/l.rate*float64(time.Second)) + time.Minute
+	now := l.now()
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastRefill) > idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *rateLimiter) runPruner(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.prune()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// middleware rejects requests above the configured rate with 429 Too Many Requests.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/health/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		clientIP := extractClientIP(r)
+		allowed, wait, rejections := l.allow(clientIP)
+		if allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rejections == 1 || rejections%offenderLogInterval == 0 {
+			log.Printf("rate_limit: event=rejected client_ip=%s method=%s path=%s consecutive_rejections=%d", clientIP, r.Method, r.URL.Path, rejections)
+		}
+
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeJSONError(w, http.StatusTooManyRequests, "rate_limited", "too many requests, retry after "+strconv.Itoa(retryAfter)+"s")
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtractClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors", nil)
+	req.RemoteAddr = "10.0.0.5:51234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+
+	if got := extractClientIP(req); got != "10.0.0.5" {
+		t.Fatalf("expected remote address when proxy headers are untrusted, got %q", got)
+	}
+
+	original := trustProxyHeaders
+	trustProxyHeaders = true
+	t.Cleanup(func() { trustProxyHeaders = original })
+
+	if got := extractClientIP(req); got != "10.0.0.1" {
+		t.Fatalf("expected the X-Forwarded-For entry the load balancer appended, got %q", got)
+	}
+	// Clients can prepend any address, which must not change the key they are limited by.
+	req.Header.Set("X-Forwarded-For", "192.0.2.99, 203.0.113.7, 10.0.0.1, ")
+	if got := extractClientIP(req); got != "10.0.0.1" {
+		t.Fatalf("expected forged entries to be ignored, got %q", got)
+	}
+
+	req.Header.Del("X-Forwarded-For")
+	req.Header.Set("X-Real-IP", "198.51.100.2")
+	if got := extractClientIP(req); got != "198.51.100.2" {
+		t.Fatalf("expected X-Real-IP, got %q", got)
+	}
+}
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(2, 2, func() time.Time { return now })
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := limiter.allow("a"); !ok {
+			t.Fatalf("expected request %d within burst to be allowed", i)
+		}
+	}
+
+	ok, wait, rejections := limiter.allow("a")
+	if ok || rejections != 1 {
+		t.Fatalf("expected third request to be rejected, got ok=%v rejections=%d", ok, rejections)
+	}
+	if wait != 500*time.Millisecond {
+		t.Fatalf("expected 500ms until next token, got %v", wait)
+	}
+
+	if ok, _, _ := limiter.allow("b"); !ok {
+		t.Fatalf("expected other clients to have their own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _, _ := limiter.allow("a"); !ok {
+		t.Fatalf("expected token to be refilled")
+	}
+
+	now = now.Add(time.Hour)
+	limiter.prune()
+	if len(limiter.buckets) != 0 {
+		t.Fatalf("expected idle buckets to be pruned, got %d", len(limiter.buckets))
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(1, 1, func() time.Time { return now })
+	handler := limiter.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	newReq := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		return req
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newReq("/api/default/connectors"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newReq("/api/default/connectors"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected Retry-After of 1s, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newReq("/health"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected health checks to bypass the limiter, got %d", rr.Code)
+	}
+}
+
+func TestNewRateLimiterFromEnv(t *testing.T) {
+	originalRPS, originalBurst := rateLimitRPS, rateLimitBurst
+	t.Cleanup(func() { rateLimitRPS, rateLimitBurst = originalRPS, originalBurst })
+
+	rateLimitRPS, rateLimitBurst = "0", ""
+	if limiter, err := newRateLimiterFromEnv(); err != nil || limiter != nil {
+		t.Fatalf("expected limiter to be disabled, got %v, %v", limiter, err)
+	}
+
+	rateLimitRPS, rateLimitBurst = "5", "20"
+	limiter, err := newRateLimiterFromEnv()
+	if err != nil || limiter == nil || limiter.burst != 20 {
+		t.Fatalf("expected configured limiter, got %+v, %v", limiter, err)
+	}
+
+	rateLimitRPS = "fast"
+	if _, err := newRateLimiterFromEnv(); err == nil {
+		t.Fatalf("expected error for invalid RATE_LIMIT_RPS")
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultRedactionPlaceholder = "***REDACTED***"
+
+// redactionConfigPath points at an optional JSON or YAML file extending the built-in
+// redaction rules. It is re-read whenever the process receives SIGHUP.
+var redactionConfigPath = getEnv("REDACTION_CONFIG", "")
+
+// RedactionConfig is the on-disk format of REDACTION_CONFIG.
+type RedactionConfig struct {
+	// SensitivePatterns are additional case-insensitive regular expressions matched
+	// against config keys, e.g. "(^|\\.)pwd$" for proprietary names like sf.pwd.
+	SensitivePatterns []string `json:"sensitivePatterns" yaml:"sensitivePatterns"`
+	// SafeKeys are exact key names that must never be redacted.
+	SafeKeys []string `json:"safeKeys" yaml:"safeKeys"`
+	// Placeholder replaces redacted values. Defaults to ***REDACTED***.
+	Placeholder string `json:"placeholder" yaml:"placeholder"`
+}
+
+type redactionRules struct {
+	patterns    []*regexp.Regexp
+	safeKeys    map[string]struct{}
+	placeholder string
+}
+
+var activeRedaction = struct {
+	sync.RWMutex
+	rules redactionRules
+}{rules: defaultRedactionRules()}
+
+func defaultRedactionRules() redactionRules {
+	safe := make(map[string]struct{}, len(safeExactKeys))
+	for key := range safeExactKeys {
+		safe[key] = struct{}{}
+	}
+	return redactionRules{
+		patterns:    []*regexp.Regexp{sensitivePattern},
+		safeKeys:    safe,
+		placeholder: defaultRedactionPlaceholder,
+	}
+}
+
+// buildRedactionRules layers cfg on top of the built-in rules.
+func buildRedactionRules(cfg RedactionConfig) (redactionRules, error) {
+	rules := defaultRedactionRules()
+
+	for _, pattern := range cfg.SensitivePatterns {
+		compiled, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return redactionRules{}, fmt.Errorf("invalid sensitive pattern %q: %w", pattern, err)
+		}
+		rules.patterns = append(rules.patterns, compiled)
+	}
+	for _, key := range cfg.SafeKeys {
+		if trimmed := strings.ToLower(strings.TrimSpace(key)); trimmed != "" {
+			rules.safeKeys[trimmed] = struct{}{}
+		}
+	}
+	if cfg.Placeholder != "" {
+		rules.placeholder = cfg.Placeholder
+	}
+
+	return rules, nil
+}
+
+func currentRedactionRules() redactionRules {
+	activeRedaction.RLock()
+	defer activeRedaction.RUnlock()
+	return activeRedaction.rules
+}
+
+func (r redactionRules) isSensitive(key string) bool {
+	lk := strings.ToLower(key)
+	if _, ok := r.safeKeys[lk]; ok {
+		return false
+	}
+	for _, pattern := range r.patterns {
+		if pattern.MatchString(lk) {
+			return true
+		}
+	}
+	return false
+}
+
+// readRedactionConfig parses path as YAML when it has a .yaml/.yml extension and as
+// JSON otherwise.
+func readRedactionConfig(path string) (RedactionConfig, error) {
+	var cfg RedactionConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read redaction config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	default:
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("decode redaction config: %w", err)
+	}
+	return cfg, nil
+}
+
+// reloadRedactionConfig re-reads REDACTION_CONFIG and swaps in the resulting rules. On
+// error the previously active rules stay in place.
+func reloadRedactionConfig() error {
+	if redactionConfigPath == "" {
+		return nil
+	}
+
+	cfg, err := readRedactionConfig(redactionConfigPath)
+	if err != nil {
+		return err
+	}
+	rules, err := buildRedactionRules(cfg)
+	if err != nil {
+		return err
+	}
+
+	activeRedaction.Lock()
+	activeRedaction.rules = rules
+	activeRedaction.Unlock()
+
+	log.Printf("redaction: loaded %d custom patterns and %d safe keys from %s", len(cfg.SensitivePatterns), len(cfg.SafeKeys), redactionConfigPath)
+	return nil
+}
+
+// watchRedactionReloads reloads the redaction config every time SIGHUP is received.
+func watchRedactionReloads() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			if err := reloadRedactionConfig(); err != nil {
+				log.Printf("redaction: reload failed, keeping previous rules: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func withRedactionConfig(t *testing.T, filename, contents string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), filename)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write redaction config: %v", err)
+	}
+
+	originalPath := redactionConfigPath
+	originalRules := currentRedactionRules()
+	redactionConfigPath = path
+	t.Cleanup(func() {
+		redactionConfigPath = originalPath
+		activeRedaction.Lock()
+		activeRedaction.rules = originalRules
+		activeRedaction.Unlock()
+	})
+}
+
+func TestReloadRedactionConfigYAML(t *testing.T) {
+	withRedactionConfig(t, "redaction.yaml", `
+sensitivePatterns:
+  - "(^|\\.)pwd$"
+safeKeys:
+  - token.endpoint.url
+placeholder: "[hidden]"
+`)
+
+	if err := reloadRedactionConfig(); err != nil {
+		t.Fatalf("reloadRedactionConfig returned error: %v", err)
+	}
+
+	redacted := redactSensitiveData(map[string]interface{}{
+		"sf.pwd":             "hunter2",
+		"token.endpoint.url": "https://login.example.com",
+		"password":           "secret",
+		"sf.user":            "svc",
+	}).(map[string]interface{})
+
+	if redacted["sf.pwd"] != "[hidden]" {
+		t.Fatalf("expected custom pattern to redact sf.pwd, got %v", redacted["sf.pwd"])
+	}
+	if redacted["password"] != "[hidden]" {
+		t.Fatalf("expected built-in pattern to keep applying, got %v", redacted["password"])
+	}
+	if redacted["token.endpoint.url"] != "https://login.example.com" {
+		t.Fatalf("expected safe key to pass through, got %v", redacted["token.endpoint.url"])
+	}
+	if redacted["sf.user"] != "svc" {
+		t.Fatalf("expected unrelated key to pass through, got %v", redacted["sf.user"])
+	}
+}
+
+func TestReloadRedactionConfigJSON(t *testing.T) {
+	withRedactionConfig(t, "redaction.json", `{"sensitivePatterns":["passphrase"]}`)
+
+	if err := reloadRedactionConfig(); err != nil {
+		t.Fatalf("reloadRedactionConfig returned error: %v", err)
+	}
+
+	redacted := redactSensitiveData(map[string]interface{}{"ssl.passphrase": "x"}).(map[string]interface{})
+	if redacted["ssl.passphrase"] != defaultRedactionPlaceholder {
+		t.Fatalf("expected ssl.passphrase to be redacted, got %v", redacted["ssl.passphrase"])
+	}
+}
+
+func TestReloadRedactionConfigKeepsRulesOnError(t *testing.T) {
+	withRedactionConfig(t, "redaction.json", `{"sensitivePatterns":["("]}`)
+
+	if err := reloadRedactionConfig(); err == nil {
+		t.Fatalf("expected invalid pattern to be rejected")
+	}
+
+	redacted := redactSensitiveData(map[string]interface{}{"password": "x"}).(map[string]interface{})
+	if redacted["password"] != defaultRedactionPlaceholder {
+		t.Fatalf("expected default rules to remain active, got %v", redacted["password"])
+	}
+}
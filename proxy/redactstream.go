@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	redactionStreamThreshold = getEnv("REDACTION_STREAM_THRESHOLD", "1048576")
+
+	redactionStreamBytes int64 = 1 << 20
+)
+
+// loadRedactionStreamThreshold parses REDACTION_STREAM_THRESHOLD. Zero streams every
+// non-empty JSON body.
+func loadRedactionStreamThreshold() (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(redactionStreamThreshold), 10, 64)
+	if err != nil || n < 0 {
+		return 0, &configError{name: "REDACTION_STREAM_THRESHOLD", value: redactionStreamThreshold}
+	}
+	return n, nil
+}
+
+// isJSONResponse reports whether resp declares a JSON media type, e.g. application/json
+// or application/vnd.kafka+json.
+func isJSONResponse(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// writeStreamedRedactedResponse writes status and header, then copies body to w with
+// sensitive values redacted as it is read. Bodies that are not declared as JSON are
+// copied unchanged. Once the header is sent an upstream or decoding error can only cut
+// the response short, so it is returned for the caller to log.
+func writeStreamedRedactedResponse(w http.ResponseWriter, resp *http.Response, body io.Reader) error {
+	for key, values := range resp.Header {
+		if strings.EqualFold(key, "Content-Length") {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	if !isJSONResponse(resp) {
+		if _, err := io.Copy(w, body); err != nil {
+			return fmt.Errorf("stream response body: %w", err)
+		}
+		return nil
+	}
+	if err := currentRedactionRules().redactStream(w, body); err != nil {
+		return fmt.Errorf("stream redacted response body: %w", err)
+	}
+	return nil
+}
+
+// redactFrame is an object or array redactStream is inside of.
+type redactFrame struct {
+	object  bool
+	count   int    // members or elements written so far
+	wantKey bool   // the next token of an object is a member name
+	key     string // name of the object member being written
+}
+
+// redactStream copies one JSON document from src to dst token by token, applying the
+// same rules as redact without holding the document in memory. Object members keep
+// their upstream order, and numbers are copied as written.
+func (r redactionRules) redactStream(dst io.Writer, src io.Reader) error {
+	dec := json.NewDecoder(src)
+	dec.UseNumber()
+	out := bufio.NewWriterSize(dst, 32<<10)
+
+	var stack []*redactFrame
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) && len(stack) == 0 {
+			break
+		}
+		if err != nil {
+			out.Flush()
+			return err
+		}
+
+		var top *redactFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			out.WriteByte(byte(delim))
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if top != nil && top.object && top.wantKey {
+			if top.count > 0 {
+				out.WriteByte(',')
+			}
+			top.count++
+			top.key, top.wantKey = tok.(string), false
+			writeJSONToken(out, top.key)
+			out.WriteByte(':')
+			continue
+		}
+		if top != nil && !top.object {
+			if top.count > 0 {
+				out.WriteByte(',')
+			}
+			top.count++
+		}
+
+		if top != nil && top.object {
+			top.wantKey = true
+			if s, ok := tok.(string); ok && isSecretReference(s) {
+				writeJSONToken(out, s)
+				continue
+			}
+			if r.isSensitive(top.key) {
+				writeJSONToken(out, r.placeholder)
+				if _, ok := tok.(json.Delim); ok {
+					if err := skipJSONValue(dec); err != nil {
+						out.Flush()
+						return err
+					}
+				}
+				continue
+			}
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			out.WriteByte(byte(delim))
+			stack = append(stack, &redactFrame{object: delim == '{', wantKey: delim == '{'})
+			continue
+		}
+		writeJSONToken(out, tok)
+	}
+	return out.Flush()
+}
+
+// skipJSONValue consumes the rest of an object or array whose opening delimiter has
+// already been read.
+func skipJSONValue(dec *json.Decoder) error {
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// writeJSONToken writes a scalar token the way json.Marshal would.
+func writeJSONToken(out *bufio.Writer, tok json.Token) {
+	switch v := tok.(type) {
+	case json.Number:
+		out.WriteString(v.String())
+	case nil:
+		out.WriteString("null")
+	default:
+		encoded, _ := json.Marshal(v)
+		out.Write(encoded)
+	}
+}
+
+// writeRedactedResponse copies resp to w with sensitive values redacted. Bodies up to
+// REDACTION_STREAM_THRESHOLD are redacted in memory; larger ones are streamed.
+func writeRedactedResponse(w http.ResponseWriter, resp *http.Response) error {
+	defer resp.Body.Close()
+
+	head, err := io.ReadAll(io.LimitReader(resp.Body, redactionStreamBytes+1))
+	if err != nil {
+		return fmt.Errorf("read response body: %w", err)
+	}
+	if int64(len(head)) <= redactionStreamBytes {
+		body, err := redactBody(head)
+		if err != nil {
+			return err
+		}
+		return writeResponse(w, resp.StatusCode, resp.Header, body)
+	}
+	return writeStreamedRedactedResponse(w, resp, io.MultiReader(bytes.NewReader(head), resp.Body))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactStreamMatchesRedact(t *testing.T) {
+	doc := `{
+		"name": "orders-sink",
+		"config": {
+			"connection.password": "hunter2",
+			"connection.user": "svc",
+			"key.converter": "org.apache.kafka.connect.json.JsonConverter",
+			"sasl.jaas.config": "${vault:secret/kafka:jaas}",
+			"tasks.max": 12345678901234567890,
+			"credentials": {"nested": ["a", {"b": 1}]},
+			"topics": ["orders", "refunds"],
+			"ssl.key.password": null,
+			"flags": [true, false, null, 1.5e3, {"token": "abc"}, []]
+		},
+		"empty": {}
+	}`
+
+	var buffered bytes.Buffer
+	if err := currentRedactionRules().redactStream(&buffered, strings.NewReader(doc)); err != nil {
+		t.Fatalf("redactStream returned error: %v", err)
+	}
+	var streamed, want interface{}
+	if err := json.Unmarshal(buffered.Bytes(), &streamed); err != nil {
+		t.Fatalf("streamed output is not JSON: %v\n%s", err, buffered.String())
+	}
+	expected, err := redactBody([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(expected, &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(streamed, want) {
+		t.Fatalf("streamed redaction differs:\n got %s\nwant %s", buffered.String(), expected)
+	}
+	if !strings.Contains(buffered.String(), `"tasks.max":12345678901234567890`) {
+		t.Fatalf("expected numbers to be copied as written, got %s", buffered.String())
+	}
+	if !strings.HasPrefix(buffered.String(), `{"name":"orders-sink","config":{"connection.password":"***REDACTED***"`) {
+		t.Fatalf("expected members to keep their order, got %s", buffered.String())
+	}
+}
+
+func TestWriteRedactedResponseStreamsLargeBodies(t *testing.T) {
+	original := redactionStreamBytes
+	t.Cleanup(func() { redactionStreamBytes = original })
+	redactionStreamBytes = 16
+
+	call := func(contentType, body string) (*httptest.ResponseRecorder, error) {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{contentType}, "Content-Length": []string{"999"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+		rr := httptest.NewRecorder()
+		return rr, writeRedactedResponse(rr, resp)
+	}
+
+	rr, err := call("application/json; charset=utf-8", `{"password": "hunter2", "topics": ["orders"]}`)
+	if err != nil || rr.Body.String() != `{"password":"***REDACTED***","topics":["orders"]}` {
+		t.Fatalf("expected a streamed redacted body, got %q (%v)", rr.Body.String(), err)
+	}
+	if rr.Header().Get("Content-Length") != "" || rr.Header().Get("Content-Type") == "" {
+		t.Fatalf("unexpected headers %v", rr.Header())
+	}
+
+	rr, err = call("text/plain", "not json, but long enough to stream")
+	if err != nil || rr.Body.String() != "not json, but long enough to stream" {
+		t.Fatalf("expected other bodies to pass through, got %q (%v)", rr.Body.String(), err)
+	}
+
+	rr, err = call("application/json", `{"password": "hunter2", "topics": [`)
+	if err == nil || strings.Contains(rr.Body.String(), "hunter2") {
+		t.Fatalf("expected a truncated document to fail without leaking, got %q (%v)", rr.Body.String(), err)
+	}
+
+	rr, err = call("application/json", `{"ok": true}`)
+	if err != nil || rr.Body.String() != `{"ok":true}` {
+		t.Fatalf("expected small bodies to be redacted in memory, got %q (%v)", rr.Body.String(), err)
+	}
+}
+
+func TestLoadRedactionStreamThreshold(t *testing.T) {
+	original := redactionStreamThreshold
+	t.Cleanup(func() { redactionStreamThreshold = original })
+
+	for value, valid := range map[string]bool{"1048576": true, "0": true, "-1": false, "1MB": false} {
+		redactionStreamThreshold = value
+		if _, err := loadRedactionStreamThreshold(); (err == nil) != valid {
+			t.Errorf("%q: expected valid=%v, got %v", value, valid, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	maxRequestBodyBytes = getEnv("MAX_REQUEST_BODY_BYTES", "1048576")
+
+	requestBodyLimit int64 = 1 << 20
+)
+
+// loadRequestBodyLimit parses MAX_REQUEST_BODY_BYTES.
+func loadRequestBodyLimit() (int64, error) {
+	limit, err := strconv.ParseInt(strings.TrimSpace(maxRequestBodyBytes), 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, &configError{name: "MAX_REQUEST_BODY_BYTES", value: maxRequestBodyBytes}
+	}
+	return limit, nil
+}
+
+// jsonBodyError reports where a request body stopped being valid JSON. Offset is the
+// zero-based byte offset; Line and Column are one-based.
+type jsonBodyError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Offset  int64  `json:"offset"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// validateJSONBody checks that body holds exactly one JSON value.
+func validateJSONBody(body []byte) *jsonBodyError {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	var value interface{}
+	err := decoder.Decode(&value)
+
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	switch {
+	case err == nil:
+		offset = decoder.InputOffset()
+		if _, err := decoder.Token(); err == io.EOF {
+			return nil
+		}
+		for offset < int64(len(body)) && strings.ContainsRune(" \t\r\n", rune(body[offset])) {
+			offset++
+		}
+		err = errors.New("unexpected data after the top-level JSON value")
+	case errors.As(err, &syntaxErr):
+		// Offset counts the bytes read including the offending one.
+		offset = syntaxErr.Offset - 1
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		offset = int64(len(body))
+		err = errors.New("unexpected end of JSON input")
+	default:
+		offset = decoder.InputOffset()
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	line := 1 + bytes.Count(body[:offset], []byte("\n"))
+	column := int(offset) - bytes.LastIndexByte(body[:offset], '\n')
+	return &jsonBodyError{
+		Error:   "invalid_json",
+		Message: fmt.Sprintf("request body is not valid JSON at line %d, column %d: %v", line, column, err),
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+	}
+}
+
+// requiresJSONBody reports whether a request writes to a connector or plugin endpoint,
+// whose bodies Kafka Connect parses as JSON.
+func requiresJSONBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return false
+	}
+	segments := clusterPathSegments(r.URL.Path)
+	return len(segments) > 0 && (segments[0] == "connectors" || segments[0] == "connector-plugins")
+}
+
+func writeBodyTooLarge(w http.ResponseWriter) {
+	writeJSONError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+		fmt.Sprintf("request body exceeds the limit of %d bytes", requestBodyLimit))
+}
+
+// requestLimitsMiddleware caps API request bodies at MAX_REQUEST_BODY_BYTES and rejects
+// malformed JSON sent to connector endpoints before it reaches Kafka Connect, which
+// would otherwise answer with an opaque 500.
+func requestLimitsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > requestBodyLimit {
+			writeBodyTooLarge(w)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, requestBodyLimit)
+		if !requiresJSONBody(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeBodyTooLarge(w)
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, "invalid_body", "failed to read request body")
+			return
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if invalid := validateJSONBody(body); invalid != nil {
+				writeJSON(w, http.StatusBadRequest, invalid)
+				return
+			}
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateJSONBody(t *testing.T) {
+	tests := []struct {
+		body         string
+		valid        bool
+		line, column int
+	}{
+		{`{"name":"alpha","config":{}}`, true, 0, 0},
+		{"  [1, 2]\n", true, 0, 0},
+		{"{\n  \"name\": \"alpha\",\n  \"tasks.max\": ,\n}", false, 3, 16},
+		{`{"name":"alpha"`, false, 1, 16},
+		{`{"a":1} {"b":2}`, false, 1, 9},
+		{`name=alpha`, false, 1, 2},
+	}
+
+	for _, tt := range tests {
+		invalid := validateJSONBody([]byte(tt.body))
+		if tt.valid {
+			if invalid != nil {
+				t.Fatalf("expected %q to be valid, got %+v", tt.body, invalid)
+			}
+			continue
+		}
+		if invalid == nil {
+			t.Fatalf("expected %q to be invalid", tt.body)
+		}
+		if invalid.Line != tt.line || invalid.Column != tt.column {
+			t.Fatalf("%q: location = %d:%d, want %d:%d (%s)", tt.body, invalid.Line, invalid.Column, tt.line, tt.column, invalid.Message)
+		}
+	}
+}
+
+func TestRequestLimitsMiddleware(t *testing.T) {
+	original := requestBodyLimit
+	requestBodyLimit = 64
+	t.Cleanup(func() { requestBodyLimit = original })
+
+	var forwarded string
+	handler := requestLimitsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		forwarded = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(method, path, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPut, "/api/default/connectors/alpha/config", `{"tasks.max":"1"}`, false); rr.Code != http.StatusOK || forwarded != `{"tasks.max":"1"}` {
+		t.Fatalf("valid body: got %d, forwarded %q", rr.Code, forwarded)
+	}
+	if rr := do(http.MethodPut, "/api/default/connectors/alpha/pause", "", false); rr.Code != http.StatusOK {
+		t.Fatalf("empty body: got %d", rr.Code)
+	}
+
+	rr := do(http.MethodPost, "/api/default/connectors", `{"name": "alpha", "config": {`, false)
+	var invalid jsonBodyError
+	if err := json.Unmarshal(rr.Body.Bytes(), &invalid); err != nil || rr.Code != http.StatusBadRequest || invalid.Error != "invalid_json" || invalid.Column != 30 {
+		t.Fatalf("malformed body: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	large := `{"a":"` + strings.Repeat("x", 100) + `"}`
+	for _, chunked := range []bool{false, true} {
+		if rr := do(http.MethodPost, "/api/default/connectors", large, chunked); rr.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rr.Body.String(), "request_too_large") {
+			t.Fatalf("oversized body (chunked=%v): got %d %s", chunked, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Other endpoints are size-limited but not parsed.
+	if rr := do(http.MethodPost, "/api/default/templates/jdbc/render", "not json", false); rr.Code != http.StatusOK {
+		t.Fatalf("non-connector body: got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/api/default/templates/jdbc/render", large, true); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized non-connector body: got %d", rr.Code)
+	}
+}
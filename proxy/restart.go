@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	restartDefaultWait = 10 * time.Second
+	restartMaxWait     = time.Minute
+)
+
+// restartPollInterval is how often the connector status is checked after a restart.
+var restartPollInterval = time.Second
+
+// errRestartOptionsUnsupported is returned for workers older than KIP-745 (Kafka 3.0),
+// which silently ignore includeTasks and onlyFailed and only restart the connector.
+var errRestartOptionsUnsupported = errors.New("includeTasks and onlyFailed require Kafka Connect 3.0 or later")
+
+// InstanceState is the state of a connector or one of its tasks.
+type InstanceState struct {
+	ID       *int   `json:"id,omitempty"`
+	State    string `json:"state"`
+	WorkerID string `json:"workerId,omitempty"`
+}
+
+// RestartStates is a snapshot of a connector and its tasks.
+type RestartStates struct {
+	Connector InstanceState   `json:"connector"`
+	Tasks     []InstanceState `json:"tasks"`
+	Failed    int             `json:"failed"`
+}
+
+// RestartOutcome is returned by POST /api/{cluster}/connectors/{name}/restart-advanced.
+// Settled is false when an instance was still restarting or unassigned once the wait
+// ran out; Recovered is true when nothing is failed afterwards.
+type RestartOutcome struct {
+	Connector     string        `json:"connector"`
+	IncludeTasks  bool          `json:"includeTasks"`
+	OnlyFailed    bool          `json:"onlyFailed"`
+	ConnectStatus int           `json:"connectStatus"`
+	Before        RestartStates `json:"before"`
+	After         RestartStates `json:"after"`
+	Settled       bool          `json:"settled"`
+	Recovered     bool          `json:"recovered"`
+	WaitedMs      int64         `json:"waitedMs"`
+}
+
+// instanceState normalizes a state, keeping RESTARTING, which Connect 3.0+ reports
+// while a restart requested with includeTasks or onlyFailed is in progress.
+func instanceState(state string) string {
+	if strings.EqualFold(state, "RESTARTING") {
+		return "restarting"
+	}
+	return normalizeState(state)
+}
+
+func restartStates(status connectorStatusResponse) RestartStates {
+	states := RestartStates{
+		Connector: InstanceState{State: instanceState(status.Connector.State), WorkerID: status.Connector.WorkerID},
+		Tasks:     make([]InstanceState, 0, len(status.Tasks)),
+	}
+	if states.Connector.State == "failed" {
+		states.Failed++
+	}
+	for _, task := range status.Tasks {
+		id := task.ID
+		state := instanceState(task.State)
+		states.Tasks = append(states.Tasks, InstanceState{ID: &id, State: state, WorkerID: task.WorkerID})
+		if state == "failed" {
+			states.Failed++
+		}
+	}
+	return states
+}
+
+// settled reports whether no instance is still restarting or waiting for a worker.
+func (s RestartStates) settled() bool {
+	pending := func(state string) bool { return state == "restarting" || state == "unassigned" }
+	if pending(s.Connector.State) {
+		return false
+	}
+	for _, task := range s.Tasks {
+		if pending(task.State) {
+			return false
+		}
+	}
+	return true
+}
+
+// supportsRestartOptions reports whether a worker version understands includeTasks and
+// onlyFailed. Confluent Platform builds (-ccs, -ce) carry the platform version, where 7.0
+// ships Kafka 3.0. Versions that cannot be parsed are given the benefit of the doubt.
+func supportsRestartOptions(version string) bool {
+	parts := strings.SplitN(version, ".", 2)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return true
+	}
+	if strings.Contains(version, "-ccs") || strings.Contains(version, "-ce") {
+		return major >= 7
+	}
+	return major >= 3
+}
+
+// fetchConnectVersion returns the version reported by the Connect root endpoint.
+func fetchConnectVersion(ctx context.Context, client *http.Client, baseURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status fetching connect version: %d", resp.StatusCode)
+	}
+
+	var info struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("decode connect version: %w", err)
+	}
+	return info.Version, nil
+}
+
+// awaitRestart polls the connector status until every instance has settled or the
+// wait runs out, and returns the last status seen.
+func awaitRestart(ctx context.Context, client *http.Client, baseURL, name string, wait time.Duration) (RestartStates, bool, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		status, err := fetchConnectorStatus(ctx, client, baseURL, name)
+		if err != nil {
+			return RestartStates{}, false, err
+		}
+		states := restartStates(status)
+		if states.settled() {
+			return states, true, nil
+		}
+		if !time.Now().Add(restartPollInterval).Before(deadline) {
+			return states, false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return states, false, nil
+		case <-time.After(restartPollInterval):
+		}
+	}
+}
+
+// parseRestartOptions reads includeTasks, onlyFailed and wait from the query.
+func parseRestartOptions(query url.Values) (includeTasks, onlyFailed bool, wait time.Duration, err error) {
+	parseFlag := func(name string) (bool, error) {
+		value := query.Get(name)
+		if value == "" {
+			return false, nil
+		}
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("%s must be true or false", name)
+		}
+		return flag, nil
+	}
+	if includeTasks, err = parseFlag("includeTasks"); err != nil {
+		return
+	}
+	if onlyFailed, err = parseFlag("onlyFailed"); err != nil {
+		return
+	}
+	wait, err = parseWaitParam(query)
+	return
+}
+
+// parseWaitParam reads how long to wait for a state change from the wait query
+// parameter.
+func parseWaitParam(query url.Values) (time.Duration, error) {
+	value := query.Get("wait")
+	if value == "" {
+		return restartDefaultWait, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil || wait < 0 || wait > restartMaxWait {
+		return 0, fmt.Errorf("wait must be a duration between 0s and %s", restartMaxWait)
+	}
+	return wait, nil
+}
+
+// restartAdvancedHandler restarts a connector with Connect's includeTasks and
+// onlyFailed options, then waits for the restarted instances to settle and reports the
+// states before and after so callers can tell whether the restart helped.
+func restartAdvancedHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	baseURL := connectURLFor(vars["cluster"])
+	includeTasks, onlyFailed, wait, err := parseRestartOptions(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_restart_options", err.Error())
+		return
+	}
+
+	client := connectClientFor(vars["cluster"], routeWrite)
+	writeError := func(err error) {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, unavailable)
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %s not found", name))
+		default:
+			writeJSONError(w, http.StatusBadGateway, "restart_failed", err.Error())
+		}
+	}
+
+	if includeTasks || onlyFailed {
+		version, err := fetchConnectVersion(r.Context(), client, baseURL)
+		if err != nil {
+			writeError(err)
+			return
+		}
+		if !supportsRestartOptions(version) {
+			writeJSONError(w, http.StatusNotImplemented, "restart_options_unsupported",
+				fmt.Sprintf("%s (cluster reports %s)", errRestartOptionsUnsupported, version))
+			return
+		}
+	}
+
+	before, err := fetchConnectorStatus(r.Context(), client, baseURL, name)
+	if err != nil {
+		writeError(err)
+		return
+	}
+	connectStatus, err := restartConnector(r.Context(), client, baseURL, name, includeTasks, onlyFailed)
+	if err != nil {
+		writeError(err)
+		return
+	}
+
+	started := time.Now()
+	after, settled, err := awaitRestart(r.Context(), client, baseURL, name, wait)
+	if err != nil {
+		writeError(err)
+		return
+	}
+	writeJSON(w, http.StatusOK, RestartOutcome{
+		Connector:     name,
+		IncludeTasks:  includeTasks,
+		OnlyFailed:    onlyFailed,
+		ConnectStatus: connectStatus,
+		Before:        restartStates(before),
+		After:         after,
+		Settled:       settled,
+		Recovered:     after.Failed == 0,
+		WaitedMs:      time.Since(started).Milliseconds(),
+	})
+}
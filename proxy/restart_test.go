@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// restartTestServer fakes a Connect cluster whose connector "orders" has a failed task
+// that is RESTARTING on the first status read after a restart and RUNNING afterwards.
+func restartTestServer(t *testing.T, version string) (*httptest.Server, func() string) {
+	t.Helper()
+	var (
+		mu         sync.Mutex
+		restartURL string
+		reads      int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/":
+			io.WriteString(w, `{"version":"`+version+`","commit":"abc","kafka_cluster_id":"k1"}`)
+		case r.URL.Path == "/connectors/orders/status":
+			taskState := "FAILED"
+			if restartURL != "" {
+				reads++
+				taskState = "RUNNING"
+				if reads == 1 {
+					taskState = "RESTARTING"
+				}
+			}
+			io.WriteString(w, `{"name":"orders","connector":{"state":"RUNNING","worker_id":"w1:8083"},"tasks":[{"id":0,"state":"RUNNING","worker_id":"w1:8083"},{"id":1,"state":"`+taskState+`","worker_id":"w2:8083"}]}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/connectors/orders/restart":
+			restartURL = r.URL.String()
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error_code":404,"message":"not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return restartURL
+	}
+}
+
+func doRestartAdvanced(name, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/default/connectors/"+name+"/restart-advanced?"+query, nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": name})
+	rr := httptest.NewRecorder()
+	restartAdvancedHandler(rr, req)
+	return rr
+}
+
+func TestRestartAdvancedHandler(t *testing.T) {
+	originalInterval := restartPollInterval
+	restartPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { restartPollInterval = originalInterval })
+
+	server, restartURL := restartTestServer(t, "3.6.1")
+	defer withTestConnectURL(t, server)()
+
+	rr := doRestartAdvanced("orders", "includeTasks=true&onlyFailed=true&wait=5s")
+	var outcome RestartOutcome
+	if err := json.Unmarshal(rr.Body.Bytes(), &outcome); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := restartURL(); got != "/connectors/orders/restart?includeTasks=true&onlyFailed=true" {
+		t.Fatalf("unexpected restart request %q", got)
+	}
+	if outcome.ConnectStatus != http.StatusAccepted || outcome.Before.Failed != 1 || outcome.Before.Tasks[1].State != "failed" {
+		t.Fatalf("unexpected before states %+v", outcome)
+	}
+	if !outcome.Settled || !outcome.Recovered || outcome.After.Tasks[1].State != "running" || outcome.After.Tasks[1].WorkerID != "w2:8083" {
+		t.Fatalf("expected the restart to settle and recover, got %+v", outcome)
+	}
+
+	if rr := doRestartAdvanced("orders", "includeTasks=maybe"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid flag, got %d", rr.Code)
+	}
+	if rr := doRestartAdvanced("orders", "wait=2h"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a wait above the maximum, got %d", rr.Code)
+	}
+	if rr := doRestartAdvanced("missing", "includeTasks=true"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing connector, got %d", rr.Code)
+	}
+}
+
+func TestRestartAdvancedRejectsOldWorkers(t *testing.T) {
+	server, restartURL := restartTestServer(t, "2.8.1")
+	defer withTestConnectURL(t, server)()
+
+	if rr := doRestartAdvanced("orders", "includeTasks=true"); rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 on Connect 2.8, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := restartURL(); got != "" {
+		t.Fatalf("expected no restart to be sent, got %q", got)
+	}
+
+	for version, supported := range map[string]bool{"3.0.0": true, "2.8.2": false, "7.5.0-ccs": true, "6.2.1-ce": false, "trunk": true} {
+		if got := supportsRestartOptions(version); got != supported {
+			t.Errorf("supportsRestartOptions(%q) = %v, want %v", version, got, supported)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	restorePointsFile = "restore-points.json"
+
+	auditActionRestore = "RESTORE"
+)
+
+var (
+	// restorePointsMax is how many restore points are kept per connector; 0 turns the
+	// snapshots off.
+	restorePointsMax = getEnv("RESTORE_POINTS_MAX", "10")
+
+	restorePoints = newRestorePointStore(10, time.Now)
+)
+
+// RestorePoint is the state of a connector taken just before a risky change went
+// through the proxy: a delete, a config update, or a restore. Config keeps secret
+// placeholders where the proxy resolved them. Offsets is Connect's offsets document,
+// when the cluster could report it (Kafka Connect 3.5+).
+type RestorePoint struct {
+	ID        string            `json:"id"`
+	Cluster   string            `json:"cluster"`
+	Connector string            `json:"connector"`
+	Operation string            `json:"operation"`
+	CreatedAt time.Time         `json:"createdAt"`
+	CreatedBy string            `json:"createdBy,omitempty"`
+	Config    map[string]string `json:"config"`
+	Offsets   json.RawMessage   `json:"offsets,omitempty"`
+}
+
+// RestoreResult is returned by the restore endpoint.
+type RestoreResult struct {
+	RestorePoint    RestorePoint `json:"restorePoint"`
+	Created         bool         `json:"created"`
+	OffsetsRestored bool         `json:"offsetsRestored"`
+}
+
+// loadRestorePointsMax parses RESTORE_POINTS_MAX.
+func loadRestorePointsMax() (int, error) {
+	n, err := strconv.Atoi(restorePointsMax)
+	if err != nil || n < 0 {
+		return 0, &configError{name: "RESTORE_POINTS_MAX", value: restorePointsMax}
+	}
+	return n, nil
+}
+
+// restorePointDocument is the persisted form of the restore points.
+type restorePointDocument struct {
+	NextID int64          `json:"nextId"`
+	Points []RestorePoint `json:"points"`
+}
+
+// restorePointStore keeps the newest restore points of every connector, oldest first,
+// and persists them to DATA_DIR.
+type restorePointStore struct {
+	mu     sync.Mutex
+	max    int
+	nextID int64
+	points []RestorePoint
+	now    func() time.Time
+}
+
+func newRestorePointStore(max int, now func() time.Time) *restorePointStore {
+	return &restorePointStore{max: max, now: now}
+}
+
+// load replaces the restore points with the persisted ones, if any.
+func (s *restorePointStore) load() error {
+	var doc restorePointDocument
+	if err := loadJSON(restorePointsFile, &doc); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID, s.points = doc.NextID, doc.Points
+	return nil
+}
+
+func (s *restorePointStore) enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.max > 0
+}
+
+// add stores point and drops the oldest points of the connector beyond the limit. The
+// point is kept in memory even when persisting it fails.
+func (s *restorePointStore) add(point RestorePoint) (RestorePoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	point.ID = strconv.FormatInt(s.nextID, 10)
+	point.CreatedAt = s.now().UTC()
+	s.points = append(s.points, point)
+
+	count := 0
+	for i := len(s.points) - 1; i >= 0; i-- {
+		p := s.points[i]
+		if p.Cluster != point.Cluster || p.Connector != point.Connector {
+			continue
+		}
+		if count++; count > s.max {
+			s.points = append(s.points[:i], s.points[i+1:]...)
+		}
+	}
+	return point, saveJSON(restorePointsFile, restorePointDocument{NextID: s.nextID, Points: s.points})
+}
+
+// list returns the restore points of a cluster, newest first; connector "" lists every
+// connector, including deleted ones.
+func (s *restorePointStore) list(cluster, connector string) []RestorePoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []RestorePoint{}
+	for i := len(s.points) - 1; i >= 0; i-- {
+		p := s.points[i]
+		if p.Cluster == cluster && (connector == "" || p.Connector == connector) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (s *restorePointStore) get(cluster, connector, id string) (RestorePoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.points {
+		if p.ID == id && p.Cluster == cluster && p.Connector == connector {
+			return p, true
+		}
+	}
+	return RestorePoint{}, false
+}
+
+// pendingRestorePoint is a snapshot taken before a change, stored once Kafka Connect
+// accepts the change.
+type pendingRestorePoint struct {
+	point *RestorePoint
+}
+
+// commit stores the snapshot if the upstream change succeeded.
+func (p pendingRestorePoint) commit(status int) {
+	if p.point == nil || status < 200 || status >= 300 {
+		return
+	}
+	if _, err := restorePoints.add(*p.point); err != nil {
+		log.Printf("restore points: failed to persist the snapshot of %s: %v", p.point.Connector, err)
+	}
+}
+
+// snapshotConnector reads the config and offsets of a connector before a change. A
+// connector that does not exist yet has nothing to restore; other failures are logged
+// and do not hold up the change.
+func snapshotConnector(ctx context.Context, client *http.Client, baseURL, cluster, name, operation, user string) pendingRestorePoint {
+	if !restorePoints.enabled() {
+		return pendingRestorePoint{}
+	}
+	config, err := fetchConnectorConfig(ctx, client, baseURL, name)
+	if err != nil {
+		if !errors.Is(err, errConnectorNotFound) {
+			log.Printf("restore points: failed to snapshot %s before %s: %v", name, operation, err)
+		}
+		return pendingRestorePoint{}
+	}
+	secretRefs.restoreStrings(cluster, name, config)
+
+	point := &RestorePoint{Cluster: cluster, Connector: name, Operation: operation, CreatedBy: user, Config: config}
+	if offsets, status, err := fetchConnectorOffsets(ctx, client, baseURL, name); err == nil && status == http.StatusOK && json.Valid(offsets) {
+		point.Offsets = offsets
+	}
+	return pendingRestorePoint{point: point}
+}
+
+// snapshotProxiedChange snapshots the connector addressed by a proxied delete or config
+// update.
+func snapshotProxiedChange(r *http.Request, name, subresource string) pendingRestorePoint {
+	var operation string
+	switch {
+	case r.Method == http.MethodDelete && subresource == "":
+		operation = auditActionDelete
+	case r.Method == http.MethodPut && subresource == "config":
+		operation = auditActionUpdate
+	default:
+		return pendingRestorePoint{}
+	}
+	cluster := mux.Vars(r)["cluster"]
+	return snapshotConnector(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster), cluster, name, operation, requestUser(r))
+}
+
+// redactedRestorePoints returns points with sensitive config values replaced by the
+// redaction placeholder; secret placeholders are kept.
+func redactedRestorePoints(rules redactionRules, points []RestorePoint) []RestorePoint {
+	redacted := make([]RestorePoint, len(points))
+	for i, point := range points {
+		config := make(map[string]string, len(point.Config))
+		for key, value := range point.Config {
+			if rules.isSensitive(key) && !isSecretReference(value) {
+				value = rules.placeholder
+			}
+			config[key] = value
+		}
+		point.Config = config
+		redacted[i] = point
+	}
+	return redacted
+}
+
+// restorePointsHandler lists the restore points of a connector, or of every connector of
+// the cluster when no name is in the path.
+func restorePointsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	writeJSON(w, http.StatusOK, redactedRestorePoints(currentRedactionRules(), restorePoints.list(vars["cluster"], vars["name"])))
+}
+
+// restoreConnectorHandler puts a connector back to a restore point: the config is
+// written with PUT /connectors/{name}/config, which also recreates a deleted connector.
+// With ?offsets=true the saved offsets are written too, which Kafka Connect only allows
+// while the connector is STOPPED. The current state is snapshotted first, so a restore
+// can itself be undone.
+func restoreConnectorHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+	point, ok := restorePoints.get(cluster, name, vars["id"])
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "restore_point_not_found", fmt.Sprintf("connector %s has no restore point %s", name, vars["id"]))
+		return
+	}
+	withOffsets := r.URL.Query().Get("offsets") == "true"
+
+	config := make(map[string]interface{}, len(point.Config)+1)
+	for key, value := range point.Config {
+		config[key] = value
+	}
+	config["name"] = name
+	if !admitConnectorConfig(w, r, name, config) {
+		return
+	}
+
+	baseURL := connectURLFor(cluster)
+	client := connectClientFor(cluster, routeWrite)
+	if withOffsets {
+		if len(point.Offsets) == 0 {
+			writeJSONError(w, http.StatusConflict, "offsets_unavailable", fmt.Sprintf("restore point %s has no offsets", point.ID))
+			return
+		}
+		status, err := fetchConnectorStatus(r.Context(), client, baseURL, name)
+		switch {
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusConflict, "connector_not_stopped",
+				fmt.Sprintf("connector %s does not exist; restore its config, stop it, then restore offsets", name))
+			return
+		case err != nil:
+			writeDryRunError(w, err, name)
+			return
+		case normalizeState(status.Connector.State) != "stopped":
+			writeJSONError(w, http.StatusConflict, "connector_not_stopped",
+				fmt.Sprintf("connector %s is %s; stop it before restoring offsets", name, status.Connector.State))
+			return
+		}
+	}
+
+	pending := snapshotConnector(r.Context(), connectClientFor(cluster, routeRead), baseURL, cluster, name, auditActionRestore, requestUser(r))
+	refs, err := connectorSecrets.resolveConfig(r.Context(), config)
+	if err != nil {
+		writeSecretResolutionError(w, err)
+		return
+	}
+	body, err := json.Marshal(config)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "encode_failed", err.Error())
+		return
+	}
+
+	details := map[string]interface{}{"restorePoint": point.ID, "operation": point.Operation, "offsets": withOffsets}
+	target := joinURL(baseURL, "connectors", url.PathEscape(name), "config")
+	resp, err := sendConnectJSON(r.Context(), client, http.MethodPut, target, body)
+	if err != nil {
+		details["error"] = err.Error()
+		recordAudit(r, auditActionRestore, name, http.StatusBadGateway, details)
+		writeConnectUnavailable(w, err)
+		return
+	}
+	if resp.StatusCode >= 400 {
+		recordAudit(r, auditActionRestore, name, resp.StatusCode, details)
+		writeConnectError(w, resp)
+		return
+	}
+	resp.Body.Close()
+	pending.commit(resp.StatusCode)
+	if err := (pendingSecretRefs{cluster: cluster, connector: name, refs: refs, record: true}).commit(resp.StatusCode); err != nil {
+		log.Printf("secrets: failed to record placeholders for %s: %v", name, err)
+	}
+	if upstream, err := url.Parse(baseURL); err == nil {
+		configCache.invalidateConnector(r.Context(), upstream, name)
+	}
+
+	result := RestoreResult{RestorePoint: redactedRestorePoints(currentRedactionRules(), []RestorePoint{point})[0], Created: resp.StatusCode == http.StatusCreated}
+	if withOffsets {
+		target := joinURL(baseURL, "connectors", url.PathEscape(name), "offsets")
+		offsetsResp, err := sendConnectJSON(r.Context(), client, http.MethodPatch, target, point.Offsets)
+		if err != nil {
+			details["error"] = err.Error()
+			recordAudit(r, auditActionRestore, name, http.StatusBadGateway, details)
+			writeConnectUnavailable(w, err)
+			return
+		}
+		if offsetsResp.StatusCode >= 400 {
+			recordAudit(r, auditActionRestore, name, offsetsResp.StatusCode, details)
+			writeConnectError(w, offsetsResp)
+			return
+		}
+		offsetsResp.Body.Close()
+		result.OffsetsRestored = true
+	}
+	recordAudit(r, auditActionRestore, name, http.StatusOK, details)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// sendConnectJSON sends a JSON body to Kafka Connect. Transport failures are reported
+// as connectUnavailableError unless they are already one of the upstream errors.
+func sendConnectJSON(ctx context.Context, client *http.Client, method, target string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &connectUnavailableError{Err: err}
+	}
+	return resp, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestRestorePoints(t *testing.T, max int) *restorePointStore {
+	t.Helper()
+	original := restorePoints
+	store := newRestorePointStore(max, func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) })
+	restorePoints = store
+	t.Cleanup(func() { restorePoints = original })
+	return store
+}
+
+func TestRestorePointStoreKeepsNewest(t *testing.T) {
+	store := withTestRestorePoints(t, 2)
+	for _, value := range []string{"1", "2", "3"} {
+		store.add(RestorePoint{Cluster: "default", Connector: "orders", Config: map[string]string{"tasks.max": value}})
+	}
+	store.add(RestorePoint{Cluster: "default", Connector: "payments"})
+	store.add(RestorePoint{Cluster: "dr", Connector: "orders"})
+
+	points := store.list("default", "orders")
+	if len(points) != 2 || points[0].Config["tasks.max"] != "3" || points[1].ID != "2" {
+		t.Fatalf("expected the two newest restore points, got %+v", points)
+	}
+	if all := store.list("default", ""); len(all) != 3 || all[0].Connector != "payments" {
+		t.Fatalf("expected every connector of the cluster, got %+v", all)
+	}
+	if _, ok := store.get("default", "orders", "1"); ok {
+		t.Fatal("expected the oldest restore point to be dropped")
+	}
+}
+
+// fakeConnectorConnect serves the config, status and offsets of connectors it keeps and
+// records restarts.
+type fakeConnectorConnect struct {
+	mu        sync.Mutex
+	configs   map[string]map[string]string
+	states    map[string]string
+	offsets   string
+	patched   string
+	restarted []string
+}
+
+func (c *fakeConnectorConnect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "connectors" {
+		http.NotFound(w, r)
+		return
+	}
+	name, subresource := parts[1], strings.Join(parts[2:], "/")
+	config, exists := c.configs[name]
+	switch {
+	case r.Method == http.MethodPut && subresource == "config":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["tasks.max"] == "0" {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error_code": 400, "message": "Connector configuration is invalid"})
+			return
+		}
+		c.configs[name] = body
+		status := http.StatusOK
+		if !exists {
+			status, c.states[name] = http.StatusCreated, "RUNNING"
+		}
+		writeJSON(w, status, map[string]interface{}{"name": name, "config": body})
+	case !exists:
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"error_code": 404, "message": "Connector " + name + " not found"})
+	case r.Method == http.MethodDelete && subresource == "":
+		delete(c.configs, name)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && subresource == "config":
+		writeJSON(w, http.StatusOK, config)
+	case r.Method == http.MethodGet && subresource == "status":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"name": name, "connector": map[string]string{"state": c.states[name]}, "tasks": []interface{}{}})
+	case r.Method == http.MethodPost && subresource == "restart":
+		c.restarted = append(c.restarted, name+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && subresource == "offsets":
+		io.WriteString(w, c.offsets)
+	case r.Method == http.MethodPatch && subresource == "offsets":
+		body, _ := io.ReadAll(r.Body)
+		c.patched = string(body)
+		writeJSON(w, http.StatusOK, map[string]string{"message": "The offsets for this connector have been altered successfully"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestProxyHandlerSnapshotsBeforeDeleteAndRestores(t *testing.T) {
+	store := withTestRestorePoints(t, 10)
+	logger := withTestAuditLog(t, 100)
+	connect := &fakeConnectorConnect{
+		configs: map[string]map[string]string{"orders": {"connector.class": "FileStreamSource", "db.password": "hunter2", "tasks.max": "2"}},
+		states:  map[string]string{"orders": "RUNNING"},
+		offsets: `{"offsets":[{"partition":{"file":"orders.txt"},"offset":{"position":42}}]}`,
+	}
+	server := httptest.NewServer(connect)
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	proxy := func(method, path, subpath, body string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(method, path, strings.NewReader(body)), map[string]string{"cluster": "default", "path": subpath})
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		return rr
+	}
+
+	// A rejected update changes nothing and leaves no restore point.
+	if rr := proxy(http.MethodPut, "/api/default/connectors/orders/config", "orders/config", `{"connector.class":"FileStreamSource","tasks.max":"0"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected the update to be rejected, got %d", rr.Code)
+	}
+	if rr := proxy(http.MethodDelete, "/api/default/connectors/orders", "orders", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected the delete to pass, got %d", rr.Code)
+	}
+	points := store.list("default", "orders")
+	if len(points) != 1 || points[0].Operation != auditActionDelete || points[0].Config["db.password"] != "hunter2" || !strings.Contains(string(points[0].Offsets), `"position":42`) {
+		t.Fatalf("expected one snapshot taken before the delete, got %+v", points)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders/restore-points", nil), map[string]string{"cluster": "default", "name": "orders"})
+	rr := httptest.NewRecorder()
+	restorePointsHandler(rr, req)
+	if strings.Contains(rr.Body.String(), "hunter2") || !strings.Contains(rr.Body.String(), `"operation":"DELETE"`) {
+		t.Fatalf("expected a redacted listing, got %s", rr.Body.String())
+	}
+
+	restore := func(id, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/connectors/orders/restore-points/"+id+"/restore"+query, nil)
+		rr := httptest.NewRecorder()
+		restoreConnectorHandler(rr, mux.SetURLVars(req, map[string]string{"cluster": "default", "name": "orders", "id": id}))
+		return rr
+	}
+	if rr := restore("99", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown restore point to be 404, got %d", rr.Code)
+	}
+	if rr := restore(points[0].ID, "?offsets=true"); rr.Code != http.StatusConflict {
+		t.Fatalf("expected offsets of a deleted connector to be refused, got %d", rr.Code)
+	}
+
+	rr = restore(points[0].ID, "")
+	var result RestoreResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK || !result.Created || result.OffsetsRestored {
+		t.Fatalf("expected the connector to be recreated, got %d %s", rr.Code, rr.Body.String())
+	}
+	if connect.configs["orders"]["db.password"] != "hunter2" || connect.configs["orders"]["name"] != "orders" {
+		t.Fatalf("expected the saved config to be written back, got %v", connect.configs["orders"])
+	}
+	if entries := logger.Query(AuditFilter{Action: auditActionRestore}); len(entries) != 1 || entries[0].ConnectorName != "orders" {
+		t.Fatalf("expected the restore to be audited, got %+v", entries)
+	}
+	if len(store.list("default", "orders")) != 1 {
+		t.Fatal("expected no snapshot of a connector that did not exist")
+	}
+
+	// Restoring offsets needs a stopped connector; the restore itself is snapshotted.
+	if rr := restore(points[0].ID, "?offsets=true"); rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "connector_not_stopped") {
+		t.Fatalf("expected a running connector to be refused, got %d %s", rr.Code, rr.Body.String())
+	}
+	connect.states["orders"] = "STOPPED"
+	rr = restore(points[0].ID, "?offsets=true")
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK || !result.OffsetsRestored || result.Created {
+		t.Fatalf("expected the offsets to be restored, got %d %s", rr.Code, rr.Body.String())
+	}
+	if !bytes.Contains([]byte(connect.patched), []byte(`"position":42`)) {
+		t.Fatalf("expected the saved offsets to be sent, got %s", connect.patched)
+	}
+	if latest := store.list("default", "orders"); len(latest) != 2 || latest[0].Operation != auditActionRestore {
+		t.Fatalf("expected the restore to leave a restore point, got %+v", latest)
+	}
+}
+
+func TestLoadRestorePointsMax(t *testing.T) {
+	original := restorePointsMax
+	t.Cleanup(func() { restorePointsMax = original })
+
+	restorePointsMax = "0"
+	if n, err := loadRestorePointsMax(); n != 0 || err != nil {
+		t.Fatalf("expected 0 to turn snapshots off, got %d %v", n, err)
+	}
+	restorePointsMax = "-1"
+	if _, err := loadRestorePointsMax(); err == nil {
+		t.Fatal("expected a negative limit to be refused")
+	}
+}
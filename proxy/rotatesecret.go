@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const auditActionRotateSecret = "ROTATE_SECRET"
+
+// secretRotationRequest is the body of POST /api/{cluster}/connectors/{name}/rotate-secret.
+// Secrets maps sensitive config keys to their new values, which may be secret
+// placeholders such as ${vault:...}.
+type secretRotationRequest struct {
+	Secrets      map[string]string `json:"secrets"`
+	Restart      bool              `json:"restart,omitempty"`
+	IncludeTasks bool              `json:"includeTasks,omitempty"`
+}
+
+// SecretRotationResult is returned by POST /api/{cluster}/connectors/{name}/rotate-secret.
+// RestartError is set when the config was rotated but the restart failed.
+type SecretRotationResult struct {
+	Connector     string   `json:"connector"`
+	Rotated       []string `json:"rotated"`
+	Restarted     bool     `json:"restarted"`
+	RestartStatus int      `json:"restartStatus,omitempty"`
+	RestartError  string   `json:"restartError,omitempty"`
+}
+
+// validate checks that every key is sensitive, already set on the connector and given a
+// real value, and returns the keys in sorted order.
+func (req secretRotationRequest) validate(rules redactionRules, live map[string]string) ([]string, error) {
+	if len(req.Secrets) == 0 {
+		return nil, fmt.Errorf("secrets must map at least one config key to its new value")
+	}
+	keys := make([]string, 0, len(req.Secrets))
+	var notSensitive, unknown, empty []string
+	for key, value := range req.Secrets {
+		keys = append(keys, key)
+		switch {
+		case !rules.isSensitive(key):
+			notSensitive = append(notSensitive, key)
+		case func() bool { _, ok := live[key]; return !ok }():
+			unknown = append(unknown, key)
+		case strings.TrimSpace(value) == "" || value == rules.placeholder:
+			empty = append(empty, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, list := range [][]string{notSensitive, unknown, empty} {
+		sort.Strings(list)
+	}
+	switch {
+	case len(notSensitive) > 0:
+		return nil, fmt.Errorf("only sensitive keys can be rotated; use PATCH .../config for %s", strings.Join(notSensitive, ", "))
+	case len(unknown) > 0:
+		return nil, fmt.Errorf("the connector config has no %s", strings.Join(unknown, ", "))
+	case len(empty) > 0:
+		return nil, fmt.Errorf("new values must not be empty or the redaction placeholder: %s", strings.Join(empty, ", "))
+	}
+	return keys, nil
+}
+
+// rotateSecretHandler replaces the values of sensitive config keys and leaves every
+// other key as it is, optionally restarting the connector so it picks them up. A
+// restore point is taken first, and one ROTATE_SECRET audit entry lists the rotated
+// keys without their values.
+func rotateSecretHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+
+	var req secretRotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", `request body must be {"secrets": {"key": "new value"}, "restart": true}`)
+		return
+	}
+
+	baseURL := connectURLFor(cluster)
+	live, err := fetchConnectorConfig(r.Context(), connectClientFor(cluster, routeRead), baseURL, name)
+	if err != nil {
+		writeDryRunError(w, err, name)
+		return
+	}
+	keys, err := req.validate(currentRedactionRules(), live)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_rotation", err.Error())
+		return
+	}
+
+	// Keep the placeholders of the other keys so they are resolved again, not pinned
+	// to their current values.
+	secretRefs.restoreStrings(cluster, name, live)
+	config := make(map[string]interface{}, len(live)+1)
+	for key, value := range live {
+		config[key] = value
+	}
+	for key, value := range req.Secrets {
+		config[key] = value
+	}
+	config["name"] = name
+	if !admitConnectorConfig(w, r, name, config) {
+		return
+	}
+
+	client := connectClientFor(cluster, routeWrite)
+	pending := snapshotConnector(r.Context(), connectClientFor(cluster, routeRead), baseURL, cluster, name, auditActionRotateSecret, requestUser(r))
+	refs, err := connectorSecrets.resolveConfig(r.Context(), config)
+	if err != nil {
+		writeSecretResolutionError(w, err)
+		return
+	}
+	body, err := json.Marshal(config)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "encode_failed", err.Error())
+		return
+	}
+
+	details := map[string]interface{}{"keys": keys, "restart": req.Restart}
+	resp, err := sendConnectJSON(r.Context(), client, http.MethodPut, joinURL(baseURL, "connectors", url.PathEscape(name), "config"), body)
+	if err != nil {
+		details["error"] = err.Error()
+		recordAudit(r, auditActionRotateSecret, name, http.StatusBadGateway, details)
+		writeConnectUnavailable(w, err)
+		return
+	}
+	if resp.StatusCode >= 400 {
+		recordAudit(r, auditActionRotateSecret, name, resp.StatusCode, details)
+		writeConnectError(w, resp)
+		return
+	}
+	resp.Body.Close()
+	pending.commit(resp.StatusCode)
+	if err := (pendingSecretRefs{cluster: cluster, connector: name, refs: refs, record: true}).commit(resp.StatusCode); err != nil {
+		log.Printf("secrets: failed to record placeholders for %s: %v", name, err)
+	}
+	if upstream, err := url.Parse(baseURL); err == nil {
+		configCache.invalidateConnector(r.Context(), upstream, name)
+	}
+
+	result := SecretRotationResult{Connector: name, Rotated: keys}
+	if req.Restart {
+		status, err := restartConnector(r.Context(), client, baseURL, name, req.IncludeTasks, false)
+		result.RestartStatus = status
+		if err != nil {
+			result.RestartError = err.Error()
+			details["restartError"] = err.Error()
+		} else {
+			result.Restarted = true
+		}
+	}
+	recordAudit(r, auditActionRotateSecret, name, http.StatusOK, details)
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRotateSecretHandler(t *testing.T) {
+	store := withTestRestorePoints(t, 10)
+	logger := withTestAuditLog(t, 100)
+	connect := &fakeConnectorConnect{
+		configs: map[string]map[string]string{"orders": {"connector.class": "FileStreamSource", "db.password": "hunter2", "tasks.max": "2"}},
+		states:  map[string]string{"orders": "RUNNING"},
+	}
+	server := httptest.NewServer(connect)
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	rotate := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/connectors/"+name+"/rotate-secret", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		rotateSecretHandler(rr, mux.SetURLVars(req, map[string]string{"cluster": "default", "name": name}))
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"secrets": {}}`,
+		`{"secrets": {"tasks.max": "4"}}`,
+		`{"secrets": {"api.password": "s3cret"}}`,
+		`{"secrets": {"db.password": ""}}`,
+		`{"secrets": {"db.password": "` + currentRedactionRules().placeholder + `"}}`,
+	} {
+		if rr := rotate("orders", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d %s", body, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := rotate("missing", `{"secrets": {"db.password": "s3cret"}}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown connector, got %d", rr.Code)
+	}
+	if connect.configs["orders"]["db.password"] != "hunter2" || len(store.list("default", "orders")) != 0 {
+		t.Fatal("expected rejected rotations to change nothing")
+	}
+
+	rr := rotate("orders", `{"secrets": {"db.password": "correct-horse"}, "restart": true, "includeTasks": true}`)
+	var result SecretRotationResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK || !result.Restarted || strings.Join(result.Rotated, ",") != "db.password" {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if config := connect.configs["orders"]; config["db.password"] != "correct-horse" || config["tasks.max"] != "2" || config["connector.class"] != "FileStreamSource" {
+		t.Fatalf("expected only the password to change, got %v", config)
+	}
+	if len(connect.restarted) != 1 || !strings.Contains(connect.restarted[0], "includeTasks=true") {
+		t.Fatalf("expected one restart including tasks, got %v", connect.restarted)
+	}
+	if points := store.list("default", "orders"); len(points) != 1 || points[0].Operation != auditActionRotateSecret || points[0].Config["db.password"] != "hunter2" {
+		t.Fatalf("expected a restore point of the old config, got %+v", points)
+	}
+	entries := logger.Query(AuditFilter{Action: auditActionRotateSecret})
+	if len(entries) != 1 || entries[0].ConnectorName != "orders" {
+		t.Fatalf("expected one audit entry, got %+v", entries)
+	}
+	if details, _ := json.Marshal(entries[0]); strings.Contains(string(details), "correct-horse") || !strings.Contains(string(details), "db.password") {
+		t.Fatalf("expected the audit entry to list the key without its value, got %s", details)
+	}
+}
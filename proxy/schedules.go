@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// Maintenance windows are usually given in local time; the runtime image has no
+	// zoneinfo, so embed it.
+	_ "time/tzdata"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	schedulesFile = "connector-schedules.json"
+
+	auditActionCreateSchedule = "CREATE_SCHEDULE"
+	auditActionDeleteSchedule = "DELETE_SCHEDULE"
+
+	// schedulerUser is recorded in the audit log for pauses and resumes done by windows.
+	schedulerUser = "scheduler"
+
+	maxScheduleDuration = 7 * 24 * time.Hour
+)
+
+var (
+	schedulerInterval = getEnv("SCHEDULER_INTERVAL", "30s")
+
+	connectorSchedules = newConnectorScheduler(time.Now)
+)
+
+// cronSpec is a parsed five-field cron expression: minute, hour, day of month, month and
+// day of week. Each field is a bit set of allowed values.
+type cronSpec struct {
+	minutes, hours, days, months, weekdays uint64
+	// anyDay and anyWeekday record a "*" field; when both day fields are restricted a
+	// time matches if either does, as in cron.
+	anyDay, anyWeekday bool
+}
+
+// parseCronField parses a comma-separated list of *, n, a-b, with an optional /step.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			a, errA := strconv.Atoi(bounds[0])
+			b, errB := strconv.Atoi(bounds[1])
+			if errA != nil || errB != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+			lo, hi = a, b
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseCron parses a standard five-field cron expression. Day of week accepts 0-7 with
+// both 0 and 7 meaning Sunday.
+func parseCron(expr string) (cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSpec{}, fmt.Errorf("cron expression %q must have 5 fields (minute hour day month weekday)", expr)
+	}
+
+	var spec cronSpec
+	var err error
+	if spec.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSpec{}, fmt.Errorf("minute: %w", err)
+	}
+	if spec.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSpec{}, fmt.Errorf("hour: %w", err)
+	}
+	if spec.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSpec{}, fmt.Errorf("day of month: %w", err)
+	}
+	if spec.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSpec{}, fmt.Errorf("month: %w", err)
+	}
+	if spec.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSpec{}, fmt.Errorf("day of week: %w", err)
+	}
+	if spec.weekdays&(1<<7) != 0 {
+		spec.weekdays |= 1
+	}
+	spec.anyDay, spec.anyWeekday = fields[2] == "*", fields[4] == "*"
+	return spec, nil
+}
+
+func (c cronSpec) dayMatches(t time.Time) bool {
+	day := c.days&(1<<uint(t.Day())) != 0
+	weekday := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if !c.anyDay && !c.anyWeekday {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+func (c cronSpec) matches(t time.Time) bool {
+	return c.minutes&(1<<uint(t.Minute())) != 0 &&
+		c.hours&(1<<uint(t.Hour())) != 0 &&
+		c.months&(1<<uint(t.Month())) != 0 &&
+		c.dayMatches(t)
+}
+
+// next returns the first matching minute after t, searching up to five years ahead.
+func (c cronSpec) next(t time.Time) (time.Time, bool) {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// windowStart returns the start of the window of length d that contains now, if any.
+func (c cronSpec) windowStart(now time.Time, d time.Duration) (time.Time, bool) {
+	earliest := now.Add(-d)
+	for t := now.Truncate(time.Minute); t.After(earliest); t = t.Add(-time.Minute) {
+		if c.matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ConnectorSchedule is a recurring maintenance window during which the scheduler keeps
+// a connector paused. The window opens at each Cron match in Timezone and lasts
+// Duration.
+type ConnectorSchedule struct {
+	ID          string    `json:"id"`
+	Cluster     string    `json:"cluster"`
+	Connector   string    `json:"connector"`
+	Cron        string    `json:"cron"`
+	Duration    string    `json:"duration"`
+	Timezone    string    `json:"timezone"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CreatedBy   string    `json:"createdBy"`
+
+	// Active is set while a window is open. PausedByScheduler records whether the
+	// scheduler paused the connector, so one that was already paused is not resumed.
+	Active            bool       `json:"active"`
+	PausedByScheduler bool       `json:"pausedByScheduler"`
+	WindowEnd         *time.Time `json:"windowEnd,omitempty"`
+	NextWindow        *time.Time `json:"nextWindow,omitempty"`
+	LastError         string     `json:"lastError,omitempty"`
+}
+
+// scheduleRequest is the body of POST /api/{cluster}/connectors/{name}/schedules.
+type scheduleRequest struct {
+	Cron        string `json:"cron"`
+	Duration    string `json:"duration"`
+	Timezone    string `json:"timezone,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// compiledSchedule is the parsed form of a schedule's cron, duration and timezone.
+type compiledSchedule struct {
+	spec     cronSpec
+	duration time.Duration
+	location *time.Location
+}
+
+func compileSchedule(cron, duration, timezone string) (compiledSchedule, error) {
+	spec, err := parseCron(cron)
+	if err != nil {
+		return compiledSchedule{}, err
+	}
+	d, err := parseWindow(duration, 0)
+	if err != nil || d < time.Minute || d > maxScheduleDuration {
+		return compiledSchedule{}, fmt.Errorf("duration must be between 1m and 7d, got %q", duration)
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return compiledSchedule{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	return compiledSchedule{spec: spec, duration: d, location: loc}, nil
+}
+
+// normalize trims the request and defaults the timezone to UTC.
+func (req scheduleRequest) normalize() scheduleRequest {
+	req.Cron, req.Duration = strings.TrimSpace(req.Cron), strings.TrimSpace(req.Duration)
+	req.Timezone, req.Description = strings.TrimSpace(req.Timezone), strings.TrimSpace(req.Description)
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	return req
+}
+
+func (req scheduleRequest) validate() error {
+	_, err := compileSchedule(req.Cron, req.Duration, req.Timezone)
+	return err
+}
+
+func (s ConnectorSchedule) compile() (compiledSchedule, error) {
+	return compileSchedule(s.Cron, s.Duration, s.Timezone)
+}
+
+// scheduleDocument is the persisted form of the scheduler.
+type scheduleDocument struct {
+	NextID    int64               `json:"nextId"`
+	Schedules []ConnectorSchedule `json:"schedules"`
+}
+
+// connectorScheduler stores maintenance windows and applies them on every tick.
+type connectorScheduler struct {
+	mu        sync.Mutex
+	nextID    int64
+	schedules []ConnectorSchedule
+	now       func() time.Time
+	client    *http.Client
+}
+
+func newConnectorScheduler(now func() time.Time) *connectorScheduler {
+	return &connectorScheduler{now: now, client: newConnectClient(30 * time.Second)}
+}
+
+// load replaces the schedules with the persisted ones, if any.
+func (s *connectorScheduler) load() error {
+	var doc scheduleDocument
+	if err := loadJSON(schedulesFile, &doc); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID, s.schedules = doc.NextID, doc.Schedules
+	return nil
+}
+
+// saveLocked persists the schedules. Callers must hold s.mu.
+func (s *connectorScheduler) saveLocked() error {
+	return saveJSON(schedulesFile, scheduleDocument{NextID: s.nextID, Schedules: s.schedules})
+}
+
+// withNextWindow fills in when the next window of a schedule opens.
+func (s *connectorScheduler) withNextWindow(schedule ConnectorSchedule) ConnectorSchedule {
+	compiled, err := schedule.compile()
+	if err != nil {
+		return schedule
+	}
+	if next, ok := compiled.spec.next(s.now().In(compiled.location)); ok {
+		next = next.UTC()
+		schedule.NextWindow = &next
+	}
+	return schedule
+}
+
+// list returns the schedules of a connector.
+func (s *connectorScheduler) list(cluster, connector string) []ConnectorSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []ConnectorSchedule{}
+	for _, schedule := range s.schedules {
+		if schedule.Cluster == cluster && schedule.Connector == connector {
+			result = append(result, s.withNextWindow(schedule))
+		}
+	}
+	return result
+}
+
+// add stores a new schedule built from a normalized, validated request.
+func (s *connectorScheduler) add(cluster, connector string, req scheduleRequest, user string) (ConnectorSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	schedule := ConnectorSchedule{
+		ID:          strconv.FormatInt(s.nextID, 10),
+		Cluster:     cluster,
+		Connector:   connector,
+		Cron:        req.Cron,
+		Duration:    req.Duration,
+		Timezone:    req.Timezone,
+		Description: req.Description,
+		CreatedAt:   s.now().UTC(),
+		CreatedBy:   user,
+	}
+	s.schedules = append(s.schedules, schedule)
+	return s.withNextWindow(schedule), s.saveLocked()
+}
+
+// remove deletes a schedule. A connector paused by an open window stays paused.
+func (s *connectorScheduler) remove(cluster, connector, id string) (ConnectorSchedule, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, schedule := range s.schedules {
+		if schedule.ID == id && schedule.Cluster == cluster && schedule.Connector == connector {
+			s.schedules = append(s.schedules[:i], s.schedules[i+1:]...)
+			return schedule, true, s.saveLocked()
+		}
+	}
+	return ConnectorSchedule{}, false, nil
+}
+
+// audit records a pause or resume performed by the scheduler.
+func (s *connectorScheduler) audit(schedule *ConnectorSchedule, action string, err error) {
+	entry := AuditLogEntry{
+		Timestamp:     s.now().UTC(),
+		User:          schedulerUser,
+		Cluster:       schedule.Cluster,
+		Action:        action,
+		ConnectorName: schedule.Connector,
+		Status:        auditStatusSuccess,
+		HTTPStatus:    http.StatusAccepted,
+		Details:       map[string]interface{}{"automatic": true, "scheduleId": schedule.ID, "cron": schedule.Cron, "duration": schedule.Duration},
+	}
+	if err != nil {
+		schedule.LastError = err.Error()
+		entry.Status, entry.HTTPStatus = auditStatusFailure, http.StatusBadGateway
+		entry.Details["error"] = err.Error()
+		log.Printf("scheduler: %s of %s/%s failed: %v", strings.ToLower(action), schedule.Cluster, schedule.Connector, err)
+	} else {
+		schedule.LastError = ""
+	}
+	logAudit(entry)
+}
+
+// openWindow pauses the connector when a window starts. A connector that is already
+// paused or stopped is left alone and will not be resumed when the window closes.
+// Failures leave the window closed so the next tick retries.
+func (s *connectorScheduler) openWindow(ctx context.Context, schedule *ConnectorSchedule, end time.Time) {
+	baseURL := connectURLFor(schedule.Cluster)
+	status, err := fetchConnectorStatus(ctx, s.client, baseURL, schedule.Connector)
+	if err != nil {
+		schedule.LastError = err.Error()
+		return
+	}
+
+	end = end.UTC()
+	if state := normalizeState(status.Connector.State); state == "paused" || state == "stopped" {
+		schedule.Active, schedule.PausedByScheduler, schedule.WindowEnd, schedule.LastError = true, false, &end, ""
+		return
+	}
+
+	err = sendConnectRequest(ctx, s.client, http.MethodPut, joinURL(baseURL, "connectors", url.PathEscape(schedule.Connector), "pause"), nil)
+	s.audit(schedule, auditActionPause, err)
+	if err == nil {
+		schedule.Active, schedule.PausedByScheduler, schedule.WindowEnd = true, true, &end
+	}
+}
+
+// closeWindow resumes the connector when its window ends, unless another open window of
+// the same connector takes over. Failed resumes keep the window open and are retried.
+func (s *connectorScheduler) closeWindow(ctx context.Context, schedule *ConnectorSchedule, all []ConnectorSchedule) {
+	for i := range all {
+		other := &all[i]
+		if other.ID != schedule.ID && other.Active && other.Cluster == schedule.Cluster && other.Connector == schedule.Connector {
+			other.PausedByScheduler = other.PausedByScheduler || schedule.PausedByScheduler
+			schedule.Active, schedule.PausedByScheduler, schedule.WindowEnd = false, false, nil
+			return
+		}
+	}
+
+	if schedule.PausedByScheduler {
+		err := sendConnectRequest(ctx, s.client, http.MethodPut, joinURL(connectURLFor(schedule.Cluster), "connectors", url.PathEscape(schedule.Connector), "resume"), nil)
+		s.audit(schedule, auditActionResume, err)
+		if err != nil {
+			return
+		}
+	}
+	schedule.Active, schedule.PausedByScheduler, schedule.WindowEnd = false, false, nil
+}
+
+// tick opens and closes windows due at the current time. Standby clusters are skipped;
+// their connectors are owned by the standby sync. Clusters in maintenance mode are
+// skipped too, and catch up on the first tick after it ends.
+func (s *connectorScheduler) tick(ctx context.Context) {
+	s.mu.Lock()
+	snapshot := append([]ConnectorSchedule(nil), s.schedules...)
+	s.mu.Unlock()
+
+	now := s.now()
+	for i := range snapshot {
+		schedule := &snapshot[i]
+		if standbys.inStandby(schedule.Cluster) || maintenance.active(schedule.Cluster) {
+			continue
+		}
+		compiled, err := schedule.compile()
+		if err != nil {
+			continue
+		}
+
+		start, inWindow := compiled.spec.windowStart(now.In(compiled.location), compiled.duration)
+		switch {
+		case inWindow && !schedule.Active:
+			s.openWindow(ctx, schedule, start.Add(compiled.duration))
+		case !inWindow && schedule.Active:
+			s.closeWindow(ctx, schedule, snapshot)
+		}
+	}
+
+	// Schedules created or deleted while the tick ran are kept as they are.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated := make(map[string]ConnectorSchedule, len(snapshot))
+	for _, schedule := range snapshot {
+		updated[schedule.ID] = schedule
+	}
+	changed := false
+	for i, schedule := range s.schedules {
+		if next, ok := updated[schedule.ID]; ok && !sameScheduleState(schedule, next) {
+			s.schedules[i] = next
+			changed = true
+		}
+	}
+	if changed {
+		if err := s.saveLocked(); err != nil {
+			log.Printf("scheduler: failed to persist schedules: %v", err)
+		}
+	}
+}
+
+func sameScheduleState(a, b ConnectorSchedule) bool {
+	sameEnd := (a.WindowEnd == nil) == (b.WindowEnd == nil) && (a.WindowEnd == nil || a.WindowEnd.Equal(*b.WindowEnd))
+	return a.Active == b.Active && a.PausedByScheduler == b.PausedByScheduler && a.LastError == b.LastError && sameEnd
+}
+
+func (s *connectorScheduler) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		s.tick(ctx)
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// connectorSchedulesHandler lists (GET) or creates (POST) the maintenance windows of a
+// connector.
+func connectorSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, connectorSchedules.list(cluster, name))
+		return
+	}
+
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON schedule object")
+		return
+	}
+	req = req.normalize()
+	if err := req.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_schedule", err.Error())
+		return
+	}
+	schedule, err := connectorSchedules.add(cluster, name, req, requestUser(r))
+	if err != nil {
+		log.Printf("scheduler: failed to persist schedule for %s: %v", name, err)
+		writeJSONError(w, http.StatusInternalServerError, "schedule_store_failed", "failed to persist schedule")
+		return
+	}
+	recordAudit(r, auditActionCreateSchedule, name, http.StatusCreated, map[string]interface{}{
+		"scheduleId": schedule.ID, "cron": schedule.Cron, "duration": schedule.Duration, "timezone": schedule.Timezone,
+	})
+	writeJSON(w, http.StatusCreated, schedule)
+}
+
+// connectorScheduleHandler deletes a maintenance window.
+func connectorScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name, id := vars["cluster"], vars["name"], vars["id"]
+
+	schedule, ok, err := connectorSchedules.remove(cluster, name, id)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "schedule_not_found", fmt.Sprintf("connector %s has no schedule %s", name, id))
+		return
+	}
+	if err != nil {
+		log.Printf("scheduler: failed to persist schedules after deleting %s: %v", id, err)
+	}
+	recordAudit(r, auditActionDeleteSchedule, name, http.StatusOK, map[string]interface{}{"scheduleId": id, "cron": schedule.Cron, "active": schedule.Active})
+	writeJSON(w, http.StatusOK, schedule)
+}
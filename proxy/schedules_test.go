@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"0 2 * * *", "*/15 9-17 * * 1-5", "30 1 1,15 * 7", "0 0 * 1-12/3 *"} {
+		if _, err := parseCron(expr); err != nil {
+			t.Fatalf("parseCron(%q): %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "0 2 * *", "60 2 * * *", "0 24 * * *", "0 2 0 * *", "0 2 * * 8", "0 5-2 * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Fatalf("expected parseCron(%q) to fail", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"0 2 * * *", time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC), time.Date(2024, 5, 2, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2024, 5, 1, 1, 59, 30, 0, time.UTC), time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)},
+		// Friday evening to Monday morning.
+		{"*/15 9-17 * * 1-5", time.Date(2024, 5, 3, 17, 50, 0, 0, time.UTC), time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)},
+		// Restricted day of month and day of week match either.
+		{"0 0 1 * 1", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		spec, _ := parseCron(tt.expr)
+		got, ok := spec.next(tt.after)
+		if !ok || !got.Equal(tt.want) {
+			t.Fatalf("next(%q, %s) = %s, %v; want %s", tt.expr, tt.after, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCronWindowStart(t *testing.T) {
+	spec, _ := parseCron("0 2 * * *")
+	start, ok := spec.windowStart(time.Date(2024, 5, 1, 2, 59, 0, 0, time.UTC), time.Hour)
+	if !ok || !start.Equal(time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the 02:00 window, got %s, %v", start, ok)
+	}
+	if _, ok := spec.windowStart(time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC), time.Hour); ok {
+		t.Fatalf("the window must be closed at 03:00")
+	}
+	if _, ok := spec.windowStart(time.Date(2024, 5, 1, 1, 59, 0, 0, time.UTC), time.Hour); ok {
+		t.Fatalf("the window must not be open before 02:00")
+	}
+}
+
+// fakePausableConnect serves status, pause and resume for connectors and counts calls.
+type fakePausableConnect struct {
+	mu     sync.Mutex
+	states map[string]string
+	calls  []string
+}
+
+func (f *fakePausableConnect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "connectors" {
+		http.NotFound(w, r)
+		return
+	}
+	name, action := parts[1], parts[2]
+	state, ok := f.states[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && action == "status":
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "connector": map[string]string{"state": state}, "tasks": []interface{}{}})
+	case r.Method == http.MethodPut && action == "pause":
+		f.states[name] = "PAUSED"
+		f.calls = append(f.calls, "pause "+name)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && action == "resume":
+		f.states[name] = "RUNNING"
+		f.calls = append(f.calls, "resume "+name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakePausableConnect) callLog() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.calls, ", ")
+}
+
+func TestSchedulerPausesAndResumesDuringWindow(t *testing.T) {
+	logger := withTestAuditLog(t, 100)
+	connect := &fakePausableConnect{states: map[string]string{"orders-sink": "RUNNING", "manual": "PAUSED"}}
+	server := httptest.NewServer(connect)
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	now := time.Date(2024, 5, 1, 1, 59, 0, 0, time.UTC)
+	scheduler := newConnectorScheduler(func() time.Time { return now })
+	req := scheduleRequest{Cron: "0 2 * * *", Duration: "1h"}.normalize()
+	for _, name := range []string{"orders-sink", "manual"} {
+		if _, err := scheduler.add("default", name, req, "alice"); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	tickAt := func(at time.Time) {
+		now = at
+		scheduler.tick(context.Background())
+	}
+
+	tickAt(time.Date(2024, 5, 1, 1, 59, 0, 0, time.UTC))
+	if calls := connect.callLog(); calls != "" {
+		t.Fatalf("nothing should happen before the window, got %s", calls)
+	}
+
+	tickAt(time.Date(2024, 5, 1, 2, 0, 30, 0, time.UTC))
+	tickAt(time.Date(2024, 5, 1, 2, 30, 0, 0, time.UTC))
+	if calls := connect.callLog(); calls != "pause orders-sink" {
+		t.Fatalf("expected a single pause, got %s", calls)
+	}
+	schedules := scheduler.list("default", "orders-sink")
+	if !schedules[0].Active || !schedules[0].PausedByScheduler || schedules[0].WindowEnd == nil || !schedules[0].WindowEnd.Equal(time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected schedule state %+v", schedules[0])
+	}
+
+	tickAt(time.Date(2024, 5, 1, 3, 0, 30, 0, time.UTC))
+	if calls := connect.callLog(); calls != "pause orders-sink, resume orders-sink" {
+		t.Fatalf("expected the connector to be resumed, got %s", calls)
+	}
+	if connect.states["manual"] != "PAUSED" {
+		t.Fatalf("a connector paused before the window must stay paused")
+	}
+	schedules = scheduler.list("default", "orders-sink")
+	if schedules[0].Active || schedules[0].NextWindow == nil || !schedules[0].NextWindow.Equal(time.Date(2024, 5, 2, 2, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected schedule after the window %+v", schedules[0])
+	}
+
+	entries := logger.Query(AuditFilter{})
+	if len(entries) != 2 || entries[0].Action != auditActionResume || entries[1].Action != auditActionPause || entries[0].User != schedulerUser {
+		t.Fatalf("unexpected audit entries %+v", entries)
+	}
+}
+
+func TestSchedulerOverlappingWindowsKeepConnectorPaused(t *testing.T) {
+	withTestAuditLog(t, 100)
+	connect := &fakePausableConnect{states: map[string]string{"orders-sink": "RUNNING"}}
+	server := httptest.NewServer(connect)
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	now := time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)
+	scheduler := newConnectorScheduler(func() time.Time { return now })
+	scheduler.add("default", "orders-sink", scheduleRequest{Cron: "0 2 * * *", Duration: "1h"}.normalize(), "alice")
+	scheduler.add("default", "orders-sink", scheduleRequest{Cron: "30 2 * * *", Duration: "1h"}.normalize(), "alice")
+
+	for _, at := range []time.Time{
+		time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC),
+		time.Date(2024, 5, 1, 2, 30, 0, 0, time.UTC),
+		time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC),
+	} {
+		now = at
+		scheduler.tick(context.Background())
+	}
+	if calls := connect.callLog(); calls != "pause orders-sink" {
+		t.Fatalf("the second window must keep the connector paused, got %s", calls)
+	}
+
+	now = time.Date(2024, 5, 1, 3, 30, 0, 0, time.UTC)
+	scheduler.tick(context.Background())
+	if calls := connect.callLog(); calls != "pause orders-sink, resume orders-sink" {
+		t.Fatalf("expected a resume when the last window closes, got %s", calls)
+	}
+}
+
+func TestConnectorSchedulesHandler(t *testing.T) {
+	logger := withTestAuditLog(t, 100)
+	original := connectorSchedules
+	connectorSchedules = newConnectorScheduler(func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) })
+	t.Cleanup(func() { connectorSchedules = original })
+
+	do := func(method, path, body string, vars map[string]string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(method, path, strings.NewReader(body)), vars)
+		rr := httptest.NewRecorder()
+		if vars["id"] != "" {
+			connectorScheduleHandler(rr, req)
+		} else {
+			connectorSchedulesHandler(rr, req)
+		}
+		return rr
+	}
+	vars := map[string]string{"cluster": "default", "name": "orders-sink"}
+	path := "/api/default/connectors/orders-sink/schedules"
+
+	for _, body := range []string{`{"cron":"0 2 * *","duration":"1h"}`, `{"cron":"0 2 * * *","duration":"8d"}`, `{"cron":"0 2 * * *","duration":"1h","timezone":"Mars/Olympus"}`} {
+		if rr := do(http.MethodPost, path, body, vars); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, rr.Code)
+		}
+	}
+
+	rr := do(http.MethodPost, path, `{"cron":"0 2 * * *","duration":"1h","timezone":"Europe/Berlin"}`, vars)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created ConnectorSchedule
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	// 02:00 in Berlin (CEST) is 00:00 UTC.
+	if created.ID == "" || created.NextWindow == nil || !created.NextWindow.Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected schedule %+v", created)
+	}
+
+	rr = do(http.MethodGet, path, "", vars)
+	var listed []ConnectorSchedule
+	json.Unmarshal(rr.Body.Bytes(), &listed)
+	if len(listed) != 1 || listed[0].ID != created.ID {
+		t.Fatalf("unexpected list %s", rr.Body.String())
+	}
+
+	idVars := map[string]string{"cluster": "default", "name": "orders-sink", "id": created.ID}
+	if rr := do(http.MethodDelete, path+"/"+created.ID, "", idVars); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, path+"/"+created.ID, "", idVars); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted schedule, got %d", rr.Code)
+	}
+
+	entries := logger.Query(AuditFilter{})
+	if len(entries) != 2 || entries[0].Action != auditActionDeleteSchedule || entries[1].Action != auditActionCreateSchedule {
+		t.Fatalf("unexpected audit entries %+v", entries)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+var (
+	// schemaRegistryURL enables Avro/Protobuf/JSON Schema decoding of schema-registry
+	// framed records. Credentials may be given as URL user info.
+	schemaRegistryURL = getEnv("SCHEMA_REGISTRY_URL", "")
+
+	schemaRegistry = newSchemaRegistryClient(schemaRegistryURL)
+)
+
+// registeredSchema is a schema fetched from the registry by ID.
+type registeredSchema struct {
+	schemaType string // AVRO, PROTOBUF or JSON
+	avro       *goavro.Codec
+}
+
+// schemaRegistryClient resolves and caches schemas by ID. Schema IDs are immutable, so
+// cached entries never expire.
+type schemaRegistryClient struct {
+	baseURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	schemas map[int]*registeredSchema
+}
+
+func newSchemaRegistryClient(baseURL string) *schemaRegistryClient {
+	return &schemaRegistryClient{
+		baseURL: baseURL,
+		client:  newUpstreamClient(10 * time.Second),
+		schemas: make(map[int]*registeredSchema),
+	}
+}
+
+func (c *schemaRegistryClient) enabled() bool {
+	return c.baseURL != ""
+}
+
+// splitSchemaFrame splits the Confluent wire format: magic byte 0, a 4-byte big-endian
+// schema ID, then the encoded payload.
+func splitSchemaFrame(data []byte) (id int, payload []byte, ok bool) {
+	if len(data) < 5 || data[0] != 0 {
+		return 0, nil, false
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], true
+}
+
+func (c *schemaRegistryClient) schema(ctx context.Context, id int) (*registeredSchema, error) {
+	c.mu.Lock()
+	cached, ok := c.schemas[id]
+	c.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(c.baseURL, "schemas", "ids", strconv.Itoa(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("schema registry unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("schema %d: schema registry returned HTTP %d: %s", id, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var payload struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode schema %d: %w", id, err)
+	}
+
+	schema := &registeredSchema{schemaType: strings.ToUpper(payload.SchemaType)}
+	if schema.schemaType == "" {
+		schema.schemaType = "AVRO"
+	}
+	if schema.schemaType == "AVRO" {
+		codec, err := goavro.NewCodec(payload.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("parse Avro schema %d: %w", id, err)
+		}
+		schema.avro = codec
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// decode decodes a record using the schema referenced by its wire-format header. format
+// is the caller's expectation ("avro", "protobuf" or "" for any); Protobuf payloads may
+// also be decoded without a registry, in which case fields are keyed by number.
+func (c *schemaRegistryClient) decode(ctx context.Context, data []byte, format string) (interface{}, string, int, error) {
+	id, payload, framed := splitSchemaFrame(data)
+
+	if !framed || !c.enabled() {
+		if format == formatProtobuf {
+			if framed {
+				payload = stripProtobufMessageIndexes(payload)
+			} else {
+				payload = data
+			}
+			value, err := decodeProtobufWire(payload)
+			return value, formatProtobuf, id, err
+		}
+		if !c.enabled() {
+			return nil, format, 0, errors.New("SCHEMA_REGISTRY_URL is not configured")
+		}
+		return nil, format, 0, errors.New("payload is not in schema registry wire format")
+	}
+
+	schema, err := c.schema(ctx, id)
+	if err != nil {
+		return nil, format, id, err
+	}
+
+	switch schema.schemaType {
+	case "AVRO":
+		native, _, err := schema.avro.NativeFromBinary(payload)
+		if err != nil {
+			return nil, formatAvro, id, fmt.Errorf("decode Avro: %w", err)
+		}
+		textual, err := schema.avro.TextualFromNative(nil, native)
+		if err != nil {
+			return nil, formatAvro, id, fmt.Errorf("encode Avro as JSON: %w", err)
+		}
+		var value interface{}
+		if err := json.Unmarshal(textual, &value); err != nil {
+			return nil, formatAvro, id, err
+		}
+		return value, formatAvro, id, nil
+	case "PROTOBUF":
+		value, err := decodeProtobufWire(stripProtobufMessageIndexes(payload))
+		return value, formatProtobuf, id, err
+	case "JSON":
+		var value interface{}
+		if err := json.Unmarshal(payload, &value); err != nil {
+			return nil, formatJSON, id, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return value, formatJSON, id, nil
+	}
+	return nil, format, id, fmt.Errorf("unsupported schema type %q", schema.schemaType)
+}
+
+// stripProtobufMessageIndexes skips the message-index list that the Confluent Protobuf
+// serializer writes after the schema ID: a zig-zag varint count followed by that many
+// indexes, with a single 0 standing for the first message.
+func stripProtobufMessageIndexes(payload []byte) []byte {
+	count, n := binary.Varint(payload)
+	if n <= 0 {
+		return payload
+	}
+	payload = payload[n:]
+	for i := int64(0); i < count; i++ {
+		_, n := binary.Varint(payload)
+		if n <= 0 {
+			return payload
+		}
+		payload = payload[n:]
+	}
+	return payload
+}
+
+// decodeProtobufWire decodes a Protobuf message without its descriptor. Fields are keyed
+// by field number; length-delimited fields are shown as nested messages, text or base64
+// depending on what they parse as. Repeated fields become arrays.
+func decodeProtobufWire(data []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	add := func(number uint64, value interface{}) {
+		key := strconv.FormatUint(number, 10)
+		switch existing := fields[key].(type) {
+		case nil:
+			fields[key] = value
+		case []interface{}:
+			fields[key] = append(existing, value)
+		default:
+			fields[key] = []interface{}{existing, value}
+		}
+	}
+
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid protobuf field tag")
+		}
+		data = data[n:]
+		number, wireType := tag>>3, tag&7
+		if number == 0 {
+			return nil, errors.New("invalid protobuf field number 0")
+		}
+
+		switch wireType {
+		case 0: // varint
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("field %d: invalid varint", number)
+			}
+			data = data[n:]
+			add(number, v)
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return nil, fmt.Errorf("field %d: truncated fixed64", number)
+			}
+			add(number, binary.LittleEndian.Uint64(data[:8]))
+			data = data[8:]
+		case 2: // length-delimited
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return nil, fmt.Errorf("field %d: truncated length-delimited value", number)
+			}
+			value := data[n : n+int(length)]
+			data = data[n+int(length):]
+			if nested, err := decodeProtobufWire(value); err == nil && len(nested) > 0 && !isPrintable(value) {
+				add(number, nested)
+			} else if utf8.Valid(value) {
+				add(number, string(value))
+			} else {
+				add(number, base64.StdEncoding.EncodeToString(value))
+			}
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return nil, fmt.Errorf("field %d: truncated fixed32", number)
+			}
+			add(number, binary.LittleEndian.Uint32(data[:4]))
+			data = data[4:]
+		default:
+			return nil, fmt.Errorf("field %d: unsupported wire type %d", number, wireType)
+		}
+	}
+	return fields, nil
+}
+
+// isPrintable reports whether data looks like human-readable text, which protobuf
+// strings usually are and nested messages usually are not.
+func isPrintable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const testAvroSchema = `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"item","type":"string"}]}`
+
+func withSchemaRegistry(t *testing.T, url string) {
+	t.Helper()
+	original := schemaRegistry
+	schemaRegistry = newSchemaRegistryClient(url)
+	t.Cleanup(func() { schemaRegistry = original })
+}
+
+func frame(id int, payload []byte) []byte {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return append(header, payload...)
+}
+
+func TestDecodePayloadAvroViaSchemaRegistry(t *testing.T) {
+	calls := 0
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/schemas/ids/42" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"schema": testAvroSchema})
+	}))
+	defer registry.Close()
+	withSchemaRegistry(t, registry.URL)
+
+	codec, err := goavro.NewCodec(testAvroSchema)
+	if err != nil {
+		t.Fatalf("codec: %v", err)
+	}
+	binaryValue, err := codec.BinaryFromNative(nil, map[string]interface{}{"id": int64(7), "item": "widget"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		value, used, id, err := decodePayload(context.Background(), frame(42, binaryValue), formatAuto)
+		if err != nil {
+			t.Fatalf("decodePayload failed: %v", err)
+		}
+		record, ok := value.(map[string]interface{})
+		if !ok || used != formatAvro || id != 42 || record["item"] != "widget" || record["id"] != float64(7) {
+			t.Fatalf("unexpected decode result: %v %s %d", value, used, id)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected schema to be fetched once, got %d", calls)
+	}
+
+	if _, _, _, err := decodePayload(context.Background(), frame(99, binaryValue), formatAvro); err == nil {
+		t.Fatalf("expected unknown schema ID to fail")
+	}
+}
+
+func TestDecodePayloadAvroRequiresRegistry(t *testing.T) {
+	withSchemaRegistry(t, "")
+	if _, _, _, err := decodePayload(context.Background(), frame(1, []byte{2}), formatAvro); err == nil {
+		t.Fatalf("expected error without SCHEMA_REGISTRY_URL")
+	}
+}
+
+func TestDecodeProtobufWire(t *testing.T) {
+	// message { int64 id = 1; string item = 2; Nested n = 3 { int32 qty = 1; } repeated int32 tags = 4 }
+	msg := []byte{
+		0x08, 0x07, // 1: 7
+		0x12, 0x06, 'w', 'i', 'd', 'g', 'e', 't', // 2: "widget"
+		0x1a, 0x02, 0x08, 0x03, // 3: {1: 3}
+		0x20, 0x01, 0x20, 0x02, // 4: 1, 4: 2
+	}
+
+	withSchemaRegistry(t, "")
+	value, used, _, err := decodePayload(context.Background(), frame(5, append([]byte{0}, msg...)), formatProtobuf)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	fields := value.(map[string]interface{})
+	nested, ok := fields["3"].(map[string]interface{})
+	if used != formatProtobuf || fields["1"] != uint64(7) || fields["2"] != "widget" || !ok || nested["1"] != uint64(3) {
+		t.Fatalf("unexpected fields: %#v", fields)
+	}
+	if tags, ok := fields["4"].([]interface{}); !ok || len(tags) != 2 {
+		t.Fatalf("expected repeated field as array, got %#v", fields["4"])
+	}
+
+	if _, err := decodeProtobufWire([]byte{0x08}); err == nil {
+		t.Fatalf("expected truncated message to fail")
+	}
+}
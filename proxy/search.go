@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Search limits: queries longer than maxSearchQueryLength are rejected and at most
+// limit hits (defaultSearchLimit unless given, never more than maxSearchLimit) are
+// returned.
+const (
+	maxSearchQueryLength = 200
+	defaultSearchLimit   = 100
+	maxSearchLimit       = 1000
+)
+
+// Fields a search hit can match, in the order hits of one connector are listed.
+const (
+	searchFieldName   = "name"
+	searchFieldClass  = "class"
+	searchFieldTopic  = "topic"
+	searchFieldConfig = "config"
+)
+
+var searchFieldOrder = map[string]int{searchFieldName: 0, searchFieldClass: 1, searchFieldTopic: 2, searchFieldConfig: 3}
+
+// SearchHit is one match of a search. Key is the config key of config hits; topic hits
+// carry the relation of the connector to the topic and whether it was inferred from
+// the config rather than reported by Connect's topic tracking.
+type SearchHit struct {
+	Connector     string `json:"connector"`
+	ConnectorType string `json:"connectorType,omitempty"`
+	State         string `json:"state,omitempty"`
+	Field         string `json:"field"`
+	Key           string `json:"key,omitempty"`
+	Value         string `json:"value"`
+	Relation      string `json:"relation,omitempty"`
+	Inferred      bool   `json:"inferred,omitempty"`
+}
+
+// SearchResults is returned by GET /api/{cluster}/search.
+type SearchResults struct {
+	Query     string      `json:"query"`
+	Total     int         `json:"total"`
+	Truncated bool        `json:"truncated"`
+	Hits      []SearchHit `json:"hits"`
+}
+
+// searchConnectors matches query case-insensitively against the name, class, topics and
+// config values of every connector. Values of sensitive keys are never searched, so a
+// search cannot be used to guess a password one character at a time. A topic pattern
+// (topics.regex or a source's topic.prefix) is a hit when the query is a topic it
+// matches.
+func searchConnectors(query string, connectors map[string]expandedConnector, active map[string][]string, rules redactionRules) []SearchHit {
+	needle := strings.ToLower(query)
+	contains := func(value string) bool { return strings.Contains(strings.ToLower(value), needle) }
+
+	hits := []SearchHit{}
+	for name, connector := range connectors {
+		connectorType := connector.Status.Type
+		if connectorType == "" {
+			connectorType = connector.Info.Type
+		}
+		base := SearchHit{Connector: name, ConnectorType: connectorType, State: normalizeState(connector.Status.Connector.State)}
+		add := func(field, key, value string) *SearchHit {
+			hit := base
+			hit.Field, hit.Key, hit.Value = field, key, value
+			hits = append(hits, hit)
+			return &hits[len(hits)-1]
+		}
+
+		if contains(name) {
+			add(searchFieldName, "", name)
+		}
+		class := connector.Info.Config["connector.class"]
+		if class != "" && contains(class) {
+			add(searchFieldClass, "", class)
+		}
+
+		relation := topologyProduces
+		if connectorType == "sink" {
+			relation = topologyConsumes
+		}
+		topics := configuredTopics(connectorType, connector.Info.Config)
+		seen := map[string]bool{}
+		for _, topic := range active[name] {
+			if contains(topic) && !seen[topic] {
+				seen[topic] = true
+				add(searchFieldTopic, "", topic).Relation = relation
+			}
+		}
+		for _, topic := range topics.topics {
+			if contains(topic) && !seen[topic] {
+				seen[topic] = true
+				hit := add(searchFieldTopic, "", topic)
+				hit.Relation, hit.Inferred = relation, true
+			}
+		}
+		if topics.deadLetter != "" && contains(topics.deadLetter) {
+			add(searchFieldTopic, "", topics.deadLetter).Relation = topologyDeadLetter
+		}
+		for _, pattern := range topics.patterns {
+			if pattern.re.MatchString(query) {
+				hit := add(searchFieldTopic, "", pattern.label)
+				hit.Relation, hit.Inferred = relation, true
+			}
+		}
+
+		for key, value := range connector.Info.Config {
+			if key == "name" || key == "connector.class" || rules.isSensitive(key) {
+				continue
+			}
+			if contains(value) {
+				add(searchFieldConfig, key, value)
+			}
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Connector != b.Connector {
+			return a.Connector < b.Connector
+		}
+		if a.Field != b.Field {
+			return searchFieldOrder[a.Field] < searchFieldOrder[b.Field]
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Value < b.Value
+	})
+	return hits
+}
+
+func parseSearchQuery(query url.Values) (string, int, error) {
+	q := strings.TrimSpace(query.Get("q"))
+	switch {
+	case q == "":
+		return "", 0, fmt.Errorf("q is required")
+	case len(q) > maxSearchQueryLength:
+		return "", 0, fmt.Errorf("q must be at most %d characters", maxSearchQueryLength)
+	}
+	limit, err := positiveIntParam(query, "limit", defaultSearchLimit)
+	if err != nil {
+		return "", 0, err
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	return q, limit, nil
+}
+
+// searchHandler answers questions like "which connector writes to topic X" or "which
+// connector reads table Y" across a whole cluster in one request.
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	q, limit, err := parseSearchQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+
+	cluster := mux.Vars(r)["cluster"]
+	client, baseURL := connectClientFor(cluster, routeRead), connectURLFor(cluster)
+	connectors, err := fetchExpandedConnectorStatuses(r.Context(), client, baseURL)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+
+	hits := searchConnectors(q, connectors, fetchActiveTopicsOf(r.Context(), client, baseURL, connectors), currentRedactionRules())
+	results := SearchResults{Query: q, Total: len(hits), Hits: hits}
+	if len(hits) > limit {
+		results.Hits, results.Truncated = hits[:limit], true
+	}
+	writeJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSearchConnectors(t *testing.T) {
+	connectors := map[string]expandedConnector{
+		"orders-cdc": testExpandedConnector("source", "RUNNING", map[string]string{
+			"connector.class":    "io.debezium.connector.postgresql.PostgresConnector",
+			"topic.prefix":       "orders",
+			"table.include.list": "public.Orders,public.customers",
+			"database.password":  "orders-secret",
+			"database.user":      "${vault:secret/db:user}",
+			"name":               "orders-cdc",
+		}),
+		"orders-sink": testExpandedConnector("sink", "FAILED", map[string]string{
+			"connector.class":                   "io.confluent.connect.jdbc.JdbcSinkConnector",
+			"topics":                            "orders.public.orders",
+			"errors.deadletterqueue.topic.name": "orders-dlq",
+		}),
+		"clicks-sink": testExpandedConnector("sink", "RUNNING", map[string]string{"topics.regex": "clicks\\..*"}),
+	}
+	active := map[string][]string{"orders-cdc": {"orders.public.orders"}}
+
+	describe := func(hits []SearchHit) []string {
+		var out []string
+		for _, hit := range hits {
+			out = append(out, fmt.Sprintf("%s %s %s=%s %s %v", hit.Connector, hit.Field, hit.Key, hit.Value, hit.Relation, hit.Inferred))
+		}
+		return out
+	}
+
+	got := describe(searchConnectors("ORDERS", connectors, active, currentRedactionRules()))
+	want := []string{
+		"orders-cdc name =orders-cdc  false",
+		"orders-cdc topic =orders.public.orders produces false",
+		"orders-cdc config table.include.list=public.Orders,public.customers  false",
+		"orders-cdc config topic.prefix=orders  false",
+		"orders-sink name =orders-sink  false",
+		"orders-sink topic =orders-dlq dead_letter false",
+		"orders-sink topic =orders.public.orders consumes true",
+		"orders-sink config errors.deadletterqueue.topic.name=orders-dlq  false",
+		"orders-sink config topics=orders.public.orders  false",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected hits:\n%s", strings.Join(got, "\n"))
+	}
+
+	if hits := searchConnectors("secret", connectors, active, currentRedactionRules()); len(hits) != 1 || hits[0].Key != "database.user" {
+		t.Fatalf("expected sensitive values to be skipped, got %v", describe(hits))
+	}
+	if got := describe(searchConnectors("clicks.web", connectors, active, currentRedactionRules())); len(got) != 1 || got[0] != `clicks-sink topic =clicks\..* consumes true` {
+		t.Fatalf("expected the topic regex to match, got %v", got)
+	}
+	if hits := searchConnectors("jdbc", connectors, active, currentRedactionRules()); len(hits) != 1 || hits[0].Field != searchFieldClass {
+		t.Fatalf("expected a class hit, got %v", describe(hits))
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/connectors":
+			w.Write([]byte(`{
+				"alpha": {"info": {"type": "sink", "config": {"topics": "payments", "connection.url": "jdbc:postgresql://db/payments"}}, "status": {"type": "sink", "connector": {"state": "RUNNING"}, "tasks": []}},
+				"beta": {"info": {"type": "source", "config": {"kafka.topic": "payments-audit"}}, "status": {"type": "source", "connector": {"state": "PAUSED"}, "tasks": []}}
+			}`))
+		case "/connectors/alpha/topics":
+			w.Write([]byte(`{"alpha": {"topics": ["payments"]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/search"+query, nil), map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		searchHandler(rr, req)
+		return rr
+	}
+
+	rr := get("?q=Payments&limit=3")
+	var results SearchResults
+	if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if results.Total != 5 || !results.Truncated || len(results.Hits) != 3 {
+		t.Fatalf("unexpected results %s", rr.Body.String())
+	}
+	if hit := results.Hits[0]; hit.Connector != "alpha" || hit.Field != searchFieldTopic || hit.Inferred || hit.State != "running" {
+		t.Fatalf("expected the active topic first, got %+v", hit)
+	}
+
+	for _, query := range []string{"", "?q=%20", "?q=" + strings.Repeat("x", maxSearchQueryLength+1), "?q=a&limit=0"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
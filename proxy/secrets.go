@@ -0,0 +1,667 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const secretRefsFile = "secret-refs.json"
+
+var (
+	secretsVaultAddr   = getEnv("SECRETS_VAULT_ADDR", "")
+	secretsVaultToken  = getEnv("SECRETS_VAULT_TOKEN", "")
+	secretsAWSRegion   = getEnv("SECRETS_AWS_REGION", getEnv("AWS_REGION", ""))
+	secretsAWSEndpoint = getEnv("SECRETS_AWS_ENDPOINT", "")
+	secretsEnvPrefix   = getEnv("SECRETS_ENV_PREFIX", "CONNECT_SECRET_")
+
+	connectorSecrets = newSecretResolver(nil)
+	secretRefs       = newSecretRefStore()
+)
+
+// secretPlaceholderPattern matches ${provider:path} and ${provider:path#key}.
+var secretPlaceholderPattern = regexp.MustCompile(`\$\{(vault|aws|env):([^}#]+)(?:#([^}]+))?\}`)
+
+// errSecretBackend marks failures to reach a secrets backend, as opposed to references
+// that cannot be resolved.
+var errSecretBackend = errors.New("secrets backend unavailable")
+
+// isSecretReference reports whether value consists only of secret placeholders. Such
+// values carry no secret material and are shown as-is instead of being redacted.
+func isSecretReference(value string) bool {
+	trimmed := strings.TrimSpace(value)
+	return trimmed != "" && strings.TrimSpace(secretPlaceholderPattern.ReplaceAllString(trimmed, "")) == ""
+}
+
+// secretProvider looks up a secret by path and, for structured secrets, key.
+type secretProvider interface {
+	lookup(ctx context.Context, path, key string) (string, error)
+}
+
+// secretResolver replaces secret placeholders in connector configs.
+type secretResolver struct {
+	providers map[string]secretProvider
+}
+
+func newSecretResolver(providers map[string]secretProvider) *secretResolver {
+	if providers == nil {
+		providers = make(map[string]secretProvider)
+	}
+	return &secretResolver{providers: providers}
+}
+
+// newSecretResolverFromEnv configures the providers from the SECRETS_* environment
+// variables. The env provider is always available; Vault and AWS only when configured.
+func newSecretResolverFromEnv() (*secretResolver, error) {
+	client := newUpstreamClient(10 * time.Second)
+	providers := map[string]secretProvider{
+		"env": envSecretProvider{prefix: secretsEnvPrefix, lookupEnv: os.LookupEnv},
+	}
+	if secretsVaultAddr != "" {
+		if secretsVaultToken == "" {
+			return nil, fmt.Errorf("SECRETS_VAULT_TOKEN is required when SECRETS_VAULT_ADDR is set")
+		}
+		providers["vault"] = vaultSecretProvider{addr: secretsVaultAddr, token: secretsVaultToken, client: client}
+	}
+	if secretsAWSRegion != "" {
+		creds := awsCredentials{
+			accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+			return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required when SECRETS_AWS_REGION is set")
+		}
+		endpoint := secretsAWSEndpoint
+		if endpoint == "" {
+			endpoint = "https://secretsmanager." + secretsAWSRegion + ".amazonaws.com"
+		}
+		providers["aws"] = awsSecretProvider{endpoint: endpoint, region: secretsAWSRegion, creds: creds, client: client, now: time.Now}
+	}
+	return newSecretResolver(providers), nil
+}
+
+// secretRef records which placeholder produced a config value. Hash is the SHA-256 of
+// the resolved value, so the placeholder is only shown again while Kafka Connect still
+// holds that value.
+type secretRef struct {
+	Placeholder string `json:"placeholder"`
+	Hash        string `json:"hash"`
+}
+
+func secretHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// resolveConfig replaces every placeholder in config in place and returns a ref for each
+// key that contained one.
+func (s *secretResolver) resolveConfig(ctx context.Context, config map[string]interface{}) (map[string]secretRef, error) {
+	refs := make(map[string]secretRef)
+	resolved := make(map[string]string)
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok := config[key].(string)
+		if !ok || !secretPlaceholderPattern.MatchString(value) {
+			continue
+		}
+
+		var lookupErr error
+		replaced := false
+		result := secretPlaceholderPattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+			if lookupErr != nil {
+				return placeholder
+			}
+			match := secretPlaceholderPattern.FindStringSubmatch(placeholder)
+			provider, path, key := match[1], strings.TrimSpace(match[2]), strings.TrimSpace(match[3])
+			if !s.handles(provider, path) {
+				return placeholder
+			}
+			replaced = true
+			if cached, ok := resolved[placeholder]; ok {
+				return cached
+			}
+			secret, err := s.lookup(ctx, provider, path, key)
+			if err != nil {
+				lookupErr = fmt.Errorf("%s: %w", key, err)
+				return placeholder
+			}
+			resolved[placeholder] = secret
+			return secret
+		})
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		if !replaced {
+			continue
+		}
+
+		config[key] = result
+		refs[key] = secretRef{Placeholder: value, Hash: secretHash(result)}
+	}
+	return refs, nil
+}
+
+// handles reports whether a placeholder is the proxy's to resolve. ${env:...} and
+// ${vault:...} are also Kafka Connect ConfigProvider syntax, so references to a provider
+// the proxy has not configured, or that the provider does not accept, are passed on for
+// the workers to expand.
+func (s *secretResolver) handles(provider, path string) bool {
+	p, ok := s.providers[provider]
+	if !ok {
+		return false
+	}
+	if scoped, ok := p.(interface{ handles(path string) bool }); ok {
+		return scoped.handles(path)
+	}
+	return true
+}
+
+func (s *secretResolver) lookup(ctx context.Context, provider, path, key string) (string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", fmt.Errorf("secret provider %q is not configured", provider)
+	}
+	return p.lookup(ctx, path, key)
+}
+
+// envSecretProvider resolves ${env:NAME}. Only variables with the configured prefix are
+// readable so configs cannot pull arbitrary proxy settings such as credentials.
+type envSecretProvider struct {
+	prefix    string
+	lookupEnv func(string) (string, bool)
+}
+
+// handles leaves variables without the prefix to the workers' EnvVarConfigProvider.
+func (p envSecretProvider) handles(name string) bool {
+	return strings.HasPrefix(name, p.prefix)
+}
+
+func (p envSecretProvider) lookup(_ context.Context, name, key string) (string, error) {
+	if key != "" {
+		return "", fmt.Errorf("env secret %s does not support a #key", name)
+	}
+	if !strings.HasPrefix(name, p.prefix) {
+		return "", fmt.Errorf("env secret %s must start with %s", name, p.prefix)
+	}
+	value, ok := p.lookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env secret %s is not set", name)
+	}
+	return value, nil
+}
+
+// vaultSecretProvider resolves ${vault:path#key} through the Vault HTTP API. KV v2
+// responses nest the secret under data.data; KV v1 and other engines use data.
+type vaultSecretProvider struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// handles leaves ${vault:path:key}, the syntax of worker-side Vault config providers,
+// to the workers; the proxy's own placeholders select the key with #.
+func (p vaultSecretProvider) handles(path string) bool {
+	return !strings.Contains(path, ":")
+}
+
+func (p vaultSecretProvider) lookup(ctx context.Context, path, key string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(p.addr, "v1", strings.Trim(path, "/")), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: vault: %v", errSecretBackend, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("vault secret %s not found", path)
+	case resp.StatusCode == http.StatusForbidden:
+		return "", fmt.Errorf("vault denied access to %s", path)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("%w: vault returned status %d for %s", errSecretBackend, resp.StatusCode, path)
+	}
+
+	var payload struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("decode vault secret %s: %w", path, err)
+	}
+	data := payload.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+	return secretField(data, path, key)
+}
+
+// secretField picks key out of a structured secret. Without a key the secret must have
+// exactly one field.
+func secretField(data map[string]interface{}, path, key string) (string, error) {
+	if key == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("secret %s has %d fields; add #key to choose one", path, len(data))
+		}
+		for k := range data {
+			key = k
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %q", path, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// awsSecretProvider resolves ${aws:secret-id#key} with Secrets Manager GetSecretValue.
+// Without a key the whole SecretString is used; with one it is parsed as a JSON object.
+type awsSecretProvider struct {
+	endpoint string
+	region   string
+	creds    awsCredentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+func (p awsSecretProvider) lookup(ctx context.Context, secretID, key string) (string, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(p.endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, "secretsmanager", p.region, p.creds, p.now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: secrets manager: %v", errSecretBackend, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&awsErr)
+		if resp.StatusCode >= 500 {
+			return "", fmt.Errorf("%w: secrets manager returned status %d for %s", errSecretBackend, resp.StatusCode, secretID)
+		}
+		return "", fmt.Errorf("secrets manager could not read %s: %s %s", secretID, awsErr.Type, awsErr.Message)
+	}
+
+	var secret struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("decode secret %s: %w", secretID, err)
+	}
+	if secret.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no string value", secretID)
+	}
+	if key == "" {
+		return *secret.SecretString, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*secret.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object, cannot select key %q", secretID, key)
+	}
+	return secretField(fields, secretID, key)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsSigningKey derives the Signature Version 4 key for a date, region and service.
+func awsSigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// signAWSRequest adds Signature Version 4 headers to req. Every header already set on
+// req is signed along with host and x-amz-date.
+func signAWSRequest(req *http.Request, body []byte, service, region string, creds awsCredentials, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := req.URL.Query()
+	queryKeys := make([]string, 0, len(query))
+	for k := range query {
+		queryKeys = append(queryKeys, k)
+	}
+	sort.Strings(queryKeys)
+	var canonicalQuery []string
+	for _, k := range queryKeys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			canonicalQuery = append(canonicalQuery, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(canonicalQuery, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	signature := hex.EncodeToString(hmacSHA256(awsSigningKey(creds.secretAccessKey, date, region, service), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+// awsEscape is URI encoding as SigV4 defines it: url.QueryEscape with spaces as %20.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// secretRefStore remembers, per cluster and connector, which config keys were resolved
+// from placeholders.
+type secretRefStore struct {
+	mu       sync.RWMutex
+	clusters map[string]map[string]map[string]secretRef
+}
+
+func newSecretRefStore() *secretRefStore {
+	return &secretRefStore{clusters: make(map[string]map[string]map[string]secretRef)}
+}
+
+// load replaces the store contents with the persisted refs, if any.
+func (s *secretRefStore) load() error {
+	clusters := make(map[string]map[string]map[string]secretRef)
+	if err := loadJSON(secretRefsFile, &clusters); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clusters = clusters
+	return nil
+}
+
+// saveLocked persists the store. Callers must hold s.mu.
+func (s *secretRefStore) saveLocked() error {
+	return saveJSON(secretRefsFile, s.clusters)
+}
+
+// get returns the refs of a connector.
+func (s *secretRefStore) get(cluster, connector string) map[string]secretRef {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.clusters[cluster][connector]
+}
+
+// hasCluster reports whether any connector of cluster has refs.
+func (s *secretRefStore) hasCluster(cluster string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.clusters[cluster]) > 0
+}
+
+// set replaces the refs of a connector; an empty set removes it.
+func (s *secretRefStore) set(cluster, connector string, refs map[string]secretRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(refs) == 0 {
+		if _, ok := s.clusters[cluster][connector]; !ok {
+			return nil
+		}
+		delete(s.clusters[cluster], connector)
+		if len(s.clusters[cluster]) == 0 {
+			delete(s.clusters, cluster)
+		}
+		return s.saveLocked()
+	}
+
+	if s.clusters[cluster] == nil {
+		s.clusters[cluster] = make(map[string]map[string]secretRef)
+	}
+	s.clusters[cluster][connector] = refs
+	return s.saveLocked()
+}
+
+// restore puts placeholders back into a config read from Kafka Connect for keys whose
+// value is still the one the proxy resolved.
+func (s *secretRefStore) restore(cluster, connector string, config map[string]interface{}) {
+	for key, ref := range s.get(cluster, connector) {
+		if value, ok := config[key].(string); ok && secretHash(value) == ref.Hash {
+			config[key] = ref.Placeholder
+		}
+	}
+}
+
+// restoreStrings is restore for configs decoded as map[string]string.
+func (s *secretRefStore) restoreStrings(cluster, connector string, config map[string]string) {
+	for key, ref := range s.get(cluster, connector) {
+		if value, ok := config[key]; ok && secretHash(value) == ref.Hash {
+			config[key] = ref.Placeholder
+		}
+	}
+}
+
+// pendingSecretRefs are the refs of a config write, recorded once Kafka Connect accepts
+// it.
+type pendingSecretRefs struct {
+	cluster   string
+	connector string
+	refs      map[string]secretRef
+	record    bool
+}
+
+// commit records the refs if the upstream write succeeded.
+func (p pendingSecretRefs) commit(status int) error {
+	if !p.record || status < 200 || status >= 300 {
+		return nil
+	}
+	return secretRefs.set(p.cluster, p.connector, p.refs)
+}
+
+// clusterPathSegments splits the path after /api/{cluster}/.
+func clusterPathSegments(path string) []string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(parts) < 3 || parts[0] != "api" {
+		return nil
+	}
+	return strings.Split(strings.Trim(parts[2], "/"), "/")
+}
+
+// resolveSecretRequest resolves placeholders in the body of a connector create
+// (POST /connectors), config update (PUT /connectors/{name}/config) or plugin validation
+// (PUT /connector-plugins/{plugin}/config/validate) and swaps in the resolved body.
+// Deleting a connector forgets its refs.
+func resolveSecretRequest(r *http.Request) (pendingSecretRefs, error) {
+	pending := pendingSecretRefs{cluster: mux.Vars(r)["cluster"]}
+	segments := clusterPathSegments(r.URL.Path)
+
+	var wrapped bool
+	switch {
+	case r.Method == http.MethodPost && len(segments) == 1 && segments[0] == "connectors":
+		wrapped, pending.record = true, true
+	case r.Method == http.MethodPut && len(segments) == 3 && segments[0] == "connectors" && segments[2] == "config":
+		pending.connector, pending.record = segments[1], true
+	case r.Method == http.MethodPut && len(segments) == 4 && segments[0] == "connector-plugins" && segments[2] == "config" && segments[3] == "validate":
+	case r.Method == http.MethodDelete && len(segments) == 2 && segments[0] == "connectors":
+		pending.connector, pending.record = segments[1], true
+		return pending, nil
+	default:
+		return pending, nil
+	}
+
+	if r.Body == nil {
+		return pendingSecretRefs{}, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return pendingSecretRefs{}, fmt.Errorf("read request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		// Let Kafka Connect report malformed bodies as it always has.
+		return pendingSecretRefs{}, nil
+	}
+	config := document
+	if wrapped {
+		config, _ = document["config"].(map[string]interface{})
+		pending.connector, _ = document["name"].(string)
+		if config == nil || pending.connector == "" {
+			return pendingSecretRefs{}, nil
+		}
+	}
+	if !secretPlaceholderPattern.Match(body) {
+		return pending, nil
+	}
+
+	if pending.refs, err = connectorSecrets.resolveConfig(r.Context(), config); err != nil {
+		return pendingSecretRefs{}, err
+	}
+	resolved, err := json.Marshal(document)
+	if err != nil {
+		return pendingSecretRefs{}, fmt.Errorf("encode resolved config: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(resolved))
+	r.ContentLength = int64(len(resolved))
+	return pending, nil
+}
+
+// writeSecretResolutionError reports a placeholder that could not be resolved.
+func writeSecretResolutionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errSecretBackend) {
+		writeJSONError(w, http.StatusBadGateway, "secret_backend_unavailable", err.Error())
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, "secret_resolution_failed", err.Error())
+}
+
+// restoreSecretResponse swaps resolved values back to their placeholders in successful
+// reads of connector info, connector config and GET /connectors?expand=info.
+func restoreSecretResponse(r *http.Request, resp *http.Response) error {
+	cluster := mux.Vars(r)["cluster"]
+	if r.Method != http.MethodGet || resp.StatusCode != http.StatusOK || !secretRefs.hasCluster(cluster) {
+		return nil
+	}
+
+	segments := clusterPathSegments(r.URL.Path)
+	var restoreBody func(data map[string]interface{})
+	switch {
+	case len(segments) == 1 && segments[0] == "connectors":
+		restoreBody = func(data map[string]interface{}) {
+			for name, entry := range data {
+				entryMap, _ := entry.(map[string]interface{})
+				info, _ := entryMap["info"].(map[string]interface{})
+				if config, ok := info["config"].(map[string]interface{}); ok {
+					secretRefs.restore(cluster, name, config)
+				}
+			}
+		}
+	case len(segments) == 2 && segments[0] == "connectors":
+		restoreBody = func(data map[string]interface{}) {
+			if config, ok := data["config"].(map[string]interface{}); ok {
+				secretRefs.restore(cluster, segments[1], config)
+			}
+		}
+	case len(segments) == 3 && segments[0] == "connectors" && segments[2] == "config":
+		restoreBody = func(data map[string]interface{}) {
+			secretRefs.restore(cluster, segments[1], data)
+		}
+	default:
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read response body: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err == nil {
+		restoreBody(data)
+		if restored, err := json.Marshal(data); err == nil {
+			body = restored
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestSecrets(t *testing.T, providers map[string]secretProvider) *secretRefStore {
+	t.Helper()
+	originalResolver, originalRefs := connectorSecrets, secretRefs
+	connectorSecrets = newSecretResolver(providers)
+	secretRefs = newSecretRefStore()
+	t.Cleanup(func() { connectorSecrets, secretRefs = originalResolver, originalRefs })
+	return secretRefs
+}
+
+func testEnvProvider(values map[string]string) envSecretProvider {
+	return envSecretProvider{prefix: "CONNECT_SECRET_", lookupEnv: func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}}
+}
+
+func TestIsSecretReference(t *testing.T) {
+	tests := map[string]bool{
+		"${vault:secret/db#password}":         true,
+		" ${env:CONNECT_SECRET_A} ":           true,
+		"${env:CONNECT_SECRET_A}${aws:db#pw}": true,
+		"pass${env:CONNECT_SECRET_A}":         false,
+		"${other:thing}":                      false,
+		"hunter2":                             false,
+		"":                                    false,
+	}
+	for value, want := range tests {
+		if got := isSecretReference(value); got != want {
+			t.Fatalf("isSecretReference(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestResolveConfigReplacesPlaceholders(t *testing.T) {
+	resolver := newSecretResolver(map[string]secretProvider{
+		"env": testEnvProvider(map[string]string{"CONNECT_SECRET_DB_PASSWORD": "hunter2", "CONNECT_SECRET_HOST": "db.internal"}),
+	})
+	config := map[string]interface{}{
+		"connection.password": "${env:CONNECT_SECRET_DB_PASSWORD}",
+		"connection.url":      "jdbc:postgresql://${env:CONNECT_SECRET_HOST}:5432/orders",
+		"tasks.max":           "1",
+	}
+
+	refs, err := resolver.resolveConfig(context.Background(), config)
+	if err != nil {
+		t.Fatalf("resolveConfig: %v", err)
+	}
+	if config["connection.password"] != "hunter2" || config["connection.url"] != "jdbc:postgresql://db.internal:5432/orders" {
+		t.Fatalf("placeholders not resolved: %+v", config)
+	}
+	if len(refs) != 2 || refs["connection.url"].Placeholder != "jdbc:postgresql://${env:CONNECT_SECRET_HOST}:5432/orders" {
+		t.Fatalf("unexpected refs: %+v", refs)
+	}
+	if refs["connection.password"].Hash != secretHash("hunter2") {
+		t.Fatalf("ref hash does not match the resolved value")
+	}
+}
+
+func TestResolveConfigErrors(t *testing.T) {
+	resolver := newSecretResolver(map[string]secretProvider{"env": testEnvProvider(nil)})
+
+	for _, value := range []string{"${env:CONNECT_SECRET_MISSING}", "${env:CONNECT_SECRET_A#key}"} {
+		if _, err := resolver.resolveConfig(context.Background(), map[string]interface{}{"password": value}); err == nil {
+			t.Fatalf("expected %s to fail", value)
+		}
+	}
+}
+
+func TestResolveConfigPassesThroughConfigProviderReferences(t *testing.T) {
+	resolver := newSecretResolver(map[string]secretProvider{
+		"env":   testEnvProvider(map[string]string{"CONNECT_SECRET_HOST": "db.internal", "DB_PASSWORD": "proxy-only"}),
+		"vault": vaultSecretProvider{},
+	})
+	config := map[string]interface{}{
+		"connection.password": "${env:DB_PASSWORD}",
+		"connection.user":     "${vault:secret/db:user}",
+		"connection.url":      "jdbc:postgresql://${env:CONNECT_SECRET_HOST}:5432/${env:DB_NAME}",
+		"ssl.key.password":    "${aws:prod/db#password}",
+	}
+
+	refs, err := resolver.resolveConfig(context.Background(), config)
+	if err != nil {
+		t.Fatalf("resolveConfig: %v", err)
+	}
+	want := map[string]interface{}{
+		"connection.password": "${env:DB_PASSWORD}",
+		"connection.user":     "${vault:secret/db:user}",
+		"connection.url":      "jdbc:postgresql://db.internal:5432/${env:DB_NAME}",
+		"ssl.key.password":    "${aws:prod/db#password}",
+	}
+	for key, value := range want {
+		if config[key] != value {
+			t.Fatalf("%s = %v, want %v", key, config[key], value)
+		}
+	}
+	if len(refs) != 1 || refs["connection.url"].Hash != secretHash("jdbc:postgresql://db.internal:5432/${env:DB_NAME}") {
+		t.Fatalf("unexpected refs: %+v", refs)
+	}
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/db":
+			w.Write([]byte(`{"data":{"data":{"password":"hunter2","user":"app"},"metadata":{"version":3}}}`))
+		case "/v1/kv/db":
+			w.Write([]byte(`{"data":{"password":"legacy"}}`))
+		case "/v1/secret/data/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := vaultSecretProvider{addr: server.URL, token: "s.token", client: server.Client()}
+	ctx := context.Background()
+
+	if value, err := provider.lookup(ctx, "secret/data/db", "password"); err != nil || value != "hunter2" {
+		t.Fatalf("KV v2 lookup = %q, %v", value, err)
+	}
+	if value, err := provider.lookup(ctx, "kv/db", ""); err != nil || value != "legacy" {
+		t.Fatalf("KV v1 lookup without key = %q, %v", value, err)
+	}
+	if _, err := provider.lookup(ctx, "secret/data/db", ""); err == nil {
+		t.Fatalf("expected an error choosing between several fields without a key")
+	}
+	if _, err := provider.lookup(ctx, "secret/data/missing", "password"); err == nil || errors.Is(err, errSecretBackend) {
+		t.Fatalf("expected a not-found error, got %v", err)
+	}
+	if _, err := provider.lookup(ctx, "secret/data/down", "password"); !errors.Is(err, errSecretBackend) {
+		t.Fatalf("expected a backend error, got %v", err)
+	}
+}
+
+func TestAWSSecretProvider(t *testing.T) {
+	var target, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target, auth = r.Header.Get("X-Amz-Target"), r.Header.Get("Authorization")
+		var body struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.SecretId != "prod/db" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+			return
+		}
+		w.Write([]byte(`{"Name":"prod/db","SecretString":"{\"password\":\"hunter2\"}"}`))
+	}))
+	defer server.Close()
+
+	provider := awsSecretProvider{
+		endpoint: server.URL,
+		region:   "eu-west-1",
+		creds:    awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "secret"},
+		client:   server.Client(),
+		now:      func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) },
+	}
+
+	value, err := provider.lookup(context.Background(), "prod/db", "password")
+	if err != nil || value != "hunter2" {
+		t.Fatalf("lookup = %q, %v", value, err)
+	}
+	if target != "secretsmanager.GetSecretValue" {
+		t.Fatalf("unexpected X-Amz-Target %q", target)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240501/eu-west-1/secretsmanager/aws4_request") {
+		t.Fatalf("unexpected Authorization %q", auth)
+	}
+	if value, err := provider.lookup(context.Background(), "prod/db", ""); err != nil || value != `{"password":"hunter2"}` {
+		t.Fatalf("lookup without key = %q, %v", value, err)
+	}
+	if _, err := provider.lookup(context.Background(), "prod/other", "password"); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Fatalf("expected a not-found error, got %v", err)
+	}
+}
+
+// TestSignAWSRequestReferenceExample checks the signer against the worked example in the
+// AWS Signature Version 4 documentation.
+func TestSignAWSRequestReferenceExample(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header = http.Header{}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	signAWSRequest(req, nil, "iam", "us-east-1",
+		awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestProxyHandlerResolvesAndRestoresPlaceholders(t *testing.T) {
+	withTestSecrets(t, map[string]secretProvider{
+		"env": testEnvProvider(map[string]string{"CONNECT_SECRET_DB_PASSWORD": "hunter2"}),
+	})
+
+	var stored map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/connectors/alpha/config":
+			json.NewDecoder(r.Body).Decode(&stored)
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": "alpha", "config": stored})
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors/alpha/config":
+			json.NewEncoder(w).Encode(stored)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+	originalCache := configCache
+	configCache = newResponseCache(0, newMemoryCache(time.Now))
+	t.Cleanup(func() { configCache = originalCache })
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "path": strings.TrimPrefix(path, "/api/default/connectors/")})
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		return rr
+	}
+
+	put := do(http.MethodPut, "/api/default/connectors/alpha/config", `{"connector.class":"JdbcSink","connection.password":"${env:CONNECT_SECRET_DB_PASSWORD}"}`)
+	if put.Code != http.StatusOK {
+		t.Fatalf("PUT returned %d: %s", put.Code, put.Body.String())
+	}
+	if stored["connection.password"] != "hunter2" {
+		t.Fatalf("Kafka Connect received %q, want the resolved secret", stored["connection.password"])
+	}
+
+	get := do(http.MethodGet, "/api/default/connectors/alpha/config", "")
+	var config map[string]string
+	if err := json.Unmarshal(get.Body.Bytes(), &config); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if config["connection.password"] != "${env:CONNECT_SECRET_DB_PASSWORD}" {
+		t.Fatalf("expected the placeholder to be shown, got %q", config["connection.password"])
+	}
+
+	// A value changed outside the console no longer matches and is redacted as usual.
+	stored["connection.password"] = "rotated"
+	get = do(http.MethodGet, "/api/default/connectors/alpha/config", "")
+	if strings.Contains(get.Body.String(), "CONNECT_SECRET_DB_PASSWORD") || strings.Contains(get.Body.String(), "rotated") {
+		t.Fatalf("expected a redacted value, got %s", get.Body.String())
+	}
+}
+
+func TestProxyHandlerRejectsUnresolvablePlaceholders(t *testing.T) {
+	withTestSecrets(t, map[string]secretProvider{"env": testEnvProvider(nil)})
+
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	body := `{"name":"alpha","config":{"connection.password":"${env:CONNECT_SECRET_DB_PASSWORD}"}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/default/connectors", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	proxyHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "secret_resolution_failed") {
+		t.Fatalf("expected 400 secret_resolution_failed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if called {
+		t.Fatalf("request must not reach Kafka Connect when a placeholder cannot be resolved")
+	}
+}
+
+func TestResolveSecretRequestCreateAndDelete(t *testing.T) {
+	refs := withTestSecrets(t, map[string]secretProvider{
+		"env": testEnvProvider(map[string]string{"CONNECT_SECRET_TOKEN": "abc"}),
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/default/connectors", strings.NewReader(`{"name":"alpha","config":{"token":"${env:CONNECT_SECRET_TOKEN}"}}`))
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+	pending, err := resolveSecretRequest(req)
+	if err != nil {
+		t.Fatalf("resolveSecretRequest: %v", err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if !bytes.Contains(body, []byte(`"token":"abc"`)) || req.ContentLength != int64(len(body)) {
+		t.Fatalf("unexpected forwarded body %s (length %d)", body, req.ContentLength)
+	}
+	if err := pending.commit(http.StatusCreated); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if got := refs.get("default", "alpha")["token"].Placeholder; got != "${env:CONNECT_SECRET_TOKEN}" {
+		t.Fatalf("expected ref to be recorded, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/default/connectors/alpha", nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+	if pending, err = resolveSecretRequest(req); err != nil {
+		t.Fatalf("resolveSecretRequest: %v", err)
+	}
+	pending.commit(http.StatusNoContent)
+	if refs.hasCluster("default") {
+		t.Fatalf("expected refs to be dropped with the connector")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const silencesFile = "alert-silences.json"
+
+const (
+	auditActionCreateSilence = "CREATE_SILENCE"
+	auditActionDeleteSilence = "DELETE_SILENCE"
+)
+
+// maxSilenceDuration bounds a silence so a forgotten one cannot hide alerts for good.
+const maxSilenceDuration = 30 * 24 * time.Hour
+
+var alertSilences = newSilenceStore(time.Now)
+
+// SilenceMatcher selects the alerts a silence suppresses. Every set field must match;
+// a matcher with only a cluster silences every alert of that cluster.
+type SilenceMatcher struct {
+	Cluster   string `json:"cluster"`             // {cluster} name, or * for every cluster
+	Connector string `json:"connector,omitempty"` // glob on the connector name, e.g. "orders-*"
+	Tag       string `json:"tag,omitempty"`       // connector tag
+}
+
+// AlertSilence suppresses matching notifications between StartsAt and EndsAt. Expired
+// silences are deleted.
+type AlertSilence struct {
+	ID        string         `json:"id"`
+	Matcher   SilenceMatcher `json:"matcher"`
+	Reason    string         `json:"reason"`
+	StartsAt  time.Time      `json:"startsAt"`
+	EndsAt    time.Time      `json:"endsAt"`
+	CreatedAt time.Time      `json:"createdAt"`
+	CreatedBy string         `json:"createdBy"`
+}
+
+// active reports whether the silence applies at now.
+func (s AlertSilence) active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// silenceRequest is the body of POST /api/{cluster}/alerts/silences.
+type silenceRequest struct {
+	Matcher  SilenceMatcher `json:"matcher"`
+	Duration string         `json:"duration"`
+	StartsAt *time.Time     `json:"startsAt,omitempty"`
+	Reason   string         `json:"reason"`
+}
+
+// normalize trims the request and defaults the matcher's cluster to the request's.
+func (req silenceRequest) normalize(cluster string) silenceRequest {
+	req.Matcher.Cluster = strings.TrimSpace(req.Matcher.Cluster)
+	req.Matcher.Connector = strings.TrimSpace(req.Matcher.Connector)
+	req.Matcher.Tag = strings.TrimSpace(req.Matcher.Tag)
+	req.Duration, req.Reason = strings.TrimSpace(req.Duration), strings.TrimSpace(req.Reason)
+	if req.Matcher.Cluster == "" {
+		req.Matcher.Cluster = cluster
+	}
+	return req
+}
+
+func (req silenceRequest) validate() (time.Duration, error) {
+	d, err := parseWindow(req.Duration, 0)
+	if err != nil || d < time.Minute || d > maxSilenceDuration {
+		return 0, fmt.Errorf("duration must be between 1m and 30d, got %q", req.Duration)
+	}
+	if req.Reason == "" {
+		return 0, fmt.Errorf("reason is required")
+	}
+	if req.Matcher.Connector != "" {
+		if _, err := path.Match(req.Matcher.Connector, ""); err != nil {
+			return 0, fmt.Errorf("invalid connector pattern %q", req.Matcher.Connector)
+		}
+	}
+	return d, nil
+}
+
+// alertCluster is the {cluster} an event is about. Connector alerts come from polling
+// the console's own cluster, whose metadata is kept under CONSOLE_CLUSTER_NAME; drift
+// events name their cluster.
+func alertCluster(event NotificationEvent) string {
+	if event.Type == eventDriftDetected {
+		return event.Cluster
+	}
+	return alertMetadataCluster
+}
+
+// matches reports whether m selects event. Connector and tag matchers never match
+// events that are not about a connector.
+func (m SilenceMatcher) matches(event NotificationEvent) bool {
+	cluster := alertCluster(event)
+	if m.Cluster != "*" && m.Cluster != cluster {
+		return false
+	}
+	if m.Connector != "" {
+		if ok, _ := path.Match(m.Connector, event.Connector); !ok || event.Connector == "" {
+			return false
+		}
+	}
+	if m.Tag != "" && (event.Connector == "" || !connectorMetadata.get(cluster, event.Connector).hasTag(m.Tag)) {
+		return false
+	}
+	return true
+}
+
+// silenceDocument is the persisted form of the silences.
+type silenceDocument struct {
+	NextID   int64          `json:"nextId"`
+	Silences []AlertSilence `json:"silences"`
+}
+
+// silenceStore keeps alert silences and persists them to DATA_DIR.
+type silenceStore struct {
+	mu       sync.Mutex
+	nextID   int64
+	silences []AlertSilence
+	now      func() time.Time
+}
+
+func newSilenceStore(now func() time.Time) *silenceStore {
+	return &silenceStore{now: now}
+}
+
+// load replaces the silences with the persisted ones, if any.
+func (s *silenceStore) load() error {
+	var doc silenceDocument
+	if err := loadJSON(silencesFile, &doc); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID, s.silences = doc.NextID, doc.Silences
+	return nil
+}
+
+// saveLocked persists the silences. Callers must hold s.mu.
+func (s *silenceStore) saveLocked() error {
+	return saveJSON(silencesFile, silenceDocument{NextID: s.nextID, Silences: s.silences})
+}
+
+// pruneLocked deletes expired silences and persists the change. Callers must hold s.mu.
+func (s *silenceStore) pruneLocked(now time.Time) {
+	kept := s.silences[:0]
+	for _, silence := range s.silences {
+		if now.Before(silence.EndsAt) {
+			kept = append(kept, silence)
+		}
+	}
+	if len(kept) == len(s.silences) {
+		return
+	}
+	s.silences = kept
+	if err := s.saveLocked(); err != nil {
+		log.Printf("silences: failed to persist silences after deleting expired ones: %v", err)
+	}
+}
+
+// list returns the silences that have not expired and apply to cluster, including
+// those that start later.
+func (s *silenceStore) list(cluster string) []AlertSilence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(s.now())
+
+	result := []AlertSilence{}
+	for _, silence := range s.silences {
+		if silence.Matcher.Cluster == cluster || silence.Matcher.Cluster == "*" {
+			result = append(result, silence)
+		}
+	}
+	return result
+}
+
+// add stores a silence built from a normalized request.
+func (s *silenceStore) add(req silenceRequest, duration time.Duration, user string) (AlertSilence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	start := now
+	if req.StartsAt != nil && req.StartsAt.After(now) {
+		start = req.StartsAt.UTC()
+	}
+	s.nextID++
+	silence := AlertSilence{
+		ID:        strconv.FormatInt(s.nextID, 10),
+		Matcher:   req.Matcher,
+		Reason:    req.Reason,
+		StartsAt:  start,
+		EndsAt:    start.Add(duration),
+		CreatedAt: now,
+		CreatedBy: user,
+	}
+	s.silences = append(s.silences, silence)
+	return silence, s.saveLocked()
+}
+
+// remove deletes a silence of cluster before it expires.
+func (s *silenceStore) remove(cluster, id string) (AlertSilence, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, silence := range s.silences {
+		if silence.ID == id && (silence.Matcher.Cluster == cluster || silence.Matcher.Cluster == "*") {
+			s.silences = append(s.silences[:i], s.silences[i+1:]...)
+			return silence, true, s.saveLocked()
+		}
+	}
+	return AlertSilence{}, false, nil
+}
+
+// activeFor returns the silences of cluster that apply now, or nil when there are none.
+func (s *silenceStore) activeFor(cluster string) []AlertSilence {
+	now := s.now()
+	var result []AlertSilence
+	for _, silence := range s.list(cluster) {
+		if silence.active(now) {
+			result = append(result, silence)
+		}
+	}
+	return result
+}
+
+// silencing returns the active silence that suppresses event, if any.
+func (s *silenceStore) silencing(event NotificationEvent) (AlertSilence, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.pruneLocked(now)
+
+	for _, silence := range s.silences {
+		if silence.active(now) && silence.Matcher.matches(event) {
+			return silence, true
+		}
+	}
+	return AlertSilence{}, false
+}
+
+// alertSilencesHandler lists (GET) or creates (POST) the alert silences of a cluster.
+func alertSilencesHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, alertSilences.list(cluster))
+		return
+	}
+
+	var req silenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON silence object")
+		return
+	}
+	req = req.normalize(cluster)
+	duration, err := req.validate()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_silence", err.Error())
+		return
+	}
+	silence, err := alertSilences.add(req, duration, requestUser(r))
+	if err != nil {
+		log.Printf("silences: failed to persist silence %s: %v", silence.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "silence_store_failed", "failed to persist silence")
+		return
+	}
+	recordAudit(r, auditActionCreateSilence, "", http.StatusCreated, map[string]interface{}{
+		"silenceId": silence.ID, "matcher": silence.Matcher, "reason": silence.Reason, "endsAt": silence.EndsAt,
+	})
+	writeJSON(w, http.StatusCreated, silence)
+}
+
+// alertSilenceHandler deletes a silence before it expires.
+func alertSilenceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, id := vars["cluster"], vars["id"]
+
+	silence, ok, err := alertSilences.remove(cluster, id)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "silence_not_found", fmt.Sprintf("cluster %s has no silence %s", cluster, id))
+		return
+	}
+	if err != nil {
+		log.Printf("silences: failed to persist silences after deleting %s: %v", id, err)
+	}
+	recordAudit(r, auditActionDeleteSilence, "", http.StatusOK, map[string]interface{}{"silenceId": id, "reason": silence.Reason})
+	writeJSON(w, http.StatusOK, silence)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestSilences(t *testing.T, now *time.Time) {
+	t.Helper()
+	originalStore, originalDir := alertSilences, dataDir
+	t.Cleanup(func() { alertSilences, dataDir = originalStore, originalDir })
+	dataDir = t.TempDir()
+	alertSilences = newSilenceStore(func() time.Time { return *now })
+}
+
+func TestSilenceMatcher(t *testing.T) {
+	withTestMetadataStore(t)
+	connectorMetadata.update(alertMetadataCluster, []string{"users-cdc"}, metadataPatch{Tags: []string{"squad-identity"}}, "alice")
+
+	failed := func(connector string) NotificationEvent {
+		return NotificationEvent{Type: eventConnectorFailed, Cluster: "kafka-cluster-id", Connector: connector}
+	}
+	drift := NotificationEvent{Type: eventDriftDetected, Cluster: "dr"}
+	tests := []struct {
+		matcher SilenceMatcher
+		event   NotificationEvent
+		want    bool
+	}{
+		{SilenceMatcher{Cluster: alertMetadataCluster}, failed("orders-sink"), true},
+		{SilenceMatcher{Cluster: "dr"}, failed("orders-sink"), false},
+		{SilenceMatcher{Cluster: "*", Connector: "orders-*"}, failed("orders-sink"), true},
+		{SilenceMatcher{Cluster: alertMetadataCluster, Connector: "orders-*"}, failed("users-cdc"), false},
+		{SilenceMatcher{Cluster: alertMetadataCluster, Tag: "squad-identity"}, failed("users-cdc"), true},
+		{SilenceMatcher{Cluster: alertMetadataCluster, Tag: "squad-identity"}, failed("orders-sink"), false},
+		{SilenceMatcher{Cluster: "dr"}, drift, true},
+		{SilenceMatcher{Cluster: "dr", Connector: "*"}, drift, false},
+	}
+	for _, tt := range tests {
+		if got := tt.matcher.matches(tt.event); got != tt.want {
+			t.Errorf("%+v on %s/%s: expected %v, got %v", tt.matcher, tt.event.Cluster, tt.event.Connector, tt.want, got)
+		}
+	}
+}
+
+func TestAlertSilencesHandlers(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	withTestSilences(t, &now)
+	withTestAuditLog(t, 10)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/alerts/silences", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		alertSilencesHandler(rr, req)
+		return rr
+	}
+
+	rr := create(`{"matcher": {"connector": "orders-*"}, "duration": "2h", "reason": "Database failover"}`)
+	var silence AlertSilence
+	if err := json.Unmarshal(rr.Body.Bytes(), &silence); err != nil || rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if silence.Matcher.Cluster != "default" || !silence.EndsAt.Equal(now.Add(2*time.Hour)) {
+		t.Fatalf("unexpected silence %+v", silence)
+	}
+	later := `{"matcher": {"tag": "pii"}, "duration": "30m", "reason": "Planned upgrade", "startsAt": "2024-05-02T00:00:00Z"}`
+	if rr := create(later); rr.Code != http.StatusCreated {
+		t.Fatalf("expected a scheduled silence to be accepted, got %d", rr.Code)
+	}
+	for _, body := range []string{
+		`{"matcher": {}, "duration": "2h"}`,
+		`{"matcher": {}, "duration": "90d", "reason": "x"}`,
+		`{"matcher": {"connector": "[orders"}, "duration": "1h", "reason": "x"}`,
+	} {
+		if rr := create(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+
+	if _, ok := alertSilences.silencing(NotificationEvent{Type: eventConnectorFailed, Connector: "orders-sink"}); !ok {
+		t.Fatal("expected the silence to suppress the alert")
+	}
+	if active := alertSilences.activeFor("default"); len(active) != 1 || active[0].ID != silence.ID {
+		t.Fatalf("expected only the started silence to be active, got %+v", active)
+	}
+
+	// Silences are deleted once they expire, and the deletion is persisted.
+	now = now.Add(3 * time.Hour)
+	if list := alertSilences.list("default"); len(list) != 1 || list[0].Reason != "Planned upgrade" {
+		t.Fatalf("expected the expired silence to be gone, got %+v", list)
+	}
+	reloaded := newSilenceStore(func() time.Time { return now })
+	if err := reloaded.load(); err != nil || len(reloaded.silences) != 1 {
+		t.Fatalf("expected the pruned silences to be persisted, got %+v %v", reloaded.silences, err)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/default/alerts/silences/2", nil), map[string]string{"cluster": "default", "id": "2"})
+	rr = httptest.NewRecorder()
+	alertSilenceHandler(rr, req)
+	if rr.Code != http.StatusOK || len(alertSilences.list("default")) != 0 {
+		t.Fatalf("expected the silence to be deleted, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	alertSilenceHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted silence, got %d", rr.Code)
+	}
+}
+
+func TestNotifierSkipsSilencedEvents(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	withTestSilences(t, &now)
+	alertSilences.add(silenceRequest{Matcher: SilenceMatcher{Cluster: alertMetadataCluster, Connector: "orders-sink"}, Reason: "Failover"}, time.Hour, "alice")
+
+	channel := recordingChannel{channel: "webhook", sent: make(chan recordedNotification, 2)}
+	n := newNotifier([]notificationChannel{channel}, newNotificationTemplates())
+	n.deliver([]NotificationEvent{
+		{Type: eventConnectorFailed, Connector: "orders-sink", State: "failed"},
+		{Type: eventConnectorFailed, Connector: "users-cdc", State: "failed"},
+	})
+	if len(channel.sent) != 1 {
+		t.Fatalf("expected only the unsilenced alert to be sent, got %d", len(channel.sent))
+	}
+	if sent := <-channel.sent; sent.event.Connector != "users-cdc" {
+		t.Fatalf("expected the users-cdc alert, got %+v", sent.event)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	auditActionCleanupStale = "CLEANUP_STALE"
+	dryRunCleanupStale      = "cleanup-stale"
+
+	// staleTopicsTimeout bounds the topic listing behind a stale check.
+	staleTopicsTimeout = 10 * time.Second
+)
+
+// Reasons a connector is reported as stale.
+const (
+	staleReasonPaused        = "paused"
+	staleReasonIdle          = "idle"
+	staleReasonMissingTopics = "missing_topics"
+)
+
+// Actions suggested for a stale connector. Only delete and stop are applied by the
+// cleanup-stale cluster action; review needs a person to look.
+const (
+	staleActionDelete = "delete"
+	staleActionStop   = "stop"
+	staleActionReview = "review"
+)
+
+var (
+	// stalePausedDays is how long a connector may stay paused before it is reported.
+	stalePausedDays = getEnv("STALE_PAUSED_DAYS", "7")
+
+	kafkaTopics topicLister = newKafkaTopicReader(splitList(kafkaBootstrapServers))
+)
+
+// topicLister lists the topics that exist in Kafka.
+type topicLister interface {
+	enabled() bool
+	topicNames(ctx context.Context) (map[string]bool, error)
+}
+
+// StaleReason is one reason a connector looks abandoned.
+type StaleReason struct {
+	Reason string     `json:"reason"`
+	Detail string     `json:"detail"`
+	Since  *time.Time `json:"since,omitempty"`
+	Topics []string   `json:"topics,omitempty"`
+}
+
+// StaleConnector is a connector that looks abandoned, with what to do about it.
+type StaleConnector struct {
+	Connector       string        `json:"connector"`
+	Type            string        `json:"type,omitempty"`
+	State           string        `json:"state"`
+	Reasons         []StaleReason `json:"reasons"`
+	SuggestedAction string        `json:"suggestedAction"`
+	Suggestion      string        `json:"suggestion"`
+}
+
+// StaleReport is returned by GET /api/{cluster}/connectors/stale. Unavailable lists the
+// checks that could not run: idle needs JOLOKIA_URL and missing_topics needs
+// KAFKA_BOOTSTRAP_SERVERS.
+type StaleReport struct {
+	EvaluatedAt time.Time        `json:"evaluatedAt"`
+	PausedDays  int              `json:"pausedDays"`
+	Unavailable []string         `json:"unavailable"`
+	Connectors  []StaleConnector `json:"connectors"`
+}
+
+// StaleCleanupRequest is the body of the cleanup-stale cluster action.
+type StaleCleanupRequest struct {
+	Connectors []string `json:"connectors"`
+}
+
+// StaleCleanupResult is returned by the cleanup-stale cluster action. Skipped maps the
+// requested connectors that were left alone to the reason.
+type StaleCleanupResult struct {
+	Action  string            `json:"action"`
+	Deleted []string          `json:"deleted"`
+	Stopped []string          `json:"stopped"`
+	Skipped map[string]string `json:"skipped"`
+	Failed  map[string]string `json:"failed"`
+}
+
+// staleInputs is what the stale checks know about one connector.
+type staleInputs struct {
+	Name  string
+	Type  string
+	State string
+	// StateSince is when the connector was last seen entering its current state, and
+	// StateFirstSeen is true when it was already in it when the history started.
+	StateSince     *time.Time
+	StateFirstSeen bool
+	// Metrics holds the retained samples; nil when metrics are not collected.
+	Metrics []ConnectorMetrics
+	// Topics holds the connector's topics, and Existing the topics that exist in Kafka;
+	// Existing is nil when Kafka cannot be reached.
+	Topics   []string
+	Existing map[string]bool
+}
+
+// connectorTopicsOf returns the topics a connector is known to use: the topics of a
+// sink's config and the active topics reported by Connect.
+func connectorTopicsOf(config map[string]string, active []string) []string {
+	var topics []string
+	for _, topic := range append(splitList(config["topics"]), active...) {
+		if !containsString(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// evaluateStaleConnector returns the connector's stale report, or false when nothing
+// about it looks abandoned.
+func evaluateStaleConnector(in staleInputs, pausedFor time.Duration, now time.Time) (StaleConnector, bool) {
+	var reasons []StaleReason
+
+	if in.State == "paused" && in.StateSince != nil && now.Sub(*in.StateSince) >= pausedFor {
+		days := int(now.Sub(*in.StateSince) / (24 * time.Hour))
+		detail := fmt.Sprintf("paused for %d day(s)", days)
+		if in.StateFirstSeen {
+			detail = fmt.Sprintf("paused for at least %d day(s)", days)
+		}
+		reasons = append(reasons, StaleReason{Reason: staleReasonPaused, Detail: detail, Since: in.StateSince})
+	}
+
+	if in.State == "running" && len(in.Metrics) >= 2 {
+		idle := true
+		for _, sample := range in.Metrics {
+			if sample.RecordsInPerSec > 0 || sample.RecordsOutPerSec > 0 {
+				idle = false
+				break
+			}
+		}
+		if idle {
+			since := in.Metrics[0].Timestamp
+			reasons = append(reasons, StaleReason{
+				Reason: staleReasonIdle,
+				Detail: fmt.Sprintf("no records in or out over the last %s", now.Sub(since).Round(time.Minute)),
+				Since:  &since,
+			})
+		}
+	}
+
+	missing := []string{}
+	if in.Existing != nil {
+		for _, topic := range in.Topics {
+			if !in.Existing[topic] {
+				missing = append(missing, topic)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		reasons = append(reasons, StaleReason{
+			Reason: staleReasonMissingTopics,
+			Detail: fmt.Sprintf("%d of %d topic(s) no longer exist", len(missing), len(in.Topics)),
+			Topics: missing,
+		})
+	}
+
+	if len(reasons) == 0 {
+		return StaleConnector{}, false
+	}
+	stale := StaleConnector{Connector: in.Name, Type: in.Type, State: in.State, Reasons: reasons}
+	switch {
+	case len(missing) > 0 && len(missing) == len(in.Topics):
+		stale.SuggestedAction, stale.Suggestion = staleActionDelete, "none of its topics exist any more; delete it"
+	case len(missing) > 0:
+		stale.SuggestedAction, stale.Suggestion = staleActionReview, "some of its topics were deleted; update its topics or delete it"
+	case reasons[0].Reason == staleReasonPaused:
+		stale.SuggestedAction, stale.Suggestion = staleActionDelete, "it has been paused for a long time; delete it if it is no longer needed"
+	default:
+		stale.SuggestedAction, stale.Suggestion = staleActionStop, "it is running without moving records; stop it to free its tasks and keep the config"
+	}
+	return stale, true
+}
+
+// staleRequestOptions reads pausedDays from the query, defaulting to STALE_PAUSED_DAYS.
+func staleRequestOptions(query url.Values) (int, error) {
+	fallback, err := loadStalePausedDays()
+	if err != nil {
+		return 0, err
+	}
+	return positiveIntParam(query, "pausedDays", fallback)
+}
+
+// loadStalePausedDays parses STALE_PAUSED_DAYS.
+func loadStalePausedDays() (int, error) {
+	days, err := strconv.Atoi(strings.TrimSpace(stalePausedDays))
+	if err != nil || days <= 0 {
+		return 0, &configError{name: "STALE_PAUSED_DAYS", value: stalePausedDays}
+	}
+	return days, nil
+}
+
+// buildStaleReport runs the stale checks over every connector of cluster.
+func buildStaleReport(ctx context.Context, cluster string, pausedDays int) (StaleReport, error) {
+	client, baseURL := connectClientFor(cluster, routeRead), connectURLFor(cluster)
+	connectors, err := fetchExpandedConnectorStatuses(ctx, client, baseURL)
+	if err != nil {
+		return StaleReport{}, err
+	}
+
+	now := time.Now().UTC()
+	report := StaleReport{EvaluatedAt: now, PausedDays: pausedDays, Unavailable: []string{}, Connectors: []StaleConnector{}}
+
+	var existing map[string]bool
+	active := map[string][]string{}
+	if kafkaTopics.enabled() {
+		topicsCtx, cancel := context.WithTimeout(ctx, staleTopicsTimeout)
+		existing, err = kafkaTopics.topicNames(topicsCtx)
+		cancel()
+		if err != nil {
+			log.Printf("stale connectors %s: %v", cluster, err)
+			existing = nil
+		} else {
+			active = fetchActiveTopicsOf(ctx, client, baseURL, connectors)
+		}
+	}
+	if existing == nil {
+		report.Unavailable = append(report.Unavailable, staleReasonMissingTopics)
+	}
+	if !connectorMetricsCollector.enabled() {
+		report.Unavailable = append(report.Unavailable, staleReasonIdle)
+	}
+
+	names := make([]string, 0, len(connectors))
+	for name := range connectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		connector := connectors[name]
+		in := staleInputs{
+			Name:     name,
+			Type:     connector.Info.Type,
+			State:    normalizeState(connector.Status.Connector.State),
+			Topics:   connectorTopicsOf(connector.Info.Config, active[name]),
+			Existing: existing,
+		}
+		if state, since, first, ok := connectorStateHistory.currentState(cluster, name); ok && state == in.State {
+			in.StateSince, in.StateFirstSeen = &since, first
+		}
+		if connectorMetricsCollector.enabled() {
+			in.Metrics = connectorMetricsCollector.history(name, connectorMetricsCollector.retention)
+		}
+		if stale, ok := evaluateStaleConnector(in, time.Duration(pausedDays)*24*time.Hour, now); ok {
+			report.Connectors = append(report.Connectors, stale)
+		}
+	}
+	return report, nil
+}
+
+// staleConnectorsHandler reports connectors that look abandoned, with a suggested
+// action for each.
+func staleConnectorsHandler(w http.ResponseWriter, r *http.Request) {
+	pausedDays, err := staleRequestOptions(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	report, err := buildStaleReport(r.Context(), mux.Vars(r)["cluster"], pausedDays)
+	if err != nil {
+		writeConnectorsFetchError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// cleanupStaleConnectors applies the suggested action to the listed connectors. The
+// report is built again first, so a connector that is no longer stale, or whose
+// suggestion needs a review, is skipped rather than deleted.
+func cleanupStaleConnectors(w http.ResponseWriter, r *http.Request) {
+	var req StaleCleanupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Connectors) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", `request body must list the connectors to clean up: {"connectors": ["..."]}`)
+		return
+	}
+	pausedDays, err := staleRequestOptions(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	cluster := mux.Vars(r)["cluster"]
+	report, err := buildStaleReport(r.Context(), cluster, pausedDays)
+	if err != nil {
+		writeConnectorsFetchError(w, err)
+		return
+	}
+	stale := make(map[string]StaleConnector, len(report.Connectors))
+	for _, connector := range report.Connectors {
+		stale[connector.Connector] = connector
+	}
+
+	result := StaleCleanupResult{Action: dryRunCleanupStale, Deleted: []string{}, Stopped: []string{}, Skipped: map[string]string{}, Failed: map[string]string{}}
+	var targets []StaleConnector
+	for _, name := range mergeNames(nil, req.Connectors) {
+		connector, ok := stale[name]
+		switch {
+		case !ok:
+			result.Skipped[name] = "not stale"
+		case connector.SuggestedAction == staleActionReview:
+			result.Skipped[name] = connector.Suggestion
+		default:
+			targets = append(targets, connector)
+		}
+	}
+
+	if isDryRun(r) {
+		var descriptions, names []string
+		for _, connector := range targets {
+			names = append(names, connector.Connector)
+			descriptions = append(descriptions, connector.SuggestedAction+" "+connector.Connector)
+		}
+		description := "nothing to clean up"
+		if len(descriptions) > 0 {
+			description = strings.Join(descriptions, ", ")
+		}
+		writeJSON(w, http.StatusOK, DryRunResult{
+			DryRun:       true,
+			Operation:    dryRunCleanupStale,
+			Description:  fmt.Sprintf("%s; %d connector(s) skipped", description, len(result.Skipped)),
+			WouldSucceed: true,
+			Connectors:   names,
+		})
+		return
+	}
+
+	baseURL := connectURLFor(cluster)
+	upstream, _ := url.Parse(baseURL)
+	client := connectClientFor(cluster, routeWrite)
+	for _, connector := range targets {
+		name := connector.Connector
+		var err error
+		if connector.SuggestedAction == staleActionDelete {
+			err = sendConnectRequest(r.Context(), client, http.MethodDelete, joinURL(baseURL, "connectors", url.PathEscape(name)), nil)
+		} else {
+			err = sendConnectRequest(r.Context(), client, http.MethodPut, joinURL(baseURL, "connectors", url.PathEscape(name), "stop"), nil)
+		}
+		if upstream != nil {
+			configCache.invalidateConnector(r.Context(), upstream, name)
+		}
+		switch {
+		case err != nil:
+			result.Failed[name] = err.Error()
+		case connector.SuggestedAction == staleActionDelete:
+			result.Deleted = append(result.Deleted, name)
+			if err := secretRefs.set(cluster, name, nil); err != nil {
+				log.Printf("cleanup-stale: failed to forget secret placeholders for %s: %v", name, err)
+			}
+		default:
+			result.Stopped = append(result.Stopped, name)
+		}
+	}
+
+	status := http.StatusOK
+	if len(result.Failed) > 0 {
+		status = http.StatusBadGateway
+	}
+	recordAudit(r, auditActionCleanupStale, "", status, map[string]interface{}{
+		"deleted": result.Deleted,
+		"stopped": result.Stopped,
+		"skipped": result.Skipped,
+		"failed":  result.Failed,
+	})
+	writeJSON(w, status, result)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type fakeTopicLister map[string]bool
+
+func (f fakeTopicLister) enabled() bool { return true }
+
+func (f fakeTopicLister) topicNames(context.Context) (map[string]bool, error) {
+	return f, nil
+}
+
+func TestEvaluateStaleConnector(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	tenDaysAgo := now.Add(-10 * 24 * time.Hour)
+	idle := []ConnectorMetrics{{Timestamp: now.Add(-time.Hour)}, {Timestamp: now.Add(-30 * time.Minute)}}
+	existing := map[string]bool{"orders": true}
+
+	tests := []struct {
+		name    string
+		in      staleInputs
+		stale   bool
+		action  string
+		reasons string
+	}{
+		{"recently paused", staleInputs{State: "paused", StateSince: &now}, false, "", ""},
+		{"paused for days", staleInputs{State: "paused", StateSince: &tenDaysAgo}, true, staleActionDelete, "paused"},
+		{"paused without history", staleInputs{State: "paused"}, false, "", ""},
+		{"idle", staleInputs{State: "running", Metrics: idle}, true, staleActionStop, "idle"},
+		{"one sample", staleInputs{State: "running", Metrics: idle[:1]}, false, "", ""},
+		{"moving records", staleInputs{State: "running", Metrics: append([]ConnectorMetrics{{RecordsOutPerSec: 3}}, idle...)}, false, "", ""},
+		{"topics gone", staleInputs{State: "running", Metrics: idle, Topics: []string{"clicks"}, Existing: existing}, true, staleActionDelete, "idle,missing_topics"},
+		{"some topics gone", staleInputs{State: "running", Topics: []string{"clicks", "orders"}, Existing: existing}, true, staleActionReview, "missing_topics"},
+		{"kafka unreachable", staleInputs{State: "running", Topics: []string{"clicks"}}, false, "", ""},
+	}
+	for _, tt := range tests {
+		got, stale := evaluateStaleConnector(tt.in, 7*24*time.Hour, now)
+		var reasons []string
+		for _, reason := range got.Reasons {
+			reasons = append(reasons, reason.Reason)
+		}
+		if stale != tt.stale || got.SuggestedAction != tt.action || strings.Join(reasons, ",") != tt.reasons {
+			t.Errorf("%s: got stale=%v action=%q reasons=%v", tt.name, stale, got.SuggestedAction, reasons)
+		}
+	}
+}
+
+func TestStaleConnectorsHandler(t *testing.T) {
+	withTestSecrets(t, nil)
+	logger := withTestAuditLog(t, 10)
+	originalHistory, originalCollector, originalTopics, originalDir := connectorStateHistory, connectorMetricsCollector, kafkaTopics, dataDir
+	t.Cleanup(func() {
+		connectorStateHistory, connectorMetricsCollector, kafkaTopics, dataDir = originalHistory, originalCollector, originalTopics, originalDir
+	})
+	dataDir = t.TempDir()
+	recorded := time.Now().UTC().Add(-10 * 24 * time.Hour)
+	connectorStateHistory = newStateHistory(30*24*time.Hour, func() time.Time { return recorded })
+	connectorMetricsCollector = newMetricsCollector(nil, time.Hour, time.Now)
+	kafkaTopics = fakeTopicLister{"orders": true}
+
+	statuses := `[
+		{"name": "archive", "connector": {"state": "RUNNING"}, "tasks": []},
+		{"name": "clicks-sink", "connector": {"state": "RUNNING"}, "tasks": []},
+		{"name": "orders-sink", "connector": {"state": "RUNNING"}, "tasks": []}
+	]`
+	connectorStateHistory.record("default", testStatuses(t, statuses))
+	recorded = recorded.Add(time.Hour)
+	connectorStateHistory.record("default", testStatuses(t, strings.Replace(statuses, `"archive", "connector": {"state": "RUNNING"}`, `"archive", "connector": {"state": "PAUSED"}`, 1)))
+
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors":
+			w.Write([]byte(`{
+				"archive": {"info": {"type": "sink", "config": {"topics": "orders"}}, "status": {"connector": {"state": "PAUSED"}, "tasks": []}},
+				"clicks-sink": {"info": {"type": "sink", "config": {"topics": "clicks"}}, "status": {"connector": {"state": "RUNNING"}, "tasks": []}},
+				"orders-sink": {"info": {"type": "sink", "config": {"topics": "orders,clicks"}}, "status": {"connector": {"state": "RUNNING"}, "tasks": []}}
+			}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/topics"):
+			http.NotFound(w, r)
+		default:
+			calls = append(calls, r.Method+" "+r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connectors/stale", nil), map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	staleConnectorsHandler(rr, req)
+	var report StaleReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(report.Connectors) != 3 || strings.Join(report.Unavailable, ",") != staleReasonIdle {
+		t.Fatalf("unexpected report %s", rr.Body.String())
+	}
+	for i, want := range []string{"archive delete", "clicks-sink delete", "orders-sink review"} {
+		if got := report.Connectors[i].Connector + " " + report.Connectors[i].SuggestedAction; got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connectors/stale?pausedDays=30", nil), map[string]string{"cluster": "default"})
+	rr = httptest.NewRecorder()
+	staleConnectorsHandler(rr, req)
+	if strings.Contains(rr.Body.String(), `"archive"`) {
+		t.Fatalf("expected the threshold to be raised, got %s", rr.Body.String())
+	}
+
+	cleanup := func(query, body string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/default/cluster/actions/cleanup-stale"+query, strings.NewReader(body)), map[string]string{"cluster": "default", "action": "cleanup-stale"})
+		rr := httptest.NewRecorder()
+		clusterActionHandler(rr, req)
+		return rr
+	}
+	if rr := cleanup("", `{"connectors": []}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without connectors, got %d", rr.Code)
+	}
+
+	body := `{"connectors": ["archive", "orders-sink", "unknown"]}`
+	if rr := cleanup("?dryRun=true", body); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "delete archive") || len(calls) != 0 {
+		t.Fatalf("unexpected dry run %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = cleanup("", body)
+	var result StaleCleanupResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Join(result.Deleted, ",") != "archive" || len(result.Skipped) != 2 || result.Skipped["unknown"] != "not stale" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if strings.Join(calls, ",") != "DELETE /connectors/archive" {
+		t.Fatalf("unexpected Connect calls %v", calls)
+	}
+	if entries := logger.Query(AuditFilter{Action: auditActionCleanupStale}); len(entries) != 1 {
+		t.Fatalf("expected the cleanup to be audited, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const standbyStateFile = "standby-state.json"
+
+const auditActionFailover = "FAILOVER"
+
+var (
+	// standbyClustersSpec marks clusters as cold standbys of a primary, e.g. "dr=default".
+	// A standby mirrors the primary's connectors in the STOPPED state until it is failed
+	// over. Standby clusters must be listed in KAFKA_CONNECT_CLUSTERS.
+	standbyClustersSpec = getEnv("STANDBY_CLUSTERS", "")
+	standbySyncInterval = getEnv("STANDBY_SYNC_INTERVAL", "60s")
+
+	standbys = newStandbyRegistry(nil, time.Now)
+)
+
+// StandbySyncResult describes what one sync pass changed on a standby cluster.
+type StandbySyncResult struct {
+	Created  []string          `json:"created"`
+	Updated  []string          `json:"updated"`
+	Stopped  []string          `json:"stopped"`
+	Orphaned []string          `json:"orphaned"` // on the standby but no longer on the primary
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// StandbyStatus is returned by GET /api/{cluster}/standby.
+type StandbyStatus struct {
+	Cluster      string             `json:"cluster"`
+	Primary      string             `json:"primary"`
+	Role         string             `json:"role"` // standby or active
+	FailedOverAt *time.Time         `json:"failedOverAt,omitempty"`
+	FailedOverBy string             `json:"failedOverBy,omitempty"`
+	LastSyncAt   *time.Time         `json:"lastSyncAt,omitempty"`
+	LastError    string             `json:"lastError,omitempty"`
+	LastSync     *StandbySyncResult `json:"lastSync,omitempty"`
+}
+
+// FailoverResult is returned by POST /api/{cluster}/failover.
+type FailoverResult struct {
+	Cluster      string            `json:"cluster"`
+	Primary      string            `json:"primary"`
+	FailedOverAt time.Time         `json:"failedOverAt"`
+	Resumed      []string          `json:"resumed"`
+	Failed       map[string]string `json:"failed"`
+}
+
+// standbyFailover is the persisted failover state of a standby cluster.
+type standbyFailover struct {
+	At time.Time `json:"at"`
+	By string    `json:"by,omitempty"`
+}
+
+// standbyRegistry tracks the standby clusters, their last sync and whether they have
+// been failed over. Failover state is persisted so a restart does not put an active
+// cluster back into standby.
+type standbyRegistry struct {
+	mu         sync.Mutex
+	now        func() time.Time
+	primaries  map[string]string // standby -> primary
+	failovers  map[string]standbyFailover
+	lastSyncAt map[string]time.Time
+	lastSync   map[string]StandbySyncResult
+	lastError  map[string]string
+}
+
+func newStandbyRegistry(primaries map[string]string, now func() time.Time) *standbyRegistry {
+	if primaries == nil {
+		primaries = map[string]string{}
+	}
+	return &standbyRegistry{
+		now:        now,
+		primaries:  primaries,
+		failovers:  make(map[string]standbyFailover),
+		lastSyncAt: make(map[string]time.Time),
+		lastSync:   make(map[string]StandbySyncResult),
+		lastError:  make(map[string]string),
+	}
+}
+
+// parseStandbyClusters parses STANDBY_CLUSTERS against the configured cluster URLs.
+func parseStandbyClusters(spec string, urls map[string]string) (map[string]string, error) {
+	primaries, err := parsePairs("STANDBY_CLUSTERS", spec)
+	if err != nil {
+		return nil, err
+	}
+	for standby, primary := range primaries {
+		if _, ok := urls[standby]; !ok {
+			return nil, fmt.Errorf("STANDBY_CLUSTERS: standby cluster %q must be listed in KAFKA_CONNECT_CLUSTERS", standby)
+		}
+		if _, chained := primaries[primary]; chained {
+			return nil, fmt.Errorf("STANDBY_CLUSTERS: primary %q of %q is itself a standby", primary, standby)
+		}
+		if primaryURL, standbyURL := lookupClusterURL(urls, primary), urls[standby]; primaryURL == standbyURL {
+			return nil, fmt.Errorf("STANDBY_CLUSTERS: %q and its primary %q point at the same Kafka Connect URL", standby, primary)
+		}
+	}
+	return primaries, nil
+}
+
+func (s *standbyRegistry) load() error {
+	failovers := make(map[string]standbyFailover)
+	if err := loadJSON(standbyStateFile, &failovers); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failovers = failovers
+	return nil
+}
+
+func (s *standbyRegistry) enabled() bool {
+	return len(s.primaries) > 0
+}
+
+// primary returns the primary of a standby cluster; ok is false for other clusters.
+func (s *standbyRegistry) primary(cluster string) (string, bool) {
+	primary, ok := s.primaries[cluster]
+	return primary, ok
+}
+
+// inStandby reports whether cluster is a standby that has not been failed over.
+func (s *standbyRegistry) inStandby(cluster string) bool {
+	if _, ok := s.primaries[cluster]; !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, failedOver := s.failovers[cluster]
+	return !failedOver
+}
+
+// markFailedOver records the failover of a standby; repeated failovers keep the first
+// timestamp so retries after a partial failure do not rewrite history.
+func (s *standbyRegistry) markFailedOver(cluster, user string) (standbyFailover, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.failovers[cluster]; ok {
+		return existing, nil
+	}
+	failover := standbyFailover{At: s.now().UTC(), By: user}
+	s.failovers[cluster] = failover
+	return failover, saveJSON(standbyStateFile, s.failovers)
+}
+
+func (s *standbyRegistry) recordSync(cluster string, result StandbySyncResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSyncAt[cluster] = s.now().UTC()
+	s.lastSync[cluster] = result
+	if err != nil {
+		s.lastError[cluster] = err.Error()
+	} else {
+		delete(s.lastError, cluster)
+	}
+}
+
+func (s *standbyRegistry) status(cluster string) (StandbyStatus, bool) {
+	primary, ok := s.primaries[cluster]
+	if !ok {
+		return StandbyStatus{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := StandbyStatus{Cluster: cluster, Primary: primary, Role: "standby", LastError: s.lastError[cluster]}
+	if failover, ok := s.failovers[cluster]; ok {
+		at := failover.At
+		status.Role = "active"
+		status.FailedOverAt = &at
+		status.FailedOverBy = failover.By
+	}
+	if at, ok := s.lastSyncAt[cluster]; ok {
+		result := s.lastSync[cluster]
+		status.LastSyncAt = &at
+		status.LastSync = &result
+	}
+	return status, true
+}
+
+// connectorInfo is the subset of GET /connectors?expand=info&expand=status used by the
+// standby sync.
+type connectorInfo struct {
+	Info struct {
+		Config map[string]string `json:"config"`
+	} `json:"info"`
+	Status struct {
+		Connector struct {
+			State string `json:"state"`
+		} `json:"connector"`
+	} `json:"status"`
+}
+
+func fetchExpandedConnectors(ctx context.Context, client *http.Client, baseURL string) (map[string]connectorInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(baseURL, "connectors")+"?expand=info&expand=status", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching connectors from %s: %d", baseURL, resp.StatusCode)
+	}
+
+	connectors := make(map[string]connectorInfo)
+	if err := json.NewDecoder(resp.Body).Decode(&connectors); err != nil {
+		return nil, fmt.Errorf("decode connectors from %s: %w", baseURL, err)
+	}
+	return connectors, nil
+}
+
+// sendConnectRequest performs a mutating Kafka Connect call and turns non-2xx responses
+// into errors carrying Connect's message.
+func sendConnectRequest(ctx context.Context, client *http.Client, method, target string, payload interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &connectUnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: HTTP %d: %s", method, target, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// syncStandby mirrors the primary's connectors onto a standby cluster. New connectors
+// are created in the STOPPED state (Connect 3.7+), changed configs are updated and any
+// connector that is running on the standby is stopped again. Connectors that only exist
+// on the standby are reported but left alone.
+func syncStandby(ctx context.Context, client *http.Client, primaryURL, standbyURL string) (StandbySyncResult, error) {
+	result := StandbySyncResult{Created: []string{}, Updated: []string{}, Stopped: []string{}, Orphaned: []string{}}
+
+	source, err := fetchExpandedConnectors(ctx, client, primaryURL)
+	if err != nil {
+		return result, fmt.Errorf("primary: %w", err)
+	}
+	target, err := fetchExpandedConnectors(ctx, client, standbyURL)
+	if err != nil {
+		return result, fmt.Errorf("standby: %w", err)
+	}
+
+	names := make([]string, 0, len(source))
+	for name := range source {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fail := func(name string, err error) {
+		if result.Errors == nil {
+			result.Errors = make(map[string]string)
+		}
+		result.Errors[name] = err.Error()
+	}
+
+	for _, name := range names {
+		config := source[name].Info.Config
+		escaped := url.PathEscape(name)
+
+		existing, ok := target[name]
+		if !ok {
+			err := sendConnectRequest(ctx, client, http.MethodPost, joinURL(standbyURL, "connectors"), map[string]interface{}{
+				"name":          name,
+				"config":        config,
+				"initial_state": "STOPPED",
+			})
+			if err != nil {
+				fail(name, err)
+				continue
+			}
+			result.Created = append(result.Created, name)
+			continue
+		}
+
+		if !reflect.DeepEqual(existing.Info.Config, config) {
+			if err := sendConnectRequest(ctx, client, http.MethodPut, joinURL(standbyURL, "connectors", escaped, "config"), config); err != nil {
+				fail(name, err)
+				continue
+			}
+			result.Updated = append(result.Updated, name)
+		}
+
+		if normalizeState(existing.Status.Connector.State) != "stopped" {
+			if err := sendConnectRequest(ctx, client, http.MethodPut, joinURL(standbyURL, "connectors", escaped, "stop"), nil); err != nil {
+				fail(name, err)
+				continue
+			}
+			result.Stopped = append(result.Stopped, name)
+		}
+	}
+
+	for name := range target {
+		if _, ok := source[name]; !ok {
+			result.Orphaned = append(result.Orphaned, name)
+		}
+	}
+	sort.Strings(result.Orphaned)
+
+	if len(result.Errors) > 0 {
+		return result, fmt.Errorf("%d connector(s) failed to sync", len(result.Errors))
+	}
+	return result, nil
+}
+
+// syncAll runs one sync pass for every standby that has not been failed over and is not
+// in maintenance mode.
+func (s *standbyRegistry) syncAll(ctx context.Context) {
+	client := newConnectClient(0)
+	for standby, primary := range s.primaries {
+		if !s.inStandby(standby) || maintenance.active(standby) {
+			continue
+		}
+		result, err := syncStandby(ctx, client, connectURLFor(primary), connectURLFor(standby))
+		s.recordSync(standby, result, err)
+		if err != nil {
+			log.Printf("standby %s: sync from %s: %v", standby, primary, err)
+		} else if changed := len(result.Created) + len(result.Updated) + len(result.Stopped); changed > 0 {
+			log.Printf("standby %s: synced from %s (%d created, %d updated, %d stopped)", standby, primary, len(result.Created), len(result.Updated), len(result.Stopped))
+		}
+	}
+}
+
+func (s *standbyRegistry) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		s.syncAll(ctx)
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// standbyEditableSuffixes are the connector paths that only change console-side
+// metadata, or only read, and so stay available on a standby.
+var standbyEditableSuffixes = []string{"/metadata", "/metadata/bulk", "/tags", "/annotations", "/config/diff"}
+
+func standbyEditable(rest string) bool {
+	for _, suffix := range standbyEditableSuffixes {
+		if strings.HasSuffix(rest, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// standbyGuard rejects connector mutations on clusters that are still in standby; their
+// connectors are owned by the sync until the cluster is failed over. Console-side
+// metadata stays editable and config diffs, which only read, stay available.
+func standbyGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cluster := mux.Vars(r)["cluster"]
+		rest := strings.TrimPrefix(r.URL.Path, "/api/"+cluster)
+		guarded := strings.HasPrefix(rest, "/connectors") || strings.HasPrefix(rest, "/cluster/actions") || rest == "/deploy"
+		if guarded && !standbyEditable(rest) && standbys.inStandby(cluster) {
+			primary, _ := standbys.primary(cluster)
+			writeJSONError(w, http.StatusConflict, "cluster_in_standby",
+				fmt.Sprintf("cluster %s is a standby of %s; make changes on the primary or fail over first", cluster, primary))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// standbyStatusHandler reports the role and last sync of a standby cluster.
+func standbyStatusHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	status, ok := standbys.status(cluster)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "not_standby", fmt.Sprintf("cluster %s is not configured as a standby", cluster))
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// failoverHandler promotes a standby cluster: the sync stops and every connector on the
+// cluster is resumed. The operation is idempotent, so it can be retried when some
+// connectors failed to resume.
+func failoverHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	primary, ok := standbys.primary(cluster)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "not_standby", fmt.Sprintf("cluster %s is not configured as a standby", cluster))
+		return
+	}
+
+	failover, err := standbys.markFailedOver(cluster, requestUser(r))
+	if err != nil {
+		log.Printf("standby %s: failed to persist failover state: %v", cluster, err)
+	}
+
+	client := connectClientFor(cluster, routeWrite)
+	baseURL := connectURLFor(cluster)
+	names, err := fetchConnectorNames(r.Context(), client, baseURL)
+	if err != nil {
+		recordAudit(r, auditActionFailover, "", http.StatusBadGateway, map[string]interface{}{"primary": primary, "error": err.Error()})
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+	sort.Strings(names)
+
+	result := FailoverResult{Cluster: cluster, Primary: primary, FailedOverAt: failover.At, Resumed: []string{}, Failed: map[string]string{}}
+	for _, name := range names {
+		if err := sendConnectRequest(r.Context(), client, http.MethodPut, joinURL(baseURL, "connectors", url.PathEscape(name), "resume"), nil); err != nil {
+			result.Failed[name] = err.Error()
+			continue
+		}
+		result.Resumed = append(result.Resumed, name)
+	}
+
+	status := http.StatusOK
+	if len(result.Failed) > 0 {
+		status = http.StatusBadGateway
+	}
+	recordAudit(r, auditActionFailover, "", status, map[string]interface{}{
+		"primary": primary,
+		"resumed": result.Resumed,
+		"failed":  result.Failed,
+	})
+	writeJSON(w, status, result)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeConnectCluster is an in-memory Kafka Connect serving the endpoints used by the
+// standby sync and failover.
+type fakeConnectCluster struct {
+	mu      sync.Mutex
+	configs map[string]map[string]string
+	states  map[string]string
+	calls   []string
+}
+
+func newFakeConnectCluster(t *testing.T, configs map[string]map[string]string, state string) (*fakeConnectCluster, *httptest.Server) {
+	t.Helper()
+	cluster := &fakeConnectCluster{configs: configs, states: map[string]string{}}
+	for name := range configs {
+		cluster.states[name] = state
+	}
+	server := httptest.NewServer(http.HandlerFunc(cluster.serveHTTP))
+	t.Cleanup(server.Close)
+	return cluster, server
+}
+
+func (c *fakeConnectCluster) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodGet {
+		c.calls = append(c.calls, r.Method+" "+r.URL.Path)
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/connectors" && r.URL.Query().Has("expand"):
+		expanded := map[string]interface{}{}
+		for name, config := range c.configs {
+			expanded[name] = map[string]interface{}{
+				"info":   map[string]interface{}{"name": name, "config": config},
+				"status": map[string]interface{}{"name": name, "connector": map[string]string{"state": c.states[name]}},
+			}
+		}
+		json.NewEncoder(w).Encode(expanded)
+	case r.Method == http.MethodGet && r.URL.Path == "/connectors":
+		names := []string{}
+		for name := range c.configs {
+			names = append(names, name)
+		}
+		json.NewEncoder(w).Encode(names)
+	case r.Method == http.MethodPost && r.URL.Path == "/connectors":
+		var body struct {
+			Name         string            `json:"name"`
+			Config       map[string]string `json:"config"`
+			InitialState string            `json:"initial_state"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		c.configs[body.Name] = body.Config
+		c.states[body.Name] = body.InitialState
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	case len(parts) == 3 && r.Method == http.MethodPut && parts[2] == "config":
+		var config map[string]string
+		json.NewDecoder(r.Body).Decode(&config)
+		c.configs[parts[1]] = config
+		w.Write([]byte(`{}`))
+	case len(parts) == 3 && r.Method == http.MethodPut && parts[2] == "stop":
+		c.states[parts[1]] = "STOPPED"
+		w.WriteHeader(http.StatusAccepted)
+	case len(parts) == 3 && r.Method == http.MethodPut && parts[2] == "resume":
+		if parts[1] == "broken" {
+			http.Error(w, `{"message":"worker unavailable"}`, http.StatusInternalServerError)
+			return
+		}
+		c.states[parts[1]] = "RUNNING"
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func withTestStandbys(t *testing.T, primaries map[string]string) *standbyRegistry {
+	t.Helper()
+	original := standbys
+	registry := newStandbyRegistry(primaries, func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) })
+	standbys = registry
+	t.Cleanup(func() { standbys = original })
+	return registry
+}
+
+func TestParseStandbyClusters(t *testing.T) {
+	urls := map[string]string{"dr": "http://connect-dr:8083", "dr2": "http://connect-dr2:8083"}
+
+	primaries, err := parseStandbyClusters("dr=default", urls)
+	if err != nil || primaries["dr"] != "default" {
+		t.Fatalf("unexpected result %v, %v", primaries, err)
+	}
+
+	for _, spec := range []string{"unknown=default", "dr=dr2,dr2=default", "dr=dr"} {
+		if _, err := parseStandbyClusters(spec, urls); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestSyncStandby(t *testing.T) {
+	_, primary := newFakeConnectCluster(t, map[string]map[string]string{
+		"alpha": {"name": "alpha", "connector.class": "FileStreamSource", "file": "/tmp/a"},
+		"beta":  {"name": "beta", "connector.class": "FileStreamSink", "topics": "orders"},
+		"gamma": {"name": "gamma", "connector.class": "FileStreamSink", "topics": "events"},
+	}, "RUNNING")
+	standby, standbyServer := newFakeConnectCluster(t, map[string]map[string]string{
+		"beta":  {"name": "beta", "connector.class": "FileStreamSink", "topics": "old"},
+		"gamma": {"name": "gamma", "connector.class": "FileStreamSink", "topics": "events"},
+		"stale": {"name": "stale", "connector.class": "FileStreamSink"},
+	}, "STOPPED")
+	standby.states["gamma"] = "RUNNING"
+
+	result, err := syncStandby(context.Background(), http.DefaultClient, primary.URL, standbyServer.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Created) != 1 || result.Created[0] != "alpha" {
+		t.Fatalf("expected alpha to be created, got %v", result.Created)
+	}
+	if len(result.Updated) != 1 || result.Updated[0] != "beta" {
+		t.Fatalf("expected beta to be updated, got %v", result.Updated)
+	}
+	if len(result.Stopped) != 1 || result.Stopped[0] != "gamma" {
+		t.Fatalf("expected gamma to be stopped, got %v", result.Stopped)
+	}
+	if len(result.Orphaned) != 1 || result.Orphaned[0] != "stale" {
+		t.Fatalf("expected stale to be reported as orphaned, got %v", result.Orphaned)
+	}
+
+	if standby.states["alpha"] != "STOPPED" || standby.configs["beta"]["topics"] != "orders" {
+		t.Fatalf("standby not in sync: states=%v configs=%v", standby.states, standby.configs)
+	}
+}
+
+func TestStandbyGuard(t *testing.T) {
+	registry := withTestStandbys(t, map[string]string{"dr": "default"})
+	handler := standbyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(method, path, cluster string) int {
+		req := mux.SetURLVars(httptest.NewRequest(method, path, nil), map[string]string{"cluster": cluster})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := serve(http.MethodPut, "/api/dr/connectors/alpha/resume", "dr"); code != http.StatusConflict {
+		t.Fatalf("expected mutation on standby to be rejected, got %d", code)
+	}
+	if code := serve(http.MethodGet, "/api/dr/connectors/alpha/status", "dr"); code != http.StatusNoContent {
+		t.Fatalf("expected reads on standby to pass, got %d", code)
+	}
+	if code := serve(http.MethodPut, "/api/dr/connectors/alpha/metadata", "dr"); code != http.StatusNoContent {
+		t.Fatalf("expected metadata edits on standby to pass, got %d", code)
+	}
+	if code := serve(http.MethodPost, "/api/dr/connectors/alpha/config/diff", "dr"); code != http.StatusNoContent {
+		t.Fatalf("expected config diffs on standby to pass, got %d", code)
+	}
+	if code := serve(http.MethodPut, "/api/default/connectors/alpha/resume", "default"); code != http.StatusNoContent {
+		t.Fatalf("expected mutations on the primary to pass, got %d", code)
+	}
+
+	if _, err := registry.markFailedOver("dr", "alice"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code := serve(http.MethodPut, "/api/dr/connectors/alpha/resume", "dr"); code != http.StatusNoContent {
+		t.Fatalf("expected mutations after failover to pass, got %d", code)
+	}
+}
+
+func TestFailoverHandler(t *testing.T) {
+	withTestTrustedAuthHeaders(t)
+	standby, server := newFakeConnectCluster(t, map[string]map[string]string{
+		"alpha":  {"name": "alpha"},
+		"beta":   {"name": "beta"},
+		"broken": {"name": "broken"},
+	}, "STOPPED")
+	withTestClusterURLs(t, map[string]string{"dr": server.URL})
+	registry := withTestStandbys(t, map[string]string{"dr": "default"})
+	logger := withTestAuditLog(t, 10)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/dr/failover", nil)
+	req.Header.Set("X-Forwarded-User", "alice")
+	req = mux.SetURLVars(req, map[string]string{"cluster": "dr"})
+	rr := httptest.NewRecorder()
+	failoverHandler(rr, req)
+
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 when a connector fails to resume, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result FailoverResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(result.Resumed) != 2 || result.Resumed[0] != "alpha" || result.Resumed[1] != "beta" {
+		t.Fatalf("unexpected resumed connectors: %v", result.Resumed)
+	}
+	if _, ok := result.Failed["broken"]; !ok {
+		t.Fatalf("expected broken to be reported as failed: %v", result.Failed)
+	}
+	if standby.states["alpha"] != "RUNNING" {
+		t.Fatalf("expected alpha to be running, got %s", standby.states["alpha"])
+	}
+
+	if registry.inStandby("dr") {
+		t.Fatalf("expected cluster to leave standby after failover")
+	}
+	status, _ := registry.status("dr")
+	if status.Role != "active" || status.FailedOverBy != "alice" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	entries := logger.Query(AuditFilter{Action: auditActionFailover})
+	if len(entries) != 1 || entries[0].Cluster != "dr" || entries[0].Status != auditStatusFailure {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+}
+
+func TestFailoverHandlerRejectsNonStandby(t *testing.T) {
+	withTestStandbys(t, map[string]string{"dr": "default"})
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/default/failover", nil), map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	failoverHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
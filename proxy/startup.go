@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	// startupProbeTimeout bounds the reachability probe of each Kafka Connect cluster at
+	// startup; "0" skips the probe.
+	startupProbeTimeout = getEnv("STARTUP_PROBE_TIMEOUT", "5s")
+	// startupProbeRequired makes an unreachable cluster a fatal error rather than a
+	// warning, for deployments where Connect is always up before the console.
+	startupProbeRequired = getEnv("STARTUP_PROBE_REQUIRED", "false")
+
+	// configSettings records every setting read through getEnv with its default, so the
+	// startup summary lists the effective configuration without a separate registry.
+	configSettingsMu sync.Mutex
+	configSettings   = map[string]string{}
+)
+
+// recordSetting remembers that the named setting exists and what it defaults to.
+func recordSetting(name, defaultValue string) {
+	configSettingsMu.Lock()
+	defer configSettingsMu.Unlock()
+	configSettings[name] = defaultValue
+}
+
+// startupIssue is a problem found while checking the configuration at startup.
+// Fatal issues stop the proxy; the rest are logged as warnings.
+type startupIssue struct {
+	Setting string
+	Message string
+	Fatal   bool
+}
+
+func (i startupIssue) String() string {
+	return i.Setting + ": " + i.Message
+}
+
+type startupChecks struct {
+	issues []startupIssue
+}
+
+func (c *startupChecks) fatalf(setting, format string, args ...interface{}) {
+	c.issues = append(c.issues, startupIssue{Setting: setting, Message: fmt.Sprintf(format, args...), Fatal: true})
+}
+
+func (c *startupChecks) warnf(setting, format string, args ...interface{}) {
+	c.issues = append(c.issues, startupIssue{Setting: setting, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkStartupConfig validates every setting up front and reports all problems at
+// once, instead of stopping at the first one or proxying to a URL that was never going
+// to work. It parses the settings the way the subsystems will, checks that URLs and
+// files are usable and probes each Kafka Connect cluster.
+func checkStartupConfig(ctx context.Context) []startupIssue {
+	checks := &startupChecks{}
+
+	if file := activeConfigFile; file != nil {
+		if file.err != nil {
+			checks.fatalf("CONFIG_FILE", "%v", file.err)
+		}
+		configSettingsMu.Lock()
+		unknown := file.unknownKeys(configSettings)
+		configSettingsMu.Unlock()
+		for _, key := range unknown {
+			checks.warnf("CONFIG_FILE", "%s in %s matches no setting and is ignored", key, file.path)
+		}
+	}
+
+	for _, loader := range []struct {
+		subsystem string
+		load      func() error
+	}{
+		{"rate limiting", func() error { _, err := newRateLimiterFromEnv(); return err }},
+		{"request limits", func() error { _, err := loadRequestBodyLimit(); return err }},
+		{"compression", func() error { _, err := loadCompressionMinBytes(); return err }},
+		{"CORS", func() error { _, err := loadCORSOptions(); return err }},
+		{"auth", func() error { _, err := loadOIDCConfig(); return err }},
+		{"local auth", func() error { _, err := loadLocalAuth(); return err }},
+		{"debug capture", func() error { _, err := loadCaptureBuffer(); return err }},
+		{"fault injection", func() error {
+			faults, err := loadFaultInjector()
+			if err == nil && faults.isEnabled() {
+				checks.warnf("FAULT_INJECTION", "fault injection is enabled; never enable it in production")
+			}
+			return err
+		}},
+		{"network policy", func() error { _, err := loadNetworkPolicy(); return err }},
+		{"secrets", func() error { _, err := newSecretResolverFromEnv(); return err }},
+		{"connector templates", func() error { _, err := loadConnectorTemplates(connectorTemplatesDir); return err }},
+		{"alerts", func() error { _, err := loadAlertDefaults(); return err }},
+		{"notifications", func() error { _, err := newNotifierFromEnv(); return err }},
+		{"metrics export", func() error { _, err := loadMetricsExporter(); return err }},
+		{"lifecycle events", func() error { _, err := loadEventPublisher(); return err }},
+		{"stale connectors", func() error { _, err := loadStalePausedDays(); return err }},
+		{"auto-restart", func() error { _, _, err := loadAutoRestartDefaults(); return err }},
+		{"kerberos", func() error { _, err := loadKerberosAuth(); return err }},
+		{"upstream", func() error { _, err := loadUpstreamPolicy(); return err }},
+		{"upstream limits", func() error { _, err := loadUpstreamLimits(); return err }},
+		{"upstream timeouts", func() error { _, err := loadUpstreamTimeouts(); return err }},
+		{"upstream connections", func() error { _, err := loadTransportSettings(); return err }},
+		{"tenancy", func() error { _, err := loadTenancy(); return err }},
+		{"audit log", func() error { _, err := loadAuditLogger(1); return err }},
+		{"audit bodies", func() error { _, err := loadAuditMaxBody(); return err }},
+		{"audit archive", func() error { _, err := loadAuditArchiver(); return err }},
+		{"restore points", func() error { _, err := loadRestorePointsMax(); return err }},
+		{"cache", func() error { _, err := loadCache(); return err }},
+		{"tracing", func() error {
+			tracer, err := loadTracer()
+			if tracer != nil {
+				tracer.provider.Shutdown(context.Background())
+			}
+			return err
+		}},
+		{"summary cache", func() error { _, err := loadSummaryCacheTTL(); return err }},
+		{"graphql", func() error { _, err := loadGraphQLLimits(); return err }},
+		{"api versioning", func() error { _, err := parseLegacyAPISunset(legacyAPISunset); return err }},
+		{"redaction", func() error {
+			if redactionConfigPath == "" {
+				return nil
+			}
+			cfg, err := readRedactionConfig(redactionConfigPath)
+			if err != nil {
+				return err
+			}
+			_, err = buildRedactionRules(cfg)
+			return err
+		}},
+		{"redaction streaming", func() error { _, err := loadRedactionStreamThreshold(); return err }},
+		{"admission", func() error {
+			if admissionPolicyPath == "" {
+				return nil
+			}
+			policy, err := readAdmissionPolicy(admissionPolicyPath)
+			if err != nil {
+				return err
+			}
+			_, err = buildAdmissionRules(policy)
+			return err
+		}},
+	} {
+		if err := loader.load(); err != nil {
+			checks.fatalf(loader.subsystem, "%v", err)
+		}
+	}
+
+	windows := map[string]string{
+		"CONSUMER_GROUP_STUCK_AFTER": consumerGroupStuckAfter,
+		"STATE_HISTORY_RETENTION":    stateHistoryRetention,
+		"SUMMARY_SNAPSHOT_RETENTION": summarySnapshotRetention,
+		"SCHEDULER_INTERVAL":         schedulerInterval,
+		"STANDBY_SYNC_INTERVAL":      standbySyncInterval,
+		"METRICS_POLL_INTERVAL":      metricsPollInterval,
+		"METRICS_RETENTION":          metricsRetention,
+	}
+	if monitoringPollInterval != "0" {
+		windows["MONITORING_POLL_INTERVAL"] = monitoringPollInterval
+	}
+	if configCacheTTL != "0" {
+		windows["CONFIG_CACHE_TTL"] = configCacheTTL
+	}
+	if validationCacheTTL != "0" {
+		windows["VALIDATION_CACHE_TTL"] = validationCacheTTL
+	}
+	if driftCheckInterval != "0" {
+		windows["DRIFT_CHECK_INTERVAL"] = driftCheckInterval
+	}
+	for name, value := range windows {
+		if _, err := parseWindow(value, time.Second); err != nil {
+			checks.fatalf(name, "%v; use a duration such as 30s, 15m or 7d", err)
+		}
+	}
+	if n, err := strconv.Atoi(auditLogMaxEntries); err != nil || n <= 0 {
+		checks.fatalf("AUDIT_LOG_MAX_ENTRIES", "%q is not a positive number of entries", auditLogMaxEntries)
+	}
+	if n, err := strconv.Atoi(listenPort); err != nil || n <= 0 || n > 65535 {
+		checks.fatalf("PORT", "%q is not a TCP port", listenPort)
+	}
+
+	// clusters maps the setting naming each Connect URL to the URL, for the probe.
+	clusters := map[string]string{}
+	if checkServiceURL(checks, "KAFKA_CONNECT_URL", connectURL) {
+		clusters["KAFKA_CONNECT_URL"] = connectURL
+	}
+	if urls, err := parseClusterURLs(connectClustersSpec); err != nil {
+		checks.fatalf("KAFKA_CONNECT_CLUSTERS", "%v; use name=url pairs such as dr=http://connect-dr:8083", err)
+	} else {
+		for name, u := range urls {
+			setting := "KAFKA_CONNECT_CLUSTERS[" + name + "]"
+			if checkServiceURL(checks, setting, u) {
+				clusters[setting] = u
+			}
+		}
+		if _, err := parseStandbyClusters(standbyClustersSpec, urls); err != nil {
+			checks.fatalf("STANDBY_CLUSTERS", "%v", err)
+		}
+	}
+	if workers, err := parseClusterWorkers(connectWorkersSpec); err != nil {
+		checks.fatalf("KAFKA_CONNECT_WORKERS", "%v; use name=url|url pairs such as default=http://connect-1:8083|http://connect-2:8083", err)
+	} else {
+		for name, urls := range workers {
+			for _, u := range urls {
+				checkServiceURL(checks, "KAFKA_CONNECT_WORKERS["+name+"]", u)
+			}
+		}
+	}
+	for _, u := range splitList(jolokiaURLs) {
+		checkServiceURL(checks, "JOLOKIA_URL", u)
+	}
+	for name, value := range map[string]string{
+		"SCHEMA_REGISTRY_URL":      schemaRegistryURL,
+		"NOTIFY_WEBHOOK_URL":       notifyWebhookURL,
+		"NOTIFY_SLACK_WEBHOOK_URL": notifySlackWebhookURL,
+		"SECRETS_VAULT_ADDR":       secretsVaultAddr,
+		"SECRETS_AWS_ENDPOINT":     secretsAWSEndpoint,
+	} {
+		if strings.TrimSpace(value) != "" {
+			checkServiceURL(checks, name, value)
+		}
+	}
+
+	if auditLogImport != "" {
+		if _, err := os.Stat(auditLogImport); err != nil {
+			checks.fatalf("AUDIT_LOG_IMPORT", "%v; point it at an NDJSON export from GET /api/{cluster}/audit-logs?format=ndjson", err)
+		}
+	}
+	if dataDir != "" {
+		if info, err := os.Stat(dataDir); err == nil && !info.IsDir() {
+			checks.fatalf("DATA_DIR", "%s is a file, not a directory", dataDir)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			checks.fatalf("DATA_DIR", "%v", err)
+		}
+	}
+
+	probeConnectClusters(ctx, checks, clusters)
+	return checks.issues
+}
+
+// checkServiceURL requires an absolute http(s) URL. A missing scheme is the usual
+// mistake: "connect:8083" parses, but as a URL with scheme "connect" and no host.
+func checkServiceURL(checks *startupChecks, setting, value string) bool {
+	u, err := url.Parse(strings.TrimSpace(value))
+	switch {
+	case err != nil:
+		checks.fatalf(setting, "%q is not a valid URL", redactURL(value))
+	case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+		checks.fatalf(setting, "%q must be an absolute http:// or https:// URL, e.g. http://connect:8083", redactURL(value))
+	default:
+		return true
+	}
+	return false
+}
+
+// probeConnectClusters checks that each cluster answers on its root endpoint within
+// STARTUP_PROBE_TIMEOUT. Connect commonly starts after the console, so an unreachable
+// cluster is only a warning unless STARTUP_PROBE_REQUIRED is set; the exception is
+// the built-in default URL, which is almost always a forgotten KAFKA_CONNECT_URL.
+func probeConnectClusters(ctx context.Context, checks *startupChecks, clusters map[string]string) {
+	if startupProbeTimeout == "0" {
+		return
+	}
+	timeout, err := parseWindow(startupProbeTimeout, 5*time.Second)
+	if err != nil {
+		checks.fatalf("STARTUP_PROBE_TIMEOUT", "%v; use a duration such as 5s, or 0 to skip the probe", err)
+		return
+	}
+	required := startupProbeRequired == "true"
+
+	settings := make([]string, 0, len(clusters))
+	for setting := range clusters {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+
+	// Kerberos is not set up before the probe; an invalid setup is reported by its own
+	// check and the probe then goes out unauthenticated.
+	kerberos, _ := loadKerberosAuth()
+	problems := make([]string, len(settings))
+	var wg sync.WaitGroup
+	for i, setting := range settings {
+		wg.Add(1)
+		go func(i int, base string) {
+			defer wg.Done()
+			problems[i] = probeConnectCluster(ctx, base, timeout, kerberos)
+		}(i, clusters[setting])
+	}
+	wg.Wait()
+
+	for i, setting := range settings {
+		if problems[i] == "" {
+			continue
+		}
+		switch {
+		case setting == "KAFKA_CONNECT_URL" && settingSource("KAFKA_CONNECT_URL") == "default":
+			checks.fatalf(setting, "KAFKA_CONNECT_URL is not set and the default %s is not usable (%s); set KAFKA_CONNECT_URL to your Connect REST endpoint", connectURL, problems[i])
+		case required:
+			checks.fatalf(setting, "%s (STARTUP_PROBE_REQUIRED is set)", problems[i])
+		default:
+			checks.warnf(setting, "%s; requests to this cluster will fail until it is reachable", problems[i])
+		}
+	}
+}
+
+// probeConnectCluster returns why the cluster at base is not usable, or "" when it is.
+func probeConnectCluster(ctx context.Context, base string, timeout time.Duration, kerberos *kerberosAuth) string {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/"), nil)
+	if err != nil {
+		return err.Error()
+	}
+	client := &http.Client{Transport: &authTransport{base: upstreamHTTP, kerberos: kerberos}}
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Sprintf("%s did not respond within %s", redactURL(base), timeout)
+		}
+		return fmt.Sprintf("%s is unreachable: %v", redactURL(base), errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		if kerberos != nil {
+			return fmt.Sprintf("%s rejected the proxy's Kerberos ticket (HTTP %d); check KAFKA_CONNECT_KERBEROS_PRINCIPAL and the service principal %s", redactURL(base), resp.StatusCode, kerberos.servicePrincipal(req))
+		}
+		return fmt.Sprintf("%s rejected the proxy's credentials (HTTP %d); check KAFKA_CONNECT_USERNAME and KAFKA_CONNECT_PASSWORD", redactURL(base), resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Sprintf("%s answered HTTP %d; is it a Kafka Connect REST endpoint?", redactURL(base), resp.StatusCode)
+	}
+	return ""
+}
+
+// logStartupConfig logs the configuration summary followed by every issue and returns
+// the number of fatal ones.
+func logStartupConfig(issues []startupIssue) int {
+	var summary strings.Builder
+	writeConfigSummary(&summary)
+	log.Printf("Effective configuration:\n%s", summary.String())
+
+	fatal := 0
+	for _, issue := range issues {
+		if issue.Fatal {
+			fatal++
+			log.Printf("config error: %s", issue)
+		} else {
+			log.Printf("warning: %s", issue)
+		}
+	}
+	return fatal
+}
+
+// writeConfigSummary prints the effective value of every setting that is set or has a
+// non-empty default. Secrets are masked and passwords stripped from URLs.
+func writeConfigSummary(w io.Writer) {
+	configSettingsMu.Lock()
+	names := make([]string, 0, len(configSettings))
+	defaults := make(map[string]string, len(configSettings))
+	for name, def := range configSettings {
+		names = append(names, name)
+		defaults[name] = def
+	}
+	configSettingsMu.Unlock()
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, name := range names {
+		value, source := lookupSetting(name, defaults[name])
+		if value == "" {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, maskSetting(name, value), source)
+	}
+	tw.Flush()
+}
+
+// maskSetting hides the value of credentials and the user info of URLs. Webhook URLs
+// carry their secret in the path, so only the host is shown.
+func maskSetting(name, value string) string {
+	upper := strings.ToUpper(name)
+	for _, marker := range []string{"PASSWORD", "SECRET", "TOKEN", "HEADERS"} {
+		if strings.Contains(upper, marker) {
+			return defaultRedactionPlaceholder
+		}
+	}
+	if strings.Contains(upper, "WEBHOOK") {
+		if u, err := url.Parse(value); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host + "/" + defaultRedactionPlaceholder
+		}
+		return defaultRedactionPlaceholder
+	}
+	return redactURL(value)
+}
+
+// redactURL strips the password from every URL in a comma-separated list, leaving
+// other values untouched.
+func redactURL(value string) string {
+	if !strings.Contains(value, "@") {
+		return value
+	}
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		prefix, raw := "", part
+		if key, rest, ok := strings.Cut(part, "="); ok && !strings.Contains(key, "/") {
+			prefix, raw = key+"=", rest
+		}
+		if u, err := url.Parse(strings.TrimSpace(raw)); err == nil && u.User != nil {
+			parts[i] = prefix + u.Redacted()
+		}
+	}
+	return strings.Join(parts, ",")
+}
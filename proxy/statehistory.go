@@ -0,0 +1,361 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	stateHistoryFile = "connector-state-history.json"
+
+	stateTargetConnector = "connector"
+	// stateRemoved ends the history of a deleted connector or a task that went away.
+	stateRemoved = "removed"
+)
+
+var (
+	stateHistoryRetention = getEnv("STATE_HISTORY_RETENTION", "7d")
+
+	connectorStateHistory = newStateHistory(7*24*time.Hour, time.Now)
+)
+
+// StateTransition is a change of state of a connector or one of its tasks ("task-0",
+// ...). From is empty the first time the target is seen.
+type StateTransition struct {
+	Target string    `json:"target"`
+	From   string    `json:"from,omitempty"`
+	To     string    `json:"to"`
+	At     time.Time `json:"at"`
+}
+
+// StateSpan is a period a connector spent in one state. End is nil for the current state.
+type StateSpan struct {
+	State           string     `json:"state"`
+	Start           time.Time  `json:"start"`
+	End             *time.Time `json:"end,omitempty"`
+	DurationSeconds float64    `json:"durationSeconds"`
+}
+
+// ConnectorStateHistory is returned by GET /api/{cluster}/connectors/{name}/history.
+// Durations are seconds per state within the window, for the connector and per task.
+type ConnectorStateHistory struct {
+	Connector   string                        `json:"connector"`
+	Window      string                        `json:"window"`
+	Since       time.Time                     `json:"since"`
+	Until       time.Time                     `json:"until"`
+	State       string                        `json:"state,omitempty"`
+	Transitions []StateTransition             `json:"transitions"`
+	Timeline    []StateSpan                   `json:"timeline"`
+	Durations   map[string]float64            `json:"durations"`
+	Tasks       map[string]map[string]float64 `json:"tasks"`
+}
+
+// connectorStateLog is the persisted history of one connector. Current holds the state
+// of each target at the last poll.
+type connectorStateLog struct {
+	Current     map[string]string `json:"current"`
+	Transitions []StateTransition `json:"transitions"`
+}
+
+type stateHistoryDocument struct {
+	Clusters map[string]map[string]*connectorStateLog `json:"clusters"`
+}
+
+// stateHistory records connector and task state transitions seen by the monitoring
+// poller and keeps them for the retention period.
+type stateHistory struct {
+	mu        sync.Mutex
+	retention time.Duration
+	now       func() time.Time
+	clusters  map[string]map[string]*connectorStateLog
+}
+
+func newStateHistory(retention time.Duration, now func() time.Time) *stateHistory {
+	return &stateHistory{retention: retention, now: now, clusters: make(map[string]map[string]*connectorStateLog)}
+}
+
+func (h *stateHistory) load() error {
+	var doc stateHistoryDocument
+	if err := loadJSON(stateHistoryFile, &doc); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if doc.Clusters != nil {
+		h.clusters = doc.Clusters
+	}
+	return nil
+}
+
+// saveLocked persists the history. Callers must hold h.mu.
+func (h *stateHistory) saveLocked() error {
+	return saveJSON(stateHistoryFile, stateHistoryDocument{Clusters: h.clusters})
+}
+
+// record compares statuses with the previous poll of cluster and appends a transition
+// for every target whose state changed. Connectors missing from statuses were deleted.
+func (h *stateHistory) record(cluster string, statuses []connectorStatusResponse) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now().UTC()
+	connectors, ok := h.clusters[cluster]
+	if !ok {
+		connectors = make(map[string]*connectorStateLog)
+		h.clusters[cluster] = connectors
+	}
+
+	changed := false
+	seen := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		seen[status.Name] = true
+		stateLog, ok := connectors[status.Name]
+		if !ok {
+			stateLog = &connectorStateLog{Current: make(map[string]string)}
+			connectors[status.Name] = stateLog
+		}
+
+		states := map[string]string{stateTargetConnector: normalizeState(status.Connector.State)}
+		for _, task := range status.Tasks {
+			states["task-"+strconv.Itoa(task.ID)] = normalizeState(task.State)
+		}
+		if stateLog.apply(states, now) {
+			changed = true
+		}
+	}
+	for name, stateLog := range connectors {
+		if !seen[name] && len(stateLog.Current) > 0 && stateLog.apply(nil, now) {
+			changed = true
+		}
+	}
+
+	if h.pruneLocked(now) {
+		changed = true
+	}
+	if changed {
+		if err := h.saveLocked(); err != nil {
+			log.Printf("state history: failed to persist transitions: %v", err)
+		}
+	}
+}
+
+// observe is a statusObserver recording the console's own cluster.
+func (h *stateHistory) observe(_ string, statuses []connectorStatusResponse) {
+	h.record(alertMetadataCluster, statuses)
+}
+
+// apply moves the log to states, marking targets that disappeared as removed.
+func (l *connectorStateLog) apply(states map[string]string, now time.Time) bool {
+	changed := false
+	targets := make([]string, 0, len(states))
+	for target := range states {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		state := states[target]
+		if previous, ok := l.Current[target]; !ok || previous != state {
+			l.Transitions = append(l.Transitions, StateTransition{Target: target, From: previous, To: state, At: now})
+			l.Current[target] = state
+			changed = true
+		}
+	}
+	for target, previous := range l.Current {
+		if _, ok := states[target]; !ok {
+			l.Transitions = append(l.Transitions, StateTransition{Target: target, From: previous, To: stateRemoved, At: now})
+			delete(l.Current, target)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// pruneLocked drops transitions older than the retention, except the last one of each
+// target, which gives its state at the start of any window. Deleted connectors are
+// forgotten once their last transition has expired.
+func (h *stateHistory) pruneLocked(now time.Time) bool {
+	cutoff := now.Add(-h.retention)
+	pruned := false
+	for _, connectors := range h.clusters {
+		for name, stateLog := range connectors {
+			latest := make(map[string]int)
+			for i, transition := range stateLog.Transitions {
+				latest[transition.Target] = i
+			}
+			kept := stateLog.Transitions[:0]
+			for i, transition := range stateLog.Transitions {
+				expired := transition.At.Before(cutoff)
+				if expired && (latest[transition.Target] != i || transition.To == stateRemoved) {
+					pruned = true
+					continue
+				}
+				kept = append(kept, transition)
+			}
+			stateLog.Transitions = kept
+			if len(stateLog.Current) == 0 && len(kept) == 0 {
+				delete(connectors, name)
+			}
+		}
+	}
+	return pruned
+}
+
+// history builds the timeline of a connector between since and until.
+func (h *stateHistory) history(cluster, name string, since, until time.Time) ConnectorStateHistory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := ConnectorStateHistory{
+		Connector:   name,
+		Since:       since,
+		Until:       until,
+		Transitions: []StateTransition{},
+		Timeline:    []StateSpan{},
+		Durations:   map[string]float64{},
+		Tasks:       map[string]map[string]float64{},
+	}
+	stateLog, ok := h.clusters[cluster][name]
+	if !ok {
+		return result
+	}
+	result.State = stateLog.Current[stateTargetConnector]
+
+	byTarget := make(map[string][]StateTransition)
+	for _, transition := range stateLog.Transitions {
+		if !transition.At.After(until) {
+			byTarget[transition.Target] = append(byTarget[transition.Target], transition)
+		}
+		if !transition.At.Before(since) && !transition.At.After(until) {
+			result.Transitions = append(result.Transitions, transition)
+		}
+	}
+
+	for target, transitions := range byTarget {
+		spans := stateSpans(transitions, since, until)
+		durations := map[string]float64{}
+		for _, span := range spans {
+			durations[span.State] += span.DurationSeconds
+		}
+		if target == stateTargetConnector {
+			result.Timeline, result.Durations = spans, durations
+		} else if len(spans) > 0 {
+			result.Tasks[target] = durations
+		}
+	}
+	return result
+}
+
+// currentState returns the state a connector had at the last poll and when it entered
+// it. first is true when the connector was already in that state when the history
+// started, so since is only a lower bound. ok is false without recorded history.
+func (h *stateHistory) currentState(cluster, name string) (state string, since time.Time, first, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stateLog, ok := h.clusters[cluster][name]
+	if !ok {
+		return "", time.Time{}, false, false
+	}
+	state = stateLog.Current[stateTargetConnector]
+	for i := len(stateLog.Transitions) - 1; i >= 0; i-- {
+		if transition := stateLog.Transitions[i]; transition.Target == stateTargetConnector {
+			if transition.To != state {
+				return "", time.Time{}, false, false
+			}
+			return state, transition.At, transition.From == "", true
+		}
+	}
+	return "", time.Time{}, false, false
+}
+
+// restarts counts how often a connector or one of its tasks came back to RUNNING from
+// FAILED, UNASSIGNED or RESTARTING since the given time. ok is false when the connector
+// has no recorded history.
+func (h *stateHistory) restarts(cluster, name string, since time.Time) (count int, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stateLog, ok := h.clusters[cluster][name]
+	if !ok {
+		return 0, false
+	}
+	for _, transition := range stateLog.Transitions {
+		if transition.To != "running" || transition.At.Before(since) {
+			continue
+		}
+		switch transition.From {
+		case "failed", "unassigned", "restarting":
+			count++
+		}
+	}
+	return count, true
+}
+
+// stateSpans turns the transitions of one target, oldest first, into the periods spent
+// in each state within [since, until]. Periods after a removal are not counted.
+func stateSpans(transitions []StateTransition, since, until time.Time) []StateSpan {
+	spans := []StateSpan{}
+	for i, transition := range transitions {
+		if transition.To == stateRemoved {
+			continue
+		}
+		start, end, open := transition.At, until, true
+		if i+1 < len(transitions) {
+			end, open = transitions[i+1].At, false
+		}
+		if !end.After(since) {
+			continue
+		}
+		if start.Before(since) {
+			start = since
+		}
+		span := StateSpan{State: transition.To, Start: start, DurationSeconds: end.Sub(start).Seconds()}
+		if !open {
+			spanEnd := end
+			span.End = &spanEnd
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// connectorStateHistoryHandler returns the state transitions of a connector and how long
+// it spent in each state within ?window= (default 24h) or since/until.
+func connectorStateHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	timeRange, loc, err := parseTimeRange(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_time_range", err.Error())
+		return
+	}
+
+	now := connectorStateHistory.now().UTC()
+	windowParam := query.Get("window")
+	if timeRange.Since.IsZero() {
+		if windowParam == "" {
+			windowParam = "24h"
+		}
+		window, err := parseWindow(windowParam, 24*time.Hour)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_window", err.Error())
+			return
+		}
+		timeRange.Since = now.Add(-window)
+	}
+	if timeRange.Until.IsZero() || timeRange.Until.After(now) {
+		timeRange.Until = now
+	}
+
+	history := connectorStateHistory.history(vars["cluster"], vars["name"], timeRange.Since.UTC(), timeRange.Until.UTC())
+	history.Window = windowParam
+	setTimezoneHeader(w, loc)
+	writeJSON(w, http.StatusOK, history)
+}
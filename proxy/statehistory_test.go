@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func testStatuses(t *testing.T, body string) []connectorStatusResponse {
+	t.Helper()
+	var statuses []connectorStatusResponse
+	if err := json.Unmarshal([]byte(body), &statuses); err != nil {
+		t.Fatalf("unmarshal statuses: %v", err)
+	}
+	return statuses
+}
+
+func TestStateHistoryRecordsTransitionsAndDurations(t *testing.T) {
+	original := dataDir
+	dataDir = t.TempDir()
+	t.Cleanup(func() { dataDir = original })
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	history := newStateHistory(7*24*time.Hour, func() time.Time { return now })
+
+	poll := func(at time.Duration, body string) {
+		now = start.Add(at)
+		history.record("default", testStatuses(t, body))
+	}
+	poll(0, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"},{"id":1,"state":"RUNNING"}]}]`)
+	poll(time.Hour, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"},{"id":1,"state":"RUNNING"}]}]`)
+	poll(2*time.Hour, `[{"name":"orders","connector":{"state":"FAILED"},"tasks":[{"id":0,"state":"FAILED"}]}]`)
+	poll(150*time.Minute, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[{"id":0,"state":"RUNNING"}]}]`)
+	now = start.Add(3 * time.Hour)
+
+	result := history.history("default", "orders", start.Add(time.Hour), now)
+	if result.State != "running" {
+		t.Fatalf("expected the current state, got %q", result.State)
+	}
+	// failed and task-0 failed at 2h, task-1 removed at 2h, both recovered at 2h30.
+	if len(result.Transitions) != 5 {
+		t.Fatalf("expected 5 transitions in the window, got %+v", result.Transitions)
+	}
+	if tr := result.Transitions[0]; tr.Target != stateTargetConnector || tr.From != "running" || tr.To != "failed" || !tr.At.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("unexpected first transition %+v", tr)
+	}
+
+	if len(result.Timeline) != 3 || result.Timeline[0].State != "running" || !result.Timeline[0].Start.Equal(start.Add(time.Hour)) ||
+		result.Timeline[1].State != "failed" || result.Timeline[1].DurationSeconds != 1800 || result.Timeline[2].End != nil {
+		t.Fatalf("unexpected timeline %+v", result.Timeline)
+	}
+	if result.Durations["running"] != 5400 || result.Durations["failed"] != 1800 {
+		t.Fatalf("unexpected durations %+v", result.Durations)
+	}
+	if result.Tasks["task-1"]["running"] != 3600 || result.Tasks["task-0"]["failed"] != 1800 {
+		t.Fatalf("unexpected task durations %+v", result.Tasks)
+	}
+
+	if count, ok := history.restarts("default", "orders", start); !ok || count != 2 {
+		t.Fatalf("expected the connector and task-0 recoveries to count as restarts, got %d", count)
+	}
+	if _, ok := history.restarts("default", "billing", start); ok {
+		t.Fatalf("expected no restart count for a connector without history")
+	}
+
+	// The history survives a restart.
+	reloaded := newStateHistory(7*24*time.Hour, func() time.Time { return now })
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := reloaded.history("default", "orders", start, now); len(got.Transitions) != len(history.history("default", "orders", start, now).Transitions) {
+		t.Fatalf("expected the reloaded history to match, got %+v", got.Transitions)
+	}
+}
+
+func TestStateHistoryDeletedConnectorsAndPruning(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	history := newStateHistory(24*time.Hour, func() time.Time { return now })
+
+	history.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[]},{"name":"legacy","connector":{"state":"PAUSED"},"tasks":[]}]`))
+	now = start.Add(time.Hour)
+	history.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[]}]`))
+
+	legacy := history.history("default", "legacy", start, now)
+	if legacy.State != "" || len(legacy.Transitions) != 2 || legacy.Transitions[1].To != stateRemoved || legacy.Durations["paused"] != 3600 {
+		t.Fatalf("unexpected history of a deleted connector %+v", legacy)
+	}
+
+	now = start.Add(48 * time.Hour)
+	history.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[]}]`))
+	if _, ok := history.clusters["default"]["legacy"]; ok {
+		t.Fatalf("expected the deleted connector to be forgotten after the retention")
+	}
+	// The last transition is kept to know the state at the start of any window.
+	orders := history.history("default", "orders", now.Add(-time.Hour), now)
+	if len(orders.Transitions) != 0 || orders.Durations["running"] != 3600 {
+		t.Fatalf("expected orders to have been running for the whole window, got %+v", orders)
+	}
+}
+
+func TestConnectorStateHistoryHandler(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	original := connectorStateHistory
+	connectorStateHistory = newStateHistory(7*24*time.Hour, func() time.Time { return now })
+	t.Cleanup(func() { connectorStateHistory = original })
+
+	connectorStateHistory.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"RUNNING"},"tasks":[]}]`))
+	now = start.Add(26 * time.Hour)
+	connectorStateHistory.record("default", testStatuses(t, `[{"name":"orders","connector":{"state":"FAILED"},"tasks":[]}]`))
+	now = start.Add(27 * time.Hour)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders/history"+query, nil), map[string]string{"cluster": "default", "name": "orders"})
+		rr := httptest.NewRecorder()
+		connectorStateHistoryHandler(rr, req)
+		return rr
+	}
+
+	var result ConnectorStateHistory
+	rr := get("")
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if result.Window != "24h" || !result.Since.Equal(start.Add(3*time.Hour)) || len(result.Transitions) != 1 ||
+		result.Durations["running"] != 23*3600 || result.Durations["failed"] != 3600 {
+		t.Fatalf("unexpected default window %+v", result)
+	}
+
+	result = ConnectorStateHistory{}
+	json.Unmarshal(get("?window=2d").Body.Bytes(), &result)
+	if len(result.Transitions) != 2 || result.Transitions[0].From != "" {
+		t.Fatalf("expected the first observation in a 2d window, got %+v", result.Transitions)
+	}
+
+	if rr := get("?window=soon"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid window, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// dataDir is where the proxy persists its own state (usage statistics, metadata, ...).
+// When empty, state is kept in memory only and lost on restart.
+var dataDir = getEnv("DATA_DIR", "")
+
+// storeMu serialises writes so concurrent saves of the same document cannot interleave.
+var storeMu sync.Mutex
+
+func storePath(name string) string {
+	return filepath.Join(dataDir, name)
+}
+
+// loadJSON reads the named document from the data directory into v. A missing file or
+// an unset DATA_DIR is not an error; v is simply left untouched.
+func loadJSON(name string, v interface{}) error {
+	if dataDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(storePath(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", name, err)
+	}
+	return nil
+}
+
+// saveJSON atomically writes v as the named document in the data directory. It is a
+// no-op when DATA_DIR is unset.
+func saveJSON(name string, v interface{}) error {
+	if dataDir == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", name, err)
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return fmt.Errorf("create data dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dataDir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file for %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", name, err)
+	}
+
+	if err := os.Rename(tmp.Name(), storePath(name)); err != nil {
+		return fmt.Errorf("replace %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	cacheHit   = "HIT"
+	cacheStale = "STALE"
+	cacheMiss  = "MISS"
+
+	// summaryCacheMaxStale is how long past its TTL a summary is still served while a
+	// refresh runs in the background. Older summaries are fetched synchronously.
+	summaryCacheMaxStale = time.Minute
+	// summaryRefreshTimeout bounds a fetch that is not tied to a single request.
+	summaryRefreshTimeout = 30 * time.Second
+
+	summaryCacheKey = "summary|"
+)
+
+var (
+	summaryCacheTTLSetting = getEnv("SUMMARY_CACHE_TTL", "10s")
+
+	monitoringSummaryCache = newSummaryCache(10*time.Second, summaryCacheMaxStale, sharedCache, time.Now, fetchCurrentMonitoringSummary)
+)
+
+// fetchCurrentMonitoringSummary fetches the summary of the console's Kafka Connect cluster.
+func fetchCurrentMonitoringSummary(ctx context.Context) (MonitoringSummary, error) {
+	summary, err := fetchMonitoringSummary(ctx, monitoringHTTPClient, connectURL)
+	if err != nil {
+		return MonitoringSummary{}, err
+	}
+	usageStats.recordActiveConnectors(summary.TotalConnectors)
+	applyHealthGrades(&summary, alertMetadataCluster, time.Now().UTC())
+	summarySnapshots.record(summary)
+	return summary, nil
+}
+
+// loadSummaryCacheTTL parses SUMMARY_CACHE_TTL; 0 disables caching.
+func loadSummaryCacheTTL() (time.Duration, error) {
+	if summaryCacheTTLSetting == "0" {
+		return 0, nil
+	}
+	return parseWindow(summaryCacheTTLSetting, 10*time.Second)
+}
+
+// summaryFetch is a fetch shared by every caller that needs it while it runs.
+type summaryFetch struct {
+	done    chan struct{}
+	summary MonitoringSummary
+	err     error
+}
+
+// cachedSummary is a summary as kept in the shared cache. FetchedAt travels with it so
+// every replica ages it the same way.
+type cachedSummary struct {
+	Summary   MonitoringSummary `json:"summary"`
+	FetchedAt time.Time         `json:"fetchedAt"`
+}
+
+// summaryCache caches the monitoring summary. Concurrent misses share one upstream
+// fetch, and a summary past its TTL is served as stale while it is refreshed in the
+// background, so the dashboard never waits on Connect for a summary it already has.
+// The summary itself lives in the shared cache; in-flight fetches are per replica.
+type summaryCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxStale time.Duration
+	store    Cache
+	now      func() time.Time
+	fetch    func(ctx context.Context) (MonitoringSummary, error)
+
+	inflight   *summaryFetch
+	generation int
+}
+
+func newSummaryCache(ttl, maxStale time.Duration, store Cache, now func() time.Time, fetch func(ctx context.Context) (MonitoringSummary, error)) *summaryCache {
+	return &summaryCache{ttl: ttl, maxStale: maxStale, store: store, now: now, fetch: fetch}
+}
+
+func (c *summaryCache) load(ctx context.Context) (cachedSummary, bool) {
+	data, ok, err := c.store.Get(ctx, summaryCacheKey)
+	if err != nil {
+		log.Printf("cache: failed to read the monitoring summary: %v", err)
+	}
+	var cached cachedSummary
+	if !ok || json.Unmarshal(data, &cached) != nil {
+		return cachedSummary{}, false
+	}
+	return cached, true
+}
+
+// get returns the summary, its age and whether it was a HIT, STALE or MISS. A MISS
+// waits for the shared fetch until ctx is done.
+func (c *summaryCache) get(ctx context.Context) (MonitoringSummary, time.Duration, string, error) {
+	if c.ttl > 0 {
+		if cached, ok := c.load(ctx); ok {
+			age := c.now().Sub(cached.FetchedAt)
+			if age < c.ttl {
+				return cached.Summary, age, cacheHit, nil
+			}
+			if age < c.ttl+c.maxStale {
+				c.mu.Lock()
+				if c.inflight == nil {
+					c.startFetchLocked()
+				}
+				c.mu.Unlock()
+				return cached.Summary, age, cacheStale, nil
+			}
+		}
+	}
+
+	c.mu.Lock()
+	fetch := c.inflight
+	if fetch == nil {
+		fetch = c.startFetchLocked()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+	case <-ctx.Done():
+		return MonitoringSummary{}, 0, cacheMiss, ctx.Err()
+	}
+	if fetch.err != nil {
+		return MonitoringSummary{}, 0, cacheMiss, fetch.err
+	}
+	return fetch.summary, 0, cacheMiss, nil
+}
+
+// startFetchLocked fetches the summary in the background. The fetch outlives the request
+// that started it so callers joining it later are not cancelled with that request.
+func (c *summaryCache) startFetchLocked() *summaryFetch {
+	fetch := &summaryFetch{done: make(chan struct{})}
+	c.inflight = fetch
+	generation := c.generation
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), summaryRefreshTimeout)
+		fetch.summary, fetch.err = c.fetch(ctx)
+		cancel()
+
+		c.mu.Lock()
+		if c.generation == generation {
+			c.inflight = nil
+			// A failed refresh keeps the previous summary so it can still be served stale.
+			if fetch.err == nil && c.ttl > 0 {
+				c.storeLocked(fetch.summary)
+			}
+		}
+		c.mu.Unlock()
+		close(fetch.done)
+	}()
+	return fetch
+}
+
+// storeLocked keeps the summary for its TTL plus the stale window. The store happens
+// under the lock so a concurrent reset cannot be overwritten by the fetch it discarded.
+func (c *summaryCache) storeLocked(summary MonitoringSummary) {
+	ctx, cancel := context.WithTimeout(context.Background(), summaryRefreshTimeout)
+	defer cancel()
+	data, err := json.Marshal(cachedSummary{Summary: summary, FetchedAt: c.now()})
+	if err == nil {
+		err = c.store.Set(ctx, summaryCacheKey, data, c.ttl+c.maxStale)
+	}
+	if err != nil {
+		log.Printf("cache: failed to store the monitoring summary: %v", err)
+	}
+}
+
+// reset drops the cached summary on every replica. A fetch still running is not stored.
+func (c *summaryCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight = nil
+	c.generation++
+	if err := c.store.Invalidate(context.Background(), summaryCacheKey); err != nil {
+		log.Printf("cache: failed to invalidate the monitoring summary: %v", err)
+	}
+}
+
+// setHeaders describes the freshness of a summary to clients and shared caches.
+func (c *summaryCache) setHeaders(h http.Header, age time.Duration, state string) {
+	h.Set("X-Cache", state)
+	if c.ttl <= 0 {
+		h.Set("Cache-Control", "no-store")
+		return
+	}
+	h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d, stale-while-revalidate=%d", int(c.ttl/time.Second), int(c.maxStale/time.Second)))
+	h.Set("Age", strconv.Itoa(int(age/time.Second)))
+}
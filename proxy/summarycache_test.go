@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestSummaryCache(t *testing.T, ttl time.Duration) *summaryCache {
+	t.Helper()
+	original := monitoringSummaryCache
+	cache := newSummaryCache(ttl, summaryCacheMaxStale, newMemoryCache(time.Now), time.Now, fetchCurrentMonitoringSummary)
+	monitoringSummaryCache = cache
+	t.Cleanup(func() { monitoringSummaryCache = original })
+	return cache
+}
+
+// waitForRefresh blocks until the cache has no fetch in flight.
+func waitForRefresh(t *testing.T, cache *summaryCache) {
+	t.Helper()
+	cache.mu.Lock()
+	fetch := cache.inflight
+	cache.mu.Unlock()
+	if fetch == nil {
+		return
+	}
+	select {
+	case <-fetch.done:
+	case <-time.After(time.Second):
+		t.Fatalf("refresh did not finish")
+	}
+}
+
+func TestSummaryCacheSharesConcurrentFetches(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	cache := newSummaryCache(time.Minute, time.Minute, newMemoryCache(time.Now), time.Now, func(ctx context.Context) (MonitoringSummary, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return MonitoringSummary{TotalConnectors: 3}, nil
+	})
+
+	var wg sync.WaitGroup
+	results := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary, _, state, err := cache.get(context.Background())
+			if err != nil || summary.TotalConnectors != 3 {
+				t.Errorf("unexpected result %+v, %v", summary, err)
+			}
+			results <- state
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected one upstream fetch, got %d", n)
+	}
+	for state := range results {
+		if state != cacheMiss && state != cacheHit {
+			t.Fatalf("unexpected cache state %q", state)
+		}
+	}
+}
+
+func TestSummaryCacheServesStaleWhileRevalidating(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	total, fail := 1, false
+	cache := newSummaryCache(10*time.Second, time.Minute, newMemoryCache(func() time.Time { return now }), func() time.Time { return now }, func(ctx context.Context) (MonitoringSummary, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return MonitoringSummary{}, errors.New("connect down")
+		}
+		return MonitoringSummary{TotalConnectors: total}, nil
+	})
+
+	if summary, _, state, _ := cache.get(context.Background()); state != cacheMiss || summary.TotalConnectors != 1 {
+		t.Fatalf("expected a MISS, got %s %+v", state, summary)
+	}
+	now = now.Add(5 * time.Second)
+	if _, age, state, _ := cache.get(context.Background()); state != cacheHit || age != 5*time.Second {
+		t.Fatalf("expected a HIT aged 5s, got %s %s", state, age)
+	}
+
+	mu.Lock()
+	total = 2
+	mu.Unlock()
+	now = now.Add(10 * time.Second)
+	if summary, _, state, _ := cache.get(context.Background()); state != cacheStale || summary.TotalConnectors != 1 {
+		t.Fatalf("expected the old summary as STALE, got %s %+v", state, summary)
+	}
+	waitForRefresh(t, cache)
+	if summary, _, state, _ := cache.get(context.Background()); state != cacheHit || summary.TotalConnectors != 2 {
+		t.Fatalf("expected the refreshed summary, got %s %+v", state, summary)
+	}
+
+	// A failed refresh keeps serving the last summary until it is too old.
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	now = now.Add(30 * time.Second)
+	if summary, _, state, _ := cache.get(context.Background()); state != cacheStale || summary.TotalConnectors != 2 {
+		t.Fatalf("expected STALE after a failed refresh, got %s %+v", state, summary)
+	}
+	waitForRefresh(t, cache)
+	now = now.Add(time.Minute)
+	if _, _, state, err := cache.get(context.Background()); state != cacheMiss || err == nil {
+		t.Fatalf("expected a failed MISS once the summary is too old, got %s %v", state, err)
+	}
+}
+
+func TestSummaryCacheDisabled(t *testing.T) {
+	var calls int32
+	cache := newSummaryCache(0, time.Minute, newMemoryCache(time.Now), time.Now, func(ctx context.Context) (MonitoringSummary, error) {
+		atomic.AddInt32(&calls, 1)
+		return MonitoringSummary{}, nil
+	})
+	cache.get(context.Background())
+	cache.get(context.Background())
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected every call to fetch, got %d", n)
+	}
+
+	header := http.Header{}
+	cache.setHeaders(header, 0, cacheMiss)
+	if header.Get("Cache-Control") != "no-store" || header.Get("Age") != "" {
+		t.Fatalf("unexpected headers %v", header)
+	}
+}
+
+func TestMonitoringSummaryHandlerCacheHeaders(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	original := monitoringSummaryCache
+	monitoringSummaryCache = newSummaryCache(10*time.Second, time.Minute, newMemoryCache(func() time.Time { return now }), func() time.Time { return now }, func(ctx context.Context) (MonitoringSummary, error) {
+		return MonitoringSummary{ClusterID: "demo"}, nil
+	})
+	t.Cleanup(func() { monitoringSummaryCache = original })
+
+	get := func() *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/monitoring/summary", nil), map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		monitoringSummaryHandler(rr, req)
+		return rr
+	}
+
+	rr := get()
+	if rr.Header().Get("X-Cache") != cacheMiss || rr.Header().Get("Cache-Control") != "private, max-age=10, stale-while-revalidate=60" || rr.Header().Get("Age") != "0" {
+		t.Fatalf("unexpected headers on a miss %v", rr.Header())
+	}
+	now = now.Add(4 * time.Second)
+	if rr := get(); rr.Header().Get("X-Cache") != cacheHit || rr.Header().Get("Age") != "4" {
+		t.Fatalf("unexpected headers on a hit %v", rr.Header())
+	}
+	now = now.Add(10 * time.Second)
+	if rr := get(); rr.Header().Get("X-Cache") != cacheStale || rr.Header().Get("Age") != "14" {
+		t.Fatalf("unexpected headers on a stale response %v", rr.Header())
+	}
+	waitForRefresh(t, monitoringSummaryCache)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+const summarySnapshotsFile = "summary-snapshots.json"
+
+// Kinds of change reported by the summary diff.
+const (
+	summaryChangeAdded   = "added"
+	summaryChangeChanged = "changed"
+	summaryChangeRemoved = "removed"
+)
+
+var (
+	summarySnapshotRetention = getEnv("SUMMARY_SNAPSHOT_RETENTION", "1h")
+
+	summarySnapshots = newSummarySnapshotStore(time.Hour, time.Now)
+)
+
+// summarySnapshot is the state of every connector as of a monitoring summary. A new
+// snapshot is only taken when something changed, so each one holds until the next.
+type summarySnapshot struct {
+	Taken      time.Time                          `json:"taken"`
+	Connectors map[string]ConnectorStatusOverview `json:"connectors"`
+}
+
+// ConnectorStateChange is a connector whose state or task states differ from the
+// snapshot a diff started from. Previous* are unset for added connectors, and State and
+// TaskStates for removed ones.
+type ConnectorStateChange struct {
+	Name               string         `json:"name"`
+	Change             string         `json:"change"`
+	Type               string         `json:"type,omitempty"`
+	State              string         `json:"state,omitempty"`
+	TaskStates         map[string]int `json:"taskStates,omitempty"`
+	PreviousState      string         `json:"previousState,omitempty"`
+	PreviousTaskStates map[string]int `json:"previousTaskStates,omitempty"`
+}
+
+// SummaryDiff is returned by GET /api/{cluster}/monitoring/summary/diff. Snapshot is
+// the token to pass as since on the next poll. Reset is set when since predates the
+// retained snapshots (or was not given), in which case every connector is listed as
+// added.
+type SummaryDiff struct {
+	Since      *time.Time             `json:"since,omitempty"`
+	Snapshot   string                 `json:"snapshot"`
+	Reset      bool                   `json:"reset"`
+	Connectors []ConnectorStateChange `json:"connectors"`
+}
+
+// summarySnapshotStore keeps the recent monitoring summaries, persisted in DATA_DIR, so
+// pollers can ask what changed since the summary they last saw.
+type summarySnapshotStore struct {
+	mu        sync.Mutex
+	retention time.Duration
+	now       func() time.Time
+	snapshots []summarySnapshot
+}
+
+func newSummarySnapshotStore(retention time.Duration, now func() time.Time) *summarySnapshotStore {
+	return &summarySnapshotStore{retention: retention, now: now}
+}
+
+func (s *summarySnapshotStore) load() error {
+	var snapshots []summarySnapshot
+	if err := loadJSON(summarySnapshotsFile, &snapshots); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots = snapshots
+	return nil
+}
+
+// record takes a snapshot of summary when it differs from the latest one.
+func (s *summarySnapshotStore) record(summary MonitoringSummary) {
+	connectors := make(map[string]ConnectorStatusOverview, len(summary.Connectors))
+	for _, overview := range summary.Connectors {
+		// Grades move with metrics and tasks with rebalances; only states and task
+		// states make a new snapshot.
+		overview.Health, overview.Tasks = nil, nil
+		connectors[overview.Name] = overview
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	changed := s.pruneLocked(now)
+	if n := len(s.snapshots); n == 0 || !reflect.DeepEqual(s.snapshots[n-1].Connectors, connectors) {
+		s.snapshots = append(s.snapshots, summarySnapshot{Taken: now, Connectors: connectors})
+		changed = true
+	}
+	if changed {
+		if err := saveJSON(summarySnapshotsFile, s.snapshots); err != nil {
+			log.Printf("summary snapshots: failed to persist: %v", err)
+		}
+	}
+}
+
+// pruneLocked drops the snapshots superseded before the retention window. The one in
+// force at its start is kept, so every since within the window has a baseline.
+func (s *summarySnapshotStore) pruneLocked(now time.Time) bool {
+	cutoff := now.Add(-s.retention)
+	drop := 0
+	for drop+1 < len(s.snapshots) && !s.snapshots[drop+1].Taken.After(cutoff) {
+		drop++
+	}
+	if drop == 0 {
+		return false
+	}
+	s.snapshots = append([]summarySnapshot(nil), s.snapshots[drop:]...)
+	return true
+}
+
+// diff compares the latest snapshot with the one in force at since. It must not be
+// called before the first record.
+func (s *summarySnapshotStore) diff(since time.Time) SummaryDiff {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := s.snapshots[len(s.snapshots)-1]
+	result := SummaryDiff{Snapshot: latest.Taken.Format(time.RFC3339Nano), Connectors: []ConnectorStateChange{}}
+	if !since.IsZero() {
+		result.Since = &since
+	}
+
+	var baseline map[string]ConnectorStatusOverview
+	for i := len(s.snapshots) - 1; i >= 0 && !since.IsZero(); i-- {
+		if !s.snapshots[i].Taken.After(since) {
+			baseline = s.snapshots[i].Connectors
+			break
+		}
+	}
+	result.Reset = baseline == nil
+
+	for name, current := range latest.Connectors {
+		previous, existed := baseline[name]
+		switch {
+		case !existed:
+			result.Connectors = append(result.Connectors, ConnectorStateChange{
+				Name: name, Change: summaryChangeAdded, Type: current.Type, State: current.State, TaskStates: current.TaskStates,
+			})
+		case previous.State != current.State || !reflect.DeepEqual(previous.TaskStates, current.TaskStates):
+			result.Connectors = append(result.Connectors, ConnectorStateChange{
+				Name: name, Change: summaryChangeChanged, Type: current.Type, State: current.State, TaskStates: current.TaskStates,
+				PreviousState: previous.State, PreviousTaskStates: previous.TaskStates,
+			})
+		}
+	}
+	for name, previous := range baseline {
+		if _, exists := latest.Connectors[name]; !exists {
+			result.Connectors = append(result.Connectors, ConnectorStateChange{
+				Name: name, Change: summaryChangeRemoved, Type: previous.Type,
+				PreviousState: previous.State, PreviousTaskStates: previous.TaskStates,
+			})
+		}
+	}
+	sort.Slice(result.Connectors, func(i, j int) bool { return result.Connectors[i].Name < result.Connectors[j].Name })
+	return result
+}
+
+// monitoringSummaryDiffHandler lists the connectors whose state or task states changed
+// since ?since=, a snapshot token from a previous call or any timestamp within
+// SUMMARY_SNAPSHOT_RETENTION. Without since every connector is listed.
+func monitoringSummaryDiffHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var since time.Time
+	if raw := query.Get("since"); raw != "" {
+		loc, err := requestLocation(query)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_time_range", err.Error())
+			return
+		}
+		if since, err = parseTimestamp(raw, loc); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_time_range", err.Error())
+			return
+		}
+	}
+
+	summary, _, _, err := monitoringSummaryCache.get(r.Context())
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "summary_fetch_failed", err.Error())
+		return
+	}
+	// The summary may come from another replica's fetch through the shared cache.
+	summarySnapshots.record(summary)
+
+	writeJSON(w, http.StatusOK, summarySnapshots.diff(since))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSummarySnapshotDiff(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := newSummarySnapshotStore(time.Hour, func() time.Time { return now })
+
+	store.record(MonitoringSummary{Connectors: []ConnectorStatusOverview{
+		{Name: "orders-sink", State: "running", Type: "sink", TaskStates: map[string]int{"running": 2}},
+		{Name: "payments-cdc", State: "running", Type: "source"},
+		{Name: "legacy", State: "paused", Type: "sink"},
+	}})
+	first := now
+
+	now = now.Add(10 * time.Second)
+	store.record(MonitoringSummary{Connectors: []ConnectorStatusOverview{
+		{Name: "orders-sink", State: "running", Type: "sink", TaskStates: map[string]int{"running": 2}},
+		{Name: "payments-cdc", State: "running", Type: "source"},
+		{Name: "legacy", State: "paused", Type: "sink"},
+	}})
+	if len(store.snapshots) != 1 {
+		t.Fatalf("expected an unchanged summary not to add a snapshot, got %d", len(store.snapshots))
+	}
+
+	now = now.Add(10 * time.Second)
+	store.record(MonitoringSummary{Connectors: []ConnectorStatusOverview{
+		{Name: "orders-sink", State: "running", Type: "sink", TaskStates: map[string]int{"running": 1, "failed": 1}},
+		{Name: "payments-cdc", State: "running", Type: "source"},
+		{Name: "audit-s3", State: "running", Type: "sink"},
+	}})
+
+	diff := store.diff(first.Add(5 * time.Second))
+	if diff.Reset || diff.Snapshot != now.Format(time.RFC3339Nano) || len(diff.Connectors) != 3 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	changes := map[string]ConnectorStateChange{}
+	for _, change := range diff.Connectors {
+		changes[change.Name] = change
+	}
+	if changes["audit-s3"].Change != summaryChangeAdded || changes["legacy"].Change != summaryChangeRemoved || changes["legacy"].PreviousState != "paused" {
+		t.Fatalf("unexpected added/removed connectors %+v", diff.Connectors)
+	}
+	if orders := changes["orders-sink"]; orders.Change != summaryChangeChanged || orders.TaskStates["failed"] != 1 || orders.PreviousTaskStates["running"] != 2 {
+		t.Fatalf("expected the failed task to be reported, got %+v", orders)
+	}
+
+	if diff := store.diff(now); diff.Reset || len(diff.Connectors) != 0 {
+		t.Fatalf("expected nothing to change since the latest snapshot, got %+v", diff)
+	}
+	if diff := store.diff(first.Add(-time.Second)); !diff.Reset || len(diff.Connectors) != 3 {
+		t.Fatalf("expected a since before the first snapshot to list everything, got %+v", diff)
+	}
+
+	now = now.Add(2 * time.Hour)
+	store.record(MonitoringSummary{})
+	if len(store.snapshots) != 2 || !store.snapshots[0].Taken.Equal(first.Add(20*time.Second)) {
+		t.Fatalf("expected snapshots superseded before the retention window to be pruned, got %+v", store.snapshots)
+	}
+}
+
+func TestMonitoringSummaryDiffHandler(t *testing.T) {
+	originalCache, originalStore := monitoringSummaryCache, summarySnapshots
+	t.Cleanup(func() { monitoringSummaryCache, summarySnapshots = originalCache, originalStore })
+
+	state := "RUNNING"
+	monitoringSummaryCache = newSummaryCache(0, 0, newMemoryCache(time.Now), time.Now, func(context.Context) (MonitoringSummary, error) {
+		return MonitoringSummary{Connectors: []ConnectorStatusOverview{{Name: "orders-sink", State: normalizeState(state), Type: "sink"}}}, nil
+	})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	summarySnapshots = newSummarySnapshotStore(time.Hour, func() time.Time { return now })
+
+	poll := func(since string) SummaryDiff {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		monitoringSummaryDiffHandler(rr, httptest.NewRequest(http.MethodGet, "/api/default/monitoring/summary/diff?"+url.Values{"since": {since}}.Encode(), nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var diff SummaryDiff
+		if err := json.Unmarshal(rr.Body.Bytes(), &diff); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return diff
+	}
+
+	first := poll("")
+	if !first.Reset || len(first.Connectors) != 1 {
+		t.Fatalf("expected the first poll to list every connector, got %+v", first)
+	}
+	if diff := poll(first.Snapshot); diff.Reset || len(diff.Connectors) != 0 || diff.Snapshot != first.Snapshot {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+
+	state, now = "FAILED", now.Add(time.Minute)
+	diff := poll(first.Snapshot)
+	if len(diff.Connectors) != 1 || diff.Connectors[0].State != "failed" || diff.Connectors[0].PreviousState != "running" || diff.Snapshot == first.Snapshot {
+		t.Fatalf("expected the failure to be reported, got %+v", diff)
+	}
+
+	rr := httptest.NewRecorder()
+	monitoringSummaryDiffHandler(rr, httptest.NewRequest(http.MethodGet, "/api/default/monitoring/summary/diff?since=yesterday", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid since to be rejected, got %d", rr.Code)
+	}
+}
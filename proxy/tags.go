@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxTagLength bounds a tag so tags stay usable as filter values and UI labels.
+const maxTagLength = 64
+
+// connectorTagsRequest is the body of PUT /api/{cluster}/connectors/{name}/tags.
+type connectorTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// TagSummary is one tag of a cluster with the connectors carrying it.
+type TagSummary struct {
+	Name       string   `json:"name"`
+	Count      int      `json:"count"`
+	Connectors []string `json:"connectors"`
+}
+
+// TagList is returned by GET /api/{cluster}/tags.
+type TagList struct {
+	Tags []TagSummary `json:"tags"`
+}
+
+// validateTags rejects tags that could not be told apart in a comma-separated filter.
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if len(tag) > maxTagLength {
+			return fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		if strings.Contains(tag, ",") {
+			return fmt.Errorf("tag %q must not contain a comma", tag)
+		}
+	}
+	return nil
+}
+
+// hasAnyTag reports whether tags contains one of wanted.
+func hasAnyTag(tags, wanted []string) bool {
+	for _, tag := range wanted {
+		if containsString(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// connectorTagsHandler replaces the tags of a connector. Tags are kept with the rest of
+// the connector metadata; an empty list removes them all.
+func connectorTagsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+
+	var req connectorTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Tags == nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", `request body must be a JSON object with a "tags" list`)
+		return
+	}
+	if err := validateTags(req.Tags); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	updated, err := connectorMetadata.update(cluster, []string{name}, metadataPatch{Tags: req.Tags}, requestUser(r))
+	if err != nil {
+		log.Printf("metadata: failed to persist tags for %s: %v", name, err)
+		writeJSONError(w, http.StatusInternalServerError, "metadata_store_failed", "failed to persist connector metadata")
+		return
+	}
+	recordAudit(r, auditActionUpdateMetadata, name, http.StatusOK, map[string]interface{}{"tags": updated[name].Tags})
+	writeJSON(w, http.StatusOK, updated[name])
+}
+
+// tagsHandler lists every tag used in a cluster, with its connectors, sorted by name.
+func tagsHandler(w http.ResponseWriter, r *http.Request) {
+	connectors := make(map[string][]string)
+	for name, meta := range connectorMetadata.all(mux.Vars(r)["cluster"]) {
+		for _, tag := range meta.Tags {
+			connectors[tag] = append(connectors[tag], name)
+		}
+	}
+
+	list := TagList{Tags: make([]TagSummary, 0, len(connectors))}
+	for tag, names := range connectors {
+		sort.Strings(names)
+		list.Tags = append(list.Tags, TagSummary{Name: tag, Count: len(names), Connectors: names})
+	}
+	sort.Slice(list.Tags, func(i, j int) bool { return list.Tags[i].Name < list.Tags[j].Name })
+	writeJSON(w, http.StatusOK, list)
+}
+
+// withTags returns a copy of the summary with each connector's tags. When filter is
+// set, only connectors with one of its tags are kept and every count is recomputed for
+// them. The cached summary itself is never modified.
+func (s MonitoringSummary) withTags(metadata map[string]ConnectorMetadata, filter []string) MonitoringSummary {
+	connectors := make([]ConnectorStatusOverview, 0, len(s.Connectors))
+	for _, overview := range s.Connectors {
+		overview.Tags = metadata[overview.Name].Tags
+		if len(filter) == 0 || hasAnyTag(overview.Tags, filter) {
+			connectors = append(connectors, overview)
+		}
+	}
+	if len(filter) == 0 {
+		s.Connectors = connectors
+		return s
+	}
+	return s.recounted(connectors)
+}
+
+// recounted returns the summary of the given connectors only, with the totals, state
+// counts and grades counted again.
+func (s MonitoringSummary) recounted(connectors []ConnectorStatusOverview) MonitoringSummary {
+	s.Connectors = connectors
+	s.TotalConnectors = len(connectors)
+	s.ConnectorStates, s.TaskStates = newStateCounter(), newStateCounter()
+	s.Totals = map[string]int{"total": len(connectors), "running": 0, "degraded": 0, "failed": 0, "stopped": 0}
+	if s.Grades != nil {
+		s.Grades = map[string]int{}
+	}
+	for _, overview := range connectors {
+		s.ConnectorStates[overview.State]++
+		for state, count := range overview.TaskStates {
+			s.TaskStates[state] += count
+		}
+		s.Totals[connectorTotalsClass(overview.State, overview.TaskStates)]++
+		if overview.Health != nil && s.Grades != nil {
+			s.Grades[overview.Health.Grade]++
+		}
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestConnectorTagsHandlers(t *testing.T) {
+	withTestMetadataStore(t)
+	audit := withTestAuditLog(t, 10)
+
+	putTags := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/default/connectors/"+name+"/tags", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": name})
+		rr := httptest.NewRecorder()
+		connectorTagsHandler(rr, req)
+		return rr
+	}
+
+	if rr := putTags("orders-sink", `{"tags": ["squad-payments", " pii ", "pii"]}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	putTags("users-cdc", `{"tags": ["pii"]}`)
+	if entries := audit.Query(AuditFilter{Action: auditActionUpdateMetadata}); len(entries) != 2 || entries[0].ConnectorName != "users-cdc" {
+		t.Fatalf("expected tag changes to be audited, got %+v", entries)
+	}
+
+	for _, body := range []string{`{}`, `{"tags": ["a,b"]}`, `not json`} {
+		if rr := putTags("orders-sink", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/tags", nil), map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	tagsHandler(rr, req)
+	var list TagList
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Tags) != 2 || list.Tags[0].Name != "pii" || list.Tags[0].Count != 2 || list.Tags[0].Connectors[0] != "orders-sink" || list.Tags[1].Name != "squad-payments" {
+		t.Fatalf("unexpected tags %+v", list.Tags)
+	}
+
+	if rr := putTags("orders-sink", `{"tags": []}`); rr.Code != http.StatusOK || len(connectorMetadata.get("default", "orders-sink").Tags) != 0 {
+		t.Fatalf("expected an empty list to clear the tags, got %d", rr.Code)
+	}
+}
+
+func TestMonitoringSummaryWithTags(t *testing.T) {
+	summary := MonitoringSummary{
+		TotalConnectors: 3,
+		Connectors: []ConnectorStatusOverview{
+			{Name: "orders-sink", State: "running", TaskStates: map[string]int{"running": 1, "failed": 1}, Health: &HealthGrade{Grade: "C"}},
+			{Name: "users-cdc", State: "paused", TaskStates: map[string]int{"paused": 2}, Health: &HealthGrade{Grade: "B"}},
+			{Name: "clicks-sink", State: "running", TaskStates: map[string]int{"running": 1}, Health: &HealthGrade{Grade: "A"}},
+		},
+		Grades: map[string]int{"A": 1, "B": 1, "C": 1},
+	}
+	metadata := map[string]ConnectorMetadata{
+		"orders-sink": {Tags: []string{"pii", "squad-payments"}},
+		"users-cdc":   {Tags: []string{"pii"}},
+	}
+
+	all := summary.withTags(metadata, nil)
+	if all.TotalConnectors != 3 || len(all.Connectors[0].Tags) != 2 || all.Connectors[2].Tags != nil {
+		t.Fatalf("expected every connector with its tags, got %+v", all.Connectors)
+	}
+
+	pii := summary.withTags(metadata, []string{"pii"})
+	if pii.TotalConnectors != 2 || pii.Totals["degraded"] != 2 || pii.Totals["running"] != 0 || pii.ConnectorStates["paused"] != 1 || pii.TaskStates["paused"] != 2 || pii.Grades["A"] != 0 || pii.Grades["B"] != 1 {
+		t.Fatalf("expected counts of the tagged connectors, got %+v", pii)
+	}
+	if summary.Connectors[0].Tags != nil || summary.TotalConnectors != 3 {
+		t.Fatal("expected the original summary to be left alone")
+	}
+}
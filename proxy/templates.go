@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// connectorTemplatesDir holds additional connector templates (*.yaml, *.yml, *.json).
+// A template with the same id as a built-in one replaces it.
+var connectorTemplatesDir = getEnv("CONNECTOR_TEMPLATES_DIR", "")
+
+//go:embed connector-templates/*.yaml
+var builtinConnectorTemplates embed.FS
+
+var connectorTemplates = map[string]*ConnectorTemplate{}
+
+// TemplateVariable is a value the user supplies when rendering a template.
+type TemplateVariable struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Required    bool     `json:"required" yaml:"required"`
+	Default     string   `json:"default,omitempty" yaml:"default"`
+	Enum        []string `json:"enum,omitempty" yaml:"enum"`
+	Example     string   `json:"example,omitempty" yaml:"example"`
+	Secret      bool     `json:"secret,omitempty" yaml:"secret"`
+}
+
+// ConnectorTemplate is a curated connector config. Config values are Go text/template
+// strings rendered with the template variables, e.g. "{{.connectionUrl}}".
+type ConnectorTemplate struct {
+	ID             string             `json:"id" yaml:"id"`
+	Name           string             `json:"name" yaml:"name"`
+	Description    string             `json:"description,omitempty" yaml:"description"`
+	Category       string             `json:"category,omitempty" yaml:"category"`
+	ConnectorClass string             `json:"connectorClass" yaml:"connectorClass"`
+	Variables      []TemplateVariable `json:"variables" yaml:"variables"`
+	Config         map[string]string  `json:"config" yaml:"config"`
+
+	compiled map[string]*template.Template
+}
+
+// templateRenderRequest is the body of POST /api/{cluster}/templates/{id}/render.
+type templateRenderRequest struct {
+	Name      string            `json:"name"`
+	Variables map[string]string `json:"variables"`
+}
+
+// RenderedConnector is a config ready to POST to /api/{cluster}/connectors.
+type RenderedConnector struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+}
+
+// parseConnectorTemplate decodes a YAML or JSON template and compiles its config values.
+func parseConnectorTemplate(data []byte, source string) (*ConnectorTemplate, error) {
+	var tmpl ConnectorTemplate
+	if strings.HasSuffix(source, ".json") {
+		if err := json.Unmarshal(data, &tmpl); err != nil {
+			return nil, fmt.Errorf("parse template %s: %w", source, err)
+		}
+	} else if err := yaml.Unmarshal(data, &tmpl); err != nil {
+		return nil, fmt.Errorf("parse template %s: %w", source, err)
+	}
+
+	if tmpl.ID == "" {
+		return nil, fmt.Errorf("template %s: id is required", source)
+	}
+	if len(tmpl.Config) == 0 {
+		return nil, fmt.Errorf("template %s: config is empty", source)
+	}
+	if tmpl.Name == "" {
+		tmpl.Name = tmpl.ID
+	}
+	if tmpl.Variables == nil {
+		tmpl.Variables = []TemplateVariable{}
+	}
+
+	declared := make(map[string]bool, len(tmpl.Variables))
+	for _, variable := range tmpl.Variables {
+		if variable.Name == "" || declared[variable.Name] {
+			return nil, fmt.Errorf("template %s: variable names must be unique and non-empty", source)
+		}
+		declared[variable.Name] = true
+	}
+
+	tmpl.compiled = make(map[string]*template.Template, len(tmpl.Config))
+	for key, value := range tmpl.Config {
+		compiled, err := template.New(key).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: config %s: %w", source, key, err)
+		}
+		tmpl.compiled[key] = compiled
+	}
+	return &tmpl, nil
+}
+
+// loadConnectorTemplates returns the built-in templates overlaid with those in dir.
+func loadConnectorTemplates(dir string) (map[string]*ConnectorTemplate, error) {
+	templates := make(map[string]*ConnectorTemplate)
+
+	builtins, err := fs.Glob(builtinConnectorTemplates, "connector-templates/*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range builtins {
+		data, err := builtinConnectorTemplates.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := parseConnectorTemplate(data, path)
+		if err != nil {
+			return nil, err
+		}
+		templates[tmpl.ID] = tmpl
+	}
+
+	if dir == "" {
+		return templates, nil
+	}
+
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read template %s: %w", path, err)
+		}
+		tmpl, err := parseConnectorTemplate(data, path)
+		if err != nil {
+			return nil, err
+		}
+		templates[tmpl.ID] = tmpl
+	}
+	return templates, nil
+}
+
+// render fills in the variables and returns the resulting config. Unknown variables,
+// missing required variables and values outside an enum are rejected. Config entries
+// that render to an empty string are dropped, so optional settings can be left out.
+func (t *ConnectorTemplate) render(name string, values map[string]string) (RenderedConnector, error) {
+	if strings.TrimSpace(name) == "" {
+		return RenderedConnector{}, fmt.Errorf("name is required")
+	}
+
+	data := make(map[string]string, len(t.Variables))
+	var problems []string
+	for _, variable := range t.Variables {
+		value, ok := values[variable.Name]
+		if !ok || value == "" {
+			value = variable.Default
+		}
+		if value == "" && variable.Required {
+			problems = append(problems, fmt.Sprintf("%s is required", variable.Name))
+			continue
+		}
+		if value != "" && len(variable.Enum) > 0 && !containsString(variable.Enum, value) {
+			problems = append(problems, fmt.Sprintf("%s must be one of %s", variable.Name, strings.Join(variable.Enum, ", ")))
+			continue
+		}
+		data[variable.Name] = value
+	}
+	for key := range values {
+		if !t.declares(key) {
+			problems = append(problems, fmt.Sprintf("%s is not a variable of template %s", key, t.ID))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return RenderedConnector{}, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	config := make(map[string]string, len(t.compiled)+1)
+	for key, compiled := range t.compiled {
+		var out bytes.Buffer
+		if err := compiled.Execute(&out, data); err != nil {
+			return RenderedConnector{}, fmt.Errorf("render %s: %w", key, err)
+		}
+		if out.Len() > 0 {
+			config[key] = out.String()
+		}
+	}
+	config["name"] = name
+	return RenderedConnector{Name: name, Config: config}, nil
+}
+
+func (t *ConnectorTemplate) declares(name string) bool {
+	for _, variable := range t.Variables {
+		if variable.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// listTemplatesHandler returns the available connector templates sorted by id.
+func listTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	templates := make([]*ConnectorTemplate, 0, len(connectorTemplates))
+	for _, tmpl := range connectorTemplates {
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].ID < templates[j].ID })
+	writeJSON(w, http.StatusOK, templates)
+}
+
+// renderTemplateHandler fills in a template and returns a ready-to-submit connector.
+func renderTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	tmpl, ok := connectorTemplates[id]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "template_not_found", fmt.Sprintf("no connector template %q", id))
+		return
+	}
+
+	var req templateRenderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "request body must be a JSON object with name and variables")
+		return
+	}
+
+	rendered, err := tmpl.render(req.Name, req.Variables)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_variables", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rendered)
+}
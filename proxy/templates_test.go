@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestConnectorTemplates(t *testing.T, dir string) map[string]*ConnectorTemplate {
+	t.Helper()
+	templates, err := loadConnectorTemplates(dir)
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	original := connectorTemplates
+	connectorTemplates = templates
+	t.Cleanup(func() { connectorTemplates = original })
+	return templates
+}
+
+func TestBuiltinConnectorTemplatesLoad(t *testing.T) {
+	templates := withTestConnectorTemplates(t, "")
+	for _, id := range []string{"jdbc-source", "s3-sink", "debezium-postgres", "debezium-mysql"} {
+		if _, ok := templates[id]; !ok {
+			t.Errorf("missing built-in template %s", id)
+		}
+	}
+}
+
+func TestConnectorTemplatesDirOverridesBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	custom := "id: s3-sink\nname: Team S3 Sink\nconfig:\n  connector.class: com.example.S3Sink\n"
+	if err := os.WriteFile(filepath.Join(dir, "s3.yaml"), []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "http.json"), []byte(`{"id":"http-sink","config":{"connector.class":"HttpSink","http.api.url":"{{.url}}"},"variables":[{"name":"url","required":true}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates := withTestConnectorTemplates(t, dir)
+	if templates["s3-sink"].Name != "Team S3 Sink" {
+		t.Fatalf("expected directory template to replace the built-in one, got %q", templates["s3-sink"].Name)
+	}
+	if _, ok := templates["http-sink"]; !ok {
+		t.Fatalf("expected JSON template to be loaded")
+	}
+	if _, ok := templates["jdbc-source"]; !ok {
+		t.Fatalf("expected other built-ins to remain")
+	}
+}
+
+func TestParseConnectorTemplateRejectsInvalid(t *testing.T) {
+	cases := map[string]string{
+		"missing id":      "config:\n  a: b\n",
+		"empty config":    "id: x\n",
+		"bad template":    "id: x\nconfig:\n  a: '{{.unterminated'\n",
+		"duplicate names": "id: x\nvariables:\n  - name: a\n  - name: a\nconfig:\n  a: b\n",
+	}
+	for name, data := range cases {
+		if _, err := parseConnectorTemplate([]byte(data), "test.yaml"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestConnectorTemplateRender(t *testing.T) {
+	templates := withTestConnectorTemplates(t, "")
+	tmpl := templates["jdbc-source"]
+
+	rendered, err := tmpl.render("orders-jdbc", map[string]string{
+		"connectionUrl": "jdbc:postgresql://db:5432/app",
+		"user":          "app",
+		"password":      "${file:/secrets:db.password}",
+		"topicPrefix":   "db-",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := rendered.Config
+	if config["name"] != "orders-jdbc" || config["connection.url"] != "jdbc:postgresql://db:5432/app" {
+		t.Fatalf("unexpected config: %v", config)
+	}
+	if config["mode"] != "incrementing" || config["incrementing.column.name"] != "id" {
+		t.Fatalf("expected defaults to be applied: %v", config)
+	}
+	if _, ok := config["table.whitelist"]; ok {
+		t.Fatalf("expected empty optional setting to be dropped: %v", config)
+	}
+
+	_, err = tmpl.render("orders-jdbc", map[string]string{"mode": "sometimes", "bogus": "x"})
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{"connectionUrl is required", "mode must be one of", "bogus is not a variable"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestTemplateHandlers(t *testing.T) {
+	withTestConnectorTemplates(t, "")
+
+	rr := httptest.NewRecorder()
+	listTemplatesHandler(rr, httptest.NewRequest(http.MethodGet, "/api/default/templates", nil))
+	var listed []ConnectorTemplate
+	if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(listed) < 4 || listed[0].ID != "debezium-mysql" {
+		t.Fatalf("expected templates sorted by id, got %d starting with %q", len(listed), listed[0].ID)
+	}
+
+	render := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/templates/"+id+"/render", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "id": id})
+		rr := httptest.NewRecorder()
+		renderTemplateHandler(rr, req)
+		return rr
+	}
+
+	rr = render("s3-sink", `{"name":"orders-s3","variables":{"topics":"orders","bucket":"archive","format":"parquet"}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var rendered RenderedConnector
+	if err := json.Unmarshal(rr.Body.Bytes(), &rendered); err != nil {
+		t.Fatalf("decode render: %v", err)
+	}
+	if rendered.Config["format.class"] != "io.confluent.connect.s3.format.parquet.ParquetFormat" {
+		t.Fatalf("unexpected format class: %v", rendered.Config)
+	}
+
+	if rr := render("s3-sink", `{"name":"orders-s3","variables":{}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for missing variables, got %d", rr.Code)
+	}
+	if rr := render("nope", `{}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown template, got %d", rr.Code)
+	}
+}
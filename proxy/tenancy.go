@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// With TENANCY_NAMESPACES set, users only see and change the connectors in the
+	// namespaces of their groups. Each entry maps a group to "|"-separated namespaces:
+	// a connector name prefix, or tag:<tag> for the connectors carrying that tag, e.g.
+	// "orders-team=orders-|tag:orders,payments=pay-". Members of TENANCY_ADMIN_GROUPS
+	// and local admins see every connector.
+	tenancyNamespaces  = getEnv("TENANCY_NAMESPACES", "")
+	tenancyAdminGroups = getEnv("TENANCY_ADMIN_GROUPS", "")
+
+	// tenancy is nil unless TENANCY_NAMESPACES is set.
+	tenancy *tenancyConfig
+)
+
+const tenantTagPrefix = "tag:"
+
+// tenantNamespace selects connectors by name prefix or by tag.
+type tenantNamespace struct {
+	prefix string
+	tag    string
+}
+
+func (n tenantNamespace) String() string {
+	if n.tag != "" {
+		return tenantTagPrefix + n.tag
+	}
+	return n.prefix
+}
+
+// tenancyConfig maps groups to their namespaces.
+type tenancyConfig struct {
+	namespaces  map[string][]tenantNamespace
+	adminGroups []string
+}
+
+// loadTenancy parses TENANCY_NAMESPACES and TENANCY_ADMIN_GROUPS. It returns nil when
+// TENANCY_NAMESPACES is unset.
+func loadTenancy() (*tenancyConfig, error) {
+	if strings.TrimSpace(tenancyNamespaces) == "" {
+		return nil, nil
+	}
+	config := &tenancyConfig{namespaces: make(map[string][]tenantNamespace), adminGroups: splitList(tenancyAdminGroups)}
+	for _, item := range splitList(tenancyNamespaces) {
+		group, value, ok := strings.Cut(item, "=")
+		group = strings.TrimSpace(group)
+		if !ok || group == "" {
+			return nil, &configError{name: "TENANCY_NAMESPACES", value: item}
+		}
+		if _, dup := config.namespaces[group]; dup {
+			return nil, fmt.Errorf("TENANCY_NAMESPACES: group %q listed twice", group)
+		}
+		var namespaces []tenantNamespace
+		for _, spec := range strings.Split(value, "|") {
+			spec = strings.TrimSpace(spec)
+			switch {
+			case spec == "":
+			case strings.HasPrefix(spec, tenantTagPrefix):
+				tag := normalizeTags([]string{strings.TrimPrefix(spec, tenantTagPrefix)})
+				if len(tag) != 1 {
+					return nil, &configError{name: "TENANCY_NAMESPACES", value: item}
+				}
+				namespaces = append(namespaces, tenantNamespace{tag: tag[0]})
+			default:
+				namespaces = append(namespaces, tenantNamespace{prefix: spec})
+			}
+		}
+		if len(namespaces) == 0 {
+			return nil, &configError{name: "TENANCY_NAMESPACES", value: item}
+		}
+		config.namespaces[group] = namespaces
+	}
+	// Without a login or a trusted authenticating proxy nobody has groups, and tenancy
+	// could only be kept by trusting headers any client can send.
+	if !loginEnabled() && !trustAuthHeaders {
+		return nil, errors.New("TENANCY_NAMESPACES requires OIDC, local users or TRUST_AUTH_HEADERS=true behind an authenticating proxy")
+	}
+	return config, nil
+}
+
+// tenantScope is the part of a cluster one caller may see.
+type tenantScope struct {
+	all        bool
+	namespaces []tenantNamespace
+}
+
+// tenantScopeFor returns the scope of the caller: everything while tenancy is off or
+// for tenancy admins, otherwise the namespaces of the caller's groups.
+func tenantScopeFor(r *http.Request) tenantScope {
+	config := tenancy
+	if config == nil {
+		return tenantScope{all: true}
+	}
+	groups := requestGroups(r)
+	if signedDeploy(r) || hasAnyTag(groups, config.adminGroups) || (localAuth != nil && localAuth.users.isAdmin(requestUser(r))) {
+		return tenantScope{all: true}
+	}
+	var scope tenantScope
+	for _, group := range groups {
+		scope.namespaces = append(scope.namespaces, config.namespaces[group]...)
+	}
+	return scope
+}
+
+// allows reports whether a connector with the given name and tags is in scope.
+func (s tenantScope) allows(name string, tags []string) bool {
+	if s.all {
+		return true
+	}
+	for _, namespace := range s.namespaces {
+		if namespace.tag != "" && containsString(tags, namespace.tag) {
+			return true
+		}
+		if namespace.prefix != "" && strings.HasPrefix(name, namespace.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsConnector is allows with the tags kept for the connector.
+func (s tenantScope) allowsConnector(cluster, name string) bool {
+	return s.all || s.allows(name, connectorMetadata.get(cluster, name).Tags)
+}
+
+// tenantReadPaths are the cluster-wide reads open to every tenant. The connector lists,
+// the summaries and the topology are filtered to the caller's namespaces; the others
+// hold no connector data. Reads under /connector-plugins are open as well.
+var tenantReadPaths = map[string]bool{
+	"connectors":          true,
+	"connectors/expanded": true,
+	"summary":             true,
+	"monitoring/summary":  true,
+	"topology":            true,
+	"plugins/drift":       true,
+	"cluster":             true,
+	"templates":           true,
+	"standby":             true,
+	"maintenance":         true,
+	"limits":              true,
+}
+
+// tenantWritePaths are the cluster-wide calls other than reads open to every tenant:
+// config validation and the creation helpers, which change nothing.
+var tenantWritePaths = map[string]bool{
+	"connector-plugins/*/config/validate":  true,
+	"connector-plugins/*/config/preflight": true,
+	"wizard/next-step":                     true,
+	"templates/*/render":                   true,
+}
+
+// tenantPathPattern replaces the plugin class or template ID of a cluster path with "*"
+// so it can be looked up in tenantWritePaths.
+func tenantPathPattern(segments []string) string {
+	pattern := make([]string, len(segments))
+	copy(pattern, segments)
+	if len(pattern) >= 3 && (pattern[0] == "connector-plugins" || pattern[0] == "templates") {
+		pattern[1] = "*"
+	}
+	return strings.Join(pattern, "/")
+}
+
+// tenantConnectorName returns the connector a cluster path addresses, if any. The list
+// views under /connectors are not connectors.
+func tenantConnectorName(segments []string) (string, bool) {
+	if len(segments) < 2 || segments[0] != "connectors" || segments[1] == "" {
+		return "", false
+	}
+	switch segments[1] {
+	case "expanded", "stale", "metadata":
+		return "", false
+	}
+	name, err := url.PathUnescape(segments[1])
+	if err != nil {
+		return segments[1], true
+	}
+	return name, true
+}
+
+// tenancyMiddleware keeps tenants inside their namespaces. Connectors outside them read
+// as missing and cannot be changed; creating a connector requires a name in one of
+// them. Cluster-wide endpoints are limited to tenantReadPaths and tenantWritePaths,
+// everything else is left to tenancy admins.
+func tenancyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cluster, ok := mux.Vars(r)["cluster"]
+		// The deploy webhook carries no groups; deployHandler checks its signature and
+		// deploys as a tenancy admin.
+		if tenancy == nil || !ok || r.Method == http.MethodOptions || isDeployWebhook(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		scope := tenantScopeFor(r)
+		if scope.all {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		segments := clusterPathSegments(r.URL.Path)
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead
+		if name, ok := tenantConnectorName(segments); ok {
+			switch {
+			case scope.allowsConnector(cluster, name):
+				next.ServeHTTP(w, r)
+			case read:
+				writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", name))
+			default:
+				writeTenantForbidden(w, fmt.Sprintf("connector %q is outside your namespaces", name))
+			}
+			return
+		}
+
+		pattern := tenantPathPattern(segments)
+		if r.Method == http.MethodPost && pattern == "connectors" {
+			name, _, ok, err := admissionRequest(r)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+				return
+			}
+			if !ok {
+				writeJSONError(w, http.StatusBadRequest, "invalid_request", `request body must be {"name": ..., "config": {...}}`)
+				return
+			}
+			if !scope.allowsConnector(cluster, name) {
+				writeTenantForbidden(w, fmt.Sprintf("connector %q is outside your namespaces %s", name, scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if read && (tenantReadPaths[pattern] || (len(segments) > 0 && segments[0] == "connector-plugins")) || !read && tenantWritePaths[pattern] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeTenantForbidden(w, fmt.Sprintf("%s %s is only available to tenancy admins", r.Method, r.URL.Path))
+	})
+}
+
+func (s tenantScope) String() string {
+	names := make([]string, len(s.namespaces))
+	for i, namespace := range s.namespaces {
+		names[i] = namespace.String()
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+func writeTenantForbidden(w http.ResponseWriter, message string) {
+	writeJSONError(w, http.StatusForbidden, "tenant_forbidden", message)
+}
+
+// filterConnectorNames keeps the connectors of a cluster that are in scope.
+func (s tenantScope) filterConnectorNames(cluster string, names []string) []string {
+	if s.all {
+		return names
+	}
+	metadata := connectorMetadata.all(cluster)
+	kept := make([]string, 0, len(names))
+	for _, name := range names {
+		if s.allows(name, metadata[name].Tags) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// isConnectorListPath reports whether path is the proxied GET /connectors.
+func isConnectorListPath(path string) bool {
+	segments := clusterPathSegments(path)
+	return len(segments) == 1 && segments[0] == "connectors"
+}
+
+// filterConnectorListBody filters Kafka Connect's GET /connectors answer, a list of
+// names or, with ?expand=, an object keyed by name. Other bodies are returned as is.
+func (s tenantScope) filterConnectorListBody(cluster string, body []byte) []byte {
+	var names []string
+	if err := json.Unmarshal(body, &names); err == nil {
+		if filtered, err := json.Marshal(s.filterConnectorNames(cluster, names)); err == nil {
+			return filtered
+		}
+		return body
+	}
+	var expanded map[string]json.RawMessage
+	if err := json.Unmarshal(body, &expanded); err != nil {
+		return body
+	}
+	metadata := connectorMetadata.all(cluster)
+	for name := range expanded {
+		if !s.allows(name, metadata[name].Tags) {
+			delete(expanded, name)
+		}
+	}
+	if filtered, err := json.Marshal(expanded); err == nil {
+		return filtered
+	}
+	return body
+}
+
+// filterTopology keeps the connectors in scope, their edges and the topics they touch.
+func (s tenantScope) filterTopology(cluster string, topology Topology) Topology {
+	if s.all {
+		return topology
+	}
+	metadata := connectorMetadata.all(cluster)
+	hidden := make(map[string]bool)
+	for _, node := range topology.Nodes {
+		if node.Kind == topologyNodeConnector && !s.allows(node.Name, metadata[node.Name].Tags) {
+			hidden[node.ID] = true
+		}
+	}
+
+	filtered := Topology{Nodes: []TopologyNode{}, Edges: []TopologyEdge{}}
+	linked := make(map[string]bool)
+	for _, edge := range topology.Edges {
+		if hidden[edge.From] || hidden[edge.To] {
+			continue
+		}
+		filtered.Edges = append(filtered.Edges, edge)
+		linked[edge.From], linked[edge.To] = true, true
+	}
+	for _, node := range topology.Nodes {
+		if node.Kind == topologyNodeConnector && !hidden[node.ID] || node.Kind != topologyNodeConnector && linked[node.ID] {
+			filtered.Nodes = append(filtered.Nodes, node)
+		}
+	}
+	return filtered
+}
+
+// filterSummary keeps the connectors in scope and recounts the totals. Tags must
+// already be set on the connectors.
+func (s tenantScope) filterSummary(summary MonitoringSummary) MonitoringSummary {
+	if s.all {
+		return summary
+	}
+	connectors := make([]ConnectorStatusOverview, 0, len(summary.Connectors))
+	for _, overview := range summary.Connectors {
+		if s.allows(overview.Name, overview.Tags) {
+			connectors = append(connectors, overview)
+		}
+	}
+	return summary.recounted(connectors)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestTenancy(t *testing.T, namespaces, adminGroups string) {
+	t.Helper()
+	withTestTrustedAuthHeaders(t)
+	originals := []string{tenancyNamespaces, tenancyAdminGroups}
+	original := tenancy
+	tenancyNamespaces, tenancyAdminGroups = namespaces, adminGroups
+	config, err := loadTenancy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenancy = config
+	t.Cleanup(func() {
+		tenancyNamespaces, tenancyAdminGroups, tenancy = originals[0], originals[1], original
+	})
+}
+
+func TestLoadTenancy(t *testing.T) {
+	withTestTenancy(t, "", "")
+	if tenancy != nil {
+		t.Fatal("expected tenancy to be off without namespaces")
+	}
+
+	withTestTenancy(t, "orders-team=orders-|tag:orders,payments=pay-", "platform")
+	if got := tenancy.namespaces["orders-team"]; len(got) != 2 || got[0].prefix != "orders-" || got[1].tag != "orders" {
+		t.Fatalf("unexpected namespaces %+v", got)
+	}
+
+	for _, spec := range []string{"orders-team", "orders-team=|", "a=x-,a=y-", "a=tag:"} {
+		tenancyNamespaces = spec
+		if _, err := loadTenancy(); err == nil {
+			t.Fatalf("expected %q to be refused", spec)
+		}
+	}
+
+	// Groups from headers any client can send would let everyone claim an admin group.
+	trustAuthHeaders = false
+	tenancyNamespaces = "orders-team=orders-"
+	if _, err := loadTenancy(); err == nil || !strings.Contains(err.Error(), "TRUST_AUTH_HEADERS") {
+		t.Fatalf("expected tenancy without a trusted source of groups to be refused, got %v", err)
+	}
+}
+
+func TestTenancyMiddleware(t *testing.T) {
+	withTestTenancy(t, "orders-team=orders-|tag:orders", "platform")
+	store := withTestMetadataStore(t)
+	if _, err := store.update("default", []string{"legacy-sync"}, metadataPatch{Tags: []string{"orders"}}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/api/{cluster}/graphql", ok)
+	router.HandleFunc("/api/{cluster}/summary", ok)
+	router.HandleFunc("/api/{cluster}/connector-plugins/{path:.*}", ok)
+	router.HandleFunc("/api/{cluster}/connectors", ok)
+	router.HandleFunc("/api/{cluster}/connectors/{path:.*}", ok)
+	router.Use(tenancyMiddleware)
+
+	call := func(method, path, body, groups string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Forwarded-Groups", groups)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	cases := []struct {
+		method, path, body, groups string
+		want                       int
+	}{
+		{http.MethodGet, "/api/default/connectors/orders-sink/status", "", "orders-team", http.StatusOK},
+		{http.MethodPut, "/api/default/connectors/legacy-sync/pause", "", "orders-team", http.StatusOK},
+		{http.MethodGet, "/api/default/connectors/pay-source", "", "orders-team", http.StatusNotFound},
+		{http.MethodDelete, "/api/default/connectors/pay-source", "", "orders-team", http.StatusForbidden},
+		{http.MethodPost, "/api/default/connectors", `{"name":"orders-new","config":{}}`, "orders-team", http.StatusOK},
+		{http.MethodPost, "/api/default/connectors", `{"name":"pay-new","config":{}}`, "orders-team", http.StatusForbidden},
+		{http.MethodGet, "/api/default/connectors", "", "orders-team", http.StatusOK},
+		{http.MethodGet, "/api/default/summary", "", "orders-team", http.StatusOK},
+		{http.MethodPut, "/api/default/connector-plugins/JdbcSink/config/validate", "{}", "orders-team", http.StatusOK},
+		{http.MethodPost, "/api/default/graphql", "{}", "orders-team", http.StatusForbidden},
+		{http.MethodGet, "/api/default/connectors/orders-sink", "", "", http.StatusNotFound},
+		{http.MethodPost, "/api/default/graphql", "{}", "platform", http.StatusOK},
+		{http.MethodDelete, "/api/default/connectors/pay-source", "", "platform", http.StatusOK},
+	}
+	for _, tc := range cases {
+		if rr := call(tc.method, tc.path, tc.body, tc.groups); rr.Code != tc.want {
+			t.Errorf("%s %s as %q: expected %d, got %d %s", tc.method, tc.path, tc.groups, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	trustAuthHeaders = false
+	if rr := call(http.MethodDelete, "/api/default/connectors/pay-source", "", "platform"); rr.Code != http.StatusForbidden {
+		t.Errorf("expected a forged admin group to be ignored, got %d", rr.Code)
+	}
+}
+
+func TestTenantScopeFilters(t *testing.T) {
+	store := withTestMetadataStore(t)
+	if _, err := store.update("default", []string{"legacy-sync"}, metadataPatch{Tags: []string{"orders"}}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	scope := tenantScope{namespaces: []tenantNamespace{{prefix: "orders-"}, {tag: "orders"}}}
+
+	names := scope.filterConnectorListBody("default", []byte(`["orders-sink","pay-source","legacy-sync"]`))
+	if string(names) != `["orders-sink","legacy-sync"]` {
+		t.Fatalf("unexpected list %s", names)
+	}
+	var expanded map[string]interface{}
+	json.Unmarshal(scope.filterConnectorListBody("default", []byte(`{"orders-sink":{},"pay-source":{}}`)), &expanded)
+	if len(expanded) != 1 || expanded["orders-sink"] == nil {
+		t.Fatalf("unexpected expanded list %v", expanded)
+	}
+
+	topology := scope.filterTopology("default", Topology{
+		Nodes: []TopologyNode{
+			{ID: connectorNodeID("orders-source"), Kind: topologyNodeConnector, Name: "orders-source"},
+			{ID: connectorNodeID("pay-sink"), Kind: topologyNodeConnector, Name: "pay-sink"},
+			{ID: topicNodeID("orders"), Kind: topologyNodeTopic, Name: "orders"},
+			{ID: topicNodeID("payments"), Kind: topologyNodeTopic, Name: "payments"},
+		},
+		Edges: []TopologyEdge{
+			{From: connectorNodeID("orders-source"), To: topicNodeID("orders"), Relation: topologyProduces},
+			{From: topicNodeID("orders"), To: connectorNodeID("pay-sink"), Relation: topologyConsumes},
+			{From: topicNodeID("payments"), To: connectorNodeID("pay-sink"), Relation: topologyConsumes},
+		},
+	})
+	if len(topology.Nodes) != 2 || len(topology.Edges) != 1 || topology.Nodes[1].Name != "orders" {
+		t.Fatalf("expected only the orders source and its topic, got %+v", topology)
+	}
+
+	summary := scope.filterSummary(MonitoringSummary{
+		TotalConnectors: 2,
+		Connectors: []ConnectorStatusOverview{
+			{Name: "orders-sink", State: "running", TaskStates: map[string]int{"running": 2}},
+			{Name: "pay-source", State: "failed", TaskStates: map[string]int{"failed": 1}},
+		},
+	})
+	if summary.TotalConnectors != 1 || summary.ConnectorStates["failed"] != 0 || summary.TaskStates["running"] != 2 || summary.Totals["running"] != 1 {
+		t.Fatalf("expected the summary to be recounted, got %+v", summary)
+	}
+}
+
+func TestTenancyMiddlewareAllowsSignedDeploy(t *testing.T) {
+	withTestTenancy(t, "orders-team=orders-", "platform")
+	originalSecret := deployWebhookSecret
+	t.Cleanup(func() { deployWebhookSecret = originalSecret })
+	deployWebhookSecret = "ci-secret"
+	deployReplays = &deployReplayGuard{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connectors":
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/config/validate"):
+			w.Write([]byte(`{"error_count": 0, "configs": []}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/{cluster}/deploy", deployHandler)
+	router.Use(tenancyMiddleware)
+
+	body := `{"connectors": [{"name": "pay-source", "config": {"connector.class": "Postgres"}}]}`
+	deploy := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/default/deploy?dryRun=true", strings.NewReader(body))
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := deploy(signDeploy("ci-secret", body, true, time.Now())); rr.Code != http.StatusOK {
+		t.Fatalf("expected the signed deployment to pass tenancy, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := deploy(signDeploy("wrong", body, true, time.Now())); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected an unsigned deployment to be rejected by its signature, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/default/deploy", nil)
+	if tenantScopeFor(req).all {
+		t.Fatal("an unchecked deploy request must not be a tenancy admin")
+	}
+	if !tenantScopeFor(req.WithContext(context.WithValue(req.Context(), deploySignedKey{}, true))).all {
+		t.Fatal("expected a signed deployment to be a tenancy admin")
+	}
+}
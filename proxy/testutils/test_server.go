@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sync"
+	"time"
 )
 
 // Response represents a mocked Kafka Connect response specification.
@@ -23,32 +24,55 @@ type Request struct {
 	Body   []byte
 }
 
+// ConnectServerOptions tune a mocked Kafka Connect server for load tests.
+type ConnectServerOptions struct {
+	// Latency is added to every response to simulate a slow Connect cluster.
+	Latency time.Duration
+	// DisableRecording stops the server from keeping every request, which would otherwise
+	// grow without bound under sustained load.
+	DisableRecording bool
+}
+
 // ConnectServer simulates a Kafka Connect endpoint for proxy tests.
 type ConnectServer struct {
 	server    *httptest.Server
 	mu        sync.Mutex
 	requests  []Request
 	responses map[string]Response
+	options   ConnectServerOptions
 }
 
 // NewConnectServer spins up an HTTP server that returns predefined responses per method + path.
 func NewConnectServer(responses map[string]Response) *ConnectServer {
+	return NewConnectServerWithOptions(responses, ConnectServerOptions{})
+}
+
+// NewConnectServerWithOptions is NewConnectServer with latency simulation and optional
+// request recording.
+func NewConnectServerWithOptions(responses map[string]Response, options ConnectServerOptions) *ConnectServer {
 	cs := &ConnectServer{
 		responses: responses,
+		options:   options,
 	}
 
 	cs.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		body, _ := io.ReadAll(r.Body)
 		r.Body.Close()
 
-		cs.mu.Lock()
-		cs.requests = append(cs.requests, Request{
-			Method: r.Method,
-			Path:   r.URL.Path,
-			Header: r.Header.Clone(),
-			Body:   body,
-		})
-		cs.mu.Unlock()
+		if !cs.options.DisableRecording {
+			cs.mu.Lock()
+			cs.requests = append(cs.requests, Request{
+				Method: r.Method,
+				Path:   r.URL.Path,
+				Header: r.Header.Clone(),
+				Body:   body,
+			})
+			cs.mu.Unlock()
+		}
+
+		if cs.options.Latency > 0 {
+			time.Sleep(cs.options.Latency)
+		}
 
 		key := r.Method + " " + r.URL.Path
 		resp, ok := cs.responses[key]
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 type sample struct {
@@ -81,6 +82,27 @@ func TestConnectServerRecordsRequests(t *testing.T) {
 	}
 }
 
+func TestConnectServerWithOptions(t *testing.T) {
+	server := NewConnectServerWithOptions(map[string]Response{
+		"GET /connectors": {Body: []string{"alpha"}},
+	}, ConnectServerOptions{Latency: 20 * time.Millisecond, DisableRecording: true})
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL() + "/connectors")
+	if err != nil {
+		t.Fatalf("failed to call test server: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("expected simulated latency, response took %s", elapsed)
+	}
+	if got := len(server.Requests()); got != 0 {
+		t.Fatalf("expected no recorded requests, got %d", got)
+	}
+}
+
 func TestNewConnectServerWithTB(t *testing.T) {
 	responses := map[string]MockResponse{
 		"/status": {Body: []byte("hello"), Headers: map[string]string{"X-Test": "1"}, Methods: []string{"GET"}},
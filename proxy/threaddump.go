@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// A task that reports RUNNING but moves no data is usually stuck in a call into the
+// external system or in a full producer buffer. The thread dump endpoint calls
+// dumpAllThreads on the worker running the task over Jolokia and keeps the threads
+// that belong to the task, so the stuck frame can be seen without shell access.
+
+// dumpAllThreadsRequest asks the Threading MXBean for every thread with its locked
+// monitors and synchronizers. Jolokia's access policy must allow exec on it.
+var dumpAllThreadsRequest = jolokiaExecRequest{
+	Type:      "exec",
+	MBean:     "java.lang:type=Threading",
+	Operation: "dumpAllThreads(boolean,boolean)",
+	Arguments: []interface{}{true, true},
+}
+
+type jolokiaExecRequest struct {
+	Type      string        `json:"type"`
+	MBean     string        `json:"mbean"`
+	Operation string        `json:"operation"`
+	Arguments []interface{} `json:"arguments"`
+}
+
+type jolokiaThreadsResponse struct {
+	Status int                 `json:"status"`
+	Error  string              `json:"error,omitempty"`
+	Value  []jolokiaThreadInfo `json:"value"`
+}
+
+// jolokiaThreadInfo is the part of java.lang.management.ThreadInfo the dump needs.
+type jolokiaThreadInfo struct {
+	ThreadName    string `json:"threadName"`
+	ThreadID      int64  `json:"threadId"`
+	ThreadState   string `json:"threadState"`
+	LockName      string `json:"lockName"`
+	LockOwnerName string `json:"lockOwnerName"`
+	BlockedCount  int64  `json:"blockedCount"`
+	WaitedCount   int64  `json:"waitedCount"`
+	InNative      bool   `json:"inNative"`
+	Suspended     bool   `json:"suspended"`
+	StackTrace    []struct {
+		ClassName    string `json:"className"`
+		MethodName   string `json:"methodName"`
+		FileName     string `json:"fileName"`
+		LineNumber   int    `json:"lineNumber"`
+		NativeMethod bool   `json:"nativeMethod"`
+	} `json:"stackTrace"`
+}
+
+// TaskThreadDump is returned by the task thread dump endpoint.
+type TaskThreadDump struct {
+	Connector  string       `json:"connector"`
+	Task       int          `json:"task"`
+	State      string       `json:"state"`
+	Worker     string       `json:"worker"`
+	CapturedAt time.Time    `json:"capturedAt"`
+	Threads    []TaskThread `json:"threads"`
+}
+
+// TaskThread is one thread of a task with its stack, formatted like a jstack frame.
+type TaskThread struct {
+	Name         string   `json:"name"`
+	ID           int64    `json:"id"`
+	State        string   `json:"state"`
+	LockName     string   `json:"lockName,omitempty"`
+	LockOwner    string   `json:"lockOwner,omitempty"`
+	BlockedCount int64    `json:"blockedCount"`
+	WaitedCount  int64    `json:"waitedCount"`
+	InNative     bool     `json:"inNative,omitempty"`
+	Stack        []string `json:"stack"`
+}
+
+// isTaskThread reports whether a thread belongs to a task: the worker thread running it,
+// named task-thread-{connector}-{task}, and the client threads of its producer and
+// consumer, whose names end in " | connector-producer-{connector}-{task}" and the like.
+func isTaskThread(name, connector string, task int) bool {
+	id := connector + "-" + strconv.Itoa(task)
+	if name == "task-thread-"+id {
+		return true
+	}
+	_, clientID, ok := strings.Cut(name, " | ")
+	return ok && (clientID == "connector-producer-"+id || clientID == "connector-consumer-"+id)
+}
+
+func (t jolokiaThreadInfo) taskThread() TaskThread {
+	thread := TaskThread{
+		Name:         t.ThreadName,
+		ID:           t.ThreadID,
+		State:        t.ThreadState,
+		LockName:     t.LockName,
+		LockOwner:    t.LockOwnerName,
+		BlockedCount: t.BlockedCount,
+		WaitedCount:  t.WaitedCount,
+		InNative:     t.InNative,
+		Stack:        make([]string, 0, len(t.StackTrace)),
+	}
+	for _, frame := range t.StackTrace {
+		location := "Unknown Source"
+		switch {
+		case frame.NativeMethod:
+			location = "Native Method"
+		case frame.FileName != "" && frame.LineNumber > 0:
+			location = fmt.Sprintf("%s:%d", frame.FileName, frame.LineNumber)
+		case frame.FileName != "":
+			location = frame.FileName
+		}
+		thread.Stack = append(thread.Stack, fmt.Sprintf("at %s.%s(%s)", frame.ClassName, frame.MethodName, location))
+	}
+	return thread
+}
+
+// workerJolokiaURLs orders the Jolokia URLs for a worker ID: the URLs on the worker's
+// host first, then the others, for setups where the worker advertises another name
+// than the one Jolokia is reached by.
+func workerJolokiaURLs(urls []string, worker string) []string {
+	host := worker
+	if h, _, err := net.SplitHostPort(worker); err == nil {
+		host = h
+	}
+	var matched, others []string
+	for _, raw := range urls {
+		parsed, err := url.Parse(raw)
+		if err == nil && strings.EqualFold(parsed.Hostname(), host) {
+			matched = append(matched, raw)
+		} else {
+			others = append(others, raw)
+		}
+	}
+	return append(matched, others...)
+}
+
+// dumpThreads returns every thread of the JVM behind a Jolokia URL.
+func (c *metricsCollector) dumpThreads(ctx context.Context, jolokiaURL string) ([]jolokiaThreadInfo, error) {
+	body, err := json.Marshal(dumpAllThreadsRequest)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, jolokiaURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var result jolokiaThreadsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode jolokia response: %w", err)
+	}
+	if result.Status != http.StatusOK {
+		return nil, fmt.Errorf("jolokia answered %d: %s", result.Status, result.Error)
+	}
+	return result.Value, nil
+}
+
+// errTaskThreadsNotFound is returned when no worker runs a thread of the task.
+var errTaskThreadsNotFound = errors.New("no Jolokia endpoint lists a thread of the task")
+
+// captureTaskThreads dumps the threads of the worker running a task and keeps the ones
+// of the task. Workers are tried in workerJolokiaURLs order until one runs the task.
+func captureTaskThreads(ctx context.Context, connector string, task int, worker string) ([]TaskThread, error) {
+	collector := connectorMetricsCollector
+	var errs []string
+	for _, jolokiaURL := range workerJolokiaURLs(collector.urls, worker) {
+		infos, err := collector.dumpThreads(ctx, jolokiaURL)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", jolokiaURL, err))
+			continue
+		}
+		var threads []TaskThread
+		for _, info := range infos {
+			if isTaskThread(info.ThreadName, connector, task) {
+				threads = append(threads, info.taskThread())
+			}
+		}
+		if len(threads) > 0 {
+			return threads, nil
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %s", errTaskThreadsNotFound, strings.Join(errs, "; "))
+	}
+	return nil, errTaskThreadsNotFound
+}
+
+// connectorTaskThreadsHandler returns the stack traces of the threads of one task.
+func connectorTaskThreadsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cluster, name := vars["cluster"], vars["name"]
+	if !connectorMetricsCollector.enabled() {
+		writeJSONError(w, http.StatusNotImplemented, "metrics_unavailable", errMetricsUnavailable.Error())
+		return
+	}
+	task, err := strconv.Atoi(vars["task"])
+	if err != nil || task < 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("invalid task ID %q", vars["task"]))
+		return
+	}
+
+	status, err := fetchConnectorStatus(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster), name)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", name))
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		default:
+			writeJSONError(w, http.StatusBadGateway, "connect_request_failed", err.Error())
+		}
+		return
+	}
+	dump := TaskThreadDump{Connector: name, Task: task}
+	found := false
+	for _, t := range status.Tasks {
+		if t.ID == task {
+			dump.State, dump.Worker, found = t.State, t.WorkerID, true
+		}
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "task_not_found", fmt.Sprintf("connector %q has no task %d", name, task))
+		return
+	}
+
+	dump.CapturedAt = time.Now().UTC()
+	threads, err := captureTaskThreads(r.Context(), name, task, dump.Worker)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, "thread_dump_failed", fmt.Sprintf("task %s-%d on %s: %v", name, task, dump.Worker, err))
+		return
+	}
+	dump.Threads = threads
+	writeJSON(w, http.StatusOK, dump)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+func TestIsTaskThread(t *testing.T) {
+	cases := map[string]bool{
+		"task-thread-orders-sink-0":                                             true,
+		"kafka-producer-network-thread | connector-producer-orders-sink-0":      true,
+		"kafka-coordinator-heartbeat-thread | connector-consumer-orders-sink-0": true,
+		"task-thread-orders-sink-1":                                             false,
+		"task-thread-orders-sink-0-extra":                                       false,
+		"kafka-producer-network-thread | connector-producer-orders-sink-10":     false,
+		"kafka-producer-network-thread | producer-1":                            false,
+	}
+	for name, want := range cases {
+		if got := isTaskThread(name, "orders-sink", 0); got != want {
+			t.Errorf("isTaskThread(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestWorkerJolokiaURLs(t *testing.T) {
+	urls := []string{"http://connect-1:8778/jolokia", "http://connect-2:8778/jolokia"}
+	if got := workerJolokiaURLs(urls, "connect-2:8083"); got[0] != urls[1] || len(got) != 2 {
+		t.Fatalf("expected the worker's own Jolokia first, got %v", got)
+	}
+}
+
+func newThreadDumpServer(t *testing.T, threads []interface{}) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req jolokiaExecRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Operation != "dumpAllThreads(boolean,boolean)" {
+			t.Errorf("unexpected Jolokia request %+v %v", req, err)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "value": threads})
+	}))
+}
+
+func TestConnectorTaskThreadsHandler(t *testing.T) {
+	connect := testutils.NewConnectServer(map[string]testutils.Response{
+		"GET /connectors/orders-sink/status": {Body: map[string]interface{}{
+			"name":      "orders-sink",
+			"connector": map[string]interface{}{"state": "RUNNING", "worker_id": "connect-2:8083"},
+			"tasks":     []interface{}{map[string]interface{}{"id": 0, "state": "RUNNING", "worker_id": "connect-2:8083"}},
+		}},
+	})
+	defer connect.Close()
+	idle := newThreadDumpServer(t, []interface{}{map[string]interface{}{"threadName": "main", "threadState": "WAITING"}})
+	defer idle.Close()
+	busy := newThreadDumpServer(t, []interface{}{
+		map[string]interface{}{"threadName": "main", "threadState": "WAITING"},
+		map[string]interface{}{
+			"threadName": "task-thread-orders-sink-0", "threadId": 42, "threadState": "BLOCKED",
+			"lockName": "java.lang.Object@1b2c", "lockOwnerName": "pool-3-thread-1",
+			"stackTrace": []interface{}{
+				map[string]interface{}{"className": "java.net.SocketInputStream", "methodName": "socketRead0", "nativeMethod": true},
+				map[string]interface{}{"className": "com.example.JdbcSinkTask", "methodName": "put", "fileName": "JdbcSinkTask.java", "lineNumber": 88},
+			},
+		},
+	})
+	defer busy.Close()
+
+	originalURL, originalCollector := connectURL, connectorMetricsCollector
+	t.Cleanup(func() { connectURL, connectorMetricsCollector = originalURL, originalCollector })
+	connectURL = connect.URL()
+
+	call := func(name, task string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/"+name+"/tasks/"+task+"/threads", nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": name, "task": task})
+		rr := httptest.NewRecorder()
+		connectorTaskThreadsHandler(rr, req)
+		return rr
+	}
+
+	connectorMetricsCollector = newMetricsCollector(nil, time.Minute, time.Now)
+	if rr := call("orders-sink", "0"); rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without Jolokia, got %d", rr.Code)
+	}
+
+	connectorMetricsCollector = newMetricsCollector([]string{idle.URL, busy.URL}, time.Minute, time.Now)
+	rr := call("orders-sink", "0")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	var dump TaskThreadDump
+	if err := json.Unmarshal(rr.Body.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if dump.Worker != "connect-2:8083" || len(dump.Threads) != 1 || dump.Threads[0].State != "BLOCKED" || dump.Threads[0].LockOwner != "pool-3-thread-1" {
+		t.Fatalf("expected only the blocked task thread, got %+v", dump)
+	}
+	if stack := dump.Threads[0].Stack; len(stack) != 2 || stack[0] != "at java.net.SocketInputStream.socketRead0(Native Method)" || stack[1] != "at com.example.JdbcSinkTask.put(JdbcSinkTask.java:88)" {
+		t.Fatalf("unexpected stack %v", stack)
+	}
+
+	if rr := call("orders-sink", "3"); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "task_not_found") {
+		t.Fatalf("expected 404 for a missing task, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call("orders-sink", "x"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid task ID, got %d", rr.Code)
+	}
+
+	connectorMetricsCollector = newMetricsCollector([]string{idle.URL}, time.Minute, time.Now)
+	if rr := call("orders-sink", "0"); rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "thread_dump_failed") {
+		t.Fatalf("expected 502 when no worker runs the task, got %d %s", rr.Code, rr.Body.String())
+	}
+}
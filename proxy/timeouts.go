@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route classes select which upstream timeout applies to a Kafka Connect call.
+// Validation covers every call that makes Connect validate a config against its plugin,
+// which for plugins such as JDBC connects to the external system and can take 30s+.
+const (
+	routeRead     = "read"
+	routeWrite    = "write"
+	routeValidate = "validate"
+)
+
+var (
+	upstreamTimeout         = getEnv("UPSTREAM_TIMEOUT", "10s")
+	upstreamReadTimeout     = getEnv("UPSTREAM_READ_TIMEOUT", "")
+	upstreamWriteTimeout    = getEnv("UPSTREAM_WRITE_TIMEOUT", "")
+	upstreamValidateTimeout = getEnv("UPSTREAM_VALIDATE_TIMEOUT", "60s")
+	// upstreamClusterTimeouts overrides the timeout per {cluster}, optionally per route
+	// class, e.g. "dr=30s,dr.validate=2m".
+	upstreamClusterTimeouts = getEnv("UPSTREAM_CLUSTER_TIMEOUTS", "")
+
+	upstreamTimeouts = upstreamTimeoutConfig{Default: 10 * time.Second, Classes: map[string]time.Duration{routeValidate: time.Minute}}
+)
+
+// upstreamTimeoutConfig resolves the timeout of a Connect call. The most specific
+// setting wins: cluster and class, then class, then cluster, then UPSTREAM_TIMEOUT.
+type upstreamTimeoutConfig struct {
+	Default  time.Duration
+	Classes  map[string]time.Duration
+	Clusters map[string]time.Duration // keyed by "cluster" or "cluster.class"
+}
+
+func (c upstreamTimeoutConfig) timeout(cluster, class string) time.Duration {
+	if d, ok := c.Clusters[cluster+"."+class]; ok {
+		return d
+	}
+	if d, ok := c.Classes[class]; ok {
+		return d
+	}
+	if d, ok := c.Clusters[cluster]; ok {
+		return d
+	}
+	return c.Default
+}
+
+// loadUpstreamTimeouts parses UPSTREAM_TIMEOUT, UPSTREAM_{READ,WRITE,VALIDATE}_TIMEOUT
+// and UPSTREAM_CLUSTER_TIMEOUTS.
+func loadUpstreamTimeouts() (upstreamTimeoutConfig, error) {
+	parse := func(name, value string) (time.Duration, error) {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 {
+			return 0, &configError{name: name, value: value}
+		}
+		return d, nil
+	}
+
+	config := upstreamTimeoutConfig{Classes: map[string]time.Duration{}, Clusters: map[string]time.Duration{}}
+	var err error
+	if config.Default, err = parse("UPSTREAM_TIMEOUT", upstreamTimeout); err != nil {
+		return config, err
+	}
+	for class, value := range map[string]string{routeRead: upstreamReadTimeout, routeWrite: upstreamWriteTimeout, routeValidate: upstreamValidateTimeout} {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		if config.Classes[class], err = parse("UPSTREAM_"+strings.ToUpper(class)+"_TIMEOUT", value); err != nil {
+			return config, err
+		}
+	}
+
+	pairs, err := parsePairs("UPSTREAM_CLUSTER_TIMEOUTS", upstreamClusterTimeouts)
+	if err != nil {
+		return config, err
+	}
+	for key, value := range pairs {
+		if config.Clusters[key], err = parse("UPSTREAM_CLUSTER_TIMEOUTS", value); err != nil {
+			return config, &configError{name: "UPSTREAM_CLUSTER_TIMEOUTS", value: key + "=" + value}
+		}
+	}
+	return config, nil
+}
+
+// upstreamRouteClass classifies a console request by the Connect call it makes.
+func upstreamRouteClass(method, path string) string {
+	segments := clusterPathSegments(path)
+	switch {
+	case method == http.MethodGet || method == http.MethodHead:
+		return routeRead
+	case len(segments) > 0 && segments[len(segments)-1] == "validate":
+		return routeValidate
+	case len(segments) >= 1 && segments[0] == "connectors":
+		// Creating a connector or replacing its config validates it first.
+		if method == http.MethodPost && (len(segments) == 1 || segments[1] == "") {
+			return routeValidate
+		}
+		if method == http.MethodPut && len(segments) == 3 && segments[2] == "config" {
+			return routeValidate
+		}
+	}
+	return routeWrite
+}
+
+// connectClients holds one client per cluster and route class, keyed by
+// "cluster\x00class".
+var connectClients sync.Map
+
+// connectClientFor returns a Connect client whose calls time out after the configured
+// timeout for the cluster and route class. An empty cluster skips the per-cluster
+// overrides. Clients are reused while their timeout and transport stay the same.
+func connectClientFor(cluster, class string) *http.Client {
+	timeout := upstreamTimeouts.timeout(cluster, class)
+	key := cluster + "\x00" + class
+	if cached, ok := connectClients.Load(key); ok {
+		client := cached.(*http.Client)
+		if t := client.Transport.(*deadlineTransport); t.timeout == timeout && t.base == connectTransport {
+			return client
+		}
+	}
+	client := &http.Client{Transport: &deadlineTransport{
+		base:    connectTransport,
+		cluster: cluster,
+		class:   class,
+		timeout: timeout,
+	}}
+	connectClients.Store(key, client)
+	return client
+}
+
+// upstreamTimeoutError reports a Connect call that ran out of time.
+type upstreamTimeoutError struct {
+	cluster string
+	class   string
+	timeout time.Duration
+}
+
+func (e *upstreamTimeoutError) Error() string {
+	return fmt.Sprintf("kafka connect did not respond within %s", e.timeout)
+}
+
+// deadlineTransport bounds each call, including retries, and turns a missed deadline
+// into an upstreamTimeoutError. The deadline also covers reading the response body.
+type deadlineTransport struct {
+	base    http.RoundTripper
+	cluster string
+	class   string
+	timeout time.Duration
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && req.Context().Err() == nil {
+			return nil, &upstreamTimeoutError{cluster: t.cluster, class: t.class, timeout: t.timeout}
+		}
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the call's context once the body has been consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// writeUpstreamTimeout answers 504 with the timeout that was exceeded.
+func writeUpstreamTimeout(w http.ResponseWriter, err *upstreamTimeoutError) {
+	writeJSON(w, http.StatusGatewayTimeout, map[string]interface{}{
+		"error":       "upstream_timeout",
+		"message":     err.Error(),
+		"remediation": errorRemediations["upstream_timeout"],
+		"cluster":     err.cluster,
+		"routeClass":  err.class,
+		"timeoutMs":   err.timeout.Milliseconds(),
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func withTestUpstreamTimeouts(t *testing.T, config upstreamTimeoutConfig) {
+	t.Helper()
+	original := upstreamTimeouts
+	upstreamTimeouts = config
+	t.Cleanup(func() { upstreamTimeouts = original })
+}
+
+func TestUpstreamTimeoutResolution(t *testing.T) {
+	restore := func(vars ...*string) {
+		originals := make([]string, len(vars))
+		for i, v := range vars {
+			originals[i] = *v
+		}
+		t.Cleanup(func() {
+			for i, v := range vars {
+				*v = originals[i]
+			}
+		})
+	}
+	restore(&upstreamTimeout, &upstreamReadTimeout, &upstreamWriteTimeout, &upstreamValidateTimeout, &upstreamClusterTimeouts)
+
+	upstreamTimeout, upstreamReadTimeout, upstreamWriteTimeout, upstreamValidateTimeout = "10s", "", "20s", "1m"
+	upstreamClusterTimeouts = "dr=30s,dr.validate=2m"
+	config, err := loadUpstreamTimeouts()
+	if err != nil {
+		t.Fatalf("loadUpstreamTimeouts: %v", err)
+	}
+	for _, tc := range []struct {
+		cluster, class string
+		want           time.Duration
+	}{
+		{"default", routeRead, 10 * time.Second},
+		{"default", routeWrite, 20 * time.Second},
+		{"default", routeValidate, time.Minute},
+		{"dr", routeRead, 30 * time.Second},
+		{"dr", routeWrite, 20 * time.Second},
+		{"dr", routeValidate, 2 * time.Minute},
+		{"", routeRead, 10 * time.Second},
+	} {
+		if got := config.timeout(tc.cluster, tc.class); got != tc.want {
+			t.Errorf("timeout(%q, %q) = %s, want %s", tc.cluster, tc.class, got, tc.want)
+		}
+	}
+
+	for name, value := range map[*string]string{&upstreamTimeout: "soon", &upstreamReadTimeout: "0s", &upstreamClusterTimeouts: "dr=fast"} {
+		original := *name
+		*name = value
+		if _, err := loadUpstreamTimeouts(); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+		*name = original
+	}
+}
+
+func TestUpstreamRouteClass(t *testing.T) {
+	for _, tc := range []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/api/default/connectors/orders/config", routeRead},
+		{http.MethodPost, "/api/default/connectors", routeValidate},
+		{http.MethodPut, "/api/default/connectors/orders/config", routeValidate},
+		{http.MethodPut, "/api/default/connector-plugins/JdbcSourceConnector/config/validate", routeValidate},
+		{http.MethodPut, "/api/default/connectors/orders/pause", routeWrite},
+		{http.MethodDelete, "/api/default/connectors/orders", routeWrite},
+	} {
+		if got := upstreamRouteClass(tc.method, tc.path); got != tc.want {
+			t.Errorf("upstreamRouteClass(%s %s) = %s, want %s", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestProxyHandlerUpstreamTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/validate") {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"error_count":0,"configs":[]}`)
+	}))
+	defer server.Close()
+	defer close(release)
+	defer withTestConnectURL(t, server)()
+	withTestUpstreamTimeouts(t, upstreamTimeoutConfig{
+		Default: time.Second,
+		Classes: map[string]time.Duration{routeValidate: 50 * time.Millisecond},
+	})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/default/"+path, strings.NewReader(`{"connector.class":"JdbcSourceConnector"}`))
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "path": path})
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPut, "connector-plugins/JdbcSourceConnector/config/validate")
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body["error"] != "upstream_timeout" || body["routeClass"] != routeValidate || body["cluster"] != "default" || body["timeoutMs"] != float64(50) {
+		t.Fatalf("unexpected timeout response %v", body)
+	}
+
+	if rr := do(http.MethodGet, "connector-plugins"); rr.Code != http.StatusOK {
+		t.Fatalf("expected reads to use their own timeout, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	_ "time/tzdata" // the runtime image ships without a zoneinfo database
+)
+
+// timezoneHeader tells clients which IANA zone the request was evaluated in. Timestamps
+// in responses are always RFC 3339 in UTC; clients format them for display in this zone.
+const timezoneHeader = "X-Timezone"
+
+// timeRange is a half-open [Since, Until) interval in UTC. A zero bound is unbounded.
+type timeRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+func (r timeRange) contains(t time.Time) bool {
+	if !r.Since.IsZero() && t.Before(r.Since) {
+		return false
+	}
+	if !r.Until.IsZero() && !t.Before(r.Until) {
+		return false
+	}
+	return true
+}
+
+// localTimeLayouts are accepted for since/until values without a UTC offset; they are
+// interpreted in the request's tz.
+var localTimeLayouts = []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04", "2006-01-02"}
+
+// parseTimestamp parses an RFC 3339 timestamp, or a local date/time interpreted in loc,
+// and returns it in UTC.
+func parseTimestamp(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q: use RFC 3339, e.g. 2024-05-01T12:00:00Z", value)
+}
+
+// requestLocation returns the zone named by the tz query parameter, defaulting to UTC.
+func requestLocation(query url.Values) (*time.Location, error) {
+	name := strings.TrimSpace(query.Get("tz"))
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+// parseTimeRange reads the since, until and tz query parameters.
+func parseTimeRange(query url.Values) (timeRange, *time.Location, error) {
+	loc, err := requestLocation(query)
+	if err != nil {
+		return timeRange{}, nil, err
+	}
+
+	var r timeRange
+	if value := strings.TrimSpace(query.Get("since")); value != "" {
+		if r.Since, err = parseTimestamp(value, loc); err != nil {
+			return timeRange{}, nil, fmt.Errorf("since: %w", err)
+		}
+	}
+	if value := strings.TrimSpace(query.Get("until")); value != "" {
+		if r.Until, err = parseTimestamp(value, loc); err != nil {
+			return timeRange{}, nil, fmt.Errorf("until: %w", err)
+		}
+	}
+	if !r.Since.IsZero() && !r.Until.IsZero() && !r.Since.Before(r.Until) {
+		return timeRange{}, nil, fmt.Errorf("since must be before until")
+	}
+	return r, loc, nil
+}
+
+// setTimezoneHeader records the zone used to interpret the request.
+func setTimezoneHeader(w http.ResponseWriter, loc *time.Location) {
+	w.Header().Set(timezoneHeader, loc.String())
+}
+
+// nonZeroTime returns nil for the zero time so open bounds are omitted from JSON.
+func nonZeroTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		query string
+		since time.Time
+		until time.Time
+		zone  string
+	}{
+		{query: "", zone: "UTC"},
+		{
+			query: "since=2024-05-01T12:00:00%2B02:00&until=2024-05-02T00:00:00Z",
+			since: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			until: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+			zone:  "UTC",
+		},
+		{
+			query: "since=2024-05-01T09:30&tz=America/New_York",
+			since: time.Date(2024, 5, 1, 13, 30, 0, 0, time.UTC),
+			zone:  "America/New_York",
+		},
+		{
+			query: "until=2024-01-15&tz=Europe/Berlin",
+			until: time.Date(2024, 1, 14, 23, 0, 0, 0, time.UTC),
+			zone:  "Europe/Berlin",
+		},
+	}
+
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		r, loc, err := parseTimeRange(query)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.query, err)
+		}
+		if !r.Since.Equal(tt.since) || !r.Until.Equal(tt.until) {
+			t.Errorf("%q: got [%v, %v), want [%v, %v)", tt.query, r.Since, r.Until, tt.since, tt.until)
+		}
+		if r.Since.Location() != time.UTC || r.Until.Location() != time.UTC {
+			t.Errorf("%q: expected bounds normalized to UTC", tt.query)
+		}
+		if loc.String() != tt.zone {
+			t.Errorf("%q: expected zone %s, got %s", tt.query, tt.zone, loc)
+		}
+	}
+}
+
+func TestParseTimeRangeRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"since=yesterday",
+		"until=1714560000",
+		"tz=Mars/Olympus_Mons",
+		"since=2024-05-02T00:00:00Z&until=2024-05-01T00:00:00Z",
+	} {
+		query, _ := url.ParseQuery(raw)
+		if _, _, err := parseTimeRange(query); err == nil {
+			t.Errorf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestTimeRangeContainsIsHalfOpen(t *testing.T) {
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	r := timeRange{Since: since, Until: since.Add(time.Hour)}
+	if !r.contains(since) || r.contains(since.Add(time.Hour)) || r.contains(since.Add(-time.Nanosecond)) {
+		t.Fatalf("expected [since, until) semantics")
+	}
+	if !(timeRange{}).contains(since) {
+		t.Fatalf("expected empty range to match everything")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build tools
+
+// This file keeps the gqlgen generator, run by go generate, in go.mod.
+package main
+
+import _ "github.com/99designs/gqlgen"
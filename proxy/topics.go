@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+const (
+	defaultTopicPreviewLimit = 20
+	maxTopicPreviewLimit     = 500
+
+	// topicPreviewTimeout bounds a preview; whatever was read by then is returned.
+	topicPreviewTimeout = 5 * time.Second
+
+	formatAuto     = "auto"
+	formatJSON     = "json"
+	formatAvro     = "avro"
+	formatProtobuf = "protobuf"
+	formatString   = "string"
+	formatBase64   = "base64"
+)
+
+var (
+	// kafkaBootstrapServers enables the topic browser when set (comma-separated host:port).
+	kafkaBootstrapServers = getEnv("KAFKA_BOOTSTRAP_SERVERS", "")
+
+	topicMessageReader topicReader = newKafkaTopicReader(splitList(kafkaBootstrapServers))
+)
+
+// topicQuery selects the records to preview. Partition -1 means every partition.
+type topicQuery struct {
+	Topic     string
+	Partition int32
+	Offset    string // "earliest", "latest" or a numeric offset
+	Limit     int
+}
+
+// topicReader reads a bounded slice of records from a topic.
+type topicReader interface {
+	enabled() bool
+	read(ctx context.Context, query topicQuery) ([]*kgo.Record, error)
+}
+
+// kafkaTopicReader reads records with a short-lived consumer that never joins a
+// consumer group, so previews do not commit offsets or disturb connector consumers.
+type kafkaTopicReader struct {
+	brokers []string
+}
+
+func newKafkaTopicReader(brokers []string) *kafkaTopicReader {
+	return &kafkaTopicReader{brokers: brokers}
+}
+
+func (r *kafkaTopicReader) enabled() bool {
+	return len(r.brokers) > 0
+}
+
+func (r *kafkaTopicReader) partitions(ctx context.Context, client *kgo.Client, topic string) ([]int32, error) {
+	req := kmsg.NewPtrMetadataRequest()
+	reqTopic := kmsg.NewMetadataRequestTopic()
+	reqTopic.Topic = kmsg.StringPtr(topic)
+	req.Topics = append(req.Topics, reqTopic)
+
+	resp, err := req.RequestWith(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("fetch metadata for %s: %w", topic, err)
+	}
+	if len(resp.Topics) == 0 {
+		return nil, kerr.UnknownTopicOrPartition
+	}
+	if err := kerr.ErrorForCode(resp.Topics[0].ErrorCode); err != nil {
+		return nil, err
+	}
+
+	partitions := make([]int32, 0, len(resp.Topics[0].Partitions))
+	for _, p := range resp.Topics[0].Partitions {
+		partitions = append(partitions, p.Partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions, nil
+}
+
+// topicNames lists the topics of the Kafka cluster.
+func (r *kafkaTopicReader) topicNames(ctx context.Context) (map[string]bool, error) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(r.brokers...))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	// A metadata request without topics asks for all of them.
+	resp, err := kmsg.NewPtrMetadataRequest().RequestWith(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("fetch topic metadata: %w", err)
+	}
+	names := make(map[string]bool, len(resp.Topics))
+	for _, topic := range resp.Topics {
+		if topic.Topic != nil && topic.ErrorCode == 0 {
+			names[*topic.Topic] = true
+		}
+	}
+	return names, nil
+}
+
+func (r *kafkaTopicReader) read(ctx context.Context, query topicQuery) ([]*kgo.Record, error) {
+	metaClient, err := kgo.NewClient(kgo.SeedBrokers(r.brokers...))
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := r.partitions(ctx, metaClient, query.Topic)
+	metaClient.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Partition >= 0 {
+		found := false
+		for _, p := range partitions {
+			found = found || p == query.Partition
+		}
+		if !found {
+			return nil, kerr.UnknownTopicOrPartition
+		}
+		partitions = []int32{query.Partition}
+	}
+
+	start := kgo.NewOffset().AtEnd().Relative(-int64(query.Limit))
+	switch query.Offset {
+	case "earliest":
+		start = kgo.NewOffset().AtStart()
+	case "latest", "":
+	default:
+		offset, _ := strconv.ParseInt(query.Offset, 10, 64)
+		start = kgo.NewOffset().At(offset)
+	}
+
+	assignments := make(map[int32]kgo.Offset, len(partitions))
+	for _, p := range partitions {
+		assignments[p] = start
+	}
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(r.brokers...),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{query.Topic: assignments}),
+		kgo.FetchMaxWait(500*time.Millisecond),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	var records []*kgo.Record
+	counts := make(map[int32]int, len(partitions))
+	done := make(map[int32]bool, len(partitions))
+	for len(done) < len(partitions) {
+		fetches := client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		var fetchErr error
+		fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+			if p.Err != nil {
+				fetchErr = p.Err
+				return
+			}
+			for _, record := range p.Records {
+				if counts[p.Partition] < query.Limit {
+					records = append(records, record)
+					counts[p.Partition]++
+				}
+			}
+			caughtUp := len(p.Records) > 0 && p.Records[len(p.Records)-1].Offset+1 >= p.HighWatermark
+			if counts[p.Partition] >= query.Limit || caughtUp {
+				done[p.Partition] = true
+			}
+		})
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+	}
+	return records, nil
+}
+
+// TopicMessage is a decoded record returned by the topic browser.
+type TopicMessage struct {
+	Partition   int32             `json:"partition"`
+	Offset      int64             `json:"offset"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Key         interface{}       `json:"key"`
+	Value       interface{}       `json:"value"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ValueFormat string            `json:"valueFormat"`
+	SchemaID    int               `json:"schemaId,omitempty"`
+	DecodeError string            `json:"decodeError,omitempty"`
+}
+
+// TopicMessagesResponse is returned by GET /api/{cluster}/topics/{topic}/messages.
+type TopicMessagesResponse struct {
+	Topic    string         `json:"topic"`
+	Format   string         `json:"format"`
+	Messages []TopicMessage `json:"messages"`
+}
+
+// decodePayload turns raw record bytes into a JSON-friendly value. It returns the
+// format actually used and the schema ID for schema-registry framed payloads.
+func decodePayload(ctx context.Context, data []byte, format string) (value interface{}, used string, schemaID int, err error) {
+	if data == nil {
+		return nil, format, 0, nil
+	}
+
+	switch format {
+	case formatString:
+		return string(data), formatString, 0, nil
+	case formatBase64:
+		return base64.StdEncoding.EncodeToString(data), formatBase64, 0, nil
+	case formatAvro, formatProtobuf:
+		return schemaRegistry.decode(ctx, data, format)
+	case formatJSON:
+		if id, payload, ok := splitSchemaFrame(data); ok && json.Valid(payload) {
+			data, schemaID = payload, id
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, formatJSON, schemaID, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return value, formatJSON, schemaID, nil
+	}
+
+	// auto: schema-registry framing first, then plain JSON, then text, then base64.
+	if _, _, ok := splitSchemaFrame(data); ok && schemaRegistry.enabled() {
+		if value, used, schemaID, err := schemaRegistry.decode(ctx, data, ""); err == nil {
+			return value, used, schemaID, nil
+		}
+	}
+	if json.Unmarshal(data, &value) == nil {
+		return value, formatJSON, 0, nil
+	}
+	if utf8.Valid(data) {
+		return string(data), formatString, 0, nil
+	}
+	return base64.StdEncoding.EncodeToString(data), formatBase64, 0, nil
+}
+
+func parseTopicQuery(r *http.Request) (topicQuery, string, error) {
+	params := r.URL.Query()
+	query := topicQuery{
+		Topic:     mux.Vars(r)["topic"],
+		Partition: -1,
+		Offset:    "latest",
+		Limit:     defaultTopicPreviewLimit,
+	}
+
+	if value := params.Get("partition"); value != "" {
+		partition, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || partition < 0 {
+			return query, "", errors.New("partition must be a non-negative integer")
+		}
+		query.Partition = int32(partition)
+	}
+	if value := params.Get("offset"); value != "" {
+		if value != "earliest" && value != "latest" {
+			if offset, err := strconv.ParseInt(value, 10, 64); err != nil || offset < 0 {
+				return query, "", errors.New(`offset must be "earliest", "latest" or a non-negative integer`)
+			}
+		}
+		query.Offset = value
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxTopicPreviewLimit {
+			return query, "", fmt.Errorf("limit must be between 1 and %d", maxTopicPreviewLimit)
+		}
+		query.Limit = limit
+	}
+
+	format := strings.ToLower(params.Get("format"))
+	switch format {
+	case "":
+		format = formatAuto
+	case formatAuto, formatJSON, formatAvro, formatProtobuf, formatString, formatBase64:
+	default:
+		return query, "", fmt.Errorf("unsupported format %q", format)
+	}
+	return query, format, nil
+}
+
+// topicMessagesHandler previews records from a topic so users can inspect what a
+// source connector produces or a sink connector consumes.
+func topicMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	reader := topicMessageReader
+	if !reader.enabled() {
+		writeJSONError(w, http.StatusNotImplemented, "topic_browser_unavailable", "set KAFKA_BOOTSTRAP_SERVERS to enable the topic browser")
+		return
+	}
+
+	query, format, err := parseTopicQuery(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+
+	readCtx, cancel := context.WithTimeout(r.Context(), topicPreviewTimeout)
+	defer cancel()
+
+	records, err := reader.read(readCtx, query)
+	if err != nil {
+		if errors.Is(err, kerr.UnknownTopicOrPartition) {
+			writeJSONError(w, http.StatusNotFound, "topic_not_found", err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "topic_read_failed", err.Error())
+		return
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].Timestamp.Equal(records[j].Timestamp) {
+			return records[i].Timestamp.Before(records[j].Timestamp)
+		}
+		return records[i].Partition < records[j].Partition
+	})
+	if len(records) > query.Limit {
+		if query.Offset == "latest" {
+			records = records[len(records)-query.Limit:]
+		} else {
+			records = records[:query.Limit]
+		}
+	}
+
+	ctx := r.Context()
+	rules := currentRedactionRules()
+	response := TopicMessagesResponse{Topic: query.Topic, Format: format, Messages: make([]TopicMessage, 0, len(records))}
+	for _, record := range records {
+		message := TopicMessage{
+			Partition: record.Partition,
+			Offset:    record.Offset,
+			Timestamp: record.Timestamp.UTC(),
+		}
+		message.Key, _, _, _ = decodePayload(ctx, record.Key, formatAuto)
+
+		value, used, schemaID, err := decodePayload(ctx, record.Value, format)
+		if err != nil {
+			message.DecodeError = err.Error()
+			value, used = base64.StdEncoding.EncodeToString(record.Value), formatBase64
+		}
+		message.Value = rules.redact(value)
+		message.ValueFormat = used
+		message.SchemaID = schemaID
+
+		if len(record.Headers) > 0 {
+			message.Headers = make(map[string]string, len(record.Headers))
+			for _, h := range record.Headers {
+				message.Headers[h.Key] = string(h.Value)
+			}
+		}
+		response.Messages = append(response.Messages, message)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
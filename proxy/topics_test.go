@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+type fakeTopicReader struct {
+	records []*kgo.Record
+	err     error
+	query   topicQuery
+}
+
+func (f *fakeTopicReader) enabled() bool { return true }
+
+func (f *fakeTopicReader) read(ctx context.Context, query topicQuery) ([]*kgo.Record, error) {
+	f.query = query
+	return f.records, f.err
+}
+
+func withTopicReader(t *testing.T, reader topicReader) {
+	t.Helper()
+	original := topicMessageReader
+	topicMessageReader = reader
+	t.Cleanup(func() { topicMessageReader = original })
+}
+
+func getTopicMessages(query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/default/topics/orders/messages"+query, nil)
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "topic": "orders"})
+	rr := httptest.NewRecorder()
+	topicMessagesHandler(rr, req)
+	return rr
+}
+
+func TestTopicMessagesHandlerDecodesRecords(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	reader := &fakeTopicReader{records: []*kgo.Record{
+		{Partition: 1, Offset: 7, Timestamp: base.Add(time.Second), Key: []byte("k2"), Value: []byte("plain text")},
+		{Partition: 0, Offset: 3, Timestamp: base, Key: []byte("k1"), Value: []byte(`{"id":1,"password":"hunter2"}`),
+			Headers: []kgo.RecordHeader{{Key: "source", Value: []byte("db")}}},
+	}}
+	withTopicReader(t, reader)
+
+	rr := getTopicMessages("?partition=0&offset=earliest&limit=5")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if reader.query.Partition != 0 || reader.query.Offset != "earliest" || reader.query.Limit != 5 {
+		t.Fatalf("unexpected query: %+v", reader.query)
+	}
+
+	var resp TopicMessagesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Messages) != 2 || resp.Messages[0].Offset != 3 {
+		t.Fatalf("expected messages ordered by timestamp, got %+v", resp.Messages)
+	}
+	first := resp.Messages[0]
+	value, ok := first.Value.(map[string]interface{})
+	if !ok || first.ValueFormat != formatJSON || value["password"] != defaultRedactionPlaceholder {
+		t.Fatalf("expected redacted JSON value, got %+v", first)
+	}
+	if first.Key != "k1" || first.Headers["source"] != "db" {
+		t.Fatalf("unexpected key/headers: %+v", first)
+	}
+	if resp.Messages[1].Value != "plain text" || resp.Messages[1].ValueFormat != formatString {
+		t.Fatalf("expected text fallback, got %+v", resp.Messages[1])
+	}
+}
+
+func TestTopicMessagesHandlerErrors(t *testing.T) {
+	withTopicReader(t, newKafkaTopicReader(nil))
+	if rr := getTopicMessages(""); rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 when unconfigured, got %d", rr.Code)
+	}
+
+	reader := &fakeTopicReader{err: kerr.UnknownTopicOrPartition}
+	withTopicReader(t, reader)
+	if rr := getTopicMessages(""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown topic, got %d", rr.Code)
+	}
+
+	for _, query := range []string{"?partition=-1", "?offset=soon", "?limit=0", "?limit=501", "?format=xml"} {
+		if rr := getTopicMessages(query); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
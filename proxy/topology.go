@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// topologyTopicsConcurrency bounds the /connectors/{name}/topics requests made per
+// topology build or search.
+const topologyTopicsConcurrency = 8
+
+// Topology node kinds and edge relations.
+const (
+	topologyNodeConnector = "connector"
+	topologyNodeTopic     = "topic"
+	topologyNodePattern   = "pattern"
+
+	topologyProduces   = "produces"
+	topologyConsumes   = "consumes"
+	topologyDeadLetter = "dead_letter"
+)
+
+// TopologyNode is a connector, a topic, or a topic pattern that matched no known topic.
+type TopologyNode struct {
+	ID             string `json:"id"`
+	Kind           string `json:"kind"`
+	Name           string `json:"name"`
+	ConnectorType  string `json:"connectorType,omitempty"`
+	ConnectorClass string `json:"connectorClass,omitempty"`
+	State          string `json:"state,omitempty"`
+}
+
+// TopologyEdge links a source connector to the topics it produces, a topic to the sink
+// connectors consuming it, or a sink to its dead letter queue. Inferred edges come from
+// the connector config only and were not confirmed by Connect's active topics tracking.
+type TopologyEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+	Inferred bool   `json:"inferred"`
+}
+
+// Topology is returned by GET /api/{cluster}/topology.
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+func connectorNodeID(name string) string { return "connector:" + name }
+func topicNodeID(name string) string     { return "topic:" + name }
+func patternNodeID(expr string) string   { return "pattern:" + expr }
+
+// topicPattern is a topic selection that is not a literal name: topics.regex on sinks
+// or a topic.prefix on sources.
+type topicPattern struct {
+	label string
+	re    *regexp.Regexp
+}
+
+// connectorTopics is what the config of a connector says about the topics it uses.
+type connectorTopics struct {
+	topics     []string
+	patterns   []topicPattern
+	deadLetter string
+}
+
+// configuredTopics reads topic names from the config of a connector of the given type.
+// Sinks use topics, topics.regex and the DLQ; sources use kafka.topic, topic, *.topic
+// keys and topic.prefix.
+func configuredTopics(connectorType string, config map[string]string) connectorTopics {
+	var result connectorTopics
+	if connectorType == "sink" {
+		result.topics = splitList(config["topics"])
+		if expr := strings.TrimSpace(config["topics.regex"]); expr != "" {
+			if re, err := regexp.Compile("^(?:" + expr + ")$"); err == nil {
+				result.patterns = append(result.patterns, topicPattern{label: expr, re: re})
+			}
+		}
+		result.deadLetter = strings.TrimSpace(config["errors.deadletterqueue.topic.name"])
+		return result
+	}
+
+	for key, value := range config {
+		if key == "kafka.topic" || key == "topic" || strings.HasSuffix(key, ".topic") {
+			result.topics = append(result.topics, splitList(value)...)
+		}
+	}
+	if prefix := strings.TrimSpace(config["topic.prefix"]); prefix != "" {
+		result.patterns = append(result.patterns, topicPattern{label: prefix + "*", re: regexp.MustCompile("^" + regexp.QuoteMeta(prefix))})
+	}
+	sort.Strings(result.topics)
+	return result
+}
+
+// fetchActiveTopics returns the topics Connect has seen a connector use. Clusters older
+// than 2.5 or with topic tracking disabled return an error, which callers ignore.
+func fetchActiveTopics(ctx context.Context, client *http.Client, baseURL, name string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, joinURL(baseURL, "connectors", url.PathEscape(name), "topics"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching topics of %s: %d", name, resp.StatusCode)
+	}
+
+	var payload map[string]struct {
+		Topics []string `json:"topics"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode topics of %s: %w", name, err)
+	}
+	return payload[name].Topics, nil
+}
+
+// fetchActiveTopicsOf asks Connect for the active topics of every connector, with at
+// most topologyTopicsConcurrency requests in flight. Connectors whose topics cannot be
+// fetched are left out.
+func fetchActiveTopicsOf(ctx context.Context, client *http.Client, baseURL string, connectors map[string]expandedConnector) map[string][]string {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		active = make(map[string][]string, len(connectors))
+		slots  = make(chan struct{}, topologyTopicsConcurrency)
+	)
+	for name := range connectors {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			topics, err := fetchActiveTopics(ctx, client, baseURL, name)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			active[name] = topics
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return active
+}
+
+// topologyBuilder collects nodes and de-duplicated edges.
+type topologyBuilder struct {
+	nodes map[string]TopologyNode
+	edges map[string]TopologyEdge
+}
+
+func (b *topologyBuilder) topic(name string) string {
+	id := topicNodeID(name)
+	if _, ok := b.nodes[id]; !ok {
+		b.nodes[id] = TopologyNode{ID: id, Kind: topologyNodeTopic, Name: name}
+	}
+	return id
+}
+
+// edge adds an edge; a confirmed edge replaces an inferred one.
+func (b *topologyBuilder) edge(from, to, relation string, inferred bool) {
+	key := from + "|" + to + "|" + relation
+	if existing, ok := b.edges[key]; ok && !existing.Inferred {
+		return
+	}
+	b.edges[key] = TopologyEdge{From: from, To: to, Relation: relation, Inferred: inferred}
+}
+
+// buildTopology links connectors through topics. Patterns are matched against every
+// topic known from configs and active topics; a pattern matching none is kept as its
+// own node so the connector is not shown as disconnected.
+func buildTopology(connectors map[string]expandedConnector, active map[string][]string) Topology {
+	b := &topologyBuilder{nodes: make(map[string]TopologyNode), edges: make(map[string]TopologyEdge)}
+
+	configs := make(map[string]connectorTopics, len(connectors))
+	for name, connector := range connectors {
+		connectorType := connector.Status.Type
+		if connectorType == "" {
+			connectorType = connector.Info.Type
+		}
+		b.nodes[connectorNodeID(name)] = TopologyNode{
+			ID:             connectorNodeID(name),
+			Kind:           topologyNodeConnector,
+			Name:           name,
+			ConnectorType:  connectorType,
+			ConnectorClass: connector.Info.Config["connector.class"],
+			State:          normalizeState(connector.Status.Connector.State),
+		}
+		configs[name] = configuredTopics(connectorType, connector.Info.Config)
+	}
+
+	link := func(name, topicID string, inferred bool) {
+		if b.nodes[connectorNodeID(name)].ConnectorType == "sink" {
+			b.edge(topicID, connectorNodeID(name), topologyConsumes, inferred)
+		} else {
+			b.edge(connectorNodeID(name), topicID, topologyProduces, inferred)
+		}
+	}
+
+	for name, topics := range active {
+		for _, topic := range topics {
+			link(name, b.topic(topic), false)
+		}
+	}
+	for name, cfg := range configs {
+		for _, topic := range cfg.topics {
+			link(name, b.topic(topic), true)
+		}
+		if cfg.deadLetter != "" {
+			b.edge(connectorNodeID(name), b.topic(cfg.deadLetter), topologyDeadLetter, false)
+		}
+	}
+
+	// Match patterns only after every literal topic is known.
+	var known []string
+	for _, node := range b.nodes {
+		if node.Kind == topologyNodeTopic {
+			known = append(known, node.Name)
+		}
+	}
+	for name, cfg := range configs {
+		for _, pattern := range cfg.patterns {
+			matched := false
+			for _, topic := range known {
+				if pattern.re.MatchString(topic) {
+					link(name, topicNodeID(topic), true)
+					matched = true
+				}
+			}
+			if !matched {
+				id := patternNodeID(pattern.label)
+				b.nodes[id] = TopologyNode{ID: id, Kind: topologyNodePattern, Name: pattern.label}
+				link(name, id, true)
+			}
+		}
+	}
+
+	topology := Topology{Nodes: make([]TopologyNode, 0, len(b.nodes)), Edges: make([]TopologyEdge, 0, len(b.edges))}
+	for _, node := range b.nodes {
+		topology.Nodes = append(topology.Nodes, node)
+	}
+	for _, edge := range b.edges {
+		topology.Edges = append(topology.Edges, edge)
+	}
+	sort.Slice(topology.Nodes, func(i, j int) bool { return topology.Nodes[i].ID < topology.Nodes[j].ID })
+	sort.Slice(topology.Edges, func(i, j int) bool {
+		a, c := topology.Edges[i], topology.Edges[j]
+		if a.From != c.From {
+			return a.From < c.From
+		}
+		if a.To != c.To {
+			return a.To < c.To
+		}
+		return a.Relation < c.Relation
+	})
+	return topology
+}
+
+// fetchTopology loads every connector with its config and active topics.
+func fetchTopology(ctx context.Context, client *http.Client, baseURL string) (Topology, error) {
+	connectors, err := fetchExpandedConnectorStatuses(ctx, client, baseURL)
+	if err != nil {
+		return Topology{}, err
+	}
+	return buildTopology(connectors, fetchActiveTopicsOf(ctx, client, baseURL, connectors)), nil
+}
+
+// topologyHandler returns the source → topic → sink graph of a cluster.
+func topologyHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)["cluster"]
+	topology, err := fetchTopology(r.Context(), connectClientFor(cluster, routeRead), connectURLFor(cluster))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, tenantScopeFor(r).filterTopology(cluster, topology))
+}
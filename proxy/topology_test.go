@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func testExpandedConnector(connectorType, state string, config map[string]string) expandedConnector {
+	var c expandedConnector
+	c.Info.Config = config
+	c.Info.Type = connectorType
+	c.Status.Type = connectorType
+	c.Status.Connector.State = state
+	return c
+}
+
+func findEdge(edges []TopologyEdge, from, to, relation string) (TopologyEdge, bool) {
+	for _, edge := range edges {
+		if edge.From == from && edge.To == to && edge.Relation == relation {
+			return edge, true
+		}
+	}
+	return TopologyEdge{}, false
+}
+
+func TestBuildTopology(t *testing.T) {
+	connectors := map[string]expandedConnector{
+		"orders-cdc": testExpandedConnector("source", "RUNNING", map[string]string{
+			"connector.class": "io.debezium.connector.postgresql.PostgresConnector",
+			"topic.prefix":    "orders",
+		}),
+		"clicks-source": testExpandedConnector("source", "RUNNING", map[string]string{"kafka.topic": "clicks"}),
+		"orders-sink": testExpandedConnector("sink", "FAILED", map[string]string{
+			"topics.regex":                      "orders\\..*",
+			"errors.deadletterqueue.topic.name": "orders-dlq",
+		}),
+		"clicks-sink":  testExpandedConnector("sink", "RUNNING", map[string]string{"topics": "clicks, views"}),
+		"archive-sink": testExpandedConnector("sink", "RUNNING", map[string]string{"topics.regex": "archive-.*"}),
+	}
+	active := map[string][]string{
+		"orders-cdc":  {"orders.public.orders", "orders.public.customers"},
+		"clicks-sink": {"clicks"},
+	}
+
+	topology := buildTopology(connectors, active)
+
+	tests := []struct {
+		from, to, relation string
+		inferred           bool
+	}{
+		{"connector:orders-cdc", "topic:orders.public.orders", topologyProduces, false},
+		{"topic:orders.public.customers", "connector:orders-sink", topologyConsumes, true},
+		{"connector:orders-sink", "topic:orders-dlq", topologyDeadLetter, false},
+		{"connector:clicks-source", "topic:clicks", topologyProduces, true},
+		{"topic:clicks", "connector:clicks-sink", topologyConsumes, false},
+		{"topic:views", "connector:clicks-sink", topologyConsumes, true},
+		{"pattern:archive-.*", "connector:archive-sink", topologyConsumes, true},
+	}
+	for _, tt := range tests {
+		edge, ok := findEdge(topology.Edges, tt.from, tt.to, tt.relation)
+		if !ok {
+			t.Fatalf("missing edge %s -[%s]-> %s in %+v", tt.from, tt.relation, tt.to, topology.Edges)
+		}
+		if edge.Inferred != tt.inferred {
+			t.Fatalf("edge %s -> %s inferred = %v, want %v", tt.from, tt.to, edge.Inferred, tt.inferred)
+		}
+	}
+	if _, ok := findEdge(topology.Edges, "topic:orders-dlq", "connector:orders-sink", topologyConsumes); ok {
+		t.Fatalf("the dead letter topic must not match the sink's own regex")
+	}
+
+	var sink TopologyNode
+	for _, node := range topology.Nodes {
+		if node.ID == "connector:orders-sink" {
+			sink = node
+		}
+	}
+	if sink.Kind != topologyNodeConnector || sink.State != "failed" || sink.ConnectorType != "sink" {
+		t.Fatalf("unexpected sink node %+v", sink)
+	}
+}
+
+func TestTopologyHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/connectors":
+			w.Write([]byte(`{
+				"source": {"info": {"type": "source", "config": {"kafka.topic": "events"}}, "status": {"type": "source", "connector": {"state": "RUNNING"}}},
+				"sink": {"info": {"type": "sink", "config": {"topics": "events"}}, "status": {"type": "sink", "connector": {"state": "RUNNING"}}}
+			}`))
+		case "/connectors/source/topics":
+			w.Write([]byte(`{"source": {"topics": ["events"]}}`))
+		default:
+			// Topic tracking disabled for the sink.
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/topology", nil), map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	topologyHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var topology Topology
+	if err := json.Unmarshal(rr.Body.Bytes(), &topology); err != nil {
+		t.Fatalf("decode topology: %v", err)
+	}
+	if len(topology.Nodes) != 3 || len(topology.Edges) != 2 {
+		t.Fatalf("unexpected topology %+v", topology)
+	}
+	if edge, _ := findEdge(topology.Edges, "connector:source", "topic:events", topologyProduces); edge.Inferred {
+		t.Fatalf("expected the source edge to be confirmed by active topics")
+	}
+	if edge, ok := findEdge(topology.Edges, "topic:events", "connector:sink", topologyConsumes); !ok || !edge.Inferred {
+		t.Fatalf("expected an inferred sink edge, got %+v", topology.Edges)
+	}
+}
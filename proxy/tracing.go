@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+
+	defaultTraceServiceName = "kconnect-console-proxy"
+
+	// OTEL_EXPORTER_OTLP_PROTOCOL values; http/protobuf is the default.
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http/protobuf"
+)
+
+// Tracing follows the standard OpenTelemetry SDK variables. It is enabled by setting
+// an OTLP endpoint; spans are exported over OTLP/gRPC or OTLP/HTTP with protobuf.
+var (
+	otelSDKDisabled        = getEnv("OTEL_SDK_DISABLED", "false")
+	otelTracesExporter     = getEnv("OTEL_TRACES_EXPORTER", "otlp")
+	otlpEndpoint           = getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	otlpTracesEndpoint     = getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	otlpHeaders            = getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")
+	otlpTracesHeaders      = getEnv("OTEL_EXPORTER_OTLP_TRACES_HEADERS", "")
+	otlpProtocol           = getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", "")
+	otlpTracesProtocol     = getEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "")
+	otlpTimeout            = getEnv("OTEL_EXPORTER_OTLP_TIMEOUT", "10000")
+	otlpTracesTimeout      = getEnv("OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", "")
+	otelServiceName        = getEnv("OTEL_SERVICE_NAME", "")
+	otelResourceAttributes = getEnv("OTEL_RESOURCE_ATTRIBUTES", "")
+	otelTracesSampler      = getEnv("OTEL_TRACES_SAMPLER", "parentbased_always_on")
+	otelTracesSamplerArg   = getEnv("OTEL_TRACES_SAMPLER_ARG", "")
+	otelBSPScheduleDelay   = getEnv("OTEL_BSP_SCHEDULE_DELAY", "5000")
+	otelBSPMaxQueueSize    = getEnv("OTEL_BSP_MAX_QUEUE_SIZE", "2048")
+	otelBSPMaxExportBatch  = getEnv("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", "512")
+
+	// activeTracer is nil while tracing is off.
+	activeTracer *tracing
+
+	// tracePropagator reads and writes the W3C traceparent and tracestate headers.
+	tracePropagator propagation.TextMapPropagator = propagation.TraceContext{}
+)
+
+// tracing is the OpenTelemetry tracer provider built from the OTEL_* settings.
+type tracing struct {
+	provider *sdktrace.TracerProvider
+	endpoint string
+	protocol string
+}
+
+// loadTracer builds the tracer provider from the OTEL_* settings. It returns nil when no
+// OTLP endpoint is configured, the SDK is disabled or the traces exporter is "none".
+// The settings are passed to the SDK explicitly, so they can also come from --config.
+func loadTracer() (*tracing, error) {
+	if disabled, _ := strconv.ParseBool(otelSDKDisabled); disabled {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(otelTracesExporter)) {
+	case "none":
+		return nil, nil
+	case "otlp", "":
+	default:
+		return nil, &configError{name: "OTEL_TRACES_EXPORTER", value: otelTracesExporter}
+	}
+
+	protocol, protocolSetting := otlpTracesProtocol, "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+	if protocol == "" {
+		protocol, protocolSetting = otlpProtocol, "OTEL_EXPORTER_OTLP_PROTOCOL"
+	}
+	switch protocol = strings.TrimSpace(protocol); protocol {
+	case "":
+		protocol = otlpProtocolHTTP
+	case otlpProtocolHTTP, otlpProtocolGRPC:
+	case "http/json":
+		return nil, fmt.Errorf("%s=http/json is not supported; use http/protobuf, which OTLP/HTTP collectors accept on the same endpoint", protocolSetting)
+	default:
+		return nil, &configError{name: protocolSetting, value: protocol}
+	}
+
+	endpoint := strings.TrimSpace(otlpTracesEndpoint)
+	setting := "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	if endpoint == "" && strings.TrimSpace(otlpEndpoint) != "" {
+		// Over HTTP the generic endpoint is a base URL the signal path is appended to;
+		// gRPC uses it as it is.
+		endpoint, setting = strings.TrimRight(strings.TrimSpace(otlpEndpoint), "/"), "OTEL_EXPORTER_OTLP_ENDPOINT"
+		if protocol == otlpProtocolHTTP {
+			endpoint += "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &configError{name: setting, value: redactURL(endpoint)}
+	}
+
+	timeoutSetting, timeoutValue := "OTEL_EXPORTER_OTLP_TIMEOUT", otlpTimeout
+	if otlpTracesTimeout != "" {
+		timeoutSetting, timeoutValue = "OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", otlpTracesTimeout
+	}
+	timeout, err := parseMillisSetting(timeoutSetting, timeoutValue)
+	if err != nil {
+		return nil, err
+	}
+	delay, err := parseMillisSetting("OTEL_BSP_SCHEDULE_DELAY", otelBSPScheduleDelay)
+	if err != nil {
+		return nil, err
+	}
+	queueSize, err := strconv.Atoi(otelBSPMaxQueueSize)
+	if err != nil || queueSize <= 0 {
+		return nil, &configError{name: "OTEL_BSP_MAX_QUEUE_SIZE", value: otelBSPMaxQueueSize}
+	}
+	batchSize, err := strconv.Atoi(otelBSPMaxExportBatch)
+	if err != nil || batchSize <= 0 || batchSize > queueSize {
+		return nil, &configError{name: "OTEL_BSP_MAX_EXPORT_BATCH_SIZE", value: otelBSPMaxExportBatch}
+	}
+
+	sampler, err := parseTraceSampler(otelTracesSampler, otelTracesSamplerArg)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := parseOTLPPairs("OTEL_EXPORTER_OTLP_HEADERS", otlpHeaders)
+	if err != nil {
+		return nil, err
+	}
+	traceHeaders, err := parseOTLPPairs("OTEL_EXPORTER_OTLP_TRACES_HEADERS", otlpTracesHeaders)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range traceHeaders {
+		headers[key] = value
+	}
+
+	attributes, err := parseOTLPPairs("OTEL_RESOURCE_ATTRIBUTES", otelResourceAttributes)
+	if err != nil {
+		return nil, err
+	}
+	if otelServiceName != "" {
+		attributes["service.name"] = otelServiceName
+	} else if attributes["service.name"] == "" {
+		attributes["service.name"] = defaultTraceServiceName
+	}
+	resourceAttributes := make([]attribute.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		resourceAttributes = append(resourceAttributes, attribute.String(key, value))
+	}
+
+	var exporter sdktrace.SpanExporter
+	if protocol == otlpProtocolGRPC {
+		exporter, err = otlptracegrpc.New(context.Background(),
+			otlptracegrpc.WithEndpointURL(endpoint), otlptracegrpc.WithHeaders(headers), otlptracegrpc.WithTimeout(timeout))
+	} else {
+		exporter, err = otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(endpoint), otlptracehttp.WithHeaders(headers), otlptracehttp.WithTimeout(timeout))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("OTLP exporter: %w", err)
+	}
+
+	return &tracing{
+		provider: sdktrace.NewTracerProvider(
+			sdktrace.WithSampler(sampler),
+			sdktrace.WithResource(resource.NewSchemaless(resourceAttributes...)),
+			sdktrace.WithBatcher(exporter,
+				sdktrace.WithBatchTimeout(delay),
+				sdktrace.WithMaxQueueSize(queueSize),
+				sdktrace.WithMaxExportBatchSize(batchSize)),
+		),
+		endpoint: endpoint,
+		protocol: protocol,
+	}, nil
+}
+
+// parseTraceSampler builds the sampler named by OTEL_TRACES_SAMPLER.
+func parseTraceSampler(name, arg string) (sdktrace.Sampler, error) {
+	root := strings.ToLower(strings.TrimSpace(name))
+	parentBased := strings.HasPrefix(root, "parentbased_")
+	root = strings.TrimPrefix(root, "parentbased_")
+
+	var sampler sdktrace.Sampler
+	switch root {
+	case "always_on":
+		sampler = sdktrace.AlwaysSample()
+	case "always_off":
+		sampler = sdktrace.NeverSample()
+	case "traceidratio":
+		ratio := 1.0
+		if strings.TrimSpace(arg) != "" {
+			var err error
+			if ratio, err = strconv.ParseFloat(strings.TrimSpace(arg), 64); err != nil || ratio < 0 || ratio > 1 {
+				return nil, &configError{name: "OTEL_TRACES_SAMPLER_ARG", value: arg}
+			}
+		}
+		sampler = sdktrace.TraceIDRatioBased(ratio)
+	default:
+		return nil, &configError{name: "OTEL_TRACES_SAMPLER", value: name}
+	}
+	if parentBased {
+		sampler = sdktrace.ParentBased(sampler)
+	}
+	return sampler, nil
+}
+
+func parseMillisSetting(name, value string) (time.Duration, error) {
+	ms, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || ms <= 0 {
+		return 0, &configError{name: name, value: value}
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// parseOTLPPairs reads the key=value,key=value lists used by the OTEL_* settings.
+// Values are URL-decoded.
+func parseOTLPPairs(name, value string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range splitList(value) {
+		key, raw, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s: expected key=value, got %q", name, item)
+		}
+		decoded, err := url.QueryUnescape(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: %w", name, key, err)
+		}
+		pairs[key] = decoded
+	}
+	return pairs, nil
+}
+
+// spanName names spans after the HTTP method until traceRouteMiddleware knows the route.
+func spanName(_ string, r *http.Request) string {
+	return r.Method
+}
+
+// tracingMiddleware starts a server span for every incoming request, continuing the
+// caller's trace when it sends a traceparent header. It must be built after loadTracer.
+func tracingMiddleware(next http.Handler) http.Handler {
+	if activeTracer == nil {
+		return next
+	}
+	return otelhttp.NewHandler(next, "",
+		otelhttp.WithTracerProvider(activeTracer.provider),
+		otelhttp.WithPropagators(tracePropagator),
+		otelhttp.WithSpanNameFormatter(spanName))
+}
+
+// traceRouteMiddleware names the server span after the matched route, e.g.
+// "GET /api/{cluster}/connectors/{name}/status".
+func traceRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := trace.SpanFromContext(r.Context()); s.IsRecording() {
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					s.SetName(r.Method + " " + template)
+					s.SetAttributes(attribute.String("http.route", template))
+				}
+			}
+			if cluster := mux.Vars(r)["cluster"]; cluster != "" {
+				s.SetAttributes(attribute.String("kconnect.cluster", cluster))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tracingTransport records a client span for each call made on behalf of a traced
+// request and passes the trace on in the traceparent header. Background calls, such as
+// scheduled polls, are not traced.
+type tracingTransport struct {
+	base http.RoundTripper
+	// peer names the remote service, e.g. "kafka-connect".
+	peer string
+
+	traced sync.Map // trace.TracerProvider -> *otelhttp.Transport
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := trace.SpanFromContext(req.Context())
+	if !parent.SpanContext().IsValid() {
+		return t.base.RoundTrip(req)
+	}
+
+	provider := parent.TracerProvider()
+	traced, ok := t.traced.Load(provider)
+	if !ok {
+		traced, _ = t.traced.LoadOrStore(provider, otelhttp.NewTransport(t.base,
+			otelhttp.WithTracerProvider(provider),
+			otelhttp.WithPropagators(tracePropagator),
+			otelhttp.WithSpanNameFormatter(spanName),
+			otelhttp.WithSpanOptions(trace.WithAttributes(attribute.String("peer.service", t.peer)))))
+	}
+	return traced.(http.RoundTripper).RoundTrip(req)
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func withTestTracingSettings(t *testing.T) {
+	t.Helper()
+	vars := []*string{&otelSDKDisabled, &otlpEndpoint, &otlpTracesEndpoint, &otlpHeaders, &otlpProtocol, &otlpTracesProtocol, &otelServiceName, &otelResourceAttributes}
+	originals := make([]string, len(vars))
+	for i, v := range vars {
+		originals[i] = *v
+	}
+	t.Cleanup(func() {
+		for i, v := range vars {
+			*v = originals[i]
+		}
+	})
+	otelSDKDisabled, otlpEndpoint, otlpTracesEndpoint, otlpHeaders, otlpProtocol, otlpTracesProtocol, otelServiceName, otelResourceAttributes = "false", "", "", "", "", "", "", ""
+}
+
+func TestParseTraceSampler(t *testing.T) {
+	low, high := trace.TraceID{15: 1}, trace.TraceID{8: 0xff}
+	unsampled := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, Remote: true,
+	}))
+	sampled := func(sampler sdktrace.Sampler, parent context.Context, id trace.TraceID) bool {
+		return sampler.ShouldSample(sdktrace.SamplingParameters{ParentContext: parent, TraceID: id}).Decision == sdktrace.RecordAndSample
+	}
+
+	sampler, err := parseTraceSampler("parentbased_traceidratio", "0.5")
+	if err != nil {
+		t.Fatalf("parseTraceSampler: %v", err)
+	}
+	if !sampled(sampler, context.Background(), low) || sampled(sampler, context.Background(), high) {
+		t.Fatalf("expected the ratio to decide root spans")
+	}
+	if sampled(sampler, unsampled, low) {
+		t.Fatalf("expected the parent's decision to be followed")
+	}
+
+	if off, _ := parseTraceSampler("always_off", ""); sampled(off, context.Background(), low) {
+		t.Fatalf("expected always_off to sample nothing")
+	}
+	if on, _ := parseTraceSampler("always_on", ""); !sampled(on, unsampled, high) {
+		t.Fatalf("expected always_on to ignore the parent")
+	}
+	for _, tc := range []struct{ name, arg string }{{"sometimes", ""}, {"traceidratio", "2"}, {"traceidratio", "half"}} {
+		if _, err := parseTraceSampler(tc.name, tc.arg); err == nil {
+			t.Errorf("expected %s %q to be rejected", tc.name, tc.arg)
+		}
+	}
+}
+
+func TestLoadTracer(t *testing.T) {
+	withTestTracingSettings(t)
+
+	if tracer, err := loadTracer(); tracer != nil || err != nil {
+		t.Fatalf("expected tracing to be off without an endpoint, got %v %v", tracer, err)
+	}
+
+	for _, tc := range []struct{ protocol, endpoint, traces, want, wantProtocol string }{
+		{"", "http://collector:4318/", "", "http://collector:4318/v1/traces", "http/protobuf"},
+		{"http/protobuf", "http://collector:4318", "https://collector:4318/custom", "https://collector:4318/custom", "http/protobuf"},
+		{"grpc", "http://collector:4317/", "", "http://collector:4317", "grpc"},
+	} {
+		otlpProtocol, otlpEndpoint, otlpTracesEndpoint = tc.protocol, tc.endpoint, tc.traces
+		tracer, err := loadTracer()
+		if err != nil {
+			t.Fatalf("%+v: loadTracer: %v", tc, err)
+		}
+		tracer.provider.Shutdown(context.Background())
+		if tracer.endpoint != tc.want || tracer.protocol != tc.wantProtocol {
+			t.Errorf("%+v: unexpected exporter %s over %s", tc, tracer.endpoint, tracer.protocol)
+		}
+	}
+	otlpTracesEndpoint = ""
+
+	otelSDKDisabled = "true"
+	if tracer, _ := loadTracer(); tracer != nil {
+		t.Fatalf("expected OTEL_SDK_DISABLED to turn tracing off")
+	}
+	otelSDKDisabled = "false"
+
+	for _, tc := range []struct{ endpoint, protocol, headers string }{
+		{"collector:4318", "", ""},
+		{"http://collector:4318", "http/json", ""},
+		{"http://collector:4318", "thrift", ""},
+		{"http://collector:4318", "", "novalue"},
+	} {
+		otlpEndpoint, otlpProtocol, otlpHeaders = tc.endpoint, tc.protocol, tc.headers
+		if _, err := loadTracer(); err == nil {
+			t.Errorf("expected %+v to be rejected", tc)
+		}
+	}
+}
+
+// testTraceCollector receives OTLP exports over gRPC.
+type testTraceCollector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	received chan *collectortrace.ExportTraceServiceRequest
+	apiKeys  chan string
+}
+
+func (c *testTraceCollector) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.apiKeys <- strings.Join(md.Get("x-api-key"), ",")
+	c.received <- req
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func TestTracerExportsOverEachProtocol(t *testing.T) {
+	withTestTracingSettings(t)
+	otlpHeaders = "x-api-key=abc%3D"
+	otelResourceAttributes = "deployment.environment=prod"
+	otelServiceName = "console"
+
+	collector := &testTraceCollector{
+		received: make(chan *collectortrace.ExportTraceServiceRequest, 1),
+		apiKeys:  make(chan string, 1),
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(grpcServer, collector)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req collectortrace.ExportTraceServiceRequest
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected export %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		} else if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("collector: %v", err)
+		}
+		collector.apiKeys <- r.Header.Get("X-Api-Key")
+		collector.received <- &req
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer httpServer.Close()
+
+	for _, tc := range []struct{ protocol, endpoint string }{
+		{"grpc", "http://" + listener.Addr().String()},
+		{"http/protobuf", httpServer.URL},
+	} {
+		otlpProtocol, otlpEndpoint = tc.protocol, tc.endpoint
+		tracer, err := loadTracer()
+		if err != nil {
+			t.Fatalf("%s: loadTracer: %v", tc.protocol, err)
+		}
+		_, span := tracer.provider.Tracer("test").Start(context.Background(), "GET /api/{cluster}/connectors")
+		span.End()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := tracer.provider.Shutdown(ctx); err != nil {
+			t.Fatalf("%s: export: %v", tc.protocol, err)
+		}
+		cancel()
+
+		var req *collectortrace.ExportTraceServiceRequest
+		select {
+		case req = <-collector.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: nothing was exported", tc.protocol)
+		}
+		if key := <-collector.apiKeys; key != "abc=" {
+			t.Errorf("%s: expected OTEL_EXPORTER_OTLP_HEADERS to be sent, got %q", tc.protocol, key)
+		}
+		resource := map[string]string{}
+		for _, attribute := range req.ResourceSpans[0].Resource.Attributes {
+			resource[attribute.Key] = attribute.Value.GetStringValue()
+		}
+		if resource["service.name"] != "console" || resource["deployment.environment"] != "prod" {
+			t.Errorf("%s: unexpected resource %v", tc.protocol, resource)
+		}
+		if spans := req.ResourceSpans[0].ScopeSpans[0].Spans; len(spans) != 1 || spans[0].Name != "GET /api/{cluster}/connectors" {
+			t.Errorf("%s: unexpected spans %v", tc.protocol, spans)
+		}
+	}
+}
+
+func TestTracingPropagatesThroughUpstreamCalls(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	originalTracer := activeTracer
+	t.Cleanup(func() { activeTracer = originalTracer })
+	activeTracer = &tracing{provider: provider}
+
+	var (
+		mu                  sync.Mutex
+		upstreamTraceparent string
+	)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		upstreamTraceparent = r.Header.Get(traceparentHeader)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &tracingTransport{base: http.DefaultTransport, peer: "kafka-connect"}}
+	router := mux.NewRouter()
+	router.Use(traceRouteMiddleware)
+	router.HandleFunc("/api/{cluster}/connectors/{name}/status", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL+"/connectors/orders/status", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("upstream: %v", err)
+			return
+		}
+		resp.Body.Close()
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/orders/status", nil)
+	req.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	tracingMiddleware(router).ServeHTTP(httptest.NewRecorder(), req)
+
+	exported := recorder.Ended()
+	if len(exported) != 2 {
+		t.Fatalf("expected a server and a client span, got %d", len(exported))
+	}
+	clientSpan, server := exported[0], exported[1]
+	if server.Name() != "GET /api/{cluster}/connectors/{name}/status" || server.SpanKind() != trace.SpanKindServer ||
+		server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected server span %s %s parent %s", server.Name(), server.SpanKind(), server.Parent().SpanID())
+	}
+	if server.Status().Code != codes.Error {
+		t.Fatalf("expected the 502 to mark the server span as failed, got %+v", server.Status())
+	}
+	if clientSpan.SpanKind() != trace.SpanKindClient || clientSpan.SpanContext().TraceID() != server.SpanContext().TraceID() ||
+		clientSpan.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("expected the client span to be a child of the server span")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := "00-" + server.SpanContext().TraceID().String() + "-" + clientSpan.SpanContext().SpanID().String() + "-01"; upstreamTraceparent != want {
+		t.Fatalf("expected Connect to receive %q, got %q", want, upstreamTraceparent)
+	}
+
+	attributes := map[string]string{}
+	for _, attribute := range clientSpan.Attributes() {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	if attributes["peer.service"] != "kafka-connect" || attributes["http.status_code"] != "404" || clientSpan.Status().Code != codes.Error {
+		t.Fatalf("unexpected client span attributes %v", attributes)
+	}
+}
+
+func TestTracingTransportSkipsUntracedCalls(t *testing.T) {
+	var header string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(traceparentHeader)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &tracingTransport{base: http.DefaultTransport, peer: "jolokia"}}
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if header != "" {
+		t.Fatalf("expected a background call to carry no trace, got %q", header)
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Causes ranked by the triage report.
+const (
+	triageAuthFailure         = "auth_failure"
+	triageSchemaIncompatible  = "schema_incompatible"
+	triageMissingTopic        = "missing_topic"
+	triageOutOfMemory         = "out_of_memory"
+	triageConnectionFailure   = "connection_failure"
+	triageRecentConfigChange  = "recent_config_change"
+	triageWorkerConcentration = "worker_concentration"
+	triageUnknown             = "unknown"
+)
+
+const (
+	// triageConfigWindow is how far back config changes are looked up in the audit log.
+	triageConfigWindow = 24 * time.Hour
+	// triageConfigLead is how close before the failure was first seen a config change
+	// must be to count as its likely trigger.
+	triageConfigLead = time.Hour
+	// triageTopicsTimeout bounds the topic lookup in Kafka.
+	triageTopicsTimeout = 10 * time.Second
+)
+
+// triageRemediations suggests what to check for each cause.
+var triageRemediations = map[string]string{
+	triageAuthFailure:         "Check the credentials in the connector config and the ACLs or grants of its principal in Kafka and the external system.",
+	triageSchemaIncompatible:  "Compare the record schema with the subject's compatibility rules, or check the converters match how the topic was written.",
+	triageMissingTopic:        "Create the topic or fix the topic names in the config; Kafka may not allow topics to be created automatically.",
+	triageOutOfMemory:         "Raise the worker heap, lower batch sizes and tasks.max, or move the tasks to workers with more memory.",
+	triageConnectionFailure:   "Check that the external system is up and reachable from the workers, and the host, port and TLS settings.",
+	triageRecentConfigChange:  "Compare the config with the version before the change, e.g. with the restore points, and roll back if needed.",
+	triageWorkerConcentration: "Look at the logs and resources of that worker; restarting the tasks moves them if the worker is unhealthy.",
+	triageUnknown:             "Read the full trace and the worker logs around the time the failure was first seen.",
+}
+
+// triageTraceRule maps exceptions in a failure trace to a cause. A rule matches an
+// exception whose class ends in one of classes, or whose message contains one of
+// messages (lowercase).
+type triageTraceRule struct {
+	cause    string
+	title    string
+	classes  []string
+	messages []string
+}
+
+// triageTraceRules are checked against every exception of a trace, first match wins
+// for each exception.
+var triageTraceRules = []triageTraceRule{
+	{triageOutOfMemory, "The worker ran out of memory", []string{"OutOfMemoryError"},
+		[]string{"java heap space", "gc overhead limit exceeded", "direct buffer memory", "metaspace"}},
+	{triageAuthFailure, "Authentication or authorization failed", []string{"AuthenticationException", "AuthorizationException", "SaslAuthenticationException", "SQLInvalidAuthorizationSpecException", "AccessDeniedException"},
+		[]string{"authentication failed", "not authorized", "unauthorized", "access denied", "permission denied", "invalid credentials", "password authentication failed"}},
+	{triageMissingTopic, "A topic the connector uses does not exist", []string{"UnknownTopicOrPartitionException", "InvalidTopicException"},
+		[]string{"unknown topic", "not present in metadata", "topic does not exist"}},
+	{triageSchemaIncompatible, "Records do not match the expected schema", []string{"SerializationException", "DataException", "SchemaParseException", "IncompatibleSchemaException"},
+		[]string{"incompatible", "unknown magic byte", "error retrieving avro", "schema not found", "subject not found", "failed to deserialize", "converting byte[] to kafka connect data failed"}},
+	{triageConnectionFailure, "The external system could not be reached", []string{"java.net.ConnectException", "UnknownHostException", "SocketTimeoutException", "NoRouteToHostException", "CommunicationsException"},
+		[]string{"connection refused", "connection reset", "timed out", "no route to host", "unknown host"}},
+}
+
+// Scores added per piece of evidence. A class match is stronger evidence than words in
+// a message.
+const (
+	triageScoreClass        = 4
+	triageScoreMessage      = 2
+	triageScoreTopicMissing = 5
+	triageScoreConfigChange = 2
+	triageScoreConfigLead   = 2
+	triageScoreWorker       = 3
+	triageScoreUnknown      = 1
+)
+
+// TriageCause is one likely cause of a connector failure with the evidence for it.
+type TriageCause struct {
+	Cause       string   `json:"cause"`
+	Title       string   `json:"title"`
+	Confidence  string   `json:"confidence"`
+	Score       int      `json:"score"`
+	Evidence    []string `json:"evidence"`
+	Remediation string   `json:"remediation"`
+}
+
+// TriageReport is returned by GET /api/{cluster}/connectors/{name}/triage. Causes are
+// ranked, most likely first. Unavailable lists checks that could not run.
+type TriageReport struct {
+	Connector   string        `json:"connector"`
+	State       string        `json:"state"`
+	Failed      []string      `json:"failed"`
+	FirstSeen   *time.Time    `json:"firstSeen,omitempty"`
+	EvaluatedAt time.Time     `json:"evaluatedAt"`
+	Causes      []TriageCause `json:"causes"`
+	Unavailable []string      `json:"unavailable"`
+}
+
+// triageInputs is what the triage of one connector looks at. Topics is nil when the
+// topics in Kafka could not be listed.
+type triageInputs struct {
+	Status        connectorStatusResponse
+	Config        map[string]string
+	ConfigChanges []AuditLogEntry
+	FirstSeen     *time.Time
+	Topics        map[string]bool
+}
+
+// failedSources lists the failed instances of a status: "connector", "task-0", ...
+func failedSources(status connectorStatusResponse) []string {
+	var failed []string
+	if normalizeState(status.Connector.State) == "failed" {
+		failed = append(failed, "connector")
+	}
+	for _, task := range status.Tasks {
+		if normalizeState(task.State) == "failed" {
+			failed = append(failed, "task-"+strconv.Itoa(task.ID))
+		}
+	}
+	return failed
+}
+
+// triageBuilder collects causes with their scores and evidence.
+type triageBuilder map[string]*TriageCause
+
+func (b triageBuilder) add(cause, title string, score int, evidence string) {
+	entry, ok := b[cause]
+	if !ok {
+		entry = &TriageCause{Cause: cause, Title: title, Remediation: triageRemediations[cause], Evidence: []string{}}
+		b[cause] = entry
+	}
+	entry.Score += score
+	if !containsString(entry.Evidence, evidence) {
+		entry.Evidence = append(entry.Evidence, evidence)
+	}
+}
+
+// ranked returns the causes by score, then name, with their confidence set.
+func (b triageBuilder) ranked() []TriageCause {
+	causes := make([]TriageCause, 0, len(b))
+	for _, cause := range b {
+		switch {
+		case cause.Score >= 5:
+			cause.Confidence = "high"
+		case cause.Score >= 3:
+			cause.Confidence = "medium"
+		default:
+			cause.Confidence = "low"
+		}
+		causes = append(causes, *cause)
+	}
+	sort.Slice(causes, func(i, j int) bool {
+		if causes[i].Score != causes[j].Score {
+			return causes[i].Score > causes[j].Score
+		}
+		return causes[i].Cause < causes[j].Cause
+	})
+	return causes
+}
+
+// shortMessage cuts an exception message to its first line and 200 characters.
+func shortMessage(message string) string {
+	message, _, _ = strings.Cut(message, "\n")
+	if len(message) > 200 {
+		message = message[:200] + "..."
+	}
+	return message
+}
+
+// matchTraceRule returns the rule an exception matches and whether it matched by class.
+func matchTraceRule(exception JavaException) (triageTraceRule, bool, bool) {
+	message := strings.ToLower(exception.Message)
+	for _, rule := range triageTraceRules {
+		for _, class := range rule.classes {
+			if strings.HasSuffix(exception.Class, class) {
+				return rule, true, true
+			}
+		}
+		for _, text := range rule.messages {
+			if strings.Contains(message, text) {
+				return rule, false, true
+			}
+		}
+	}
+	return triageTraceRule{}, false, false
+}
+
+// evaluateTriage ranks the likely causes of a connector's failures. A connector without
+// failed instances gets no causes.
+func evaluateTriage(in triageInputs) []TriageCause {
+	causes := triageBuilder{}
+	status := in.Status
+	if len(failedSources(status)) == 0 {
+		return []TriageCause{}
+	}
+
+	// Exceptions in the failure traces.
+	traces := map[string]string{"connector": status.Connector.Trace}
+	for _, task := range status.Tasks {
+		traces["task-"+strconv.Itoa(task.ID)] = task.Trace
+	}
+	sources := make([]string, 0, len(traces))
+	for source, trace := range traces {
+		if trace != "" {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	var roots []string
+	for _, source := range sources {
+		chain := parseJavaTrace(traces[source])
+		matched := map[string]bool{}
+		for _, exception := range chain {
+			rule, byClass, ok := matchTraceRule(exception)
+			if !ok || matched[rule.cause] {
+				continue
+			}
+			matched[rule.cause] = true
+			score := triageScoreMessage
+			if byClass {
+				score = triageScoreClass
+			}
+			evidence := fmt.Sprintf("%s: %s", source, exception.Class)
+			if exception.Message != "" {
+				evidence += ": " + shortMessage(exception.Message)
+			}
+			causes.add(rule.cause, rule.title, score, evidence)
+		}
+		if len(chain) > 0 {
+			root := chain[len(chain)-1]
+			roots = append(roots, fmt.Sprintf("%s: root cause %s: %s", source, root.Class, shortMessage(root.Message)))
+		}
+	}
+
+	// Topics named in the config that do not exist in Kafka.
+	if in.Topics != nil {
+		topics := configuredTopics(status.Type, in.Config)
+		for _, topic := range topics.topics {
+			if !in.Topics[topic] {
+				causes.add(triageMissingTopic, "A topic the connector uses does not exist", triageScoreTopicMissing, fmt.Sprintf("topic %q does not exist in Kafka", topic))
+			}
+		}
+		if topics.deadLetter != "" && !in.Topics[topics.deadLetter] {
+			causes.add(triageMissingTopic, "A topic the connector uses does not exist", triageScoreTopicMissing, fmt.Sprintf("dead letter queue topic %q does not exist in Kafka", topics.deadLetter))
+		}
+	}
+
+	// Config changes shortly before the failure was first seen.
+	for _, change := range in.ConfigChanges {
+		score := triageScoreConfigChange
+		evidence := fmt.Sprintf("config %s by %s at %s", strings.ToLower(change.Action)+"d", change.User, change.Timestamp.UTC().Format(time.RFC3339))
+		if in.FirstSeen != nil && !change.Timestamp.After(*in.FirstSeen) && in.FirstSeen.Sub(change.Timestamp) <= triageConfigLead {
+			score += triageScoreConfigLead
+			evidence += fmt.Sprintf(", %s before the failure was first seen", in.FirstSeen.Sub(change.Timestamp).Round(time.Second))
+		}
+		causes.add(triageRecentConfigChange, "The config changed recently", score, evidence)
+	}
+
+	// Failed tasks gathered on one worker while the others run elsewhere.
+	failedOn, runningElsewhere := map[string]int{}, 0
+	for _, task := range status.Tasks {
+		if normalizeState(task.State) == "failed" {
+			failedOn[task.WorkerID]++
+		}
+	}
+	if len(failedOn) == 1 {
+		for worker, failed := range failedOn {
+			for _, task := range status.Tasks {
+				if normalizeState(task.State) == "running" && task.WorkerID != worker {
+					runningElsewhere++
+				}
+			}
+			if worker != "" && runningElsewhere > 0 {
+				causes.add(triageWorkerConcentration, "The failures are limited to one worker", triageScoreWorker,
+					fmt.Sprintf("all %d failed task(s) run on %s while %d task(s) on other workers are running", failed, worker, runningElsewhere))
+			}
+		}
+	}
+
+	if len(causes) == 0 {
+		for _, root := range roots {
+			causes.add(triageUnknown, "No known failure pattern matched", triageScoreUnknown, root)
+		}
+		if len(roots) == 0 {
+			causes.add(triageUnknown, "No known failure pattern matched", triageScoreUnknown, "the failed instances report no trace")
+		}
+	}
+	return causes.ranked()
+}
+
+// buildTriageReport gathers the inputs of the triage of one connector. The config, the
+// topics and the audit log are optional; the report names the checks that could not run.
+func buildTriageReport(ctx context.Context, cluster, name string) (TriageReport, error) {
+	client, baseURL := connectClientFor(cluster, routeRead), connectURLFor(cluster)
+	status, err := fetchConnectorStatus(ctx, client, baseURL, name)
+	if err != nil {
+		return TriageReport{}, err
+	}
+
+	now := time.Now().UTC()
+	report := TriageReport{
+		Connector:   name,
+		State:       normalizeState(status.Connector.State),
+		Failed:      failedSources(status),
+		EvaluatedAt: now,
+		Unavailable: []string{},
+	}
+	if report.Failed == nil {
+		report.Failed = []string{}
+	}
+	in := triageInputs{Status: status}
+
+	for _, group := range connectorErrors.record(cluster, status) {
+		if group.Active && (in.FirstSeen == nil || group.FirstSeen.Before(*in.FirstSeen)) {
+			firstSeen := group.FirstSeen
+			in.FirstSeen = &firstSeen
+		}
+	}
+	report.FirstSeen = in.FirstSeen
+
+	in.Config, err = fetchConnectorConfig(ctx, client, baseURL, name)
+	if err != nil {
+		log.Printf("triage %s/%s: %v", cluster, name, err)
+		report.Unavailable = append(report.Unavailable, "config")
+	}
+
+	if kafkaTopics.enabled() && in.Config != nil {
+		topicsCtx, cancel := context.WithTimeout(ctx, triageTopicsTimeout)
+		in.Topics, err = kafkaTopics.topicNames(topicsCtx)
+		cancel()
+		if err != nil {
+			log.Printf("triage %s/%s: %v", cluster, name, err)
+			in.Topics = nil
+		}
+	}
+	if in.Topics == nil {
+		report.Unavailable = append(report.Unavailable, "topics")
+	}
+
+	since := now.Add(-triageConfigWindow)
+	for _, entry := range auditLog.Query(AuditFilter{Connector: name, Status: auditStatusSuccess, Range: timeRange{Since: since}}) {
+		if entry.Cluster == cluster && (entry.Action == auditActionUpdate || entry.Action == auditActionCreate) {
+			in.ConfigChanges = append(in.ConfigChanges, entry)
+		}
+	}
+
+	report.Causes = evaluateTriage(in)
+	return report, nil
+}
+
+// connectorTriageHandler ranks the likely causes of a connector's failures.
+func connectorTriageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	report, err := buildTriageReport(r.Context(), vars["cluster"], vars["name"])
+	if err != nil {
+		var unavailable *connectUnavailableError
+		switch {
+		case errors.Is(err, errConnectorNotFound):
+			writeJSONError(w, http.StatusNotFound, "connector_not_found", fmt.Sprintf("connector %q does not exist", vars["name"]))
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		default:
+			writeJSONError(w, http.StatusBadGateway, "connect_request_failed", err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
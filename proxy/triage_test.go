@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+func triageStatus(connectorState string, tasks ...[3]string) connectorStatusResponse {
+	var status connectorStatusResponse
+	status.Name, status.Type = "orders-sink", "sink"
+	status.Connector.State = connectorState
+	for i, task := range tasks {
+		status.Tasks = append(status.Tasks, struct {
+			ID       int    `json:"id"`
+			State    string `json:"state"`
+			WorkerID string `json:"worker_id"`
+			Trace    string `json:"trace,omitempty"`
+		}{ID: i, State: task[0], WorkerID: task[1], Trace: task[2]})
+	}
+	return status
+}
+
+func TestEvaluateTriage(t *testing.T) {
+	firstSeen := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	saslTrace := "org.apache.kafka.connect.errors.ConnectException: Failed to connect\n\tat org.apache.kafka.connect.runtime.WorkerSinkTask.poll(WorkerSinkTask.java:10)\nCaused by: org.apache.kafka.common.errors.SaslAuthenticationException: Authentication failed: Invalid username or password\n"
+	oomTrace := "java.lang.OutOfMemoryError: Java heap space\n\tat java.util.Arrays.copyOf(Arrays.java:3236)\n"
+	avroTrace := "org.apache.kafka.connect.errors.ConnectException: Tolerance exceeded\nCaused by: org.apache.kafka.common.errors.SerializationException: Unknown magic byte!\n"
+	oddTrace := "com.example.WeirdException: something odd\n\tat com.example.Task.put(Task.java:1)\n"
+
+	tests := []struct {
+		name string
+		in   triageInputs
+		want []string
+	}{
+		{"running", triageInputs{Status: triageStatus("RUNNING", [3]string{"RUNNING", "w1", ""})}, nil},
+		{"auth by class", triageInputs{Status: triageStatus("RUNNING", [3]string{"FAILED", "w1", saslTrace})}, []string{triageAuthFailure}},
+		{"oom on one worker", triageInputs{Status: triageStatus("RUNNING", [3]string{"FAILED", "w1", oomTrace}, [3]string{"RUNNING", "w2", ""})}, []string{triageOutOfMemory, triageWorkerConcentration}},
+		{"schema", triageInputs{Status: triageStatus("RUNNING", [3]string{"FAILED", "w1", avroTrace})}, []string{triageSchemaIncompatible}},
+		{"missing topic", triageInputs{
+			Status: triageStatus("RUNNING", [3]string{"FAILED", "w1", oddTrace}),
+			Config: map[string]string{"topics": "orders,refunds"},
+			Topics: map[string]bool{"orders": true},
+		}, []string{triageMissingTopic}},
+		{"config change before failure", triageInputs{
+			Status:        triageStatus("RUNNING", [3]string{"FAILED", "w1", oddTrace}),
+			FirstSeen:     &firstSeen,
+			ConfigChanges: []AuditLogEntry{{Action: auditActionUpdate, User: "alice", Timestamp: firstSeen.Add(-10 * time.Minute)}},
+		}, []string{triageRecentConfigChange}},
+		{"unknown", triageInputs{Status: triageStatus("FAILED", [3]string{"FAILED", "w1", oddTrace})}, []string{triageUnknown}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			causes := evaluateTriage(tc.in)
+			var got []string
+			for _, cause := range causes {
+				got = append(got, cause.Cause)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("expected causes %v, got %v (%+v)", tc.want, got, causes)
+			}
+		})
+	}
+
+	causes := evaluateTriage(tests[1].in)
+	if causes[0].Confidence != "medium" || !strings.Contains(causes[0].Evidence[0], "task-0: org.apache.kafka.common.errors.SaslAuthenticationException: Authentication failed") {
+		t.Fatalf("unexpected auth evidence %+v", causes)
+	}
+	causes = evaluateTriage(tests[5].in)
+	if causes[0].Confidence != "medium" || !strings.Contains(causes[0].Evidence[0], "updated by alice") || !strings.Contains(causes[0].Evidence[0], "10m0s before the failure") {
+		t.Fatalf("unexpected config change evidence %+v", causes[0])
+	}
+}
+
+func TestConnectorTriageHandler(t *testing.T) {
+	server := testutils.NewConnectServer(map[string]testutils.Response{
+		"GET /connectors/orders-sink/status": {Body: map[string]interface{}{
+			"name": "orders-sink", "type": "sink",
+			"connector": map[string]interface{}{"state": "RUNNING", "worker_id": "w1"},
+			"tasks": []interface{}{map[string]interface{}{
+				"id": 0, "state": "FAILED", "worker_id": "w1",
+				"trace": "org.apache.kafka.common.errors.UnknownTopicOrPartitionException: This server does not host this topic-partition.\n",
+			}},
+		}},
+		"GET /connectors/orders-sink/config": {Body: map[string]string{"connector.class": "JdbcSink", "topics": "orders,refunds"}},
+	})
+	defer server.Close()
+
+	originalURL, originalTopics, originalErrors := connectURL, kafkaTopics, connectorErrors
+	t.Cleanup(func() { connectURL, kafkaTopics, connectorErrors = originalURL, originalTopics, originalErrors })
+	connectURL = server.URL()
+	kafkaTopics = fakeTopicLister{"orders": true}
+	connectorErrors = newErrorTracker(time.Now)
+	logger := withTestAuditLog(t, 10)
+	logger.Log(AuditLogEntry{Timestamp: time.Now().Add(-5 * time.Minute), User: "bob", Cluster: "default", Action: auditActionUpdate, ConnectorName: "orders-sink", Status: auditStatusSuccess})
+	logger.Log(AuditLogEntry{Timestamp: time.Now().Add(-5 * time.Minute), User: "bob", Cluster: "other", Action: auditActionUpdate, ConnectorName: "orders-sink", Status: auditStatusSuccess})
+
+	call := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/default/connectors/"+name+"/triage", nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "name": name})
+		rr := httptest.NewRecorder()
+		connectorTriageHandler(rr, req)
+		return rr
+	}
+
+	rr := call("orders-sink")
+	var report TriageReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if len(report.Causes) != 2 || report.Causes[0].Cause != triageMissingTopic || report.Causes[0].Confidence != "high" || len(report.Causes[0].Evidence) != 2 {
+		t.Fatalf("expected the missing topic first with trace and Kafka evidence, got %+v", report.Causes)
+	}
+	if report.Causes[1].Cause != triageRecentConfigChange || len(report.Causes[1].Evidence) != 1 || report.FirstSeen == nil || len(report.Unavailable) != 0 {
+		t.Fatalf("expected this cluster's config change only, got %+v", report)
+	}
+	if strings.Join(report.Failed, ",") != "task-0" {
+		t.Fatalf("unexpected failed instances %v", report.Failed)
+	}
+
+	if rr := call("missing"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown connector, got %d", rr.Code)
+	}
+}
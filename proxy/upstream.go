@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// Basic-auth credentials for Kafka Connect. When set they are added to upstream
+	// requests for KAFKA_CONNECT_URL and its workers, replacing any Authorization header
+	// sent by the browser. Clusters of KAFKA_CONNECT_CLUSTERS on other hosts never get
+	// them.
+	connectUsername = getEnv("KAFKA_CONNECT_USERNAME", "")
+	connectPassword = getEnv("KAFKA_CONNECT_PASSWORD", "")
+
+	// Idempotent reads are retried on network errors and 502/503/504 responses with
+	// exponential backoff and jitter. After CIRCUIT_BREAKER_THRESHOLD consecutive
+	// failures the circuit for that Connect host opens and requests fail immediately
+	// until CIRCUIT_BREAKER_COOLDOWN has passed.
+	upstreamRetries         = getEnv("UPSTREAM_RETRIES", "2")
+	upstreamRetryBackoff    = getEnv("UPSTREAM_RETRY_BACKOFF", "100ms")
+	upstreamRetryMaxBackoff = getEnv("UPSTREAM_RETRY_MAX_BACKOFF", "2s")
+	circuitBreakerThreshold = getEnv("CIRCUIT_BREAKER_THRESHOLD", "5")
+	circuitBreakerCooldown  = getEnv("CIRCUIT_BREAKER_COOLDOWN", "30s")
+
+	connectResilience = newResilientTransport(&authTransport{base: &faultTransport{base: &decodingTransport{base: upstreamHTTP}}}, defaultUpstreamPolicy, time.Now)
+
+	connectTransport http.RoundTripper = &tracingTransport{base: connectLimiter, peer: "kafka-connect"}
+)
+
+// authTransport injects the configured Connect credentials, basic auth or a Kerberos
+// Negotiate token, into requests for the hosts of the default cluster, and logs a hint
+// the first time Connect rejects a request as unauthorized.
+type authTransport struct {
+	base     http.RoundTripper
+	kerberos *kerberosAuth // defaults to connectKerberos
+	warned   atomic.Bool
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	kerberos := t.kerberos
+	if kerberos == nil {
+		kerberos = connectKerberos
+	}
+	credentialed := credentialedHost(req.URL.Host)
+	switch {
+	case !credentialed:
+		// Another cluster's host: its own Connect credentials are not ours to send.
+	case kerberos != nil:
+		req = req.Clone(req.Context())
+		if err := kerberos.authorize(req); err != nil {
+			return nil, err
+		}
+	case connectUsername != "":
+		req = req.Clone(req.Context())
+		req.SetBasicAuth(connectUsername, connectPassword)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && t.warned.CompareAndSwap(false, true) {
+		if !credentialed {
+			log.Printf("warning: Kafka Connect at %s requires authentication; credentials are only sent to the hosts of KAFKA_CONNECT_URL and its KAFKA_CONNECT_WORKERS", req.URL.Host)
+		} else if kerberos != nil {
+			log.Printf("warning: Kafka Connect at %s rejected the Kerberos ticket of %s for %s; check that the service principal exists and KAFKA_CONNECT_KERBEROS_SERVICE", req.URL.Host, kerberos.principal, kerberos.servicePrincipal(req))
+		} else if connectUsername == "" {
+			log.Printf("warning: Kafka Connect at %s requires authentication; set KAFKA_CONNECT_USERNAME and KAFKA_CONNECT_PASSWORD", req.URL.Host)
+		} else {
+			log.Printf("warning: Kafka Connect at %s rejected the credentials for user %q; check KAFKA_CONNECT_USERNAME and KAFKA_CONNECT_PASSWORD", req.URL.Host, connectUsername)
+		}
+	}
+	return resp, nil
+}
+
+// credentialedHost reports whether the Connect credentials may be sent to host: the
+// host of KAFKA_CONNECT_URL or of one of the workers of the default cluster.
+func credentialedHost(host string) bool {
+	for _, raw := range append([]string{connectURL}, clusterWorkers[alertMetadataCluster]...) {
+		if u, err := url.Parse(raw); err == nil && strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// newConnectClient returns an HTTP client for Kafka Connect using the shared transport.
+// A zero timeout leaves the deadline to the request context.
+func newConnectClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: connectTransport}
+}
+
+// upstreamPolicy configures retries and the circuit breaker for Kafka Connect calls.
+type upstreamPolicy struct {
+	Retries          int
+	Backoff          time.Duration
+	MaxBackoff       time.Duration
+	BreakerThreshold int // 0 disables the circuit breaker
+	BreakerCooldown  time.Duration
+}
+
+var defaultUpstreamPolicy = upstreamPolicy{
+	Retries:          2,
+	Backoff:          100 * time.Millisecond,
+	MaxBackoff:       2 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// loadUpstreamPolicy parses the UPSTREAM_* and CIRCUIT_BREAKER_* environment variables.
+func loadUpstreamPolicy() (upstreamPolicy, error) {
+	var policy upstreamPolicy
+	var err error
+	if policy.Retries, err = strconv.Atoi(upstreamRetries); err != nil || policy.Retries < 0 {
+		return policy, &configError{name: "UPSTREAM_RETRIES", value: upstreamRetries}
+	}
+	if policy.Backoff, err = time.ParseDuration(upstreamRetryBackoff); err != nil || policy.Backoff <= 0 {
+		return policy, &configError{name: "UPSTREAM_RETRY_BACKOFF", value: upstreamRetryBackoff}
+	}
+	if policy.MaxBackoff, err = time.ParseDuration(upstreamRetryMaxBackoff); err != nil || policy.MaxBackoff < policy.Backoff {
+		return policy, &configError{name: "UPSTREAM_RETRY_MAX_BACKOFF", value: upstreamRetryMaxBackoff}
+	}
+	if policy.BreakerThreshold, err = strconv.Atoi(circuitBreakerThreshold); err != nil || policy.BreakerThreshold < 0 {
+		return policy, &configError{name: "CIRCUIT_BREAKER_THRESHOLD", value: circuitBreakerThreshold}
+	}
+	if policy.BreakerCooldown, err = parseWindow(circuitBreakerCooldown, 30*time.Second); err != nil {
+		return policy, &configError{name: "CIRCUIT_BREAKER_COOLDOWN", value: circuitBreakerCooldown}
+	}
+	return policy, nil
+}
+
+// circuitOpenError is returned without contacting Kafka Connect while the circuit for
+// its host is open.
+type circuitOpenError struct {
+	host       string
+	retryAfter time.Duration
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("kafka connect at %s is unreachable; not retrying for %s", e.host, e.retryAfter.Round(time.Second))
+}
+
+// circuit tracks consecutive failures of one Connect host.
+type circuit struct {
+	failures int
+	openedAt time.Time
+	probing  bool // a half-open trial request is in flight
+}
+
+// resilientTransport retries idempotent requests and trips a per-host circuit breaker.
+type resilientTransport struct {
+	base http.RoundTripper
+	now  func() time.Time
+
+	mu       sync.Mutex
+	policy   upstreamPolicy
+	circuits map[string]*circuit
+}
+
+func newResilientTransport(base http.RoundTripper, policy upstreamPolicy, now func() time.Time) *resilientTransport {
+	return &resilientTransport{base: base, now: now, policy: policy, circuits: make(map[string]*circuit)}
+}
+
+func (t *resilientTransport) setPolicy(policy upstreamPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.policy = policy
+	t.circuits = make(map[string]*circuit)
+}
+
+// allow reports whether a request to host may be sent. Once the cooldown has passed a
+// single trial request is let through; its outcome closes or re-opens the circuit.
+func (t *resilientTransport) allow(host string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.circuits[host]
+	if t.policy.BreakerThreshold == 0 || c == nil || c.failures < t.policy.BreakerThreshold {
+		return nil
+	}
+	remaining := c.openedAt.Add(t.policy.BreakerCooldown).Sub(t.now())
+	if remaining > 0 || c.probing {
+		if remaining <= 0 {
+			remaining = t.policy.BreakerCooldown
+		}
+		return &circuitOpenError{host: host, retryAfter: remaining}
+	}
+	c.probing = true
+	return nil
+}
+
+func (t *resilientTransport) record(host string, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.policy.BreakerThreshold == 0 {
+		return
+	}
+	c := t.circuits[host]
+	if c == nil {
+		c = &circuit{}
+		t.circuits[host] = c
+	}
+	wasOpen := c.failures >= t.policy.BreakerThreshold
+	c.probing = false
+	if !failed {
+		if wasOpen {
+			log.Printf("kafka connect at %s is reachable again; circuit closed", host)
+		}
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= t.policy.BreakerThreshold {
+		if !wasOpen {
+			log.Printf("kafka connect at %s failed %d times in a row; circuit open for %s", host, c.failures, t.policy.BreakerCooldown)
+		}
+		c.openedAt = t.now()
+	}
+}
+
+// release ends a trial request without an outcome.
+func (t *resilientTransport) release(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c := t.circuits[host]; c != nil {
+		c.probing = false
+	}
+}
+
+// upstreamFailure reports whether an attempt counts against the circuit and may be
+// retried: network errors and gateway responses.
+func upstreamFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+	return false
+}
+
+// backoff returns the delay before retry n (starting at 1): the base delay doubled per
+// retry, capped, with jitter over the upper half so concurrent callers spread out.
+func (p upstreamPolicy) backoff(n int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < n && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	policy := t.policy
+	t.mu.Unlock()
+
+	host := req.URL.Host
+	attempts := 1
+	if retryable(req) {
+		attempts += policy.Retries
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := t.allow(host); err != nil {
+			return nil, err
+		}
+
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil && errors.Is(err, context.Canceled) {
+			// The caller gave up; that says nothing about Connect.
+			t.release(host)
+			return nil, err
+		}
+		failed := upstreamFailure(resp, err)
+		t.record(host, failed)
+		if !failed || attempt >= attempts {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// writeConnectUnavailable reports an unreachable Kafka Connect. While the circuit is
+// open, or the calls to Connect are at their limit, the response says so and carries
+// Retry-After; a timed out call answers 504.
+func writeConnectUnavailable(w http.ResponseWriter, err error) {
+	var timedOut *upstreamTimeoutError
+	if errors.As(err, &timedOut) {
+		writeUpstreamTimeout(w, timedOut)
+		return
+	}
+	var open *circuitOpenError
+	if errors.As(err, &open) {
+		w.Header().Set("Retry-After", strconv.Itoa(int((open.retryAfter+time.Second-1)/time.Second)))
+		writeJSONError(w, http.StatusServiceUnavailable, "connect_unreachable", open.Error())
+		return
+	}
+	var busy *upstreamBusyError
+	if errors.As(err, &busy) {
+		w.Header().Set("Retry-After", strconv.Itoa(int((busy.retryAfter+time.Second-1)/time.Second)))
+		writeJSONError(w, http.StatusServiceUnavailable, "connect_busy", busy.Error())
+		return
+	}
+	writeJSONError(w, http.StatusServiceUnavailable, "connect_unavailable", err.Error())
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TestMain disables retries and the circuit breaker of the shared Connect transport:
+// tests run in one process, and failures against one test's unreachable server would
+// otherwise trip the circuit for the next. The resilience tests use their own transport.
+func TestMain(m *testing.M) {
+	connectResilience.setPolicy(upstreamPolicy{Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	os.Exit(m.Run())
+}
+
+func withConnectCredentials(t *testing.T, username, password string) {
+	t.Helper()
+	originalUser, originalPass := connectUsername, connectPassword
+	connectUsername, connectPassword = username, password
+	t.Cleanup(func() { connectUsername, connectPassword = originalUser, originalPass })
+}
+
+func newBasicAuthServer(t *testing.T, seen *[]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "connect" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		*seen = append(*seen, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/connectors":
+			w.Write([]byte(`["alpha"]`))
+		case "/connectors/alpha/status":
+			w.Write([]byte(`{"name":"alpha","connector":{"state":"RUNNING"},"tasks":[]}`))
+		default:
+			w.Write([]byte(`{"version":"3.6.0"}`))
+		}
+	}))
+}
+
+func TestUpstreamRequestsCarryBasicAuth(t *testing.T) {
+	var seen []string
+	server := newBasicAuthServer(t, &seen)
+	defer server.Close()
+
+	restore := withTestConnectURL(t, server)
+	defer restore()
+	withConnectCredentials(t, "connect", "s3cret")
+
+	if _, err := fetchFromKafkaConnect("connectors"); err != nil {
+		t.Fatalf("fetchFromKafkaConnect failed with credentials: %v", err)
+	}
+
+	if _, err := fetchMonitoringSummary(context.Background(), newConnectClient(0), server.URL); err != nil {
+		t.Fatalf("fetchMonitoringSummary failed with credentials: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors", nil)
+	req.Header.Set("Authorization", "Bearer browser-token")
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	proxyHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected proxied request to authenticate, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/default/cluster/actions/restart", bytes.NewBufferString(`{}`))
+	req = mux.SetURLVars(req, map[string]string{"cluster": "default", "action": "restart"})
+	rr = httptest.NewRecorder()
+	clusterActionHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected cluster action to authenticate, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	healthHandler(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected health check to authenticate, got %d", rr.Code)
+	}
+
+	if len(seen) < 6 {
+		t.Fatalf("expected all upstream calls to be authenticated, saw %v", seen)
+	}
+}
+
+func TestUpstreamCredentialsStayWithDefaultCluster(t *testing.T) {
+	var seen []string
+	server := newBasicAuthServer(t, &seen)
+	defer server.Close()
+	var leaked []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			leaked = append(leaked, auth)
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer other.Close()
+
+	defer withTestConnectURL(t, server)()
+	withConnectCredentials(t, "connect", "s3cret")
+	withTestClusterURLs(t, map[string]string{"partner": other.URL})
+
+	for _, cluster := range []string{"default", "partner"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/"+cluster+"/connectors", nil)
+		req = mux.SetURLVars(req, map[string]string{"cluster": cluster, "path": "connectors"})
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", cluster, rr.Code)
+		}
+	}
+	if len(seen) != 1 {
+		t.Fatalf("expected the default cluster to be authenticated, saw %v", seen)
+	}
+	if len(leaked) != 0 {
+		t.Fatalf("expected no credentials to reach another cluster's host, got %v", leaked)
+	}
+}
+
+func TestHealthHandlerReportsRejectedCredentials(t *testing.T) {
+	var seen []string
+	server := newBasicAuthServer(t, &seen)
+	defer server.Close()
+
+	restore := withTestConnectURL(t, server)
+	defer restore()
+	withConnectCredentials(t, "connect", "wrong")
+
+	rr := httptest.NewRecorder()
+	healthHandler(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for rejected credentials, got %d", rr.Code)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte("KAFKA_CONNECT_USERNAME")) {
+		t.Fatalf("expected actionable hint in response, got %s", rr.Body.String())
+	}
+}
+
+// scriptedTransport replays a fixed sequence of upstream outcomes.
+type scriptedTransport struct {
+	outcomes []int // HTTP status, or 0 for a network error
+	calls    int
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	outcome := s.outcomes[len(s.outcomes)-1]
+	if s.calls < len(s.outcomes) {
+		outcome = s.outcomes[s.calls]
+	}
+	s.calls++
+	if outcome == 0 {
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{StatusCode: outcome, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+func TestResilientTransportRetriesIdempotentRequests(t *testing.T) {
+	base := &scriptedTransport{outcomes: []int{0, http.StatusServiceUnavailable, http.StatusOK}}
+	transport := newResilientTransport(base, upstreamPolicy{Retries: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}, time.Now)
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get("http://connect:8083/connectors")
+	if err != nil || resp.StatusCode != http.StatusOK || base.calls != 3 {
+		t.Fatalf("expected success on the third attempt, got %v %v after %d calls", resp, err, base.calls)
+	}
+
+	base = &scriptedTransport{outcomes: []int{0, http.StatusOK}}
+	transport = newResilientTransport(base, upstreamPolicy{Retries: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}, time.Now)
+	client = &http.Client{Transport: transport}
+	if _, err := client.Post("http://connect:8083/connectors", "application/json", strings.NewReader("{}")); err == nil || base.calls != 1 {
+		t.Fatalf("expected POST not to be retried, got %v after %d calls", err, base.calls)
+	}
+}
+
+func TestResilientTransportCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	base := &scriptedTransport{outcomes: []int{0, 0, 0, http.StatusOK}}
+	transport := newResilientTransport(base, upstreamPolicy{Backoff: time.Millisecond, MaxBackoff: time.Millisecond, BreakerThreshold: 3, BreakerCooldown: 30 * time.Second}, func() time.Time { return now })
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get("http://connect:8083/connectors"); err == nil {
+			t.Fatalf("expected failure %d", i+1)
+		}
+	}
+
+	_, err := client.Get("http://connect:8083/connectors")
+	var open *circuitOpenError
+	if !errors.As(err, &open) || base.calls != 3 {
+		t.Fatalf("expected the open circuit to fail fast, got %v after %d calls", err, base.calls)
+	}
+	if _, err := client.Get("http://other:8083/connectors"); errors.As(err, &open) {
+		t.Fatalf("expected circuits to be tracked per host")
+	}
+
+	now = now.Add(31 * time.Second)
+	resp, err := client.Get("http://connect:8083/connectors")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the trial request after the cooldown to pass, got %v", err)
+	}
+	if err := transport.allow("connect:8083"); err != nil {
+		t.Fatalf("expected a successful trial to close the circuit, got %v", err)
+	}
+}
+
+func TestWriteConnectUnavailableForOpenCircuit(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeConnectUnavailable(rr, &connectUnavailableError{Err: &circuitOpenError{host: "connect:8083", retryAfter: 1500 * time.Millisecond}})
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "2" || !strings.Contains(rr.Body.String(), "connect_unreachable") {
+		t.Fatalf("unexpected response: %d %v %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Calls to each Kafka Connect cluster are limited so a burst of console traffic
+	// cannot take every request thread of the workers. Calls beyond the limit wait in a
+	// short queue; when the queue is full or the wait runs out they fail with 503.
+	upstreamMaxConcurrent = getEnv("UPSTREAM_MAX_CONCURRENT", "20")
+	upstreamMaxQueued     = getEnv("UPSTREAM_MAX_QUEUED", "50")
+	upstreamQueueTimeout  = getEnv("UPSTREAM_QUEUE_TIMEOUT", "5s")
+
+	connectLimiter = newLimitTransport(connectResilience, defaultUpstreamLimits)
+)
+
+// upstreamLimits bounds the concurrent calls to one Connect host.
+type upstreamLimits struct {
+	MaxConcurrent int // 0 disables the limit
+	MaxQueued     int
+	QueueTimeout  time.Duration
+}
+
+var defaultUpstreamLimits = upstreamLimits{MaxConcurrent: 20, MaxQueued: 50, QueueTimeout: 5 * time.Second}
+
+// loadUpstreamLimits parses UPSTREAM_MAX_CONCURRENT, UPSTREAM_MAX_QUEUED and
+// UPSTREAM_QUEUE_TIMEOUT.
+func loadUpstreamLimits() (upstreamLimits, error) {
+	var limits upstreamLimits
+	var err error
+	if limits.MaxConcurrent, err = strconv.Atoi(strings.TrimSpace(upstreamMaxConcurrent)); err != nil || limits.MaxConcurrent < 0 {
+		return limits, &configError{name: "UPSTREAM_MAX_CONCURRENT", value: upstreamMaxConcurrent}
+	}
+	if limits.MaxQueued, err = strconv.Atoi(strings.TrimSpace(upstreamMaxQueued)); err != nil || limits.MaxQueued < 0 {
+		return limits, &configError{name: "UPSTREAM_MAX_QUEUED", value: upstreamMaxQueued}
+	}
+	if limits.QueueTimeout, err = time.ParseDuration(strings.TrimSpace(upstreamQueueTimeout)); err != nil || limits.QueueTimeout <= 0 {
+		return limits, &configError{name: "UPSTREAM_QUEUE_TIMEOUT", value: upstreamQueueTimeout}
+	}
+	return limits, nil
+}
+
+// upstreamBusyError is returned without contacting Kafka Connect when every slot for
+// its host is taken and the call could not queue, or queued for too long.
+type upstreamBusyError struct {
+	host       string
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *upstreamBusyError) Error() string {
+	return fmt.Sprintf("kafka connect at %s is busy: %s", e.host, e.reason)
+}
+
+// hostSlots holds the slots of one Connect host; a call owns a slot while it has a
+// value in the channel.
+type hostSlots struct {
+	slots  chan struct{}
+	queued int
+}
+
+// limitTransport caps the calls in flight per Connect host. A call keeps its slot
+// until its response body has been read or closed.
+type limitTransport struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	limits upstreamLimits
+	hosts  map[string]*hostSlots
+}
+
+func newLimitTransport(base http.RoundTripper, limits upstreamLimits) *limitTransport {
+	return &limitTransport{base: base, limits: limits, hosts: make(map[string]*hostSlots)}
+}
+
+// setLimits replaces the limits. Calls holding a slot finish against the old ones.
+func (t *limitTransport) setLimits(limits upstreamLimits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limits = limits
+	t.hosts = make(map[string]*hostSlots)
+}
+
+// acquire takes a slot for host, queueing while the host is at its limit, and returns
+// the function that gives the slot back.
+func (t *limitTransport) acquire(ctx context.Context, host string) (func(), error) {
+	t.mu.Lock()
+	limits := t.limits
+	if limits.MaxConcurrent <= 0 {
+		t.mu.Unlock()
+		return func() {}, nil
+	}
+	h, ok := t.hosts[host]
+	if !ok {
+		h = &hostSlots{slots: make(chan struct{}, limits.MaxConcurrent)}
+		t.hosts[host] = h
+	}
+	var once sync.Once
+	release := func() { once.Do(func() { <-h.slots }) }
+
+	select {
+	case h.slots <- struct{}{}:
+		t.mu.Unlock()
+		return release, nil
+	default:
+	}
+	if h.queued >= limits.MaxQueued {
+		t.mu.Unlock()
+		return nil, &upstreamBusyError{
+			host:       host,
+			reason:     fmt.Sprintf("%d calls in flight and %d queued", limits.MaxConcurrent, limits.MaxQueued),
+			retryAfter: limits.QueueTimeout,
+		}
+	}
+	h.queued++
+	t.mu.Unlock()
+	dequeue := func() {
+		t.mu.Lock()
+		h.queued--
+		t.mu.Unlock()
+	}
+
+	timer := time.NewTimer(limits.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case h.slots <- struct{}{}:
+		dequeue()
+		return release, nil
+	case <-timer.C:
+		dequeue()
+		return nil, &upstreamBusyError{
+			host:       host,
+			reason:     fmt.Sprintf("no free slot within %s", limits.QueueTimeout),
+			retryAfter: limits.QueueTimeout,
+		}
+	case <-ctx.Done():
+		dequeue()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseOnDone{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseOnDone gives the slot back once the body has been read to the end or closed,
+// whichever comes first.
+type releaseOnDone struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseOnDone) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releaseOnDone) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatedTransport answers once a value arrives on gate.
+type gatedTransport struct {
+	gate chan struct{}
+}
+
+func (t *gatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-t.gate
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("[]")), Request: req}, nil
+}
+
+// slotUsage returns the calls holding a slot for host and the calls queued for one.
+func (t *limitTransport) slotUsage(host string) (inFlight, queued int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h := t.hosts[host]; h != nil {
+		return len(h.slots), h.queued
+	}
+	return 0, 0
+}
+
+func TestLimitTransportQueuesAndRejects(t *testing.T) {
+	base := &gatedTransport{gate: make(chan struct{})}
+	transport := newLimitTransport(base, upstreamLimits{MaxConcurrent: 1, MaxQueued: 1, QueueTimeout: time.Second})
+	client := &http.Client{Transport: transport}
+
+	results := make(chan error, 2)
+	call := func() {
+		resp, err := client.Get("http://connect:8083/connectors")
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+		}
+		results <- err
+	}
+	go call()
+	waitFor(t, func() bool { inFlight, _ := transport.slotUsage("connect:8083"); return inFlight == 1 })
+	go call()
+	waitFor(t, func() bool { _, queued := transport.slotUsage("connect:8083"); return queued == 1 })
+
+	_, err := client.Get("http://connect:8083/connectors")
+	var busy *upstreamBusyError
+	if !errors.As(err, &busy) || !strings.Contains(busy.Error(), "1 calls in flight and 1 queued") {
+		t.Fatalf("expected a full queue to reject the call, got %v", err)
+	}
+	if _, err := (&http.Client{Transport: newLimitTransport(&scriptedTransport{outcomes: []int{http.StatusOK}}, upstreamLimits{MaxConcurrent: 1, QueueTimeout: time.Second})}).Get("http://other:8083/connectors"); err != nil {
+		t.Fatalf("expected hosts to be limited separately, got %v", err)
+	}
+
+	// Reading the first body to the end frees its slot for the queued call.
+	base.gate <- struct{}{}
+	base.gate <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("expected the queued call to go through, got %v", err)
+		}
+	}
+	if n, _ := transport.slotUsage("connect:8083"); n != 0 {
+		t.Fatalf("expected every slot to be released, %d still taken", n)
+	}
+}
+
+func TestLimitTransportQueueTimeout(t *testing.T) {
+	base := &gatedTransport{gate: make(chan struct{})}
+	transport := newLimitTransport(base, upstreamLimits{MaxConcurrent: 1, MaxQueued: 5, QueueTimeout: 20 * time.Millisecond})
+	client := &http.Client{Transport: transport}
+	done := make(chan struct{})
+	go func() {
+		resp, err := client.Get("http://connect:8083/connectors")
+		if err == nil {
+			resp.Body.Close()
+		}
+		close(done)
+	}()
+	waitFor(t, func() bool { inFlight, _ := transport.slotUsage("connect:8083"); return inFlight == 1 })
+
+	_, err := client.Get("http://connect:8083/connectors")
+	var busy *upstreamBusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("expected the queued call to time out, got %v", err)
+	}
+	rr := httptest.NewRecorder()
+	writeConnectUnavailable(rr, &connectUnavailableError{Err: err})
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "1" || !strings.Contains(rr.Body.String(), "connect_busy") {
+		t.Fatalf("unexpected response: %d %v %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+	base.gate <- struct{}{}
+	<-done
+}
+
+func TestLoadUpstreamLimits(t *testing.T) {
+	originals := []string{upstreamMaxConcurrent, upstreamMaxQueued, upstreamQueueTimeout}
+	t.Cleanup(func() {
+		upstreamMaxConcurrent, upstreamMaxQueued, upstreamQueueTimeout = originals[0], originals[1], originals[2]
+	})
+	upstreamMaxConcurrent, upstreamMaxQueued, upstreamQueueTimeout = "0", "0", "2s"
+	if limits, err := loadUpstreamLimits(); err != nil || limits.MaxConcurrent != 0 || limits.QueueTimeout != 2*time.Second {
+		t.Fatalf("unexpected limits %+v %v", limits, err)
+	}
+	upstreamQueueTimeout = "0s"
+	if _, err := loadUpstreamLimits(); err == nil || !strings.Contains(err.Error(), "UPSTREAM_QUEUE_TIMEOUT") {
+		t.Fatalf("expected an invalid timeout to be rejected, got %v", err)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
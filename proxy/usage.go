@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	usageStoreFile     = "usage.json"
+	usageRetentionDays = 366
+	usageDateLayout    = "2006-01-02"
+)
+
+var usageStats = newUsageTracker(time.Now)
+
+// usageDay holds the anonymous counters collected for a single UTC day.
+type usageDay struct {
+	Date             string          `json:"date"`
+	APICalls         int             `json:"apiCalls"`
+	ActiveConnectors int             `json:"activeConnectors"`
+	Users            map[string]bool `json:"users"` // keyed by hashed user name
+}
+
+// UsageDaySummary is the public view of a usage day; user identities are reduced to a count.
+type UsageDaySummary struct {
+	Date             string `json:"date"`
+	APICalls         int    `json:"apiCalls"`
+	ActiveConnectors int    `json:"activeConnectors"`
+	DistinctUsers    int    `json:"distinctUsers"`
+}
+
+// UsageReport is returned by GET /api/admin/usage.
+type UsageReport struct {
+	Window string            `json:"window"`
+	From   string            `json:"from"`
+	To     string            `json:"to"`
+	Days   []UsageDaySummary `json:"days"`
+	Totals struct {
+		APICalls             int `json:"apiCalls"`
+		PeakActiveConnectors int `json:"peakActiveConnectors"`
+		DistinctUsers        int `json:"distinctUsers"`
+	} `json:"totals"`
+}
+
+type usageTracker struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	days  map[string]*usageDay
+	dirty bool
+}
+
+func newUsageTracker(now func() time.Time) *usageTracker {
+	return &usageTracker{now: now, days: make(map[string]*usageDay)}
+}
+
+func hashUser(user string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(user)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// today returns the bucket for the current UTC day. Callers must hold t.mu.
+func (t *usageTracker) today() *usageDay {
+	key := t.now().UTC().Format(usageDateLayout)
+	day, ok := t.days[key]
+	if !ok {
+		day = &usageDay{Date: key, Users: make(map[string]bool)}
+		t.days[key] = day
+		t.pruneLocked()
+	}
+	return day
+}
+
+func (t *usageTracker) pruneLocked() {
+	cutoff := t.now().UTC().AddDate(0, 0, -usageRetentionDays).Format(usageDateLayout)
+	for key := range t.days {
+		if key < cutoff {
+			delete(t.days, key)
+		}
+	}
+}
+
+// recordCall counts one API call made by user.
+func (t *usageTracker) recordCall(user string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	day := t.today()
+	day.APICalls++
+	day.Users[hashUser(user)] = true
+	t.dirty = true
+}
+
+// recordActiveConnectors keeps the highest connector count observed during the day.
+func (t *usageTracker) recordActiveConnectors(count int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	day := t.today()
+	if count > day.ActiveConnectors {
+		day.ActiveConnectors = count
+		t.dirty = true
+	}
+}
+
+// report summarises the days that fall inside window, oldest first.
+func (t *usageTracker) report(window time.Duration) UsageReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now().UTC()
+	days := int(window / (24 * time.Hour))
+	if days < 1 {
+		days = 1
+	}
+	from := now.AddDate(0, 0, -(days - 1)).Format(usageDateLayout)
+	to := now.Format(usageDateLayout)
+
+	report := UsageReport{From: from, To: to, Days: []UsageDaySummary{}}
+	users := make(map[string]bool)
+
+	keys := make([]string, 0, len(t.days))
+	for key := range t.days {
+		if key >= from && key <= to {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		day := t.days[key]
+		report.Days = append(report.Days, UsageDaySummary{
+			Date:             day.Date,
+			APICalls:         day.APICalls,
+			ActiveConnectors: day.ActiveConnectors,
+			DistinctUsers:    len(day.Users),
+		})
+		report.Totals.APICalls += day.APICalls
+		if day.ActiveConnectors > report.Totals.PeakActiveConnectors {
+			report.Totals.PeakActiveConnectors = day.ActiveConnectors
+		}
+		for user := range day.Users {
+			users[user] = true
+		}
+	}
+	report.Totals.DistinctUsers = len(users)
+
+	return report
+}
+
+func (t *usageTracker) load() error {
+	var days []*usageDay
+	if err := loadJSON(usageStoreFile, &days); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, day := range days {
+		if day.Users == nil {
+			day.Users = make(map[string]bool)
+		}
+		t.days[day.Date] = day
+	}
+	t.pruneLocked()
+	return nil
+}
+
+func (t *usageTracker) flush() error {
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	days := make([]usageDay, 0, len(t.days))
+	for _, day := range t.days {
+		users := make(map[string]bool, len(day.Users))
+		for user := range day.Users {
+			users[user] = true
+		}
+		copied := *day
+		copied.Users = users
+		days = append(days, copied)
+	}
+	t.dirty = false
+	t.mu.Unlock()
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return saveJSON(usageStoreFile, days)
+}
+
+// run periodically persists the counters until stop is closed.
+func (t *usageTracker) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.flush(); err != nil {
+				log.Printf("usage: failed to persist statistics: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// usageMiddleware counts every API request routed through the proxy.
+func usageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			usageStats.recordCall(requestUser(r))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// usageHandler returns anonymous per-day usage counters for capacity planning.
+func usageHandler(w http.ResponseWriter, r *http.Request) {
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = "30d"
+	}
+
+	window, err := parseWindow(windowParam, 30*24*time.Hour)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_window", err.Error())
+		return
+	}
+	if window > usageRetentionDays*24*time.Hour {
+		window = usageRetentionDays * 24 * time.Hour
+	}
+
+	report := usageStats.report(window)
+	report.Window = windowParam
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":    time.Hour,
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"15m": 15 * time.Minute,
+	}
+	for input, expected := range tests {
+		got, err := parseWindow(input, time.Hour)
+		if err != nil {
+			t.Fatalf("parseWindow(%q) returned error: %v", input, err)
+		}
+		if got != expected {
+			t.Fatalf("parseWindow(%q) = %v, want %v", input, got, expected)
+		}
+	}
+
+	for _, input := range []string{"abc", "-1d", "0h", "xd"} {
+		if _, err := parseWindow(input, time.Hour); err == nil {
+			t.Fatalf("expected error for window %q", input)
+		}
+	}
+}
+
+func TestUsageTrackerReport(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tracker := newUsageTracker(func() time.Time { return now })
+
+	tracker.recordCall("alice")
+	tracker.recordCall("bob")
+	tracker.recordActiveConnectors(4)
+
+	now = now.AddDate(0, 0, 1)
+	tracker.recordCall("Alice")
+	tracker.recordActiveConnectors(7)
+	tracker.recordActiveConnectors(5)
+
+	report := tracker.report(30 * 24 * time.Hour)
+	if len(report.Days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(report.Days))
+	}
+	if report.Days[0].Date != "2024-03-10" || report.Days[0].DistinctUsers != 2 {
+		t.Fatalf("unexpected first day: %+v", report.Days[0])
+	}
+	if report.Days[1].ActiveConnectors != 7 {
+		t.Fatalf("expected peak connectors to be kept, got %d", report.Days[1].ActiveConnectors)
+	}
+	if report.Totals.APICalls != 3 || report.Totals.DistinctUsers != 2 || report.Totals.PeakActiveConnectors != 7 {
+		t.Fatalf("unexpected totals: %+v", report.Totals)
+	}
+
+	oneDay := tracker.report(24 * time.Hour)
+	if len(oneDay.Days) != 1 || oneDay.Days[0].Date != "2024-03-11" {
+		t.Fatalf("expected window to exclude older days, got %+v", oneDay.Days)
+	}
+}
+
+func TestUsageTrackerPersistence(t *testing.T) {
+	original := dataDir
+	dataDir = t.TempDir()
+	t.Cleanup(func() { dataDir = original })
+
+	tracker := newUsageTracker(time.Now)
+	tracker.recordCall("alice")
+	if err := tracker.flush(); err != nil {
+		t.Fatalf("flush returned error: %v", err)
+	}
+
+	restored := newUsageTracker(time.Now)
+	if err := restored.load(); err != nil {
+		t.Fatalf("load returned error: %v", err)
+	}
+	report := restored.report(24 * time.Hour)
+	if report.Totals.APICalls != 1 || report.Totals.DistinctUsers != 1 {
+		t.Fatalf("expected persisted counters, got %+v", report.Totals)
+	}
+}
+
+func TestUsageMiddlewareAndHandler(t *testing.T) {
+	original := usageStats
+	usageStats = newUsageTracker(time.Now)
+	t.Cleanup(func() { usageStats = original })
+
+	handler := usageMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/api/default/connectors", nil)
+	req.Header.Set("X-Forwarded-User", "carol")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	rr := httptest.NewRecorder()
+	usageHandler(rr, httptest.NewRequest(http.MethodGet, "/api/admin/usage?window=7d", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var report UsageReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode usage report: %v", err)
+	}
+	if report.Window != "7d" || report.Totals.APICalls != 1 || report.Totals.DistinctUsers != 1 {
+		t.Fatalf("unexpected usage report: %+v", report)
+	}
+
+	rr = httptest.NewRecorder()
+	usageHandler(rr, httptest.NewRequest(http.MethodGet, "/api/admin/usage?window=bogus", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid window, got %d", rr.Code)
+	}
+}
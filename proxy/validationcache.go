@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Validating a config makes Connect load the plugin and, for connectors such as JDBC,
+// reach the external system, which can take 10s or more. Wizard-driven UIs validate
+// on every change, so identical validations of a plugin are answered from the shared
+// cache for VALIDATION_CACHE_TTL.
+var (
+	validationCacheTTL = getEnv("VALIDATION_CACHE_TTL", "30s")
+
+	validationCache = newResponseCache(30*time.Second, sharedCache)
+)
+
+const validationCacheNamespace = "validation|"
+
+// validationCacheKey returns the cache key of a proxied
+// PUT /connector-plugins/{plugin}/config/validate, or "" when the call must reach
+// Connect: the cache is off, the client bypassed it with ?cache=false or
+// Cache-Control: no-cache, or the body is not a config object. Configs holding secret
+// references are not cached either, so resolved secrets never land in the cache. The
+// body is left for the caller to read.
+func validationCacheKey(r *http.Request, upstream *url.URL) string {
+	segments := clusterPathSegments(r.URL.Path)
+	if validationCache.ttl <= 0 || r.Method != http.MethodPut || len(segments) != 4 ||
+		segments[0] != "connector-plugins" || segments[2] != "config" || segments[3] != "validate" {
+		return ""
+	}
+	if r.URL.Query().Get("cache") == "false" || strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+		return ""
+	}
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	hash, ok := validationConfigHash(body)
+	if !ok {
+		return ""
+	}
+	class, err := url.PathUnescape(segments[1])
+	if err != nil {
+		return ""
+	}
+	return validationCacheNamespace + upstream.Scheme + "://" + upstream.Host + "|" + class + "|" + hash
+}
+
+// validationConfigHash hashes a config object independent of key order and of whether
+// values were sent as JSON strings or numbers and booleans, which Connect reads alike.
+func validationConfigHash(body []byte) (string, bool) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(body, &config); err != nil || config == nil {
+		return "", false
+	}
+	normalized := make(map[string]string, len(config))
+	for key, value := range config {
+		text, ok := value.(string)
+		if !ok {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return "", false
+			}
+			text = string(encoded)
+		}
+		if secretPlaceholderPattern.MatchString(text) {
+			return "", false
+		}
+		normalized[key] = text
+	}
+	encoded, err := json.Marshal(normalized) // map keys are marshalled in sorted order
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), true
+}
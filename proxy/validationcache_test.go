@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mcnabb998/kconnect-console/proxy/testutils"
+)
+
+func TestValidationConfigHash(t *testing.T) {
+	first, ok := validationConfigHash([]byte(`{"connector.class":"JdbcSink","tasks.max":2,"auto.create":true}`))
+	if !ok {
+		t.Fatal("expected a config object to be hashed")
+	}
+	if second, _ := validationConfigHash([]byte(`{"auto.create":"true","tasks.max":"2","connector.class":"JdbcSink"}`)); second != first {
+		t.Fatal("expected key order and JSON scalar types not to matter")
+	}
+	if third, _ := validationConfigHash([]byte(`{"connector.class":"JdbcSink","tasks.max":3,"auto.create":true}`)); third == first {
+		t.Fatal("expected a changed value to change the hash")
+	}
+	if _, ok := validationConfigHash([]byte(`{"connection.password":"${vault:secret/db#password}"}`)); ok {
+		t.Fatal("expected configs with secret references not to be cached")
+	}
+	if _, ok := validationConfigHash([]byte(`["not", "a", "config"]`)); ok {
+		t.Fatal("expected other bodies not to be cached")
+	}
+}
+
+func TestProxyHandlerCachesValidations(t *testing.T) {
+	server := testutils.NewConnectServer(map[string]testutils.Response{
+		"PUT /connector-plugins/JdbcSink/config/validate": {
+			Body:    map[string]interface{}{"name": "JdbcSink", "error_count": 0, "groups": []string{}, "configs": []interface{}{}},
+			Headers: map[string]string{"Content-Type": "application/json"},
+		},
+	})
+	defer server.Close()
+
+	originalURL, originalCache := connectURL, validationCache
+	connectURL = server.URL()
+	validationCache = newResponseCache(time.Minute, newMemoryCache(time.Now))
+	t.Cleanup(func() { connectURL, validationCache = originalURL, originalCache })
+
+	validate := func(query, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/default/connector-plugins/JdbcSink/config/validate"+query, strings.NewReader(body))
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req = mux.SetURLVars(req, map[string]string{"cluster": "default", "path": "JdbcSink/config/validate"})
+		rr := httptest.NewRecorder()
+		proxyHandler(rr, req)
+		return rr
+	}
+
+	config := `{"connector.class":"JdbcSink","tasks.max":"1"}`
+	if rr := validate("", config, nil); rr.Code != http.StatusOK || rr.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expected the first validation to reach Connect, got %d %q", rr.Code, rr.Header().Get("X-Cache"))
+	}
+	if rr := validate("", `{"tasks.max":1,"connector.class":"JdbcSink"}`, nil); rr.Header().Get("X-Cache") != "HIT" || !strings.Contains(rr.Body.String(), `"error_count":0`) {
+		t.Fatalf("expected the same config to be answered from the cache, got %q %s", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+	validate("?cache=false", config, nil)
+	validate("", config, http.Header{"Cache-Control": {"no-cache"}})
+	validate("", `{"connector.class":"JdbcSink","tasks.max":"2"}`, nil)
+
+	calls := 0
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut {
+			calls++
+		}
+	}
+	if calls != 4 {
+		t.Fatalf("expected the bypassed and changed validations to reach Connect, got %d calls", calls)
+	}
+}
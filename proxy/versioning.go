@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// currentAPIVersion is the newest versioned prefix, served at /api/{version}/...
+	currentAPIVersion = "v1"
+
+	apiVersionHeader = "X-API-Version"
+)
+
+// supportedAPIVersions lists the versioned prefixes the proxy answers. Unversioned
+// /api/... paths are a compatibility shim that behaves like legacyAPIVersion.
+var (
+	supportedAPIVersions = map[string]bool{"v1": true}
+	legacyAPIVersion     = "v1"
+
+	// legacyAPISunset announces when unversioned paths stop being served (RFC 3339).
+	// Until it is set the shim is not flagged as deprecated.
+	legacyAPISunset = getEnv("LEGACY_API_SUNSET", "")
+)
+
+// apiDeprecation marks a route prefix, within one API version, as deprecated.
+type apiDeprecation struct {
+	Version string
+	Prefix  string // path after /api/{version}/; "*" matches one segment, e.g. "*/summary"
+	Sunset  time.Time
+	Link    string
+}
+
+// apiDeprecations holds route-level deprecations. Add an entry here before a breaking
+// change so clients see Deprecation/Sunset headers for at least one release.
+var apiDeprecations []apiDeprecation
+
+type apiVersionKey struct{}
+
+// apiVersionFromContext returns the API version resolved for the request.
+func apiVersionFromContext(ctx context.Context) string {
+	if version, ok := ctx.Value(apiVersionKey{}).(string); ok {
+		return version
+	}
+	return legacyAPIVersion
+}
+
+// splitAPIVersion strips a supported version segment from an /api/ path. versioned is
+// false for unversioned (legacy) paths, which are returned unchanged.
+func splitAPIVersion(path string) (rest, version string, versioned bool) {
+	trimmed := strings.TrimPrefix(path, "/api/")
+	if trimmed == path {
+		return path, "", false
+	}
+	segment, remainder, _ := strings.Cut(trimmed, "/")
+	if !supportedAPIVersions[segment] {
+		return path, "", false
+	}
+	return "/api/" + remainder, segment, true
+}
+
+// matchesDeprecation reports whether rest (the path after /api/) matches prefix, where
+// a "*" segment in prefix matches any single path segment.
+func matchesDeprecation(prefix, rest string) bool {
+	want := strings.Split(prefix, "/")
+	got := strings.Split(rest, "/")
+	if len(got) < len(want) {
+		return false
+	}
+	for i, segment := range want {
+		if segment != "*" && segment != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// setDeprecationHeaders writes the Deprecation, Sunset and Link headers (RFC 8594).
+func setDeprecationHeaders(h http.Header, sunset time.Time, link string) {
+	h.Set("Deprecation", "true")
+	if !sunset.IsZero() {
+		h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	if link != "" {
+		h.Add("Link", link)
+	}
+}
+
+// parseLegacyAPISunset validates LEGACY_API_SUNSET. A zero time means no sunset.
+func parseLegacyAPISunset(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	sunset, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, &configError{name: "LEGACY_API_SUNSET", value: value}
+	}
+	return sunset, nil
+}
+
+// apiVersionMiddleware maps /api/{version}/... onto the existing /api/... routes and
+// stamps responses with the resolved version. Unversioned paths keep working and point
+// at their versioned successor; once legacySunset is set they are also flagged as
+// deprecated. It wraps the router rather than being registered with router.Use because
+// it must rewrite the path before route matching.
+func apiVersionMiddleware(next http.Handler, legacySunset time.Time) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rest, version, versioned := splitAPIVersion(r.URL.Path)
+		if versioned {
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = rest
+			if r.URL.RawPath != "" {
+				r2.URL.RawPath, _, _ = splitAPIVersion(r.URL.RawPath)
+			}
+			r2.RequestURI = r2.URL.RequestURI()
+			r = r2
+		} else {
+			version = legacyAPIVersion
+			successor := "<" + "/api/" + currentAPIVersion + strings.TrimPrefix(r.URL.Path, "/api") + `>; rel="successor-version"`
+			if legacySunset.IsZero() {
+				w.Header().Add("Link", successor)
+			} else {
+				setDeprecationHeaders(w.Header(), legacySunset, successor)
+			}
+		}
+
+		w.Header().Set(apiVersionHeader, version)
+		routePath := strings.TrimPrefix(r.URL.Path, "/api/")
+		for _, dep := range apiDeprecations {
+			if dep.Version == version && matchesDeprecation(dep.Prefix, routePath) {
+				setDeprecationHeaders(w.Header(), dep.Sunset, dep.Link)
+				break
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+	})
+}
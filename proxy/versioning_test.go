@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func newVersionedRouter(sunset time.Time) http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/{cluster}/connectors/{path:.*}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mux.Vars(r)["cluster"] + " " + r.URL.Path + " " + apiVersionFromContext(r.Context())))
+	})
+	return apiVersionMiddleware(router, sunset)
+}
+
+func TestAPIVersionMiddlewareRoutesVersionedPaths(t *testing.T) {
+	handler := newVersionedRouter(time.Time{})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/default/connectors/alpha/status", nil))
+	if got := rr.Body.String(); got != "default /api/default/connectors/alpha/status v1" {
+		t.Fatalf("unexpected routing: %q", got)
+	}
+	if rr.Header().Get(apiVersionHeader) != "v1" || rr.Header().Get("Deprecation") != "" {
+		t.Fatalf("unexpected headers: %v", rr.Header())
+	}
+
+	// An unknown version segment is treated as a cluster name on the legacy routes.
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v9/connectors/alpha", nil))
+	if got := rr.Body.String(); got != "v9 /api/v9/connectors/alpha v1" {
+		t.Fatalf("unexpected routing for unknown version: %q", got)
+	}
+}
+
+func TestAPIVersionMiddlewareLegacyShim(t *testing.T) {
+	rr := httptest.NewRecorder()
+	newVersionedRouter(time.Time{}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/default/connectors/alpha", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected legacy path to keep working, got %d", rr.Code)
+	}
+	if rr.Header().Get("Link") != `</api/v1/default/connectors/alpha>; rel="successor-version"` {
+		t.Fatalf("unexpected Link header: %q", rr.Header().Get("Link"))
+	}
+	if rr.Header().Get("Deprecation") != "" {
+		t.Fatalf("expected no deprecation without a sunset date")
+	}
+
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	rr = httptest.NewRecorder()
+	newVersionedRouter(sunset).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/default/connectors/alpha", nil))
+	if rr.Header().Get("Deprecation") != "true" || rr.Header().Get("Sunset") != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Fatalf("expected deprecation headers, got %v", rr.Header())
+	}
+}
+
+func TestAPIVersionMiddlewareRouteDeprecations(t *testing.T) {
+	original := apiDeprecations
+	apiDeprecations = []apiDeprecation{{Version: "v1", Prefix: "*/connectors", Link: `</docs/api>; rel="deprecation"`}}
+	t.Cleanup(func() { apiDeprecations = original })
+
+	rr := httptest.NewRecorder()
+	newVersionedRouter(time.Time{}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/prod/connectors/alpha", nil))
+	if rr.Header().Get("Deprecation") != "true" || rr.Header().Get("Link") != `</docs/api>; rel="deprecation"` {
+		t.Fatalf("expected route deprecation headers, got %v", rr.Header())
+	}
+
+	if _, err := parseLegacyAPISunset("next year"); err == nil {
+		t.Fatalf("expected invalid sunset to be rejected")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	webUIIndex    = "index.html"
+	webUINotFound = "404.html"
+
+	// webUIDynamicParam is the name the export renders dynamic routes for; the page reads
+	// the real one from the URL (STATIC_EXPORT_PARAM in web/lib/config.ts).
+	webUIDynamicParam = "_"
+)
+
+// webUIDynamicRoutes are the export's dynamic routes: every path directly below one of
+// them is served the page exported for webUIDynamicParam.
+var webUIDynamicRoutes = []string{"connectors"}
+
+// webUIReservedPrefixes are the paths the UI fallback never answers: an unknown API path
+// keeps returning a 404 rather than the UI's index page.
+var webUIReservedPrefixes = []string{"/api/", "/auth/", "/health/"}
+
+// loadWebUI returns the web UI's static export embedded in the binary, or nil when the
+// binary was built without it (see webui_embed.go).
+func loadWebUI() (fs.FS, error) {
+	files, err := embeddedWebUI()
+	if err != nil || files == nil {
+		return nil, err
+	}
+	for _, page := range append([]string{webUIIndex}, webUIDynamicPages("")...) {
+		if _, err := fs.Stat(files, page); err != nil {
+			return nil, fmt.Errorf("the embedded web UI has no %s; build it with make build-embedded", page)
+		}
+	}
+	return files, nil
+}
+
+// webUIDynamicPages returns the exported pages of the dynamic routes, or their RSC
+// payloads when ext is ".txt".
+func webUIDynamicPages(ext string) []string {
+	if ext != ".txt" {
+		ext = ".html"
+	}
+	pages := make([]string, len(webUIDynamicRoutes))
+	for i, route := range webUIDynamicRoutes {
+		pages[i] = path.Join(route, webUIDynamicParam+ext)
+	}
+	return pages
+}
+
+// webUIDynamicPage returns the exported page that serves name, a path below a dynamic
+// route such as connectors/orders-sink. Client-side navigation fetches the page's RSC
+// payload as name.txt instead.
+func webUIDynamicPage(name string) (string, bool) {
+	dir, base := path.Split(name)
+	for i, route := range webUIDynamicRoutes {
+		if dir == route+"/" && base != "" {
+			return webUIDynamicPages(path.Ext(base))[i], true
+		}
+	}
+	return "", false
+}
+
+func webUIReserved(urlPath string) bool {
+	for _, prefix := range webUIReservedPrefixes {
+		if urlPath+"/" == prefix || strings.HasPrefix(urlPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// webUIHandler serves the static export in files. It is installed as the router's
+// NotFoundHandler, so every API route keeps precedence. A path is served from the file
+// it names, its .html page or its directory's index.html; paths below a dynamic route
+// that match none of them get the route's exported page, and anything else the export's
+// 404 page.
+func webUIHandler(files fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if webUIReserved(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "the web UI only serves GET and HEAD requests")
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		candidates := []string{webUIIndex}
+		if name != "" {
+			candidates = []string{name, name + ".html", path.Join(name, webUIIndex)}
+			if page, ok := webUIDynamicPage(name); ok {
+				candidates = append(candidates, page)
+			}
+		}
+		for _, candidate := range candidates {
+			served, err := serveWebUIFile(w, r, files, candidate)
+			if served || err != nil {
+				if err != nil {
+					writeJSONError(w, http.StatusInternalServerError, "web_ui_unavailable", err.Error())
+				}
+				return
+			}
+		}
+		serveWebUINotFound(w, r, files)
+	})
+}
+
+// serveWebUINotFound answers 404 with the export's 404 page, or a plain 404 for assets
+// and exports without one.
+func serveWebUINotFound(w http.ResponseWriter, r *http.Request, files fs.FS) {
+	page, err := fs.ReadFile(files, webUINotFound)
+	if err != nil || path.Ext(r.URL.Path) != "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != http.MethodHead {
+		w.Write(page)
+	}
+}
+
+// serveWebUIFile writes the regular file name from files, reporting false when there is
+// no such file.
+func serveWebUIFile(w http.ResponseWriter, r *http.Request, files fs.FS, name string) (bool, error) {
+	file, err := files.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return false, nil
+	}
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		return false, fmt.Errorf("%s is not seekable", name)
+	}
+
+	// The export's build assets are content-hashed; its pages are not.
+	if strings.HasPrefix(name, "_next/static/") {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return true, nil
+}
@@ -0,0 +1,18 @@
+//go:build embedui
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// webUIExport is the web UI's static export, copied into proxy/webui before building
+// with -tags embedui (make build-embedded does both).
+//
+//go:embed all:webui
+var webUIExport embed.FS
+
+func embeddedWebUI() (fs.FS, error) {
+	return fs.Sub(webUIExport, "webui")
+}
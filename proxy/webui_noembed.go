@@ -0,0 +1,10 @@
+//go:build !embedui
+
+package main
+
+import "io/fs"
+
+// embeddedWebUI reports no web UI: the default build serves the API only.
+func embeddedWebUI() (fs.FS, error) {
+	return nil, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWebUIHandler(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":                 {Data: []byte("<html>home</html>")},
+		"connectors.html":            {Data: []byte("<html>connectors</html>")},
+		"settings/index.html":        {Data: []byte("<html>settings</html>")},
+		"connectors/new.html":        {Data: []byte("<html>new connector</html>")},
+		"connectors/_.html":          {Data: []byte("<html>connector detail</html>")},
+		"connectors/_.txt":           {Data: []byte("connector detail payload")},
+		"404.html":                   {Data: []byte("<html>not found</html>")},
+		"_next/static/chunks/app.js": {Data: []byte("console.log('app')")},
+	}
+	router := mux.NewRouter()
+	registerRoutes(router)
+	router.NotFoundHandler = webUIHandler(files)
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{http.MethodGet, "/", http.StatusOK, "home", "no-cache"},
+		{http.MethodGet, "/connectors", http.StatusOK, "connectors", "no-cache"},
+		{http.MethodGet, "/settings/", http.StatusOK, "settings", "no-cache"},
+		{http.MethodGet, "/_next/static/chunks/app.js", http.StatusOK, "console.log", "public, max-age=31536000, immutable"},
+		{http.MethodGet, "/connectors/orders-sink", http.StatusOK, "connector detail", "no-cache"},
+		{http.MethodGet, "/connectors/orders.sink", http.StatusOK, "connector detail", "no-cache"},
+		{http.MethodGet, "/connectors/orders-sink.txt", http.StatusOK, "connector detail payload", "no-cache"},
+		{http.MethodGet, "/connectors/new", http.StatusOK, "new connector", "no-cache"},
+		{http.MethodGet, "/connectors/orders-sink/tasks", http.StatusNotFound, "not found", "no-cache"},
+		{http.MethodGet, "/no-such-page", http.StatusNotFound, "not found", "no-cache"},
+		{http.MethodGet, "/_next/static/chunks/missing.js", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/api/default/no-such-route", http.StatusNotFound, "404 page not found", ""},
+		{http.MethodGet, "/api/openapi.json", http.StatusOK, `"openapi"`, ""},
+		{http.MethodPost, "/api/openapi.json", http.StatusMethodNotAllowed, "", ""},
+		{http.MethodPost, "/settings", http.StatusMethodNotAllowed, "method_not_allowed", ""},
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+		if rr.Code != tc.status || !strings.Contains(rr.Body.String(), tc.body) || rr.Header().Get("Cache-Control") != tc.cacheControl {
+			t.Errorf("%s %s: got %d %q (Cache-Control %q)", tc.method, tc.path, rr.Code, rr.Body.String(), rr.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestWebUIDynamicPage(t *testing.T) {
+	for name, want := range map[string]string{
+		"connectors/orders-sink":     "connectors/_.html",
+		"connectors/orders.sink":     "connectors/_.html",
+		"connectors/orders-sink.txt": "connectors/_.txt",
+		"connectors/orders/tasks":    "",
+		"settings/plugins":           "",
+		"connectors":                 "",
+	} {
+		if page, ok := webUIDynamicPage(name); page != want || ok != (want != "") {
+			t.Errorf("webUIDynamicPage(%q) = %q, %v; want %q", name, page, ok, want)
+		}
+	}
+}
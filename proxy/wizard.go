@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// wizardOtherGroup collects settings a plugin does not put in any group.
+const wizardOtherGroup = "Other"
+
+// WizardStepRequest is the body of POST /api/{cluster}/wizard/next-step: the plugin
+// and the config entered so far. Class may be left out when the config sets
+// connector.class.
+type WizardStepRequest struct {
+	Class  string            `json:"class"`
+	Config map[string]string `json:"config"`
+}
+
+// WizardKey is one setting of a wizard step. Set reports whether the config already
+// has a value for it; values are not echoed back, so secrets never return.
+type WizardKey struct {
+	Name              string   `json:"name"`
+	DisplayName       string   `json:"displayName,omitempty"`
+	Type              string   `json:"type"`
+	Required          bool     `json:"required"`
+	Importance        string   `json:"importance"`
+	Documentation     string   `json:"documentation,omitempty"`
+	DefaultValue      *string  `json:"defaultValue"`
+	RecommendedValues []string `json:"recommendedValues,omitempty"`
+	Set               bool     `json:"set"`
+	Errors            []string `json:"errors,omitempty"`
+}
+
+// WizardStep is returned by POST /api/{cluster}/wizard/next-step. Group is the first
+// group of the plugin's settings with a missing required setting or an invalid value,
+// with all of its visible settings; Complete is true, and Group empty, once Connect
+// accepts the config. Errors holds the validation errors of the settings entered so
+// far, across all groups.
+type WizardStep struct {
+	Class      string              `json:"class"`
+	Complete   bool                `json:"complete"`
+	Group      string              `json:"group,omitempty"`
+	Step       int                 `json:"step,omitempty"`
+	TotalSteps int                 `json:"totalSteps"`
+	Groups     []string            `json:"groups"`
+	Pending    []string            `json:"pending"`
+	Keys       []WizardKey         `json:"keys"`
+	ErrorCount int                 `json:"errorCount"`
+	Errors     map[string][]string `json:"errors"`
+}
+
+// nextWizardStep turns a validation of the partial config into the next step. Settings
+// Connect marks invisible, because another setting disables them, are left out.
+func nextWizardStep(class string, config map[string]string, validation connectConfigValidation) WizardStep {
+	step := WizardStep{Class: class, Groups: []string{}, Pending: []string{}, Keys: []WizardKey{}, Errors: map[string][]string{}}
+
+	groups := map[string][]WizardKey{}
+	order := map[string]int{}
+	for _, setting := range validation.Configs {
+		definition, value := setting.Definition, setting.Value
+		if !value.Visible {
+			continue
+		}
+		group := definition.Group
+		if group == "" {
+			group = wizardOtherGroup
+		}
+		_, set := config[definition.Name]
+		if set && strings.TrimSpace(config[definition.Name]) == "" {
+			set = false
+		}
+		groups[group] = append(groups[group], WizardKey{
+			Name:              definition.Name,
+			DisplayName:       definition.DisplayName,
+			Type:              definition.Type,
+			Required:          definition.Required,
+			Importance:        definition.Importance,
+			Documentation:     definition.Documentation,
+			DefaultValue:      definition.DefaultValue,
+			RecommendedValues: value.RecommendedValues,
+			Set:               set,
+			Errors:            value.Errors,
+		})
+		order[definition.Name] = definition.Order
+		if set && len(value.Errors) > 0 {
+			step.Errors[definition.Name] = value.Errors
+			step.ErrorCount += len(value.Errors)
+		}
+	}
+
+	// Groups follow the plugin's order; any group it does not list comes after, and
+	// ungrouped settings come last.
+	names := append([]string{}, validation.Groups...)
+	var unlisted []string
+	for group := range groups {
+		if group != wizardOtherGroup && !containsString(names, group) {
+			unlisted = append(unlisted, group)
+		}
+	}
+	sort.Strings(unlisted)
+	names = append(append(names, unlisted...), wizardOtherGroup)
+	for _, group := range names {
+		keys := groups[group]
+		if len(keys) == 0 || containsString(step.Groups, group) {
+			continue
+		}
+		step.Groups = append(step.Groups, group)
+		sort.SliceStable(keys, func(i, j int) bool {
+			if order[keys[i].Name] != order[keys[j].Name] {
+				return order[keys[i].Name] < order[keys[j].Name]
+			}
+			return keys[i].Name < keys[j].Name
+		})
+	}
+	step.TotalSteps = len(step.Groups)
+
+	for i, group := range step.Groups {
+		incomplete := false
+		for _, key := range groups[group] {
+			if len(key.Errors) > 0 {
+				incomplete = true
+				break
+			}
+		}
+		if !incomplete {
+			continue
+		}
+		if step.Group == "" {
+			step.Group, step.Step, step.Keys = group, i+1, groups[group]
+		} else {
+			step.Pending = append(step.Pending, group)
+		}
+	}
+	step.Complete = step.Group == ""
+	return step
+}
+
+// wizardNextStepHandler validates a partial connector config and returns the next
+// group of settings to fill in, so a multi-step creation wizard does not need to
+// re-implement Connect's config model. Nothing is created.
+func wizardNextStepHandler(w http.ResponseWriter, r *http.Request) {
+	var req WizardStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", `request body must be {"class": "...", "config": {...}} with string config values`)
+		return
+	}
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+	class := strings.TrimSpace(req.Class)
+	if class == "" {
+		class = strings.TrimSpace(req.Config["connector.class"])
+	}
+	if class == "" {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "class or config.connector.class is required")
+		return
+	}
+	req.Config["connector.class"] = class
+
+	// Placeholders are resolved so Connect validates the values it would receive.
+	resolved := make(map[string]interface{}, len(req.Config))
+	for key, value := range req.Config {
+		resolved[key] = value
+	}
+	if _, err := connectorSecrets.resolveConfig(r.Context(), resolved); err != nil {
+		writeSecretResolutionError(w, err)
+		return
+	}
+
+	cluster := mux.Vars(r)["cluster"]
+	validation, err := requestConfigValidation(r.Context(), connectClientFor(cluster, routeValidate), connectURLFor(cluster), class, resolved)
+	if err != nil {
+		var unavailable *connectUnavailableError
+		var refused *configValidationError
+		switch {
+		case errors.As(err, &unavailable):
+			writeConnectUnavailable(w, err)
+		case errors.As(err, &refused) && refused.Status < 500:
+			writeJSONError(w, http.StatusBadRequest, "invalid_plugin", err.Error())
+		default:
+			writeJSONError(w, http.StatusBadGateway, "validation_failed", err.Error())
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nextWizardStep(class, req.Config, validation))
+}
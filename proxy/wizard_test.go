@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeJdbcValidation answers a config validation the way Connect does for a small sink
+// plugin: required settings without a value and unknown insert modes are errors, and
+// pk.fields is only visible in upsert mode.
+func fakeJdbcValidation(config map[string]string) string {
+	type setting struct {
+		name, group, typ string
+		required         bool
+		order            int
+		recommended      []string
+	}
+	settings := []setting{
+		{"name", "Common", "STRING", true, 1, nil},
+		{"connector.class", "Common", "STRING", true, 2, nil},
+		{"connection.password", "Connection", "PASSWORD", false, 2, nil},
+		{"connection.url", "Connection", "STRING", true, 1, nil},
+		{"insert.mode", "Writes", "STRING", false, 1, []string{"insert", "upsert"}},
+		{"pk.fields", "Writes", "LIST", false, 2, nil},
+		{"batch.size", "", "INT", false, 1, nil},
+	}
+	var configs []string
+	errorCount := 0
+	for _, s := range settings {
+		var errs []string
+		value, set := config[s.name]
+		if s.required && !set {
+			errs = append(errs, fmt.Sprintf("Missing required configuration %q which has no default value.", s.name))
+		}
+		if s.name == "insert.mode" && set && value != "insert" && value != "upsert" {
+			errs = append(errs, "Invalid value "+value+" for configuration insert.mode")
+		}
+		errorCount += len(errs)
+		visible := s.name != "pk.fields" || config["insert.mode"] == "upsert"
+		definition, _ := json.Marshal(map[string]interface{}{"name": s.name, "type": s.typ, "required": s.required, "default_value": nil, "importance": "HIGH", "group": s.group, "order": s.order, "dependents": []string{}})
+		result, _ := json.Marshal(map[string]interface{}{"name": s.name, "value": nil, "recommended_values": s.recommended, "errors": errs, "visible": visible})
+		configs = append(configs, fmt.Sprintf(`{"definition":%s,"value":%s}`, definition, result))
+	}
+	return fmt.Sprintf(`{"name":"io.example.JdbcSink","error_count":%d,"groups":["Common","Connection","Writes"],"configs":[%s]}`, errorCount, strings.Join(configs, ","))
+}
+
+func TestWizardNextStepHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/connector-plugins/io.example.JdbcSink/config/validate" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error_code":400,"message":"Failed to find any class that implements Connector"}`)
+			return
+		}
+		var config map[string]string
+		json.NewDecoder(r.Body).Decode(&config)
+		io.WriteString(w, fakeJdbcValidation(config))
+	}))
+	defer server.Close()
+	defer withTestConnectURL(t, server)()
+
+	next := func(body string) (*httptest.ResponseRecorder, WizardStep) {
+		t.Helper()
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/default/wizard/next-step", strings.NewReader(body)), map[string]string{"cluster": "default"})
+		rr := httptest.NewRecorder()
+		wizardNextStepHandler(rr, req)
+		var step WizardStep
+		json.Unmarshal(rr.Body.Bytes(), &step)
+		return rr, step
+	}
+	names := func(keys []WizardKey) string {
+		var out []string
+		for _, key := range keys {
+			out = append(out, key.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	rr, step := next(`{"class": "io.example.JdbcSink"}`)
+	if rr.Code != http.StatusOK || step.Group != "Common" || step.Step != 1 || step.TotalSteps != 4 || names(step.Keys) != "name,connector.class" {
+		t.Fatalf("unexpected first step %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Join(step.Groups, ",") != "Common,Connection,Writes,Other" || strings.Join(step.Pending, ",") != "Connection" || step.ErrorCount != 0 {
+		t.Fatalf("unexpected groups %+v", step)
+	}
+	if !step.Keys[1].Set || step.Keys[0].Set || len(step.Keys[0].Errors) != 1 {
+		t.Fatalf("expected the class to be set and the name missing, got %+v", step.Keys)
+	}
+
+	rr, step = next(`{"config": {"connector.class": "io.example.JdbcSink", "name": "orders", "insert.mode": "merge", "connection.password": "hunter2"}}`)
+	if step.Group != "Connection" || step.Step != 2 || names(step.Keys) != "connection.url,connection.password" || strings.Join(step.Pending, ",") != "Writes" {
+		t.Fatalf("unexpected second step %+v", step)
+	}
+	if step.ErrorCount != 1 || len(step.Errors["insert.mode"]) != 1 || strings.Contains(rr.Body.String(), "hunter2") {
+		t.Fatalf("expected the invalid insert mode to be reported so far, got %+v", step.Errors)
+	}
+
+	_, step = next(`{"class": "io.example.JdbcSink", "config": {"name": "orders", "connection.url": "jdbc:postgresql://db/orders", "insert.mode": "merge"}}`)
+	if step.Group != "Writes" || step.Step != 3 || names(step.Keys) != "insert.mode" || step.Keys[0].RecommendedValues[1] != "upsert" {
+		t.Fatalf("expected pk.fields to stay hidden outside upsert mode, got %+v", step)
+	}
+
+	_, step = next(`{"class": "io.example.JdbcSink", "config": {"name": "orders", "connection.url": "jdbc:postgresql://db/orders", "insert.mode": "upsert"}}`)
+	if !step.Complete || step.Group != "" || len(step.Keys) != 0 || len(step.Pending) != 0 {
+		t.Fatalf("expected the config to be complete, got %+v", step)
+	}
+
+	for _, body := range []string{
+		`{"config": {"name": "orders"}}`,
+		`{"config": {"batch.size": 5}}`,
+		`{"class": "io.example.Missing"}`,
+		`not json`,
+	} {
+		if rr, _ := next(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
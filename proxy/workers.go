@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// workerProbeTimeout bounds the request made to each worker's own REST endpoint for its
+// version. Unreachable workers are still listed, without a version.
+var workerProbeTimeout = 2 * time.Second
+
+// WorkerTask is a task placed on a worker.
+type WorkerTask struct {
+	Connector string `json:"connector"`
+	ID        int    `json:"id"`
+	State     string `json:"state"`
+}
+
+// WorkerDetail describes one Connect worker as derived from connector and task placement.
+type WorkerDetail struct {
+	ID             string       `json:"id"`
+	Host           string       `json:"host"`
+	Port           string       `json:"port,omitempty"`
+	Connectors     []string     `json:"connectors"`
+	Tasks          []WorkerTask `json:"tasks"`
+	FailedTasks    int          `json:"failedTasks"`
+	Version        string       `json:"version,omitempty"`
+	Commit         string       `json:"commit,omitempty"`
+	KafkaClusterID string       `json:"kafkaClusterId,omitempty"`
+	Reachable      bool         `json:"reachable"`
+	Error          string       `json:"error,omitempty"`
+}
+
+// WorkersDetail is returned by GET /api/{cluster}/workers/detail. Kafka Connect has no
+// workers listing, so idle workers without connectors or tasks do not appear.
+type WorkersDetail struct {
+	Workers         []WorkerDetail `json:"workers"`
+	UnassignedTasks []WorkerTask   `json:"unassignedTasks"`
+}
+
+// aggregateWorkers groups connector and task placement by worker ID.
+func aggregateWorkers(connectors map[string]expandedConnector) WorkersDetail {
+	byID := make(map[string]*WorkerDetail)
+	worker := func(id string) *WorkerDetail {
+		detail, ok := byID[id]
+		if !ok {
+			detail = &WorkerDetail{ID: id, Host: id, Connectors: []string{}, Tasks: []WorkerTask{}}
+			if host, port, err := net.SplitHostPort(id); err == nil {
+				detail.Host, detail.Port = host, port
+			}
+			byID[id] = detail
+		}
+		return detail
+	}
+
+	result := WorkersDetail{Workers: []WorkerDetail{}, UnassignedTasks: []WorkerTask{}}
+	for name, connector := range connectors {
+		if id := connector.Status.Connector.WorkerID; id != "" {
+			w := worker(id)
+			w.Connectors = append(w.Connectors, name)
+		}
+		for _, task := range connector.Status.Tasks {
+			placed := WorkerTask{Connector: name, ID: task.ID, State: normalizeState(task.State)}
+			if task.WorkerID == "" {
+				result.UnassignedTasks = append(result.UnassignedTasks, placed)
+				continue
+			}
+			w := worker(task.WorkerID)
+			w.Tasks = append(w.Tasks, placed)
+			if placed.State == "failed" {
+				w.FailedTasks++
+			}
+		}
+	}
+
+	for _, detail := range byID {
+		sort.Strings(detail.Connectors)
+		sortWorkerTasks(detail.Tasks)
+		result.Workers = append(result.Workers, *detail)
+	}
+	sort.Slice(result.Workers, func(i, j int) bool { return result.Workers[i].ID < result.Workers[j].ID })
+	sortWorkerTasks(result.UnassignedTasks)
+	return result
+}
+
+func sortWorkerTasks(tasks []WorkerTask) {
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Connector != tasks[j].Connector {
+			return tasks[i].Connector < tasks[j].Connector
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+// workerURL builds the REST URL of a worker from its ID, reusing the scheme of the
+// configured Connect URL since worker IDs carry no scheme.
+func workerURL(baseURL, workerID string) string {
+	scheme := "http"
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Scheme != "" {
+		scheme = parsed.Scheme
+	}
+	return scheme + "://" + workerID + "/"
+}
+
+// probeWorker asks a worker for its version and commit through its root endpoint.
+func probeWorker(ctx context.Context, client *http.Client, baseURL string, detail *WorkerDetail) {
+	ctx, cancel := context.WithTimeout(ctx, workerProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, workerURL(baseURL, detail.ID), nil)
+	if err != nil {
+		detail.Error = err.Error()
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		detail.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		return
+	}
+
+	var info struct {
+		Version        string `json:"version"`
+		Commit         string `json:"commit"`
+		KafkaClusterID string `json:"kafka_cluster_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		detail.Error = fmt.Sprintf("decode worker info: %v", err)
+		return
+	}
+	detail.Reachable = true
+	detail.Version, detail.Commit, detail.KafkaClusterID = info.Version, info.Commit, info.KafkaClusterID
+}
+
+// fetchWorkersDetail derives the worker view of a cluster and probes every worker.
+func fetchWorkersDetail(ctx context.Context, client *http.Client, baseURL string) (WorkersDetail, error) {
+	connectors, err := fetchExpandedConnectorStatuses(ctx, client, baseURL)
+	if err != nil {
+		return WorkersDetail{}, err
+	}
+
+	detail := aggregateWorkers(connectors)
+	var wg sync.WaitGroup
+	for i := range detail.Workers {
+		wg.Add(1)
+		go func(w *WorkerDetail) {
+			defer wg.Done()
+			probeWorker(ctx, client, baseURL, w)
+		}(&detail.Workers[i])
+	}
+	wg.Wait()
+	return detail, nil
+}
+
+// workerSummary flattens the worker view into the key/value workerInfo of /summary.
+func workerSummary(detail WorkersDetail) map[string]interface{} {
+	ids := make([]string, 0, len(detail.Workers))
+	versions := make(map[string]struct{})
+	tasks := len(detail.UnassignedTasks)
+	for _, w := range detail.Workers {
+		ids = append(ids, w.ID)
+		tasks += len(w.Tasks)
+		if w.Version != "" {
+			versions[w.Version] = struct{}{}
+		}
+	}
+	versionList := make([]string, 0, len(versions))
+	for version := range versions {
+		versionList = append(versionList, version)
+	}
+	sort.Strings(versionList)
+
+	info := map[string]interface{}{
+		"workers":          len(detail.Workers),
+		"worker_ids":       strings.Join(ids, ", "),
+		"tasks":            tasks,
+		"unassigned_tasks": len(detail.UnassignedTasks),
+	}
+	if len(versionList) > 0 {
+		info["versions"] = strings.Join(versionList, ", ")
+	}
+	return info
+}
+
+// workersDetailHandler lists the workers of a cluster with the connectors and tasks they
+// run.
+func workersDetailHandler(w http.ResponseWriter, r *http.Request) {
+	detail, err := fetchWorkersDetail(r.Context(), connectClientFor(mux.Vars(r)["cluster"], routeRead), connectURLFor(mux.Vars(r)["cluster"]))
+	if err != nil {
+		var unavailable *connectUnavailableError
+		if errors.As(err, &unavailable) {
+			writeConnectUnavailable(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadGateway, "connectors_fetch_failed", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAggregateWorkers(t *testing.T) {
+	var connectors map[string]expandedConnector
+	fixture := `{
+	  "orders": {"status": {"connector": {"state": "RUNNING", "worker_id": "w1:8083"}, "tasks": [
+	    {"id": 1, "state": "FAILED", "worker_id": "w2:8083"},
+	    {"id": 0, "state": "RUNNING", "worker_id": "w1:8083"}
+	  ]}},
+	  "payments": {"status": {"connector": {"state": "RUNNING", "worker_id": "w2:8083"}, "tasks": [
+	    {"id": 0, "state": "UNASSIGNED", "worker_id": ""}
+	  ]}}
+	}`
+	if err := json.Unmarshal([]byte(fixture), &connectors); err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+
+	detail := aggregateWorkers(connectors)
+	if len(detail.Workers) != 2 || detail.Workers[0].ID != "w1:8083" || detail.Workers[1].ID != "w2:8083" {
+		t.Fatalf("unexpected workers: %+v", detail.Workers)
+	}
+	w1, w2 := detail.Workers[0], detail.Workers[1]
+	if w1.Host != "w1" || w1.Port != "8083" || len(w1.Connectors) != 1 || w1.Connectors[0] != "orders" || len(w1.Tasks) != 1 {
+		t.Fatalf("unexpected w1: %+v", w1)
+	}
+	if len(w2.Connectors) != 1 || w2.Connectors[0] != "payments" || w2.FailedTasks != 1 || w2.Tasks[0].Connector != "orders" {
+		t.Fatalf("unexpected w2: %+v", w2)
+	}
+	if len(detail.UnassignedTasks) != 1 || detail.UnassignedTasks[0].Connector != "payments" {
+		t.Fatalf("expected unassigned task, got %+v", detail.UnassignedTasks)
+	}
+}
+
+func TestWorkersDetailHandlerProbesWorkers(t *testing.T) {
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"3.7.0","commit":"abc123","kafka_cluster_id":"kc-1"}`))
+	}))
+	defer worker.Close()
+	workerID := strings.TrimPrefix(worker.URL, "http://")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"orders": {"status": {"connector": {"state": "RUNNING", "worker_id": %q}, "tasks": [
+		  {"id": 0, "state": "RUNNING", "worker_id": %q},
+		  {"id": 1, "state": "RUNNING", "worker_id": "127.0.0.1:1"}
+		]}}}`, workerID, workerID)
+	}))
+	defer server.Close()
+	restore := withTestConnectURL(t, server)
+	defer restore()
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/default/workers/detail", nil), map[string]string{"cluster": "default"})
+	rr := httptest.NewRecorder()
+	workersDetailHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var detail WorkersDetail
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(detail.Workers) != 2 {
+		t.Fatalf("expected two workers, got %+v", detail.Workers)
+	}
+	for _, w := range detail.Workers {
+		switch w.ID {
+		case workerID:
+			if !w.Reachable || w.Version != "3.7.0" || w.Commit != "abc123" {
+				t.Fatalf("expected probed version, got %+v", w)
+			}
+		default:
+			if w.Reachable || w.Error == "" {
+				t.Fatalf("expected unreachable worker to report an error, got %+v", w)
+			}
+		}
+	}
+}
@@ -1,10 +1,11 @@
 import { act, fireEvent, render, screen, waitFor } from '@testing-library/react';
-import ConnectorDetail from '../app/connectors/[name]/page';
-import { useParams, useRouter, useSearchParams } from 'next/navigation';
+import ConnectorDetail from '../app/connectors/[name]/ConnectorDetail';
+import { useParams, usePathname, useRouter, useSearchParams } from 'next/navigation';
 
 describe('ConnectorDetail page', () => {
   const fetchMock = jest.fn();
   const mockedUseParams = useParams as unknown as jest.Mock;
+  const mockedUsePathname = usePathname as unknown as jest.Mock;
   const mockedUseRouter = useRouter as unknown as jest.Mock;
   const mockedUseSearchParams = useSearchParams as unknown as jest.Mock;
   const originalConfirm = window.confirm;
@@ -131,6 +132,18 @@ describe('ConnectorDetail page', () => {
     setTimeoutSpy.mockRestore();
   });
 
+  it('reads the connector name from the URL on the static export page', async () => {
+    mockedUseParams.mockReturnValue({ name: '_' });
+    mockedUsePathname.mockReturnValue('/connectors/orders-sink');
+    queueSuccessfulFetch({ ...baseStatus, name: 'orders-sink' }, { ...baseConfig, name: 'orders-sink' });
+
+    render(<ConnectorDetail />);
+
+    await waitFor(() => {
+      expect(fetchMock).toHaveBeenCalledWith(expect.stringContaining('/connectors/orders-sink/status'));
+    });
+  });
+
   it('displays an error message when fetching details fails', async () => {
     jest.useFakeTimers();
     const statusFailure = {
@@ -80,6 +80,37 @@ describe('Settings Page', () => {
       });
     });
 
+    it('lists the sections the proxy could not load', async () => {
+      mockFetchSummary.mockResolvedValue({
+        ...mockSummaryData,
+        errors: { plugins: 'unexpected status fetching connector plugins: 500' },
+      });
+
+      render(<Settings />);
+
+      await waitFor(() => {
+        expect(screen.getByRole('alert')).toHaveTextContent('Connector plugins: unexpected status fetching connector plugins: 500');
+      });
+    });
+
+    it('renders the other sections when cluster information could not be loaded', async () => {
+      mockFetchSummary.mockResolvedValue({
+        ...mockSummaryData,
+        clusterInfo: null,
+        connectorPlugins: null,
+        errors: { clusterInfo: 'unexpected status fetching cluster info: 503' },
+      });
+
+      render(<Settings />);
+
+      await waitFor(() => {
+        expect(screen.getByRole('alert')).toHaveTextContent('Cluster information: unexpected status fetching cluster info: 503');
+      });
+      expect(screen.getByText('Cluster information is unavailable.')).toBeInTheDocument();
+      expect(screen.getByText('Total Connectors')).toBeInTheDocument();
+      expect(screen.getByText('5')).toBeInTheDocument();
+    });
+
     it('displays error message when summary fails to load', async () => {
       mockFetchSummary.mockRejectedValue(new Error('Failed to fetch summary'));
 
@@ -17,18 +17,20 @@ interface ConnectorPlugin {
   version: string;
 }
 
+// Sections that could not be loaded from Kafka Connect are null and listed in errors.
 interface Summary {
-  clusterInfo: ClusterInfo;
-  connectorPlugins: ConnectorPlugin[];
-  connectorStats: {
+  clusterInfo?: ClusterInfo | null;
+  connectorPlugins?: ConnectorPlugin[] | null;
+  connectorStats?: {
     total: number;
     running: number;
     failed: number;
     paused: number;
-  };
-  workerInfo: {
+  } | null;
+  workerInfo?: {
     [key: string]: any;
-  };
+  } | null;
+  errors?: Record<string, string>;
 }
 
 export default function SettingsPage() {
@@ -10,29 +10,52 @@ interface ConnectorPlugin {
   version: string;
 }
 
+// Sections that could not be loaded from Kafka Connect are null and listed in errors.
 interface Summary {
-  clusterInfo: ClusterInfo;
-  connectorPlugins?: ConnectorPlugin[];
-  connectorStats: {
+  clusterInfo?: ClusterInfo | null;
+  connectorPlugins?: ConnectorPlugin[] | null;
+  connectorStats?: {
     total: number;
     running: number;
     failed: number;
     paused: number;
-  };
-  workerInfo: {
+  } | null;
+  workerInfo?: {
     [key: string]: any;
-  };
+  } | null;
+  errors?: Record<string, string>;
 }
 
+const sectionLabels: Record<string, string> = {
+  clusterInfo: 'Cluster information',
+  plugins: 'Connector plugins',
+  connectorStats: 'Connector statistics',
+  workers: 'Worker information',
+};
+
 interface CardsProps {
   summary: Summary;
 }
 
 export default function Cards({ summary }: CardsProps) {
-  const { clusterInfo, connectorStats, connectorPlugins = [], workerInfo } = summary;
+  const { clusterInfo, connectorStats, connectorPlugins, workerInfo, errors = {} } = summary;
+  const failedSections = Object.entries(errors);
 
   return (
     <div className="space-y-6">
+      {failedSections.length > 0 && (
+        <div role="alert" className="bg-yellow-50 border border-yellow-200 rounded-lg p-4">
+          <h2 className="text-yellow-800 font-semibold mb-2">Some sections could not be loaded from Kafka Connect</h2>
+          <ul className="text-sm text-yellow-700 space-y-1">
+            {failedSections.map(([section, message]) => (
+              <li key={section}>
+                <strong>{sectionLabels[section] ?? section}:</strong> {message}
+              </li>
+            ))}
+          </ul>
+        </div>
+      )}
+
       {/* KPI Cards */}
       <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-4 gap-4">
         <div className="bg-white rounded-lg border border-gray-200 p-4">
@@ -46,7 +69,7 @@ export default function Cards({ summary }: CardsProps) {
             </div>
             <div className="ml-4">
               <div className="text-sm font-medium text-gray-500">Total Connectors</div>
-              <div className="text-2xl font-bold text-gray-900">{connectorStats.total}</div>
+              <div className="text-2xl font-bold text-gray-900">{connectorStats?.total ?? 0}</div>
             </div>
           </div>
         </div>
@@ -62,7 +85,7 @@ export default function Cards({ summary }: CardsProps) {
             </div>
             <div className="ml-4">
               <div className="text-sm font-medium text-gray-500">Running</div>
-              <div className="text-2xl font-bold text-green-600">{connectorStats.running}</div>
+              <div className="text-2xl font-bold text-green-600">{connectorStats?.running ?? 0}</div>
             </div>
           </div>
         </div>
@@ -78,7 +101,7 @@ export default function Cards({ summary }: CardsProps) {
             </div>
             <div className="ml-4">
               <div className="text-sm font-medium text-gray-500">Failed</div>
-              <div className="text-2xl font-bold text-red-600">{connectorStats.failed}</div>
+              <div className="text-2xl font-bold text-red-600">{connectorStats?.failed ?? 0}</div>
             </div>
           </div>
         </div>
@@ -94,7 +117,7 @@ export default function Cards({ summary }: CardsProps) {
             </div>
             <div className="ml-4">
               <div className="text-sm font-medium text-gray-500">Paused</div>
-              <div className="text-2xl font-bold text-yellow-600">{connectorStats.paused}</div>
+              <div className="text-2xl font-bold text-yellow-600">{connectorStats?.paused ?? 0}</div>
             </div>
           </div>
         </div>
@@ -106,20 +129,24 @@ export default function Cards({ summary }: CardsProps) {
         <div className="bg-white rounded-lg border border-gray-200">
           <div className="px-4 py-5 sm:p-6">
             <h3 className="text-lg font-medium text-gray-900 mb-4">Cluster Information</h3>
-            <dl className="space-y-3">
-              <div>
-                <dt className="text-sm font-medium text-gray-500">Version</dt>
-                <dd className="text-sm text-gray-900">{clusterInfo.version}</dd>
-              </div>
-              <div>
-                <dt className="text-sm font-medium text-gray-500">Commit</dt>
-                <dd className="text-sm text-gray-900 font-mono">{clusterInfo.commit.substring(0, 8)}</dd>
-              </div>
-              <div>
-                <dt className="text-sm font-medium text-gray-500">Kafka Cluster ID</dt>
-                <dd className="text-sm text-gray-900 font-mono">{clusterInfo.kafka_cluster_id}</dd>
-              </div>
-            </dl>
+            {clusterInfo ? (
+              <dl className="space-y-3">
+                <div>
+                  <dt className="text-sm font-medium text-gray-500">Version</dt>
+                  <dd className="text-sm text-gray-900">{clusterInfo.version}</dd>
+                </div>
+                <div>
+                  <dt className="text-sm font-medium text-gray-500">Commit</dt>
+                  <dd className="text-sm text-gray-900 font-mono">{clusterInfo.commit?.substring(0, 8)}</dd>
+                </div>
+                <div>
+                  <dt className="text-sm font-medium text-gray-500">Kafka Cluster ID</dt>
+                  <dd className="text-sm text-gray-900 font-mono">{clusterInfo.kafka_cluster_id}</dd>
+                </div>
+              </dl>
+            ) : (
+              <p className="text-sm text-gray-500">Cluster information is unavailable.</p>
+            )}
           </div>
         </div>
 
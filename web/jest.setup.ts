@@ -12,5 +12,6 @@ jest.mock('next/link', () => {
 jest.mock('next/navigation', () => ({
   useRouter: jest.fn(),
   useParams: jest.fn(),
+  usePathname: jest.fn(),
   useSearchParams: jest.fn()
 }));
@@ -58,8 +58,17 @@ export interface ConnectorConfig {
 }
 
 export interface ApiError {
+  /** Machine-readable code set by the proxy, e.g. `connector_not_found` or `rebalance_in_progress`. */
+  error?: string;
   error_code?: number;
   message: string;
+  remediation?: string;
+  /** What Kafka Connect answered, when the error came from Connect. */
+  upstream?: {
+    status: number;
+    error_code?: number;
+    message?: string;
+  };
 }
 
 class KafkaConnectApiError extends Error {
@@ -30,6 +30,12 @@ export const API_CONFIG = {
   clusterId: process.env.NEXT_PUBLIC_CLUSTER_ID || 'default',
 };
 
+/**
+ * Placeholder the static export (NEXT_OUTPUT=export) renders dynamic routes for, since
+ * connector names are only known at runtime. The proxy serves that page for every name.
+ */
+export const STATIC_EXPORT_PARAM = '_';
+
 /**
  * Get the appropriate proxy URL based on execution context
  * @param forceClient - Force client URL even in SSR context
@@ -1,6 +1,7 @@
 /** @type {import('next').NextConfig} */
 const nextConfig = {
-  output: 'standalone',
+  // NEXT_OUTPUT=export builds the static export the proxy can embed (make build-embedded).
+  output: process.env.NEXT_OUTPUT === 'export' ? 'export' : 'standalone',
   reactStrictMode: true,
   // Enable detailed error messages in development
   productionBrowserSourceMaps: false,